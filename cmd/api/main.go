@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,11 +11,43 @@ import (
 	"time"
 
 	"github.com/streaming-service/internal/api"
+	"github.com/streaming-service/internal/auth"
 	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/diagnostics"
+	"github.com/streaming-service/internal/events"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/cloudfront"
 	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/kms"
+	"github.com/streaming-service/internal/repository/meilisearch"
 	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/repository/secrets"
+	"github.com/streaming-service/internal/repository/sentry"
+	"github.com/streaming-service/internal/rpc"
+	"github.com/streaming-service/internal/service/ads"
+	"github.com/streaming-service/internal/service/analytics"
+	"github.com/streaming-service/internal/service/apikeys"
+	"github.com/streaming-service/internal/service/audit"
+	"github.com/streaming-service/internal/service/captions"
+	"github.com/streaming-service/internal/service/channels"
+	"github.com/streaming-service/internal/service/collections"
+	"github.com/streaming-service/internal/service/embed"
+	"github.com/streaming-service/internal/service/enrich"
+	"github.com/streaming-service/internal/service/estimate"
+	"github.com/streaming-service/internal/service/export"
+	"github.com/streaming-service/internal/service/idempotency"
+	"github.com/streaming-service/internal/service/jobs"
+	"github.com/streaming-service/internal/service/keys"
+	"github.com/streaming-service/internal/service/live"
+	"github.com/streaming-service/internal/service/moderation"
+	"github.com/streaming-service/internal/service/notify"
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/internal/service/retention"
+	"github.com/streaming-service/internal/service/search"
 	"github.com/streaming-service/internal/service/stream"
 	"github.com/streaming-service/internal/service/upload"
+	"github.com/streaming-service/internal/service/webhooks"
+	"github.com/streaming-service/internal/token"
 	"github.com/streaming-service/pkg/logger"
 )
 
@@ -25,14 +58,39 @@ func main() {
 		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
 		os.Exit(1)
 	}
+	if err := cfg.Validate(cfg.Live.Enabled); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	// Initialize logger
-	log := logger.New(cfg.Log.Level, cfg.Log.Format)
+	log, err := logger.NewWithOptions(cfg.Log.Options())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
 	log.Info("starting streaming service api", "version", cfg.App.Version)
 
 	// Initialize AWS clients
 	ctx := context.Background()
 
+	// Resolve secrets referenced from Secrets Manager or Parameter Store
+	if err := secrets.ResolveConfig(ctx, cfg); err != nil {
+		log.Error("failed to resolve secrets", "error", err)
+		os.Exit(1)
+	}
+
+	// Report errors and panics to Sentry
+	if cfg.ErrorReporting.DSN != "" {
+		reporter, err := sentry.NewClient(cfg.ErrorReporting, cfg.App)
+		if err != nil {
+			log.Error("failed to initialize error reporting", "error", err)
+			os.Exit(1)
+		}
+		log = log.WithReporter(reporter)
+		defer reporter.Flush(cfg.ErrorReporting.FlushTimeout)
+	}
+
 	s3Client, err := s3.NewClient(ctx, cfg.AWS)
 	if err != nil {
 		log.Error("failed to initialize S3 client", "error", err)
@@ -45,15 +103,286 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Initialize job queue
+	jobQueue, err := queue.NewRedisQueue(cfg.Redis)
+	if err != nil {
+		log.Error("failed to initialize job queue", "error", err)
+		os.Exit(1)
+	}
+
+	// Record each job as it's queued, for the job status endpoints
+	jobsService := jobs.NewService(dynamoClient, cfg.Worker.JobRetention, log)
+	recordedQueue := jobsService.Queue(jobQueue)
+	if cfg.Server.Metrics {
+		queue.MeasureDepth(jobQueue)
+	}
+
 	// Initialize services
 	uploadService := upload.NewService(s3Client, dynamoClient, log)
+	uploadService.SetQueue(recordedQueue)
+	uploadService.SetAllowlist(cfg.Upload)
+	uploadService.SetTagIndex(dynamoClient)
 	streamService := stream.NewService(s3Client, dynamoClient, cfg.AWS.CloudFrontDomain, log)
+	analyticsService := analytics.NewService(dynamoClient, log)
+	adsService := ads.NewService(s3Client, dynamoClient, log)
+	collectionsService := collections.NewService(dynamoClient, streamService, log)
+	channelsService := channels.NewService(s3Client, dynamoClient, streamService, cfg.AWS.CloudFrontDomain, log)
+
+	// Enable server-side ad insertion if a provider is configured
+	ssaiProvider, err := ads.NewSSAIProvider(cfg.Ads)
+	if err != nil {
+		log.Error("failed to initialize SSAI provider", "error", err)
+		os.Exit(1)
+	}
+	if ssaiProvider != nil {
+		adsService.SetProvider(ssaiProvider)
+		adsService.SetTargetingParams(cfg.Ads.TargetingParams)
+		streamService.SetManifestConditioner(adsService)
+	}
+
+	// Enable CDN invalidation if a distribution is configured
+	var cdnClient *cloudfront.Client
+	if cfg.AWS.CloudFrontDistributionID != "" {
+		var err error
+		cdnClient, err = cloudfront.NewClient(ctx, cfg.AWS)
+		if err != nil {
+			log.Error("failed to initialize CloudFront client", "error", err)
+			os.Exit(1)
+		}
+		streamService.SetCDN(cdnClient)
+		adsService.SetCDN(cdnClient)
+	}
+
+	// Playback and embed tokens are signed with the same secret
+	signer := token.NewSigner(cfg.Playback.TokenSecret)
+
+	// Enable AES-128 encryption with KMS-wrapped content keys
+	var keysService *keys.Service
+	if cfg.Encryption.Enabled {
+		kmsClient, err := kms.NewClient(ctx, cfg.AWS, cfg.Encryption.KMSKeyID)
+		if err != nil {
+			log.Error("failed to initialize KMS client", "error", err)
+			os.Exit(1)
+		}
+		keysService = keys.NewService(dynamoClient, kmsClient, signer, cfg.Encryption, cfg.Playback.TokenTTL, log)
+	}
+
+	// Give players of DRM-protected media their license servers
+	if cfg.DRM.Enabled {
+		streamService.SetDRMLicenses(cfg.DRM.Licenses)
+	}
+
+	// Let owners embed media on third-party sites with signed tokens
+	var embedService *embed.Service
+	if cfg.Playback.EmbedEnabled {
+		if cfg.Playback.TokenSecret == "" {
+			log.Error("playback.tokensecret is required for embed tokens")
+			os.Exit(1)
+		}
+		embedService = embed.NewService(dynamoClient, signer, cfg.Playback, log)
+	}
+
+	// Enable live streaming with WebRTC (WHIP) ingest
+	var liveService *live.Service
+	var whipIngest *live.WHIPIngest
+	var packager *live.Packager
+	if cfg.Live.Enabled {
+		packager = live.NewPackager(s3Client, cfg.FFMPEG, cfg.Live, log)
+		liveService = live.NewService(dynamoClient, packager, cfg.Live, log)
+
+		whipIngest, err = live.NewWHIPIngest(liveService, cfg.Live, log)
+		if err != nil {
+			log.Error("failed to initialize WHIP ingest", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Enable full-text search, indexing media as it changes
+	var searchService *search.Service
+	var searchClient *meilisearch.Client
+	if cfg.Search.Enabled {
+		searchClient = meilisearch.NewClient(cfg.Search)
+		searchService = search.NewService(dynamoClient, searchClient, log)
+		if err := searchService.Init(ctx); err != nil {
+			log.Error("failed to initialize search index", "error", err)
+			os.Exit(1)
+		}
+		uploadService.SetSearch(searchService)
+		streamService.SetSearch(searchService)
+	}
+
+	// Users' webhooks; the worker sends their deliveries
+	var webhooksService *webhooks.Service
+	if cfg.Webhooks.Enabled {
+		webhooksService = webhooks.NewService(dynamoClient, cfg.Webhooks, log)
+	}
+
+	// Publish uploads and deletions for downstream systems and webhooks,
+	// through the outbox the worker relays when it's enabled
+	if cfg.Outbox.Enabled {
+		uploadService.SetOutbox(true)
+		streamService.SetOutbox(true)
+	} else if cfg.Events.Enabled || webhooksService != nil {
+		dispatcher := events.NewDispatcher(log)
+		if cfg.Events.Enabled {
+			bus, err := events.NewBus(ctx, cfg.AWS, cfg.Events)
+			if err != nil {
+				log.Error("failed to initialize event bus", "error", err)
+				os.Exit(1)
+			}
+			dispatcher.Subscribe(cfg.Events.Provider, bus)
+			log.Info("event publishing enabled", "provider", cfg.Events.Provider)
+		}
+		if webhooksService != nil {
+			dispatcher.Subscribe("webhooks", webhooksService)
+		}
+		uploadService.SetEvents(dispatcher)
+		streamService.SetEvents(dispatcher)
+	}
+
+	// Authenticate API callers with JWTs from the configured provider
+	var verifier *auth.Verifier
+	if cfg.Auth.Enabled {
+		authCfg := cfg.Auth
+		if authCfg.Region == "" {
+			authCfg.Region = cfg.AWS.Region
+		}
+		verifier, err = auth.NewVerifier(ctx, authCfg, log)
+		if err != nil {
+			log.Error("failed to initialize JWT verifier", "error", err)
+			os.Exit(1)
+		}
+	} else {
+		log.Warn("authentication disabled, trusting X-User-ID header")
+	}
+
+	// Authenticate server-to-server callers with API keys
+	var apiKeysService *apikeys.Service
+	if cfg.APIKeys.Enabled {
+		apiKeysService = apikeys.NewService(dynamoClient, cfg.APIKeys, log)
+	}
+
+	// Replay responses to POSTs retried with the same Idempotency-Key
+	var idempotencyService *idempotency.Service
+	if cfg.Idempotency.Enabled {
+		idempotencyService = idempotency.NewService(dynamoClient, cfg.Idempotency, log)
+	}
+
+	// Forecast processing cost with the workers' rendition ladder
+	estimateService := estimate.NewService(cfg.FFMPEG, log)
+
+	// Enforce per-tenant storage, transcode minute and job quotas
+	var quotasService *quotas.Service
+	if cfg.Quotas.Enabled {
+		quotasService = quotas.NewService(dynamoClient, cfg.Quotas, log)
+		uploadService.SetQuotas(quotasService)
+		streamService.SetQuotas(quotasService)
+	}
+
+	// Take subtitle uploads, and queue translations of subtitle tracks
+	// for the worker
+	captionsService := captions.NewService(s3Client, dynamoClient, log)
+	captionsService.SetSegmentDuration(time.Duration(cfg.FFMPEG.SegmentDuration) * time.Second)
+	if cdnClient != nil {
+		captionsService.SetCDN(cdnClient)
+	}
+	if cfg.Translation.Enabled {
+		captionsService.SetQueue(recordedQueue)
+	}
+
+	// Queue chapter and summary generation for the worker
+	var enrichService *enrich.Service
+	if cfg.Enrichment.Enabled {
+		enrichService = enrich.NewService(s3Client, dynamoClient, nil, cfg.Enrichment, log)
+		enrichService.SetQueue(recordedQueue)
+	}
+
+	// Expire media after its tenant's retention; the worker deletes or
+	// archives it
+	var retentionService *retention.Service
+	if cfg.Retention.Enabled {
+		retentionService = retention.NewService(dynamoClient, streamService, cfg.Retention, log)
+		uploadService.SetRetention(cfg.Retention)
+	}
+
+	// Queue export packages for the worker to build
+	var exportService *export.Service
+	if cfg.Export.Enabled {
+		exportService = export.NewService(s3Client, dynamoClient, cfg.Export, log)
+		exportService.SetQueue(recordedQueue)
+	}
+
+	// Manage the preferences for the emails the worker sends
+	var notifyService *notify.Service
+	if cfg.Notifications.Enabled {
+		notifyService = notify.NewService(dynamoClient, nil, cfg.Notifications, cfg.AWS.CloudFrontDomain, log)
+	}
+
+	// Serve the review queue of media flagged by moderation
+	var moderationService *moderation.Service
+	if cfg.Moderation.Enabled {
+		moderationService = moderation.NewService(dynamoClient, cfg.Moderation, log)
+		moderationService.SetSearch(searchService)
+	}
+
+	// Record mutating API calls for compliance
+	var auditService *audit.Service
+	if cfg.Audit.Enabled {
+		auditService = audit.NewService(dynamoClient, cfg.Audit, log)
+	}
+
+	// Dependencies probed by /ready; playback and reads keep working
+	// without the job queue, search or live packaging, so those only
+	// degrade readiness
+	readinessChecks := []api.ReadinessCheck{
+		{Name: "dynamodb", Critical: true, Check: dynamoClient.Ping},
+		{Name: "s3", Critical: true, Check: s3Client.Ping},
+		{Name: "redis", Check: jobQueue.Ping},
+	}
+	if searchClient != nil {
+		readinessChecks = append(readinessChecks, api.ReadinessCheck{Name: "search", Check: searchClient.Ping})
+	}
+	if packager != nil {
+		readinessChecks = append(readinessChecks, api.ReadinessCheck{Name: "ffmpeg", Check: packager.Ping})
+	}
 
 	// Initialize HTTP router
 	router := api.NewRouter(api.RouterConfig{
-		UploadService: uploadService,
-		StreamService: streamService,
-		Logger:        log,
+		UploadService:      uploadService,
+		StreamService:      streamService,
+		AnalyticsService:   analyticsService,
+		AdsService:         adsService,
+		KeysService:        keysService,
+		LiveService:        liveService,
+		WHIPIngest:         whipIngest,
+		APIKeysService:     apiKeysService,
+		SearchService:      searchService,
+		CollectionsService: collectionsService,
+		ChannelsService:    channelsService,
+		EmbedService:       embedService,
+		IdempotencyService: idempotencyService,
+		AuditService:       auditService,
+		EstimateService:    estimateService,
+		QuotasService:      quotasService,
+		ModerationService:  moderationService,
+		CaptionsService:    captionsService,
+		EnrichService:      enrichService,
+		NotifyService:      notifyService,
+		RetentionService:   retentionService,
+		ExportService:      exportService,
+		WebhooksService:    webhooksService,
+		JobsService:        jobsService,
+		AdminScope:         cfg.Auth.AdminScope,
+		BodyLimits: api.BodyLimits{
+			Default: cfg.Server.MaxBodySize,
+			Upload:  cfg.Server.MaxUploadSize,
+			Artwork: cfg.Server.MaxArtworkSize,
+		},
+		ReadinessChecks:  readinessChecks,
+		ReadinessTimeout: cfg.Server.ReadinessTimeout,
+		Metrics:          cfg.Server.Metrics,
+		Verifier:         verifier,
+		Logger:           log,
 	})
 
 	// Create HTTP server
@@ -74,6 +403,60 @@ func main() {
 		}
 	}()
 
+	// Serve the gRPC API on its own port
+	var rpcServer *rpc.Server
+	if cfg.Server.GRPCPort != 0 {
+		rpcServer = rpc.NewServer(verifier, cfg.Server.IdleTimeout, log)
+		if apiKeysService != nil {
+			rpcServer.SetAPIKeys(apiKeysService)
+		}
+		if auditService != nil {
+			rpcServer.SetAudit(auditService)
+		}
+		rpcServer.RegisterUploadService(uploadService)
+		rpcServer.RegisterMediaService(streamService)
+		rpcServer.RegisterStreamService(streamService, keysService)
+
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.GRPCPort))
+		if err != nil {
+			log.Error("failed to listen for grpc", "error", err)
+			os.Exit(1)
+		}
+
+		go func() {
+			log.Info("grpc server listening", "port", cfg.Server.GRPCPort)
+			if err := rpcServer.Serve(lis); err != nil {
+				log.Error("grpc server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// Serve pprof and the queue's depth on the debug listener
+	if cfg.Debug.Addr != "" {
+		diag := diagnostics.NewServer(log)
+		diag.Add("queue", func(ctx context.Context) (interface{}, error) {
+			return jobQueue.Depth(ctx)
+		})
+		go diag.ListenAndServe(ctx, cfg.Debug.Addr)
+	}
+
+	// Apply settings changed in the config file without restarting
+	cfg.Watch(func(next *config.Config, err error) {
+		if err != nil {
+			log.Error("failed to reload config", "error", err)
+			return
+		}
+		if err := log.SetLevel(next.Log.Level); err != nil {
+			log.Warn("ignoring invalid log level", "level", next.Log.Level)
+		}
+		if apiKeysService != nil {
+			apiKeysService.SetRateLimits(next.APIKeys)
+		}
+		estimateService.SetProfiles(next.FFMPEG)
+		log.Info("config reloaded", "log_level", next.Log.Level)
+	})
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -85,10 +468,21 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if rpcServer != nil {
+		if err := rpcServer.Shutdown(shutdownCtx); err != nil {
+			log.Error("grpc server forced to shutdown", "error", err)
+		}
+	}
+
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Error("server forced to shutdown", "error", err)
 		os.Exit(1)
 	}
 
+	// End live sessions so their playlists are finalized
+	if whipIngest != nil {
+		whipIngest.Shutdown()
+	}
+
 	log.Info("server stopped")
 }
@@ -0,0 +1,278 @@
+// Command dev runs the API and the transcoding worker in one process with
+// no external services: jobs go through an in-memory queue, buckets are
+// directories and metadata is kept in an embedded store, all under a
+// local data directory. Only ffmpeg needs to be installed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/streaming-service/internal/api"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/diagnostics"
+	"github.com/streaming-service/internal/media/ffmpeg"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/service/ads"
+	"github.com/streaming-service/internal/service/analytics"
+	"github.com/streaming-service/internal/service/apikeys"
+	"github.com/streaming-service/internal/service/audit"
+	"github.com/streaming-service/internal/service/captions"
+	"github.com/streaming-service/internal/service/channels"
+	"github.com/streaming-service/internal/service/collections"
+	"github.com/streaming-service/internal/service/estimate"
+	"github.com/streaming-service/internal/service/export"
+	"github.com/streaming-service/internal/service/idempotency"
+	"github.com/streaming-service/internal/service/jobs"
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/internal/service/retention"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/service/transcode"
+	"github.com/streaming-service/internal/service/upload"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// filesPath is where the filesystem buckets are served, standing in for
+// presigned S3 URLs and the CDN
+const filesPath = "/files"
+
+func main() {
+	dataDir := flag.String("data", ".dev", "directory holding the buckets and metadata")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Turn off everything that needs a service beyond ffmpeg
+	cfg.Auth.Enabled = false
+	cfg.Search.Enabled = false
+	cfg.Encryption.Enabled = false
+	cfg.DRM.Enabled = false
+	cfg.Live.Enabled = false
+	cfg.Ads.SSAIProvider = ""
+	cfg.ErrorReporting.DSN = ""
+	cfg.AWS.CloudFrontDistributionID = ""
+	cfg.AWS.CloudFrontLogBucket = ""
+	cfg.Server.GRPCPort = 0
+
+	baseURL := fmt.Sprintf("http://localhost:%d%s", cfg.Server.Port, filesPath)
+	cfg.AWS.CloudFrontDomain = baseURL + "/" + cfg.AWS.S3ProcessedBucket
+
+	if err := cfg.Validate(true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Initialize logger
+	log, err := logger.NewWithOptions(cfg.Log.Options())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	log.Info("starting streaming service in dev mode", "version", cfg.App.Version, "data", *dataDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Initialize local stand-ins for S3, DynamoDB and Redis
+	s3Client, files, err := s3.NewFilesystemClient(cfg.AWS, filepath.Join(*dataDir, "buckets"), baseURL)
+	if err != nil {
+		log.Error("failed to initialize filesystem storage", "error", err)
+		os.Exit(1)
+	}
+
+	dynamoClient, err := dynamodb.NewEmbeddedClient(cfg.AWS, filepath.Join(*dataDir, "metadata.json"))
+	if err != nil {
+		log.Error("failed to initialize embedded metadata store", "error", err)
+		os.Exit(1)
+	}
+
+	jobQueue := queue.NewMemoryQueue()
+	jobsService := jobs.NewService(dynamoClient, cfg.Worker.JobRetention, log)
+	recordedQueue := jobsService.Queue(jobQueue)
+	if cfg.Server.Metrics {
+		queue.MeasureDepth(jobQueue)
+	}
+
+	// Initialize API services
+	uploadService := upload.NewService(s3Client, dynamoClient, log)
+	uploadService.SetQueue(recordedQueue)
+	uploadService.SetAllowlist(cfg.Upload)
+	uploadService.SetTagIndex(dynamoClient)
+	streamService := stream.NewService(s3Client, dynamoClient, cfg.AWS.CloudFrontDomain, log)
+	analyticsService := analytics.NewService(dynamoClient, log)
+	adsService := ads.NewService(s3Client, dynamoClient, log)
+	collectionsService := collections.NewService(dynamoClient, streamService, log)
+	channelsService := channels.NewService(s3Client, dynamoClient, streamService, cfg.AWS.CloudFrontDomain, log)
+	estimateService := estimate.NewService(cfg.FFMPEG, log)
+	captionsService := captions.NewService(s3Client, dynamoClient, log)
+	captionsService.SetSegmentDuration(time.Duration(cfg.FFMPEG.SegmentDuration) * time.Second)
+
+	var apiKeysService *apikeys.Service
+	if cfg.APIKeys.Enabled {
+		apiKeysService = apikeys.NewService(dynamoClient, cfg.APIKeys, log)
+	}
+
+	var idempotencyService *idempotency.Service
+	if cfg.Idempotency.Enabled {
+		idempotencyService = idempotency.NewService(dynamoClient, cfg.Idempotency, log)
+	}
+
+	var auditService *audit.Service
+	if cfg.Audit.Enabled {
+		auditService = audit.NewService(dynamoClient, cfg.Audit, log)
+	}
+
+	// Initialize FFMPEG processor and transcode service
+	ffmpegProcessor := ffmpeg.NewProcessor(cfg.FFMPEG)
+	transcodeService := transcode.NewService(s3Client, dynamoClient, ffmpegProcessor, log)
+	transcodeService.SetProfiles(cfg.FFMPEG.Profiles)
+	transcodeService.SetKeepVersions(cfg.Versions.Keep)
+
+	var quotasService *quotas.Service
+	if cfg.Quotas.Enabled {
+		quotasService = quotas.NewService(dynamoClient, cfg.Quotas, log)
+		uploadService.SetQuotas(quotasService)
+		streamService.SetQuotas(quotasService)
+		transcodeService.SetQuotas(quotasService)
+	}
+
+	var retentionService *retention.Service
+	if cfg.Retention.Enabled {
+		retentionService = retention.NewService(dynamoClient, streamService, cfg.Retention, log)
+		uploadService.SetRetention(cfg.Retention)
+		go retentionService.Run(ctx)
+	}
+
+	var exportService *export.Service
+	if cfg.Export.Enabled {
+		exportService = export.NewService(s3Client, dynamoClient, cfg.Export, log)
+		exportService.SetQueue(recordedQueue)
+		exportService.SetRemuxer(ffmpegProcessor)
+	}
+
+	log.Warn("authentication disabled, trusting X-User-ID header")
+
+	router := api.NewRouter(api.RouterConfig{
+		UploadService:      uploadService,
+		StreamService:      streamService,
+		AnalyticsService:   analyticsService,
+		AdsService:         adsService,
+		APIKeysService:     apiKeysService,
+		CollectionsService: collectionsService,
+		ChannelsService:    channelsService,
+		IdempotencyService: idempotencyService,
+		AuditService:       auditService,
+		EstimateService:    estimateService,
+		QuotasService:      quotasService,
+		RetentionService:   retentionService,
+		ExportService:      exportService,
+		CaptionsService:    captionsService,
+		JobsService:        jobsService,
+		AdminScope:         cfg.Auth.AdminScope,
+		BodyLimits: api.BodyLimits{
+			Default: cfg.Server.MaxBodySize,
+			Upload:  cfg.Server.MaxUploadSize,
+			Artwork: cfg.Server.MaxArtworkSize,
+		},
+		// Without ffmpeg, uploads queue until it is back
+		ReadinessChecks: []api.ReadinessCheck{
+			{Name: "dynamodb", Critical: true, Check: dynamoClient.Ping},
+			{Name: "s3", Critical: true, Check: s3Client.Ping},
+			{Name: "ffmpeg", Check: ffmpegProcessor.Ping},
+		},
+		ReadinessTimeout: cfg.Server.ReadinessTimeout,
+		Metrics:          cfg.Server.Metrics,
+		Logger:           log,
+	})
+
+	// Serve presigned uploads and playback from the filesystem buckets,
+	// outside the API's middleware and body limits
+	mux := http.NewServeMux()
+	mux.Handle(filesPath+"/", http.StripPrefix(filesPath, files.Handler()))
+	mux.Handle("/", router)
+
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:      mux,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	go func() {
+		log.Info("server listening", "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("server error", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Create worker pool
+	worker := transcode.NewWorker(jobQueue, transcodeService, cfg.Worker.Concurrency, log)
+	worker.SetJobs(jobsService)
+	// Scheduled jobs claim their quota job slot once due
+	jobQueue.SetAdmitter(worker)
+	worker.SetJobTimeout(cfg.Worker.JobTimeout)
+	if exportService != nil {
+		worker.SetExport(exportService)
+	}
+	worker.SetDependencies([]transcode.DependencyCheck{
+		{Name: "ffmpeg", Check: ffmpegProcessor.Ping},
+	}, cfg.Worker.HealthCheckInterval, cfg.Worker.HealthCheckTimeout)
+
+	go func() {
+		log.Info("worker started", "concurrency", cfg.Worker.Concurrency)
+		if err := worker.Start(ctx); err != nil {
+			log.Error("worker error", "error", err)
+			cancel()
+		}
+	}()
+
+	if cfg.Debug.Addr != "" {
+		diag := diagnostics.NewServer(log)
+		diag.Add("worker", func(ctx context.Context) (interface{}, error) {
+			return worker.Stats(), nil
+		})
+		diag.Add("queue", func(ctx context.Context) (interface{}, error) {
+			return jobQueue.Depth(ctx)
+		})
+		diag.Add("disk", diagnostics.DiskUsage(cfg.FFMPEG.TempDir, filepath.Join(os.TempDir(), "streaming")))
+		go diag.ListenAndServe(ctx, cfg.Debug.Addr)
+	}
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info("shutting down...")
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("server forced to shutdown", "error", err)
+	}
+
+	// Jobs still queued are lost with the in-memory queue, so let running
+	// ones finish
+	cancel()
+	if !worker.Drain(cfg.Worker.DrainTimeout) {
+		log.Warn("drain deadline passed, unfinished jobs dropped")
+	}
+	log.Info("stopped")
+}
@@ -0,0 +1,67 @@
+// Command e2e uploads fixture media through the API, waits for the worker
+// to transcode it with a fake processor and plays it back, exiting
+// non-zero if any step fails. It needs neither AWS nor ffmpeg, so CI can
+// run it against local storage or LocalStack.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/streaming-service/internal/e2e"
+	"github.com/streaming-service/pkg/logger"
+)
+
+func main() {
+	localStack := flag.Bool("localstack", false, "use LocalStack at AWS_ENDPOINT_URL instead of local storage")
+	fixture := flag.String("fixture", e2e.SampleVideo, "fixture media to upload")
+	timeout := flag.Duration("timeout", 2*time.Minute, "how long the run may take")
+	verbose := flag.Bool("v", false, "log the services' output")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	if err := run(ctx, *localStack, *fixture, *verbose); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, localStack bool, fixture string, verbose bool) error {
+	level := "error"
+	if verbose {
+		level = "debug"
+	}
+	log, err := logger.NewWithOptions(logger.Options{
+		Level:   level,
+		Format:  "console",
+		Outputs: []string{logger.OutputStderr},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	h, err := e2e.New(ctx, e2e.Options{LocalStack: localStack, Logger: log})
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	start := time.Now()
+	playback, err := h.Run(ctx, fixture)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ok: %s played back with %d renditions, %d segments, %d cover art sizes and %d storyboard sprites in %s\n",
+		fixture, len(playback.Playlists), playback.Segments, playback.CoverArt, playback.Sprites, time.Since(start).Round(time.Millisecond))
+	return nil
+}
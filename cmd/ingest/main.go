@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/media/rtmp"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/repository/secrets"
+	"github.com/streaming-service/internal/repository/sentry"
+	"github.com/streaming-service/internal/service/live"
+	"github.com/streaming-service/pkg/logger"
+)
+
+func main() {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.Validate(true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !cfg.Live.Enabled {
+		fmt.Fprintln(os.Stderr, "live.enabled must be true to run the ingest server")
+		os.Exit(1)
+	}
+
+	// Initialize logger
+	log, err := logger.NewWithOptions(cfg.Log.Options())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	log.Info("starting RTMP ingest server", "version", cfg.App.Version, "addr", cfg.Live.RTMPAddr)
+
+	ctx := context.Background()
+
+	// Resolve secrets referenced from Secrets Manager or Parameter Store
+	if err := secrets.ResolveConfig(ctx, cfg); err != nil {
+		log.Error("failed to resolve secrets", "error", err)
+		os.Exit(1)
+	}
+
+	// Report errors and panics to Sentry
+	if cfg.ErrorReporting.DSN != "" {
+		reporter, err := sentry.NewClient(cfg.ErrorReporting, cfg.App)
+		if err != nil {
+			log.Error("failed to initialize error reporting", "error", err)
+			os.Exit(1)
+		}
+		log = log.WithReporter(reporter)
+		defer reporter.Flush(cfg.ErrorReporting.FlushTimeout)
+	}
+
+	// Initialize AWS clients
+	s3Client, err := s3.NewClient(ctx, cfg.AWS)
+	if err != nil {
+		log.Error("failed to initialize S3 client", "error", err)
+		os.Exit(1)
+	}
+
+	dynamoClient, err := dynamodb.NewClient(ctx, cfg.AWS)
+	if err != nil {
+		log.Error("failed to initialize DynamoDB client", "error", err)
+		os.Exit(1)
+	}
+
+	// Package pushed streams to live HLS, published to S3 as they're cut
+	packager := live.NewPackager(s3Client, cfg.FFMPEG, cfg.Live, log)
+	liveService := live.NewService(dynamoClient, packager, cfg.Live, log)
+	ingest := live.NewRTMPIngest(liveService, cfg.Live, log)
+
+	go func() {
+		if err := ingest.ListenAndServe(cfg.Live.RTMPAddr); err != nil && !errors.Is(err, rtmp.ErrServerClosed) {
+			log.Error("failed to start RTMP server", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	// End live sessions so their playlists are finalized
+	log.Info("shutting down ingest server...")
+	ingest.Shutdown()
+
+	log.Info("ingest server stopped")
+}
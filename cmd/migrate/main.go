@@ -0,0 +1,100 @@
+// Command migrate creates the DynamoDB tables and indexes, S3 buckets and
+// bucket rules the service needs, and checks existing ones and the Redis
+// queue keys match what the service expects. It is safe to run repeatedly.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/repository/secrets"
+	"github.com/streaming-service/pkg/logger"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report changes without making them")
+	timeout := flag.Duration("timeout", 30*time.Minute, "how long to wait for tables and indexes to be created")
+	skipRedis := flag.Bool("skip-redis", false, "don't check the Redis queue keys")
+	rewriteTimes := flag.Bool("rewrite-times", false, "rewrite times indexes sort by that earlier versions stored unsortably")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	if err := run(ctx, *dryRun, *skipRedis, *rewriteTimes); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dryRun, skipRedis, rewriteTimes bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.Validate(false); err != nil {
+		return err
+	}
+	if err := secrets.ResolveConfig(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	log, err := logger.NewWithOptions(logger.Options{
+		Level:   cfg.Log.Level,
+		Format:  "console",
+		Outputs: []string{logger.OutputStderr},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	tables, err := dynamodb.NewMigrator(ctx, cfg.AWS, log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize DynamoDB client: %w", err)
+	}
+	if err := tables.Migrate(ctx, dryRun); err != nil {
+		return err
+	}
+	log.Info("dynamodb tables checked")
+
+	if rewriteTimes {
+		if err := tables.RewriteTimes(ctx, dryRun); err != nil {
+			return err
+		}
+	}
+
+	buckets, err := s3.NewMigrator(ctx, cfg.AWS, log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize S3 client: %w", err)
+	}
+	if err := buckets.Migrate(ctx, dryRun); err != nil {
+		return err
+	}
+	log.Info("s3 buckets checked")
+
+	if !skipRedis {
+		jobQueue, err := queue.NewRedisQueue(cfg.Redis)
+		if err != nil {
+			return fmt.Errorf("failed to initialize job queue: %w", err)
+		}
+		defer jobQueue.Close()
+
+		if err := jobQueue.Verify(ctx); err != nil {
+			return err
+		}
+		log.Info("redis queue keys checked")
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newInvalidateCommand(a *app) *cobra.Command {
+	var mediaID string
+
+	cmd := &cobra.Command{
+		Use:   "invalidate [PATH...]",
+		Short: "Invalidate cached CDN paths",
+		Long: "Invalidate cached CDN paths, such as /media/abc123/*, or with --media\n" +
+			"every file of a media item.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths := args
+			if mediaID != "" {
+				dynamoClient, err := a.dynamo(cmd.Context())
+				if err != nil {
+					return err
+				}
+				media, err := dynamoClient.GetMedia(a.context(cmd.Context()), mediaID)
+				if err != nil {
+					return err
+				}
+				paths = append(paths, "/"+media.GetOutputPrefix()+"*")
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("give paths to invalidate or --media")
+			}
+
+			cdn, err := a.cloudfront(cmd.Context())
+			if err != nil {
+				return err
+			}
+			id, err := cdn.InvalidatePaths(cmd.Context(), paths)
+			if err != nil {
+				return err
+			}
+			fmt.Println("created invalidation", id)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&mediaID, "media", "", "invalidate every file of this media item")
+	return cmd
+}
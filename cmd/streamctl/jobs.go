@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/streaming-service/internal/queue"
+)
+
+// jobStates are listed in the order a job moves through them
+var jobStates = []queue.JobState{queue.JobStateScheduled, queue.JobStatePending, queue.JobStateProcessing, queue.JobStateDead}
+
+func newJobsCommand(a *app) *cobra.Command {
+	var state string
+
+	cmd := &cobra.Command{
+		Use:   "jobs [MEDIA_ID]",
+		Short: "List scheduled, queued, running and dead jobs",
+		Long: "List scheduled, queued, running and dead jobs, optionally only those of one media\n" +
+			"item, whose status and processing stage are printed too.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			states := jobStates
+			if state != "" {
+				states = []queue.JobState{queue.JobState(state)}
+			}
+
+			jobQueue, err := a.queue()
+			if err != nil {
+				return err
+			}
+
+			var mediaID string
+			if len(args) == 1 {
+				mediaID = args[0]
+				if err := a.printMediaStatus(cmd, mediaID); err != nil {
+					return err
+				}
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "STATE\tJOB\tTYPE\tMEDIA\tTENANT\tATTEMPTS\tCREATED\tRUN AT")
+			for _, s := range states {
+				jobs, err := jobQueue.Jobs(cmd.Context(), s)
+				if err != nil {
+					return err
+				}
+				for _, job := range jobs {
+					if mediaID != "" && job.MediaID != mediaID {
+						continue
+					}
+					runAt := "-"
+					if job.RunAt != nil {
+						runAt = job.RunAt.Format(time.RFC3339)
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+						s, job.ID, job.Type, job.MediaID, job.TenantID, job.Attempts, job.CreatedAt.Format(time.RFC3339), runAt)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&state, "state", "", "only list jobs that are scheduled, pending, processing or dead")
+	return cmd
+}
+
+// printMediaStatus prints a media item's status and processing stage
+func (a *app) printMediaStatus(cmd *cobra.Command, mediaID string) error {
+	dynamoClient, err := a.dynamo(cmd.Context())
+	if err != nil {
+		return err
+	}
+	media, err := dynamoClient.GetMedia(a.context(cmd.Context()), mediaID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("media %s: %s", media.ID, media.Status)
+	if p := media.Processing; p != nil {
+		fmt.Printf(", stage %s", p.Stage)
+		if p.FailedStage != "" {
+			fmt.Printf(" in %s", p.FailedStage)
+		}
+		fmt.Printf(", updated %s", p.UpdatedAt.Format(time.RFC3339))
+	}
+	fmt.Print("\n\n")
+	return nil
+}
+
+func newDLQCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dlq",
+		Short: "Manage the dead letter queue of jobs that failed every attempt",
+	}
+
+	var yes bool
+	purge := &cobra.Command{
+		Use:   "purge",
+		Short: "Discard every job in the dead letter queue",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobQueue, err := a.queue()
+			if err != nil {
+				return err
+			}
+
+			if !yes {
+				jobs, err := jobQueue.Jobs(cmd.Context(), queue.JobStateDead)
+				if err != nil {
+					return err
+				}
+				return fmt.Errorf("%d dead jobs would be discarded; rerun with --yes to purge them", len(jobs))
+			}
+
+			n, err := jobQueue.PurgeDead(cmd.Context())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("purged %d dead jobs\n", n)
+			return nil
+		},
+	}
+	purge.Flags().BoolVar(&yes, "yes", false, "confirm discarding the jobs")
+
+	cmd.AddCommand(purge)
+	return cmd
+}
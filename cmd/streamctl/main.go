@@ -0,0 +1,170 @@
+// Command streamctl performs common operational tasks against a
+// deployment's S3 buckets, DynamoDB tables, job queue and CDN, using the
+// same configuration as the API and worker.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/cloudfront"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/repository/secrets"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// app holds the configuration and clients shared by every command.
+// Clients are connected on first use, so commands only need the services
+// they touch.
+type app struct {
+	cfg      *config.Config
+	log      *logger.Logger
+	tenantID string
+
+	s3Client     *s3.Client
+	dynamoClient *dynamodb.Client
+	jobQueue     *queue.RedisQueue
+	cdn          *cloudfront.Client
+}
+
+// newRootCommand builds the streamctl command tree
+func newRootCommand() *cobra.Command {
+	a := &app{}
+
+	root := &cobra.Command{
+		Use:           "streamctl",
+		Short:         "Operate the streaming service",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return a.init(cmd.Context())
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if a.jobQueue != nil {
+				_ = a.jobQueue.Close()
+			}
+		},
+	}
+	root.PersistentFlags().StringVar(&a.tenantID, "tenant", tenant.Default, "tenant whose media is operated on")
+
+	root.AddCommand(
+		newUploadCommand(a),
+		newRequeueCommand(a),
+		newMediaCommand(a),
+		newJobsCommand(a),
+		newDLQCommand(a),
+		newInvalidateCommand(a),
+	)
+	return root
+}
+
+// init loads and validates the configuration, resolves its secrets and
+// starts logging to stderr, leaving stdout for command output
+func (a *app) init(ctx context.Context) error {
+	if !tenant.Valid(a.tenantID) {
+		return fmt.Errorf("invalid tenant %q", a.tenantID)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.Validate(false); err != nil {
+		return err
+	}
+	if err := secrets.ResolveConfig(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	log, err := logger.NewWithOptions(logger.Options{
+		Level:   cfg.Log.Level,
+		Format:  "console",
+		Outputs: []string{logger.OutputStderr},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	a.cfg = cfg
+	a.log = log
+	return nil
+}
+
+// context scopes ctx to the --tenant flag
+func (a *app) context(ctx context.Context) context.Context {
+	return tenant.WithID(ctx, a.tenantID)
+}
+
+func (a *app) s3(ctx context.Context) (*s3.Client, error) {
+	if a.s3Client == nil {
+		client, err := s3.NewClient(ctx, a.cfg.AWS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize S3 client: %w", err)
+		}
+		a.s3Client = client
+	}
+	return a.s3Client, nil
+}
+
+func (a *app) dynamo(ctx context.Context) (*dynamodb.Client, error) {
+	if a.dynamoClient == nil {
+		client, err := dynamodb.NewClient(ctx, a.cfg.AWS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize DynamoDB client: %w", err)
+		}
+		a.dynamoClient = client
+	}
+	return a.dynamoClient, nil
+}
+
+func (a *app) queue() (*queue.RedisQueue, error) {
+	if a.jobQueue == nil {
+		q, err := queue.NewRedisQueue(a.cfg.Redis)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize job queue: %w", err)
+		}
+		a.jobQueue = q
+	}
+	return a.jobQueue, nil
+}
+
+func (a *app) cloudfront(ctx context.Context) (*cloudfront.Client, error) {
+	if a.cfg.AWS.CloudFrontDistributionID == "" {
+		return nil, fmt.Errorf("aws.cloudfrontdistributionid is not configured")
+	}
+	if a.cdn == nil {
+		client, err := cloudfront.NewClient(ctx, a.cfg.AWS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize CloudFront client: %w", err)
+		}
+		a.cdn = client
+	}
+	return a.cdn, nil
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/meilisearch"
+	"github.com/streaming-service/internal/service/jobs"
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/internal/service/search"
+	"github.com/streaming-service/internal/service/upload"
+)
+
+// uploadService builds the upload service the API uses, queueing and
+// recording jobs and keeping quotas and the search index in step
+func (a *app) uploadService(cmd *cobra.Command) (*upload.Service, error) {
+	ctx := cmd.Context()
+	s3Client, err := a.s3(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dynamoClient, err := a.dynamo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobQueue, err := a.queue()
+	if err != nil {
+		return nil, err
+	}
+
+	svc := upload.NewService(s3Client, dynamoClient, a.log)
+	svc.SetQueue(jobs.NewService(dynamoClient, a.cfg.Worker.JobRetention, a.log).Queue(jobQueue))
+	svc.SetTagIndex(dynamoClient)
+	if a.cfg.Quotas.Enabled {
+		svc.SetQuotas(quotas.NewService(dynamoClient, a.cfg.Quotas, a.log))
+	}
+	if a.cfg.Search.Enabled {
+		svc.SetSearch(search.NewService(dynamoClient, meilisearch.NewClient(a.cfg.Search), a.log))
+	}
+	return svc, nil
+}
+
+func newUploadCommand(a *app) *cobra.Command {
+	var req upload.UploadRequest
+	var visibility string
+
+	cmd := &cobra.Command{
+		Use:   "upload FILE",
+		Short: "Upload a media file on behalf of a user and queue it for processing",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch domain.Visibility(visibility) {
+			case domain.VisibilityPublic, domain.VisibilityUnlisted, domain.VisibilityPrivate:
+			default:
+				return fmt.Errorf("--visibility must be public, unlisted or private")
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			info, err := f.Stat()
+			if err != nil {
+				return err
+			}
+
+			svc, err := a.uploadService(cmd)
+			if err != nil {
+				return err
+			}
+
+			req.Filename = filepath.Base(args[0])
+			if req.Title == "" {
+				req.Title = req.Filename
+			}
+			req.ContentType = mime.TypeByExtension(filepath.Ext(req.Filename))
+			req.Visibility = domain.Visibility(visibility)
+			req.Body = f
+			req.Size = info.Size()
+
+			resp, err := svc.Upload(a.context(cmd.Context()), &req)
+			if err != nil {
+				return err
+			}
+			return printJSON(resp)
+		},
+	}
+	cmd.Flags().StringVar(&req.UserID, "user", "", "ID of the user who will own the media (required)")
+	cmd.Flags().StringVar(&req.Title, "title", "", "title, the file name by default")
+	cmd.Flags().StringVar(&req.Description, "description", "", "description")
+	cmd.Flags().StringVar(&visibility, "visibility", string(domain.VisibilityPublic), "public, unlisted or private")
+	cmd.Flags().StringToStringVar(&req.Tags, "tag", nil, "tag as key=value, repeatable")
+	_ = cmd.MarkFlagRequired("user")
+	return cmd
+}
+
+func newRequeueCommand(a *app) *cobra.Command {
+	var runAt string
+
+	cmd := &cobra.Command{
+		Use:   "requeue MEDIA_ID...",
+		Short: "Queue media for processing again from their source files",
+		Long: "Queue media for processing again from their source files, whoever owns\n" +
+			"them and whatever their status, such as media stuck in processing, or\n" +
+			"with --at, schedule it for later, such as off-peak hours.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var at time.Time
+			if runAt != "" {
+				var err error
+				if at, err = time.Parse(time.RFC3339, runAt); err != nil {
+					return fmt.Errorf("invalid --at: %w", err)
+				}
+			}
+
+			svc, err := a.uploadService(cmd)
+			if err != nil {
+				return err
+			}
+
+			ctx := a.context(cmd.Context())
+			for _, id := range args {
+				if err := svc.Requeue(ctx, id, at); err != nil {
+					return fmt.Errorf("failed to requeue %s: %w", id, err)
+				}
+				if at.After(time.Now()) {
+					fmt.Println("scheduled", id, "for", at.Format(time.RFC3339))
+				} else {
+					fmt.Println("queued", id)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&runAt, "at", "", "queue the media at this RFC 3339 time rather than now")
+	return cmd
+}
+
+func newMediaCommand(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "media MEDIA_ID",
+		Short: "Print a media record as JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dynamoClient, err := a.dynamo(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			media, err := dynamoClient.GetMedia(a.context(cmd.Context()), args[0])
+			if err != nil {
+				return err
+			}
+			return printJSON(media)
+		},
+	}
+}
@@ -3,17 +3,54 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"syscall"
+	"time"
 
 	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/diagnostics"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/events"
+	"github.com/streaming-service/internal/media/chromaprint"
 	"github.com/streaming-service/internal/media/ffmpeg"
+	"github.com/streaming-service/internal/media/transcript"
 	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/cloudfront"
 	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/kms"
+	"github.com/streaming-service/internal/repository/llm"
+	"github.com/streaming-service/internal/repository/meilisearch"
+	"github.com/streaming-service/internal/repository/rekognition"
 	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/repository/secrets"
+	"github.com/streaming-service/internal/repository/sentry"
+	"github.com/streaming-service/internal/repository/ses"
+	"github.com/streaming-service/internal/repository/smtp"
+	"github.com/streaming-service/internal/repository/speke"
+	"github.com/streaming-service/internal/repository/transcribe"
+	"github.com/streaming-service/internal/repository/translate"
+	"github.com/streaming-service/internal/service/analytics"
+	"github.com/streaming-service/internal/service/captions"
+	"github.com/streaming-service/internal/service/copyright"
+	"github.com/streaming-service/internal/service/enrich"
+	"github.com/streaming-service/internal/service/export"
+	"github.com/streaming-service/internal/service/jobs"
+	"github.com/streaming-service/internal/service/keys"
+	"github.com/streaming-service/internal/service/moderation"
+	"github.com/streaming-service/internal/service/notify"
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/internal/service/retention"
+	"github.com/streaming-service/internal/service/search"
+	"github.com/streaming-service/internal/service/stream"
 	"github.com/streaming-service/internal/service/transcode"
+	"github.com/streaming-service/internal/service/webhooks"
+	"github.com/streaming-service/internal/token"
 	"github.com/streaming-service/pkg/logger"
+	"github.com/streaming-service/pkg/metrics"
 )
 
 func main() {
@@ -23,14 +60,39 @@ func main() {
 		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
 		os.Exit(1)
 	}
+	if err := cfg.Validate(true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	// Initialize logger
-	log := logger.New(cfg.Log.Level, cfg.Log.Format)
+	log, err := logger.NewWithOptions(cfg.Log.Options())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
 	log.Info("starting transcoding worker", "version", cfg.App.Version)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Resolve secrets referenced from Secrets Manager or Parameter Store
+	if err := secrets.ResolveConfig(ctx, cfg); err != nil {
+		log.Error("failed to resolve secrets", "error", err)
+		os.Exit(1)
+	}
+
+	// Report errors and panics to Sentry
+	if cfg.ErrorReporting.DSN != "" {
+		reporter, err := sentry.NewClient(cfg.ErrorReporting, cfg.App)
+		if err != nil {
+			log.Error("failed to initialize error reporting", "error", err)
+			os.Exit(1)
+		}
+		log = log.WithReporter(reporter)
+		defer reporter.Flush(cfg.ErrorReporting.FlushTimeout)
+	}
+
 	// Initialize AWS clients
 	s3Client, err := s3.NewClient(ctx, cfg.AWS)
 	if err != nil {
@@ -50,6 +112,11 @@ func main() {
 		log.Error("failed to initialize job queue", "error", err)
 		os.Exit(1)
 	}
+	jobQueue.SetLease(cfg.Worker.JobLease)
+
+	// Record each job as it's queued, started and finished
+	jobsService := jobs.NewService(dynamoClient, cfg.Worker.JobRetention, log)
+	recordedQueue := jobsService.Queue(jobQueue)
 
 	// Initialize FFMPEG processor
 	ffmpegProcessor := ffmpeg.NewProcessor(cfg.FFMPEG)
@@ -61,6 +128,123 @@ func main() {
 		ffmpegProcessor,
 		log,
 	)
+	transcodeService.SetProfiles(cfg.FFMPEG.Profiles)
+	transcodeService.SetKeepVersions(cfg.Versions.Keep)
+
+	// Enable CDN invalidation if a distribution is configured
+	var cdnClient *cloudfront.Client
+	if cfg.AWS.CloudFrontDistributionID != "" {
+		cdnClient, err = cloudfront.NewClient(ctx, cfg.AWS)
+		if err != nil {
+			log.Error("failed to initialize CloudFront client", "error", err)
+			os.Exit(1)
+		}
+		transcodeService.SetCDN(cdnClient)
+	}
+
+	// Enable AES-128 encryption with KMS-wrapped content keys
+	if cfg.Encryption.Enabled {
+		kmsClient, err := kms.NewClient(ctx, cfg.AWS, cfg.Encryption.KMSKeyID)
+		if err != nil {
+			log.Error("failed to initialize KMS client", "error", err)
+			os.Exit(1)
+		}
+		signer := token.NewSigner(cfg.Playback.TokenSecret)
+		transcodeService.SetKeys(keys.NewService(dynamoClient, kmsClient, signer, cfg.Encryption, cfg.Playback.TokenTTL, log))
+	}
+
+	// Protect CMAF segments with Common Encryption for each DRM system
+	// given a license server
+	if cfg.DRM.Enabled {
+		systems := make([]domain.DRMSystem, 0, len(cfg.DRM.Licenses))
+		for system := range cfg.DRM.Licenses {
+			systems = append(systems, domain.DRMSystem(system))
+		}
+		sort.Slice(systems, func(i, j int) bool { return systems[i] < systems[j] })
+		transcodeService.SetDRM(speke.NewClient(cfg.DRM), systems)
+	}
+
+	// Scan sources against content policy before their media is published
+	if cfg.Moderation.Enabled {
+		rekognitionClient, err := rekognition.NewClient(ctx, cfg.AWS, cfg.Moderation.MinConfidence)
+		if err != nil {
+			log.Error("failed to initialize Rekognition client", "error", err)
+			os.Exit(1)
+		}
+		moderationService := moderation.NewService(dynamoClient, cfg.Moderation, log)
+		moderationService.SetScanner(rekognitionClient, ffmpegProcessor)
+		transcodeService.SetModeration(moderationService)
+	}
+
+	// Flag media whose audio matches copyrighted recordings
+	if cfg.Copyright.Enabled {
+		references, err := copyright.LoadReferences(cfg.Copyright.References)
+		if err != nil {
+			log.Error("failed to load copyright references", "error", err)
+			os.Exit(1)
+		}
+		fingerprinter := chromaprint.NewFingerprinter(cfg.Copyright.BinaryPath, cfg.Copyright.Length)
+		transcodeService.SetCopyright(copyright.NewService(dynamoClient, fingerprinter, references, cfg.Copyright, log))
+		log.Info("copyright matching enabled", "references", len(references))
+	}
+
+	// Transcribe captions from speech, translate subtitle tracks into more
+	// languages and generate chapters and summaries from them
+	var captionsService *captions.Service
+	if cfg.Transcribe.Enabled || cfg.Translation.Enabled || cfg.Enrichment.Enabled {
+		captionsService = captions.NewService(s3Client, dynamoClient, log)
+		captionsService.SetSegmentDuration(time.Duration(cfg.FFMPEG.SegmentDuration) * time.Second)
+		if cdnClient != nil {
+			captionsService.SetCDN(cdnClient)
+		}
+	}
+	if cfg.Translation.Enabled {
+		translateClient, err := translate.NewClient(ctx, cfg.AWS)
+		if err != nil {
+			log.Error("failed to initialize Translate client", "error", err)
+			os.Exit(1)
+		}
+		captionsService.SetTranslator(translateClient)
+	}
+	if cfg.Transcribe.Enabled {
+		transcribeClient, err := transcribe.NewClient(ctx, cfg.AWS, cfg.Transcribe)
+		if err != nil {
+			log.Error("failed to initialize Transcribe client", "error", err)
+			os.Exit(1)
+		}
+		captionsService.SetTranscriber(transcribeClient, ffmpegProcessor)
+		captionsService.SetTranscriptFilter(transcript.NewFilter(cfg.Transcript))
+		captionsService.SetQueue(recordedQueue)
+	}
+	var enrichService *enrich.Service
+	if cfg.Enrichment.Enabled {
+		enrichService = enrich.NewService(s3Client, dynamoClient, captionsService, cfg.Enrichment, log)
+		enrichService.SetProvider(enrich.NewLLMProvider(llm.NewClient(cfg.Enrichment)))
+		if cdnClient != nil {
+			enrichService.SetCDN(cdnClient)
+		}
+	}
+
+	// Keep the search index in step with processing status
+	var searchService *search.Service
+	if cfg.Search.Enabled {
+		searchService = search.NewService(dynamoClient, meilisearch.NewClient(cfg.Search), log)
+		if err := searchService.Init(ctx); err != nil {
+			log.Error("failed to initialize search index", "error", err)
+			os.Exit(1)
+		}
+		transcodeService.SetSearch(searchService)
+		if captionsService != nil {
+			captionsService.SetSearch(searchService)
+		}
+	}
+
+	// Count processed output and transcode minutes against tenant quotas
+	var quotasService *quotas.Service
+	if cfg.Quotas.Enabled {
+		quotasService = quotas.NewService(dynamoClient, cfg.Quotas, log)
+		transcodeService.SetQuotas(quotasService)
+	}
 
 	// Create worker pool
 	worker := transcode.NewWorker(
@@ -69,6 +253,98 @@ func main() {
 		cfg.Worker.Concurrency,
 		log,
 	)
+	worker.SetJobs(jobsService)
+	worker.SetJobTimeout(cfg.Worker.JobTimeout)
+	// Requeue the jobs of workers that crash mid-job
+	worker.SetLeases(jobQueue, cfg.Worker.JobLease, cfg.Worker.ReapInterval)
+	worker.SetPromoter(jobQueue, cfg.Worker.PromoteInterval)
+	// Scheduled jobs claim their quota job slot once due
+	jobQueue.SetAdmitter(worker)
+	typeConcurrency := make(map[queue.JobType]int, len(cfg.Worker.TypeConcurrency))
+	for jobType, limit := range cfg.Worker.TypeConcurrency {
+		typeConcurrency[queue.JobType(jobType)] = limit
+	}
+	worker.SetTypeConcurrency(typeConcurrency)
+	if captionsService != nil {
+		worker.SetCaptions(captionsService)
+	}
+	if enrichService != nil {
+		worker.SetEnrichment(enrichService)
+	}
+	if cfg.Export.Enabled {
+		exportService := export.NewService(s3Client, dynamoClient, cfg.Export, log)
+		exportService.SetRemuxer(ffmpegProcessor)
+		worker.SetExport(exportService)
+	}
+
+	// Publish processing outcomes for downstream systems and webhooks, and
+	// email users when their uploads finish processing or fail
+	dispatcher := events.NewDispatcher(log)
+	if cfg.Events.Enabled {
+		bus, err := events.NewBus(ctx, cfg.AWS, cfg.Events)
+		if err != nil {
+			log.Error("failed to initialize event bus", "error", err)
+			os.Exit(1)
+		}
+		dispatcher.Subscribe(cfg.Events.Provider, bus)
+		log.Info("event publishing enabled", "provider", cfg.Events.Provider)
+	}
+	if cfg.Notifications.Enabled {
+		var sender notify.Sender
+		switch cfg.Notifications.Provider {
+		case "smtp":
+			sender = smtp.NewClient(cfg.Notifications.SMTP, cfg.Notifications.From)
+		default:
+			sesClient, err := ses.NewClient(ctx, cfg.AWS, cfg.Notifications.From)
+			if err != nil {
+				log.Error("failed to initialize SES client", "error", err)
+				os.Exit(1)
+			}
+			sender = sesClient
+		}
+		dispatcher.Subscribe("notifications", notify.NewService(dynamoClient, sender, cfg.Notifications, cfg.AWS.CloudFrontDomain, log))
+		log.Info("notifications enabled", "provider", cfg.Notifications.Provider)
+	}
+	if cfg.Webhooks.Enabled {
+		webhooksService := webhooks.NewService(dynamoClient, cfg.Webhooks, log)
+		dispatcher.Subscribe("webhooks", webhooksService)
+		go webhooksService.Run(ctx)
+		log.Info("webhook delivery started")
+	}
+	worker.SetEvents(dispatcher)
+
+	// Deliver the events the services write to the outbox
+	if cfg.Outbox.Enabled {
+		transcodeService.SetOutbox(true)
+		go events.NewRelay(dynamoClient, dispatcher, cfg.Outbox, log).Run(ctx)
+		log.Info("outbox relay started")
+	}
+
+	// Delete or archive media as it expires, cleaning up after it as an
+	// API deletion would
+	if cfg.Retention.Enabled {
+		streamService := stream.NewService(s3Client, dynamoClient, cfg.AWS.CloudFrontDomain, log)
+		if cdnClient != nil {
+			streamService.SetCDN(cdnClient)
+		}
+		streamService.SetSearch(searchService)
+		streamService.SetQuotas(quotasService)
+		if cfg.Outbox.Enabled {
+			streamService.SetOutbox(true)
+		} else {
+			streamService.SetEvents(dispatcher)
+		}
+		go retention.NewService(dynamoClient, streamService, cfg.Retention, log).Run(ctx)
+		log.Info("media retention started", "action", cfg.Retention.Action)
+	}
+
+	// Only dequeue while the dependencies needed to process jobs are up
+	worker.SetDependencies([]transcode.DependencyCheck{
+		{Name: "s3", Check: s3Client.Ping},
+		{Name: "dynamodb", Check: dynamoClient.Ping},
+		{Name: "redis", Check: jobQueue.Ping},
+		{Name: "ffmpeg", Check: ffmpegProcessor.Ping},
+	}, cfg.Worker.HealthCheckInterval, cfg.Worker.HealthCheckTimeout)
 
 	// Start worker
 	go func() {
@@ -79,15 +355,88 @@ func main() {
 		}
 	}()
 
+	// Serve metrics for Prometheus on their own port
+	var metricsServer *http.Server
+	if cfg.Worker.MetricsPort != 0 {
+		queue.MeasureDepth(jobQueue)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              fmt.Sprintf(":%d", cfg.Worker.MetricsPort),
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		go func() {
+			log.Info("metrics server listening", "port", cfg.Worker.MetricsPort)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("metrics server error", "error", err)
+			}
+		}()
+	}
+
+	// Serve pprof, the worker's jobs, the queue's depth and the disk
+	// used by temp files on the debug listener
+	if cfg.Debug.Addr != "" {
+		diag := diagnostics.NewServer(log)
+		diag.Add("worker", func(ctx context.Context) (interface{}, error) {
+			return worker.Stats(), nil
+		})
+		diag.Add("queue", func(ctx context.Context) (interface{}, error) {
+			return jobQueue.Depth(ctx)
+		})
+		diag.Add("disk", diagnostics.DiskUsage(cfg.FFMPEG.TempDir, filepath.Join(os.TempDir(), "streaming")))
+		go diag.ListenAndServe(ctx, cfg.Debug.Addr)
+	}
+
+	// Start CDN log ingestion if a log bucket is configured
+	if cfg.AWS.CloudFrontLogBucket != "" {
+		ingester := analytics.NewLogIngester(
+			s3Client,
+			dynamoClient,
+			cfg.AWS.CloudFrontLogBucket,
+			cfg.AWS.CloudFrontLogPrefix,
+			cfg.AWS.CloudFrontLogArchivePrefix,
+			cfg.FFMPEG.SegmentDuration,
+			log,
+		)
+		go ingester.Run(ctx, cfg.Worker.LogIngestInterval)
+		log.Info("cloudfront log ingestion started", "bucket", cfg.AWS.CloudFrontLogBucket)
+	}
+
+	// Apply settings changed in the config file without restarting
+	cfg.Watch(func(next *config.Config, err error) {
+		if err != nil {
+			log.Error("failed to reload config", "error", err)
+			return
+		}
+		if err := log.SetLevel(next.Log.Level); err != nil {
+			log.Warn("ignoring invalid log level", "level", next.Log.Level)
+		}
+		transcodeService.SetProfiles(next.FFMPEG.Profiles)
+		if next.Worker.Concurrency > 0 {
+			worker.SetConcurrency(next.Worker.Concurrency)
+		} else {
+			log.Warn("ignoring invalid worker concurrency", "concurrency", next.Worker.Concurrency)
+		}
+		log.Info("config reloaded", "log_level", next.Log.Level, "profiles", len(next.FFMPEG.Profiles),
+			"concurrency", next.Worker.Concurrency)
+	})
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Info("shutting down worker...")
+	log.Info("shutting down worker...", "drain_timeout", cfg.Worker.DrainTimeout)
 	cancel()
 
-	// Wait for worker to finish current jobs
-	worker.Wait()
+	// Give in-flight jobs until the drain deadline to finish
+	if !worker.Drain(cfg.Worker.DrainTimeout) {
+		log.Warn("drain deadline passed, unfinished jobs requeued")
+	}
+	if metricsServer != nil {
+		_ = metricsServer.Close()
+	}
 	log.Info("worker stopped")
 }
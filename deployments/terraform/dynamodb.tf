@@ -25,6 +25,26 @@ resource "aws_dynamodb_table" "video_metadata" {
     type = "S"
   }
 
+  attribute {
+    name = "channel_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "moderation_status"
+    type = "S"
+  }
+
+  attribute {
+    name = "expiring"
+    type = "S"
+  }
+
+  attribute {
+    name = "expires_at"
+    type = "S"
+  }
+
   # GSI for querying by user
   global_secondary_index {
     name            = "user_id-index"
@@ -33,6 +53,14 @@ resource "aws_dynamodb_table" "video_metadata" {
     projection_type = "ALL"
   }
 
+  # GSI for channel pages; only published media carry channel_id
+  global_secondary_index {
+    name            = "channel_id-index"
+    hash_key        = "channel_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
   # GSI for querying by status (for worker processing)
   global_secondary_index {
     name            = "status-index"
@@ -41,6 +69,24 @@ resource "aws_dynamodb_table" "video_metadata" {
     projection_type = "ALL"
   }
 
+  # GSI for the moderation review queue; only scanned media carry
+  # moderation_status
+  global_secondary_index {
+    name            = "moderation_status-index"
+    hash_key        = "moderation_status"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # GSI for the retention job; only media with an expiration carry
+  # expiring
+  global_secondary_index {
+    name            = "expiring-index"
+    hash_key        = "expiring"
+    range_key       = "expires_at"
+    projection_type = "ALL"
+  }
+
   point_in_time_recovery {
     enabled = var.environment == "production"
   }
@@ -52,6 +98,357 @@ resource "aws_dynamodb_table" "video_metadata" {
   tags = local.tags
 }
 
+# DynamoDB Table for view counters and playback analytics
+resource "aws_dynamodb_table" "media_analytics" {
+  name         = "${var.project_name}-analytics-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key  = "pk"
+  range_key = "sk"
+
+  attribute {
+    name = "pk"
+    type = "S"
+  }
+
+  attribute {
+    name = "sk"
+    type = "S"
+  }
+
+  # Session dedup markers expire on their own
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for KMS-wrapped HLS content keys
+resource "aws_dynamodb_table" "content_keys" {
+  name         = "${var.project_name}-keys-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key  = "media_id"
+  range_key = "key_id"
+
+  attribute {
+    name = "media_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "key_id"
+    type = "S"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for live streams and stream keys
+resource "aws_dynamodb_table" "live_streams" {
+  name         = "${var.project_name}-live-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "stream_key"
+    type = "S"
+  }
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  # GSI for authenticating publishers by stream key
+  global_secondary_index {
+    name            = "stream_key-index"
+    hash_key        = "stream_key"
+    projection_type = "ALL"
+  }
+
+  # GSI for querying by user
+  global_secondary_index {
+    name            = "user_id-index"
+    hash_key        = "user_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for API keys
+resource "aws_dynamodb_table" "api_keys" {
+  name         = "${var.project_name}-api-keys-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "key_hash"
+    type = "S"
+  }
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  # GSI for authenticating callers by key hash
+  global_secondary_index {
+    name            = "key_hash-index"
+    hash_key        = "key_hash"
+    projection_type = "ALL"
+  }
+
+  # GSI for querying by user
+  global_secondary_index {
+    name            = "user_id-index"
+    hash_key        = "user_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for Tag index: media IDs by tag key and key:value
+resource "aws_dynamodb_table" "media_tags" {
+  name         = "${var.project_name}-tags-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key  = "tag"
+  range_key = "media_id"
+
+  attribute {
+    name = "tag"
+    type = "S"
+  }
+
+  attribute {
+    name = "media_id"
+    type = "S"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for media collections
+resource "aws_dynamodb_table" "collections" {
+  name         = "${var.project_name}-collections-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  # GSI for querying by user
+  global_secondary_index {
+    name            = "user_id-index"
+    hash_key        = "user_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for creator channels
+resource "aws_dynamodb_table" "channels" {
+  name         = "${var.project_name}-channels-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  # GSI for querying by user
+  global_secondary_index {
+    name            = "user_id-index"
+    hash_key        = "user_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for Idempotency-Key records and replayable responses
+resource "aws_dynamodb_table" "idempotency_keys" {
+  name         = "${var.project_name}-idempotency-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  # Keys can be reused once their records expire
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for the audit log of mutating API calls
+resource "aws_dynamodb_table" "audit_log" {
+  name         = "${var.project_name}-audit-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "actor_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "resource"
+    type = "S"
+  }
+
+  attribute {
+    name = "day"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  # GSI for a caller's history
+  global_secondary_index {
+    name            = "actor_id-index"
+    hash_key        = "actor_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # GSI for a resource's history
+  global_secondary_index {
+    name            = "resource-index"
+    hash_key        = "resource"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # GSI for browsing a day's calls
+  global_secondary_index {
+    name            = "day-index"
+    hash_key        = "day"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # Entries are deleted once past the retention period
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  point_in_time_recovery {
+    enabled = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
 # DynamoDB Table for Terraform State Locking
 resource "aws_dynamodb_table" "terraform_locks" {
   count = var.environment == "production" ? 1 : 0
@@ -67,3 +464,232 @@ resource "aws_dynamodb_table" "terraform_locks" {
 
   tags = local.tags
 }
+
+# DynamoDB Table for per-tenant quota usage counters
+resource "aws_dynamodb_table" "quotas" {
+  name         = "${var.project_name}-quotas-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  # Monthly transcode minute counters expire after a year
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for per-user settings such as notification preferences
+resource "aws_dynamodb_table" "user_preferences" {
+  name         = "${var.project_name}-user-preferences-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for the transactional outbox of media lifecycle events
+resource "aws_dynamodb_table" "event_outbox" {
+  name         = "${var.project_name}-event-outbox-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "status"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  # GSI for the relay to find pending events, oldest first
+  global_secondary_index {
+    name            = "status-index"
+    hash_key        = "status"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # Delivered events are deleted once past the retention period
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for users' webhook subscriptions
+resource "aws_dynamodb_table" "webhooks" {
+  name         = "${var.project_name}-webhooks-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "user_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  # GSI for querying by user
+  global_secondary_index {
+    name            = "user_id-index"
+    hash_key        = "user_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for the delivery log of webhook events
+resource "aws_dynamodb_table" "webhook_deliveries" {
+  name         = "${var.project_name}-webhook-deliveries-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "webhook_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  attribute {
+    name = "status"
+    type = "S"
+  }
+
+  attribute {
+    name = "next_attempt_at"
+    type = "S"
+  }
+
+  # GSI for a webhook's delivery log, newest first
+  global_secondary_index {
+    name            = "webhook_id-index"
+    hash_key        = "webhook_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # GSI for the worker to find pending deliveries that are due; only
+  # pending deliveries have next_attempt_at
+  global_secondary_index {
+    name            = "status-index"
+    hash_key        = "status"
+    range_key       = "next_attempt_at"
+    projection_type = "ALL"
+  }
+
+  # Finished deliveries are deleted once past the retention period
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB Table for the records of processing jobs
+resource "aws_dynamodb_table" "jobs" {
+  name         = "${var.project_name}-jobs-${var.environment}"
+  billing_mode = var.dynamodb_billing_mode
+
+  hash_key = "id"
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "media_id"
+    type = "S"
+  }
+
+  attribute {
+    name = "created_at"
+    type = "S"
+  }
+
+  # GSI for a media item's job history, newest first
+  global_secondary_index {
+    name            = "media_id-index"
+    hash_key        = "media_id"
+    range_key       = "created_at"
+    projection_type = "ALL"
+  }
+
+  # Finished jobs are deleted once past the retention period
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
+  server_side_encryption {
+    enabled = true
+  }
+
+  tags = local.tags
+}
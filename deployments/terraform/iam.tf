@@ -67,13 +67,98 @@ resource "aws_iam_role_policy" "dynamodb_access" {
           "dynamodb:UpdateItem",
           "dynamodb:DeleteItem",
           "dynamodb:Query",
-          "dynamodb:Scan"
+          "dynamodb:Scan",
+          "dynamodb:BatchGetItem",
+          "dynamodb:BatchWriteItem",
+          "dynamodb:DescribeTable"
         ]
         Resource = [
           aws_dynamodb_table.video_metadata.arn,
-          "${aws_dynamodb_table.video_metadata.arn}/index/*"
+          "${aws_dynamodb_table.video_metadata.arn}/index/*",
+          aws_dynamodb_table.media_analytics.arn,
+          aws_dynamodb_table.content_keys.arn,
+          "${aws_dynamodb_table.content_keys.arn}/index/*",
+          aws_dynamodb_table.live_streams.arn,
+          "${aws_dynamodb_table.live_streams.arn}/index/*",
+          aws_dynamodb_table.api_keys.arn,
+          "${aws_dynamodb_table.api_keys.arn}/index/*",
+          aws_dynamodb_table.media_tags.arn,
+          "${aws_dynamodb_table.media_tags.arn}/index/*",
+          aws_dynamodb_table.collections.arn,
+          "${aws_dynamodb_table.collections.arn}/index/*",
+          aws_dynamodb_table.channels.arn,
+          "${aws_dynamodb_table.channels.arn}/index/*",
+          aws_dynamodb_table.idempotency_keys.arn,
+          aws_dynamodb_table.audit_log.arn,
+          "${aws_dynamodb_table.audit_log.arn}/index/*",
+          aws_dynamodb_table.quotas.arn,
+          aws_dynamodb_table.user_preferences.arn,
+          aws_dynamodb_table.event_outbox.arn,
+          "${aws_dynamodb_table.event_outbox.arn}/index/*",
+          aws_dynamodb_table.webhooks.arn,
+          "${aws_dynamodb_table.webhooks.arn}/index/*",
+          aws_dynamodb_table.webhook_deliveries.arn,
+          "${aws_dynamodb_table.webhook_deliveries.arn}/index/*",
+          aws_dynamodb_table.jobs.arn,
+          "${aws_dynamodb_table.jobs.arn}/index/*"
         ]
       }
     ]
   })
 }
+
+# CloudFront Invalidation Policy
+resource "aws_iam_role_policy" "cloudfront_invalidation" {
+  name = "cloudfront-invalidation"
+  role = aws_iam_role.app.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Effect   = "Allow"
+        Action   = ["cloudfront:CreateInvalidation"]
+        Resource = [aws_cloudfront_distribution.cdn.arn]
+      }
+    ]
+  })
+}
+
+# KMS Access Policy for content keys
+resource "aws_iam_role_policy" "kms_content_keys" {
+  name = "kms-content-keys"
+  role = aws_iam_role.app.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Effect   = "Allow"
+        Action   = ["kms:Encrypt", "kms:Decrypt"]
+        Resource = [aws_kms_key.content_keys.arn]
+      }
+    ]
+  })
+}
+
+# Secrets referenced from config, kept under the project's prefix
+resource "aws_iam_role_policy" "config_secrets" {
+  name = "config-secrets"
+  role = aws_iam_role.app.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Effect   = "Allow"
+        Action   = ["secretsmanager:GetSecretValue"]
+        Resource = ["arn:aws:secretsmanager:${var.aws_region}:${data.aws_caller_identity.current.account_id}:secret:${var.project_name}/*"]
+      },
+      {
+        Effect   = "Allow"
+        Action   = ["ssm:GetParameter"]
+        Resource = ["arn:aws:ssm:${var.aws_region}:${data.aws_caller_identity.current.account_id}:parameter/${var.project_name}/*"]
+      }
+    ]
+  })
+}
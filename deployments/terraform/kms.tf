@@ -0,0 +1,13 @@
+# KMS key wrapping HLS AES-128 content keys
+resource "aws_kms_key" "content_keys" {
+  description             = "${var.project_name} content key encryption - ${var.environment}"
+  deletion_window_in_days = 30
+  enable_key_rotation     = true
+
+  tags = local.tags
+}
+
+resource "aws_kms_alias" "content_keys" {
+  name          = "alias/${var.project_name}-content-keys-${var.environment}"
+  target_key_id = aws_kms_key.content_keys.key_id
+}
@@ -36,7 +36,23 @@ resource "kubernetes_config_map" "app_config" {
         s3rawbucket: ${aws_s3_bucket.raw_media.id}
         s3processedbucket: ${aws_s3_bucket.processed_media.id}
         dynamodbtable: ${aws_dynamodb_table.video_metadata.name}
+        analyticstable: ${aws_dynamodb_table.media_analytics.name}
+        keystable: ${aws_dynamodb_table.content_keys.name}
+        livetable: ${aws_dynamodb_table.live_streams.name}
+        apikeystable: ${aws_dynamodb_table.api_keys.name}
+        tagstable: ${aws_dynamodb_table.media_tags.name}
+        collectionstable: ${aws_dynamodb_table.collections.name}
+        channelstable: ${aws_dynamodb_table.channels.name}
+        idempotencytable: ${aws_dynamodb_table.idempotency_keys.name}
+        audittable: ${aws_dynamodb_table.audit_log.name}
+        quotastable: ${aws_dynamodb_table.quotas.name}
+        preferencestable: ${aws_dynamodb_table.user_preferences.name}
+        outboxtable: ${aws_dynamodb_table.event_outbox.name}
+        webhookstable: ${aws_dynamodb_table.webhooks.name}
+        deliveriestable: ${aws_dynamodb_table.webhook_deliveries.name}
+        jobstable: ${aws_dynamodb_table.jobs.name}
         cloudfrontdomain: ${aws_cloudfront_distribution.cdn.domain_name}
+        cloudfrontdistributionid: ${aws_cloudfront_distribution.cdn.id}
 
       redis:
         host: ${aws_elasticache_replication_group.redis.primary_endpoint_address}
@@ -49,6 +65,7 @@ resource "kubernetes_config_map" "app_config" {
       worker:
         concurrency: 4
         jobtimeout: 30m
+        draintimeout: 10m
 
       log:
         level: info
@@ -94,9 +111,15 @@ resource "kubernetes_deployment" "api" {
           image = local.api_image
 
           port {
+            name           = "http"
             container_port = 8080
           }
 
+          port {
+            name           = "grpc"
+            container_port = 9090
+          }
+
           resources {
             requests = {
               cpu    = var.api_cpu_request
@@ -124,6 +147,8 @@ resource "kubernetes_deployment" "api" {
             }
             initial_delay_seconds = 5
             period_seconds        = 5
+            # Dependency checks may take up to server.readinesstimeout
+            timeout_seconds = 3
           }
 
           volume_mount {
@@ -165,9 +190,16 @@ resource "kubernetes_service" "api" {
     }
 
     port {
+      name        = "http"
       port        = 80
       target_port = 8080
     }
+
+    port {
+      name        = "grpc"
+      port        = 9090
+      target_port = 9090
+    }
   }
 }
 
@@ -216,6 +248,9 @@ resource "kubernetes_deployment" "worker" {
           NodeType = "worker"
         }
 
+        # Leave time for worker.draintimeout, plus a margin to requeue
+        termination_grace_period_seconds = 630
+
         container {
           name  = "worker"
           image = local.worker_image
@@ -43,6 +43,21 @@ resource "aws_s3_bucket_lifecycle_configuration" "raw_media" {
       storage_class = "GLACIER"
     }
   }
+
+  # Export packages are downloaded through short-lived links; keep them a
+  # week
+  rule {
+    id     = "expire-exports"
+    status = "Enabled"
+
+    filter {
+      prefix = "exports/"
+    }
+
+    expiration {
+      days = 7
+    }
+  }
 }
 
 resource "aws_s3_bucket_cors_configuration" "raw_media" {
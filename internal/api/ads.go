@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/ads"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Ad breaks request body
+type adBreaksRequest struct {
+	AdBreaks []domain.AdBreak `json:"ad_breaks"`
+}
+
+func (req *adBreaksRequest) Validate(v *validate.Validator) {
+	for i, b := range req.AdBreaks {
+		v.Min(fmt.Sprintf("ad_breaks[%d].offset", i), b.Offset, 0)
+		v.Min(fmt.Sprintf("ad_breaks[%d].duration", i), b.Duration, 0)
+	}
+}
+
+// setAdBreaksHandler replaces the ad breaks of a media item
+func setAdBreaksHandler(svc *ads.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		if mediaID == "" {
+			respondError(w, http.StatusBadRequest, "media ID is required")
+			return
+		}
+
+		var body adBreaksRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		if err := svc.SetAdBreaks(r.Context(), mediaID, getUserID(r), body.AdBreaks); err != nil {
+			switch err {
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, "ad breaks must be non-overlapping and within the media duration")
+			default:
+				log.Error("failed to set ad breaks", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to set ad breaks")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, body)
+	}
+}
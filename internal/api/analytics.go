@@ -0,0 +1,256 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/analytics"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Player-supplied beacon field limits in characters
+const (
+	maxSessionIDLength   = 128
+	maxBeaconFieldLength = 64
+)
+
+// View request body
+type viewRequest struct {
+	SessionID string `json:"session_id"`
+}
+
+func (req *viewRequest) Validate(v *validate.Validator) {
+	v.MaxLength("session_id", req.SessionID, maxSessionIDLength)
+}
+
+// recordViewHandler counts a playback view
+func recordViewHandler(svc *analytics.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		if mediaID == "" {
+			respondError(w, http.StatusBadRequest, "media ID is required")
+			return
+		}
+
+		// Body is optional; clients without a session ID fall back to a fingerprint
+		var body viewRequest
+		if r.ContentLength > 0 {
+			if !decodeBody(w, r, &body) {
+				return
+			}
+		}
+
+		counted, err := svc.RecordView(r.Context(), mediaID, getSessionID(r, body.SessionID), getUserID(r))
+		if err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			log.Error("failed to record view", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to record view")
+			return
+		}
+
+		respondJSON(w, http.StatusAccepted, map[string]bool{
+			"counted": counted,
+		})
+	}
+}
+
+// Playback event request body
+type eventRequest struct {
+	SessionID string  `json:"session_id"`
+	Type      string  `json:"type"`
+	Position  float64 `json:"position"`
+	Watched   float64 `json:"watched"`
+	Device    string  `json:"device"`
+	Country   string  `json:"country"`
+}
+
+func (req *eventRequest) Validate(v *validate.Validator) {
+	v.MaxLength("session_id", req.SessionID, maxSessionIDLength)
+	v.Required("type", req.Type)
+	v.OneOf("type", req.Type, string(domain.PlaybackEventStart), string(domain.PlaybackEventProgress), string(domain.PlaybackEventComplete))
+	v.Min("position", req.Position, 0)
+	v.Min("watched", req.Watched, 0)
+	v.MaxLength("device", req.Device, maxBeaconFieldLength)
+	v.MaxLength("country", req.Country, maxBeaconFieldLength)
+}
+
+// recordEventHandler ingests a player analytics beacon
+func recordEventHandler(svc *analytics.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		if mediaID == "" {
+			respondError(w, http.StatusBadRequest, "media ID is required")
+			return
+		}
+
+		var body eventRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		event := &domain.PlaybackEvent{
+			MediaID:   mediaID,
+			SessionID: getSessionID(r, body.SessionID),
+			Type:      domain.PlaybackEventType(body.Type),
+			Position:  body.Position,
+			Watched:   body.Watched,
+			Device:    body.Device,
+			Country:   body.Country,
+			Timestamp: time.Now(),
+		}
+		if event.Device == "" {
+			event.Device = analytics.DeviceClass(r.UserAgent())
+		}
+		if event.Country == "" {
+			// Populated when CloudFront forwards viewer country headers
+			event.Country = r.Header.Get("CloudFront-Viewer-Country")
+		}
+
+		if err := svc.RecordEvent(r.Context(), event, getUserID(r)); err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid event")
+				return
+			}
+			log.Error("failed to record event", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to record event")
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// mediaAnalyticsHandler returns aggregated analytics for a media item
+func mediaAnalyticsHandler(svc *analytics.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		if mediaID == "" {
+			respondError(w, http.StatusBadRequest, "media ID is required")
+			return
+		}
+
+		days := 30
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 365 {
+				respondError(w, http.StatusBadRequest, "days must be between 1 and 365")
+				return
+			}
+			days = n
+		}
+
+		stats, err := svc.GetMediaAnalytics(r.Context(), mediaID, getUserID(r), days)
+		if err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			if err == domain.ErrUnauthorized {
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+				return
+			}
+			log.Error("failed to get analytics", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to get analytics")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, stats)
+	}
+}
+
+// trendingHandler returns the most viewed media in a time window
+func trendingHandler(svc *analytics.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days := 1
+		if v := r.URL.Query().Get("window"); v != "" {
+			n, err := parseWindow(v)
+			if err != nil || n < 1 || n > analytics.MaxTrendingDays {
+				respondError(w, http.StatusBadRequest, "window must be whole days between 1d and 30d")
+				return
+			}
+			days = n
+		}
+
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		items, err := svc.Trending(r.Context(), days, limit)
+		if err != nil {
+			log.Error("failed to get trending media", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to get trending media")
+			return
+		}
+
+		respondPage(w, &page{
+			Items: items,
+			Count: len(items),
+			Meta: map[string]interface{}{
+				"window": fmt.Sprintf("%dd", days),
+			},
+		})
+	}
+}
+
+// parseWindow parses a trending window into days. It is a day count such
+// as "7d", or a Go duration of whole days such as "48h"; views are counted
+// per UTC day, so shorter windows can't be honoured.
+func parseWindow(v string) (int, error) {
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		return strconv.Atoi(days)
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if d%(24*time.Hour) != 0 {
+		return 0, errors.New("window is not whole days")
+	}
+	return int(d / (24 * time.Hour)), nil
+}
+
+// getSessionID resolves the viewer session used for view deduplication
+func getSessionID(r *http.Request, fromBody string) string {
+	if fromBody != "" {
+		return fromBody
+	}
+	if id := r.Header.Get("X-Session-ID"); id != "" {
+		return id
+	}
+
+	// Fingerprint anonymous viewers by address and user agent
+	sum := sha256.Sum256([]byte(clientIP(r) + "|" + r.UserAgent()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// clientIP returns the caller address without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/auth"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/apikeys"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Create API key request body
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// RateLimit is in requests per minute
+	RateLimit int `json:"rate_limit"`
+}
+
+func (req *createAPIKeyRequest) Validate(v *validate.Validator) {
+	v.Required("name", req.Name)
+	v.MaxLength("name", req.Name, domain.MaxTitleLength)
+	v.Check(len(req.Scopes) > 0, "scopes", "is required")
+	for i, scope := range req.Scopes {
+		v.Check(domain.IsValidScope(scope), fmt.Sprintf("scopes[%d]", i), "must be a known scope")
+	}
+	v.Min("rate_limit", float64(req.RateLimit), 0)
+}
+
+// createAPIKeyHandler issues an API key for the caller
+func createAPIKeyHandler(svc *apikeys.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body createAPIKeyRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		key, secret, err := svc.CreateKey(r.Context(), &apikeys.CreateKeyRequest{
+			Name:      body.Name,
+			UserID:    getUserID(r),
+			Scopes:    body.Scopes,
+			RateLimit: body.RateLimit,
+		})
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "name and known scopes are required and rate_limit must be within the allowed range")
+				return
+			}
+			log.Error("failed to create api key", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to create api key")
+			return
+		}
+
+		// The secret is only ever returned at creation
+		respondJSON(w, http.StatusCreated, map[string]interface{}{
+			"key":     key,
+			"api_key": secret,
+		})
+	}
+}
+
+// listAPIKeysHandler lists the caller's API keys
+func listAPIKeysHandler(svc *apikeys.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := svc.ListKeys(r.Context(), getUserID(r))
+		if err != nil {
+			log.Error("failed to list api keys", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to list api keys")
+			return
+		}
+
+		respondPage(w, &page{Items: keys, Count: len(keys)})
+	}
+}
+
+// revokeAPIKeyHandler revokes one of the caller's API keys
+func revokeAPIKeyHandler(svc *apikeys.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keyID := chi.URLParam(r, "keyID")
+		if keyID == "" {
+			respondError(w, http.StatusBadRequest, "key ID is required")
+			return
+		}
+
+		if err := svc.RevokeKey(r.Context(), keyID, getUserID(r)); err != nil {
+			switch err {
+			case domain.ErrAPIKeyNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "api key not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			default:
+				log.Error("failed to revoke api key", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to revoke api key")
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// authenticateAPIKey authenticates callers presenting X-API-Key as the
+// key's owner, restricted to the key's scopes and rate limit
+func authenticateAPIKey(svc *apikeys.Service, log *logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := r.Header.Get("X-API-Key")
+			if secret == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := svc.Authenticate(r.Context(), secret)
+			if err != nil {
+				switch err {
+				case domain.ErrUnauthorized:
+					respondDomainError(w, err, http.StatusUnauthorized, "invalid api key")
+				case domain.ErrRateLimited:
+					w.Header().Set("Retry-After", "1")
+					w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", key.RateLimit))
+					writeError(w, http.StatusTooManyRequests, &apiError{
+						Code:    domain.ErrorCode(err),
+						Message: "rate limit exceeded",
+						Details: map[string]interface{}{"limit_per_minute": key.RateLimit},
+					})
+				default:
+					log.Error("failed to authenticate api key", "error", err)
+					respondError(w, http.StatusInternalServerError, "failed to authenticate api key")
+				}
+				return
+			}
+
+			ctx := auth.WithClaims(r.Context(), &auth.Claims{
+				UserID:   key.UserID,
+				Scopes:   key.Scopes,
+				APIKeyID: key.ID,
+				TenantID: key.TenantID,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requireScope restricts API key callers to routes within their scopes.
+// User tokens are not limited by API key scopes.
+func requireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if claims, ok := auth.FromContext(r.Context()); ok && claims.APIKeyID != "" && !claims.HasScope(scope) {
+				respondError(w, http.StatusForbidden, "api key lacks scope "+scope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireUserToken rejects API key callers, so keys cannot mint or
+// revoke other keys
+func requireUserToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := auth.FromContext(r.Context()); ok && claims.APIKeyID != "" {
+			respondError(w, http.StatusForbidden, "api keys cannot manage api keys")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/streaming-service/internal/auth"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/audit"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// auditState collects what inner middleware learns about an audited
+// request: who made it, for which tenant, and whether its route opted out
+type auditState struct {
+	claims   *auth.Claims
+	tenantID string
+	skip     bool
+}
+
+type auditStateKey struct{}
+
+// auditCalls records an audit entry for every mutating call once it has
+// been handled. It runs ahead of authentication so rejected credentials
+// are recorded too; auditActor passes the caller back to it.
+func auditCalls(svc *audit.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if svc == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			state := &auditState{}
+			r = r.WithContext(context.WithValue(r.Context(), auditStateKey{}, state))
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			pattern := chi.RouteContext(r.Context()).RoutePattern()
+			if state.skip || pattern == "" || strings.HasSuffix(pattern, "*") {
+				return
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			entry := &domain.AuditEntry{
+				ActorID:   "anonymous",
+				Action:    r.Method + " " + unversioned(pattern),
+				Resource:  auditResource(unversioned(pattern), unversioned(r.URL.Path)),
+				IP:        clientIP(r),
+				UserAgent: r.UserAgent(),
+				Status:    status,
+				Outcome:   domain.AuditOutcomeForStatus(status),
+				RequestID: middleware.GetReqID(r.Context()),
+			}
+			if state.claims != nil {
+				entry.ActorID = state.claims.UserID
+				entry.APIKeyID = state.claims.APIKeyID
+			}
+			svc.Record(tenant.WithID(context.Background(), state.tenantID), entry)
+		})
+	}
+}
+
+// auditActor hands the authenticated caller and their tenant to
+// auditCalls. Calls rejected before the tenant is resolved are recorded
+// under the default tenant.
+func auditActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if state, ok := r.Context().Value(auditStateKey{}).(*auditState); ok {
+			if claims, ok := auth.FromContext(r.Context()); ok {
+				state.claims = claims
+			}
+			state.tenantID = tenant.FromContext(r.Context())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// skipAudit leaves a route's calls out of the audit log, for high-volume
+// public telemetry and read-only POSTs
+func skipAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if state, ok := r.Context().Value(auditStateKey{}).(*auditState); ok {
+			state.skip = true
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unversioned strips the /api/vN prefix from a path or route pattern
+func unversioned(path string) string {
+	parts := strings.SplitN(path, "/", 4)
+	if len(parts) < 4 || parts[1] != "api" {
+		return path
+	}
+	return "/" + parts[3]
+}
+
+// auditResource names the resource a call acted on: the path up to the
+// first route parameter, such as "media/abc123" for
+// /media/{mediaID}/tags, or the whole path for routes without one
+func auditResource(pattern, path string) string {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range patternParts {
+		if i >= len(pathParts) {
+			break
+		}
+		if strings.HasPrefix(part, "{") {
+			return strings.Join(pathParts[:i+1], "/")
+		}
+	}
+	return strings.Join(pathParts, "/")
+}
+
+// listAuditHandler queries the audit history a page at a time
+func listAuditHandler(svc *audit.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		limit := 20
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		filter := &domain.AuditFilter{
+			ActorID:  q.Get("actor"),
+			Resource: strings.Trim(q.Get("resource"), "/"),
+			Day:      q.Get("day"),
+			Action:   q.Get("action"),
+			Outcome:  domain.AuditOutcome(q.Get("outcome")),
+		}
+		for param, dst := range map[string]*time.Time{
+			"since": &filter.Since,
+			"until": &filter.Until,
+		} {
+			if v := q.Get(param); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					respondError(w, http.StatusBadRequest, param+" must be an RFC 3339 timestamp")
+					return
+				}
+				*dst = t
+			}
+		}
+
+		entries, next, err := svc.List(r.Context(), filter, int32(limit), q.Get("cursor"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid cursor or filter")
+				return
+			}
+			log.Error("failed to list audit entries", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to list audit entries")
+			return
+		}
+
+		respondPage(w, &page{Items: entries, Count: len(entries), NextCursor: next})
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/service/upload"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// maxBatchSize caps the media items in one batch request
+	maxBatchSize = 100
+	// batchConcurrency bounds how many items of a batch run at once
+	batchConcurrency = 8
+	// maxRunAhead caps how far ahead reprocessing may be scheduled
+	maxRunAhead = 7 * 24 * time.Hour
+)
+
+// Batch actions
+const (
+	batchActionDelete        = "delete"
+	batchActionSetVisibility = "set_visibility"
+	batchActionReprocess     = "reprocess"
+)
+
+// Batch media request body
+type batchMediaRequest struct {
+	Action   string   `json:"action"`
+	MediaIDs []string `json:"media_ids"`
+	// Visibility is required by set_visibility
+	Visibility domain.Visibility `json:"visibility"`
+	// RunAt schedules reprocessing for later, such as off-peak hours
+	RunAt *time.Time `json:"run_at"`
+}
+
+func (req *batchMediaRequest) Validate(v *validate.Validator) {
+	v.Required("action", req.Action)
+	v.OneOf("action", req.Action, batchActionDelete, batchActionSetVisibility, batchActionReprocess)
+	v.Items("media_ids", len(req.MediaIDs), 1, maxBatchSize)
+	v.UniqueIDs("media_ids", req.MediaIDs)
+	if req.Action == batchActionSetVisibility {
+		validateVisibility(v, req.Visibility, true)
+	}
+	if req.RunAt != nil {
+		v.Check(req.Action == batchActionReprocess, "run_at", "only applies to reprocess")
+		v.Check(req.RunAt.Before(time.Now().Add(maxRunAhead)), "run_at", "must be within 7 days")
+	}
+}
+
+// batchItemResult is the outcome of a batch action on one media item,
+// with the status code the equivalent single-item call would return
+type batchItemResult struct {
+	MediaID string `json:"media_id"`
+	Status  int    `json:"status"`
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// batchMediaHandler applies one action to many media items, reporting
+// the result of each
+func batchMediaHandler(streamSvc *stream.Service, uploadSvc *upload.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body batchMediaRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		userID := getUserID(r)
+
+		var apply func(ctx context.Context, mediaID string) error
+		switch body.Action {
+		case batchActionDelete:
+			apply = func(ctx context.Context, mediaID string) error {
+				return streamSvc.DeleteMedia(ctx, mediaID, userID)
+			}
+		case batchActionSetVisibility:
+			apply = func(ctx context.Context, mediaID string) error {
+				return streamSvc.SetVisibility(ctx, mediaID, userID, body.Visibility)
+			}
+		case batchActionReprocess:
+			var at time.Time
+			if body.RunAt != nil {
+				at = *body.RunAt
+			}
+			apply = func(ctx context.Context, mediaID string) error {
+				return uploadSvc.Reprocess(ctx, mediaID, userID, at)
+			}
+		}
+
+		results := make([]batchItemResult, len(body.MediaIDs))
+		sem := make(chan struct{}, batchConcurrency)
+		var wg sync.WaitGroup
+		for i, mediaID := range body.MediaIDs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, mediaID string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				results[i] = batchItemResult{MediaID: mediaID, Status: http.StatusOK}
+				if err := apply(r.Context(), mediaID); err != nil {
+					results[i].Status, results[i].Error = batchItemError(err)
+					results[i].Code = domain.ErrorCode(err)
+					if results[i].Code == "" {
+						results[i].Code = errorCode(results[i].Status)
+					}
+					if results[i].Status == http.StatusInternalServerError {
+						log.Error("batch item failed", "action", body.Action, "media_id", mediaID, "error", err)
+					}
+				}
+			}(i, mediaID)
+		}
+		wg.Wait()
+
+		succeeded := 0
+		for _, result := range results {
+			if result.Status == http.StatusOK {
+				succeeded++
+			}
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"action":    body.Action,
+			"results":   results,
+			"succeeded": succeeded,
+			"failed":    len(results) - succeeded,
+		})
+	}
+}
+
+// batchItemError maps an item's error to a status code and message
+func batchItemError(err error) (int, string) {
+	switch err {
+	case domain.ErrMediaNotFound:
+		return http.StatusNotFound, "media not found"
+	case domain.ErrUnauthorized:
+		return http.StatusForbidden, "unauthorized"
+	case domain.ErrMediaBusy:
+		return http.StatusConflict, "media is already being processed"
+	case domain.ErrQueueUnavailable:
+		return http.StatusServiceUnavailable, "processing queue unavailable"
+	case domain.ErrQuotaExceeded:
+		return http.StatusTooManyRequests, "quota exceeded"
+	case domain.ErrLegalHold:
+		return http.StatusConflict, "media is under legal hold"
+	default:
+		return http.StatusInternalServerError, "internal error"
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// publicMediaCacheControl lets shared caches keep the info of public
+	// and unlisted media for a minute
+	publicMediaCacheControl = "public, max-age=60"
+	// privateMediaCacheControl keeps private media out of shared caches
+	// and makes browsers revalidate it
+	privateMediaCacheControl = "private, no-cache"
+	// livePreviewCacheControl matches how often a live stream's preview
+	// image is refreshed
+	livePreviewCacheControl = "public, max-age=10"
+)
+
+// authVaryHeaders are the request headers that can change a response
+// depending on who is asking
+const authVaryHeaders = "Authorization, X-API-Key"
+
+// setCacheHeaders sets Cache-Control and, when modified is known,
+// Last-Modified. It answers 304 when the client's copy from
+// If-Modified-Since is current, reporting whether it did.
+func setCacheHeaders(w http.ResponseWriter, r *http.Request, cacheControl string, modified time.Time) bool {
+	w.Header().Set("Cache-Control", cacheControl)
+	if modified.IsZero() {
+		return false
+	}
+
+	// HTTP dates have second precision
+	modified = modified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,345 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/channels"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Create channel request body
+type createChannelRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func (req *createChannelRequest) Validate(v *validate.Validator) {
+	v.Required("title", req.Title)
+	validateMetadata(v, &req.Title, &req.Description)
+}
+
+// Update channel request body; omitted fields are left unchanged
+type updateChannelRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+}
+
+func (req *updateChannelRequest) Validate(v *validate.Validator) {
+	if req.Title != nil {
+		v.Required("title", *req.Title)
+	}
+	validateMetadata(v, req.Title, req.Description)
+}
+
+// Publish episode request body
+type publishEpisodeRequest struct {
+	// PublishAt schedules the release; omitted releases it now
+	PublishAt *time.Time `json:"publish_at"`
+	Season    int        `json:"season"`
+	Number    int        `json:"number"`
+	Explicit  bool       `json:"explicit"`
+}
+
+func (req *publishEpisodeRequest) Validate(v *validate.Validator) {
+	v.Min("season", float64(req.Season), 0)
+	v.Min("number", float64(req.Number), 0)
+}
+
+// createChannelHandler creates a channel for the caller
+func createChannelHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body createChannelRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		channel, err := svc.Create(r.Context(), getUserID(r), body.Title, body.Description)
+		if err != nil {
+			respondChannelError(w, log, err, "failed to create channel")
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, channel)
+	}
+}
+
+// listChannelsHandler lists the caller's channels
+func listChannelsHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondChannels(w, r, svc, log, getUserID(r))
+	}
+}
+
+// userChannelsHandler lists a creator's channels, so their public channel
+// pages can be found
+func userChannelsHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondChannels(w, r, svc, log, chi.URLParam(r, "userID"))
+	}
+}
+
+// respondChannels writes a page of a user's channels
+func respondChannels(w http.ResponseWriter, r *http.Request, svc *channels.Service, log *logger.Logger, userID string) {
+	limit, ok := parseLimit(w, r)
+	if !ok {
+		return
+	}
+
+	items, next, err := svc.List(r.Context(), userID, int32(limit), r.URL.Query().Get("cursor"))
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			respondDomainError(w, err, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		log.Error("failed to list channels", "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to list channels")
+		return
+	}
+
+	respondPage(w, &page{Items: items, Count: len(items), NextCursor: next})
+}
+
+// getChannelHandler returns a channel's public page details
+func getChannelHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel, err := svc.Get(r.Context(), chi.URLParam(r, "channelID"))
+		if err != nil {
+			respondChannelError(w, log, err, "failed to get channel")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, channel)
+	}
+}
+
+// updateChannelHandler changes a channel's title or description
+func updateChannelHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body updateChannelRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		channel, err := svc.Update(r.Context(), chi.URLParam(r, "channelID"), getUserID(r), &channels.UpdateRequest{
+			Title:       body.Title,
+			Description: body.Description,
+		})
+		if err != nil {
+			respondChannelError(w, log, err, "failed to update channel")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, channel)
+	}
+}
+
+// setChannelArtworkHandler replaces a channel's artwork with the image in
+// the request body
+func setChannelArtworkHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			if !respondTooLarge(w, err) {
+				respondError(w, http.StatusBadRequest, "failed to read artwork")
+			}
+			return
+		}
+
+		channel, err := svc.SetArtwork(r.Context(), chi.URLParam(r, "channelID"), getUserID(r), bytes.NewReader(data), r.Header.Get("Content-Type"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusUnsupportedMediaType, "artwork must be image/jpeg, image/png or image/webp")
+				return
+			}
+			respondChannelError(w, log, err, "failed to set artwork")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, channel)
+	}
+}
+
+// deleteChannelHandler deletes a channel, unpublishing its media
+func deleteChannelHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.Delete(r.Context(), chi.URLParam(r, "channelID"), getUserID(r)); err != nil {
+			respondChannelError(w, log, err, "failed to delete channel")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// channelMediaHandler lists the media published to a channel
+func channelMediaHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := parseLimit(w, r)
+		if !ok {
+			return
+		}
+
+		media, next, err := svc.ListMedia(r.Context(), chi.URLParam(r, "channelID"), getUserID(r), int32(limit), r.URL.Query().Get("cursor"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid cursor")
+				return
+			}
+			respondChannelError(w, log, err, "failed to list channel media")
+			return
+		}
+
+		respondPage(w, &page{Items: media, Count: len(media), NextCursor: next})
+	}
+}
+
+// publishMediaHandler publishes a media item to a channel
+func publishMediaHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.Publish(r.Context(), chi.URLParam(r, "channelID"), chi.URLParam(r, "mediaID"), getUserID(r)); err != nil {
+			respondChannelError(w, log, err, "failed to publish media")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// unpublishMediaHandler removes a media item from a channel
+func unpublishMediaHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.Unpublish(r.Context(), chi.URLParam(r, "channelID"), chi.URLParam(r, "mediaID"), getUserID(r)); err != nil {
+			respondChannelError(w, log, err, "failed to unpublish media")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// publishEpisodeHandler publishes audio media to a channel as a podcast
+// episode
+func publishEpisodeHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body publishEpisodeRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		req := &channels.EpisodeRequest{
+			Season:   body.Season,
+			Number:   body.Number,
+			Explicit: body.Explicit,
+		}
+		if body.PublishAt != nil {
+			req.PublishAt = *body.PublishAt
+		}
+
+		episode, err := svc.PublishEpisode(r.Context(), chi.URLParam(r, "channelID"), chi.URLParam(r, "mediaID"), getUserID(r), req)
+		if err != nil {
+			respondEpisodeError(w, log, err, "failed to publish episode")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, episode)
+	}
+}
+
+// unpublishEpisodeHandler withdraws a podcast episode from a channel
+func unpublishEpisodeHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.UnpublishEpisode(r.Context(), chi.URLParam(r, "channelID"), chi.URLParam(r, "mediaID"), getUserID(r)); err != nil {
+			respondEpisodeError(w, log, err, "failed to unpublish episode")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// channelEpisodesHandler lists a channel's podcast episodes
+func channelEpisodesHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := parseLimit(w, r)
+		if !ok {
+			return
+		}
+
+		episodes, next, err := svc.ListEpisodes(r.Context(), chi.URLParam(r, "channelID"), getUserID(r), int32(limit), r.URL.Query().Get("cursor"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid cursor")
+				return
+			}
+			respondChannelError(w, log, err, "failed to list episodes")
+			return
+		}
+
+		respondPage(w, &page{Items: episodes, Count: len(episodes), NextCursor: next})
+	}
+}
+
+// channelFeedHandler serves a channel's podcast RSS feed
+func channelFeedHandler(svc *channels.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feed, err := svc.Feed(r.Context(), chi.URLParam(r, "channelID"))
+		if err != nil {
+			respondChannelError(w, log, err, "failed to render feed")
+			return
+		}
+
+		setCacheHeaders(w, r, publicMediaCacheControl, time.Time{})
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		_, _ = w.Write(feed)
+	}
+}
+
+// respondEpisodeError maps episode errors to responses
+func respondEpisodeError(w http.ResponseWriter, log *logger.Logger, err error, msg string) {
+	switch err {
+	case domain.ErrInvalidInput:
+		respondDomainError(w, err, http.StatusBadRequest, "season and number must not be negative")
+	case domain.ErrInvalidMediaType:
+		respondDomainError(w, err, http.StatusBadRequest, "episodes must be audio media")
+	default:
+		respondChannelError(w, log, err, msg)
+	}
+}
+
+// parseLimit reads the page size, responding 400 when it is out of range
+func parseLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return 0, false
+		}
+		limit = n
+	}
+	return limit, true
+}
+
+// respondChannelError maps channel errors to responses
+func respondChannelError(w http.ResponseWriter, log *logger.Logger, err error, msg string) {
+	switch err {
+	case domain.ErrInvalidInput:
+		respondDomainError(w, err, http.StatusBadRequest, "title is required")
+	case domain.ErrChannelNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "channel not found")
+	case domain.ErrMediaNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "media not found")
+	case domain.ErrUnauthorized:
+		respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+	default:
+		log.Error(msg, "error", err)
+		respondError(w, http.StatusInternalServerError, msg)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/collections"
+	"github.com/streaming-service/internal/service/keys"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Create collection request body
+type createCollectionRequest struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Visibility  domain.Visibility `json:"visibility"`
+	MediaIDs    []string          `json:"media_ids"`
+}
+
+func (req *createCollectionRequest) Validate(v *validate.Validator) {
+	v.Required("title", req.Title)
+	validateMetadata(v, &req.Title, &req.Description)
+	validateVisibility(v, req.Visibility, false)
+	v.Items("media_ids", len(req.MediaIDs), 0, domain.MaxCollectionItems)
+	v.UniqueIDs("media_ids", req.MediaIDs)
+}
+
+// Update collection request body; omitted fields are left unchanged
+type updateCollectionRequest struct {
+	Title       *string            `json:"title"`
+	Description *string            `json:"description"`
+	Visibility  *domain.Visibility `json:"visibility"`
+	MediaIDs    *[]string          `json:"media_ids"`
+}
+
+func (req *updateCollectionRequest) Validate(v *validate.Validator) {
+	if req.Title != nil {
+		v.Required("title", *req.Title)
+	}
+	validateMetadata(v, req.Title, req.Description)
+	if req.Visibility != nil {
+		validateVisibility(v, *req.Visibility, true)
+	}
+	if req.MediaIDs != nil {
+		v.Items("media_ids", len(*req.MediaIDs), 0, domain.MaxCollectionItems)
+		v.UniqueIDs("media_ids", *req.MediaIDs)
+	}
+}
+
+// createCollectionHandler creates a collection for the caller
+func createCollectionHandler(svc *collections.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body createCollectionRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		collection, err := svc.Create(r.Context(), getUserID(r), &collections.CreateRequest{
+			Title:       body.Title,
+			Description: body.Description,
+			Visibility:  body.Visibility,
+			MediaIDs:    body.MediaIDs,
+		})
+		if err != nil {
+			respondCollectionError(w, log, err, "failed to create collection")
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, collection)
+	}
+}
+
+// listCollectionsHandler lists the caller's collections
+func listCollectionsHandler(svc *collections.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		items, next, err := svc.List(r.Context(), getUserID(r), int32(limit), r.URL.Query().Get("cursor"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid cursor")
+				return
+			}
+			log.Error("failed to list collections", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to list collections")
+			return
+		}
+
+		respondPage(w, &page{Items: items, Count: len(items), NextCursor: next})
+	}
+}
+
+// getCollectionHandler returns a collection
+func getCollectionHandler(svc *collections.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		collection, err := svc.Get(r.Context(), chi.URLParam(r, "collectionID"), getUserID(r))
+		if err != nil {
+			respondCollectionError(w, log, err, "failed to get collection")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, collection)
+	}
+}
+
+// updateCollectionHandler changes a collection's metadata or items
+func updateCollectionHandler(svc *collections.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body updateCollectionRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		collection, err := svc.Update(r.Context(), chi.URLParam(r, "collectionID"), getUserID(r), &collections.UpdateRequest{
+			Title:       body.Title,
+			Description: body.Description,
+			Visibility:  body.Visibility,
+			MediaIDs:    body.MediaIDs,
+		})
+		if err != nil {
+			respondCollectionError(w, log, err, "failed to update collection")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, collection)
+	}
+}
+
+// deleteCollectionHandler deletes a collection
+func deleteCollectionHandler(svc *collections.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.Delete(r.Context(), chi.URLParam(r, "collectionID"), getUserID(r)); err != nil {
+			respondCollectionError(w, log, err, "failed to delete collection")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// collectionPlaybackHandler returns the stream URL of each item of a
+// collection in order
+func collectionPlaybackHandler(svc *collections.Service, keysSvc *keys.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := newPlaybackSession(r)
+
+		collection, items, err := svc.Playback(r.Context(), chi.URLParam(r, "collectionID"), session)
+		if err != nil {
+			respondCollectionError(w, log, err, "failed to get collection playback")
+			return
+		}
+
+		// Encrypted renditions need a token to fetch content keys
+		if keysSvc != nil {
+			for _, item := range items {
+				tok, err := keysSvc.IssuePlaybackToken(item.MediaID, session.UserID)
+				if err != nil {
+					log.Error("failed to issue playback token", "error", err)
+					respondError(w, http.StatusInternalServerError, "failed to issue playback token")
+					return
+				}
+				item.KeyToken = tok
+			}
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"collection_id": collection.ID,
+			"title":         collection.Title,
+			"items":         items,
+			"count":         len(items),
+		})
+	}
+}
+
+// respondCollectionError maps collection errors to responses
+func respondCollectionError(w http.ResponseWriter, log *logger.Logger, err error, msg string) {
+	switch err {
+	case domain.ErrInvalidInput:
+		respondDomainError(w, err, http.StatusBadRequest, fmt.Sprintf(
+			"title is required, visibility must be public, unlisted or private, and collections hold at most %d items",
+			domain.MaxCollectionItems))
+	case domain.ErrMediaNotFound:
+		respondDomainError(w, err, http.StatusBadRequest, "collection references media that does not exist")
+	case domain.ErrCollectionNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "collection not found")
+	case domain.ErrUnauthorized:
+		respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+	default:
+		log.Error(msg, "error", err)
+		respondError(w, http.StatusInternalServerError, msg)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/embed"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// embedTokenParam is the playback query parameter carrying an embed token
+const embedTokenParam = "embed_token"
+
+// Create embed token request body
+type createEmbedTokenRequest struct {
+	// ExpiresIn is the token lifetime in seconds; zero uses the default
+	ExpiresIn int64    `json:"expires_in"`
+	Origins   []string `json:"origins"`
+}
+
+func (req *createEmbedTokenRequest) Validate(v *validate.Validator) {
+	v.Min("expires_in", float64(req.ExpiresIn), 0)
+	v.Items("origins", len(req.Origins), 0, embed.MaxOrigins)
+	for i, origin := range req.Origins {
+		_, ok := embed.NormalizeOrigin(origin)
+		v.Check(ok, fmt.Sprintf("origins[%d]", i), "must be an http or https origin such as https://example.com")
+	}
+}
+
+// createEmbedTokenHandler issues an embed token for one of the caller's
+// media items
+func createEmbedTokenHandler(svc *embed.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		if mediaID == "" {
+			respondError(w, http.StatusBadRequest, "media ID is required")
+			return
+		}
+
+		var body createEmbedTokenRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		tok, err := svc.Issue(r.Context(), &embed.IssueRequest{
+			MediaID: mediaID,
+			UserID:  getUserID(r),
+			TTL:     time.Duration(body.ExpiresIn) * time.Second,
+			Origins: body.Origins,
+		})
+		if err != nil {
+			switch err {
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, fmt.Sprintf("expires_in must be at most %d seconds", int64(svc.MaxTTL().Seconds())))
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			default:
+				log.Error("failed to issue embed token", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to issue embed token")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, tok)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/enrich"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Enrich media request body
+type enrichMediaRequest struct {
+	// Track is the subtitle track to use as the transcript; empty picks
+	// the default track
+	Track string `json:"track"`
+}
+
+func (req *enrichMediaRequest) Validate(v *validate.Validator) {
+	v.MaxLength("track", req.Track, 64)
+}
+
+// enrichMediaHandler queues a job generating a summary and chapters for
+// the user's media from its transcript
+func enrichMediaHandler(svc *enrich.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body enrichMediaRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		jobID, err := svc.RequestEnrichment(r.Context(), chi.URLParam(r, "mediaID"), getUserID(r), body.Track)
+		if err != nil {
+			switch err {
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrSubtitleNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "no subtitle track to use as the transcript")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			case domain.ErrQueueUnavailable:
+				respondDomainError(w, err, http.StatusServiceUnavailable, "job queue unavailable")
+			default:
+				log.Error("failed to queue enrichment", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to queue enrichment")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusAccepted, map[string]string{"job_id": jobID})
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/streaming-service/internal/correlation"
+	"github.com/streaming-service/internal/domain"
+)
+
+// requestIDHeader reports the ID of a request, for clients to quote when
+// reporting problems
+const requestIDHeader = "X-Request-ID"
+
+// apiError is the error envelope of every error response
+type apiError struct {
+	// Code is stable and machine-readable, such as "media_not_found"
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details holds error-specific fields such as a rate limit
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// withRequestID echoes the request ID on responses so the error helpers
+// can report it
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(requestIDHeader, id)
+			// Jobs queued by the request carry the ID to the worker
+			r = r.WithContext(correlation.WithID(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// respondError writes an error whose code is derived from its status
+func respondError(w http.ResponseWriter, status int, message string) {
+	writeError(w, status, &apiError{Code: errorCode(status), Message: message})
+}
+
+// respondDomainError writes an error caused by a domain error, coded by
+// the domain error when it has a code
+func respondDomainError(w http.ResponseWriter, err error, status int, message string) {
+	code := domain.ErrorCode(err)
+	if code == "" {
+		code = errorCode(status)
+	}
+	writeError(w, status, &apiError{Code: code, Message: message})
+}
+
+// writeError writes an error envelope. v2 nests it under "error":
+//
+//	{"error": {"code": "media_not_found", "message": "media not found", "request_id": "..."}}
+//
+// while v1 keeps "error" as the message and adds the other fields
+// alongside it.
+func writeError(w http.ResponseWriter, status int, e *apiError) {
+	e.RequestID = w.Header().Get(requestIDHeader)
+
+	if responseVersion(w) >= apiV2 {
+		respondJSON(w, status, map[string]interface{}{"error": e})
+		return
+	}
+
+	resp := map[string]interface{}{
+		"error": e.Message,
+		"code":  e.Code,
+	}
+	if len(e.Details) > 0 {
+		resp["details"] = e.Details
+	}
+	if e.RequestID != "" {
+		resp["request_id"] = e.RequestID
+	}
+	respondJSON(w, status, resp)
+}
+
+// errorCode derives a machine-readable code from a status, such as
+// "not_found", for errors without a domain error
+func errorCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/internal/service/estimate"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// maxEstimateProfiles caps the renditions of a ladder being estimated
+const maxEstimateProfiles = 20
+
+// Estimate request body: a probed source and, optionally, the ladder to
+// estimate in place of the configured one
+type estimateRequest struct {
+	Duration float64           `json:"duration"`
+	Width    int               `json:"width"`
+	Height   int               `json:"height"`
+	Profiles []estimateProfile `json:"profiles"`
+}
+
+// estimateProfile is one rendition of a ladder being estimated
+type estimateProfile struct {
+	Name         string `json:"name"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	VideoBitrate string `json:"video_bitrate"`
+	AudioBitrate string `json:"audio_bitrate"`
+	Codec        string `json:"codec"`
+}
+
+func (req *estimateRequest) Validate(v *validate.Validator) {
+	v.Check(req.Duration > 0, "duration", "must be positive")
+	v.Check(req.Width > 0, "width", "must be positive")
+	v.Check(req.Height > 0, "height", "must be positive")
+	v.Items("profiles", len(req.Profiles), 0, maxEstimateProfiles)
+
+	names := make(map[string]bool, len(req.Profiles))
+	for i, p := range req.Profiles {
+		field := fmt.Sprintf("profiles[%d]", i)
+		v.Required(field+".name", p.Name)
+		v.Check(!names[p.Name], field+".name", "is used by another profile")
+		names[p.Name] = true
+		v.Check(p.Width > 0, field+".width", "must be positive")
+		v.Check(p.Height > 0, field+".height", "must be positive")
+		_, err := processor.ParseBitrate(p.VideoBitrate)
+		v.Check(err == nil, field+".video_bitrate", "must be a bitrate such as 2500k")
+		_, err = processor.ParseBitrate(p.AudioBitrate)
+		v.Check(err == nil, field+".audio_bitrate", "must be a bitrate such as 128k")
+		v.Check(config.KnownCodecs[p.Codec], field+".codec", "is not a known codec")
+	}
+}
+
+// estimateHandler forecasts the transcode time and output size of a
+// source before it is uploaded or reprocessed
+func estimateHandler(svc *estimate.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body estimateRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		profiles := make([]config.TranscodeProfile, 0, len(body.Profiles))
+		for _, p := range body.Profiles {
+			profiles = append(profiles, config.TranscodeProfile{
+				Name:         p.Name,
+				Width:        p.Width,
+				Height:       p.Height,
+				VideoBitrate: p.VideoBitrate,
+				AudioBitrate: p.AudioBitrate,
+				Codec:        p.Codec,
+			})
+		}
+
+		result, err := svc.Estimate(estimate.Source{
+			Duration: body.Duration,
+			Width:    body.Width,
+			Height:   body.Height,
+		}, profiles)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidInput) {
+				respondDomainError(w, err, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Error("failed to estimate transcode", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to estimate transcode")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, result)
+	}
+}
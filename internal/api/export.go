@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/export"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Export media request body
+type exportMediaRequest struct {
+	// Format is what the package holds the media as; empty means source
+	Format domain.ExportFormat `json:"format"`
+}
+
+func (req *exportMediaRequest) Validate(v *validate.Validator) {
+	v.OneOf("format", string(req.Format), string(domain.ExportFormatSource), string(domain.ExportFormatMP4))
+}
+
+// exportMediaHandler queues a downloadable package of the user's media
+func exportMediaHandler(svc *export.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		var body exportMediaRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+		if body.Format == "" {
+			body.Format = domain.ExportFormatSource
+		}
+
+		exp, err := svc.Request(r.Context(), mediaID, getUserID(r), body.Format)
+		if err != nil {
+			switch err {
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			case domain.ErrInvalidMediaStatus:
+				respondDomainError(w, err, http.StatusConflict, "mp4 exports need processed, unencrypted media")
+			case domain.ErrMediaBusy:
+				respondDomainError(w, err, http.StatusConflict, "an export is already being built")
+			case domain.ErrQueueUnavailable:
+				respondDomainError(w, err, http.StatusServiceUnavailable, "job queue unavailable")
+			default:
+				log.Error("failed to queue export", "error", err, "media_id", mediaID)
+				respondError(w, http.StatusInternalServerError, "failed to queue export")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusAccepted, exp)
+	}
+}
+
+// getExportHandler reports the latest export of the user's media, with a
+// download link once it's built
+func getExportHandler(svc *export.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		exp, err := svc.Get(r.Context(), mediaID, getUserID(r))
+		if err != nil {
+			switch err {
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrExportNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "export not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			default:
+				log.Error("failed to get export", "error", err, "media_id", mediaID)
+				respondError(w, http.StatusInternalServerError, "failed to get export")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, exp)
+	}
+}
@@ -0,0 +1,486 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/streaming-service/internal/auth"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/graphql"
+	"github.com/streaming-service/internal/service/analytics"
+	"github.com/streaming-service/internal/service/collections"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// graphqlLimits bound the queries the GraphQL endpoint executes. Fields
+// resolved with reads cost one per read, so the cost bounds the reads a
+// query can fan out into.
+var graphqlLimits = graphql.Limits{
+	Depth:  8,
+	Fields: 500,
+	Cost:   500,
+}
+
+// Resolver errors reported to GraphQL callers
+var (
+	errAuthRequired = errors.New("authentication required")
+	errInternal     = errors.New("internal error")
+)
+
+// graphqlHandler executes GraphQL queries sent as a JSON body, or as query
+// parameters on GET
+func graphqlHandler(schema *graphql.Schema, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req graphql.Request
+		if r.Method == http.MethodGet {
+			q := r.URL.Query()
+			req.Query = q.Get("query")
+			req.OperationName = q.Get("operationName")
+			if v := q.Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					respondError(w, http.StatusBadRequest, "variables must be a JSON object")
+					return
+				}
+			}
+		} else if !decodeBody(w, r, &req) {
+			return
+		}
+
+		if req.Query == "" {
+			respondProblem(w, http.StatusBadRequest, "request failed validation", validate.Errors{
+				{Field: "query", Message: "is required"},
+			})
+			return
+		}
+
+		respondJSON(w, http.StatusOK, schema.Execute(r.Context(), &req))
+	}
+}
+
+// catalogSchema builds the GraphQL schema over media, renditions,
+// collections and analytics. Fields resolve only when selected, so
+// analytics and collection items cost nothing unless requested.
+func catalogSchema(streamSvc *stream.Service, collectionsSvc *collections.Service, analyticsSvc *analytics.Service, authRequired bool, log *logger.Logger) *graphql.Schema {
+	dateTime := &graphql.Scalar{
+		Name: "DateTime",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, false
+			}
+			return t.UTC().Format(time.RFC3339), true
+		},
+		Parse: func(v interface{}) (interface{}, bool) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			t, err := time.Parse(time.RFC3339, s)
+			return t, err == nil
+		},
+	}
+
+	str := graphql.String
+	nonNull := graphql.NewNonNull
+	list := func(t graphql.Type) graphql.Type {
+		return nonNull(graphql.NewList(nonNull(t)))
+	}
+
+	mediaType := &graphql.Enum{Name: "MediaType", Values: []string{"video", "audio"}}
+	mediaStatus := &graphql.Enum{Name: "MediaStatus", Values: []string{"pending", "processing", "completed", "failed"}}
+	visibility := &graphql.Enum{Name: "Visibility", Values: []string{"public", "unlisted", "private"}}
+
+	// resolveErr hides unexpected errors from callers
+	resolveErr := func(err error, msg string) error {
+		switch err {
+		case domain.ErrUnauthorized:
+			return domain.ErrUnauthorized
+		case domain.ErrInvalidInput:
+			return domain.ErrInvalidInput
+		}
+		log.Error(msg, "error", err)
+		return errInternal
+	}
+
+	// caller returns the authenticated user, checking API key scopes
+	caller := func(ctx context.Context, scope string) (string, error) {
+		claims, ok := auth.FromContext(ctx)
+		if !ok {
+			if authRequired {
+				return "", errAuthRequired
+			}
+			return "anonymous", nil
+		}
+		if claims.APIKeyID != "" && !claims.HasScope(scope) {
+			return "", errors.New("api key lacks scope " + scope)
+		}
+		return claims.UserID, nil
+	}
+
+	viewer := func(ctx context.Context) string {
+		if claims, ok := auth.FromContext(ctx); ok {
+			return claims.UserID
+		}
+		return "anonymous"
+	}
+
+	countEntry := &graphql.Object{
+		Name: "CountEntry",
+		Fields: map[string]*graphql.Field{
+			"key":   {Type: nonNull(str)},
+			"count": {Type: nonNull(graphql.Int)},
+		},
+	}
+
+	// counts lists a count map largest first
+	counts := func(m map[string]int64) []map[string]interface{} {
+		entries := make([]map[string]interface{}, 0, len(m))
+		for k, v := range m {
+			entries = append(entries, map[string]interface{}{"key": k, "count": v})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			ci, cj := entries[i]["count"].(int64), entries[j]["count"].(int64)
+			if ci != cj {
+				return ci > cj
+			}
+			return entries[i]["key"].(string) < entries[j]["key"].(string)
+		})
+		return entries
+	}
+
+	analyticsType := &graphql.Object{
+		Name: "MediaAnalytics",
+		Fields: map[string]*graphql.Field{
+			"totalViews": {Type: nonNull(graphql.Int)},
+			"views": {Type: list(&graphql.Object{
+				Name: "DailyCount",
+				Fields: map[string]*graphql.Field{
+					"date":  {Type: nonNull(str)},
+					"count": {Type: nonNull(graphql.Int)},
+				},
+			})},
+			"heatmap": {Type: list(&graphql.Object{
+				Name: "HeatmapBucket",
+				Fields: map[string]*graphql.Field{
+					"start":        {Type: nonNull(graphql.Int)},
+					"end":          {Type: nonNull(graphql.Int)},
+					"watchSeconds": {Type: nonNull(graphql.Float)},
+				},
+			})},
+			"starts":         {Type: nonNull(graphql.Int)},
+			"completions":    {Type: nonNull(graphql.Int)},
+			"completionRate": {Type: nonNull(graphql.Float)},
+			"devices": {
+				Type: list(countEntry),
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					return counts(source.(*domain.MediaAnalytics).Devices), nil
+				},
+			},
+			"countries": {
+				Type: list(countEntry),
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					return counts(source.(*domain.MediaAnalytics).Countries), nil
+				},
+			},
+			"deliveredBytes": {Type: nonNull(graphql.Float)},
+			"cdnRequests":    {Type: nonNull(graphql.Int)},
+		},
+	}
+
+	rendition := &graphql.Object{
+		Name: "Rendition",
+		Fields: map[string]*graphql.Field{
+			"name":      {Type: nonNull(str)},
+			"width":     {Type: graphql.Int},
+			"height":    {Type: graphql.Int},
+			"bitrate":   {Type: nonNull(graphql.Int)},
+			"streamUrl": {Type: nonNull(str)},
+		},
+	}
+
+	media := &graphql.Object{
+		Name: "Media",
+		Fields: map[string]*graphql.Field{
+			"id":          {Type: nonNull(graphql.ID)},
+			"title":       {Type: nonNull(str)},
+			"description": {Type: nonNull(str)},
+			"type":        {Type: nonNull(mediaType)},
+			"status":      {Type: nonNull(mediaStatus)},
+			"visibility":  {Type: nonNull(visibility)},
+			"duration":    {Type: nonNull(graphql.Float)},
+			"channelId":   {Type: graphql.ID, Resolve: optional(func(m *stream.MediaInfo) string { return m.ChannelID })},
+			"playbackUrl": {Type: str, Resolve: optional(func(m *stream.MediaInfo) string { return m.PlaybackURL })},
+			"createdAt":   {Type: nonNull(dateTime)},
+			"tags": {
+				Type: list(&graphql.Object{
+					Name: "Tag",
+					Fields: map[string]*graphql.Field{
+						"key":   {Type: nonNull(str)},
+						"value": {Type: nonNull(str)},
+					},
+				}),
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					tags := source.(*stream.MediaInfo).Tags
+					keys := make([]string, 0, len(tags))
+					for k := range tags {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+
+					result := make([]map[string]interface{}, 0, len(keys))
+					for _, k := range keys {
+						result = append(result, map[string]interface{}{"key": k, "value": tags[k]})
+					}
+					return result, nil
+				},
+			},
+			"renditions": {
+				Type: list(rendition),
+				Cost: 1,
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					info := source.(*stream.MediaInfo)
+					if info.Renditions != nil || info.Status != domain.MediaStatusCompleted {
+						return info.Renditions, nil
+					}
+
+					// Listings leave renditions out; fetch them when asked for
+					detail, err := streamSvc.GetMedia(ctx, info.ID, viewer(ctx))
+					if err != nil {
+						return nil, resolveErr(err, "failed to get renditions")
+					}
+					return detail.Renditions, nil
+				},
+			},
+			"analytics": {
+				Type: analyticsType,
+				Cost: 2,
+				Args: map[string]*graphql.Argument{
+					"days": {Type: graphql.Int, Default: 30},
+				},
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					userID, err := caller(ctx, domain.ScopeAnalyticsRead)
+					if err != nil {
+						return nil, err
+					}
+					days := args["days"].(int)
+					if days < 1 || days > 365 {
+						return nil, errors.New("days must be between 1 and 365")
+					}
+
+					stats, err := analyticsSvc.GetMediaAnalytics(ctx, source.(*stream.MediaInfo).ID, userID, days)
+					if err != nil {
+						return nil, resolveErr(err, "failed to get analytics")
+					}
+					return stats, nil
+				},
+			},
+		},
+	}
+
+	collection := &graphql.Object{
+		Name: "Collection",
+		Fields: map[string]*graphql.Field{
+			"id":          {Type: nonNull(graphql.ID)},
+			"title":       {Type: nonNull(str)},
+			"description": {Type: nonNull(str)},
+			"visibility":  {Type: nonNull(visibility)},
+			"mediaIds":    {Type: list(graphql.ID)},
+			"createdAt":   {Type: nonNull(dateTime)},
+			"updatedAt":   {Type: nonNull(dateTime)},
+			"items": {
+				Type: list(media),
+				Cost: 1,
+				Multiplier: func(args map[string]interface{}) int {
+					return domain.MaxCollectionItems
+				},
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					items, err := streamSvc.GetMediaBatch(ctx, source.(*domain.Collection).MediaIDs, viewer(ctx))
+					if err != nil {
+						return nil, resolveErr(err, "failed to get collection items")
+					}
+					return items, nil
+				},
+			},
+		},
+	}
+
+	page := func(name string, item graphql.Type) *graphql.Object {
+		return &graphql.Object{
+			Name: name,
+			Fields: map[string]*graphql.Field{
+				"items":      {Type: list(item)},
+				"nextCursor": {Type: str},
+			},
+		}
+	}
+	pageArgs := func() map[string]*graphql.Argument {
+		return map[string]*graphql.Argument{
+			"limit":  {Type: graphql.Int, Default: 20},
+			"cursor": {Type: str},
+		}
+	}
+	pageSize := func(args map[string]interface{}) int {
+		limit, _ := args["limit"].(int)
+		return limit
+	}
+	pageResult := func(items interface{}, next string) map[string]interface{} {
+		result := map[string]interface{}{"items": items}
+		if next != "" {
+			result["nextCursor"] = next
+		}
+		return result
+	}
+	pageLimit := func(args map[string]interface{}) (int32, string, error) {
+		limit := args["limit"].(int)
+		if limit < 1 || limit > 100 {
+			return 0, "", errors.New("limit must be between 1 and 100")
+		}
+		cursor, _ := args["cursor"].(string)
+		return int32(limit), cursor, nil
+	}
+
+	mediaListArgs := pageArgs()
+	mediaListArgs["status"] = &graphql.Argument{Type: mediaStatus}
+	mediaListArgs["type"] = &graphql.Argument{Type: mediaType}
+	mediaListArgs["tag"] = &graphql.Argument{Type: str}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: map[string]*graphql.Field{
+			"media": {
+				Type: media,
+				Cost: 1,
+				Args: map[string]*graphql.Argument{"id": {Type: nonNull(graphql.ID)}},
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					info, err := streamSvc.GetMedia(ctx, args["id"].(string), viewer(ctx))
+					if err == domain.ErrMediaNotFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, resolveErr(err, "failed to get media")
+					}
+					return info, nil
+				},
+			},
+			"mediaList": {
+				Type:       nonNull(page("MediaPage", media)),
+				Args:       mediaListArgs,
+				Cost:       1,
+				Multiplier: pageSize,
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					userID, err := caller(ctx, domain.ScopeMediaRead)
+					if err != nil {
+						return nil, err
+					}
+					limit, cursor, err := pageLimit(args)
+					if err != nil {
+						return nil, err
+					}
+
+					filter := &domain.MediaFilter{}
+					if v, ok := args["status"].(string); ok {
+						filter.Status = domain.MediaStatus(v)
+					}
+					if v, ok := args["type"].(string); ok {
+						filter.Type = domain.MediaType(v)
+					}
+					filter.Tag, _ = args["tag"].(string)
+
+					items, next, err := streamSvc.ListMedia(ctx, userID, filter, limit, cursor)
+					if err != nil {
+						return nil, resolveErr(err, "failed to list media")
+					}
+					return pageResult(items, next), nil
+				},
+			},
+			"collection": {
+				Type: collection,
+				Cost: 1,
+				Args: map[string]*graphql.Argument{"id": {Type: nonNull(graphql.ID)}},
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					c, err := collectionsSvc.Get(ctx, args["id"].(string), viewer(ctx))
+					if err == domain.ErrCollectionNotFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, resolveErr(err, "failed to get collection")
+					}
+					return c, nil
+				},
+			},
+			"collections": {
+				Type:       nonNull(page("CollectionPage", collection)),
+				Args:       pageArgs(),
+				Cost:       1,
+				Multiplier: pageSize,
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					userID, err := caller(ctx, domain.ScopeMediaRead)
+					if err != nil {
+						return nil, err
+					}
+					limit, cursor, err := pageLimit(args)
+					if err != nil {
+						return nil, err
+					}
+
+					items, next, err := collectionsSvc.List(ctx, userID, limit, cursor)
+					if err != nil {
+						return nil, resolveErr(err, "failed to list collections")
+					}
+					return pageResult(items, next), nil
+				},
+			},
+			"trending": {
+				Type: list(&graphql.Object{
+					Name: "TrendingItem",
+					Fields: map[string]*graphql.Field{
+						"id":    {Type: nonNull(graphql.ID)},
+						"title": {Type: nonNull(str)},
+						"type":  {Type: nonNull(mediaType)},
+						"views": {Type: nonNull(graphql.Int)},
+					},
+				}),
+				Args: map[string]*graphql.Argument{
+					"window": {Type: str, Default: "1d"},
+					"limit":  {Type: graphql.Int, Default: 20},
+				},
+				// A read per day of the window, and one for the titles
+				Cost: 31,
+				Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+					days, err := parseWindow(args["window"].(string))
+					if err != nil || days < 1 || days > analytics.MaxTrendingDays {
+						return nil, errors.New("window must be whole days between 1d and 30d")
+					}
+					limit := args["limit"].(int)
+					if limit < 1 || limit > 100 {
+						return nil, errors.New("limit must be between 1 and 100")
+					}
+
+					items, err := analyticsSvc.Trending(ctx, days, limit)
+					if err != nil {
+						return nil, resolveErr(err, "failed to get trending media")
+					}
+					return items, nil
+				},
+			},
+		},
+	}
+
+	return graphql.NewSchema(query, graphqlLimits)
+}
+
+// optional resolves an empty media string field to null
+func optional(get func(*stream.MediaInfo) string) graphql.ResolveFunc {
+	return func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+		if v := get(source.(*stream.MediaInfo)); v != "" {
+			return v, nil
+		}
+		return nil, nil
+	}
+}
@@ -2,66 +2,231 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/auth"
 	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/embed"
+	"github.com/streaming-service/internal/service/keys"
 	"github.com/streaming-service/internal/service/stream"
 	"github.com/streaming-service/internal/service/upload"
+	"github.com/streaming-service/internal/validate"
 	"github.com/streaming-service/pkg/logger"
 )
 
 // Upload request body
 type uploadRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Visibility  domain.Visibility `json:"visibility"`
+	// AutoCaptions requests captions transcribed from the speech
+	AutoCaptions bool              `json:"auto_captions"`
+	Tags         map[string]string `json:"tags"`
+	// ExpiresAt expires the media then, when retention is enabled
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Filename is the name the presigned upload was requested for, which
+	// its stored extension comes from; multipart forms take the file's
+	Filename string `json:"filename"`
 }
 
-// Presign request body
+func (req *uploadRequest) Validate(v *validate.Validator) {
+	v.MaxLength("filename", req.Filename, maxFilenameLength)
+	validateMetadata(v, &req.Title, &req.Description)
+	validateVisibility(v, req.Visibility, false)
+	validateTags(v, req.Tags, 0)
+	v.Check(req.ExpiresAt == nil || req.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+}
+
+// Set visibility request body
+type visibilityRequest struct {
+	Visibility domain.Visibility `json:"visibility"`
+}
+
+func (req *visibilityRequest) Validate(v *validate.Validator) {
+	validateVisibility(v, req.Visibility, true)
+}
+
+// Presign request body, also starting multipart uploads
 type presignRequest struct {
 	Filename    string `json:"filename"`
 	ContentType string `json:"content_type"`
 }
 
+func (req *presignRequest) Validate(v *validate.Validator) {
+	v.Required("filename", req.Filename)
+	v.MaxLength("filename", req.Filename, maxFilenameLength)
+	v.Required("content_type", req.ContentType)
+}
+
+// Part URLs request body
+type partURLsRequest struct {
+	UploadID string  `json:"upload_id"`
+	Filename string  `json:"filename"`
+	Parts    []int32 `json:"parts"`
+}
+
+func (req *partURLsRequest) Validate(v *validate.Validator) {
+	v.Required("upload_id", req.UploadID)
+	v.Required("filename", req.Filename)
+	v.Items("parts", len(req.Parts), 1, upload.MaxPartURLs)
+	for _, n := range req.Parts {
+		if n < 1 || n > upload.MaxParts {
+			v.Check(false, "parts", fmt.Sprintf("part numbers must be between 1 and %d", upload.MaxParts))
+			break
+		}
+	}
+}
+
+// Complete multipart upload request body: the parts, and the media's
+// details as when confirming a presigned upload
+type completeMultipartRequest struct {
+	uploadRequest
+	UploadID string        `json:"upload_id"`
+	Parts    []upload.Part `json:"parts"`
+}
+
+func (req *completeMultipartRequest) Validate(v *validate.Validator) {
+	req.uploadRequest.Validate(v)
+	v.Required("upload_id", req.UploadID)
+	v.Required("filename", req.Filename)
+	v.Items("parts", len(req.Parts), 1, upload.MaxParts)
+	for i, part := range req.Parts {
+		if part.PartNumber < 1 || part.PartNumber > upload.MaxParts || part.ETag == "" {
+			v.Check(false, fmt.Sprintf("parts[%d]", i), fmt.Sprintf("needs a part_number between 1 and %d and its etag", upload.MaxParts))
+		}
+	}
+}
+
+// Abort multipart upload request body
+type abortMultipartRequest struct {
+	UploadID string `json:"upload_id"`
+	Filename string `json:"filename"`
+}
+
+func (req *abortMultipartRequest) Validate(v *validate.Validator) {
+	v.Required("upload_id", req.UploadID)
+	v.Required("filename", req.Filename)
+}
+
+// maxFormMemory is how much of a multipart form, such as a subtitle
+// upload, is held in memory
+const maxFormMemory = 32 << 20
+
+// maxFilenameLength bounds the length of uploaded file names
+const maxFilenameLength = 255
+
+// validateMetadata checks the lengths of a title and description, either
+// of which may be omitted
+func validateMetadata(v *validate.Validator, title, description *string) {
+	if title != nil {
+		v.MaxLength("title", *title, domain.MaxTitleLength)
+	}
+	if description != nil {
+		v.MaxLength("description", *description, domain.MaxDescriptionLength)
+	}
+}
+
+// validateVisibility checks a visibility field, which may be omitted to
+// use the default unless required
+func validateVisibility(v *validate.Validator, visibility domain.Visibility, required bool) {
+	if required {
+		v.Required("visibility", string(visibility))
+	}
+	v.OneOf("visibility", string(visibility), string(domain.VisibilityPublic), string(domain.VisibilityUnlisted), string(domain.VisibilityPrivate))
+}
+
 // uploadHandler handles direct file uploads
 func uploadHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse multipart form (max 100MB)
-		if err := r.ParseMultipartForm(100 << 20); err != nil {
-			respondError(w, http.StatusBadRequest, "failed to parse form")
-			return
-		}
-
-		file, header, err := r.FormFile("file")
+		// Stream the multipart form, spooling the file to disk; the body
+		// is capped by server.maxuploadsize, and files that aren't allowed
+		// are rejected before they are read
+		body, err := readUploadForm(r, svc.CheckFile)
 		if err != nil {
-			respondError(w, http.StatusBadRequest, "file is required")
+			var fe formError
+			switch {
+			case respondTooLarge(w, err):
+			case errors.Is(err, domain.ErrFileTypeNotAllowed):
+				respondDomainError(w, err, http.StatusUnsupportedMediaType, "file type not allowed")
+			case errors.As(err, &fe):
+				respondError(w, http.StatusBadRequest, fe.Error())
+			default:
+				respondError(w, http.StatusBadRequest, "failed to parse form")
+			}
 			return
 		}
-		defer file.Close()
+		defer body.Close()
 
-		title := r.FormValue("title")
-		if title == "" {
-			title = header.Filename
+		form := uploadRequest{
+			Title:       body.Value("title"),
+			Description: body.Value("description"),
+			Visibility:  domain.Visibility(body.Value("visibility")),
+		}
+		if v := body.Value("auto_captions"); v != "" {
+			if form.AutoCaptions, err = strconv.ParseBool(v); err != nil {
+				respondError(w, http.StatusBadRequest, "auto_captions must be true or false")
+				return
+			}
+		}
+		if v := body.Value("tags"); v != "" {
+			if err := json.Unmarshal([]byte(v), &form.Tags); err != nil {
+				respondError(w, http.StatusBadRequest, "tags must be a JSON object of strings")
+				return
+			}
+		}
+		if v := body.Value("expires_at"); v != "" {
+			at, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "expires_at must be an RFC 3339 time")
+				return
+			}
+			form.ExpiresAt = &at
+		}
+		if form.Title == "" {
+			form.Title = body.filename
+		}
+		if errs := validate.Struct(&form); errs != nil {
+			respondProblem(w, http.StatusBadRequest, "request failed validation", errs)
+			return
 		}
 
 		// Get user ID from context (set by auth middleware)
 		userID := getUserID(r)
 
 		req := &upload.UploadRequest{
-			Title:       title,
-			Description: r.FormValue("description"),
-			UserID:      userID,
-			Filename:    header.Filename,
-			ContentType: header.Header.Get("Content-Type"),
-			Body:        file,
+			Title:        form.Title,
+			Description:  form.Description,
+			UserID:       userID,
+			Visibility:   form.Visibility,
+			Filename:     body.filename,
+			ContentType:  body.contentType,
+			Body:         body.file,
+			Size:         body.size,
+			AutoCaptions: form.AutoCaptions,
+			Tags:         form.Tags,
+			ExpiresAt:    form.ExpiresAt,
 		}
 
 		resp, err := svc.Upload(r.Context(), req)
 		if err != nil {
+			switch err {
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, "invalid visibility, tags or expires_at")
+				return
+			case domain.ErrQuotaExceeded:
+				respondDomainError(w, err, http.StatusTooManyRequests, "quota exceeded")
+				return
+			}
 			log.Error("upload failed", "error", err)
 			respondError(w, http.StatusInternalServerError, "upload failed")
 			return
 		}
+		uploadSize.Observe(float64(body.size))
 
 		respondJSON(w, http.StatusCreated, resp)
 	}
@@ -71,13 +236,7 @@ func uploadHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
 func presignHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req presignRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondError(w, http.StatusBadRequest, "invalid request body")
-			return
-		}
-
-		if req.Filename == "" || req.ContentType == "" {
-			respondError(w, http.StatusBadRequest, "filename and content_type are required")
+		if !decodeBody(w, r, &req) {
 			return
 		}
 
@@ -85,6 +244,14 @@ func presignHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
 
 		resp, err := svc.GetPresignedUploadURL(r.Context(), userID, req.Filename, req.ContentType)
 		if err != nil {
+			switch err {
+			case domain.ErrFileTypeNotAllowed:
+				respondDomainError(w, err, http.StatusUnsupportedMediaType, "file type not allowed")
+				return
+			case domain.ErrQuotaExceeded:
+				respondDomainError(w, err, http.StatusTooManyRequests, "quota exceeded")
+				return
+			}
 			log.Error("failed to generate presigned URL", "error", err)
 			respondError(w, http.StatusInternalServerError, "failed to generate upload URL")
 			return
@@ -104,21 +271,33 @@ func confirmUploadHandler(svc *upload.Service, log *logger.Logger) http.HandlerF
 		}
 
 		var body uploadRequest
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			respondError(w, http.StatusBadRequest, "invalid request body")
+		if !decodeBody(w, r, &body) {
 			return
 		}
 
 		userID := getUserID(r)
 
 		req := &upload.UploadRequest{
-			Title:       body.Title,
-			Description: body.Description,
-			UserID:      userID,
+			Title:        body.Title,
+			Description:  body.Description,
+			UserID:       userID,
+			Visibility:   body.Visibility,
+			Filename:     body.Filename,
+			AutoCaptions: body.AutoCaptions,
+			Tags:         body.Tags,
+			ExpiresAt:    body.ExpiresAt,
 		}
 
 		resp, err := svc.ConfirmUpload(r.Context(), req, mediaID)
 		if err != nil {
+			switch err {
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, "invalid visibility, tags or expires_at")
+				return
+			case domain.ErrQuotaExceeded:
+				respondDomainError(w, err, http.StatusTooManyRequests, "quota exceeded")
+				return
+			}
 			log.Error("failed to confirm upload", "error", err)
 			respondError(w, http.StatusInternalServerError, "failed to confirm upload")
 			return
@@ -128,6 +307,164 @@ func confirmUploadHandler(svc *upload.Service, log *logger.Logger) http.HandlerF
 	}
 }
 
+// startMultipartHandler starts a multipart upload for a large source
+func startMultipartHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req presignRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		resp, err := svc.StartMultipartUpload(r.Context(), getUserID(r), req.Filename, req.ContentType)
+		if err != nil {
+			switch err {
+			case domain.ErrFileTypeNotAllowed:
+				respondDomainError(w, err, http.StatusUnsupportedMediaType, "file type not allowed")
+				return
+			case domain.ErrQuotaExceeded:
+				respondDomainError(w, err, http.StatusTooManyRequests, "quota exceeded")
+				return
+			}
+			log.Error("failed to start multipart upload", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to start upload")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// partURLsHandler presigns URLs for parts of a multipart upload
+func partURLsHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		var body partURLsRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		parts, err := svc.GetPartURLs(r.Context(), mediaID, body.UploadID, body.Filename, body.Parts)
+		if err != nil {
+			switch err {
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, "invalid part numbers")
+			case domain.ErrUploadNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "upload not found")
+			default:
+				log.Error("failed to generate part URLs", "error", err, "media_id", mediaID)
+				respondError(w, http.StatusInternalServerError, "failed to generate part URLs")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"media_id":  mediaID,
+			"upload_id": body.UploadID,
+			"parts":     parts,
+		})
+	}
+}
+
+// completeMultipartHandler assembles a multipart upload's parts and
+// confirms the upload
+func completeMultipartHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		var body completeMultipartRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		req := &upload.UploadRequest{
+			Title:        body.Title,
+			Description:  body.Description,
+			UserID:       getUserID(r),
+			Visibility:   body.Visibility,
+			Filename:     body.Filename,
+			AutoCaptions: body.AutoCaptions,
+			Tags:         body.Tags,
+			ExpiresAt:    body.ExpiresAt,
+		}
+
+		resp, err := svc.CompleteMultipartUpload(r.Context(), req, mediaID, body.UploadID, body.Parts)
+		if err != nil {
+			switch err {
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, "parts don't match the uploaded parts, or invalid visibility, tags or expires_at")
+			case domain.ErrUploadNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "upload not found")
+			case domain.ErrQuotaExceeded:
+				respondDomainError(w, err, http.StatusTooManyRequests, "quota exceeded")
+			default:
+				log.Error("failed to complete multipart upload", "error", err, "media_id", mediaID)
+				respondError(w, http.StatusInternalServerError, "failed to complete upload")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// abortMultipartHandler discards a multipart upload
+func abortMultipartHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		var body abortMultipartRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		if err := svc.AbortMultipartUpload(r.Context(), mediaID, body.UploadID, body.Filename); err != nil {
+			if err == domain.ErrUploadNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "upload not found")
+				return
+			}
+			log.Error("failed to abort multipart upload", "error", err, "media_id", mediaID)
+			respondError(w, http.StatusInternalServerError, "failed to abort upload")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// retryMediaHandler queues failed media for processing again
+func retryMediaHandler(svc *upload.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		if mediaID == "" {
+			respondError(w, http.StatusBadRequest, "media ID is required")
+			return
+		}
+
+		retry, err := svc.Retry(r.Context(), mediaID, getUserID(r))
+		if err != nil {
+			switch err {
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			case domain.ErrInvalidMediaStatus:
+				respondDomainError(w, err, http.StatusConflict, "only failed media can be retried")
+			case domain.ErrQuotaExceeded:
+				respondDomainError(w, err, http.StatusTooManyRequests, "quota exceeded")
+			case domain.ErrQueueUnavailable:
+				respondDomainError(w, err, http.StatusServiceUnavailable, "job queue unavailable")
+			default:
+				log.Error("failed to retry media", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to retry media")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusAccepted, retry)
+	}
+}
+
 // getMediaHandler retrieves media information
 func getMediaHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -137,10 +474,10 @@ func getMediaHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
 			return
 		}
 
-		info, err := svc.GetMedia(r.Context(), mediaID)
+		info, err := svc.GetMedia(r.Context(), mediaID, getUserID(r))
 		if err != nil {
 			if err == domain.ErrMediaNotFound {
-				respondError(w, http.StatusNotFound, "media not found")
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
 				return
 			}
 			log.Error("failed to get media", "error", err)
@@ -148,29 +485,151 @@ func getMediaHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
 			return
 		}
 
+		// Private media is only visible to its owner, so it must not be
+		// kept by shared caches
+		cacheControl := publicMediaCacheControl
+		if info.Visibility == domain.VisibilityPrivate {
+			cacheControl = privateMediaCacheControl
+		}
+		w.Header().Set("Vary", authVaryHeaders)
+		if setCacheHeaders(w, r, cacheControl, info.UpdatedAt) {
+			return
+		}
+
+		respondJSON(w, http.StatusOK, info)
+	}
+}
+
+// mediaProgressHandler reports how far processing a media item has got.
+// It is polled while the worker runs, so it is never cached.
+func mediaProgressHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := svc.GetProgress(r.Context(), chi.URLParam(r, "mediaID"), getUserID(r))
+		if err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			log.Error("failed to get processing progress", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to get processing progress")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
 		respondJSON(w, http.StatusOK, info)
 	}
 }
 
-// listMediaHandler lists media for a user
+// listMediaHandler lists media for a user a page at a time
 func listMediaHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := getUserID(r)
 
-		media, err := svc.ListMedia(r.Context(), userID, 100)
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		filter, msg := parseMediaFilter(r)
+		if msg != "" {
+			respondError(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		media, next, err := svc.ListMedia(r.Context(), userID, filter, int32(limit), r.URL.Query().Get("cursor"))
 		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid cursor or filter")
+				return
+			}
 			log.Error("failed to list media", "error", err)
 			respondError(w, http.StatusInternalServerError, "failed to list media")
 			return
 		}
 
-		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"items": media,
-			"count": len(media),
-		})
+		respondPage(w, &page{Items: media, Count: len(media), NextCursor: next})
 	}
 }
 
+// setVisibilityHandler changes who can see a media item
+func setVisibilityHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		if mediaID == "" {
+			respondError(w, http.StatusBadRequest, "media ID is required")
+			return
+		}
+
+		var body visibilityRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		if err := svc.SetVisibility(r.Context(), mediaID, getUserID(r), body.Visibility); err != nil {
+			switch err {
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, "visibility must be public, unlisted or private")
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			default:
+				log.Error("failed to set visibility", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to set visibility")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, body)
+	}
+}
+
+// parseMediaFilter reads the listing filters from the query string,
+// returning a message describing the first invalid parameter
+func parseMediaFilter(r *http.Request) (*domain.MediaFilter, string) {
+	q := r.URL.Query()
+
+	filter := &domain.MediaFilter{
+		Status: domain.MediaStatus(q.Get("status")),
+		Type:   domain.MediaType(q.Get("type")),
+		Tag:    q.Get("tag"),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, "status must be pending, processing, completed or failed"
+	}
+	if filter.Type != "" && !filter.Type.IsValid() {
+		return nil, "type must be video or audio"
+	}
+
+	for param, dst := range map[string]*time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, param + " must be an RFC 3339 timestamp"
+			}
+			*dst = t
+		}
+	}
+
+	switch q.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return nil, "order must be asc or desc"
+	}
+
+	return filter, ""
+}
+
 // deleteMediaHandler deletes a media item
 func deleteMediaHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -184,11 +643,15 @@ func deleteMediaHandler(svc *stream.Service, log *logger.Logger) http.HandlerFun
 
 		if err := svc.DeleteMedia(r.Context(), mediaID, userID); err != nil {
 			if err == domain.ErrMediaNotFound {
-				respondError(w, http.StatusNotFound, "media not found")
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
 				return
 			}
 			if err == domain.ErrUnauthorized {
-				respondError(w, http.StatusForbidden, "unauthorized")
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+				return
+			}
+			if err == domain.ErrLegalHold {
+				respondDomainError(w, err, http.StatusConflict, "media is under legal hold")
 				return
 			}
 			log.Error("failed to delete media", "error", err)
@@ -201,7 +664,7 @@ func deleteMediaHandler(svc *stream.Service, log *logger.Logger) http.HandlerFun
 }
 
 // playbackHandler returns playback URLs
-func playbackHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+func playbackHandler(svc *stream.Service, keysSvc *keys.Service, embedSvc *embed.Service, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		mediaID := chi.URLParam(r, "mediaID")
 		if mediaID == "" {
@@ -209,10 +672,27 @@ func playbackHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
 			return
 		}
 
-		url, err := svc.GetPlaybackURL(r.Context(), mediaID)
+		session := newPlaybackSession(r)
+
+		// An embed token grants playback of this media item in place of
+		// the caller's own access
+		if tok := r.URL.Query().Get(embedTokenParam); tok != "" {
+			if embedSvc == nil {
+				respondError(w, http.StatusBadRequest, "embed tokens are not enabled")
+				return
+			}
+			if err := embedSvc.Verify(tok, mediaID, r.Header.Get("Origin")); err != nil {
+				respondDomainError(w, err, http.StatusUnauthorized, "invalid or expired embed token")
+				return
+			}
+			delete(session.Params, embedTokenParam)
+			session.Embedded = true
+		}
+
+		playback, err := svc.GetPlayback(r.Context(), mediaID, session)
 		if err != nil {
 			if err == domain.ErrMediaNotFound {
-				respondError(w, http.StatusNotFound, "media not found")
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
 				return
 			}
 			log.Error("failed to get playback URL", "error", err)
@@ -220,19 +700,57 @@ func playbackHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
 			return
 		}
 
-		respondJSON(w, http.StatusOK, map[string]string{
-			"playback_url": url,
-		})
+		resp := map[string]interface{}{
+			"playback_url": playback.URL,
+		}
+		if playback.DASHURL != "" {
+			resp["dash_url"] = playback.DASHURL
+		}
+		if playback.StoryboardURL != "" {
+			resp["storyboard_url"] = playback.StoryboardURL
+		}
+		if playback.DRM != nil {
+			resp["drm"] = playback.DRM
+		}
+
+		// Encrypted renditions need a token to fetch content keys
+		if keysSvc != nil {
+			tok, err := keysSvc.IssuePlaybackToken(mediaID, session.UserID)
+			if err != nil {
+				log.Error("failed to issue playback token", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to issue playback token")
+				return
+			}
+			resp["key_token"] = tok
+		}
+
+		respondJSON(w, http.StatusOK, resp)
+	}
+}
+
+// newPlaybackSession describes the viewer making a playback request, with
+// its query parameters, from which the ad service forwards the allowed
+// targeting parameters
+func newPlaybackSession(r *http.Request) *stream.PlaybackSession {
+	session := &stream.PlaybackSession{
+		SessionID: getSessionID(r, ""),
+		UserID:    getUserID(r),
+		UserAgent: r.UserAgent(),
+		ClientIP:  clientIP(r),
+		Params:    make(map[string]string),
+	}
+	for key, values := range r.URL.Query() {
+		if len(values) > 0 {
+			session.Params[key] = values[0]
+		}
 	}
+	return session
 }
 
-// getUserID extracts user ID from request context
-// In production, this would come from auth middleware
+// getUserID returns the authenticated user from the request context
 func getUserID(r *http.Request) string {
-	// Placeholder - should come from JWT or session
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
-		userID = "anonymous"
+	if claims, ok := auth.FromContext(r.Context()); ok {
+		return claims.UserID
 	}
-	return userID
+	return "anonymous"
 }
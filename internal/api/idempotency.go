@@ -0,0 +1,239 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/idempotency"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// idempotencyKeyHeader carries the client's key for a retryable POST
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks a replayed response
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength bounds client-chosen keys
+	maxIdempotencyKeyLength = 255
+	// maxBufferedBody is how much of a request body is kept in memory
+	// while fingerprinting; larger bodies are spooled to disk
+	maxBufferedBody = 1 << 20
+)
+
+// replayedHeaders are the response headers stored for replay
+var replayedHeaders = []string{"Content-Type", "Location", versionHeader}
+
+// idempotent lets clients retry a POST with the same Idempotency-Key
+// header without repeating it: the first response is stored and replayed
+// to retries, concurrent retries get 409 and reusing a key for a
+// different request gets 422. Requests without the header, and all
+// requests when svc is nil, pass through.
+func idempotent(svc *idempotency.Service, log *logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if svc == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			userID := getUserID(r)
+			if key == "" || userID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength))
+				return
+			}
+
+			fingerprint, cleanup, err := fingerprintRequest(r)
+			if err != nil {
+				if !respondTooLarge(w, err) {
+					respondError(w, http.StatusBadRequest, "failed to read request body")
+				}
+				return
+			}
+			defer cleanup()
+
+			replay, err := svc.Begin(r.Context(), userID, key, fingerprint)
+			if err != nil {
+				switch err {
+				case domain.ErrRequestInProgress:
+					respondDomainError(w, err, http.StatusConflict, "a request with this Idempotency-Key is in progress")
+				case domain.ErrIdempotencyReused:
+					respondDomainError(w, err, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				default:
+					log.Error("failed to claim idempotency key", "error", err)
+					respondError(w, http.StatusInternalServerError, "failed to process request")
+				}
+				return
+			}
+
+			if replay != nil {
+				for name, value := range replay.Header {
+					w.Header().Set(name, value)
+				}
+				w.Header().Set(idempotentReplayedHeader, "true")
+				w.WriteHeader(replay.Status)
+				_, _ = w.Write(replay.Body)
+				return
+			}
+
+			// Release the key unless the response is stored, so failed and
+			// panicking requests can be retried
+			ctx := context.WithoutCancel(r.Context())
+			stored := false
+			defer func() {
+				if !stored {
+					if err := svc.Release(ctx, userID, key); err != nil {
+						log.Error("failed to release idempotency key", "error", err)
+					}
+				}
+			}()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			body := &cappedBuffer{max: idempotency.MaxResponseSize}
+			ww.Tee(body)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			// Server errors are worth retrying
+			if status >= http.StatusInternalServerError {
+				return
+			}
+			if body.overflow {
+				log.Warn("response too large to store for idempotency", "path", r.URL.Path, "bytes", ww.BytesWritten())
+				return
+			}
+
+			header := make(map[string]string, len(replayedHeaders))
+			for _, name := range replayedHeaders {
+				if value := w.Header().Get(name); value != "" {
+					header[name] = value
+				}
+			}
+			if err := svc.Complete(ctx, userID, key, fingerprint, &idempotency.Response{
+				Status: status,
+				Header: header,
+				Body:   body.Bytes(),
+			}); err != nil {
+				log.Error("failed to store idempotent response", "error", err)
+				return
+			}
+			stored = true
+		})
+	}
+}
+
+// fingerprintRequest hashes a request's method, path and body, replacing
+// the body so the handler can still read it. Multipart bodies are hashed
+// by their parts, since clients pick a new boundary on every attempt.
+func fingerprintRequest(r *http.Request) (string, func(), error) {
+	body, cleanup, err := spoolBody(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" && params["boundary"] != "" {
+		err = hashParts(h, multipart.NewReader(body, params["boundary"]))
+	} else {
+		_, err = io.Copy(h, body)
+	}
+	if err == nil {
+		_, err = body.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), cleanup, nil
+}
+
+// hashParts hashes the names and contents of multipart form parts
+func hashParts(w io.Writer, mr *multipart.Reader) error {
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%q %q %q\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"))
+		if _, err := io.Copy(w, part); err != nil {
+			return err
+		}
+	}
+}
+
+// spoolBody reads a request body into memory, or a temporary file when it
+// is large, and replaces it with the buffered copy
+func spoolBody(r *http.Request) (io.ReadSeeker, func(), error) {
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxBufferedBody+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(buf) <= maxBufferedBody {
+		body := bytes.NewReader(buf)
+		r.Body = io.NopCloser(body)
+		return body, func() {}, nil
+	}
+
+	f, err := os.CreateTemp("", "request-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if _, err := f.Write(buf); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := io.Copy(f, r.Body); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	r.Body = f
+	return f, cleanup, nil
+}
+
+// cappedBuffer keeps up to max bytes written to it, noting any overflow
+type cappedBuffer struct {
+	bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow || b.Len()+len(p) > b.max {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
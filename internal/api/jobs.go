@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/jobs"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// respondJobError maps the errors of job calls
+func respondJobError(w http.ResponseWriter, log *logger.Logger, err error, action string) {
+	switch err {
+	case domain.ErrJobNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "job not found")
+	case domain.ErrMediaNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "media not found")
+	case domain.ErrUnauthorized:
+		respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+	case domain.ErrInvalidInput:
+		respondDomainError(w, err, http.StatusBadRequest, "invalid cursor")
+	default:
+		log.Error("failed to "+action, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to "+action)
+	}
+}
+
+// getJobHandler returns the status of a job of one of the caller's media
+// items
+func getJobHandler(svc *jobs.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := svc.GetJob(r.Context(), chi.URLParam(r, "jobID"), getUserID(r))
+		if err != nil {
+			respondJobError(w, log, err, "get job")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		respondJSON(w, http.StatusOK, job)
+	}
+}
+
+// listMediaJobsHandler pages through the job history of one of the
+// caller's media items
+func listMediaJobsHandler(svc *jobs.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		limit := 20
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		history, next, err := svc.ListMediaJobs(r.Context(), chi.URLParam(r, "mediaID"), getUserID(r), int32(limit), q.Get("cursor"))
+		if err != nil {
+			respondJobError(w, log, err, "list jobs")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		respondPage(w, &page{Items: history, Count: len(history), NextCursor: next})
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/keys"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// keyHandler serves an AES-128 content key to holders of a playback token
+func keyHandler(svc *keys.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		keyID := chi.URLParam(r, "keyID")
+		if mediaID == "" || keyID == "" {
+			respondError(w, http.StatusBadRequest, "media ID and key ID are required")
+			return
+		}
+
+		// Players attach the token either as a bearer token or query param
+		tok := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			tok = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if tok == "" {
+			respondError(w, http.StatusUnauthorized, "playback token is required")
+			return
+		}
+
+		key, err := svc.GetKey(r.Context(), mediaID, keyID, tok)
+		if err != nil {
+			switch err {
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "invalid playback token")
+			case domain.ErrKeyNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "key not found")
+			default:
+				log.Error("failed to get content key", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to get content key")
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(key)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// maxLegalHoldReasonLength bounds the reason recorded with a legal hold
+const maxLegalHoldReasonLength = 1000
+
+// Legal hold request body
+type legalHoldRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (req *legalHoldRequest) Validate(v *validate.Validator) {
+	v.Required("reason", req.Reason)
+	v.MaxLength("reason", req.Reason, maxLegalHoldReasonLength)
+}
+
+// legalHoldResponse reports the legal hold on a media item; LegalHold is
+// null when it has none
+type legalHoldResponse struct {
+	MediaID   string            `json:"media_id"`
+	LegalHold *domain.LegalHold `json:"legal_hold"`
+}
+
+// getLegalHoldHandler reports whether a media item is under legal hold
+func getLegalHoldHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		hold, err := svc.GetLegalHold(r.Context(), mediaID)
+		if err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			log.Error("failed to get legal hold", "error", err, "media_id", mediaID)
+			respondError(w, http.StatusInternalServerError, "failed to get legal hold")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, &legalHoldResponse{MediaID: mediaID, LegalHold: hold})
+	}
+}
+
+// applyLegalHoldHandler places a media item under legal hold
+func applyLegalHoldHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		var body legalHoldRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		hold, err := svc.ApplyLegalHold(r.Context(), mediaID, getUserID(r), body.Reason)
+		if err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			log.Error("failed to apply legal hold", "error", err, "media_id", mediaID)
+			respondError(w, http.StatusInternalServerError, "failed to apply legal hold")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, &legalHoldResponse{MediaID: mediaID, LegalHold: hold})
+	}
+}
+
+// releaseLegalHoldHandler releases the legal hold on a media item
+func releaseLegalHoldHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		if err := svc.ReleaseLegalHold(r.Context(), mediaID, getUserID(r)); err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			log.Error("failed to release legal hold", "error", err, "media_id", mediaID)
+			respondError(w, http.StatusInternalServerError, "failed to release legal hold")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// likeHandler likes or unlikes a media item for the user, or reports
+// whether they like it, depending on the method
+func likeHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID, userID := chi.URLParam(r, "mediaID"), getUserID(r)
+
+		var status *stream.LikeStatus
+		var err error
+		switch r.Method {
+		case http.MethodPut:
+			status, err = svc.Like(r.Context(), mediaID, userID)
+		case http.MethodDelete:
+			status, err = svc.Unlike(r.Context(), mediaID, userID)
+		default:
+			status, err = svc.GetLike(r.Context(), mediaID, userID)
+		}
+		if err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			log.Error("failed to update like", "error", err, "method", r.Method)
+			respondError(w, http.StatusInternalServerError, "failed to update like")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, status)
+	}
+}
+
+// listFavoritesHandler lists the media the user liked a page at a time
+func listFavoritesHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		media, next, err := svc.ListFavorites(r.Context(), getUserID(r), int32(limit), r.URL.Query().Get("cursor"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid cursor")
+				return
+			}
+			log.Error("failed to list favorites", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to list favorites")
+			return
+		}
+
+		respondPage(w, &page{
+			Items:      media,
+			Count:      len(media),
+			NextCursor: next,
+		})
+	}
+}
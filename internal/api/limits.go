@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// BodyLimits caps request bodies in bytes by kind of route; zero leaves a
+// kind uncapped
+type BodyLimits struct {
+	// Default caps JSON and other small bodies
+	Default int64
+	// Upload caps direct multipart media uploads
+	Upload int64
+	// Artwork caps channel artwork images
+	Artwork int64
+}
+
+// limitedBody is a request body capped by limitBody, keeping the original
+// so a route can replace the default cap rather than nest within it
+type limitedBody struct {
+	io.ReadCloser
+	orig io.ReadCloser
+}
+
+// limitBody caps request bodies at n bytes; reads past the cap fail with
+// *http.MaxBytesError, which handlers answer with 413
+func limitBody(n int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := r.Body
+			if lb, ok := body.(*limitedBody); ok {
+				body = lb.orig
+			}
+			if n > 0 {
+				body = &limitedBody{ReadCloser: http.MaxBytesReader(w, body, n), orig: body}
+			}
+			r.Body = body
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// respondTooLarge answers with 413 when err is a body over its cap,
+// reporting whether it did
+func respondTooLarge(w http.ResponseWriter, err error) bool {
+	var sizeErr *http.MaxBytesError
+	if !errors.As(err, &sizeErr) {
+		return false
+	}
+	respondError(w, http.StatusRequestEntityTooLarge, "request body must be at most "+formatSize(sizeErr.Limit))
+	return true
+}
+
+// formatSize formats a byte count for error messages, such as "5MB"
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
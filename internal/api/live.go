@@ -0,0 +1,369 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/live"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// maxSDPSize bounds the size of a WHIP offer
+const maxSDPSize = 64 << 10
+
+// Create live stream request body
+type createLiveStreamRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// DVRWindow is the seekable window in seconds
+	DVRWindow *int `json:"dvr_window"`
+}
+
+func (req *createLiveStreamRequest) Validate(v *validate.Validator) {
+	v.Required("title", req.Title)
+	validateMetadata(v, &req.Title, &req.Description)
+	if req.DVRWindow != nil {
+		v.Min("dvr_window", float64(*req.DVRWindow), 0)
+	}
+}
+
+// createLiveStreamHandler creates a live stream and its stream key
+func createLiveStreamHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body createLiveStreamRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		req := &live.CreateStreamRequest{
+			Title:       body.Title,
+			Description: body.Description,
+			UserID:      getUserID(r),
+		}
+		if body.DVRWindow != nil {
+			window := time.Duration(*body.DVRWindow) * time.Second
+			req.DVRWindow = &window
+		}
+
+		stream, err := svc.CreateStream(r.Context(), req)
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "title is required and dvr_window must be within the allowed range")
+				return
+			}
+			log.Error("failed to create live stream", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to create live stream")
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, stream)
+	}
+}
+
+// listLiveStreamsHandler lists the caller's live streams
+func listLiveStreamsHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streams, err := svc.ListStreams(r.Context(), getUserID(r), 100)
+		if err != nil {
+			log.Error("failed to list live streams", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to list live streams")
+			return
+		}
+
+		respondPage(w, &page{Items: streams, Count: len(streams)})
+	}
+}
+
+// getLiveStreamHandler returns a live stream owned by the caller
+func getLiveStreamHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := chi.URLParam(r, "streamID")
+		if streamID == "" {
+			respondError(w, http.StatusBadRequest, "stream ID is required")
+			return
+		}
+
+		stream, err := svc.GetStream(r.Context(), streamID, getUserID(r))
+		if err != nil {
+			switch err {
+			case domain.ErrStreamNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "live stream not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			default:
+				log.Error("failed to get live stream", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to get live stream")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, stream)
+	}
+}
+
+// liveHealthHandler returns the ingest health of a live stream
+func liveHealthHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := chi.URLParam(r, "streamID")
+		if streamID == "" {
+			respondError(w, http.StatusBadRequest, "stream ID is required")
+			return
+		}
+
+		report, err := svc.GetHealth(r.Context(), streamID, getUserID(r))
+		if err != nil {
+			switch err {
+			case domain.ErrStreamNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "live stream not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			default:
+				log.Error("failed to get live stream health", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to get live stream health")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, report)
+	}
+}
+
+// livePlaybackHandler returns the playback URL of a live stream
+func livePlaybackHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := chi.URLParam(r, "streamID")
+		if streamID == "" {
+			respondError(w, http.StatusBadRequest, "stream ID is required")
+			return
+		}
+
+		url, err := svc.GetLivePlaybackURL(r.Context(), streamID)
+		if err != nil {
+			switch err {
+			case domain.ErrStreamNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "live stream not found")
+			case domain.ErrStreamNotLive:
+				respondDomainError(w, err, http.StatusConflict, "stream is not live")
+			default:
+				log.Error("failed to get live playback URL", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to get playback URL")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]string{
+			"playback_url": url,
+		})
+	}
+}
+
+// llMasterHandler serves the master playlist of a stream's LL-HLS output
+func llMasterHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := svc.LowLatencyMaster(chi.URLParam(r, "streamID"))
+		if err != nil {
+			respondLLError(w, err, log)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "max-age=1")
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		_, _ = w.Write(data)
+	}
+}
+
+// llPlaylistHandler serves a rendition's LL-HLS playlist, honouring the
+// _HLS_msn and _HLS_part blocking reload parameters
+func llPlaylistHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		msn, err := parseHLSParam(r, "_HLS_msn")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid _HLS_msn")
+			return
+		}
+		part, err := parseHLSParam(r, "_HLS_part")
+		if err != nil || (part >= 0 && msn < 0) {
+			respondError(w, http.StatusBadRequest, "invalid _HLS_part")
+			return
+		}
+
+		data, err := svc.LowLatencyPlaylist(r.Context(), chi.URLParam(r, "streamID"), chi.URLParam(r, "rendition"), msn, part)
+		if err != nil {
+			respondLLError(w, err, log)
+			return
+		}
+
+		// Blocking responses are unique per request parameters, so they
+		// can be cached briefly by a CDN that keys on the query string
+		w.Header().Set("Cache-Control", "max-age=1")
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		_, _ = w.Write(data)
+	}
+}
+
+// llFileHandler serves a part, segment or init file of an LL-HLS rendition
+func llFileHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path, err := svc.LowLatencyFile(r.Context(), chi.URLParam(r, "streamID"), chi.URLParam(r, "rendition"), chi.URLParam(r, "file"))
+		if err != nil {
+			respondLLError(w, err, log)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "max-age=31536000, immutable")
+		w.Header().Set("Content-Type", "video/mp4")
+		http.ServeFile(w, r, path)
+	}
+}
+
+// respondLLError maps LL-HLS lookup errors to responses
+func respondLLError(w http.ResponseWriter, err error, log *logger.Logger) {
+	switch err {
+	case domain.ErrStreamNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "not found")
+	case domain.ErrStreamNotLive:
+		respondDomainError(w, err, http.StatusNotFound, "stream is not live on this server")
+	case domain.ErrInvalidInput:
+		respondDomainError(w, err, http.StatusBadRequest, "requested segment is too far ahead")
+	case live.ErrPlaylistTimeout:
+		respondError(w, http.StatusServiceUnavailable, "timed out waiting for playlist update")
+	case context.Canceled:
+	default:
+		log.Error("failed to serve LL-HLS", "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to serve LL-HLS")
+	}
+}
+
+// parseHLSParam parses a blocking reload query parameter, returning -1
+// when it is absent
+func parseHLSParam(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return -1, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, domain.ErrInvalidInput
+	}
+	return n, nil
+}
+
+// livePreviewHandler returns the preview image URL of a live stream
+func livePreviewHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID := chi.URLParam(r, "streamID")
+		if streamID == "" {
+			respondError(w, http.StatusBadRequest, "stream ID is required")
+			return
+		}
+
+		url, err := svc.GetLivePreviewURL(r.Context(), streamID)
+		if err != nil {
+			switch err {
+			case domain.ErrStreamNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "live stream not found")
+			case domain.ErrStreamNotLive:
+				respondDomainError(w, err, http.StatusConflict, "stream is not live")
+			default:
+				log.Error("failed to get live preview URL", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to get preview URL")
+			}
+			return
+		}
+
+		setCacheHeaders(w, r, livePreviewCacheControl, time.Time{})
+		respondJSON(w, http.StatusOK, map[string]string{
+			"preview_url": url,
+		})
+	}
+}
+
+// whipPublishHandler accepts a WHIP offer. The stream key is sent as a
+// bearer token and the SDP answer is returned with the session resource
+// in the Location header.
+func whipPublishHandler(ingest *live.WHIPIngest, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/sdp" {
+			respondError(w, http.StatusUnsupportedMediaType, "content type must be application/sdp")
+			return
+		}
+
+		streamKey := bearerToken(r)
+		if streamKey == "" {
+			respondError(w, http.StatusUnauthorized, "stream key is required")
+			return
+		}
+
+		offer, err := io.ReadAll(io.LimitReader(r.Body, maxSDPSize))
+		if err != nil || len(offer) == 0 {
+			respondError(w, http.StatusBadRequest, "invalid SDP offer")
+			return
+		}
+
+		session, err := ingest.Publish(r.Context(), streamKey, string(offer))
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrUnauthorized):
+				respondDomainError(w, err, http.StatusUnauthorized, "invalid stream key")
+			case errors.Is(err, domain.ErrStreamAlreadyLive):
+				respondDomainError(w, err, http.StatusConflict, "stream is already live")
+			case errors.Is(err, domain.ErrInvalidInput):
+				respondDomainError(w, err, http.StatusBadRequest, "offer must contain H.264 video and Opus audio")
+			default:
+				log.Error("failed to start whip session", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to start session")
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/sdp")
+		w.Header().Set("Location", "/api/v1/live/whip/"+session.ID)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(session.Answer))
+	}
+}
+
+// whipStopHandler ends a WHIP session
+func whipStopHandler(ingest *live.WHIPIngest, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID := chi.URLParam(r, "sessionID")
+		if sessionID == "" {
+			respondError(w, http.StatusBadRequest, "session ID is required")
+			return
+		}
+
+		if err := ingest.Stop(sessionID, bearerToken(r)); err != nil {
+			switch err {
+			case domain.ErrSessionNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "session not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusUnauthorized, "invalid stream key")
+			default:
+				log.Error("failed to stop whip session", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to stop session")
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// bearerToken returns the bearer token from the Authorization header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+}
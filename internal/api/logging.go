@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Set log level request body
+type setLogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func (req *setLogLevelRequest) Validate(v *validate.Validator) {
+	v.Required("level", req.Level)
+	v.OneOf("level", req.Level, "debug", "info", "warn", "error")
+}
+
+// getLogLevelHandler reports this instance's log level
+func getLogLevelHandler(log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, map[string]string{"level": log.Level()})
+	}
+}
+
+// setLogLevelHandler changes this instance's log level until it restarts
+// or the config file is reloaded
+func setLogLevelHandler(log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body setLogLevelRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		previous := log.Level()
+		if err := log.SetLevel(body.Level); err != nil {
+			respondError(w, http.StatusBadRequest, "unknown log level")
+			return
+		}
+		log.Warn("log level changed", "from", previous, "to", body.Level, "user_id", getUserID(r))
+
+		respondJSON(w, http.StatusOK, map[string]string{"level": log.Level()})
+	}
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// maxMetadataImportRows caps the rows of one metadata import
+const maxMetadataImportRows = 1000
+
+// Metadata export formats
+const (
+	metadataFormatJSON = "json"
+	metadataFormatCSV  = "csv"
+)
+
+// metadataCSVColumns are the columns of a CSV metadata export. Imports
+// read id, title, description, visibility and tags and ignore the rest,
+// so an export can be edited and imported back.
+var metadataCSVColumns = []string{"id", "title", "description", "visibility", "tags", "type", "status", "duration", "created_at", "updated_at"}
+
+// Metadata import request body
+type metadataImportRequest struct {
+	Items []metadataImportRow `json:"items"`
+}
+
+func (req *metadataImportRequest) Validate(v *validate.Validator) {
+	v.Items("items", len(req.Items), 1, maxMetadataImportRows)
+}
+
+// metadataImportRow is one media item's update. Omitted fields are left
+// as they are; tags, when given, replace all of the item's tags.
+type metadataImportRow struct {
+	MediaID     string            `json:"id"`
+	Title       *string           `json:"title"`
+	Description *string           `json:"description"`
+	Visibility  domain.Visibility `json:"visibility"`
+	Tags        map[string]string `json:"tags"`
+}
+
+func (row *metadataImportRow) Validate(v *validate.Validator) {
+	v.Required("id", row.MediaID)
+	validateMetadata(v, row.Title, row.Description)
+	validateVisibility(v, row.Visibility, false)
+	validateTags(v, row.Tags, 0)
+}
+
+// metadataImportResult is the outcome of one row of an import, with the
+// status code the equivalent single-item call would return and the
+// fields it changed, or would change in a dry run
+type metadataImportResult struct {
+	// Row counts from 1, after the header of a CSV import
+	Row     int             `json:"row"`
+	MediaID string          `json:"media_id"`
+	Status  int             `json:"status"`
+	Changed []string        `json:"changed,omitempty"`
+	Code    string          `json:"code,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Fields  validate.Errors `json:"fields,omitempty"`
+}
+
+// exportMetadataHandler downloads the metadata of all of the user's
+// media as JSON or CSV
+func exportMetadataHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = metadataFormatJSON
+		}
+		if format != metadataFormatJSON && format != metadataFormatCSV {
+			respondError(w, http.StatusBadRequest, "format must be json or csv")
+			return
+		}
+
+		// Headers go out with the first record, so a failure before it
+		// can still be reported as an error
+		var started bool
+		var write func(*stream.MetadataRecord) error
+		var finish func() error
+		switch format {
+		case metadataFormatCSV:
+			cw := csv.NewWriter(w)
+			write = func(record *stream.MetadataRecord) error {
+				if !started {
+					started = true
+					startDownload(w, "text/csv", "metadata.csv")
+					if err := cw.Write(metadataCSVColumns); err != nil {
+						return err
+					}
+				}
+				tags, err := json.Marshal(record.Tags)
+				if err != nil {
+					return err
+				}
+				return cw.Write([]string{
+					record.ID,
+					record.Title,
+					record.Description,
+					string(record.Visibility),
+					string(tags),
+					string(record.Type),
+					string(record.Status),
+					strconv.FormatFloat(record.Duration, 'f', -1, 64),
+					record.CreatedAt.UTC().Format(time.RFC3339),
+					record.UpdatedAt.UTC().Format(time.RFC3339),
+				})
+			}
+			finish = func() error {
+				if !started {
+					startDownload(w, "text/csv", "metadata.csv")
+					_ = cw.Write(metadataCSVColumns)
+				}
+				cw.Flush()
+				return cw.Error()
+			}
+		default:
+			enc := json.NewEncoder(w)
+			write = func(record *stream.MetadataRecord) error {
+				sep := ","
+				if !started {
+					started = true
+					startDownload(w, "application/json", "metadata.json")
+					sep = "["
+				}
+				if _, err := io.WriteString(w, sep); err != nil {
+					return err
+				}
+				return enc.Encode(record)
+			}
+			finish = func() error {
+				if !started {
+					startDownload(w, "application/json", "metadata.json")
+					_, err := io.WriteString(w, "[]\n")
+					return err
+				}
+				_, err := io.WriteString(w, "]\n")
+				return err
+			}
+		}
+
+		err := svc.ExportMetadata(r.Context(), getUserID(r), write)
+		if err == nil {
+			err = finish()
+		}
+		if err != nil {
+			log.Error("failed to export metadata", "error", err, "format", format)
+			if !started {
+				respondError(w, http.StatusInternalServerError, "failed to export metadata")
+			}
+		}
+	}
+}
+
+// startDownload writes the headers of a file download
+func startDownload(w http.ResponseWriter, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+}
+
+// importMetadataHandler applies metadata updates to many media items from
+// JSON or CSV, reporting the result of each row. With ?dry_run=true the
+// updates are checked but not made.
+func importMetadataHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				respondError(w, http.StatusBadRequest, "dry_run must be true or false")
+				return
+			}
+		}
+
+		var rows []metadataImportRow
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "text/csv" {
+			var msg string
+			rows, msg = readMetadataCSV(r.Body)
+			if msg != "" {
+				respondError(w, http.StatusBadRequest, msg)
+				return
+			}
+		} else {
+			var body metadataImportRequest
+			if !decodeBody(w, r, &body) {
+				return
+			}
+			rows = body.Items
+		}
+
+		userID := getUserID(r)
+		results := make([]metadataImportResult, len(rows))
+		seen := make(map[string]bool, len(rows))
+		sem := make(chan struct{}, batchConcurrency)
+		var wg sync.WaitGroup
+		for i := range rows {
+			row := &rows[i]
+			results[i] = metadataImportResult{Row: i + 1, MediaID: row.MediaID, Status: http.StatusOK}
+
+			errs := validate.Struct(row)
+			if row.MediaID != "" && seen[row.MediaID] {
+				errs = append(errs, validate.FieldError{Field: "id", Message: "is a duplicate"})
+			}
+			seen[row.MediaID] = true
+			if errs != nil {
+				results[i].Status = http.StatusBadRequest
+				results[i].Code = "validation_failed"
+				results[i].Error = "row failed validation"
+				results[i].Fields = errs
+				continue
+			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(result *metadataImportResult, row *metadataImportRow) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				applyMetadataRow(r.Context(), svc, userID, row, dryRun, result, log)
+			}(&results[i], row)
+		}
+		wg.Wait()
+
+		succeeded := 0
+		for _, result := range results {
+			if result.Status == http.StatusOK {
+				succeeded++
+			}
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"dry_run":   dryRun,
+			"results":   results,
+			"succeeded": succeeded,
+			"failed":    len(results) - succeeded,
+		})
+	}
+}
+
+// applyMetadataRow applies one row of an import, recording its result
+func applyMetadataRow(ctx context.Context, svc *stream.Service, userID string, row *metadataImportRow, dryRun bool, result *metadataImportResult, log *logger.Logger) {
+	changed, err := svc.UpdateMetadata(ctx, row.MediaID, userID, &stream.MetadataUpdate{
+		Title:       row.Title,
+		Description: row.Description,
+		Visibility:  row.Visibility,
+		Tags:        row.Tags,
+	}, dryRun)
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			result.Status, result.Error = http.StatusBadRequest, "invalid metadata"
+		} else {
+			result.Status, result.Error = batchItemError(err)
+		}
+		result.Code = domain.ErrorCode(err)
+		if result.Code == "" {
+			result.Code = errorCode(result.Status)
+		}
+		if result.Status == http.StatusInternalServerError {
+			log.Error("metadata import row failed", "media_id", row.MediaID, "error", err)
+		}
+		return
+	}
+	result.Changed = changed
+}
+
+// readMetadataCSV reads import rows from CSV with a header row naming its
+// columns. id is required; empty title, description, visibility and tags
+// cells leave those fields as they are. tags holds a JSON object, as in
+// exports. It returns a message saying what's wrong with a malformed
+// file.
+func readMetadataCSV(body io.Reader) ([]metadataImportRow, string) {
+	cr := csv.NewReader(body)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, "request body is required"
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	if _, ok := columns["id"]; !ok {
+		return nil, "CSV header must include an id column"
+	}
+	cell := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []metadataImportRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		if len(rows) == maxMetadataImportRows {
+			return nil, fmt.Sprintf("CSV must have at most %d rows", maxMetadataImportRows)
+		}
+
+		row := metadataImportRow{
+			MediaID:    cell(record, "id"),
+			Visibility: domain.Visibility(cell(record, "visibility")),
+		}
+		if title := cell(record, "title"); title != "" {
+			row.Title = &title
+		}
+		if description := cell(record, "description"); description != "" {
+			row.Description = &description
+		}
+		if tags := cell(record, "tags"); tags != "" {
+			if err := json.Unmarshal([]byte(tags), &row.Tags); err != nil || row.Tags == nil {
+				return nil, fmt.Sprintf("tags of row %d must be a JSON object", len(rows)+1)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, "CSV must have at least one row"
+	}
+	return rows, ""
+}
+
+// csvError describes why a CSV body couldn't be read
+func csvError(err error) string {
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		return "request body must be at most " + formatSize(sizeErr.Limit)
+	}
+	return "request body is not valid CSV"
+}
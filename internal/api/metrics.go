@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/streaming-service/pkg/metrics"
+)
+
+var (
+	requestDuration = metrics.NewHistogram("streaming_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by method, route pattern and status",
+		metrics.DefaultBuckets, "method", "route", "status")
+	// uploadSize covers direct uploads; presigned uploads go straight to
+	// S3
+	uploadSize = metrics.NewHistogram("streaming_upload_size_bytes",
+		"Sizes of media files uploaded through the API",
+		metrics.ExponentialBuckets(1<<20, 4, 8))
+)
+
+// measureRequests records the latency of each request by its route
+// pattern rather than its path, so IDs don't make a series each
+func measureRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		requestDuration.Since(start, r.Method, route, strconv.Itoa(status))
+	})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/moderation"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Review request body
+type reviewMediaRequest struct {
+	Status string `json:"status"`
+}
+
+func (req *reviewMediaRequest) Validate(v *validate.Validator) {
+	v.Required("status", req.Status)
+	v.OneOf("status", req.Status, string(domain.ModerationStatusApproved), string(domain.ModerationStatusRejected))
+}
+
+// moderationQueueHandler lists media by moderation status, pending review
+// by default
+func moderationQueueHandler(svc *moderation.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := parseLimit(w, r)
+		if !ok {
+			return
+		}
+
+		status := domain.ModerationStatus(r.URL.Query().Get("status"))
+		if status == "" {
+			status = domain.ModerationStatusPendingReview
+		}
+
+		media, next, err := svc.List(r.Context(), status, int32(limit), r.URL.Query().Get("cursor"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid status or cursor")
+				return
+			}
+			log.Error("failed to list moderation queue", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to list moderation queue")
+			return
+		}
+
+		respondPage(w, &page{Items: media, Count: len(media), NextCursor: next})
+	}
+}
+
+// reviewMediaHandler records a reviewer approving or rejecting media
+func reviewMediaHandler(svc *moderation.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body reviewMediaRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		result, err := svc.Review(r.Context(), chi.URLParam(r, "mediaID"), getUserID(r), domain.ModerationStatus(body.Status))
+		if err != nil {
+			switch err {
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			default:
+				log.Error("failed to review media", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to review media")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+const (
+	// maxFormFields and maxFieldSize bound the text fields of a multipart
+	// upload, which are held in memory
+	maxFormFields = 16
+	maxFieldSize  = 64 << 10
+)
+
+// formError is a problem with an upload form the client can fix, its
+// message fit to respond with
+type formError string
+
+func (e formError) Error() string { return string(e) }
+
+// uploadForm is a multipart media upload read part by part: its text
+// fields, and its file spooled to a temporary file, so memory use doesn't
+// grow with the upload
+type uploadForm struct {
+	fields      map[string]string
+	file        *os.File
+	filename    string
+	contentType string
+	size        int64
+}
+
+// readUploadForm reads a multipart upload with one file part named
+// "file", which may come before or after the text fields. check is called
+// with the file's name and declared type before any of it is read, so a
+// file that isn't allowed is rejected without being stored anywhere.
+func readUploadForm(r *http.Request, check func(filename, contentType string) error) (*uploadForm, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &uploadForm{fields: make(map[string]string)}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			form.Close()
+			return nil, err
+		}
+
+		if part.FormName() == "file" && part.FileName() != "" {
+			err = form.spool(part, check)
+		} else {
+			err = form.readField(part)
+		}
+		part.Close()
+		if err != nil {
+			form.Close()
+			return nil, err
+		}
+	}
+
+	if form.file == nil {
+		return nil, formError("file is required")
+	}
+	if _, err := form.file.Seek(0, io.SeekStart); err != nil {
+		form.Close()
+		return nil, err
+	}
+	return form, nil
+}
+
+// spool checks the file part and copies it to a temporary file
+func (f *uploadForm) spool(part *multipart.Part, check func(filename, contentType string) error) error {
+	if f.file != nil {
+		return formError("only one file may be uploaded")
+	}
+
+	f.filename = part.FileName()
+	f.contentType = part.Header.Get("Content-Type")
+	if err := check(f.filename, f.contentType); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	f.file = file
+
+	f.size, err = io.Copy(file, part)
+	return err
+}
+
+// readField reads a text field, or discards a file part other than the
+// upload
+func (f *uploadForm) readField(part *multipart.Part) error {
+	if part.FileName() != "" {
+		_, err := io.Copy(io.Discard, part)
+		return err
+	}
+	if len(f.fields) >= maxFormFields {
+		return formError("too many form fields")
+	}
+
+	value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+	if err != nil {
+		return err
+	}
+	if len(value) > maxFieldSize {
+		return formError(fmt.Sprintf("form field %s must be at most %s", part.FormName(), formatSize(maxFieldSize)))
+	}
+	f.fields[part.FormName()] = string(value)
+	return nil
+}
+
+// Value returns a text field, or "" if the form has none by the name
+func (f *uploadForm) Value(name string) string {
+	return f.fields[name]
+}
+
+// Close removes the spooled file
+func (f *uploadForm) Close() {
+	if f.file == nil {
+		return
+	}
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
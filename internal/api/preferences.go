@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/streaming-service/internal/auth"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/notify"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Notification preferences request body
+type notificationPreferencesRequest struct {
+	domain.NotificationPreferences
+}
+
+func (req *notificationPreferencesRequest) Validate(v *validate.Validator) {
+	if req.Email != "" {
+		v.Check(strings.Count(req.Email, "@") == 1 && !strings.HasPrefix(req.Email, "@") && !strings.HasSuffix(req.Email, "@"),
+			"email", "must be an email address")
+		v.MaxLength("email", req.Email, 254)
+	}
+}
+
+// getNotificationPreferencesHandler returns the user's notification
+// preferences
+func getNotificationPreferencesHandler(svc *notify.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		prefs, err := svc.GetPreferences(r.Context(), getUserID(r))
+		if err != nil {
+			log.Error("failed to get preferences", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to get preferences")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, prefs.Notifications)
+	}
+}
+
+// updateNotificationPreferencesHandler replaces the user's notification
+// preferences. The email defaults to the one in the user's token.
+func updateNotificationPreferencesHandler(svc *notify.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body notificationPreferencesRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+		if body.Email == "" {
+			if claims, ok := auth.FromContext(r.Context()); ok {
+				body.Email = claims.Email
+			}
+		}
+
+		prefs, err := svc.UpdatePreferences(r.Context(), getUserID(r), body.NotificationPreferences)
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "email is required while notifications are on")
+				return
+			}
+			log.Error("failed to update preferences", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to update preferences")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, prefs.Notifications)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/streaming-service/internal/validate"
+)
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details response
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	// Errors lists the invalid fields of a request body
+	Errors validate.Errors `json:"errors,omitempty"`
+	// Error repeats the detail in v1 so clients reading the usual error
+	// message keep working
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// respondProblem writes an RFC 7807 problem details response with the
+// invalid fields of a request
+func respondProblem(w http.ResponseWriter, status int, detail string, errs validate.Errors) {
+	p := &problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Errors:    errs,
+		Code:      errorCode(status),
+		RequestID: w.Header().Get(requestIDHeader),
+	}
+	if len(errs) > 0 {
+		p.Code = "validation_failed"
+	}
+	if responseVersion(w) < apiV2 {
+		p.Error = detail
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// decodeBody decodes a JSON request body into dst and runs its checks
+// when it is validate.Validatable. Malformed and invalid bodies are
+// answered with problem details naming the offending fields; it reports
+// whether the handler should go on.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		var sizeErr *http.MaxBytesError
+		switch {
+		case errors.Is(err, io.EOF):
+			respondProblem(w, http.StatusBadRequest, "request body is required", nil)
+		case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+			respondProblem(w, http.StatusBadRequest, "request body is not valid JSON", nil)
+		case errors.As(err, &typeErr):
+			field := typeErr.Field
+			if field == "" {
+				field = "body"
+			}
+			respondProblem(w, http.StatusBadRequest, "request body failed validation", validate.Errors{
+				{Field: field, Message: "must be " + jsonKind(typeErr.Type)},
+			})
+		case errors.As(err, &sizeErr):
+			respondProblem(w, http.StatusRequestEntityTooLarge, "request body must be at most "+formatSize(sizeErr.Limit), nil)
+		default:
+			respondProblem(w, http.StatusBadRequest, "invalid request body", nil)
+		}
+		return false
+	}
+
+	if v, ok := dst.(validate.Validatable); ok {
+		if errs := validate.Struct(v); errs != nil {
+			respondProblem(w, http.StatusBadRequest, "request body failed validation", errs)
+			return false
+		}
+	}
+	return true
+}
+
+// jsonKind names the JSON type a Go type decodes from
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
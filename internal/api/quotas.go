@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// quotaHandler reports the caller's tenant usage against its quotas
+func quotaHandler(svc *quotas.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		usage, err := svc.Usage(r.Context())
+		if err != nil {
+			log.Error("failed to get quota usage", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to get quota usage")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, usage)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/streaming-service/pkg/logger"
+)
+
+// ReadinessCheck probes a dependency for /ready
+type ReadinessCheck struct {
+	Name string
+	// Critical dependencies make the service unready when they are down;
+	// the others only mark it degraded
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+// dependencyStatus is the outcome of one readiness check
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// readyHandler checks every dependency concurrently, each bounded by
+// timeout, and responds 503 when a critical one is down
+func readyHandler(checks []ReadinessCheck, timeout time.Duration, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results := make(map[string]*dependencyStatus, len(checks))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, check := range checks {
+			wg.Add(1)
+			go func(check ReadinessCheck) {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(r.Context(), timeout)
+				defer cancel()
+
+				start := time.Now()
+				err := check.Check(ctx)
+				result := &dependencyStatus{
+					Status:    "up",
+					Critical:  check.Critical,
+					LatencyMS: time.Since(start).Milliseconds(),
+				}
+				if err != nil {
+					result.Status = "down"
+					result.Error = err.Error()
+					log.Warn("readiness check failed", "dependency", check.Name, "error", err)
+				}
+
+				mu.Lock()
+				results[check.Name] = result
+				mu.Unlock()
+			}(check)
+		}
+		wg.Wait()
+
+		status, code := "ready", http.StatusOK
+		for _, result := range results {
+			if result.Status == "up" {
+				continue
+			}
+			if result.Critical {
+				status, code = "not_ready", http.StatusServiceUnavailable
+				break
+			}
+			status = "degraded"
+		}
+
+		respondJSON(w, code, map[string]interface{}{
+			"status":       status,
+			"dependencies": results,
+		})
+	}
+}
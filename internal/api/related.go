@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// relatedMediaHandler lists media similar to a media item, for "up next"
+// rails
+func relatedMediaHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 10
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > stream.MaxRelated {
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", stream.MaxRelated))
+				return
+			}
+			limit = n
+		}
+
+		related, err := svc.Related(r.Context(), chi.URLParam(r, "mediaID"), getUserID(r), limit)
+		if err != nil {
+			if err == domain.ErrMediaNotFound {
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+				return
+			}
+			log.Error("failed to get related media", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to get related media")
+			return
+		}
+
+		respondPage(w, &page{
+			Items: related,
+			Count: len(related),
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/live"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Add restream destination request body
+type addDestinationRequest struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+func (req *addDestinationRequest) Validate(v *validate.Validator) {
+	v.Required("name", req.Name)
+	v.MaxLength("name", req.Name, 100)
+	v.Required("url", req.URL)
+	if req.URL != "" {
+		v.Check(live.ValidDestinationURL(req.URL), "url", "must be an rtmp or rtmps URL such as rtmp://a.rtmp.youtube.com/live2/KEY")
+	}
+}
+
+// respondDestinationError maps the errors of restream destination calls
+func respondDestinationError(w http.ResponseWriter, log *logger.Logger, err error, action string) {
+	switch err {
+	case domain.ErrStreamNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "live stream not found")
+	case domain.ErrDestinationNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "destination not found")
+	case domain.ErrUnauthorized:
+		respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+	default:
+		log.Error("failed to "+action, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to "+action)
+	}
+}
+
+// listDestinationsHandler lists a live stream's restream destinations
+// with the health of their pushes
+func listDestinationsHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dests, err := svc.ListDestinations(r.Context(), chi.URLParam(r, "streamID"), getUserID(r))
+		if err != nil {
+			respondDestinationError(w, log, err, "list restream destinations")
+			return
+		}
+
+		respondPage(w, &page{Items: dests, Count: len(dests)})
+	}
+}
+
+// addDestinationHandler adds a restream destination to a live stream
+func addDestinationHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body addDestinationRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		enabled := true
+		if body.Enabled != nil {
+			enabled = *body.Enabled
+		}
+
+		dest, err := svc.AddDestination(r.Context(), chi.URLParam(r, "streamID"), getUserID(r), &live.AddDestinationRequest{
+			Name:    body.Name,
+			URL:     body.URL,
+			Enabled: enabled,
+		})
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "a stream may have up to 5 restream destinations")
+				return
+			}
+			respondDestinationError(w, log, err, "add restream destination")
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, dest)
+	}
+}
+
+// enableDestinationHandler enables or disables a restream destination
+func enableDestinationHandler(svc *live.Service, enabled bool, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dest, err := svc.SetDestinationEnabled(r.Context(), chi.URLParam(r, "streamID"),
+			chi.URLParam(r, "destinationID"), getUserID(r), enabled)
+		if err != nil {
+			respondDestinationError(w, log, err, "update restream destination")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, dest)
+	}
+}
+
+// removeDestinationHandler removes a restream destination
+func removeDestinationHandler(svc *live.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.RemoveDestination(r.Context(), chi.URLParam(r, "streamID"),
+			chi.URLParam(r, "destinationID"), getUserID(r)); err != nil {
+			respondDestinationError(w, log, err, "remove restream destination")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
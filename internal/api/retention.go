@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/retention"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// expirationRequest sets when a media item expires; a null expires_at
+// keeps it indefinitely
+type expirationRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (req *expirationRequest) Validate(v *validate.Validator) {
+	v.Check(req.ExpiresAt == nil || req.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+}
+
+// expirationResponse reports when a media item expires
+type expirationResponse struct {
+	MediaID   string     `json:"media_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// setExpirationHandler sets or clears when a media item expires
+func setExpirationHandler(svc *retention.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		var body expirationRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		if err := svc.SetExpiration(r.Context(), mediaID, getUserID(r), body.ExpiresAt); err != nil {
+			switch err {
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, "expires_at must be in the future")
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			default:
+				log.Error("failed to set expiration", "error", err, "media_id", mediaID)
+				respondError(w, http.StatusInternalServerError, "failed to set expiration")
+			}
+			return
+		}
+
+		var expiresAt *time.Time
+		if body.ExpiresAt != nil {
+			at := body.ExpiresAt.UTC().Truncate(time.Second)
+			expiresAt = &at
+		}
+		respondJSON(w, http.StatusOK, &expirationResponse{MediaID: mediaID, ExpiresAt: expiresAt})
+	}
+}
@@ -2,21 +2,97 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"runtime/debug"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/streaming-service/internal/auth"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/ads"
+	"github.com/streaming-service/internal/service/analytics"
+	"github.com/streaming-service/internal/service/apikeys"
+	"github.com/streaming-service/internal/service/audit"
+	"github.com/streaming-service/internal/service/captions"
+	"github.com/streaming-service/internal/service/channels"
+	"github.com/streaming-service/internal/service/collections"
+	"github.com/streaming-service/internal/service/embed"
+	"github.com/streaming-service/internal/service/enrich"
+	"github.com/streaming-service/internal/service/estimate"
+	"github.com/streaming-service/internal/service/export"
+	"github.com/streaming-service/internal/service/idempotency"
+	"github.com/streaming-service/internal/service/jobs"
+	"github.com/streaming-service/internal/service/keys"
+	"github.com/streaming-service/internal/service/live"
+	"github.com/streaming-service/internal/service/moderation"
+	"github.com/streaming-service/internal/service/notify"
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/internal/service/retention"
+	"github.com/streaming-service/internal/service/search"
 	"github.com/streaming-service/internal/service/stream"
 	"github.com/streaming-service/internal/service/upload"
+	"github.com/streaming-service/internal/service/webhooks"
 	"github.com/streaming-service/pkg/logger"
+	"github.com/streaming-service/pkg/metrics"
 )
 
 // RouterConfig contains router dependencies
 type RouterConfig struct {
-	UploadService *upload.Service
-	StreamService *stream.Service
-	Logger        *logger.Logger
+	UploadService      *upload.Service
+	StreamService      *stream.Service
+	AnalyticsService   *analytics.Service
+	AdsService         *ads.Service
+	KeysService        *keys.Service
+	LiveService        *live.Service
+	WHIPIngest         *live.WHIPIngest
+	APIKeysService     *apikeys.Service
+	SearchService      *search.Service
+	CollectionsService *collections.Service
+	ChannelsService    *channels.Service
+	// EmbedService issues embed tokens for third-party sites; nil
+	// disables them
+	EmbedService *embed.Service
+	// AuditService records mutating calls; nil disables the audit log
+	AuditService *audit.Service
+	// EstimateService forecasts transcode time and output size
+	EstimateService *estimate.Service
+	// QuotasService reports tenant quota usage; nil disables quotas
+	QuotasService *quotas.Service
+	// ModerationService serves the review queue; nil disables it
+	ModerationService *moderation.Service
+	// CaptionsService takes subtitle uploads and queues translations when
+	// enabled; nil disables both
+	CaptionsService *captions.Service
+	// EnrichService queues chapter and summary generation; nil disables it
+	EnrichService *enrich.Service
+	// NotifyService manages notification preferences; nil disables them
+	NotifyService *notify.Service
+	// RetentionService sets media expirations; nil disables them
+	RetentionService *retention.Service
+	// ExportService queues and hands out media export packages; nil
+	// disables them
+	ExportService *export.Service
+	// WebhooksService manages users' webhooks; nil disables them
+	WebhooksService *webhooks.Service
+	// JobsService serves the records of processing jobs; nil disables
+	// them
+	JobsService *jobs.Service
+	// AdminScope is the token scope required for admin endpoints
+	AdminScope string
+	// IdempotencyService replays retried POSTs; nil ignores Idempotency-Key
+	IdempotencyService *idempotency.Service
+	// BodyLimits caps request bodies
+	BodyLimits BodyLimits
+	// ReadinessChecks are probed by /ready, each bounded by ReadinessTimeout
+	ReadinessChecks  []ReadinessCheck
+	ReadinessTimeout time.Duration
+	// Metrics measures requests and serves /metrics
+	Metrics bool
+	// Verifier validates bearer JWTs; nil disables authentication
+	Verifier *auth.Verifier
+	Logger   *logger.Logger
 }
 
 // NewRouter creates a new HTTP router
@@ -25,35 +101,269 @@ func NewRouter(cfg RouterConfig) *chi.Mux {
 
 	// Middleware stack
 	r.Use(middleware.RequestID)
+	r.Use(withRequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Recoverer)
+	r.Use(middleware.GetHead)
+	r.Use(recoverPanics(cfg.Logger))
 	r.Use(middleware.Timeout(60 * time.Second))
+	if cfg.Metrics {
+		r.Use(measureRequests)
+	}
 	r.Use(requestLogger(cfg.Logger))
 	r.Use(corsMiddleware)
 
 	// Health check
 	r.Get("/health", healthHandler)
-	r.Get("/ready", readyHandler)
+	r.Get("/ready", readyHandler(cfg.ReadinessChecks, cfg.ReadinessTimeout, cfg.Logger))
+	if cfg.Metrics {
+		r.Handle("/metrics", metrics.Handler())
+	}
+
+	// API routes; each version serves the same routes with its own
+	// response shapes
+	r.Route("/api/v1", apiRoutes(cfg, apiV1))
+	r.Route("/api/v2", apiRoutes(cfg, apiV2))
+
+	return r
+}
+
+// apiRoutes registers the API routes for a version
+func apiRoutes(cfg RouterConfig, version apiVersion) func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Use(withVersion(version))
+		r.Use(limitBody(cfg.BodyLimits.Default))
+		r.Use(auditCalls(cfg.AuditService))
+		r.Use(authenticate(cfg.Verifier, cfg.Logger))
+		if cfg.APIKeysService != nil {
+			r.Use(authenticateAPIKey(cfg.APIKeysService, cfg.Logger))
+		}
+		r.Use(resolveTenant)
+		r.Use(auditActor)
+
+		// Writes and owner-scoped reads require an authenticated user, and
+		// API key callers the matching scope; playback and player
+		// telemetry stay public
+		user := requireUser(cfg.Verifier)
+		scoped := func(scope string) chi.Middlewares {
+			return chi.Chain(user, requireScope(scope))
+		}
+		// POSTs that create resources or start work honour Idempotency-Key
+		idempotentScoped := func(scope string) chi.Middlewares {
+			return append(scoped(scope), idempotent(cfg.IdempotencyService, cfg.Logger))
+		}
 
-	// API routes
-	r.Route("/api/v1", func(r chi.Router) {
 		// Upload routes
 		r.Route("/upload", func(r chi.Router) {
-			r.Post("/", uploadHandler(cfg.UploadService, cfg.Logger))
-			r.Post("/presign", presignHandler(cfg.UploadService, cfg.Logger))
-			r.Post("/{mediaID}/confirm", confirmUploadHandler(cfg.UploadService, cfg.Logger))
+			r.Use(scoped(domain.ScopeMediaWrite)...)
+			idem := idempotent(cfg.IdempotencyService, cfg.Logger)
+			// The upload cap replaces the default before the body is read
+			r.With(limitBody(cfg.BodyLimits.Upload), idem).Post("/", uploadHandler(cfg.UploadService, cfg.Logger))
+			r.With(idem).Post("/presign", presignHandler(cfg.UploadService, cfg.Logger))
+			r.With(idem).Post("/{mediaID}/confirm", confirmUploadHandler(cfg.UploadService, cfg.Logger))
+			// Multipart uploads of large sources, in parts to presigned URLs
+			r.With(idem).Post("/multipart", startMultipartHandler(cfg.UploadService, cfg.Logger))
+			r.Post("/{mediaID}/multipart/parts", partURLsHandler(cfg.UploadService, cfg.Logger))
+			r.With(idem).Post("/{mediaID}/multipart/complete", completeMultipartHandler(cfg.UploadService, cfg.Logger))
+			r.Post("/{mediaID}/multipart/abort", abortMultipartHandler(cfg.UploadService, cfg.Logger))
 		})
 
+		// Bulk media operations with per-item results
+		r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/media:batch", batchMediaHandler(cfg.StreamService, cfg.UploadService, cfg.Logger))
+
+		// Bulk metadata export and import
+		r.With(scoped(domain.ScopeMediaRead)...).Get("/media:metadata", exportMetadataHandler(cfg.StreamService, cfg.Logger))
+		r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/media:metadata", importMetadataHandler(cfg.StreamService, cfg.Logger))
+
 		// Media routes
 		r.Route("/media", func(r chi.Router) {
-			r.Get("/", listMediaHandler(cfg.StreamService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaRead)...).Get("/", listMediaHandler(cfg.StreamService, cfg.Logger))
+			r.Get("/trending", trendingHandler(cfg.AnalyticsService, cfg.Logger))
 			r.Get("/{mediaID}", getMediaHandler(cfg.StreamService, cfg.Logger))
-			r.Delete("/{mediaID}", deleteMediaHandler(cfg.StreamService, cfg.Logger))
-			r.Get("/{mediaID}/playback", playbackHandler(cfg.StreamService, cfg.Logger))
+			r.Get("/{mediaID}/progress", mediaProgressHandler(cfg.StreamService, cfg.Logger))
+			r.Get("/{mediaID}/related", relatedMediaHandler(cfg.StreamService, cfg.Logger))
+			r.With(user).Get("/{mediaID}/like", likeHandler(cfg.StreamService, cfg.Logger))
+			r.With(user).Put("/{mediaID}/like", likeHandler(cfg.StreamService, cfg.Logger))
+			r.With(user).Delete("/{mediaID}/like", likeHandler(cfg.StreamService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Delete("/{mediaID}", deleteMediaHandler(cfg.StreamService, cfg.Logger))
+			r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/{mediaID}/retry", retryMediaHandler(cfg.UploadService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Put("/{mediaID}/visibility", setVisibilityHandler(cfg.StreamService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Post("/{mediaID}/tags", addTagsHandler(cfg.StreamService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Delete("/{mediaID}/tags/{key}", removeTagHandler(cfg.StreamService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaRead)...).Get("/{mediaID}/versions", listVersionsHandler(cfg.StreamService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Put("/{mediaID}/versions/active", activateVersionHandler(cfg.StreamService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Post("/{mediaID}/versions/rollback", rollbackVersionHandler(cfg.StreamService, cfg.Logger))
+			r.Get("/{mediaID}/playback", playbackHandler(cfg.StreamService, cfg.KeysService, cfg.EmbedService, cfg.Logger))
+			if cfg.EmbedService != nil {
+				r.With(scoped(domain.ScopeMediaWrite)...).Post("/{mediaID}/embed-tokens", createEmbedTokenHandler(cfg.EmbedService, cfg.Logger))
+			}
+			r.With(skipAudit).Post("/{mediaID}/views", recordViewHandler(cfg.AnalyticsService, cfg.Logger))
+			r.With(skipAudit).Post("/{mediaID}/events", recordEventHandler(cfg.AnalyticsService, cfg.Logger))
+			r.With(scoped(domain.ScopeAnalyticsRead)...).Get("/{mediaID}/analytics", mediaAnalyticsHandler(cfg.AnalyticsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Put("/{mediaID}/ad-breaks", setAdBreaksHandler(cfg.AdsService, cfg.Logger))
+			if cfg.CaptionsService != nil {
+				r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/{mediaID}/subtitles", uploadSubtitlesHandler(cfg.CaptionsService, cfg.Logger))
+				if cfg.CaptionsService.TranslationsEnabled() {
+					r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/{mediaID}/subtitles/{trackID}/translations", translateSubtitlesHandler(cfg.CaptionsService, cfg.Logger))
+				}
+			}
+			if cfg.EnrichService != nil {
+				r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/{mediaID}/enrichment", enrichMediaHandler(cfg.EnrichService, cfg.Logger))
+			}
+			if cfg.RetentionService != nil {
+				r.With(scoped(domain.ScopeMediaWrite)...).Put("/{mediaID}/expiration", setExpirationHandler(cfg.RetentionService, cfg.Logger))
+			}
+			if cfg.ExportService != nil {
+				r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/{mediaID}/export", exportMediaHandler(cfg.ExportService, cfg.Logger))
+				r.With(scoped(domain.ScopeMediaRead)...).Get("/{mediaID}/export", getExportHandler(cfg.ExportService, cfg.Logger))
+			}
+			if cfg.JobsService != nil {
+				r.With(scoped(domain.ScopeMediaRead)...).Get("/{mediaID}/jobs", listMediaJobsHandler(cfg.JobsService, cfg.Logger))
+			}
 		})
-	})
 
-	return r
+		// Processing job status
+		if cfg.JobsService != nil {
+			r.With(scoped(domain.ScopeMediaRead)...).Get("/jobs/{jobID}", getJobHandler(cfg.JobsService, cfg.Logger))
+		}
+
+		// Collection routes
+		r.Route("/collections", func(r chi.Router) {
+			r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/", createCollectionHandler(cfg.CollectionsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaRead)...).Get("/", listCollectionsHandler(cfg.CollectionsService, cfg.Logger))
+			r.Get("/{collectionID}", getCollectionHandler(cfg.CollectionsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Put("/{collectionID}", updateCollectionHandler(cfg.CollectionsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Delete("/{collectionID}", deleteCollectionHandler(cfg.CollectionsService, cfg.Logger))
+			r.Get("/{collectionID}/playback", collectionPlaybackHandler(cfg.CollectionsService, cfg.KeysService, cfg.Logger))
+		})
+
+		// Channel routes; channel pages are public
+		r.Route("/channels", func(r chi.Router) {
+			r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/", createChannelHandler(cfg.ChannelsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaRead)...).Get("/", listChannelsHandler(cfg.ChannelsService, cfg.Logger))
+			r.Get("/{channelID}", getChannelHandler(cfg.ChannelsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Put("/{channelID}", updateChannelHandler(cfg.ChannelsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Delete("/{channelID}", deleteChannelHandler(cfg.ChannelsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).With(limitBody(cfg.BodyLimits.Artwork)).Put("/{channelID}/artwork", setChannelArtworkHandler(cfg.ChannelsService, cfg.Logger))
+			r.Get("/{channelID}/media", channelMediaHandler(cfg.ChannelsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Put("/{channelID}/media/{mediaID}", publishMediaHandler(cfg.ChannelsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Delete("/{channelID}/media/{mediaID}", unpublishMediaHandler(cfg.ChannelsService, cfg.Logger))
+			r.Get("/{channelID}/episodes", channelEpisodesHandler(cfg.ChannelsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Put("/{channelID}/episodes/{mediaID}", publishEpisodeHandler(cfg.ChannelsService, cfg.Logger))
+			r.With(scoped(domain.ScopeMediaWrite)...).Delete("/{channelID}/episodes/{mediaID}", unpublishEpisodeHandler(cfg.ChannelsService, cfg.Logger))
+			r.Get("/{channelID}/feed.xml", channelFeedHandler(cfg.ChannelsService, cfg.Logger))
+		})
+		r.Get("/users/{userID}/channels", userChannelsHandler(cfg.ChannelsService, cfg.Logger))
+
+		// GraphQL over the catalog for dashboards
+		schema := catalogSchema(cfg.StreamService, cfg.CollectionsService, cfg.AnalyticsService, cfg.Verifier != nil, cfg.Logger)
+		r.Get("/graphql", graphqlHandler(schema, cfg.Logger))
+		r.With(skipAudit).Post("/graphql", graphqlHandler(schema, cfg.Logger))
+
+		// The media the user liked
+		r.With(user).Get("/favorites", listFavoritesHandler(cfg.StreamService, cfg.Logger))
+
+		// The user's notification preferences
+		if cfg.NotifyService != nil {
+			r.With(user).Get("/preferences/notifications", getNotificationPreferencesHandler(cfg.NotifyService, cfg.Logger))
+			r.With(user).Put("/preferences/notifications", updateNotificationPreferencesHandler(cfg.NotifyService, cfg.Logger))
+		}
+
+		// Webhooks receiving the events of the user's media
+		if cfg.WebhooksService != nil {
+			r.Route("/webhooks", func(r chi.Router) {
+				r.With(idempotentScoped(domain.ScopeMediaWrite)...).Post("/", createWebhookHandler(cfg.WebhooksService, cfg.Logger))
+				r.With(scoped(domain.ScopeMediaRead)...).Get("/", listWebhooksHandler(cfg.WebhooksService, cfg.Logger))
+				r.With(scoped(domain.ScopeMediaRead)...).Get("/{webhookID}", getWebhookHandler(cfg.WebhooksService, cfg.Logger))
+				r.With(scoped(domain.ScopeMediaWrite)...).Put("/{webhookID}", updateWebhookHandler(cfg.WebhooksService, cfg.Logger))
+				r.With(scoped(domain.ScopeMediaWrite)...).Delete("/{webhookID}", deleteWebhookHandler(cfg.WebhooksService, cfg.Logger))
+				r.With(scoped(domain.ScopeMediaRead)...).Get("/{webhookID}/deliveries", listWebhookDeliveriesHandler(cfg.WebhooksService, cfg.Logger))
+			})
+		}
+
+		// Tag-based catalog browsing
+		r.Get("/tags", popularTagsHandler(cfg.StreamService, cfg.Logger))
+		r.Get("/tags/{tag}/media", listMediaByTagHandler(cfg.StreamService, cfg.Logger))
+
+		// Full-text search over the public catalog
+		if cfg.SearchService != nil {
+			r.Get("/search", searchHandler(cfg.SearchService, cfg.Logger))
+			r.Get("/search/transcripts", searchTranscriptsHandler(cfg.SearchService, cfg.Logger))
+		}
+
+		// Pre-flight cost of processing a source with a rendition ladder
+		if cfg.EstimateService != nil {
+			r.With(append(scoped(domain.ScopeMediaRead), skipAudit)...).Post("/estimate", estimateHandler(cfg.EstimateService, cfg.Logger))
+		}
+
+		// Content key delivery for AES-128 HLS
+		if cfg.KeysService != nil {
+			r.Get("/keys/{mediaID}/{keyID}", keyHandler(cfg.KeysService, cfg.Logger))
+		}
+
+		// API key management for server-to-server callers
+		if cfg.APIKeysService != nil {
+			r.Route("/api-keys", func(r chi.Router) {
+				r.Use(user, requireUserToken)
+				r.Post("/", createAPIKeyHandler(cfg.APIKeysService, cfg.Logger))
+				r.Get("/", listAPIKeysHandler(cfg.APIKeysService, cfg.Logger))
+				r.Delete("/{keyID}", revokeAPIKeyHandler(cfg.APIKeysService, cfg.Logger))
+			})
+		}
+
+		// Audit history for compliance review
+		if cfg.AuditService != nil {
+			r.With(user, requireAdmin(cfg.Verifier, cfg.AdminScope)).Get("/audit", listAuditHandler(cfg.AuditService, cfg.Logger))
+		}
+
+		// Usage against the caller's tenant quotas
+		if cfg.QuotasService != nil {
+			r.With(scoped(domain.ScopeMediaRead)...).Get("/quota", quotaHandler(cfg.QuotasService, cfg.Logger))
+		}
+
+		// Runtime diagnostics and content review for operators
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(user, requireAdmin(cfg.Verifier, cfg.AdminScope))
+			r.Get("/log-level", getLogLevelHandler(cfg.Logger))
+			r.Put("/log-level", setLogLevelHandler(cfg.Logger))
+			if cfg.ModerationService != nil {
+				r.Get("/moderation", moderationQueueHandler(cfg.ModerationService, cfg.Logger))
+				r.Put("/media/{mediaID}/moderation", reviewMediaHandler(cfg.ModerationService, cfg.Logger))
+			}
+			r.Get("/media/{mediaID}/legal-hold", getLegalHoldHandler(cfg.StreamService, cfg.Logger))
+			r.Put("/media/{mediaID}/legal-hold", applyLegalHoldHandler(cfg.StreamService, cfg.Logger))
+			r.Delete("/media/{mediaID}/legal-hold", releaseLegalHoldHandler(cfg.StreamService, cfg.Logger))
+		})
+
+		// Live streaming routes
+		if cfg.LiveService != nil {
+			r.Route("/live", func(r chi.Router) {
+				r.With(idempotentScoped(domain.ScopeLiveWrite)...).Post("/streams", createLiveStreamHandler(cfg.LiveService, cfg.Logger))
+				r.With(scoped(domain.ScopeLiveRead)...).Get("/streams", listLiveStreamsHandler(cfg.LiveService, cfg.Logger))
+				r.With(scoped(domain.ScopeLiveRead)...).Get("/streams/{streamID}", getLiveStreamHandler(cfg.LiveService, cfg.Logger))
+				r.With(scoped(domain.ScopeLiveRead)...).Get("/streams/{streamID}/health", liveHealthHandler(cfg.LiveService, cfg.Logger))
+				r.Get("/streams/{streamID}/playback", livePlaybackHandler(cfg.StreamService, cfg.Logger))
+				r.Get("/streams/{streamID}/preview", livePreviewHandler(cfg.StreamService, cfg.Logger))
+
+				// Pushes to external RTMP destinations while the stream is live
+				r.With(scoped(domain.ScopeLiveRead)...).Get("/streams/{streamID}/destinations", listDestinationsHandler(cfg.LiveService, cfg.Logger))
+				r.With(idempotentScoped(domain.ScopeLiveWrite)...).Post("/streams/{streamID}/destinations", addDestinationHandler(cfg.LiveService, cfg.Logger))
+				r.With(scoped(domain.ScopeLiveWrite)...).Put("/streams/{streamID}/destinations/{destinationID}/enabled", enableDestinationHandler(cfg.LiveService, true, cfg.Logger))
+				r.With(scoped(domain.ScopeLiveWrite)...).Delete("/streams/{streamID}/destinations/{destinationID}/enabled", enableDestinationHandler(cfg.LiveService, false, cfg.Logger))
+				r.With(scoped(domain.ScopeLiveWrite)...).Delete("/streams/{streamID}/destinations/{destinationID}", removeDestinationHandler(cfg.LiveService, cfg.Logger))
+
+				// LL-HLS is served by the instance hosting the ingest session
+				r.Get("/streams/{streamID}/ll/master.m3u8", llMasterHandler(cfg.LiveService, cfg.Logger))
+				r.Get("/streams/{streamID}/ll/{rendition}/playlist.m3u8", llPlaylistHandler(cfg.LiveService, cfg.Logger))
+				r.Get("/streams/{streamID}/ll/{rendition}/{file}", llFileHandler(cfg.LiveService, cfg.Logger))
+
+				// WebRTC ingest (WHIP), authenticated by stream key
+				if cfg.WHIPIngest != nil {
+					r.Post("/whip", whipPublishHandler(cfg.WHIPIngest, cfg.Logger))
+					r.Delete("/whip/{sessionID}", whipStopHandler(cfg.WHIPIngest, cfg.Logger))
+				}
+			})
+		}
+	}
 }
 
 // JSON response helpers
@@ -65,10 +375,6 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, map[string]string{"error": message})
-}
-
 // Health check handlers
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{
@@ -77,19 +383,13 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func readyHandler(w http.ResponseWriter, r *http.Request) {
-	// TODO: Check dependencies (DB, S3, Redis)
-	respondJSON(w, http.StatusOK, map[string]string{
-		"status": "ready",
-	})
-}
-
 // CORS middleware
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Tenant-ID, Idempotency-Key, If-Modified-Since")
+		w.Header().Set("Access-Control-Expose-Headers", "Location, API-Version, Idempotent-Replayed, X-Request-ID, Last-Modified")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusNoContent)
@@ -100,6 +400,35 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// recoverPanics turns a panicking handler into a 500, logging the panic
+// with its stack so it's reported like any other error
+func recoverPanics(log *logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				log.Error("panic handling request",
+					"panic", fmt.Sprint(rec),
+					"stack", string(debug.Stack()),
+					"request_id", middleware.GetReqID(r.Context()),
+					"method", r.Method,
+					"path", r.URL.Path,
+				)
+				respondError(w, http.StatusInternalServerError, "internal server error")
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Request logger middleware
 func requestLogger(log *logger.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -110,6 +439,7 @@ func requestLogger(log *logger.Logger) func(next http.Handler) http.Handler {
 			next.ServeHTTP(ww, r)
 
 			log.Info("request",
+				"request_id", middleware.GetReqID(r.Context()),
 				"method", r.Method,
 				"path", r.URL.Path,
 				"status", ww.Status(),
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/streaming-service/internal/service/search"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// searchHandler runs a full-text query over the public catalog
+func searchHandler(svc *search.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, limit, offset, ok := parseSearch(w, r)
+		if !ok {
+			return
+		}
+
+		results, err := svc.Search(r.Context(), query, limit, offset)
+		if err != nil {
+			log.Error("failed to search media", "error", err)
+			respondError(w, http.StatusBadGateway, "search is unavailable")
+			return
+		}
+
+		respondPage(w, &page{
+			Items: results.Items,
+			Count: len(results.Items),
+			Meta: map[string]interface{}{
+				"query":  query,
+				"total":  results.Total,
+				"limit":  results.Limit,
+				"offset": results.Offset,
+			},
+		})
+	}
+}
+
+// searchTranscriptsHandler finds the public media whose transcripts
+// contain a phrase, and where in them it is spoken
+func searchTranscriptsHandler(svc *search.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, limit, offset, ok := parseSearch(w, r)
+		if !ok {
+			return
+		}
+
+		results, err := svc.SearchTranscripts(r.Context(), query, limit, offset)
+		if err != nil {
+			log.Error("failed to search transcripts", "error", err)
+			respondError(w, http.StatusBadGateway, "search is unavailable")
+			return
+		}
+
+		respondPage(w, &page{
+			Items: results.Items,
+			Count: len(results.Items),
+			Meta: map[string]interface{}{
+				"query":  query,
+				"total":  results.Total,
+				"limit":  results.Limit,
+				"offset": results.Offset,
+			},
+		})
+	}
+}
+
+// parseSearch reads the q, limit and offset parameters of a search,
+// responding with an error if they're invalid
+func parseSearch(w http.ResponseWriter, r *http.Request) (query string, limit, offset int, ok bool) {
+	query = strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		respondError(w, http.StatusBadRequest, "q is required")
+		return "", 0, 0, false
+	}
+
+	limit = search.DefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > search.MaxLimit {
+			respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return "", 0, 0, false
+		}
+		limit = n
+	}
+
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			respondError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return "", 0, 0, false
+		}
+		offset = n
+	}
+
+	return query, limit, offset, true
+}
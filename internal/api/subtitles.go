@@ -0,0 +1,152 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/captions"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// maxSubtitleNameLength bounds the names of uploaded subtitle tracks
+const maxSubtitleNameLength = 100
+
+// Upload subtitles form fields
+type uploadSubtitlesRequest struct {
+	ID       string
+	Language string
+	Name     string
+	Default  string
+}
+
+func (req *uploadSubtitlesRequest) Validate(v *validate.Validator) {
+	v.Required("language", req.Language)
+	if req.Language != "" {
+		v.Check(captions.IsValidLanguage(req.Language), "language", "must be a BCP 47 language tag")
+	}
+	if req.ID != "" {
+		v.Check(domain.IsValidSubtitleID(req.ID), "id", "must be at most 64 letters, digits, hyphens and underscores")
+	}
+	v.MaxLength("name", req.Name, maxSubtitleNameLength)
+	if req.Default != "" {
+		_, err := strconv.ParseBool(req.Default)
+		v.Check(err == nil, "default", "must be true or false")
+	}
+}
+
+// uploadSubtitlesHandler adds an SRT or WebVTT file as a subtitle track of
+// the user's media
+func uploadSubtitlesHandler(svc *captions.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(maxFormMemory); err != nil {
+			if !respondTooLarge(w, err) {
+				respondError(w, http.StatusBadRequest, "failed to parse form")
+			}
+			return
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "file is required")
+			return
+		}
+		defer file.Close()
+
+		form := uploadSubtitlesRequest{
+			ID:       r.FormValue("id"),
+			Language: r.FormValue("language"),
+			Name:     r.FormValue("name"),
+			Default:  r.FormValue("default"),
+		}
+		if errs := validate.Struct(&form); errs != nil {
+			respondProblem(w, http.StatusBadRequest, "request failed validation", errs)
+			return
+		}
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "failed to read file")
+			return
+		}
+
+		isDefault, _ := strconv.ParseBool(form.Default)
+		track, err := svc.UploadTrack(r.Context(), chi.URLParam(r, "mediaID"), getUserID(r), &captions.TrackUpload{
+			ID:       form.ID,
+			Language: form.Language,
+			Name:     form.Name,
+			Default:  isDefault,
+			Data:     data,
+		})
+		if err != nil {
+			switch err {
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest, "file must be SRT or WebVTT with at least one cue")
+			default:
+				log.Error("failed to upload subtitles", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to upload subtitles")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, track)
+	}
+}
+
+// Translate subtitles request body
+type translateSubtitlesRequest struct {
+	Languages []string `json:"languages"`
+}
+
+func (req *translateSubtitlesRequest) Validate(v *validate.Validator) {
+	v.Items("languages", len(req.Languages), 1, captions.MaxTranslationLanguages)
+	for i, l := range req.Languages {
+		v.Required(fmt.Sprintf("languages[%d]", i), l)
+	}
+}
+
+// translateSubtitlesHandler queues a job translating a subtitle track of
+// the user's media into more languages
+func translateSubtitlesHandler(svc *captions.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body translateSubtitlesRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		jobID, err := svc.RequestTranslation(r.Context(), chi.URLParam(r, "mediaID"), getUserID(r),
+			chi.URLParam(r, "trackID"), body.Languages)
+		if err != nil {
+			switch err {
+			case domain.ErrMediaNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "media not found")
+			case domain.ErrSubtitleNotFound:
+				respondDomainError(w, err, http.StatusNotFound, "subtitle track not found")
+			case domain.ErrUnauthorized:
+				respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+			case domain.ErrInvalidInput:
+				respondDomainError(w, err, http.StatusBadRequest,
+					"languages must be distinct language tags other than the track's, not replacing other tracks")
+			case domain.ErrQueueUnavailable:
+				respondDomainError(w, err, http.StatusServiceUnavailable, "job queue unavailable")
+			default:
+				log.Error("failed to queue subtitle translation", "error", err)
+				respondError(w, http.StatusInternalServerError, "failed to queue subtitle translation")
+			}
+			return
+		}
+
+		respondJSON(w, http.StatusAccepted, map[string]interface{}{
+			"job_id":    jobID,
+			"languages": body.Languages,
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Add tags request body
+type addTagsRequest struct {
+	Tags map[string]string `json:"tags"`
+}
+
+func (req *addTagsRequest) Validate(v *validate.Validator) {
+	validateTags(v, req.Tags, 1)
+}
+
+// validateTags checks there are at least min tags and no more than a
+// media item may carry, each of them valid
+func validateTags(v *validate.Validator, tags map[string]string, min int) {
+	v.Items("tags", len(tags), min, domain.MaxTagsPerMedia)
+	for key, value := range tags {
+		v.Check(domain.IsValidTag(key, value), "tags."+key, fmt.Sprintf("key must be 1 to %d bytes without ':' and value at most %d bytes", domain.MaxTagKeyLength, domain.MaxTagValueLength))
+	}
+}
+
+// addTagsHandler merges tags into a media item
+func addTagsHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		if mediaID == "" {
+			respondError(w, http.StatusBadRequest, "media ID is required")
+			return
+		}
+
+		var body addTagsRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		tags, err := svc.AddTags(r.Context(), mediaID, getUserID(r), body.Tags)
+		if err != nil {
+			respondTagError(w, log, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"media_id": mediaID,
+			"tags":     tags,
+		})
+	}
+}
+
+// removeTagHandler removes a tag by key from a media item
+func removeTagHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+		key := chi.URLParam(r, "key")
+		if mediaID == "" || key == "" {
+			respondError(w, http.StatusBadRequest, "media ID and tag key are required")
+			return
+		}
+
+		tags, err := svc.RemoveTag(r.Context(), mediaID, getUserID(r), key)
+		if err != nil {
+			respondTagError(w, log, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"media_id": mediaID,
+			"tags":     tags,
+		})
+	}
+}
+
+// listMediaByTagHandler lists media carrying a tag key or "key:value"
+func listMediaByTagHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag := chi.URLParam(r, "tag")
+		if tag == "" {
+			respondError(w, http.StatusBadRequest, "tag is required")
+			return
+		}
+
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		media, next, err := svc.ListMediaByTag(r.Context(), tag, getUserID(r), int32(limit), r.URL.Query().Get("cursor"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid cursor")
+				return
+			}
+			log.Error("failed to list media by tag", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to list media")
+			return
+		}
+
+		respondPage(w, &page{
+			Items:      media,
+			Count:      len(media),
+			NextCursor: next,
+			Meta: map[string]interface{}{
+				"tag": tag,
+			},
+		})
+	}
+}
+
+// popularTagsHandler lists the tags on the most media, or with a key,
+// that key's most used values
+func popularTagsHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if strings.Contains(key, ":") {
+			respondError(w, http.StatusBadRequest, "key must not contain ':'")
+			return
+		}
+
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		tags, err := svc.PopularTags(r.Context(), key, limit)
+		if err != nil {
+			log.Error("failed to list popular tags", "error", err)
+			respondError(w, http.StatusInternalServerError, "failed to list tags")
+			return
+		}
+
+		meta := map[string]interface{}{}
+		if key != "" {
+			meta["key"] = key
+		}
+		respondPage(w, &page{
+			Items: tags,
+			Count: len(tags),
+			Meta:  meta,
+		})
+	}
+}
+
+// respondTagError maps tag update errors to responses
+func respondTagError(w http.ResponseWriter, log *logger.Logger, err error) {
+	switch err {
+	case domain.ErrInvalidInput:
+		respondDomainError(w, err, http.StatusBadRequest, fmt.Sprintf(
+			"tags need a key without ':' of up to %d bytes, values of up to %d bytes, and at most %d per media",
+			domain.MaxTagKeyLength, domain.MaxTagValueLength, domain.MaxTagsPerMedia))
+	case domain.ErrMediaNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "media not found")
+	case domain.ErrUnauthorized:
+		respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+	default:
+		log.Error("failed to update tags", "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to update tags")
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// apiVersion is a major version of the HTTP API. Versions share routes,
+// handlers and services and differ only in response shapes, so /api/v1
+// stays stable while /api/v2 evolves.
+type apiVersion int
+
+// Served API versions
+const (
+	apiV1 apiVersion = 1
+	apiV2 apiVersion = 2
+)
+
+// versionHeader reports the API version a response was shaped for
+const versionHeader = "API-Version"
+
+// withVersion marks responses with the API version being served, which
+// the response helpers read to pick their shapes
+func withVersion(v apiVersion) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(versionHeader, strconv.Itoa(int(v)))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// responseVersion returns the API version of a response, v1 for routes
+// outside the versioned API
+func responseVersion(w http.ResponseWriter) apiVersion {
+	if v, err := strconv.Atoi(w.Header().Get(versionHeader)); err == nil {
+		return apiVersion(v)
+	}
+	return apiV1
+}
+
+// page is one page of a listing
+type page struct {
+	Items interface{}
+	Count int
+	// NextCursor is empty on the last page
+	NextCursor string
+	// Meta holds listing-specific fields such as the search query
+	Meta map[string]interface{}
+}
+
+// respondPage writes a listing. v1 returns items, count and next_cursor
+// alongside the listing's own fields; v2 wraps them in an envelope:
+//
+//	{"data": [...], "pagination": {"count": 20, "next_cursor": "...", "has_more": true}, "meta": {...}}
+func respondPage(w http.ResponseWriter, p *page) {
+	if responseVersion(w) >= apiV2 {
+		pagination := map[string]interface{}{
+			"count":    p.Count,
+			"has_more": p.NextCursor != "",
+		}
+		if p.NextCursor != "" {
+			pagination["next_cursor"] = p.NextCursor
+		}
+
+		resp := map[string]interface{}{
+			"data":       p.Items,
+			"pagination": pagination,
+		}
+		if len(p.Meta) > 0 {
+			resp["meta"] = p.Meta
+		}
+
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	resp := make(map[string]interface{}, len(p.Meta)+3)
+	for k, v := range p.Meta {
+		resp[k] = v
+	}
+	resp["items"] = p.Items
+	resp["count"] = p.Count
+	if p.NextCursor != "" {
+		resp["next_cursor"] = p.NextCursor
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
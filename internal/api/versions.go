@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Activate version request body
+type activateVersionRequest struct {
+	Version int `json:"version"`
+}
+
+func (req *activateVersionRequest) Validate(v *validate.Validator) {
+	v.Min("version", float64(req.Version), 1)
+}
+
+// listVersionsHandler lists the output versions of the user's media
+func listVersionsHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		versions, err := svc.ListVersions(r.Context(), mediaID, getUserID(r))
+		if err != nil {
+			respondVersionError(w, log, err, "failed to list versions")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, versions)
+	}
+}
+
+// activateVersionHandler switches the output version of the user's media
+// that plays
+func activateVersionHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		var body activateVersionRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		versions, err := svc.ActivateVersion(r.Context(), mediaID, getUserID(r), body.Version)
+		if err != nil {
+			respondVersionError(w, log, err, "failed to activate version")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, versions)
+	}
+}
+
+// rollbackVersionHandler switches the user's media back to the output
+// version before the active one
+func rollbackVersionHandler(svc *stream.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaID := chi.URLParam(r, "mediaID")
+
+		versions, err := svc.RollbackVersion(r.Context(), mediaID, getUserID(r))
+		if err != nil {
+			respondVersionError(w, log, err, "failed to roll back version")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, versions)
+	}
+}
+
+// respondVersionError maps output version errors to responses
+func respondVersionError(w http.ResponseWriter, log *logger.Logger, err error, msg string) {
+	switch err {
+	case domain.ErrMediaNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "media not found")
+	case domain.ErrVersionNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "version not found")
+	case domain.ErrUnauthorized:
+		respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+	case domain.ErrMediaBusy:
+		respondDomainError(w, err, http.StatusConflict, "media is being processed")
+	case domain.ErrVersionConflict:
+		respondDomainError(w, err, http.StatusConflict, "active version changed, retry")
+	default:
+		log.Error(msg, "error", err)
+		respondError(w, http.StatusInternalServerError, msg)
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/webhooks"
+	"github.com/streaming-service/internal/validate"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Create webhook request body
+type createWebhookRequest struct {
+	URL         string             `json:"url"`
+	Description string             `json:"description"`
+	Events      []domain.EventType `json:"events"`
+}
+
+func (req *createWebhookRequest) Validate(v *validate.Validator) {
+	v.Required("url", req.URL)
+	if req.URL != "" {
+		v.Check(webhooks.ValidURL(req.URL), "url", "must be an http or https URL")
+	}
+	v.MaxLength("description", req.Description, domain.MaxTitleLength)
+	validateWebhookEvents(v, req.Events)
+}
+
+// Update webhook request body; omitted fields are left unchanged
+type updateWebhookRequest struct {
+	URL         *string            `json:"url"`
+	Description *string            `json:"description"`
+	Events      []domain.EventType `json:"events"`
+	Enabled     *bool              `json:"enabled"`
+}
+
+func (req *updateWebhookRequest) Validate(v *validate.Validator) {
+	if req.URL != nil {
+		v.Check(webhooks.ValidURL(*req.URL), "url", "must be an http or https URL")
+	}
+	if req.Description != nil {
+		v.MaxLength("description", *req.Description, domain.MaxTitleLength)
+	}
+	if req.Events != nil {
+		validateWebhookEvents(v, req.Events)
+	}
+}
+
+// validateWebhookEvents checks a webhook subscribes to known event types
+func validateWebhookEvents(v *validate.Validator, events []domain.EventType) {
+	v.Items("events", len(events), 1, len(domain.WebhookEvents))
+	allowed := make([]string, 0, len(domain.WebhookEvents))
+	for _, e := range domain.WebhookEvents {
+		allowed = append(allowed, string(e))
+	}
+	for i, e := range events {
+		v.OneOf(fmt.Sprintf("events[%d]", i), string(e), allowed...)
+		v.Check(e != "", fmt.Sprintf("events[%d]", i), "is required")
+	}
+}
+
+// respondWebhookError maps the errors of webhook calls
+func respondWebhookError(w http.ResponseWriter, log *logger.Logger, err error, action string) {
+	switch err {
+	case domain.ErrWebhookNotFound:
+		respondDomainError(w, err, http.StatusNotFound, "webhook not found")
+	case domain.ErrUnauthorized:
+		respondDomainError(w, err, http.StatusForbidden, "unauthorized")
+	case domain.ErrInvalidInput:
+		respondDomainError(w, err, http.StatusBadRequest, "invalid input")
+	default:
+		log.Error("failed to "+action, "error", err)
+		respondError(w, http.StatusInternalServerError, "failed to "+action)
+	}
+}
+
+// createWebhookHandler subscribes a URL to the events of the caller's media
+func createWebhookHandler(svc *webhooks.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body createWebhookRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		webhook, err := svc.CreateWebhook(r.Context(), &webhooks.CreateWebhookRequest{
+			UserID:      getUserID(r),
+			URL:         body.URL,
+			Description: body.Description,
+			Events:      body.Events,
+		})
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest,
+					fmt.Sprintf("a user may have up to %d webhooks", webhooks.MaxWebhooks))
+				return
+			}
+			respondWebhookError(w, log, err, "create webhook")
+			return
+		}
+
+		// The secret is only ever returned at creation
+		respondJSON(w, http.StatusCreated, map[string]interface{}{
+			"webhook": webhook,
+			"secret":  webhook.Secret,
+		})
+	}
+}
+
+// listWebhooksHandler lists the caller's webhooks
+func listWebhooksHandler(svc *webhooks.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := svc.ListWebhooks(r.Context(), getUserID(r))
+		if err != nil {
+			respondWebhookError(w, log, err, "list webhooks")
+			return
+		}
+
+		respondPage(w, &page{Items: list, Count: len(list)})
+	}
+}
+
+// getWebhookHandler returns one of the caller's webhooks
+func getWebhookHandler(svc *webhooks.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhook, err := svc.GetWebhook(r.Context(), chi.URLParam(r, "webhookID"), getUserID(r))
+		if err != nil {
+			respondWebhookError(w, log, err, "get webhook")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, webhook)
+	}
+}
+
+// updateWebhookHandler changes one of the caller's webhooks
+func updateWebhookHandler(svc *webhooks.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body updateWebhookRequest
+		if !decodeBody(w, r, &body) {
+			return
+		}
+
+		webhook, err := svc.UpdateWebhook(r.Context(), chi.URLParam(r, "webhookID"), getUserID(r), &webhooks.UpdateWebhookRequest{
+			URL:         body.URL,
+			Description: body.Description,
+			Events:      body.Events,
+			Enabled:     body.Enabled,
+		})
+		if err != nil {
+			respondWebhookError(w, log, err, "update webhook")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, webhook)
+	}
+}
+
+// deleteWebhookHandler deletes one of the caller's webhooks
+func deleteWebhookHandler(svc *webhooks.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.DeleteWebhook(r.Context(), chi.URLParam(r, "webhookID"), getUserID(r)); err != nil {
+			respondWebhookError(w, log, err, "delete webhook")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listWebhookDeliveriesHandler pages through a webhook's delivery log
+func listWebhookDeliveriesHandler(svc *webhooks.Service, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		limit := 20
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				respondError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+			limit = n
+		}
+
+		deliveries, next, err := svc.ListDeliveries(r.Context(), chi.URLParam(r, "webhookID"), getUserID(r), int32(limit), q.Get("cursor"))
+		if err != nil {
+			if err == domain.ErrInvalidInput {
+				respondDomainError(w, err, http.StatusBadRequest, "invalid cursor")
+				return
+			}
+			respondWebhookError(w, log, err, "list webhook deliveries")
+			return
+		}
+
+		respondPage(w, &page{Items: deliveries, Count: len(deliveries), NextCursor: next})
+	}
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Authentication errors
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrInvalidTenant = errors.New("invalid tenant")
+)
+
+// TenantHeader names the tenant of anonymous callers, such as players
+// fetching a tenant's public media. Authenticated callers always act for
+// the tenant in their credentials.
+const TenantHeader = "X-Tenant-ID"
+
+// Token providers
+const (
+	ProviderOIDC    = "oidc"
+	ProviderCognito = "cognito"
+	ProviderStatic  = "static"
+)
+
+// signingMethods are the asymmetric algorithms accepted from the issuer
+var signingMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// hmacSigningMethods are the algorithms accepted from the static provider
+var hmacSigningMethods = []string{"HS256", "HS384", "HS512"}
+
+// minSecretLength is the shortest static provider secret accepted, the
+// size of an HS256 key
+const minSecretLength = 32
+
+// Claims are the authenticated caller's identity
+type Claims struct {
+	UserID    string
+	Email     string
+	Scopes    []string
+	Issuer    string
+	ExpiresAt time.Time
+	// APIKeyID is set when the caller authenticated with an API key
+	APIKeyID string
+	// TenantID is the tenant the caller belongs to; empty is the default
+	// tenant
+	TenantID string
+}
+
+// HasScope reports whether the caller was granted scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// keyFunc returns the key verifying a token signed with key ID kid
+type keyFunc func(ctx context.Context, kid string) (interface{}, error)
+
+// Verifier validates bearer JWTs from the configured provider
+type Verifier struct {
+	userClaim   string
+	tenantClaim string
+	// clientID, when set, must be the token's aud or client_id claim
+	clientID string
+	keys     keyFunc
+	parser   *jwt.Parser
+	log      *logger.Logger
+}
+
+// NewVerifier creates a verifier for the configured provider. OIDC issuers
+// without a configured JWKS URL have it discovered from their OpenID
+// configuration; Cognito user pools publish theirs at a known URL; the
+// static provider checks HMAC signatures made with a shared secret.
+func NewVerifier(ctx context.Context, cfg config.AuthConfig, log *logger.Logger) (*Verifier, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	methods := signingMethods
+	issuer, audience := cfg.Issuer, cfg.Audience
+	var keys keyFunc
+	var clientID string
+	switch cfg.Provider {
+	case "", ProviderOIDC:
+		if cfg.Issuer == "" && cfg.JWKSURL == "" {
+			return nil, fmt.Errorf("auth requires an issuer or a JWKS URL")
+		}
+		jwksURL := cfg.JWKSURL
+		if jwksURL == "" {
+			discovered, err := discoverJWKSURL(ctx, client, cfg.Issuer)
+			if err != nil {
+				return nil, err
+			}
+			jwksURL = discovered
+		}
+		keys = newKeySet(client, jwksURL, cfg.JWKSRefreshInterval, log).get
+		log.Info("jwt authentication enabled", "provider", ProviderOIDC, "issuer", cfg.Issuer, "jwks_url", jwksURL)
+
+	case ProviderCognito:
+		if cfg.UserPoolID == "" || cfg.Region == "" {
+			return nil, fmt.Errorf("cognito auth requires a user pool ID and region")
+		}
+		issuer = CognitoIssuer(cfg.Region, cfg.UserPoolID)
+		// Access tokens name the app client in client_id and have no aud
+		audience, clientID = "", cfg.Audience
+		keys = newKeySet(client, issuer+"/.well-known/jwks.json", cfg.JWKSRefreshInterval, log).get
+		log.Info("jwt authentication enabled", "provider", ProviderCognito, "issuer", issuer)
+
+	case ProviderStatic:
+		if len(cfg.Secret) < minSecretLength {
+			return nil, fmt.Errorf("static auth requires a secret of at least %d bytes", minSecretLength)
+		}
+		secret := []byte(cfg.Secret)
+		methods = hmacSigningMethods
+		keys = func(context.Context, string) (interface{}, error) { return secret, nil }
+		log.Info("jwt authentication enabled", "provider", ProviderStatic, "issuer", cfg.Issuer)
+
+	default:
+		return nil, fmt.Errorf("unknown auth provider %q", cfg.Provider)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.ClockSkew),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	userClaim := cfg.UserClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+
+	return &Verifier{
+		userClaim:   userClaim,
+		tenantClaim: cfg.TenantClaim,
+		clientID:    clientID,
+		keys:        keys,
+		parser:      jwt.NewParser(opts...),
+		log:         log,
+	}, nil
+}
+
+// CognitoIssuer returns the issuer of a Cognito user pool's tokens
+func CognitoIssuer(region, userPoolID string) string {
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID)
+}
+
+// Verify validates a raw JWT and returns its claims
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Claims, error) {
+	mapClaims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(raw, mapClaims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if v.clientID != "" && !hasClientID(mapClaims, v.clientID) {
+		return nil, fmt.Errorf("%w: issued to another client", ErrInvalidToken)
+	}
+
+	userID, _ := mapClaims[v.userClaim].(string)
+	if userID == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.userClaim)
+	}
+
+	claims := &Claims{
+		UserID: userID,
+		Scopes: scopes(mapClaims),
+	}
+	if v.tenantClaim != "" {
+		claims.TenantID, _ = mapClaims[v.tenantClaim].(string)
+		if claims.TenantID != "" && !tenant.Valid(claims.TenantID) {
+			return nil, fmt.Errorf("%w: malformed %s claim", ErrInvalidToken, v.tenantClaim)
+		}
+	}
+	claims.Email, _ = mapClaims["email"].(string)
+	claims.Issuer, _ = mapClaims.GetIssuer()
+	if exp, err := mapClaims.GetExpirationTime(); err == nil && exp != nil {
+		claims.ExpiresAt = exp.Time
+	}
+
+	return claims, nil
+}
+
+// hasClientID reports whether a token was issued to an app client: ID
+// tokens name it in aud, access tokens in client_id
+func hasClientID(claims jwt.MapClaims, clientID string) bool {
+	if id, _ := claims["client_id"].(string); id == clientID {
+		return true
+	}
+	aud, _ := claims.GetAudience()
+	for _, a := range aud {
+		if a == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// scopes reads the space separated scope claim, or the scp array used by
+// some issuers
+func scopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	switch scp := claims["scp"].(type) {
+	case string:
+		return strings.Fields(scp)
+	case []interface{}:
+		result := make([]string, 0, len(scp))
+		for _, s := range scp {
+			if str, ok := s.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	}
+
+	return nil
+}
+
+// discoverJWKSURL reads jwks_uri from the issuer's OpenID configuration
+func discoverJWKSURL(ctx context.Context, client *http.Client, issuer string) (string, error) {
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create discovery request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch OpenID configuration: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch OpenID configuration: status %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", fmt.Errorf("failed to decode OpenID configuration: %w", err)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OpenID configuration has no jwks_uri")
+	}
+
+	return doc.JWKSURI, nil
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a context carrying the caller's claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the caller's claims, if authenticated
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// ResolveTenant scopes ctx to the caller's tenant: the one in their claims
+// when authenticated, otherwise the one named by requested, the
+// TenantHeader value, or the default tenant. A malformed tenant yields
+// ErrInvalidTenant.
+func ResolveTenant(ctx context.Context, requested string) (context.Context, error) {
+	id := requested
+	if claims, ok := FromContext(ctx); ok {
+		id = claims.TenantID
+	}
+	if id != "" && !tenant.Valid(id) {
+		return ctx, ErrInvalidTenant
+	}
+	return tenant.WithID(ctx, id), nil
+}
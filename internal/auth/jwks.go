@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/streaming-service/pkg/logger"
+)
+
+// minRefreshInterval limits JWKS refetches triggered by unknown key IDs
+const minRefreshInterval = time.Minute
+
+// keySet caches an issuer's signing keys, refetching them when they age
+// out or a token references a key that is not cached yet (key rotation)
+type keySet struct {
+	client          *http.Client
+	url             string
+	refreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+
+	log *logger.Logger
+}
+
+// newKeySet creates a lazily fetched key set
+func newKeySet(client *http.Client, url string, refreshInterval time.Duration, log *logger.Logger) *keySet {
+	if refreshInterval <= 0 {
+		refreshInterval = time.Hour
+	}
+	return &keySet{
+		client:          client,
+		url:             url,
+		refreshInterval: refreshInterval,
+		keys:            make(map[string]crypto.PublicKey),
+		log:             log,
+	}
+}
+
+// get returns the key with the given ID. Tokens without a kid are
+// accepted only when the set holds a single key.
+func (s *keySet) get(ctx context.Context, kid string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stale := time.Since(s.fetchedAt) > s.refreshInterval
+	key, ok := s.lookup(kid)
+	if stale || (!ok && time.Since(s.fetchedAt) > minRefreshInterval) {
+		if err := s.refresh(ctx); err != nil {
+			// Keep serving cached keys through an issuer outage
+			if ok {
+				s.log.Warn("failed to refresh JWKS, using cached keys", "error", err)
+				return key, nil
+			}
+			return nil, err
+		}
+		key, ok = s.lookup(kid)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup finds a cached key. Callers hold s.mu.
+func (s *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" {
+		if len(s.keys) != 1 {
+			return nil, false
+		}
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the JWKS. Callers hold s.mu.
+func (s *keySet) refresh(ctx context.Context) error {
+	// Count failed attempts too, so an unreachable issuer is not hammered
+	s.fetchedAt = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			s.log.Warn("skipping unsupported JWKS key", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	s.keys = keys
+	s.log.Debug("refreshed JWKS", "keys", len(keys))
+
+	return nil
+}
+
+// jwk is a JSON Web Key (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes the key material
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// decodeBigInt decodes a base64url encoded big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -5,18 +5,52 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
+	"github.com/streaming-service/pkg/logger"
 )
 
 // Config holds all configuration for the application
 type Config struct {
 	App    AppConfig
 	Server ServerConfig
+	Upload UploadConfig
 	AWS    AWSConfig
 	Redis  RedisConfig
 	FFMPEG FFMPEGConfig
 	Worker WorkerConfig
 	Log    LogConfig
+	Ads    AdsConfig
+
+	Playback   PlaybackConfig
+	Encryption EncryptionConfig
+	DRM        DRMConfig
+	Live       LiveConfig
+	Auth       AuthConfig
+	APIKeys    APIKeysConfig
+	Search     SearchConfig
+	Moderation ModerationConfig
+	Copyright  CopyrightConfig
+	Transcript TranscriptConfig
+	Transcribe TranscribeConfig
+
+	Translation    TranslationConfig
+	Enrichment     EnrichmentConfig
+	Idempotency    IdempotencyConfig
+	Audit          AuditConfig
+	Quotas         QuotasConfig
+	ErrorReporting ErrorReportingConfig
+	Notifications  NotificationsConfig
+	Events         EventsConfig
+	Outbox         OutboxConfig
+	Webhooks       WebhooksConfig
+	Retention      RetentionConfig
+	Export         ExportConfig
+	Versions       VersionsConfig
+	Debug          DebugConfig
+
+	// v is kept to watch the config file for changes
+	v *viper.Viper
 }
 
 // AppConfig holds application metadata
@@ -32,6 +66,29 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	// GRPCPort serves the gRPC API over cleartext HTTP/2; 0 disables it
+	GRPCPort int
+	// Metrics serves /metrics for Prometheus to scrape
+	Metrics bool
+	// ReadinessTimeout bounds each dependency check of /ready
+	ReadinessTimeout time.Duration
+	// Request body limits in bytes: MaxBodySize for JSON bodies,
+	// MaxUploadSize for direct media uploads and MaxArtworkSize for
+	// channel artwork
+	MaxBodySize    int64
+	MaxUploadSize  int64
+	MaxArtworkSize int64
+}
+
+// UploadConfig holds which media files may be uploaded. Files are
+// checked by name and declared type before anything is stored.
+type UploadConfig struct {
+	// Extensions lists the file extensions uploads may have, such as ".mp4"
+	Extensions []string
+	// ContentTypes lists the MIME types uploads may declare, such as
+	// "video/mp4" or "audio/*". Uploads declaring none, or the generic
+	// application/octet-stream, are checked by extension alone.
+	ContentTypes []string
 }
 
 // AWSConfig holds AWS service configuration
@@ -42,8 +99,55 @@ type AWSConfig struct {
 	S3RawBucket       string
 	S3ProcessedBucket string
 	DynamoDBTable     string
+	AnalyticsTable    string
+	KeysTable         string
+	LiveTable         string
+	APIKeysTable      string
+	TagsTable         string
+	CollectionsTable  string
+	ChannelsTable     string
+	IdempotencyTable  string
+	AuditTable        string
+	QuotasTable       string
+	PreferencesTable  string
+	OutboxTable       string
+	WebhooksTable     string
+	DeliveriesTable   string
+	JobsTable         string
 	CloudFrontDomain  string
 	CloudFrontKeyID   string
+	// CloudFrontDistributionID enables cache invalidation when set
+	CloudFrontDistributionID string
+	// CloudFront standard logs location; ingestion is disabled when empty
+	CloudFrontLogBucket string
+	CloudFrontLogPrefix string
+	// CloudFrontLogArchivePrefix is where ingested logs are moved to in
+	// the log bucket, outside CloudFrontLogPrefix
+	CloudFrontLogArchivePrefix string
+
+	// RoleARN is assumed for all AWS calls when set, with ExternalID if
+	// the role requires one. With WebIdentityTokenFile the role is assumed
+	// using that token instead of the base credentials (IRSA on EKS).
+	RoleARN              string
+	ExternalID           string
+	RoleSessionName      string
+	WebIdentityTokenFile string
+
+	// MaxAttempts and MaxBackoff bound retries of throttled and failed
+	// calls. RetryMode "adaptive" also slows calls down client-side while
+	// a service is throttling.
+	MaxAttempts int
+	MaxBackoff  time.Duration
+	RetryMode   string
+	// BreakerThreshold consecutive failed S3 or DynamoDB calls open a
+	// circuit breaker failing calls fast for BreakerCooldown; zero
+	// disables it
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// S3UsePathStyle addresses buckets in the URL path rather than the
+	// host name, as LocalStack and other S3-compatible stores need
+	S3UsePathStyle bool
 }
 
 // RedisConfig holds Redis connection configuration
@@ -60,6 +164,31 @@ type FFMPEGConfig struct {
 	TempDir         string
 	SegmentDuration int
 	Profiles        []TranscodeProfile
+	// EncodeSpeed is how many times faster than real time a worker
+	// encodes a 1080p H.264 rendition, calibrating transcode estimates
+	EncodeSpeed float64
+	// AudioSingleFile packages each audio rendition as one file addressed
+	// with EXT-X-BYTERANGE, rather than a file per segment
+	AudioSingleFile bool
+	// Packaging is the video segment format: "ts" for MPEG-TS, or "cmaf"
+	// for fragmented MP4 segments shared by an HLS playlist and a DASH
+	// manifest
+	Packaging  string
+	Storyboard StoryboardConfig
+}
+
+// StoryboardConfig holds the sprite sheets of video thumbnails made for
+// players to preview while scrubbing
+type StoryboardConfig struct {
+	Enabled bool
+	// Interval is the time between thumbnails
+	Interval time.Duration
+	// Width is the width of each thumbnail in pixels; the height follows
+	// the video's aspect ratio
+	Width int
+	// Columns and Rows tile thumbnails into each sprite sheet
+	Columns int
+	Rows    int
 }
 
 // TranscodeProfile defines a transcoding output profile
@@ -74,14 +203,478 @@ type TranscodeProfile struct {
 
 // WorkerConfig holds worker pool configuration
 type WorkerConfig struct {
-	Concurrency int
-	JobTimeout  time.Duration
+	Concurrency       int
+	JobTimeout        time.Duration
+	LogIngestInterval time.Duration
+	// HealthCheckInterval is how often S3, DynamoDB, Redis and FFMPEG are
+	// checked; dequeuing pauses while any is down. Each check is bounded
+	// by HealthCheckTimeout.
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	// DrainTimeout is how long in-flight jobs get to finish on shutdown
+	// before they are aborted and requeued
+	DrainTimeout time.Duration
+	// JobRetention is how long the records of finished jobs are kept
+	JobRetention time.Duration
+	// JobLease is how long a job stays leased to its worker without a
+	// heartbeat; workers heartbeat three times a lease. Every
+	// ReapInterval, jobs whose lease expired are returned to the queue.
+	JobLease     time.Duration
+	ReapInterval time.Duration
+	// PromoteInterval is how often scheduled jobs that are due are moved
+	// to the queue
+	PromoteInterval time.Duration
+	// TypeConcurrency caps how many jobs of a type, such as "transcode" or
+	// "export", run at once in each worker, within Concurrency
+	TypeConcurrency map[string]int
+	// MetricsPort serves /metrics for Prometheus to scrape; 0 disables it
+	MetricsPort int
+}
+
+// AdsConfig holds server-side ad insertion configuration
+type AdsConfig struct {
+	// SSAIProvider selects the stitching provider: "", "http" or "prefix"
+	SSAIProvider string
+	SSAIEndpoint string
+	SSAIPrefix   string
+	SSAITimeout  time.Duration
+	// TargetingParams are the playback query parameters forwarded to the
+	// provider for ad targeting; others are dropped
+	TargetingParams []string
+}
+
+// PlaybackConfig holds playback authorization configuration
+type PlaybackConfig struct {
+	TokenSecret string
+	TokenTTL    time.Duration
+	// EmbedEnabled lets media owners issue embed tokens granting playback
+	// of one media item on third-party sites
+	EmbedEnabled     bool
+	EmbedTokenTTL    time.Duration
+	MaxEmbedTokenTTL time.Duration
+}
+
+// EncryptionConfig holds HLS AES-128 encryption configuration
+type EncryptionConfig struct {
+	Enabled  bool
+	KMSKeyID string
+	// KeyURLBase is the public API base URL used in #EXT-X-KEY URIs
+	KeyURLBase string
+	// SegmentsPerKey controls key rotation within a media item
+	SegmentsPerKey int
+}
+
+// DRMConfig holds Common Encryption (cenc) of CMAF output, with content
+// keys from a SPEKE-compatible key server
+type DRMConfig struct {
+	Enabled bool
+	// KeyServerURL is the SPEKE endpoint content keys are requested from
+	KeyServerURL string
+	// KeyServerToken, when set, is sent to the key server as a bearer
+	// token
+	KeyServerToken string
+	// Timeout bounds each key request
+	Timeout time.Duration
+	// Licenses maps each DRM system keys are requested for, "widevine"
+	// or "playready", to the license server URL players are given
+	Licenses map[string]string
+}
+
+// LiveConfig holds live ingest and packaging configuration
+type LiveConfig struct {
+	Enabled bool
+	// OutputDir is where the live packager writes HLS output
+	OutputDir       string
+	SegmentDuration int
+	PlaylistSize    int
+	// DVRWindow is the default seekable window of live playlists; streams
+	// may request up to MaxDVRWindow. Zero keeps only PlaylistSize segments.
+	DVRWindow    time.Duration
+	MaxDVRWindow time.Duration
+	// LowLatency enables LL-HLS with parts of PartDuration, served with
+	// blocking playlist reloads by the instance running the packager
+	LowLatency   bool
+	PartDuration time.Duration
+	// PublishInterval is how often output is mirrored to S3
+	PublishInterval time.Duration
+	// StopTimeout bounds how long the packager may take to flush on stop
+	StopTimeout time.Duration
+	// HealthInterval is how often ingest health is reported
+	HealthInterval time.Duration
+	// PreviewInterval is how often a preview frame is captured; zero
+	// disables previews
+	PreviewInterval time.Duration
+	PreviewWidth    int
+
+	// WebRTC (WHIP) settings
+	ICEServers []string
+	// PublicIPs are advertised as host candidates when running behind NAT
+	PublicIPs  []string
+	UDPPortMin uint16
+	UDPPortMax uint16
+
+	// RTMP settings, for the ingest server
+	RTMPAddr string
+	// RTMPTimeout drops encoders that send nothing for this long
+	RTMPTimeout time.Duration
+}
+
+// AuthConfig holds JWT authentication configuration
+type AuthConfig struct {
+	Enabled bool
+	// Provider validates tokens: "oidc" (an issuer's JWKS), "cognito" (a
+	// Cognito user pool) or "static" (HMAC with a shared secret)
+	Provider string
+	// Issuer is the expected iss claim; the JWKS URL is discovered from its
+	// OpenID configuration unless JWKSURL is set
+	Issuer string
+	// Audience is the expected aud claim; Cognito access tokens carry it
+	// as client_id instead
+	Audience string
+	JWKSURL  string
+	// UserPoolID and Region name the Cognito user pool; Region defaults to
+	// the AWS region
+	UserPoolID string
+	Region     string
+	// Secret is the static provider's HMAC signing key, at least 32 bytes
+	Secret string
+	// UserClaim is the claim holding the user ID
+	UserClaim string
+	// TenantClaim is the claim holding the caller's tenant; tokens without
+	// it act for the default tenant
+	TenantClaim string
+	// JWKSRefreshInterval is how long fetched signing keys are trusted
+	JWKSRefreshInterval time.Duration
+	// ClockSkew is the leeway allowed when validating exp and nbf
+	ClockSkew time.Duration
+	// AdminScope is the token scope that grants access to admin endpoints
+	AdminScope string
+}
+
+// APIKeysConfig holds server-to-server API key configuration
+type APIKeysConfig struct {
+	Enabled bool
+	// DefaultRateLimit and MaxRateLimit are in requests per minute per key
+	DefaultRateLimit int
+	MaxRateLimit     int
+	// CacheTTL bounds how long a revoked key may keep working
+	CacheTTL time.Duration
+}
+
+// SearchConfig holds full-text search configuration
+type SearchConfig struct {
+	Enabled bool
+	// URL is the Meilisearch endpoint
+	URL    string
+	APIKey string
+	Index  string
+	// Timeout bounds each request to the search engine
+	Timeout time.Duration
+}
+
+// ModerationConfig holds content moderation configuration
+type ModerationConfig struct {
+	Enabled bool
+	// Provider scans sampled frames; "rekognition" is built in
+	Provider string
+	// MinConfidence is the confidence, from 0 to 100, a label needs to
+	// count as a violation
+	MinConfidence float64
+	// FrameInterval is the spacing of the frames sampled from video
+	FrameInterval time.Duration
+	// MaxFrames bounds how many frames are scanned per media item
+	MaxFrames int
+	// HoldForReview keeps media with violations, or whose scan failed,
+	// from everyone but its owner until a reviewer approves it
+	HoldForReview bool
+}
+
+// CopyrightConfig holds audio fingerprinting configuration, used to
+// flag media matching copyrighted recordings
+type CopyrightConfig struct {
+	Enabled bool
+	// BinaryPath is Chromaprint's fpcalc tool
+	BinaryPath string
+	// Length is how much of each source's audio is fingerprinted
+	Length time.Duration
+	// References is a JSON file of the reference recordings' fingerprints
+	References string
+	// MinScore is the similarity, from 0 to 1, audio needs to a reference
+	// to match it; unrelated audio scores around 0.5
+	MinScore float64
+	// MinOverlap is how long audio and a reference must overlap to be
+	// compared
+	MinOverlap time.Duration
+}
+
+// TranscriptConfig holds the filtering applied to generated captions,
+// which keeps the original track and adds a cleaned one
+type TranscriptConfig struct {
+	// MaskProfanity masks profane words in the cleaned track; they are
+	// counted either way
+	MaskProfanity bool
+	// ProfanityWords are masked along with the built-in list
+	ProfanityWords []string
+	// DetectPII looks for e-mail addresses, phone, card and social
+	// security numbers
+	DetectPII bool
+	// MaskPII masks the personal information found in the cleaned track
+	MaskPII bool
+}
+
+// TranscribeConfig holds the automatic captions transcribed from speech
+// with Amazon Transcribe, for media uploaded with them requested
+type TranscribeConfig struct {
+	Enabled bool
+	// Language is the language of the speech, such as "en-US"; empty
+	// identifies it from the audio
+	Language string
+	// PollInterval is how often a running transcription is checked
+	PollInterval time.Duration
+	// Timeout bounds each transcription
+	Timeout time.Duration
+}
+
+// TranslationConfig holds subtitle translation configuration
+type TranslationConfig struct {
+	Enabled bool
+	// Provider translates the cues; "aws" (Amazon Translate) is built in
+	Provider string
+}
+
+// EnrichmentConfig holds the configuration of the summaries and chapters
+// generated from transcripts
+type EnrichmentConfig struct {
+	Enabled bool
+	// URL is the base URL of an OpenAI-compatible chat completions API
+	URL    string
+	APIKey string
+	Model  string
+	// Timeout bounds each request to the API
+	Timeout time.Duration
+	// MaxChapters bounds how many chapters are generated
+	MaxChapters int
+}
+
+// NotificationsConfig holds the configuration of the emails sent to users
+// about their media
+type NotificationsConfig struct {
+	Enabled bool
+	// Provider sends the emails: "ses" or "smtp"
+	Provider string
+	// From is the sender address, verified with SES when sending with it
+	From string
+	// WatchURL links to a processed media item, with {id} replaced by its
+	// ID; the master playlist URL is linked when empty
+	WatchURL string
+	SMTP     SMTPConfig
+}
+
+// SMTPConfig holds the SMTP server emails are sent through. STARTTLS is
+// used when the server offers it.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// EventsConfig holds the configuration of media lifecycle events
+// published for downstream systems
+type EventsConfig struct {
+	Enabled bool
+	// Provider publishes the events: "eventbridge" or "sns"
+	Provider string
+	// EventBus and Source address EventBridge events
+	EventBus string
+	Source   string
+	// TopicARN is the SNS topic events are published to
+	TopicARN string
+}
+
+// OutboxConfig holds the configuration of the transactional outbox. When
+// enabled, events are written with the change they describe and the
+// worker's relay delivers them, instead of being published afterwards.
+type OutboxConfig struct {
+	Enabled bool
+	// Interval is how often the relay looks for pending events
+	Interval time.Duration
+	// BatchSize is how many pending events the relay reads at a time
+	BatchSize int
+	// Lease is how long a relay holds an event it is delivering, and so
+	// how long a failed delivery waits before it is retried
+	Lease time.Duration
+	// Retention is how long delivered events are kept
+	Retention time.Duration
+	// MaxAttempts is how many failed deliveries an event gets before it
+	// is marked failed and no longer retried
+	MaxAttempts int
+}
+
+// WebhooksConfig holds the configuration of user webhooks. The worker
+// delivers the lifecycle events of each user's media to their webhooks,
+// retrying failed deliveries with exponential backoff.
+type WebhooksConfig struct {
+	Enabled bool
+	// Timeout bounds each delivery request
+	Timeout time.Duration
+	// MaxAttempts is how many requests are made before a delivery fails
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubling with each one
+	// after up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Interval is how often the worker looks for deliveries that are due
+	Interval time.Duration
+	// BatchSize is how many due deliveries the worker reads at a time
+	BatchSize int
+	// Retention is how long finished deliveries are kept in the log
+	Retention time.Duration
+	// AllowPrivate lets deliveries reach loopback, private and link-local
+	// addresses, for receivers on a development machine. Otherwise users
+	// could probe the internal network through their webhooks.
+	AllowPrivate bool
+}
+
+// IdempotencyConfig holds Idempotency-Key handling configuration
+type IdempotencyConfig struct {
+	Enabled bool
+	// TTL is how long a key's response is kept for replay
+	TTL time.Duration
+	// Lease is how long a key is held for its running request. A key
+	// left by a request that never finished, such as one cut short by a
+	// crash, can be claimed again once its lease runs out, so it should
+	// outlast the API's 60s request timeout but not by much.
+	Lease time.Duration
+}
+
+// AuditConfig holds audit logging configuration
+type AuditConfig struct {
+	Enabled bool
+	// Retention is how long audit entries are kept
+	Retention time.Duration
+}
+
+// QuotasConfig holds per-tenant usage limits
+type QuotasConfig struct {
+	Enabled bool
+	// Default applies to tenants without an entry in Tenants
+	Default QuotaLimits
+	// Tenants overrides the default limits by tenant ID
+	Tenants map[string]QuotaLimits
+	// WarnAt is the fraction of a limit at which a usage warning is sent;
+	// zero disables warnings
+	WarnAt float64
+	// WebhookURL receives usage warnings as JSON POSTs
+	WebhookURL     string
+	WebhookTimeout time.Duration
+}
+
+// QuotaLimits are a tenant's usage limits; zero is unlimited
+type QuotaLimits struct {
+	StorageBytes int64
+	// TranscodeMinutes is counted per calendar month, UTC
+	TranscodeMinutes int64
+	ConcurrentJobs   int64
+}
+
+// For returns the limits of a tenant
+func (c QuotasConfig) For(tenantID string) QuotaLimits {
+	if limits, ok := c.Tenants[tenantID]; ok {
+		return limits
+	}
+	return c.Default
+}
+
+// RetentionConfig holds media retention policies. Media expires after its
+// tenant's retention, or when set on the media, and a job in the worker
+// then deletes or archives it.
+type RetentionConfig struct {
+	Enabled bool
+	// Default applies to tenants without an entry in Tenants; zero keeps
+	// media until an expiration is set on it
+	Default time.Duration
+	// Tenants overrides the default retention by tenant ID
+	Tenants map[string]time.Duration
+	// Action is "delete" to delete expired media or "archive" to delete
+	// only its processed files
+	Action string
+	// Interval is how often the worker looks for expired media
+	Interval time.Duration
+	// WarnBefore is how long before media expires a warning is sent; zero
+	// disables warnings
+	WarnBefore time.Duration
+	// WebhookURL receives expiration warnings as JSON POSTs
+	WebhookURL     string
+	WebhookTimeout time.Duration
+}
+
+// For returns the retention of a tenant's media
+func (c RetentionConfig) For(tenantID string) time.Duration {
+	if retention, ok := c.Tenants[tenantID]; ok {
+		return retention
+	}
+	return c.Default
+}
+
+// ExportConfig holds the configuration of media export packages, built
+// by the worker in the raw media bucket
+type ExportConfig struct {
+	Enabled bool
+	// URLExpiry is how long the download link of a package works
+	URLExpiry time.Duration
+}
+
+// VersionsConfig holds how many generations of a media item's processed
+// output are kept to roll back to
+type VersionsConfig struct {
+	// Keep is how many output versions are kept, the active one included;
+	// the oldest inactive ones are deleted past it
+	Keep int
+}
+
+// DebugConfig holds the debug listener configuration
+type DebugConfig struct {
+	// Addr serves pprof profiles, goroutine dumps and process internals,
+	// such as localhost:6060; empty disables it. It has no
+	// authentication, so must never be reachable publicly.
+	Addr string
+}
+
+// ErrorReportingConfig holds Sentry-compatible error reporting
+// configuration
+type ErrorReportingConfig struct {
+	// DSN enables reporting of error logs, panics and job failures
+	DSN string
+	// SampleRate is the fraction of errors reported, from 0 to 1
+	SampleRate float64
+	// FlushTimeout bounds how long shutdown waits for queued reports
+	FlushTimeout time.Duration
 }
 
 // LogConfig holds logging configuration
 type LogConfig struct {
 	Level  string
 	Format string
+	// Outputs lists where logs are written: "stdout", "stderr", "file"
+	// and "syslog"
+	Outputs []string
+	File    logger.FileOptions
+	Syslog  logger.SyslogOptions
+	// Sampling limits repeated debug entries
+	Sampling logger.SamplingOptions
+}
+
+// Options returns the logger options for this configuration
+func (c LogConfig) Options() logger.Options {
+	return logger.Options{
+		Level:    c.Level,
+		Format:   c.Format,
+		Outputs:  c.Outputs,
+		File:     c.File,
+		Syslog:   c.Syslog,
+		Sampling: c.Sampling,
+	}
 }
 
 // Load reads configuration from file and environment
@@ -115,10 +708,58 @@ func Load() (*Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("unable to unmarshal config: %w", err)
 	}
+	cfg.v = v
 
 	return &cfg, nil
 }
 
+// Secrets returns the settings holding secrets. Each may instead hold a
+// reference to a Secrets Manager secret or Parameter Store parameter,
+// resolved at startup.
+func (c *Config) Secrets() []*string {
+	return []*string{
+		&c.Redis.Password,
+		&c.Playback.TokenSecret,
+		&c.Auth.Secret,
+		&c.Search.APIKey,
+		&c.Enrichment.APIKey,
+		&c.Notifications.SMTP.Password,
+		&c.ErrorReporting.DSN,
+	}
+}
+
+// Watch watches the config file and calls onChange with the reloaded
+// configuration whenever it changes, or with the error if it can't be
+// read or is invalid. Most settings are only read at startup; callers
+// apply the few that are safe to change at runtime. Nothing is watched
+// when there is no config file.
+func (c *Config) Watch(onChange func(*Config, error)) {
+	if c.v == nil || c.v.ConfigFileUsed() == "" {
+		return
+	}
+
+	c.v.OnConfigChange(func(fsnotify.Event) {
+		// viper keeps the previous settings when the file can't be
+		// parsed; read it again to report why
+		if err := c.v.ReadInConfig(); err != nil {
+			onChange(nil, fmt.Errorf("error reading config file: %w", err))
+			return
+		}
+
+		var next Config
+		if err := c.v.Unmarshal(&next); err != nil {
+			onChange(nil, fmt.Errorf("unable to unmarshal config: %w", err))
+			return
+		}
+		if err := next.Validate(false); err != nil {
+			onChange(nil, err)
+			return
+		}
+		onChange(&next, nil)
+	})
+	c.v.WatchConfig()
+}
+
 func setDefaults(v *viper.Viper) {
 	// App defaults
 	v.SetDefault("app.name", "streaming-service")
@@ -127,25 +768,78 @@ func setDefaults(v *viper.Viper) {
 
 	// Server defaults
 	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.grpcport", 9090)
+	v.SetDefault("server.metrics", true)
 	v.SetDefault("server.readtimeout", 30*time.Second)
 	v.SetDefault("server.writetimeout", 30*time.Second)
 	v.SetDefault("server.idletimeout", 60*time.Second)
+	v.SetDefault("server.readinesstimeout", 2*time.Second)
+	v.SetDefault("server.maxbodysize", 1<<20)
+	v.SetDefault("server.maxuploadsize", 100<<20)
+	v.SetDefault("server.maxartworksize", 5<<20)
+
+	// Upload defaults
+	v.SetDefault("upload.extensions", []string{
+		".mp4", ".mov", ".avi", ".mkv", ".webm", ".flv", ".wmv", ".m4v",
+		".mp3", ".aac", ".wav", ".flac", ".ogg", ".m4a", ".wma", ".opus",
+	})
+	v.SetDefault("upload.contenttypes", []string{"video/*", "audio/*", "application/ogg"})
 
 	// AWS defaults
 	v.SetDefault("aws.region", "us-east-1")
+	// Credentials and secrets default to empty so viper binds their
+	// environment variables
+	v.SetDefault("aws.accesskeyid", "")
+	v.SetDefault("aws.secretaccesskey", "")
+	v.SetDefault("aws.rolearn", "")
+	v.SetDefault("aws.externalid", "")
+	v.SetDefault("aws.webidentitytokenfile", "")
 	v.SetDefault("aws.s3rawbucket", "streaming-raw-media")
 	v.SetDefault("aws.s3processedbucket", "streaming-processed-media")
+	v.SetDefault("aws.s3usepathstyle", false)
 	v.SetDefault("aws.dynamodbtable", "video-metadata")
+	v.SetDefault("aws.analyticstable", "media-analytics")
+	v.SetDefault("aws.keystable", "media-keys")
+	v.SetDefault("aws.livetable", "live-streams")
+	v.SetDefault("aws.apikeystable", "api-keys")
+	v.SetDefault("aws.tagstable", "media-tags")
+	v.SetDefault("aws.collectionstable", "collections")
+	v.SetDefault("aws.channelstable", "channels")
+	v.SetDefault("aws.idempotencytable", "idempotency-keys")
+	v.SetDefault("aws.audittable", "audit-log")
+	v.SetDefault("aws.quotastable", "quotas")
+	v.SetDefault("aws.preferencestable", "user-preferences")
+	v.SetDefault("aws.outboxtable", "event-outbox")
+	v.SetDefault("aws.webhookstable", "webhooks")
+	v.SetDefault("aws.deliveriestable", "webhook-deliveries")
+	v.SetDefault("aws.jobstable", "jobs")
+	v.SetDefault("aws.cloudfrontlogprefix", "cdn-logs/")
+	v.SetDefault("aws.cloudfrontlogarchiveprefix", "cdn-logs-ingested/")
+	v.SetDefault("aws.rolesessionname", "streaming-service")
+	v.SetDefault("aws.maxattempts", 5)
+	v.SetDefault("aws.maxbackoff", "20s")
+	v.SetDefault("aws.retrymode", "standard")
+	v.SetDefault("aws.breakerthreshold", 5)
+	v.SetDefault("aws.breakercooldown", "30s")
 
 	// Redis defaults
 	v.SetDefault("redis.host", "localhost")
 	v.SetDefault("redis.port", 6379)
 	v.SetDefault("redis.db", 0)
+	v.SetDefault("redis.password", "")
 
 	// FFMPEG defaults
 	v.SetDefault("ffmpeg.binarypath", "ffmpeg")
 	v.SetDefault("ffmpeg.tempdir", "/tmp/streaming")
 	v.SetDefault("ffmpeg.segmentduration", 6)
+	v.SetDefault("ffmpeg.encodespeed", 2.0)
+	v.SetDefault("ffmpeg.audiosinglefile", true)
+	v.SetDefault("ffmpeg.packaging", "ts")
+	v.SetDefault("ffmpeg.storyboard.enabled", true)
+	v.SetDefault("ffmpeg.storyboard.interval", "10s")
+	v.SetDefault("ffmpeg.storyboard.width", 160)
+	v.SetDefault("ffmpeg.storyboard.columns", 5)
+	v.SetDefault("ffmpeg.storyboard.rows", 5)
 	v.SetDefault("ffmpeg.profiles", []TranscodeProfile{
 		{Name: "1080p", Width: 1920, Height: 1080, VideoBitrate: "5000k", AudioBitrate: "192k", Codec: "h264"},
 		{Name: "720p", Width: 1280, Height: 720, VideoBitrate: "2500k", AudioBitrate: "128k", Codec: "h264"},
@@ -156,8 +850,206 @@ func setDefaults(v *viper.Viper) {
 	// Worker defaults
 	v.SetDefault("worker.concurrency", 4)
 	v.SetDefault("worker.jobtimeout", 30*time.Minute)
+	v.SetDefault("worker.logingestinterval", 5*time.Minute)
+	v.SetDefault("worker.healthcheckinterval", 15*time.Second)
+	v.SetDefault("worker.healthchecktimeout", 5*time.Second)
+	v.SetDefault("worker.draintimeout", 10*time.Minute)
+	v.SetDefault("worker.jobretention", 30*24*time.Hour)
+	v.SetDefault("worker.joblease", 2*time.Minute)
+	v.SetDefault("worker.reapinterval", 30*time.Second)
+	v.SetDefault("worker.promoteinterval", 5*time.Second)
+	v.SetDefault("worker.metricsport", 9091)
+
+	// Ads defaults
+	v.SetDefault("ads.ssaiprovider", "")
+	v.SetDefault("ads.ssaitimeout", 2*time.Second)
+	v.SetDefault("ads.targetingparams", []string{"genre", "keywords", "lang", "ppid"})
+
+	// Playback defaults
+	v.SetDefault("playback.tokensecret", "")
+	v.SetDefault("playback.tokenttl", 4*time.Hour)
+	v.SetDefault("playback.embedenabled", false)
+	v.SetDefault("playback.embedtokenttl", 24*time.Hour)
+	v.SetDefault("playback.maxembedtokenttl", 30*24*time.Hour)
+
+	// Encryption defaults
+	v.SetDefault("encryption.enabled", false)
+	v.SetDefault("encryption.keyurlbase", "http://localhost:8080")
+	v.SetDefault("encryption.segmentsperkey", 50)
+
+	// DRM defaults
+	v.SetDefault("drm.enabled", false)
+	v.SetDefault("drm.keyserverurl", "")
+	v.SetDefault("drm.keyservertoken", "")
+	v.SetDefault("drm.timeout", 10*time.Second)
+	v.SetDefault("drm.licenses", map[string]string{})
+
+	// Auth defaults
+	v.SetDefault("auth.enabled", false)
+	v.SetDefault("auth.provider", "oidc")
+	v.SetDefault("auth.userclaim", "sub")
+	v.SetDefault("auth.tenantclaim", "tenant_id")
+	v.SetDefault("auth.jwksrefreshinterval", time.Hour)
+	v.SetDefault("auth.clockskew", 30*time.Second)
+	v.SetDefault("auth.adminscope", "admin")
+
+	// API key defaults
+	v.SetDefault("apikeys.enabled", false)
+	v.SetDefault("apikeys.defaultratelimit", 600)
+	v.SetDefault("apikeys.maxratelimit", 6000)
+	v.SetDefault("apikeys.cachettl", time.Minute)
+
+	// Search defaults
+	v.SetDefault("search.enabled", false)
+	v.SetDefault("search.url", "http://localhost:7700")
+	v.SetDefault("search.index", "media")
+	v.SetDefault("search.apikey", "")
+	v.SetDefault("search.timeout", 5*time.Second)
+
+	// Moderation defaults
+	v.SetDefault("moderation.enabled", false)
+	v.SetDefault("moderation.provider", "rekognition")
+	v.SetDefault("moderation.minconfidence", 80.0)
+	v.SetDefault("moderation.frameinterval", 10*time.Second)
+	v.SetDefault("moderation.maxframes", 60)
+	v.SetDefault("moderation.holdforreview", true)
+
+	// Copyright defaults
+	v.SetDefault("copyright.enabled", false)
+	v.SetDefault("copyright.binarypath", "fpcalc")
+	v.SetDefault("copyright.length", 2*time.Minute)
+	v.SetDefault("copyright.references", "")
+	v.SetDefault("copyright.minscore", 0.85)
+	v.SetDefault("copyright.minoverlap", 10*time.Second)
+
+	// Transcript defaults
+	v.SetDefault("transcript.maskprofanity", true)
+	v.SetDefault("transcript.profanitywords", []string{})
+	v.SetDefault("transcript.detectpii", true)
+	v.SetDefault("transcript.maskpii", true)
+
+	// Transcribe defaults
+	v.SetDefault("transcribe.enabled", false)
+	v.SetDefault("transcribe.language", "")
+	v.SetDefault("transcribe.pollinterval", 10*time.Second)
+	v.SetDefault("transcribe.timeout", time.Hour)
+
+	// Translation defaults
+	v.SetDefault("translation.enabled", false)
+	v.SetDefault("translation.provider", "aws")
+
+	// Enrichment defaults
+	v.SetDefault("enrichment.enabled", false)
+	v.SetDefault("enrichment.url", "https://api.openai.com/v1")
+	v.SetDefault("enrichment.apikey", "")
+	v.SetDefault("enrichment.model", "gpt-4o-mini")
+	v.SetDefault("enrichment.timeout", 2*time.Minute)
+	v.SetDefault("enrichment.maxchapters", 12)
+
+	// Notifications defaults
+	v.SetDefault("notifications.enabled", false)
+	v.SetDefault("notifications.provider", "ses")
+	v.SetDefault("notifications.from", "")
+	v.SetDefault("notifications.watchurl", "")
+	v.SetDefault("notifications.smtp.host", "")
+	v.SetDefault("notifications.smtp.port", 587)
+	v.SetDefault("notifications.smtp.username", "")
+	v.SetDefault("notifications.smtp.password", "")
+
+	// Events defaults
+	v.SetDefault("events.enabled", false)
+	v.SetDefault("events.provider", "eventbridge")
+	v.SetDefault("events.eventbus", "default")
+	v.SetDefault("events.source", "streaming-service")
+	v.SetDefault("events.topicarn", "")
+
+	// Outbox defaults
+	v.SetDefault("outbox.enabled", false)
+	v.SetDefault("outbox.interval", time.Second)
+	v.SetDefault("outbox.batchsize", 25)
+	v.SetDefault("outbox.lease", 30*time.Second)
+	v.SetDefault("outbox.retention", 7*24*time.Hour)
+	v.SetDefault("outbox.maxattempts", 10)
+
+	// Webhooks defaults
+	v.SetDefault("webhooks.enabled", false)
+	v.SetDefault("webhooks.timeout", 10*time.Second)
+	v.SetDefault("webhooks.maxattempts", 8)
+	v.SetDefault("webhooks.backoff", 30*time.Second)
+	v.SetDefault("webhooks.maxbackoff", time.Hour)
+	v.SetDefault("webhooks.interval", 5*time.Second)
+	v.SetDefault("webhooks.batchsize", 25)
+	v.SetDefault("webhooks.retention", 30*24*time.Hour)
+	v.SetDefault("webhooks.allowprivate", false)
+
+	// Idempotency defaults
+	v.SetDefault("idempotency.enabled", true)
+	v.SetDefault("idempotency.ttl", 24*time.Hour)
+	v.SetDefault("idempotency.lease", 2*time.Minute)
+
+	// Audit defaults
+	v.SetDefault("audit.enabled", true)
+	v.SetDefault("audit.retention", 365*24*time.Hour)
+
+	// Quota defaults; limits are unlimited until configured
+	v.SetDefault("quotas.enabled", false)
+	v.SetDefault("quotas.default.storagebytes", 0)
+	v.SetDefault("quotas.default.transcodeminutes", 0)
+	v.SetDefault("quotas.default.concurrentjobs", 0)
+	v.SetDefault("quotas.warnat", 0.8)
+	v.SetDefault("quotas.webhookurl", "")
+	v.SetDefault("quotas.webhooktimeout", 10*time.Second)
+
+	// Retention defaults; media is kept until configured
+	v.SetDefault("retention.enabled", false)
+	v.SetDefault("retention.default", 0)
+	v.SetDefault("retention.action", "delete")
+	v.SetDefault("retention.interval", 5*time.Minute)
+	v.SetDefault("retention.warnbefore", 7*24*time.Hour)
+	v.SetDefault("retention.webhookurl", "")
+	v.SetDefault("retention.webhooktimeout", 10*time.Second)
+
+	// Export defaults
+	v.SetDefault("export.enabled", false)
+	v.SetDefault("export.urlexpiry", time.Hour)
+
+	// Versions defaults
+	v.SetDefault("versions.keep", 3)
+
+	// Debug defaults
+	v.SetDefault("debug.addr", "")
+
+	// Live defaults
+	v.SetDefault("live.enabled", false)
+	v.SetDefault("live.outputdir", "/tmp/streaming/live")
+	v.SetDefault("live.segmentduration", 2)
+	v.SetDefault("live.playlistsize", 6)
+	v.SetDefault("live.dvrwindow", 0)
+	v.SetDefault("live.maxdvrwindow", 4*time.Hour)
+	v.SetDefault("live.lowlatency", false)
+	v.SetDefault("live.partduration", 500*time.Millisecond)
+	v.SetDefault("live.publishinterval", time.Second)
+	v.SetDefault("live.stoptimeout", 10*time.Second)
+	v.SetDefault("live.healthinterval", 5*time.Second)
+	v.SetDefault("live.previewinterval", 10*time.Second)
+	v.SetDefault("live.previewwidth", 640)
+	v.SetDefault("live.rtmpaddr", ":1935")
+	v.SetDefault("live.rtmptimeout", 30*time.Second)
+
+	// Error reporting defaults
+	v.SetDefault("errorreporting.dsn", "")
+	v.SetDefault("errorreporting.samplerate", 1.0)
+	v.SetDefault("errorreporting.flushtimeout", 2*time.Second)
 
 	// Log defaults
 	v.SetDefault("log.level", "info")
 	v.SetDefault("log.format", "json")
+	v.SetDefault("log.outputs", []string{"stdout"})
+	v.SetDefault("log.file.path", "/var/log/streaming-service/app.log")
+	v.SetDefault("log.file.maxsizemb", 100)
+	v.SetDefault("log.file.maxagedays", 7)
+	v.SetDefault("log.file.maxbackups", 5)
+	v.SetDefault("log.syslog.tag", "streaming-service")
+	v.SetDefault("log.sampling.initial", 0)
+	v.SetDefault("log.sampling.thereafter", 100)
 }
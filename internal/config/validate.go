@@ -0,0 +1,411 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+// KnownCodecs are the video encoders transcode profiles may use
+var KnownCodecs = map[string]bool{
+	"h264":    true,
+	"libx264": true,
+	"hevc":    true,
+	"libx265": true,
+}
+
+// ValidationError lists every problem found in the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// problems collects validation failures
+type problems []string
+
+// check records the problem described by format unless ok
+func (p *problems) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		*p = append(*p, fmt.Sprintf(format, args...))
+	}
+}
+
+// required records a problem if value is empty
+func (p *problems) required(key, value string) {
+	p.check(value != "", "%s is required", key)
+}
+
+// positive records a problem if d isn't positive
+func (p *problems) positive(key string, d time.Duration) {
+	p.check(d > 0, "%s must be positive, got %s", key, d)
+}
+
+// checkQuotaLimits records a problem for each negative limit
+func checkQuotaLimits(p *problems, key string, limits QuotaLimits) {
+	p.check(limits.StorageBytes >= 0, "%s.storagebytes must not be negative", key)
+	p.check(limits.TranscodeMinutes >= 0, "%s.transcodeminutes must not be negative", key)
+	p.check(limits.ConcurrentJobs >= 0, "%s.concurrentjobs must not be negative", key)
+}
+
+// Validate checks the configuration at startup, returning a
+// *ValidationError listing every problem found. requireFFMPEG also checks
+// that the FFMPEG binary can be found, for processes that run it.
+func (c *Config) Validate(requireFFMPEG bool) error {
+	var p problems
+
+	// Server
+	p.check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	p.check(c.Server.GRPCPort >= 0 && c.Server.GRPCPort <= 65535, "server.grpcport must be between 0 and 65535, got %d", c.Server.GRPCPort)
+	p.positive("server.readtimeout", c.Server.ReadTimeout)
+	p.positive("server.writetimeout", c.Server.WriteTimeout)
+	p.positive("server.readinesstimeout", c.Server.ReadinessTimeout)
+	p.check(c.Server.MaxBodySize > 0, "server.maxbodysize must be positive")
+	p.check(c.Server.MaxUploadSize > 0, "server.maxuploadsize must be positive")
+
+	// Upload
+	p.check(len(c.Upload.Extensions) > 0, "upload.extensions must list at least one extension")
+	for _, ext := range c.Upload.Extensions {
+		p.check(strings.HasPrefix(ext, ".") && len(ext) > 1, "upload.extensions entry %q must be an extension such as .mp4", ext)
+	}
+	for _, contentType := range c.Upload.ContentTypes {
+		p.check(strings.Count(contentType, "/") == 1, "upload.contenttypes entry %q must be a MIME type such as video/mp4 or video/*", contentType)
+	}
+
+	// AWS
+	p.required("aws.region", c.AWS.Region)
+	p.required("aws.s3rawbucket", c.AWS.S3RawBucket)
+	p.required("aws.s3processedbucket", c.AWS.S3ProcessedBucket)
+	p.required("aws.dynamodbtable", c.AWS.DynamoDBTable)
+	p.required("aws.analyticstable", c.AWS.AnalyticsTable)
+	p.required("aws.keystable", c.AWS.KeysTable)
+	p.required("aws.livetable", c.AWS.LiveTable)
+	p.required("aws.apikeystable", c.AWS.APIKeysTable)
+	p.required("aws.tagstable", c.AWS.TagsTable)
+	p.required("aws.collectionstable", c.AWS.CollectionsTable)
+	p.required("aws.channelstable", c.AWS.ChannelsTable)
+	p.required("aws.idempotencytable", c.AWS.IdempotencyTable)
+	p.required("aws.audittable", c.AWS.AuditTable)
+	p.required("aws.quotastable", c.AWS.QuotasTable)
+	p.required("aws.preferencestable", c.AWS.PreferencesTable)
+	p.required("aws.outboxtable", c.AWS.OutboxTable)
+	p.required("aws.webhookstable", c.AWS.WebhooksTable)
+	p.required("aws.deliveriestable", c.AWS.DeliveriesTable)
+	p.required("aws.jobstable", c.AWS.JobsTable)
+	p.check((c.AWS.AccessKeyID == "") == (c.AWS.SecretAccessKey == ""),
+		"aws.accesskeyid and aws.secretaccesskey must be set together")
+	p.check(c.AWS.WebIdentityTokenFile == "" || c.AWS.RoleARN != "",
+		"aws.rolearn is required with aws.webidentitytokenfile")
+	p.check(c.AWS.MaxAttempts > 0, "aws.maxattempts must be positive, got %d", c.AWS.MaxAttempts)
+	p.positive("aws.maxbackoff", c.AWS.MaxBackoff)
+	p.check(c.AWS.RetryMode == "standard" || c.AWS.RetryMode == "adaptive",
+		"aws.retrymode must be \"standard\" or \"adaptive\", got %q", c.AWS.RetryMode)
+	p.check(c.AWS.BreakerThreshold >= 0, "aws.breakerthreshold must not be negative, got %d", c.AWS.BreakerThreshold)
+	if c.AWS.BreakerThreshold > 0 {
+		p.positive("aws.breakercooldown", c.AWS.BreakerCooldown)
+	}
+
+	// Redis
+	p.required("redis.host", c.Redis.Host)
+	p.check(c.Redis.Port > 0 && c.Redis.Port <= 65535, "redis.port must be between 1 and 65535, got %d", c.Redis.Port)
+
+	// FFMPEG
+	p.required("ffmpeg.binarypath", c.FFMPEG.BinaryPath)
+	p.check(c.FFMPEG.SegmentDuration > 0, "ffmpeg.segmentduration must be positive, got %d", c.FFMPEG.SegmentDuration)
+	p.check(c.FFMPEG.EncodeSpeed > 0, "ffmpeg.encodespeed must be positive, got %g", c.FFMPEG.EncodeSpeed)
+	p.check(c.FFMPEG.Packaging == "ts" || c.FFMPEG.Packaging == "cmaf",
+		"ffmpeg.packaging %q is not one of \"ts\", \"cmaf\"", c.FFMPEG.Packaging)
+	if c.FFMPEG.Storyboard.Enabled {
+		p.positive("ffmpeg.storyboard.interval", c.FFMPEG.Storyboard.Interval)
+		p.check(c.FFMPEG.Storyboard.Width > 0, "ffmpeg.storyboard.width must be positive, got %d", c.FFMPEG.Storyboard.Width)
+		p.check(c.FFMPEG.Storyboard.Columns > 0, "ffmpeg.storyboard.columns must be positive, got %d", c.FFMPEG.Storyboard.Columns)
+		p.check(c.FFMPEG.Storyboard.Rows > 0, "ffmpeg.storyboard.rows must be positive, got %d", c.FFMPEG.Storyboard.Rows)
+	}
+	names := make(map[string]bool)
+	for i, profile := range c.FFMPEG.Profiles {
+		key := fmt.Sprintf("ffmpeg.profiles[%d]", i)
+		p.required(key+".name", profile.Name)
+		p.check(!names[profile.Name], "%s.name %q is used by another profile", key, profile.Name)
+		names[profile.Name] = true
+		p.check(profile.Width > 0 && profile.Height > 0, "%s must have a positive width and height", key)
+		_, err := processor.ParseBitrate(profile.VideoBitrate)
+		p.check(err == nil, "%s.videobitrate %q is not a bitrate such as \"2500k\"", key, profile.VideoBitrate)
+		_, err = processor.ParseBitrate(profile.AudioBitrate)
+		p.check(err == nil, "%s.audiobitrate %q is not a bitrate such as \"128k\"", key, profile.AudioBitrate)
+		p.check(KnownCodecs[profile.Codec], "%s.codec %q is not a known codec", key, profile.Codec)
+	}
+	if requireFFMPEG && c.FFMPEG.BinaryPath != "" {
+		_, err := exec.LookPath(c.FFMPEG.BinaryPath)
+		p.check(err == nil, "ffmpeg.binarypath %q is not executable: %v", c.FFMPEG.BinaryPath, err)
+	}
+
+	// Worker
+	p.check(c.Worker.Concurrency > 0, "worker.concurrency must be positive, got %d", c.Worker.Concurrency)
+	p.positive("worker.jobtimeout", c.Worker.JobTimeout)
+	p.positive("worker.healthcheckinterval", c.Worker.HealthCheckInterval)
+	p.positive("worker.healthchecktimeout", c.Worker.HealthCheckTimeout)
+	p.positive("worker.draintimeout", c.Worker.DrainTimeout)
+	p.positive("worker.jobretention", c.Worker.JobRetention)
+	p.positive("worker.joblease", c.Worker.JobLease)
+	p.positive("worker.reapinterval", c.Worker.ReapInterval)
+	p.positive("worker.promoteinterval", c.Worker.PromoteInterval)
+	p.check(c.Worker.MetricsPort >= 0 && c.Worker.MetricsPort <= 65535, "worker.metricsport must be between 0 and 65535, got %d", c.Worker.MetricsPort)
+	for jobType, limit := range c.Worker.TypeConcurrency {
+		p.check(limit > 0, "worker.typeconcurrency.%s must be positive, got %d", jobType, limit)
+	}
+	if c.AWS.CloudFrontLogBucket != "" {
+		p.positive("worker.logingestinterval", c.Worker.LogIngestInterval)
+		p.required("aws.cloudfrontlogarchiveprefix", c.AWS.CloudFrontLogArchivePrefix)
+		p.check(!strings.HasPrefix(c.AWS.CloudFrontLogArchivePrefix, c.AWS.CloudFrontLogPrefix),
+			"aws.cloudfrontlogarchiveprefix must be outside aws.cloudfrontlogprefix, got %q", c.AWS.CloudFrontLogArchivePrefix)
+	}
+
+	// Ads
+	switch c.Ads.SSAIProvider {
+	case "":
+	case "http":
+		p.required("ads.ssaiendpoint", c.Ads.SSAIEndpoint)
+	case "prefix":
+		p.required("ads.ssaiprefix", c.Ads.SSAIPrefix)
+	default:
+		p.check(false, "ads.ssaiprovider %q is not one of \"http\" or \"prefix\"", c.Ads.SSAIProvider)
+	}
+
+	// Playback
+	p.positive("playback.tokenttl", c.Playback.TokenTTL)
+	if c.Playback.EmbedEnabled {
+		p.required("playback.tokensecret", c.Playback.TokenSecret)
+		p.positive("playback.embedtokenttl", c.Playback.EmbedTokenTTL)
+		p.check(c.Playback.EmbedTokenTTL <= c.Playback.MaxEmbedTokenTTL,
+			"playback.embedtokenttl must not exceed playback.maxembedtokenttl")
+	}
+
+	// Encryption
+	if c.Encryption.Enabled {
+		p.required("encryption.kmskeyid", c.Encryption.KMSKeyID)
+		p.check(c.Encryption.SegmentsPerKey > 0, "encryption.segmentsperkey must be positive, got %d", c.Encryption.SegmentsPerKey)
+		// Segments are encrypted whole, which only MPEG-TS players accept
+		p.check(c.FFMPEG.Packaging != "cmaf", "encryption is not supported with ffmpeg.packaging \"cmaf\"")
+	}
+
+	// DRM
+	if c.DRM.Enabled {
+		p.required("drm.keyserverurl", c.DRM.KeyServerURL)
+		p.positive("drm.timeout", c.DRM.Timeout)
+		// Only CMAF segments carry Common Encryption
+		p.check(c.FFMPEG.Packaging == "cmaf", "drm requires ffmpeg.packaging \"cmaf\"")
+		p.check(!c.Encryption.Enabled, "drm and encryption can't both be enabled")
+		p.check(len(c.DRM.Licenses) > 0, "drm.licenses must name at least one DRM system")
+		for system := range c.DRM.Licenses {
+			p.check(system == "widevine" || system == "playready",
+				"drm.licenses key %q is not one of \"widevine\", \"playready\"", system)
+		}
+	}
+
+	// Live
+	if c.Live.Enabled {
+		p.required("live.outputdir", c.Live.OutputDir)
+		p.check(c.Live.SegmentDuration > 0, "live.segmentduration must be positive, got %d", c.Live.SegmentDuration)
+		p.check(c.Live.PlaylistSize > 0, "live.playlistsize must be positive, got %d", c.Live.PlaylistSize)
+		p.check(c.Live.DVRWindow <= c.Live.MaxDVRWindow, "live.dvrwindow must not exceed live.maxdvrwindow")
+		if c.Live.LowLatency {
+			p.positive("live.partduration", c.Live.PartDuration)
+		}
+		p.positive("live.publishinterval", c.Live.PublishInterval)
+		p.check(c.Live.UDPPortMin <= c.Live.UDPPortMax, "live.udpportmin must not exceed live.udpportmax")
+		p.required("live.rtmpaddr", c.Live.RTMPAddr)
+		p.positive("live.rtmptimeout", c.Live.RTMPTimeout)
+	}
+
+	// Auth
+	if c.Auth.Enabled {
+		switch c.Auth.Provider {
+		case "oidc":
+			p.check(c.Auth.Issuer != "" || c.Auth.JWKSURL != "", "auth.issuer or auth.jwksurl is required")
+		case "cognito":
+			p.required("auth.userpoolid", c.Auth.UserPoolID)
+			p.check(c.Auth.Region != "" || c.AWS.Region != "", "auth.region or aws.region is required")
+		case "static":
+			p.required("auth.secret", c.Auth.Secret)
+		default:
+			p.check(false, "auth.provider %q is not one of \"oidc\", \"cognito\", \"static\"", c.Auth.Provider)
+		}
+		p.required("auth.userclaim", c.Auth.UserClaim)
+	}
+
+	// Search
+	if c.Search.Enabled {
+		p.required("search.url", c.Search.URL)
+		p.required("search.index", c.Search.Index)
+		p.positive("search.timeout", c.Search.Timeout)
+	}
+
+	// Moderation
+	if c.Moderation.Enabled {
+		p.check(c.Moderation.Provider == "rekognition", "moderation.provider %q is not one of \"rekognition\"", c.Moderation.Provider)
+		p.check(c.Moderation.MinConfidence >= 0 && c.Moderation.MinConfidence <= 100,
+			"moderation.minconfidence must be between 0 and 100, got %g", c.Moderation.MinConfidence)
+		p.positive("moderation.frameinterval", c.Moderation.FrameInterval)
+		p.check(c.Moderation.MaxFrames > 0, "moderation.maxframes must be positive, got %d", c.Moderation.MaxFrames)
+	}
+
+	// Copyright
+	if c.Copyright.Enabled {
+		p.required("copyright.binarypath", c.Copyright.BinaryPath)
+		p.required("copyright.references", c.Copyright.References)
+		p.positive("copyright.length", c.Copyright.Length)
+		p.check(c.Copyright.MinScore > 0.5 && c.Copyright.MinScore <= 1,
+			"copyright.minscore must be above 0.5 and at most 1, got %g", c.Copyright.MinScore)
+		p.positive("copyright.minoverlap", c.Copyright.MinOverlap)
+		p.check(c.Copyright.MinOverlap <= c.Copyright.Length,
+			"copyright.minoverlap must not be longer than copyright.length")
+		if requireFFMPEG && c.Copyright.BinaryPath != "" {
+			_, err := exec.LookPath(c.Copyright.BinaryPath)
+			p.check(err == nil, "copyright.binarypath %q is not executable: %v", c.Copyright.BinaryPath, err)
+		}
+	}
+
+	// Transcribe
+	if c.Transcribe.Enabled {
+		p.positive("transcribe.pollinterval", c.Transcribe.PollInterval)
+		p.positive("transcribe.timeout", c.Transcribe.Timeout)
+	}
+
+	// Translation
+	if c.Translation.Enabled {
+		p.check(c.Translation.Provider == "aws", "translation.provider %q is not one of \"aws\"", c.Translation.Provider)
+	}
+
+	// Notifications
+	if c.Notifications.Enabled {
+		p.check(c.Notifications.Provider == "ses" || c.Notifications.Provider == "smtp",
+			"notifications.provider %q is not one of \"ses\", \"smtp\"", c.Notifications.Provider)
+		p.required("notifications.from", c.Notifications.From)
+		if c.Notifications.Provider == "smtp" {
+			p.required("notifications.smtp.host", c.Notifications.SMTP.Host)
+			p.check(c.Notifications.SMTP.Port > 0, "notifications.smtp.port must be positive, got %d", c.Notifications.SMTP.Port)
+		}
+	}
+
+	// Events
+	if c.Events.Enabled {
+		p.check(c.Events.Provider == "eventbridge" || c.Events.Provider == "sns",
+			"events.provider %q is not one of \"eventbridge\", \"sns\"", c.Events.Provider)
+		if c.Events.Provider == "sns" {
+			p.required("events.topicarn", c.Events.TopicARN)
+		} else {
+			p.required("events.eventbus", c.Events.EventBus)
+			p.required("events.source", c.Events.Source)
+		}
+	}
+
+	// Outbox
+	if c.Outbox.Enabled {
+		p.positive("outbox.interval", c.Outbox.Interval)
+		p.check(c.Outbox.BatchSize > 0 && c.Outbox.BatchSize <= 100,
+			"outbox.batchsize must be between 1 and 100, got %d", c.Outbox.BatchSize)
+		p.positive("outbox.lease", c.Outbox.Lease)
+		p.positive("outbox.retention", c.Outbox.Retention)
+		p.check(c.Outbox.MaxAttempts > 0, "outbox.maxattempts must be positive, got %d", c.Outbox.MaxAttempts)
+	}
+
+	// Webhooks
+	if c.Webhooks.Enabled {
+		p.positive("webhooks.timeout", c.Webhooks.Timeout)
+		p.check(c.Webhooks.MaxAttempts > 0, "webhooks.maxattempts must be positive, got %d", c.Webhooks.MaxAttempts)
+		p.positive("webhooks.backoff", c.Webhooks.Backoff)
+		p.check(c.Webhooks.MaxBackoff >= c.Webhooks.Backoff,
+			"webhooks.maxbackoff must be at least webhooks.backoff, got %s", c.Webhooks.MaxBackoff)
+		p.positive("webhooks.interval", c.Webhooks.Interval)
+		p.check(c.Webhooks.BatchSize > 0 && c.Webhooks.BatchSize <= 100,
+			"webhooks.batchsize must be between 1 and 100, got %d", c.Webhooks.BatchSize)
+		p.positive("webhooks.retention", c.Webhooks.Retention)
+	}
+
+	// Enrichment
+	if c.Enrichment.Enabled {
+		p.required("enrichment.url", c.Enrichment.URL)
+		p.required("enrichment.model", c.Enrichment.Model)
+		p.positive("enrichment.timeout", c.Enrichment.Timeout)
+		p.check(c.Enrichment.MaxChapters > 0, "enrichment.maxchapters must be positive, got %d", c.Enrichment.MaxChapters)
+	}
+
+	if c.Idempotency.Enabled {
+		p.positive("idempotency.ttl", c.Idempotency.TTL)
+		p.positive("idempotency.lease", c.Idempotency.Lease)
+	}
+	if c.Audit.Enabled {
+		p.positive("audit.retention", c.Audit.Retention)
+	}
+	if c.Quotas.Enabled {
+		checkQuotaLimits(&p, "quotas.default", c.Quotas.Default)
+		for id, limits := range c.Quotas.Tenants {
+			p.check(tenant.Valid(id), "quotas.tenants %q is not a valid tenant ID", id)
+			checkQuotaLimits(&p, "quotas.tenants."+id, limits)
+		}
+		p.check(c.Quotas.WarnAt >= 0 && c.Quotas.WarnAt < 1,
+			"quotas.warnat must be at least 0 and below 1, got %g", c.Quotas.WarnAt)
+		if c.Quotas.WebhookURL != "" {
+			p.positive("quotas.webhooktimeout", c.Quotas.WebhookTimeout)
+		}
+	}
+
+	if c.Retention.Enabled {
+		p.check(c.Retention.Default >= 0, "retention.default must not be negative, got %s", c.Retention.Default)
+		for id, retention := range c.Retention.Tenants {
+			p.check(tenant.Valid(id), "retention.tenants %q is not a valid tenant ID", id)
+			p.check(retention >= 0, "retention.tenants.%s must not be negative, got %s", id, retention)
+		}
+		p.check(c.Retention.Action == "delete" || c.Retention.Action == "archive",
+			"retention.action must be \"delete\" or \"archive\", got %q", c.Retention.Action)
+		p.positive("retention.interval", c.Retention.Interval)
+		p.check(c.Retention.WarnBefore >= 0, "retention.warnbefore must not be negative, got %s", c.Retention.WarnBefore)
+		if c.Retention.WebhookURL != "" {
+			p.positive("retention.webhooktimeout", c.Retention.WebhookTimeout)
+		}
+	}
+
+	if c.Export.Enabled {
+		p.positive("export.urlexpiry", c.Export.URLExpiry)
+	}
+
+	p.check(c.Versions.Keep >= 1, "versions.keep must be at least 1, got %d", c.Versions.Keep)
+
+	if c.Debug.Addr != "" {
+		_, _, err := net.SplitHostPort(c.Debug.Addr)
+		p.check(err == nil, "debug.addr %q must be host:port", c.Debug.Addr)
+	}
+
+	if c.ErrorReporting.DSN != "" {
+		p.check(c.ErrorReporting.SampleRate > 0 && c.ErrorReporting.SampleRate <= 1,
+			"errorreporting.samplerate must be above 0 and at most 1, got %g", c.ErrorReporting.SampleRate)
+	}
+
+	// Log
+	var level zapcore.Level
+	p.check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level %q is not a known level", c.Log.Level)
+	p.check(c.Log.Format == "json" || c.Log.Format == "console", "log.format must be \"json\" or \"console\", got %q", c.Log.Format)
+	for _, output := range c.Log.Outputs {
+		switch output {
+		case logger.OutputStdout, logger.OutputStderr, logger.OutputSyslog:
+		case logger.OutputFile:
+			p.required("log.file.path", c.Log.File.Path)
+		default:
+			p.check(false, "log.outputs %q is not one of \"stdout\", \"stderr\", \"file\" or \"syslog\"", output)
+		}
+	}
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
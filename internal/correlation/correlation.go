@@ -0,0 +1,21 @@
+// Package correlation carries the ID that ties an API request to the
+// background work it starts, such as transcoding jobs
+package correlation
+
+import "context"
+
+type idKey struct{}
+
+// WithID returns a context carrying the correlation ID
+func WithID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// ID returns the correlation ID carried by ctx, or "" when there is none
+func ID(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
@@ -0,0 +1,203 @@
+// Package diagnostics serves pprof profiles, goroutine dumps and the
+// internals of a running process on a debug listener, for diagnosing
+// problems such as memory growth in long-running workers. The listener
+// must never be exposed publicly.
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/pprof"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/streaming-service/pkg/logger"
+)
+
+// sectionTimeout bounds collecting a section
+const sectionTimeout = 10 * time.Second
+
+// Section collects part of a process's internals, served as JSON
+type Section func(ctx context.Context) (interface{}, error)
+
+// Server serves diagnostics
+type Server struct {
+	mux     *http.ServeMux
+	started time.Time
+	log     *logger.Logger
+}
+
+// NewServer creates a diagnostics server serving pprof under
+// /debug/pprof/, a goroutine dump on /debug/goroutines and runtime
+// statistics on /debug/runtime
+func NewServer(log *logger.Logger) *Server {
+	s := &Server{
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+		log:     log,
+	}
+
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	s.mux.HandleFunc("/debug/goroutines", goroutines)
+	s.Add("runtime", s.runtime)
+	return s
+}
+
+// Add serves a section on /debug/<name>
+func (s *Server) Add(name string, section Section) {
+	s.mux.HandleFunc("/debug/"+name, func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), sectionTimeout)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "application/json")
+		v, err := section(ctx)
+		if err != nil {
+			s.log.Error("failed to collect diagnostics", "error", err, "section", name)
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(v)
+	})
+}
+
+// Handler returns the server's handler
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// ListenAndServe serves diagnostics on addr until ctx is done
+func (s *Server) ListenAndServe(ctx context.Context, addr string) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	s.log.Warn("debug listener serving pprof and diagnostics", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.log.Error("debug listener error", "error", err)
+	}
+}
+
+// goroutines writes the stack of every goroutine
+func goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// Runtime is a snapshot of the Go runtime's statistics
+type Runtime struct {
+	Uptime     string `json:"uptime"`
+	GoVersion  string `json:"go_version"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	Goroutines int    `json:"goroutines"`
+	// Memory in bytes
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	StackInuse   uint64 `json:"stack_inuse"`
+	Sys          uint64 `json:"sys"`
+	TotalAlloc   uint64 `json:"total_alloc"`
+	NumGC        uint32 `json:"num_gc"`
+	LastGC       string `json:"last_gc,omitempty"`
+	PauseTotalNs uint64 `json:"pause_total_ns"`
+}
+
+func (s *Server) runtime(ctx context.Context) (interface{}, error) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := &Runtime{
+		Uptime:       time.Since(s.started).Round(time.Second).String(),
+		GoVersion:    runtime.Version(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapInuse:    mem.HeapInuse,
+		HeapObjects:  mem.HeapObjects,
+		StackInuse:   mem.StackInuse,
+		Sys:          mem.Sys,
+		TotalAlloc:   mem.TotalAlloc,
+		NumGC:        mem.NumGC,
+		PauseTotalNs: mem.PauseTotalNs,
+	}
+	if mem.LastGC != 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC)).UTC().Format(time.RFC3339)
+	}
+	return stats, nil
+}
+
+// DirUsage is the disk used by the files under a directory
+type DirUsage struct {
+	Dir   string `json:"dir"`
+	Bytes int64  `json:"bytes"`
+	Files int    `json:"files"`
+}
+
+// DiskUsage returns a Section totalling the files under each directory,
+// each counted once. A directory that doesn't exist uses nothing.
+func DiskUsage(dirs ...string) Section {
+	seen := make(map[string]bool, len(dirs))
+	unique := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			unique = append(unique, dir)
+		}
+	}
+	dirs = unique
+
+	return func(ctx context.Context) (interface{}, error) {
+		usage := make([]DirUsage, 0, len(dirs))
+		for _, dir := range dirs {
+			u := DirUsage{Dir: dir}
+			err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					// Files come and go as jobs run
+					if errors.Is(err, fs.ErrNotExist) {
+						return nil
+					}
+					return err
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if d.IsDir() {
+					return nil
+				}
+				info, err := d.Info()
+				if err != nil {
+					if errors.Is(err, fs.ErrNotExist) {
+						return nil
+					}
+					return err
+				}
+				u.Bytes += info.Size()
+				u.Files++
+				return nil
+			})
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			usage = append(usage, u)
+		}
+		return usage, nil
+	}
+}
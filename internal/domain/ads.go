@@ -0,0 +1,34 @@
+package domain
+
+import "sort"
+
+// AdBreak marks an ad opportunity within a media item's timeline
+type AdBreak struct {
+	// Offset is the break position in seconds from the start of the media
+	Offset float64 `json:"offset" dynamodbav:"offset"`
+	// Duration is the length of the ad avail in seconds; zero marks a
+	// pure insertion point that doesn't replace content
+	Duration float64 `json:"duration" dynamodbav:"duration"`
+}
+
+// ValidateAdBreaks sorts breaks by offset and checks that they are within
+// the media duration (when known) and don't overlap
+func ValidateAdBreaks(breaks []AdBreak, mediaDuration float64) error {
+	sort.Slice(breaks, func(i, j int) bool {
+		return breaks[i].Offset < breaks[j].Offset
+	})
+
+	for i, b := range breaks {
+		if b.Offset < 0 || b.Duration < 0 {
+			return ErrInvalidInput
+		}
+		if mediaDuration > 0 && b.Offset+b.Duration > mediaDuration {
+			return ErrInvalidInput
+		}
+		if i > 0 && breaks[i-1].Offset+breaks[i-1].Duration > b.Offset {
+			return ErrInvalidInput
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package domain
+
+import "time"
+
+// PlaybackEventType represents a player beacon type
+type PlaybackEventType string
+
+const (
+	PlaybackEventStart    PlaybackEventType = "start"
+	PlaybackEventProgress PlaybackEventType = "progress"
+	PlaybackEventComplete PlaybackEventType = "complete"
+)
+
+// HeatmapBucketSeconds is the width of a watch-time heatmap bucket
+const HeatmapBucketSeconds = 10
+
+// PlaybackEvent is a single analytics event reported for a media item
+type PlaybackEvent struct {
+	MediaID   string            `json:"media_id"`
+	SessionID string            `json:"session_id"`
+	Type      PlaybackEventType `json:"type"`
+	// Position is the playhead position in seconds
+	Position float64 `json:"position"`
+	// Watched is the number of seconds watched since the previous event
+	Watched   float64   `json:"watched"`
+	Device    string    `json:"device"`
+	Country   string    `json:"country"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// IsValid reports whether the event type is known
+func (t PlaybackEventType) IsValid() bool {
+	switch t {
+	case PlaybackEventStart, PlaybackEventProgress, PlaybackEventComplete:
+		return true
+	}
+	return false
+}
+
+// MediaAnalytics aggregates playback analytics for a media item
+type MediaAnalytics struct {
+	MediaID        string           `json:"media_id"`
+	TotalViews     int64            `json:"total_views"`
+	Views          []DailyCount     `json:"views"`
+	Heatmap        []HeatmapBucket  `json:"heatmap"`
+	Starts         int64            `json:"starts"`
+	Completions    int64            `json:"completions"`
+	CompletionRate float64          `json:"completion_rate"`
+	Devices        map[string]int64 `json:"devices"`
+	Countries      map[string]int64 `json:"countries"`
+	DeliveredBytes int64            `json:"delivered_bytes"`
+	CDNRequests    int64            `json:"cdn_requests"`
+}
+
+// DailyCount is a count for a single UTC day
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// HeatmapBucket is the watch time accumulated for a position range
+type HeatmapBucket struct {
+	Start        int     `json:"start"`
+	End          int     `json:"end"`
+	WatchSeconds float64 `json:"watch_seconds"`
+}
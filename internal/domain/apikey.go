@@ -0,0 +1,70 @@
+package domain
+
+import "time"
+
+// API key scopes
+const (
+	ScopeMediaRead     = "media:read"
+	ScopeMediaWrite    = "media:write"
+	ScopeLiveRead      = "live:read"
+	ScopeLiveWrite     = "live:write"
+	ScopeAnalyticsRead = "analytics:read"
+)
+
+// APIKeyScopes lists every scope a key may be granted
+var APIKeyScopes = []string{
+	ScopeMediaRead,
+	ScopeMediaWrite,
+	ScopeLiveRead,
+	ScopeLiveWrite,
+	ScopeAnalyticsRead,
+}
+
+// APIKey authenticates a server-to-server caller on behalf of its owner.
+// Only a hash of the secret is stored.
+type APIKey struct {
+	ID     string `json:"id" dynamodbav:"id"`
+	Name   string `json:"name" dynamodbav:"name"`
+	UserID string `json:"user_id" dynamodbav:"user_id"`
+	// TenantID is the tenant the key's owner belongs to, which callers
+	// using the key act for
+	TenantID string `json:"-" dynamodbav:"tenant_id,omitempty"`
+
+	// Prefix is the start of the secret, shown to tell keys apart
+	Prefix  string `json:"prefix" dynamodbav:"prefix"`
+	KeyHash string `json:"-" dynamodbav:"key_hash"`
+
+	Scopes []string `json:"scopes" dynamodbav:"scopes"`
+	// RateLimit is in requests per minute
+	RateLimit int `json:"rate_limit" dynamodbav:"rate_limit"`
+
+	// Timestamps
+	CreatedAt  time.Time  `json:"created_at" dynamodbav:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" dynamodbav:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" dynamodbav:"revoked_at,omitempty"`
+}
+
+// IsRevoked returns true once the key has been revoked
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// HasScope reports whether the key was granted scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidScope reports whether scope is a known API key scope
+func IsValidScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package domain
+
+import "time"
+
+// AuditOutcome summarises how an audited call ended
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	// AuditOutcomeDenied is a call rejected for missing or insufficient
+	// credentials
+	AuditOutcomeDenied  AuditOutcome = "denied"
+	AuditOutcomeFailure AuditOutcome = "failure"
+)
+
+// IsValid returns true if the outcome is known
+func (o AuditOutcome) IsValid() bool {
+	switch o {
+	case AuditOutcomeSuccess, AuditOutcomeDenied, AuditOutcomeFailure:
+		return true
+	}
+	return false
+}
+
+// AuditOutcomeForStatus classifies a response status code
+func AuditOutcomeForStatus(status int) AuditOutcome {
+	switch {
+	case status == 401 || status == 403:
+		return AuditOutcomeDenied
+	case status >= 400:
+		return AuditOutcomeFailure
+	}
+	return AuditOutcomeSuccess
+}
+
+// AuditEntry records a mutating API call
+type AuditEntry struct {
+	ID string `json:"id" dynamodbav:"id"`
+	// ActorID is the calling user, or "anonymous"
+	ActorID string `json:"actor_id" dynamodbav:"actor_id"`
+	// APIKeyID is set when the caller authenticated with an API key
+	APIKeyID string `json:"api_key_id,omitempty" dynamodbav:"api_key_id,omitempty"`
+	// TenantID is the tenant the call was made in
+	TenantID string `json:"-" dynamodbav:"tenant_id,omitempty"`
+	// Action is the method and route, such as "DELETE /media/{mediaID}"
+	Action string `json:"action" dynamodbav:"action"`
+	// Resource is the resource acted on, such as "media/abc123"
+	Resource  string       `json:"resource" dynamodbav:"resource"`
+	IP        string       `json:"ip" dynamodbav:"ip"`
+	UserAgent string       `json:"user_agent,omitempty" dynamodbav:"user_agent,omitempty"`
+	Status    int          `json:"status" dynamodbav:"status"`
+	Outcome   AuditOutcome `json:"outcome" dynamodbav:"outcome"`
+	RequestID string       `json:"request_id,omitempty" dynamodbav:"request_id,omitempty"`
+	CreatedAt time.Time    `json:"created_at" dynamodbav:"created_at"`
+	// Day partitions entries by UTC date for time-range queries
+	Day string `json:"-" dynamodbav:"day"`
+	// ExpiresAt is a Unix timestamp after which the entry is deleted
+	ExpiresAt int64 `json:"-" dynamodbav:"expires_at"`
+}
+
+// AuditFilter narrows an audit history query. Entries are looked up by
+// actor, by resource or, failing both, by day.
+type AuditFilter struct {
+	ActorID  string
+	Resource string
+	// Day is a UTC date such as "2026-01-02"; it defaults to today
+	Day     string
+	Action  string
+	Outcome AuditOutcome
+	// Since and Until bound the time of the calls
+	Since time.Time
+	Until time.Time
+}
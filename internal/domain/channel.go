@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/streaming-service/internal/tenant"
+)
+
+// Channel groups a creator's published media under a public page, and is
+// the unit feeds and browse pages are built from
+type Channel struct {
+	ID          string `json:"id" dynamodbav:"id"`
+	UserID      string `json:"user_id" dynamodbav:"user_id"`
+	Title       string `json:"title" dynamodbav:"title"`
+	Description string `json:"description" dynamodbav:"description"`
+	// TenantID is the customer the channel belongs to; empty means the
+	// default tenant
+	TenantID string `json:"-" dynamodbav:"tenant_id,omitempty"`
+
+	// ArtworkKey is the artwork image in the processed bucket
+	ArtworkKey string `json:"-" dynamodbav:"artwork_key,omitempty"`
+	ArtworkURL string `json:"artwork_url,omitempty" dynamodbav:"-"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// GetArtworkKey returns the key for a version of the channel's artwork.
+// Each upload gets a new key so cached copies never go stale.
+func (c *Channel) GetArtworkKey(version int64, ext string) string {
+	return fmt.Sprintf("%schannels/%s/artwork-%d%s", tenant.KeyPrefix(c.TenantID), c.ID, version, ext)
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// MaxCollectionItems caps the number of media in a collection
+const MaxCollectionItems = 200
+
+// Collection is an ordered list of media with its own metadata, such as a
+// playlist or a series
+type Collection struct {
+	ID          string     `json:"id" dynamodbav:"id"`
+	UserID      string     `json:"user_id" dynamodbav:"user_id"`
+	Title       string     `json:"title" dynamodbav:"title"`
+	Description string     `json:"description" dynamodbav:"description"`
+	Visibility  Visibility `json:"visibility" dynamodbav:"visibility"`
+	// TenantID is the customer the collection belongs to; empty means the
+	// default tenant
+	TenantID string `json:"-" dynamodbav:"tenant_id,omitempty"`
+
+	// MediaIDs are the collection's items in play order
+	MediaIDs []string `json:"media_ids" dynamodbav:"media_ids"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// CanView reports whether the user may see the collection
+func (c *Collection) CanView(userID string) bool {
+	return c.Visibility != VisibilityPrivate || c.UserID == userID
+}
@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// CopyrightStatus is the outcome of matching a media item's audio
+// against the copyright reference set
+type CopyrightStatus string
+
+const (
+	// CopyrightStatusClear audio matched no reference
+	CopyrightStatusClear CopyrightStatus = "clear"
+	// CopyrightStatusConflict audio matched at least one reference
+	CopyrightStatusConflict CopyrightStatus = "conflict"
+	// CopyrightStatusError audio could not be fingerprinted
+	CopyrightStatusError CopyrightStatus = "error"
+)
+
+// CopyrightMatch is a reference recording a media item's audio matched
+type CopyrightMatch struct {
+	ReferenceID string `json:"reference_id" dynamodbav:"reference_id"`
+	Title       string `json:"title,omitempty" dynamodbav:"title,omitempty"`
+	// Score is how similar the audio is, from 0 to 1
+	Score float64 `json:"score" dynamodbav:"score"`
+	// Offset is where in seconds the reference lines up with the media's
+	// audio; negative when the media starts partway into the reference
+	Offset float64 `json:"offset" dynamodbav:"offset"`
+}
+
+// CopyrightCheck is the result of fingerprinting a media item's audio and
+// matching it against the reference set
+type CopyrightCheck struct {
+	Status  CopyrightStatus  `json:"status" dynamodbav:"status"`
+	Matches []CopyrightMatch `json:"matches,omitempty" dynamodbav:"matches,omitempty"`
+	// Error is why fingerprinting failed, if it did
+	Error     string    `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at" dynamodbav:"checked_at"`
+}
@@ -0,0 +1,37 @@
+package domain
+
+// DRMSystem is a DRM system licensing Common Encryption keys
+type DRMSystem string
+
+const (
+	DRMSystemWidevine  DRMSystem = "widevine"
+	DRMSystemPlayReady DRMSystem = "playready"
+)
+
+// drmSystemIDs are the DASH-IF system IDs of the supported DRM systems
+var drmSystemIDs = map[DRMSystem]string{
+	DRMSystemWidevine:  "edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",
+	DRMSystemPlayReady: "9a04f079-9840-4286-ab92-e65be0885f95",
+}
+
+// IsValid returns true if the DRM system is supported
+func (s DRMSystem) IsValid() bool {
+	_, ok := drmSystemIDs[s]
+	return ok
+}
+
+// SystemID returns the DASH-IF system ID of the DRM system, or empty
+func (s DRMSystem) SystemID() string {
+	return drmSystemIDs[s]
+}
+
+// DRMInfo describes the Common Encryption of an output version's
+// segments
+type DRMInfo struct {
+	// Scheme is the protection scheme, "cenc"
+	Scheme string `json:"scheme" dynamodbav:"scheme"`
+	// KeyIDs are the IDs of the content keys, as UUIDs
+	KeyIDs []string `json:"key_ids" dynamodbav:"key_ids"`
+	// Systems are the DRM systems licensing the keys
+	Systems []DRMSystem `json:"systems" dynamodbav:"systems"`
+}
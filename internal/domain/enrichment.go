@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// Chapter is a titled section of a media item
+type Chapter struct {
+	// Start is where the chapter begins in seconds
+	Start float64 `json:"start" dynamodbav:"start"`
+	Title string  `json:"title" dynamodbav:"title"`
+}
+
+// Enrichment is the summary and chapters generated from a media item's
+// transcript
+type Enrichment struct {
+	Summary  string    `json:"summary,omitempty" dynamodbav:"summary,omitempty"`
+	Chapters []Chapter `json:"chapters,omitempty" dynamodbav:"chapters,omitempty"`
+	// Track is the ID of the subtitle track used as the transcript
+	Track string `json:"track" dynamodbav:"track"`
+	// Provider is the service that generated it
+	Provider    string    `json:"provider,omitempty" dynamodbav:"provider,omitempty"`
+	GeneratedAt time.Time `json:"generated_at" dynamodbav:"generated_at"`
+}
+
+// GetChaptersKey returns the key for the media's WebVTT chapters track
+func (m *Media) GetChaptersKey() string {
+	return m.GetOutputPrefix() + "chapters.vtt"
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Episode makes an audio media item a podcast episode of the channel it
+// is published to
+type Episode struct {
+	// PublishAt is when the episode is released; until then only the
+	// channel owner sees it
+	PublishAt time.Time `json:"publish_at" dynamodbav:"publish_at"`
+	Season    int       `json:"season,omitempty" dynamodbav:"season,omitempty"`
+	Number    int       `json:"number,omitempty" dynamodbav:"number,omitempty"`
+	Explicit  bool      `json:"explicit" dynamodbav:"explicit"`
+}
+
+// IsReleased returns true once the episode's publish date has passed
+func (e *Episode) IsReleased(now time.Time) bool {
+	return !e.PublishAt.After(now)
+}
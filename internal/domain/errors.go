@@ -4,14 +4,93 @@ import "errors"
 
 // Common domain errors.
 var (
-	ErrMediaNotFound      = errors.New("media not found")
-	ErrMediaAlreadyExists = errors.New("media already exists")
-	ErrInvalidMediaType   = errors.New("invalid media type")
-	ErrInvalidMediaStatus = errors.New("invalid media status")
-	ErrProcessingFailed   = errors.New("media processing failed")
-	ErrUploadFailed       = errors.New("media upload failed")
-	ErrStorageError       = errors.New("storage error")
-	ErrDatabaseError      = errors.New("database error")
-	ErrUnauthorized       = errors.New("unauthorized access")
-	ErrInvalidInput       = errors.New("invalid input")
+	ErrMediaNotFound       = errors.New("media not found")
+	ErrMediaAlreadyExists  = errors.New("media already exists")
+	ErrInvalidMediaType    = errors.New("invalid media type")
+	ErrInvalidMediaStatus  = errors.New("invalid media status")
+	ErrProcessingFailed    = errors.New("media processing failed")
+	ErrUploadFailed        = errors.New("media upload failed")
+	ErrStorageError        = errors.New("storage error")
+	ErrDatabaseError       = errors.New("database error")
+	ErrUnauthorized        = errors.New("unauthorized access")
+	ErrInvalidInput        = errors.New("invalid input")
+	ErrKeyNotFound         = errors.New("content key not found")
+	ErrStreamNotFound      = errors.New("live stream not found")
+	ErrStreamAlreadyLive   = errors.New("live stream is already live")
+	ErrStreamNotLive       = errors.New("live stream is not live")
+	ErrSessionNotFound     = errors.New("ingest session not found")
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrRateLimited         = errors.New("rate limit exceeded")
+	ErrCollectionNotFound  = errors.New("collection not found")
+	ErrChannelNotFound     = errors.New("channel not found")
+	ErrMediaBusy           = errors.New("media is being processed")
+	ErrQueueUnavailable    = errors.New("job queue unavailable")
+	ErrIdempotencyKeyUsed  = errors.New("idempotency key already used")
+	ErrIdempotencyReused   = errors.New("idempotency key reused with a different request")
+	ErrRequestInProgress   = errors.New("request with the same idempotency key in progress")
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrSubtitleNotFound    = errors.New("subtitle track not found")
+	ErrLegalHold           = errors.New("media is under legal hold")
+	ErrExportNotFound      = errors.New("export not found")
+	ErrVersionNotFound     = errors.New("output version not found")
+	ErrVersionConflict     = errors.New("active output version changed")
+	ErrDestinationNotFound = errors.New("restream destination not found")
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrJobNotFound         = errors.New("job not found")
+	ErrFileTypeNotAllowed  = errors.New("file type not allowed")
+	ErrUploadNotFound      = errors.New("upload not found")
 )
+
+// errorCodes are the stable machine-readable codes reported to API
+// clients for each domain error
+var errorCodes = map[error]string{
+	ErrMediaNotFound:       "media_not_found",
+	ErrMediaAlreadyExists:  "media_already_exists",
+	ErrInvalidMediaType:    "invalid_media_type",
+	ErrInvalidMediaStatus:  "invalid_media_status",
+	ErrProcessingFailed:    "processing_failed",
+	ErrUploadFailed:        "upload_failed",
+	ErrStorageError:        "storage_error",
+	ErrDatabaseError:       "database_error",
+	ErrUnauthorized:        "access_denied",
+	ErrInvalidInput:        "invalid_input",
+	ErrKeyNotFound:         "content_key_not_found",
+	ErrStreamNotFound:      "stream_not_found",
+	ErrStreamAlreadyLive:   "stream_already_live",
+	ErrStreamNotLive:       "stream_not_live",
+	ErrSessionNotFound:     "session_not_found",
+	ErrAPIKeyNotFound:      "api_key_not_found",
+	ErrRateLimited:         "rate_limited",
+	ErrCollectionNotFound:  "collection_not_found",
+	ErrChannelNotFound:     "channel_not_found",
+	ErrMediaBusy:           "media_busy",
+	ErrQueueUnavailable:    "queue_unavailable",
+	ErrIdempotencyKeyUsed:  "idempotency_key_used",
+	ErrIdempotencyReused:   "idempotency_key_reused",
+	ErrRequestInProgress:   "request_in_progress",
+	ErrQuotaExceeded:       "quota_exceeded",
+	ErrSubtitleNotFound:    "subtitle_not_found",
+	ErrLegalHold:           "legal_hold",
+	ErrExportNotFound:      "export_not_found",
+	ErrVersionNotFound:     "version_not_found",
+	ErrVersionConflict:     "version_conflict",
+	ErrDestinationNotFound: "destination_not_found",
+	ErrWebhookNotFound:     "webhook_not_found",
+	ErrJobNotFound:         "job_not_found",
+	ErrFileTypeNotAllowed:  "file_type_not_allowed",
+	ErrUploadNotFound:      "upload_not_found",
+}
+
+// ErrorCode returns the machine-readable code of a domain error, or of
+// the domain error it wraps, and "" for other errors
+func ErrorCode(err error) string {
+	if code, ok := errorCodes[err]; ok {
+		return code
+	}
+	for target, code := range errorCodes {
+		if errors.Is(err, target) {
+			return code
+		}
+	}
+	return ""
+}
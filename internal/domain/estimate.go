@@ -0,0 +1,33 @@
+package domain
+
+// TranscodeEstimate forecasts what processing a source with a rendition
+// ladder will cost, before it is uploaded or reprocessed
+type TranscodeEstimate struct {
+	// Duration, Width and Height describe the probed source
+	Duration   float64             `json:"duration"`
+	Width      int                 `json:"width"`
+	Height     int                 `json:"height"`
+	Renditions []RenditionEstimate `json:"renditions"`
+	// OutputBytes is the total size of the renditions' segments
+	OutputBytes int64 `json:"output_bytes"`
+	// TranscodeSeconds is the worker time to produce every rendition
+	TranscodeSeconds float64 `json:"transcode_seconds"`
+	// QuotaMinutes is what processing counts against the tenant's
+	// transcode minute quota
+	QuotaMinutes float64 `json:"quota_minutes"`
+}
+
+// RenditionEstimate forecasts the cost of one rendition of a ladder
+type RenditionEstimate struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Codec  string `json:"codec"`
+	// Bitrate is the combined video and audio bitrate in bits per second
+	Bitrate          int     `json:"bitrate"`
+	OutputBytes      int64   `json:"output_bytes"`
+	TranscodeSeconds float64 `json:"transcode_seconds"`
+	// Upscaled is set when the rendition is larger than the source, so
+	// dropping it saves cost without losing quality
+	Upscaled bool `json:"upscaled,omitempty"`
+}
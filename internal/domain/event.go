@@ -0,0 +1,58 @@
+package domain
+
+import "time"
+
+// EventType identifies a media lifecycle event
+type EventType string
+
+const (
+	// EventMediaCreated is emitted when a media item is uploaded
+	EventMediaCreated EventType = "media.created"
+	// EventMediaProcessing is emitted when the worker starts processing
+	// a media item, on each attempt
+	EventMediaProcessing EventType = "media.processing"
+	// EventMediaProcessed is emitted once a media item is ready to play
+	EventMediaProcessed EventType = "media.processed"
+	// EventMediaFailed is emitted once processing has failed for good,
+	// after its last retry
+	EventMediaFailed EventType = "media.failed"
+	// EventMediaDeleted is emitted when a media item is deleted
+	EventMediaDeleted EventType = "media.deleted"
+)
+
+// Event is something that happened to a media item
+type Event struct {
+	ID       string    `json:"id" dynamodbav:"id"`
+	Type     EventType `json:"type" dynamodbav:"type"`
+	MediaID  string    `json:"media_id" dynamodbav:"media_id"`
+	TenantID string    `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	// UserID is the media's owner, where the event's source knows it
+	UserID string `json:"user_id,omitempty" dynamodbav:"user_id,omitempty"`
+	// Error is why processing failed, on media.failed
+	Error string    `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	Time  time.Time `json:"time" dynamodbav:"time"`
+}
+
+// OutboxStatus is whether an outbox entry has been delivered
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusDelivered OutboxStatus = "delivered"
+	// OutboxStatusFailed entries failed delivery too often and are no
+	// longer retried
+	OutboxStatusFailed OutboxStatus = "failed"
+)
+
+// OutboxEntry is an event written with the change it describes, waiting
+// for the relay to deliver it
+type OutboxEntry struct {
+	ID     string       `json:"id" dynamodbav:"id"`
+	Status OutboxStatus `json:"status" dynamodbav:"status"`
+	Event  Event        `json:"event" dynamodbav:"event"`
+	// Attempts counts failed deliveries, and LastError is the latest
+	Attempts    int        `json:"attempts" dynamodbav:"attempts"`
+	LastError   string     `json:"last_error,omitempty" dynamodbav:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" dynamodbav:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty" dynamodbav:"delivered_at,omitempty"`
+}
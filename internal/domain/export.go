@@ -0,0 +1,52 @@
+package domain
+
+import "time"
+
+// ExportStatus is where a media export is
+type ExportStatus string
+
+const (
+	ExportStatusPending   ExportStatus = "pending"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+)
+
+// ExportFormat is how the media itself is packaged in an export
+type ExportFormat string
+
+const (
+	// ExportFormatSource packages the uploaded source file as it is
+	ExportFormatSource ExportFormat = "source"
+	// ExportFormatMP4 packages the highest rendition remuxed into an MP4
+	ExportFormatMP4 ExportFormat = "mp4"
+)
+
+// IsValid returns true if the export format is known
+func (f ExportFormat) IsValid() bool {
+	return f == ExportFormatSource || f == ExportFormatMP4
+}
+
+// MediaExport is a zip package of a media item built for download: its
+// source or an MP4, manifests, captions and metadata
+type MediaExport struct {
+	ID     string       `json:"id" dynamodbav:"id"`
+	Status ExportStatus `json:"status" dynamodbav:"status"`
+	Format ExportFormat `json:"format" dynamodbav:"format"`
+	// Key is where the package is stored in the raw media bucket
+	Key  string `json:"-" dynamodbav:"key,omitempty"`
+	Size int64  `json:"size,omitempty" dynamodbav:"size,omitempty"`
+	// Error is why the export failed, if it did
+	Error       string     `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	RequestedAt time.Time  `json:"requested_at" dynamodbav:"requested_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" dynamodbav:"completed_at,omitempty"`
+
+	// URL downloads a completed package until URLExpiresAt; it is
+	// presigned when the export is read, not stored
+	URL          string     `json:"url,omitempty" dynamodbav:"-"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty" dynamodbav:"-"`
+}
+
+// GetExportKey returns the key of an export package of the media
+func (m *Media) GetExportKey(exportID string) string {
+	return "exports/" + m.GetOutputPrefix() + exportID + ".zip"
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// IdempotencyRecord remembers a request made with an Idempotency-Key and,
+// once it has finished, its response so retries can be replayed
+type IdempotencyRecord struct {
+	// Key is the caller's Idempotency-Key scoped to the caller
+	Key string `json:"key" dynamodbav:"id"`
+	// Fingerprint hashes the request the key was first used with
+	Fingerprint string `json:"fingerprint" dynamodbav:"fingerprint"`
+	// Completed is false while the original request is still running
+	Completed bool              `json:"completed" dynamodbav:"completed"`
+	Status    int               `json:"status,omitempty" dynamodbav:"status,omitempty"`
+	Header    map[string]string `json:"header,omitempty" dynamodbav:"header,omitempty"`
+	Body      []byte            `json:"body,omitempty" dynamodbav:"body,omitempty"`
+	CreatedAt time.Time         `json:"created_at" dynamodbav:"created_at"`
+	// ExpiresAt is a Unix timestamp after which the key may be reused
+	ExpiresAt int64 `json:"expires_at" dynamodbav:"expires_at"`
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+// JobStatus is where a processing job is
+type JobStatus string
+
+const (
+	// JobStatusQueued jobs are waiting for a worker
+	JobStatusQueued     JobStatus = "queued"
+	JobStatusProcessing JobStatus = "processing"
+	// JobStatusRetrying jobs failed an attempt and were queued again
+	JobStatusRetrying  JobStatus = "retrying"
+	JobStatusSucceeded JobStatus = "succeeded"
+	// JobStatusFailed jobs failed their last attempt and were moved to
+	// the dead letter queue
+	JobStatusFailed JobStatus = "failed"
+)
+
+// Job is the record of a queued processing job, kept after it finishes as
+// the history of its media
+type Job struct {
+	ID       string    `json:"id" dynamodbav:"id"`
+	Type     string    `json:"type" dynamodbav:"type"`
+	MediaID  string    `json:"media_id" dynamodbav:"media_id"`
+	TenantID string    `json:"-" dynamodbav:"tenant_id,omitempty"`
+	Status   JobStatus `json:"status" dynamodbav:"status"`
+	// Attempts counts the times a worker has started the job
+	Attempts int `json:"attempts" dynamodbav:"attempts"`
+	// Error is why the latest failed attempt failed
+	Error string `json:"error,omitempty" dynamodbav:"error,omitempty"`
+
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+	// RunAt is when a scheduled job is due to be processed
+	RunAt *time.Time `json:"run_at,omitempty" dynamodbav:"run_at,omitempty"`
+	// StartedAt is when the latest attempt started
+	StartedAt  *time.Time `json:"started_at,omitempty" dynamodbav:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" dynamodbav:"finished_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at" dynamodbav:"updated_at"`
+	// ExpiresAt is the Unix time a finished job's record is deleted
+	ExpiresAt int64 `json:"-" dynamodbav:"expires_at,omitempty"`
+}
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/streaming-service/internal/tenant"
+)
+
+// LiveStreamStatus represents the state of a live stream
+type LiveStreamStatus string
+
+const (
+	LiveStreamStatusIdle  LiveStreamStatus = "idle"
+	LiveStreamStatusLive  LiveStreamStatus = "live"
+	LiveStreamStatusEnded LiveStreamStatus = "ended"
+)
+
+// LivePrefix is the storage prefix under which live output is published
+const LivePrefix = "live/"
+
+// LivePreviewFile is the name of the periodically refreshed preview image
+const LivePreviewFile = "preview.jpg"
+
+// IngestProtocol identifies how a live stream is contributed
+type IngestProtocol string
+
+const (
+	IngestProtocolWHIP IngestProtocol = "whip"
+	IngestProtocolRTMP IngestProtocol = "rtmp"
+)
+
+// LiveStream represents a live channel that a broadcaster publishes to
+// with its stream key
+type LiveStream struct {
+	ID          string           `json:"id" dynamodbav:"id"`
+	Title       string           `json:"title" dynamodbav:"title"`
+	Description string           `json:"description" dynamodbav:"description"`
+	Status      LiveStreamStatus `json:"status" dynamodbav:"status"`
+
+	// StreamKey authenticates the broadcaster and must be kept secret
+	StreamKey string         `json:"stream_key" dynamodbav:"stream_key"`
+	Protocol  IngestProtocol `json:"ingest_protocol,omitempty" dynamodbav:"ingest_protocol,omitempty"`
+
+	// DVRWindow is the seekable window in seconds; zero disables DVR
+	DVRWindow int `json:"dvr_window" dynamodbav:"dvr_window"`
+
+	// Ingest health of the current or last session
+	Health *LiveHealth `json:"health,omitempty" dynamodbav:"health,omitempty"`
+	// Disconnects counts encoder disconnects over the stream's lifetime
+	Disconnects int64 `json:"disconnects" dynamodbav:"disconnects"`
+
+	// Destinations are external RTMP endpoints the stream is pushed to
+	// while it's live
+	Destinations []RestreamDestination `json:"destinations,omitempty" dynamodbav:"destinations,omitempty"`
+	// Restreams is the push status of each destination by ID, reported by
+	// the instance running the current or last session
+	Restreams map[string]*RestreamStatus `json:"restreams,omitempty" dynamodbav:"restreams,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+	StartedAt time.Time `json:"started_at,omitempty" dynamodbav:"started_at,omitempty"`
+	EndedAt   time.Time `json:"ended_at,omitempty" dynamodbav:"ended_at,omitempty"`
+
+	// User info
+	UserID string `json:"user_id" dynamodbav:"user_id"`
+	// TenantID is the customer the stream belongs to; empty means the
+	// default tenant
+	TenantID string `json:"-" dynamodbav:"tenant_id,omitempty"`
+}
+
+// LiveHealth is a snapshot of ingest health for a live session
+type LiveHealth struct {
+	// Bitrate is the ingest bitrate in bits per second
+	Bitrate       int64 `json:"bitrate" dynamodbav:"bitrate"`
+	VideoFrames   int64 `json:"video_frames" dynamodbav:"video_frames"`
+	DroppedFrames int64 `json:"dropped_frames" dynamodbav:"dropped_frames"`
+	PacketsLost   int64 `json:"packets_lost" dynamodbav:"packets_lost"`
+	// KeyframeInterval is the average seconds between keyframes
+	KeyframeInterval float64   `json:"keyframe_interval" dynamodbav:"keyframe_interval"`
+	LastKeyframeAt   time.Time `json:"last_keyframe_at,omitempty" dynamodbav:"last_keyframe_at,omitempty"`
+	// Disconnects counts transient connection losses in this session
+	Disconnects int64     `json:"disconnects" dynamodbav:"disconnects"`
+	UpdatedAt   time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// RestreamState is the state of a push to a restream destination
+type RestreamState string
+
+const (
+	RestreamStateConnecting RestreamState = "connecting"
+	RestreamStateLive       RestreamState = "live"
+	RestreamStateFailed     RestreamState = "failed"
+	RestreamStateStopped    RestreamState = "stopped"
+)
+
+// RestreamDestination is an external RTMP endpoint, such as a YouTube or
+// Twitch ingest, that a live stream is pushed to
+type RestreamDestination struct {
+	ID   string `json:"id" dynamodbav:"id"`
+	Name string `json:"name" dynamodbav:"name"`
+	// URL is the rtmp:// or rtmps:// URL to push to, including the
+	// platform's stream key, and must be kept secret
+	URL string `json:"url" dynamodbav:"url"`
+	// Enabled destinations are pushed to whenever the stream is live
+	Enabled   bool      `json:"enabled" dynamodbav:"enabled"`
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+}
+
+// RestreamStatus is the health of the push to a destination
+type RestreamStatus struct {
+	State RestreamState `json:"state" dynamodbav:"state"`
+	// Bitrate is the outgoing bitrate in bits per second
+	Bitrate int64 `json:"bitrate" dynamodbav:"bitrate"`
+	// Speed is how fast media is pushed relative to real time; below 1
+	// the destination is falling behind
+	Speed float64 `json:"speed" dynamodbav:"speed"`
+	// Restarts counts pushes restarted after failing in this session
+	Restarts  int       `json:"restarts" dynamodbav:"restarts"`
+	Error     string    `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	StartedAt time.Time `json:"started_at,omitempty" dynamodbav:"started_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// GetDestination returns the restream destination with the ID, or nil
+func (s *LiveStream) GetDestination(id string) *RestreamDestination {
+	for i := range s.Destinations {
+		if s.Destinations[i].ID == id {
+			return &s.Destinations[i]
+		}
+	}
+	return nil
+}
+
+// IsLive returns true while a broadcaster is publishing
+func (s *LiveStream) IsLive() bool {
+	return s.Status == LiveStreamStatusLive
+}
+
+// GetOutputPrefix returns the storage prefix for the stream's HLS output
+func (s *LiveStream) GetOutputPrefix() string {
+	return tenant.KeyPrefix(s.TenantID) + LivePrefix + s.ID + "/"
+}
+
+// GetMasterPlaylistKey returns the key for the live master HLS playlist
+func (s *LiveStream) GetMasterPlaylistKey() string {
+	return s.GetOutputPrefix() + "master.m3u8"
+}
+
+// GetPreviewKey returns the key for the live preview image
+func (s *LiveStream) GetPreviewKey() string {
+	return s.GetOutputPrefix() + LivePreviewFile
+}
@@ -2,6 +2,8 @@ package domain
 
 import (
 	"time"
+
+	"github.com/streaming-service/internal/tenant"
 )
 
 // MediaType represents the type of media content
@@ -12,6 +14,11 @@ const (
 	MediaTypeAudio MediaType = "audio"
 )
 
+// IsValid reports whether the media type is known
+func (t MediaType) IsValid() bool {
+	return t == MediaTypeVideo || t == MediaTypeAudio
+}
+
 // MediaStatus represents the processing status of media
 type MediaStatus string
 
@@ -20,6 +27,60 @@ const (
 	MediaStatusProcessing MediaStatus = "processing"
 	MediaStatusCompleted  MediaStatus = "completed"
 	MediaStatusFailed     MediaStatus = "failed"
+	// MediaStatusArchived media expired under an archive policy: its
+	// processed files are gone but the record and source are kept
+	MediaStatusArchived MediaStatus = "archived"
+)
+
+// IsValid reports whether the media status is known
+func (s MediaStatus) IsValid() bool {
+	switch s {
+	case MediaStatusPending, MediaStatusProcessing, MediaStatusCompleted, MediaStatusFailed, MediaStatusArchived:
+		return true
+	}
+	return false
+}
+
+// MediaFilter narrows and orders a media listing. Zero values match
+// everything.
+type MediaFilter struct {
+	Status MediaStatus
+	Type   MediaType
+	// Tag matches media carrying the tag key, or "key:value" for a value
+	Tag           string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Ascending lists oldest first; listings are newest first by default
+	Ascending bool
+}
+
+// Visibility controls who can find and play a media item
+type Visibility string
+
+const (
+	// VisibilityPublic media is listed and playable by anyone
+	VisibilityPublic Visibility = "public"
+	// VisibilityUnlisted media is playable by anyone with its ID but is
+	// left out of public listings
+	VisibilityUnlisted Visibility = "unlisted"
+	// VisibilityPrivate media is only visible to its owner
+	VisibilityPrivate Visibility = "private"
+)
+
+// IsValid reports whether v is a known visibility
+func (v Visibility) IsValid() bool {
+	switch v {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// Length limits in characters for titles and descriptions of media,
+// collections, channels and live streams
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 5000
 )
 
 // Media represents a media item (video or audio)
@@ -29,15 +90,36 @@ type Media struct {
 	Description string      `json:"description" dynamodbav:"description"`
 	Type        MediaType   `json:"type" dynamodbav:"type"`
 	Status      MediaStatus `json:"status" dynamodbav:"status"`
+	Visibility  Visibility  `json:"visibility" dynamodbav:"visibility,omitempty"`
 
 	// Source file info
 	SourceKey    string `json:"source_key" dynamodbav:"source_key"`
 	SourceBucket string `json:"source_bucket" dynamodbav:"source_bucket"`
 	SourceSize   int64  `json:"source_size" dynamodbav:"source_size"`
 	SourceFormat string `json:"source_format" dynamodbav:"source_format"`
+	// OutputSize is the total size of the processed files, counted against
+	// the tenant's storage quota with SourceSize
+	OutputSize int64 `json:"output_size,omitempty" dynamodbav:"output_size,omitempty"`
 
 	// Processed outputs
-	Renditions []Rendition `json:"renditions" dynamodbav:"renditions"`
+	Renditions []Rendition `json:"renditions" dynamodbav:"renditions,omitempty"`
+	// Processing tracks the worker through each stage of processing
+	Processing *ProcessingProgress `json:"processing,omitempty" dynamodbav:"processing,omitempty"`
+	// Retries are the latest retries of failed processing, oldest first
+	Retries []ProcessingRetry `json:"retries,omitempty" dynamodbav:"retries,omitempty"`
+	// CoverArtKey is the largest size of the cover art in the processed
+	// bucket, when the source has any
+	CoverArtKey string `json:"cover_art_key,omitempty" dynamodbav:"cover_art_key,omitempty"`
+	// DASHKey is the DASH manifest in the processed bucket, when the
+	// renditions are packaged as CMAF
+	DASHKey string `json:"dash_key,omitempty" dynamodbav:"dash_key,omitempty"`
+	// StoryboardKey is the WebVTT file mapping playback time to thumbnail
+	// sprite sheets, when the video has them
+	StoryboardKey string `json:"storyboard_key,omitempty" dynamodbav:"storyboard_key,omitempty"`
+	// OutputVersion is the number of the output version the renditions
+	// and cover art above are from; Versions holds every version kept
+	OutputVersion int             `json:"output_version,omitempty" dynamodbav:"output_version,omitempty"`
+	Versions      []OutputVersion `json:"-" dynamodbav:"versions,omitempty"`
 
 	// Metadata
 	Duration float64           `json:"duration" dynamodbav:"duration"`
@@ -46,6 +128,39 @@ type Media struct {
 	Bitrate  int               `json:"bitrate,omitempty" dynamodbav:"bitrate,omitempty"`
 	Codec    string            `json:"codec,omitempty" dynamodbav:"codec,omitempty"`
 	Tags     map[string]string `json:"tags,omitempty" dynamodbav:"tags,omitempty"`
+	// SourceMetadata is everything probing the source found, which the
+	// fields above summarize
+	SourceMetadata *SourceMetadata `json:"source_metadata,omitempty" dynamodbav:"source_metadata,omitempty"`
+
+	// Content protection
+	Encryption *EncryptionInfo `json:"encryption,omitempty" dynamodbav:"encryption,omitempty"`
+	// DRM is the Common Encryption of the active output version, when
+	// its segments are protected
+	DRM *DRMInfo `json:"drm,omitempty" dynamodbav:"drm,omitempty"`
+
+	// Advertising
+	AdBreaks []AdBreak `json:"ad_breaks,omitempty" dynamodbav:"ad_breaks,omitempty"`
+
+	// Engagement
+	ViewCount int64 `json:"view_count" dynamodbav:"view_count"`
+	LikeCount int64 `json:"like_count" dynamodbav:"like_count"`
+
+	// Retention
+	// ExpiresAt is when the media is deleted or archived, from the tenant's
+	// retention policy or set on the media
+	ExpiresAt *time.Time `json:"expires_at,omitempty" dynamodbav:"expires_at,omitempty"`
+	// Expiring is set while ExpiresAt is, putting the media in the index
+	// the retention job reads
+	Expiring string `json:"-" dynamodbav:"expiring,omitempty"`
+	// ExpiryWarnedAt is when the warning before expiry was sent
+	ExpiryWarnedAt *time.Time `json:"-" dynamodbav:"expiry_warned_at,omitempty"`
+	ArchivedAt     *time.Time `json:"archived_at,omitempty" dynamodbav:"archived_at,omitempty"`
+	// LegalHold keeps the media from being deleted or expired until an
+	// admin releases it
+	LegalHold *LegalHold `json:"legal_hold,omitempty" dynamodbav:"legal_hold,omitempty"`
+
+	// Export is the latest package of the media requested for download
+	Export *MediaExport `json:"-" dynamodbav:"export,omitempty"`
 
 	// Timestamps
 	CreatedAt   time.Time `json:"created_at" dynamodbav:"created_at"`
@@ -54,6 +169,39 @@ type Media struct {
 
 	// User info
 	UserID string `json:"user_id" dynamodbav:"user_id"`
+	// TenantID is the customer the media belongs to; it is empty on
+	// records written before tenancy, which belong to the default tenant
+	TenantID string `json:"-" dynamodbav:"tenant_id,omitempty"`
+
+	// ChannelID is the channel the media is published to, if any
+	ChannelID string `json:"channel_id,omitempty" dynamodbav:"channel_id,omitempty"`
+	// Episode is set on audio published to the channel as a podcast
+	// episode
+	Episode *Episode `json:"episode,omitempty" dynamodbav:"episode,omitempty"`
+
+	// Moderation is the result of the content policy scan, when
+	// moderation is enabled. ModerationStatus repeats its status at the
+	// top level for the moderation index.
+	Moderation       *Moderation      `json:"moderation,omitempty" dynamodbav:"moderation,omitempty"`
+	ModerationStatus ModerationStatus `json:"-" dynamodbav:"moderation_status,omitempty"`
+
+	// Copyright is the result of matching the audio's fingerprint
+	// against the copyright reference set, when fingerprinting is enabled
+	Copyright *CopyrightCheck `json:"copyright,omitempty" dynamodbav:"copyright,omitempty"`
+
+	// Subtitles are the subtitle tracks listed in the master playlist
+	Subtitles []SubtitleTrack `json:"subtitles,omitempty" dynamodbav:"subtitles,omitempty"`
+
+	// Enrichment is the summary and chapters generated from a transcript
+	Enrichment *Enrichment `json:"enrichment,omitempty" dynamodbav:"enrichment,omitempty"`
+
+	// TranscriptFlags is what filtering the generated captions found
+	TranscriptFlags *TranscriptFlags `json:"transcript_flags,omitempty" dynamodbav:"transcript_flags,omitempty"`
+
+	// AutoCaptions requests captions transcribed from the speech once
+	// the media is processed; Captioning tracks them
+	AutoCaptions bool        `json:"auto_captions,omitempty" dynamodbav:"auto_captions,omitempty"`
+	Captioning   *Captioning `json:"captioning,omitempty" dynamodbav:"captioning,omitempty"`
 }
 
 // Rendition represents a processed version of media
@@ -67,6 +215,13 @@ type Rendition struct {
 	SegmentPrefix string `json:"segment_prefix" dynamodbav:"segment_prefix"`
 }
 
+// EncryptionInfo describes how a media's segments are encrypted
+type EncryptionInfo struct {
+	Method         string   `json:"method" dynamodbav:"method"`
+	KeyIDs         []string `json:"key_ids" dynamodbav:"key_ids"`
+	SegmentsPerKey int      `json:"segments_per_key" dynamodbav:"segments_per_key"`
+}
+
 // Video is a specialized Media type for video content
 type Video struct {
 	Media
@@ -78,34 +233,115 @@ type Video struct {
 // Audio is a specialized Media type for audio content
 type Audio struct {
 	Media
-	Artist      string `json:"artist,omitempty" dynamodbav:"artist,omitempty"`
-	Album       string `json:"album,omitempty" dynamodbav:"album,omitempty"`
-	Genre       string `json:"genre,omitempty" dynamodbav:"genre,omitempty"`
-	SampleRate  int    `json:"sample_rate,omitempty" dynamodbav:"sample_rate,omitempty"`
-	Channels    int    `json:"channels,omitempty" dynamodbav:"channels,omitempty"`
-	CoverArtKey string `json:"cover_art_key,omitempty" dynamodbav:"cover_art_key,omitempty"`
+	Artist     string `json:"artist,omitempty" dynamodbav:"artist,omitempty"`
+	Album      string `json:"album,omitempty" dynamodbav:"album,omitempty"`
+	Genre      string `json:"genre,omitempty" dynamodbav:"genre,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty" dynamodbav:"sample_rate,omitempty"`
+	Channels   int    `json:"channels,omitempty" dynamodbav:"channels,omitempty"`
 }
 
 // NewMedia creates a new Media with initialized fields
 func NewMedia(id, title, userID string, mediaType MediaType) *Media {
 	now := time.Now()
 	return &Media{
-		ID:        id,
-		Title:     title,
-		UserID:    userID,
-		Type:      mediaType,
-		Status:    MediaStatusPending,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:         id,
+		Title:      title,
+		UserID:     userID,
+		Type:       mediaType,
+		Status:     MediaStatusPending,
+		Visibility: VisibilityPublic,
+		CreatedAt:  now,
+		UpdatedAt:  now,
 	}
 }
 
+// SetExpiration sets or, with nil, clears when the media expires. Times
+// are kept in UTC to the second so they sort as strings in the index.
+func (m *Media) SetExpiration(at *time.Time) {
+	m.ExpiryWarnedAt = nil
+	if at == nil {
+		m.ExpiresAt, m.Expiring = nil, ""
+		return
+	}
+	t := at.UTC().Truncate(time.Second)
+	m.ExpiresAt, m.Expiring = &t, ExpiringIndexKey
+}
+
 // IsProcessed returns true if media has been successfully processed
 func (m *Media) IsProcessed() bool {
 	return m.Status == MediaStatusCompleted && len(m.Renditions) > 0
 }
 
+// GetVisibility returns the media's visibility. Records created before
+// visibility existed are public.
+func (m *Media) GetVisibility() Visibility {
+	if m.Visibility == "" {
+		return VisibilityPublic
+	}
+	return m.Visibility
+}
+
+// CanView reports whether the user may see and play the media. Media held
+// by moderation is only visible to its owner.
+func (m *Media) CanView(userID string) bool {
+	return m.UserID == userID || (m.GetVisibility() != VisibilityPrivate && !m.IsHeld())
+}
+
+// IsListed reports whether the media may appear in public listings
+func (m *Media) IsListed() bool {
+	return m.GetVisibility() == VisibilityPublic && !m.IsHeld()
+}
+
+// IsHeld returns true while moderation holds the media pending review, or
+// after it was rejected
+func (m *Media) IsHeld() bool {
+	return m.Moderation.IsHeld()
+}
+
+// GetOutputPrefix returns the storage prefix for the media's processed
+// output
+func (m *Media) GetOutputPrefix() string {
+	return tenant.KeyPrefix(m.TenantID) + m.ID + "/"
+}
+
+// CoverArtSizes are the sizes in pixels cover art is made in, largest
+// first; each fits within a square of that size
+var CoverArtSizes = []int{1400, 600, 300}
+
+// GetCoverArtKey returns the key for a size of the media's cover art
+func (m *Media) GetCoverArtKey(size int) string {
+	return m.GetVersionCoverArtKey(m.GetActiveVersion(), size)
+}
+
 // GetMasterPlaylistKey returns the key for the master HLS playlist
 func (m *Media) GetMasterPlaylistKey() string {
-	return m.ID + "/master.m3u8"
+	return m.GetOutputPrefix() + "master.m3u8"
+}
+
+// LegalHold records who placed media under legal hold and why
+type LegalHold struct {
+	Reason    string    `json:"reason" dynamodbav:"reason"`
+	AppliedBy string    `json:"applied_by" dynamodbav:"applied_by"`
+	AppliedAt time.Time `json:"applied_at" dynamodbav:"applied_at"`
+}
+
+// ExpiringIndexKey is the value of Media.Expiring on media that expires
+const ExpiringIndexKey = "1"
+
+// ExpirationAction is what happens to media when it expires
+type ExpirationAction string
+
+const (
+	ExpirationActionDelete  ExpirationAction = "delete"
+	ExpirationActionArchive ExpirationAction = "archive"
+)
+
+// ExpirationWarning is sent before media expires
+type ExpirationWarning struct {
+	MediaID   string           `json:"media_id"`
+	TenantID  string           `json:"tenant_id"`
+	UserID    string           `json:"user_id"`
+	Title     string           `json:"title"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	Action    ExpirationAction `json:"action"`
 }
@@ -0,0 +1,59 @@
+package domain
+
+// HDR formats of a video stream
+const (
+	HDRFormatHDR10       = "hdr10"
+	HDRFormatHLG         = "hlg"
+	HDRFormatDolbyVision = "dolby_vision"
+)
+
+// SourceMetadata is what probing a media item's source file found
+type SourceMetadata struct {
+	// Container is the container format, e.g. "mov,mp4,m4a,3gp,3g2,mj2"
+	Container string  `json:"container" dynamodbav:"container"`
+	Duration  float64 `json:"duration" dynamodbav:"duration"`
+	// Bitrate is the overall bitrate in bits per second
+	Bitrate int   `json:"bitrate,omitempty" dynamodbav:"bitrate,omitempty"`
+	Size    int64 `json:"size,omitempty" dynamodbav:"size,omitempty"`
+
+	Video []VideoStreamInfo `json:"video,omitempty" dynamodbav:"video,omitempty"`
+	Audio []AudioStreamInfo `json:"audio,omitempty" dynamodbav:"audio,omitempty"`
+}
+
+// VideoStreamInfo describes a video stream of a source file
+type VideoStreamInfo struct {
+	Index   int    `json:"index" dynamodbav:"index"`
+	Codec   string `json:"codec" dynamodbav:"codec"`
+	Profile string `json:"profile,omitempty" dynamodbav:"profile,omitempty"`
+	// Level is as ffprobe reports it, e.g. 40 for H.264 level 4.0
+	Level       int     `json:"level,omitempty" dynamodbav:"level,omitempty"`
+	Width       int     `json:"width" dynamodbav:"width"`
+	Height      int     `json:"height" dynamodbav:"height"`
+	FrameRate   float64 `json:"frame_rate,omitempty" dynamodbav:"frame_rate,omitempty"`
+	Bitrate     int     `json:"bitrate,omitempty" dynamodbav:"bitrate,omitempty"`
+	PixelFormat string  `json:"pixel_format,omitempty" dynamodbav:"pixel_format,omitempty"`
+	BitDepth    int     `json:"bit_depth,omitempty" dynamodbav:"bit_depth,omitempty"`
+
+	ColorSpace     string `json:"color_space,omitempty" dynamodbav:"color_space,omitempty"`
+	ColorTransfer  string `json:"color_transfer,omitempty" dynamodbav:"color_transfer,omitempty"`
+	ColorPrimaries string `json:"color_primaries,omitempty" dynamodbav:"color_primaries,omitempty"`
+	// HDRFormat is one of the HDRFormat constants, or empty for SDR
+	HDRFormat string `json:"hdr_format,omitempty" dynamodbav:"hdr_format,omitempty"`
+}
+
+// HDR reports whether the stream is high dynamic range
+func (v *VideoStreamInfo) HDR() bool {
+	return v.HDRFormat != ""
+}
+
+// AudioStreamInfo describes an audio stream of a source file
+type AudioStreamInfo struct {
+	Index         int    `json:"index" dynamodbav:"index"`
+	Codec         string `json:"codec" dynamodbav:"codec"`
+	Profile       string `json:"profile,omitempty" dynamodbav:"profile,omitempty"`
+	Channels      int    `json:"channels" dynamodbav:"channels"`
+	ChannelLayout string `json:"channel_layout,omitempty" dynamodbav:"channel_layout,omitempty"`
+	SampleRate    int    `json:"sample_rate" dynamodbav:"sample_rate"`
+	Bitrate       int    `json:"bitrate,omitempty" dynamodbav:"bitrate,omitempty"`
+	Language      string `json:"language,omitempty" dynamodbav:"language,omitempty"`
+}
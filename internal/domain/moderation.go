@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+// ModerationStatus is the outcome of checking media against content
+// policy
+type ModerationStatus string
+
+const (
+	// ModerationStatusApproved media passed the scan or was approved on
+	// review
+	ModerationStatusApproved ModerationStatus = "approved"
+	// ModerationStatusFlagged media had violations found but is not held
+	ModerationStatusFlagged ModerationStatus = "flagged"
+	// ModerationStatusPendingReview media is held until a reviewer
+	// approves or rejects it
+	ModerationStatusPendingReview ModerationStatus = "pending_review"
+	// ModerationStatusRejected media was rejected on review and stays held
+	ModerationStatusRejected ModerationStatus = "rejected"
+)
+
+// IsValid returns true for a known moderation status
+func (s ModerationStatus) IsValid() bool {
+	switch s {
+	case ModerationStatusApproved, ModerationStatusFlagged, ModerationStatusPendingReview, ModerationStatusRejected:
+		return true
+	}
+	return false
+}
+
+// ModerationLabel is a policy violation a scan found
+type ModerationLabel struct {
+	Name string `json:"name" dynamodbav:"name"`
+	// Parent is the label's top-level category, if it has one
+	Parent     string  `json:"parent,omitempty" dynamodbav:"parent,omitempty"`
+	Confidence float64 `json:"confidence" dynamodbav:"confidence"`
+	// Timestamp is the position in seconds of the frame it was found in
+	Timestamp float64 `json:"timestamp,omitempty" dynamodbav:"timestamp,omitempty"`
+	// Source is what was scanned: "frame" or "audio"
+	Source string `json:"source" dynamodbav:"source"`
+}
+
+// Moderation is the result of scanning a media item and of any review
+type Moderation struct {
+	Status ModerationStatus `json:"status" dynamodbav:"status"`
+	// Provider is the service that scanned the media
+	Provider string            `json:"provider,omitempty" dynamodbav:"provider,omitempty"`
+	Labels   []ModerationLabel `json:"labels,omitempty" dynamodbav:"labels,omitempty"`
+	// Error is why the scan failed, if it did
+	Error     string     `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	ScannedAt *time.Time `json:"scanned_at,omitempty" dynamodbav:"scanned_at,omitempty"`
+
+	ReviewedBy string     `json:"reviewed_by,omitempty" dynamodbav:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty" dynamodbav:"reviewed_at,omitempty"`
+}
+
+// IsHeld returns true while media is kept from everyone but its owner
+func (m *Moderation) IsHeld() bool {
+	return m != nil && (m.Status == ModerationStatusPendingReview || m.Status == ModerationStatusRejected)
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// UserPreferences are a user's settings
+type UserPreferences struct {
+	UserID        string                  `json:"user_id" dynamodbav:"user_id"`
+	TenantID      string                  `json:"-" dynamodbav:"tenant_id,omitempty"`
+	Notifications NotificationPreferences `json:"notifications" dynamodbav:"notifications"`
+	UpdatedAt     time.Time               `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// NotificationPreferences choose the emails a user gets about their media
+type NotificationPreferences struct {
+	// Email is where notifications go; none are sent without one
+	Email string `json:"email" dynamodbav:"email"`
+	// MediaProcessed and MediaFailed send an email when processing an
+	// upload completes or fails
+	MediaProcessed bool `json:"media_processed" dynamodbav:"media_processed"`
+	MediaFailed    bool `json:"media_failed" dynamodbav:"media_failed"`
+}
+
+// DefaultPreferences are the preferences of a user who saved none: both
+// notifications on, once an email is given
+func DefaultPreferences(userID string) *UserPreferences {
+	return &UserPreferences{
+		UserID: userID,
+		Notifications: NotificationPreferences{
+			MediaProcessed: true,
+			MediaFailed:    true,
+		},
+	}
+}
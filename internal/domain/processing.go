@@ -0,0 +1,99 @@
+package domain
+
+import "time"
+
+// ProcessingStage is a step of the media processing pipeline
+type ProcessingStage string
+
+const (
+	ProcessingStageQueued         ProcessingStage = "queued"
+	ProcessingStageDownloading    ProcessingStage = "downloading"
+	ProcessingStageTranscoding    ProcessingStage = "transcoding"
+	ProcessingStageModerating     ProcessingStage = "moderating"
+	ProcessingStageFingerprinting ProcessingStage = "fingerprinting"
+	ProcessingStageEncrypting     ProcessingStage = "encrypting"
+	ProcessingStageUploading      ProcessingStage = "uploading"
+	ProcessingStagePublishing     ProcessingStage = "publishing"
+	ProcessingStageCompleted      ProcessingStage = "completed"
+	ProcessingStageFailed         ProcessingStage = "failed"
+)
+
+// RenditionStatus is how far a rendition's transcode has got
+type RenditionStatus string
+
+const (
+	RenditionStatusPending     RenditionStatus = "pending"
+	RenditionStatusTranscoding RenditionStatus = "transcoding"
+	RenditionStatusDone        RenditionStatus = "done"
+)
+
+// RenditionProgress is the transcode status of one rendition
+type RenditionProgress struct {
+	Name   string          `json:"name" dynamodbav:"name"`
+	Status RenditionStatus `json:"status" dynamodbav:"status"`
+	// Percent is how far through the source the transcode has got
+	Percent float64 `json:"percent" dynamodbav:"percent"`
+}
+
+// StepStatus is where a step of the processing workflow is
+type StepStatus string
+
+const (
+	StepStatusPending   StepStatus = "pending"
+	StepStatusRunning   StepStatus = "running"
+	StepStatusSucceeded StepStatus = "succeeded"
+	StepStatusFailed    StepStatus = "failed"
+	// StepStatusSkipped steps' conditions didn't hold, such as moderation
+	// not being enabled
+	StepStatusSkipped StepStatus = "skipped"
+)
+
+// WorkflowStep is the status of one step of the processing workflow
+type WorkflowStep struct {
+	Name   string     `json:"name" dynamodbav:"name"`
+	Status StepStatus `json:"status" dynamodbav:"status"`
+	// Attempts counts the times the step ran, including its retries
+	Attempts int `json:"attempts,omitempty" dynamodbav:"attempts,omitempty"`
+	// Error is why the step's latest attempt failed
+	Error      string     `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty" dynamodbav:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" dynamodbav:"finished_at,omitempty"`
+}
+
+// ProcessingProgress is the worker's progress through processing a media
+// item, finer grained than its status
+type ProcessingProgress struct {
+	Stage ProcessingStage `json:"stage" dynamodbav:"stage"`
+	// Renditions is filled in once transcoding starts
+	Renditions []RenditionProgress `json:"renditions,omitempty" dynamodbav:"renditions,omitempty"`
+	// Rendition is the rendition being transcoded
+	Rendition string `json:"rendition,omitempty" dynamodbav:"rendition,omitempty"`
+	// Percent is how much of the transcoding is complete, over every
+	// rendition
+	Percent float64 `json:"percent" dynamodbav:"percent"`
+	// FailedStage is the stage processing failed in
+	FailedStage ProcessingStage `json:"failed_stage,omitempty" dynamodbav:"failed_stage,omitempty"`
+	// Steps are the steps of the processing workflow, in order
+	Steps []WorkflowStep `json:"steps,omitempty" dynamodbav:"steps,omitempty"`
+	// Error is why processing failed, when known, such as its job timing
+	// out
+	Error     string    `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// ProcessingRetry records failed processing being retried
+type ProcessingRetry struct {
+	// JobID is the job the retry queued
+	JobID string `json:"job_id" dynamodbav:"job_id"`
+	// FailedStage is the stage the processing retried had failed in
+	FailedStage ProcessingStage `json:"failed_stage,omitempty" dynamodbav:"failed_stage,omitempty"`
+	RetriedAt   time.Time       `json:"retried_at" dynamodbav:"retried_at"`
+}
+
+// NewProcessingProgress returns progress at the given stage
+func NewProcessingProgress(stage ProcessingStage) *ProcessingProgress {
+	return &ProcessingProgress{
+		Stage:     stage,
+		UpdatedAt: time.Now(),
+	}
+}
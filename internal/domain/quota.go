@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// Quota names a per-tenant usage limit
+type Quota string
+
+const (
+	// QuotaStorage limits the bytes of source and processed files stored
+	QuotaStorage Quota = "storage"
+	// QuotaTranscodeMinutes limits the minutes of media transcoded each
+	// calendar month (UTC)
+	QuotaTranscodeMinutes Quota = "transcode_minutes"
+	// QuotaConcurrentJobs limits the processing jobs queued or running at
+	// once
+	QuotaConcurrentJobs Quota = "concurrent_jobs"
+)
+
+// QuotaUsage is a tenant's usage against its limits. Zero limits are
+// unlimited.
+type QuotaUsage struct {
+	TenantID string `json:"tenant_id"`
+	// Period is the month transcode minutes are counted in, as YYYY-MM
+	Period                string  `json:"period"`
+	StorageBytes          int64   `json:"storage_bytes"`
+	StorageLimit          int64   `json:"storage_limit"`
+	TranscodeMinutes      float64 `json:"transcode_minutes"`
+	TranscodeMinutesLimit int64   `json:"transcode_minutes_limit"`
+	ActiveJobs            int64   `json:"active_jobs"`
+	ConcurrentJobsLimit   int64   `json:"concurrent_jobs_limit"`
+}
+
+// QuotaWarning reports a tenant's usage crossing the warning threshold of
+// one of its limits
+type QuotaWarning struct {
+	TenantID string  `json:"tenant_id"`
+	Quota    Quota   `json:"quota"`
+	Usage    float64 `json:"usage"`
+	Limit    int64   `json:"limit"`
+	// Period is set for monthly quotas
+	Period string    `json:"period,omitempty"`
+	At     time.Time `json:"at"`
+}
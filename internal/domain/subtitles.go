@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// SubtitleSource is where a subtitle track came from
+type SubtitleSource string
+
+const (
+	SubtitleSourceUpload      SubtitleSource = "upload"
+	SubtitleSourceTranscribe  SubtitleSource = "transcribe"
+	SubtitleSourceTranslation SubtitleSource = "translation"
+)
+
+// SubtitleTrack is a WebVTT subtitle rendition of a media item, listed in
+// its master playlist
+type SubtitleTrack struct {
+	// ID names the track in its storage keys; it is the language unless
+	// there are several tracks in one language
+	ID string `json:"id" dynamodbav:"id"`
+	// Language is a BCP 47 language tag such as "en" or "pt-BR"
+	Language string `json:"language" dynamodbav:"language"`
+	// Name is what players show in their subtitle menu
+	Name   string         `json:"name" dynamodbav:"name"`
+	Source SubtitleSource `json:"source" dynamodbav:"source"`
+	// TranslatedFrom is the ID of the track a translation was made from
+	TranslatedFrom string `json:"translated_from,omitempty" dynamodbav:"translated_from,omitempty"`
+	// Default marks the track players select when none is chosen
+	Default   bool      `json:"default,omitempty" dynamodbav:"default,omitempty"`
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+}
+
+// maxSubtitleIDLength bounds subtitle track IDs
+const maxSubtitleIDLength = 64
+
+// subtitleIDPattern is what subtitle track IDs, which name directories,
+// are made of
+var subtitleIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// IsValidSubtitleID reports whether id can identify a subtitle track
+func IsValidSubtitleID(id string) bool {
+	return len(id) <= maxSubtitleIDLength && subtitleIDPattern.MatchString(id)
+}
+
+// GetSubtitleTrack returns the media's subtitle track with an ID, or nil
+func (m *Media) GetSubtitleTrack(id string) *SubtitleTrack {
+	for i := range m.Subtitles {
+		if m.Subtitles[i].ID == id {
+			return &m.Subtitles[i]
+		}
+	}
+	return nil
+}
+
+// GetSubtitleKey returns the key for a subtitle track's WebVTT file
+func (m *Media) GetSubtitleKey(id string) string {
+	return m.GetOutputPrefix() + SubtitlePath(id) + "captions.vtt"
+}
+
+// GetSubtitleSegmentKey returns the key for a segment of a subtitle
+// track's segmented WebVTT
+func (m *Media) GetSubtitleSegmentKey(id string, n int) string {
+	return m.GetOutputPrefix() + SubtitlePath(id) + SubtitleSegmentName(n)
+}
+
+// SubtitleSegmentName returns the file name of a subtitle segment,
+// relative to its playlist
+func SubtitleSegmentName(n int) string {
+	return fmt.Sprintf("segment_%04d.vtt", n)
+}
+
+// GetSubtitlePlaylistKey returns the key for a subtitle track's HLS
+// playlist
+func (m *Media) GetSubtitlePlaylistKey(id string) string {
+	return m.GetOutputPrefix() + SubtitlePath(id) + "playlist.m3u8"
+}
+
+// SubtitlePath returns the directory of a subtitle track's files,
+// relative to the master playlist
+func SubtitlePath(id string) string {
+	return "subtitles/" + id + "/"
+}
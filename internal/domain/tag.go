@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxTagsPerMedia caps the number of tags on a media item
+	MaxTagsPerMedia = 50
+	// MaxTagKeyLength and MaxTagValueLength cap tag sizes in bytes
+	MaxTagKeyLength   = 64
+	MaxTagValueLength = 256
+)
+
+// IsValidTag reports whether a tag key and value may be stored. Keys may
+// not contain ':' as it separates key and value in tag queries.
+func IsValidTag(key, value string) bool {
+	if key == "" || len(key) > MaxTagKeyLength || len(value) > MaxTagValueLength {
+		return false
+	}
+	return !strings.Contains(key, ":")
+}
+
+// TagIndexKeys returns the tag index entries for a set of tags: the bare
+// key, so media can be found by key alone, and "key:value" for tags with
+// a value
+func TagIndexKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags)*2)
+	for key, value := range tags {
+		keys = append(keys, key)
+		if value != "" {
+			keys = append(keys, key+":"+value)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
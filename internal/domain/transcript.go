@@ -0,0 +1,65 @@
+package domain
+
+import "time"
+
+// PIIKind is a kind of personal information found in a transcript
+type PIIKind string
+
+const (
+	PIIKindEmail PIIKind = "email"
+	PIIKindPhone PIIKind = "phone"
+	PIIKindCard  PIIKind = "card"
+	PIIKindSSN   PIIKind = "ssn"
+)
+
+// TranscriptFlags is what filtering a media item's generated captions
+// found
+type TranscriptFlags struct {
+	// Profanity counts the profane words found
+	Profanity int `json:"profanity,omitempty" dynamodbav:"profanity,omitempty"`
+	// PII counts the personal information found, by kind
+	PII map[PIIKind]int `json:"pii,omitempty" dynamodbav:"pii,omitempty"`
+}
+
+// HasFindings returns true if filtering found anything
+func (f *TranscriptFlags) HasFindings() bool {
+	return f != nil && (f.Profanity > 0 || len(f.PII) > 0)
+}
+
+// CaptioningStatus is the state of a media item's automatic captions
+type CaptioningStatus string
+
+const (
+	CaptioningStatusQueued    CaptioningStatus = "queued"
+	CaptioningStatusCompleted CaptioningStatus = "completed"
+	CaptioningStatusFailed    CaptioningStatus = "failed"
+)
+
+// Captioning tracks the automatic captions of a media item uploaded with
+// them requested
+type Captioning struct {
+	Status CaptioningStatus `json:"status" dynamodbav:"status"`
+	// Language is the language of the speech, once transcribed
+	Language string `json:"language,omitempty" dynamodbav:"language,omitempty"`
+	// Track is the ID of the subtitle track the captions were added as
+	Track     string    `json:"track,omitempty" dynamodbav:"track,omitempty"`
+	Error     string    `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// TranscriptWord is a word or punctuation mark of a speech transcript
+type TranscriptWord struct {
+	// Start and End are when the word is spoken; punctuation has neither
+	Start time.Duration
+	End   time.Duration
+	Text  string
+	// Punctuation attaches to the word before it
+	Punctuation bool
+}
+
+// Transcript is the text of a media item's speech, word by word
+type Transcript struct {
+	// Language is the BCP 47 tag of the speech's language
+	Language string
+	Words    []TranscriptWord
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// OutputVersion is one generation of a media item's processed output.
+// Each processing run makes a new one and activates it; an earlier one
+// can be activated again to roll back.
+type OutputVersion struct {
+	Number     int         `json:"number" dynamodbav:"number"`
+	Renditions []Rendition `json:"renditions" dynamodbav:"renditions"`
+	// CoverArtKey is the largest size of the version's cover art, when
+	// it has any
+	CoverArtKey string `json:"-" dynamodbav:"cover_art_key,omitempty"`
+	// DASHKey is the version's DASH manifest, when its renditions are
+	// packaged as CMAF
+	DASHKey string `json:"-" dynamodbav:"dash_key,omitempty"`
+	// StoryboardKey is the WebVTT file of the version's thumbnail sprite
+	// sheets, when it has any
+	StoryboardKey string `json:"-" dynamodbav:"storyboard_key,omitempty"`
+	// DRM is the Common Encryption of the version's segments, when they
+	// are protected
+	DRM       *DRMInfo  `json:"-" dynamodbav:"drm,omitempty"`
+	Size      int64     `json:"size" dynamodbav:"size"`
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+}
+
+// GetActiveVersion returns the number of the output version playback
+// uses. Media processed before output was versioned has only version 1.
+func (m *Media) GetActiveVersion() int {
+	if m.OutputVersion < 1 {
+		return 1
+	}
+	return m.OutputVersion
+}
+
+// GetVersion returns an output version by number, or nil
+func (m *Media) GetVersion(number int) *OutputVersion {
+	versions := m.GetVersions()
+	for i := range versions {
+		if versions[i].Number == number {
+			return &versions[i]
+		}
+	}
+	return nil
+}
+
+// GetVersions returns the media's output versions, oldest first. Media
+// processed before output was versioned has its only version made up
+// from the record.
+func (m *Media) GetVersions() []OutputVersion {
+	if len(m.Versions) == 0 && len(m.Renditions) > 0 {
+		return []OutputVersion{{
+			Number:        1,
+			Renditions:    m.Renditions,
+			CoverArtKey:   m.CoverArtKey,
+			DASHKey:       m.DASHKey,
+			StoryboardKey: m.StoryboardKey,
+			DRM:           m.DRM,
+			Size:          m.OutputSize,
+			CreatedAt:     m.UpdatedAt,
+		}}
+	}
+	return m.Versions
+}
+
+// NextVersion returns the number of the media's next output version
+func (m *Media) NextVersion() int {
+	next := 1
+	for _, v := range m.GetVersions() {
+		if v.Number >= next {
+			next = v.Number + 1
+		}
+	}
+	return next
+}
+
+// GetVersionPrefix returns the storage prefix of an output version. The
+// first is stored at the output prefix itself, as all output was before
+// it was versioned.
+func (m *Media) GetVersionPrefix(number int) string {
+	if number <= 1 {
+		return m.GetOutputPrefix()
+	}
+	return fmt.Sprintf("%sv%d/", m.GetOutputPrefix(), number)
+}
+
+// GetVersionMasterKey returns the key of an output version's master
+// playlist, with URIs relative to the output prefix and no subtitles, from
+// which the published master playlist is made when it's activated
+func (m *Media) GetVersionMasterKey(number int) string {
+	return fmt.Sprintf("%sversions/%d.m3u8", m.GetOutputPrefix(), number)
+}
+
+// GetVersionDASHKey returns the key of an output version's DASH manifest.
+// It sits with the renditions it references, so it needs no rewriting
+// when the version is activated.
+func (m *Media) GetVersionDASHKey(number int) string {
+	return m.GetVersionPrefix(number) + "manifest.mpd"
+}
+
+// GetVersionStoryboardPrefix returns the prefix of an output version's
+// thumbnail sprite sheets and the WebVTT file referencing them
+func (m *Media) GetVersionStoryboardPrefix(number int) string {
+	return m.GetVersionPrefix(number) + "thumbnails/"
+}
+
+// GetVersionCoverArtKey returns the key for a size of an output version's
+// cover art
+func (m *Media) GetVersionCoverArtKey(number, size int) string {
+	return fmt.Sprintf("%scover/%d.jpg", m.GetVersionPrefix(number), size)
+}
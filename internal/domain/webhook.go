@@ -0,0 +1,90 @@
+package domain
+
+import "time"
+
+// WebhookEvents lists the event types a webhook may subscribe to
+var WebhookEvents = []EventType{
+	EventMediaCreated,
+	EventMediaProcessing,
+	EventMediaProcessed,
+	EventMediaFailed,
+	EventMediaDeleted,
+}
+
+// IsWebhookEvent reports whether webhooks can subscribe to an event type
+func IsWebhookEvent(t EventType) bool {
+	for _, e := range WebhookEvents {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook is a URL a user subscribed to the lifecycle events of their
+// media. The secret signs each delivery, so unlike an API key it is kept
+// in the clear.
+type Webhook struct {
+	ID          string `json:"id" dynamodbav:"id"`
+	UserID      string `json:"user_id" dynamodbav:"user_id"`
+	TenantID    string `json:"-" dynamodbav:"tenant_id,omitempty"`
+	URL         string `json:"url" dynamodbav:"url"`
+	Description string `json:"description,omitempty" dynamodbav:"description,omitempty"`
+	// Events are the event types delivered to the webhook
+	Events  []EventType `json:"events" dynamodbav:"events"`
+	Enabled bool        `json:"enabled" dynamodbav:"enabled"`
+	Secret  string      `json:"-" dynamodbav:"secret"`
+
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// Subscribes reports whether the webhook is enabled and receives events
+// of type t
+func (w *Webhook) Subscribes(t EventType) bool {
+	if !w.Enabled {
+		return false
+	}
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus is how far delivering an event to a webhook has
+// got
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending deliveries are waiting for their next attempt
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed deliveries were given up on after their last
+	// attempt
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is the delivery of one event to one webhook, kept as
+// the webhook's delivery log
+type WebhookDelivery struct {
+	ID        string                `json:"id" dynamodbav:"id"`
+	WebhookID string                `json:"webhook_id" dynamodbav:"webhook_id"`
+	TenantID  string                `json:"-" dynamodbav:"tenant_id,omitempty"`
+	Event     Event                 `json:"event" dynamodbav:"event"`
+	Status    WebhookDeliveryStatus `json:"status" dynamodbav:"status"`
+	// Attempts counts the requests made so far. ResponseStatus and Error
+	// describe the latest.
+	Attempts       int    `json:"attempts" dynamodbav:"attempts"`
+	ResponseStatus int    `json:"response_status,omitempty" dynamodbav:"response_status,omitempty"`
+	Error          string `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	// NextAttemptAt is set while the delivery is pending, putting it in
+	// the index of deliveries due
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" dynamodbav:"next_attempt_at,omitempty"`
+
+	CreatedAt   time.Time  `json:"created_at" dynamodbav:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty" dynamodbav:"delivered_at,omitempty"`
+	// ExpiresAt is the Unix time a finished delivery is deleted
+	ExpiresAt int64 `json:"-" dynamodbav:"expires_at,omitempty"`
+}
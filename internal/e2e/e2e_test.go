@@ -0,0 +1,33 @@
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end pipeline in short mode")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	h, err := New(ctx, Options{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer h.Close()
+
+	playback, err := h.Run(ctx, SampleVideo)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(playback.Playlists) == 0 || playback.Segments == 0 {
+		t.Errorf("played back %d renditions and %d segments, want some of each", len(playback.Playlists), playback.Segments)
+	}
+	if playback.CoverArt == 0 || playback.Sprites == 0 {
+		t.Errorf("fetched %d cover art sizes and %d storyboard sprites, want some of each", playback.CoverArt, playback.Sprites)
+	}
+}
@@ -0,0 +1,26 @@
+package e2e
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// fixtures holds the media uploaded by the harness. They are only
+// well-formed enough to pass upload checks; FakeProcessor never decodes
+// them.
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// SampleVideo is the name of the fixture video
+const SampleVideo = "sample.mp4"
+
+// Fixture returns the contents of the named fixture media file
+func Fixture(name string) ([]byte, error) {
+	data, err := fs.ReadFile(fixtures, "fixtures/"+name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown fixture %s: %w", name, err)
+	}
+	return data, nil
+}
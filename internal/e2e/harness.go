@@ -0,0 +1,573 @@
+// Package e2e runs the whole media pipeline in one process for end-to-end
+// checks: media is uploaded through the API, transcoded by a worker with a
+// fake processor and played back from the processed bucket, without real
+// AWS or ffmpeg. Storage and metadata are local by default, or LocalStack
+// when pointed at it.
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/api"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/internal/media/webvtt"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/service/ads"
+	"github.com/streaming-service/internal/service/analytics"
+	"github.com/streaming-service/internal/service/captions"
+	"github.com/streaming-service/internal/service/channels"
+	"github.com/streaming-service/internal/service/collections"
+	"github.com/streaming-service/internal/service/jobs"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/service/transcode"
+	"github.com/streaming-service/internal/service/upload"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// filesPath is where the filesystem buckets are served
+	filesPath = "/files"
+	// pollInterval is how often WaitForStatus checks a media item
+	pollInterval = 100 * time.Millisecond
+	// drainTimeout bounds waiting for running jobs on Close
+	drainTimeout = 10 * time.Second
+	// userID is the caller the harness acts as
+	userID = "e2e-user"
+)
+
+// Options configures a Harness
+type Options struct {
+	// LocalStack builds the S3 and DynamoDB clients as in production, for
+	// AWS_ENDPOINT_URL pointing at LocalStack, and creates the buckets and
+	// tables first. Otherwise buckets and metadata are kept in DataDir.
+	LocalStack bool
+	// DataDir holds local buckets and metadata; a temporary directory,
+	// removed on Close, when empty
+	DataDir string
+	// Processor transcodes media; a FakeProcessor when nil
+	Processor processor.MediaProcessor
+	// Logger defaults to errors only, on stderr
+	Logger *logger.Logger
+}
+
+// Harness is a running API server and transcoding worker
+type Harness struct {
+	// Config is the configuration the services were built with
+	Config *config.Config
+	S3     *s3.Client
+	Dynamo *dynamodb.Client
+	Queue  *queue.MemoryQueue
+	// Server serves the API, and the local buckets under /files
+	Server *httptest.Server
+
+	worker  *transcode.Worker
+	cancel  context.CancelFunc
+	tempDir string
+	client  *http.Client
+	log     *logger.Logger
+}
+
+// New starts a harness. Close must be called to stop it.
+func New(ctx context.Context, opts Options) (*Harness, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	log := opts.Logger
+	if log == nil {
+		log, err = logger.NewWithOptions(logger.Options{
+			Level:   "error",
+			Format:  "console",
+			Outputs: []string{logger.OutputStderr},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize logger: %w", err)
+		}
+	}
+
+	proc := opts.Processor
+	if proc == nil {
+		proc = NewFakeProcessor()
+	}
+
+	// Turn off everything the pipeline doesn't need
+	cfg.Auth.Enabled = false
+	cfg.Search.Enabled = false
+	cfg.Encryption.Enabled = false
+	cfg.DRM.Enabled = false
+	cfg.Live.Enabled = false
+	cfg.Quotas.Enabled = false
+	cfg.AWS.CloudFrontDistributionID = ""
+
+	// The listener is needed up front for the local buckets' URLs
+	server := httptest.NewUnstartedServer(nil)
+	h := &Harness{
+		Config: cfg,
+		Queue:  queue.NewMemoryQueue(),
+		Server: server,
+		client: &http.Client{Timeout: 30 * time.Second},
+		log:    log,
+	}
+	baseURL := "http://" + server.Listener.Addr().String() + filesPath
+
+	var files http.Handler
+	if opts.LocalStack {
+		files, err = h.connectLocalStack(ctx)
+	} else {
+		files, err = h.openLocal(opts.DataDir, baseURL)
+	}
+	if err != nil {
+		server.Close()
+		h.removeTemp()
+		return nil, err
+	}
+
+	jobsService := jobs.NewService(h.Dynamo, cfg.Worker.JobRetention, log)
+	uploadService := upload.NewService(h.S3, h.Dynamo, log)
+	uploadService.SetQueue(jobsService.Queue(h.Queue))
+	uploadService.SetAllowlist(cfg.Upload)
+	uploadService.SetTagIndex(h.Dynamo)
+	if cfg.Server.Metrics {
+		queue.MeasureDepth(h.Queue)
+	}
+	streamService := stream.NewService(h.S3, h.Dynamo, cfg.AWS.CloudFrontDomain, log)
+	transcodeService := transcode.NewService(h.S3, h.Dynamo, proc, log)
+	transcodeService.SetProfiles(cfg.FFMPEG.Profiles)
+	transcodeService.SetKeepVersions(cfg.Versions.Keep)
+
+	router := api.NewRouter(api.RouterConfig{
+		UploadService:      uploadService,
+		StreamService:      streamService,
+		AnalyticsService:   analytics.NewService(h.Dynamo, log),
+		AdsService:         ads.NewService(h.S3, h.Dynamo, log),
+		CollectionsService: collections.NewService(h.Dynamo, streamService, log),
+		ChannelsService:    channels.NewService(h.S3, h.Dynamo, streamService, cfg.AWS.CloudFrontDomain, log),
+		CaptionsService:    captions.NewService(h.S3, h.Dynamo, log),
+		JobsService:        jobsService,
+		AdminScope:         cfg.Auth.AdminScope,
+		BodyLimits: api.BodyLimits{
+			Default: cfg.Server.MaxBodySize,
+			Upload:  cfg.Server.MaxUploadSize,
+			Artwork: cfg.Server.MaxArtworkSize,
+		},
+		ReadinessChecks: []api.ReadinessCheck{
+			{Name: "dynamodb", Critical: true, Check: h.Dynamo.Ping},
+			{Name: "s3", Critical: true, Check: h.S3.Ping},
+		},
+		ReadinessTimeout: cfg.Server.ReadinessTimeout,
+		Metrics:          cfg.Server.Metrics,
+		Logger:           log,
+	})
+
+	mux := http.NewServeMux()
+	if files != nil {
+		mux.Handle(filesPath+"/", http.StripPrefix(filesPath, files))
+	}
+	mux.Handle("/", router)
+	server.Config.Handler = mux
+	server.Start()
+
+	var workerCtx context.Context
+	workerCtx, h.cancel = context.WithCancel(context.Background())
+	h.worker = transcode.NewWorker(h.Queue, transcodeService, cfg.Worker.Concurrency, log)
+	h.worker.SetJobs(jobsService)
+	h.worker.SetJobTimeout(cfg.Worker.JobTimeout)
+	if err := h.worker.Start(workerCtx); err != nil {
+		h.Close()
+		return nil, fmt.Errorf("failed to start worker: %w", err)
+	}
+
+	return h, nil
+}
+
+// openLocal keeps the buckets and metadata in dir, returning the handler
+// serving the buckets
+func (h *Harness) openLocal(dir, baseURL string) (http.Handler, error) {
+	if dir == "" {
+		temp, err := os.MkdirTemp("", "streaming-e2e-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %w", err)
+		}
+		dir, h.tempDir = temp, temp
+	}
+	h.Config.AWS.CloudFrontDomain = baseURL + "/" + h.Config.AWS.S3ProcessedBucket
+
+	s3Client, files, err := s3.NewFilesystemClient(h.Config.AWS, filepath.Join(dir, "buckets"), baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize filesystem storage: %w", err)
+	}
+	dynamoClient, err := dynamodb.NewEmbeddedClient(h.Config.AWS, filepath.Join(dir, "metadata.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize embedded metadata store: %w", err)
+	}
+
+	h.S3, h.Dynamo = s3Client, dynamoClient
+	return files.Handler(), nil
+}
+
+// connectLocalStack creates the buckets and tables on LocalStack and
+// connects to them. Playback reads the processed bucket directly, as
+// LocalStack has no CDN.
+func (h *Harness) connectLocalStack(ctx context.Context) (http.Handler, error) {
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		return nil, fmt.Errorf("AWS_ENDPOINT_URL must point at LocalStack")
+	}
+
+	cfg := &h.Config.AWS
+	if cfg.AccessKeyID == "" {
+		// LocalStack accepts any credentials
+		cfg.AccessKeyID, cfg.SecretAccessKey = "test", "test"
+	}
+	cfg.S3UsePathStyle = true
+	cfg.CloudFrontDomain = strings.TrimSuffix(endpoint, "/") + "/" + cfg.S3ProcessedBucket
+
+	tables, err := dynamodb.NewMigrator(ctx, *cfg, h.log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize DynamoDB client: %w", err)
+	}
+	if err := tables.Migrate(ctx, false); err != nil {
+		return nil, err
+	}
+	buckets, err := s3.NewMigrator(ctx, *cfg, h.log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize S3 client: %w", err)
+	}
+	if err := buckets.Migrate(ctx, false); err != nil {
+		return nil, err
+	}
+
+	if h.S3, err = s3.NewClient(ctx, *cfg); err != nil {
+		return nil, fmt.Errorf("failed to initialize S3 client: %w", err)
+	}
+	if h.Dynamo, err = dynamodb.NewClient(ctx, *cfg); err != nil {
+		return nil, fmt.Errorf("failed to initialize DynamoDB client: %w", err)
+	}
+	return nil, nil
+}
+
+// Close stops the worker, letting running jobs finish, and the server
+func (h *Harness) Close() {
+	if h.cancel != nil {
+		h.cancel()
+		if !h.worker.Drain(drainTimeout) {
+			h.log.Warn("drain deadline passed, unfinished jobs dropped")
+		}
+	}
+	h.Server.Close()
+	h.removeTemp()
+}
+
+func (h *Harness) removeTemp() {
+	if h.tempDir != "" {
+		os.RemoveAll(h.tempDir)
+	}
+}
+
+// Upload uploads the named fixture through the API, returning the new
+// media ID
+func (h *Harness) Upload(ctx context.Context, fixture, title string) (string, error) {
+	data, err := Fixture(fixture)
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("title", title); err != nil {
+		return "", err
+	}
+	part, err := form.CreateFormFile("file", fixture)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	var resp upload.UploadResponse
+	if err := h.call(ctx, http.MethodPost, "/api/v1/upload", form.FormDataContentType(), &body, http.StatusCreated, &resp); err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	return resp.MediaID, nil
+}
+
+// Media fetches a media item through the API
+func (h *Harness) Media(ctx context.Context, mediaID string) (*stream.MediaInfo, error) {
+	var info stream.MediaInfo
+	if err := h.call(ctx, http.MethodGet, "/api/v1/media/"+url.PathEscape(mediaID), "", nil, http.StatusOK, &info); err != nil {
+		return nil, fmt.Errorf("failed to get media: %w", err)
+	}
+	return &info, nil
+}
+
+// WaitForStatus polls a media item until it reaches status. Reaching
+// failed instead is an error.
+func (h *Harness) WaitForStatus(ctx context.Context, mediaID string, status domain.MediaStatus) (*stream.MediaInfo, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		info, err := h.Media(ctx, mediaID)
+		if err != nil {
+			return nil, err
+		}
+		switch info.Status {
+		case status:
+			return info, nil
+		case domain.MediaStatusFailed:
+			return nil, fmt.Errorf("media %s failed processing", mediaID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for media %s to be %s, still %s: %w", mediaID, status, info.Status, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// PlaybackURL fetches the master playlist URL of a media item through the
+// API
+func (h *Harness) PlaybackURL(ctx context.Context, mediaID string) (string, error) {
+	var resp struct {
+		PlaybackURL string `json:"playback_url"`
+	}
+	if err := h.call(ctx, http.MethodGet, "/api/v1/media/"+url.PathEscape(mediaID)+"/playback", "", nil, http.StatusOK, &resp); err != nil {
+		return "", fmt.Errorf("failed to get playback URL: %w", err)
+	}
+	return resp.PlaybackURL, nil
+}
+
+// Playback is what a player fetched for a media item
+type Playback struct {
+	// Master is the master playlist
+	Master []byte
+	// Playlists are the rendition playlists by their URL
+	Playlists map[string][]byte
+	// Segments counts the segments fetched
+	Segments int
+	// CoverArt counts the cover art images fetched
+	CoverArt int
+	// Sprites counts the storyboard sprite sheets fetched
+	Sprites int
+}
+
+// Play fetches the master playlist at playbackURL and every rendition
+// playlist and segment it leads to, as a player would. Empty or missing
+// files are errors.
+func (h *Harness) Play(ctx context.Context, playbackURL string) (*Playback, error) {
+	master, err := h.fetch(ctx, playbackURL)
+	if err != nil {
+		return nil, err
+	}
+
+	playback := &Playback{Master: master, Playlists: make(map[string][]byte)}
+	renditions, err := references(playbackURL, master)
+	if err != nil {
+		return nil, err
+	}
+	if len(renditions) == 0 {
+		return nil, fmt.Errorf("master playlist %s has no renditions", playbackURL)
+	}
+
+	for _, renditionURL := range renditions {
+		playlist, err := h.fetch(ctx, renditionURL)
+		if err != nil {
+			return nil, err
+		}
+		playback.Playlists[renditionURL] = playlist
+
+		segments, err := references(renditionURL, playlist)
+		if err != nil {
+			return nil, err
+		}
+		if len(segments) == 0 {
+			return nil, fmt.Errorf("playlist %s has no segments", renditionURL)
+		}
+		for _, segmentURL := range segments {
+			if _, err := h.fetch(ctx, segmentURL); err != nil {
+				return nil, err
+			}
+			playback.Segments++
+		}
+	}
+
+	return playback, nil
+}
+
+// Run exercises the whole pipeline with the named fixture: it is
+// uploaded, waited on until processed, and played back
+func (h *Harness) Run(ctx context.Context, fixture string) (*Playback, error) {
+	mediaID, err := h.Upload(ctx, fixture, "e2e "+fixture)
+	if err != nil {
+		return nil, err
+	}
+	info, err := h.WaitForStatus(ctx, mediaID, domain.MediaStatusCompleted)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Renditions) == 0 {
+		return nil, fmt.Errorf("media %s completed with no renditions", mediaID)
+	}
+
+	playbackURL, err := h.PlaybackURL(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	playback, err := h.Play(ctx, playbackURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(playback.Playlists) != len(info.Renditions) {
+		return nil, fmt.Errorf("media %s has %d renditions but its master playlist lists %d", mediaID, len(info.Renditions), len(playback.Playlists))
+	}
+
+	for _, coverURL := range info.CoverArt {
+		if _, err := h.fetch(ctx, coverURL); err != nil {
+			return nil, err
+		}
+		playback.CoverArt++
+	}
+
+	if info.StoryboardURL != "" {
+		if playback.Sprites, err = h.fetchStoryboard(ctx, info.StoryboardURL); err != nil {
+			return nil, err
+		}
+	}
+	return playback, nil
+}
+
+// fetchStoryboard fetches a storyboard's WebVTT file and each sprite
+// sheet its cues refer to, returning how many sheets there are
+func (h *Harness) fetchStoryboard(ctx context.Context, storyboardURL string) (int, error) {
+	data, err := h.fetch(ctx, storyboardURL)
+	if err != nil {
+		return 0, err
+	}
+	cues, err := webvtt.Parse(data)
+	if err != nil {
+		return 0, fmt.Errorf("invalid storyboard %s: %w", storyboardURL, err)
+	}
+	if len(cues) == 0 {
+		return 0, fmt.Errorf("storyboard %s has no thumbnails", storyboardURL)
+	}
+	base, err := url.Parse(storyboardURL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid storyboard URL %s: %w", storyboardURL, err)
+	}
+
+	fetched := make(map[string]bool)
+	for _, cue := range cues {
+		sprite, _, _ := strings.Cut(cue.Text, "#")
+		ref, err := base.Parse(sprite)
+		if err != nil {
+			return 0, fmt.Errorf("invalid sprite %q in %s: %w", sprite, storyboardURL, err)
+		}
+		if fetched[ref.String()] {
+			continue
+		}
+		if _, err := h.fetch(ctx, ref.String()); err != nil {
+			return 0, err
+		}
+		fetched[ref.String()] = true
+	}
+	return len(fetched), nil
+}
+
+// call makes an API request as the harness user, decoding a response
+// with the expected status into out
+func (h *Harness) call(ctx context.Context, method, path, contentType string, body io.Reader, expected int, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, h.Server.URL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-User-ID", userID)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != expected {
+		return fmt.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return json.Unmarshal(data, out)
+}
+
+// fetch gets a playback file, which must be non-empty
+func (h *Harness) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", rawURL)
+	}
+	return data, nil
+}
+
+// references resolves the URIs a playlist lists against its own URL
+func references(playlistURL string, playlist []byte) ([]string, error) {
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid playlist URL %s: %w", playlistURL, err)
+	}
+
+	var refs []string
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ref, err := base.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URI %q in %s: %w", line, playlistURL, err)
+		}
+		refs = append(refs, ref.String())
+	}
+	return refs, scanner.Err()
+}
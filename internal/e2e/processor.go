@@ -0,0 +1,295 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/internal/media/webvtt"
+)
+
+// Storyboard thumbnail size and sprite sheet layout
+const (
+	thumbnailWidth   = 160
+	thumbnailHeight  = 90
+	storyboardTiles  = 2
+	storyboardSprite = "sprite_0001.jpg"
+)
+
+// tsPacketSize is the size of an MPEG-TS packet
+const tsPacketSize = 188
+
+// FakeProcessor implements MediaProcessor without ffmpeg. It writes HLS
+// output in the layout ffmpeg produces, with placeholder segments, so the
+// rest of the pipeline can run on sources it cannot decode.
+type FakeProcessor struct {
+	// Segments is how many segments each rendition gets
+	Segments int
+	// SegmentDuration is the length of each segment in seconds
+	SegmentDuration float64
+	// Err, when set, fails every job with it
+	Err error
+
+	mu        sync.Mutex
+	processed []string
+}
+
+// NewFakeProcessor creates a fake processor writing three 4 second
+// segments per rendition
+func NewFakeProcessor() *FakeProcessor {
+	return &FakeProcessor{
+		Segments:        3,
+		SegmentDuration: 4,
+	}
+}
+
+// Ping always succeeds, standing in for ffmpeg's dependency check
+func (p *FakeProcessor) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Process writes a master playlist and, for each profile, a playlist and
+// segments under input.OutputDir
+func (p *FakeProcessor) Process(ctx context.Context, input *processor.ProcessInput) (*processor.ProcessOutput, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	if _, err := os.Stat(input.SourcePath); err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+
+	var renditions []processor.RenditionOutput
+	for _, profile := range input.Profiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if input.Progress != nil {
+			input.Progress(profile.Name, false)
+		}
+
+		rendition, err := p.writeRendition(input, profile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write rendition %s: %w", profile.Name, err)
+		}
+		renditions = append(renditions, *rendition)
+
+		if input.Percent != nil {
+			input.Percent(profile.Name, 100)
+		}
+		if input.Progress != nil {
+			input.Progress(profile.Name, true)
+		}
+	}
+
+	masterPath := filepath.Join(input.OutputDir, "master.m3u8")
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#EXT-X-VERSION:3\n")
+	for _, r := range renditions {
+		buf.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n", r.Bitrate, r.Width, r.Height))
+		buf.WriteString(r.Name + "/playlist.m3u8\n")
+	}
+	if err := os.WriteFile(masterPath, buf.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write master playlist: %w", err)
+	}
+
+	coverArt, err := writeCoverArt(input.OutputDir, input.CoverArtSizes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write cover art: %w", err)
+	}
+
+	storyboard, err := p.writeStoryboard(input.OutputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write storyboard: %w", err)
+	}
+
+	p.mu.Lock()
+	p.processed = append(p.processed, input.MediaID)
+	p.mu.Unlock()
+
+	return &processor.ProcessOutput{
+		MediaID:    input.MediaID,
+		Renditions: renditions,
+		Duration:   float64(p.Segments) * p.SegmentDuration,
+		MasterPath: masterPath,
+		Metadata:   map[string]interface{}{"fake": true},
+		Source:     p.source(input.Profiles),
+		CoverArt:   coverArt,
+		Storyboard: storyboard,
+	}, nil
+}
+
+// writeStoryboard writes a plain sprite sheet with a thumbnail per
+// segment and the WebVTT file mapping segments to its tiles
+func (p *FakeProcessor) writeStoryboard(dir string) (*processor.StoryboardOutput, error) {
+	storyboardDir := filepath.Join(dir, "thumbnails")
+	if err := os.MkdirAll(storyboardDir, 0755); err != nil {
+		return nil, err
+	}
+
+	img := image.NewGray(image.Rect(0, 0, thumbnailWidth*storyboardTiles, thumbnailHeight*storyboardTiles))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		return nil, err
+	}
+	spritePath := filepath.Join(storyboardDir, storyboardSprite)
+	if err := os.WriteFile(spritePath, buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(p.SegmentDuration * float64(time.Second))
+	var cues []webvtt.Cue
+	for i := 0; i < p.Segments && i < storyboardTiles*storyboardTiles; i++ {
+		cues = append(cues, webvtt.Cue{
+			Start: time.Duration(i) * interval,
+			End:   time.Duration(i+1) * interval,
+			Text: fmt.Sprintf("%s#xywh=%d,%d,%d,%d", storyboardSprite,
+				i%storyboardTiles*thumbnailWidth, i/storyboardTiles*thumbnailHeight, thumbnailWidth, thumbnailHeight),
+		})
+	}
+	vttPath := filepath.Join(storyboardDir, "storyboard.vtt")
+	if err := os.WriteFile(vttPath, webvtt.Write(cues), 0644); err != nil {
+		return nil, err
+	}
+
+	return &processor.StoryboardOutput{VTTPath: vttPath, SpritePaths: []string{spritePath}}, nil
+}
+
+// writeCoverArt writes a plain square JPEG in each size
+func writeCoverArt(dir string, sizes []int) (map[int]string, error) {
+	if len(sizes) == 0 {
+		return nil, nil
+	}
+	coverDir := filepath.Join(dir, "cover")
+	if err := os.MkdirAll(coverDir, 0755); err != nil {
+		return nil, err
+	}
+
+	paths := make(map[int]string, len(sizes))
+	for _, size := range sizes {
+		img := image.NewGray(image.Rect(0, 0, size, size))
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(coverDir, fmt.Sprintf("%d.jpg", size))
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+		paths[size] = path
+	}
+	return paths, nil
+}
+
+func (p *FakeProcessor) writeRendition(input *processor.ProcessInput, profile processor.ProfileConfig) (*processor.RenditionOutput, error) {
+	dir := filepath.Join(input.OutputDir, profile.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var playlist bytes.Buffer
+	playlist.WriteString("#EXTM3U\n")
+	playlist.WriteString("#EXT-X-VERSION:3\n")
+	playlist.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(p.SegmentDuration+0.999)))
+	playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	playlist.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+
+	var segments []string
+	for i := 0; i < p.Segments; i++ {
+		name := fmt.Sprintf("segment_%04d.ts", i)
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, segment(input.MediaID, profile.Name, i), 0644); err != nil {
+			return nil, err
+		}
+		segments = append(segments, path)
+		playlist.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n%s\n", p.SegmentDuration, name))
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+
+	playlistPath := filepath.Join(dir, "playlist.m3u8")
+	if err := os.WriteFile(playlistPath, playlist.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	return &processor.RenditionOutput{
+		Name:         profile.Name,
+		Width:        profile.Width,
+		Height:       profile.Height,
+		Bitrate:      parseBitrate(profile.VideoBitrate) + parseBitrate(profile.AudioBitrate),
+		Codec:        profile.Codec,
+		PlaylistPath: playlistPath,
+		SegmentPaths: segments,
+	}, nil
+}
+
+// source describes the source as if it were at the size of the largest
+// profile
+func (p *FakeProcessor) source(profiles []processor.ProfileConfig) *domain.SourceMetadata {
+	video := domain.VideoStreamInfo{Codec: "h264", Profile: "High", Level: 40, FrameRate: 30, PixelFormat: "yuv420p", BitDepth: 8}
+	for _, profile := range profiles {
+		if profile.Height > video.Height {
+			video.Width, video.Height = profile.Width, profile.Height
+		}
+	}
+	return &domain.SourceMetadata{
+		Container: "mov,mp4,m4a,3gp,3g2,mj2",
+		Duration:  float64(p.Segments) * p.SegmentDuration,
+		Video:     []domain.VideoStreamInfo{video},
+		Audio: []domain.AudioStreamInfo{{
+			Index: 1, Codec: "aac", Profile: "LC", Channels: 2, ChannelLayout: "stereo", SampleRate: 48000,
+		}},
+	}
+}
+
+// GetSupportedFormats returns the formats of the fixture media
+func (p *FakeProcessor) GetSupportedFormats() []string {
+	return []string{"mp4"}
+}
+
+// GetType returns the media type this processor handles
+func (p *FakeProcessor) GetType() domain.MediaType {
+	return domain.MediaTypeVideo
+}
+
+// Processed returns the IDs of the media processed so far, in order
+func (p *FakeProcessor) Processed() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.processed...)
+}
+
+// segment returns a placeholder segment of MPEG-TS packets whose payload
+// identifies it, so a fetched segment can be traced to its source
+func segment(mediaID, rendition string, index int) []byte {
+	packet := make([]byte, tsPacketSize)
+	packet[0] = 0x47
+	copy(packet[4:], fmt.Sprintf("%s/%s/%d", mediaID, rendition, index))
+	return bytes.Repeat(packet, 2)
+}
+
+// parseBitrate converts an ffmpeg bitrate such as "2500k" to bits per
+// second, returning zero if it can't be parsed
+func parseBitrate(s string) int {
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "k"):
+		mult = 1000
+	case strings.HasSuffix(s, "M"):
+		mult = 1000000
+	}
+	n, err := strconv.Atoi(strings.TrimRight(s, "kM"))
+	if err != nil {
+		return 0
+	}
+	return n * mult
+}
@@ -0,0 +1,27 @@
+package events
+
+import (
+	"context"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/eventbridge"
+	"github.com/streaming-service/internal/repository/sns"
+)
+
+// NewBus returns a handler publishing events to the EventBridge bus or SNS
+// topic configured for downstream systems
+func NewBus(ctx context.Context, aws config.AWSConfig, cfg config.EventsConfig) (Handler, error) {
+	if cfg.Provider == "sns" {
+		client, err := sns.NewClient(ctx, aws, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return HandlerFunc(client.PublishEvent), nil
+	}
+
+	client, err := eventbridge.NewClient(ctx, aws, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return HandlerFunc(client.PublishEvent), nil
+}
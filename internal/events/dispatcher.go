@@ -0,0 +1,84 @@
+// Package events fans media lifecycle events out to the handlers that
+// react to them, such as email notifications and the event bus downstream
+// systems subscribe to
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Handler reacts to media lifecycle events
+type Handler interface {
+	HandleEvent(ctx context.Context, event *domain.Event) error
+}
+
+// HandlerFunc adapts a function, such as an event bus client's
+// PublishEvent, to a Handler
+type HandlerFunc func(ctx context.Context, event *domain.Event) error
+
+// HandleEvent calls f
+func (f HandlerFunc) HandleEvent(ctx context.Context, event *domain.Event) error {
+	return f(ctx, event)
+}
+
+// Dispatcher delivers events to its handlers
+type Dispatcher struct {
+	handlers []subscription
+	log      *logger.Logger
+}
+
+type subscription struct {
+	name    string
+	handler Handler
+}
+
+// NewDispatcher creates a dispatcher with no handlers
+func NewDispatcher(log *logger.Logger) *Dispatcher {
+	return &Dispatcher{log: log}
+}
+
+// Subscribe adds a handler, named in logs, that receives every event
+func (d *Dispatcher) Subscribe(name string, h Handler) {
+	d.handlers = append(d.handlers, subscription{name: name, handler: h})
+}
+
+// Publish delivers an event to each handler in turn, filling in its ID,
+// time and tenant when unset. A failing handler is logged and doesn't stop
+// the others.
+func (d *Dispatcher) Publish(ctx context.Context, event *domain.Event) {
+	_ = d.Deliver(ctx, event)
+}
+
+// Deliver is Publish, also returning the errors of the handlers that
+// failed, for callers that retry
+func (d *Dispatcher) Deliver(ctx context.Context, event *domain.Event) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.TenantID == "" {
+		event.TenantID = tenant.FromContext(ctx)
+	}
+
+	log := logger.FromContext(ctx, d.log)
+	var errs []error
+	for _, s := range d.handlers {
+		if err := s.handler.HandleEvent(ctx, event); err != nil {
+			log.Error("event handler failed", "error", err, "handler", s.name,
+				"event", event.Type, "media_id", event.MediaID)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
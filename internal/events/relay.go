@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+	"github.com/streaming-service/pkg/metrics"
+)
+
+// eventsFailed counts outbox events given up on, which need attention
+var eventsFailed = metrics.NewCounter("streaming_outbox_events_failed_total",
+	"Outbox events marked failed after MaxAttempts failed deliveries, by type", "type")
+
+// Relay delivers the events written to the outbox through a dispatcher.
+// Delivery is at least once: an event whose delivery failed for any
+// handler is delivered to all of them again once its lease runs out, until
+// it has failed MaxAttempts times.
+type Relay struct {
+	dynamoClient *dynamodb.Client
+	dispatcher   *Dispatcher
+	cfg          config.OutboxConfig
+	log          *logger.Logger
+}
+
+// NewRelay creates a relay delivering outbox events through dispatcher
+func NewRelay(dynamoClient *dynamodb.Client, dispatcher *Dispatcher, cfg config.OutboxConfig, log *logger.Logger) *Relay {
+	return &Relay{
+		dynamoClient: dynamoClient,
+		dispatcher:   dispatcher,
+		cfg:          cfg,
+		log:          log,
+	}
+}
+
+// Run delivers pending events every interval until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.DeliverPending(ctx); err != nil && ctx.Err() == nil {
+			r.log.Error("outbox relay failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverPending delivers each pending event it can claim, oldest first
+func (r *Relay) DeliverPending(ctx context.Context) error {
+	cursor := ""
+	for {
+		entries, next, err := r.dynamoClient.ListPendingEvents(ctx, int32(r.cfg.BatchSize), cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			claimed, err := r.dynamoClient.ClaimEvent(ctx, entry.ID, time.Now().Add(r.cfg.Lease))
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+
+			eventCtx := tenant.WithID(ctx, entry.Event.TenantID)
+			if err := r.dispatcher.Deliver(eventCtx, &entry.Event); err != nil {
+				failed, recordErr := r.dynamoClient.RecordEventFailure(ctx, entry.ID, err, r.cfg.MaxAttempts)
+				if recordErr != nil {
+					r.log.Error("failed to record outbox delivery failure", "error", recordErr, "event_id", entry.ID)
+				}
+				if failed {
+					eventsFailed.Inc(string(entry.Event.Type))
+					r.log.Error("outbox event failed too often, giving up",
+						"error", err,
+						"event_id", entry.ID,
+						"event_type", entry.Event.Type,
+						"attempts", r.cfg.MaxAttempts,
+					)
+				}
+				continue
+			}
+			if err := r.dynamoClient.MarkEventDelivered(ctx, entry.ID, r.cfg.Retention); err != nil {
+				return err
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
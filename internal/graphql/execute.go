@@ -0,0 +1,708 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is the result of executing a request. Data is absent when the
+// request could not be executed.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is a request or field error
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute parses, validates and executes a query against the schema
+func (s *Schema) Execute(ctx context.Context, req *Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return failed(fmt.Sprintf("syntax error: %v", err))
+	}
+
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return failed(err.Error())
+	}
+	if op.kind != "query" {
+		return failed(fmt.Sprintf("%s operations are not supported", op.kind))
+	}
+
+	v := &validator{schema: s, doc: doc}
+	if errs := v.validate(op); len(errs) > 0 {
+		resp := &Response{}
+		for _, msg := range errs {
+			resp.Errors = append(resp.Errors, &Error{Message: msg})
+		}
+		return resp
+	}
+
+	vars, err := s.coerceVariables(op, req.Variables)
+	if err != nil {
+		return failed(err.Error())
+	}
+
+	if s.cost(doc, s.query, op.selections, vars) > s.limits.Cost {
+		return failed(fmt.Sprintf("query costs more than the limit of %d", s.limits.Cost))
+	}
+
+	e := &executor{doc: doc, vars: vars}
+	data, _ := e.selectionSet(ctx, s.query, nil, op.selections, nil)
+	if data == nil {
+		return &Response{Data: json.RawMessage("null"), Errors: e.errors}
+	}
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func failed(msg string) *Response {
+	return &Response{Errors: []*Error{{Message: msg}}}
+}
+
+// selectOperation picks the operation to run
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return doc.operations[0], nil
+	}
+
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// validator checks a query against the schema before execution
+type validator struct {
+	schema *Schema
+	doc    *document
+	errors []string
+	// fields counts the fields selected, with fragments expanded
+	fields int
+}
+
+func (v *validator) validate(op *operation) []string {
+	declared := make(map[string]bool)
+	for _, def := range op.vars {
+		if declared[def.name] {
+			v.errorf("variable $%s is declared more than once", def.name)
+		}
+		declared[def.name] = true
+		if _, err := v.schema.inputType(def.typ); err != nil {
+			v.errorf("variable $%s: %v", def.name, err)
+		}
+	}
+
+	v.selections(v.schema.query, op.selections, declared, 1, make(map[string]bool))
+	return v.errors
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.errors = append(v.errors, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) selections(obj *Object, sels []selection, vars map[string]bool, depth int, spreading map[string]bool) {
+	if depth > v.schema.limits.Depth {
+		v.errorf("query is nested deeper than %d levels", v.schema.limits.Depth)
+		return
+	}
+
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *field:
+			// Stop walking once over the limit, so fragments spread over
+			// and over can't make validation itself expensive
+			v.fields++
+			if v.fields > v.schema.limits.Fields {
+				if v.fields == v.schema.limits.Fields+1 {
+					v.errorf("query selects more than %d fields", v.schema.limits.Fields)
+				}
+				return
+			}
+			v.variables(sel.args, vars)
+			for _, dir := range sel.directives {
+				v.directive(dir, vars)
+			}
+
+			if sel.name == "__typename" {
+				if sel.selections != nil {
+					v.errorf("field __typename cannot have a selection set")
+				}
+				continue
+			}
+
+			def, ok := obj.Fields[sel.name]
+			if !ok {
+				v.errorf("cannot query field %q on type %s", sel.name, obj.Name)
+				continue
+			}
+
+			for name := range sel.args {
+				if _, ok := def.Args[name]; !ok {
+					v.errorf("unknown argument %q on field %s.%s", name, obj.Name, sel.name)
+				}
+			}
+			for name, arg := range def.Args {
+				if _, required := arg.Type.(*NonNull); required && arg.Default == nil {
+					if _, ok := sel.args[name]; !ok {
+						v.errorf("field %s.%s requires argument %q", obj.Name, sel.name, name)
+					}
+				}
+			}
+
+			child, isObject := namedType(def.Type).(*Object)
+			switch {
+			case isObject && sel.selections == nil:
+				v.errorf("field %s.%s of type %s must have a selection set", obj.Name, sel.name, def.Type)
+			case !isObject && sel.selections != nil:
+				v.errorf("field %s.%s of type %s cannot have a selection set", obj.Name, sel.name, def.Type)
+			case isObject:
+				v.selections(child, sel.selections, vars, depth+1, spreading)
+			}
+		case *fragmentSpread:
+			frag, ok := v.doc.fragments[sel.name]
+			if !ok {
+				v.errorf("unknown fragment %q", sel.name)
+				continue
+			}
+			if spreading[sel.name] {
+				v.errorf("fragment %q spreads itself", sel.name)
+				continue
+			}
+			if frag.typeCond != obj.Name {
+				v.errorf("fragment %q on %s cannot be spread within %s", sel.name, frag.typeCond, obj.Name)
+				continue
+			}
+			spreading[sel.name] = true
+			v.selections(obj, frag.selections, vars, depth, spreading)
+			delete(spreading, sel.name)
+		case *inlineFragment:
+			if sel.typeCond != "" && sel.typeCond != obj.Name {
+				v.errorf("inline fragment on %s cannot be spread within %s", sel.typeCond, obj.Name)
+				continue
+			}
+			v.selections(obj, sel.selections, vars, depth, spreading)
+		}
+	}
+}
+
+func (v *validator) directive(dir *directive, vars map[string]bool) {
+	if dir.name != "skip" && dir.name != "include" {
+		v.errorf("unknown directive @%s", dir.name)
+		return
+	}
+	if _, ok := dir.args["if"]; !ok || len(dir.args) != 1 {
+		v.errorf("directive @%s takes a single \"if\" argument", dir.name)
+	}
+	v.variables(dir.args, vars)
+}
+
+// variables checks that the variables used in a value are declared
+func (v *validator) variables(value interface{}, vars map[string]bool) {
+	switch value := value.(type) {
+	case variable:
+		if !vars[string(value)] {
+			v.errorf("variable $%s is not declared", value)
+		}
+	case []interface{}:
+		for _, item := range value {
+			v.variables(item, vars)
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			v.variables(item, vars)
+		}
+	}
+}
+
+// cost adds up the cost of resolving sels on obj: each field's own cost,
+// and its selections' cost once for each item it may return. Every
+// selection counts, whether or not @skip or @include leave it out.
+func (s *Schema) cost(doc *document, obj *Object, sels []selection, vars map[string]interface{}) int {
+	total := 0
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *field:
+			def, ok := obj.Fields[sel.name]
+			if !ok {
+				continue
+			}
+			total += def.Cost
+
+			child, ok := namedType(def.Type).(*Object)
+			if !ok {
+				continue
+			}
+			n := 1
+			if def.Multiplier != nil {
+				args, err := arguments(def, sel, vars)
+				if err != nil {
+					// Execution reports the bad argument
+					continue
+				}
+				n = max(def.Multiplier(args), 1)
+			}
+			// Capped, so the product can't overflow
+			total += n * min(s.cost(doc, child, sel.selections, vars), s.limits.Cost+1)
+		case *fragmentSpread:
+			total += s.cost(doc, obj, doc.fragments[sel.name].selections, vars)
+		case *inlineFragment:
+			total += s.cost(doc, obj, sel.selections, vars)
+		}
+
+		// Past the limit the total is only compared, so stop before
+		// multiplying it further
+		if total > s.limits.Cost {
+			return total
+		}
+	}
+	return total
+}
+
+// arguments returns the values of a field's arguments, with variables
+// substituted and defaults applied
+func arguments(def *Field, f *field, vars map[string]interface{}) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(def.Args))
+	for name, arg := range def.Args {
+		raw, given := f.args[name]
+		if !given || (isVariable(raw) && !hasVariable(vars, raw)) {
+			if arg.Default != nil {
+				args[name] = arg.Default
+				continue
+			}
+		}
+		value, err := coerce(raw, arg.Type, vars)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", name, err)
+		}
+		if value != nil {
+			args[name] = value
+		}
+	}
+	return args, nil
+}
+
+// inputType resolves a variable's declared type
+func (s *Schema) inputType(ref *typeRef) (Type, error) {
+	var t Type
+	if ref.list != nil {
+		inner, err := s.inputType(ref.list)
+		if err != nil {
+			return nil, err
+		}
+		t = NewList(inner)
+	} else {
+		named, ok := s.inputs[ref.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown input type %s", ref.name)
+		}
+		t = named
+	}
+
+	if ref.nonNull {
+		t = NewNonNull(t)
+	}
+	return t, nil
+}
+
+// coerceVariables applies defaults and checks variable values against
+// their declared types
+func (s *Schema) coerceVariables(op *operation, input map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{}, len(op.vars))
+	for _, def := range op.vars {
+		t, _ := s.inputType(def.typ)
+
+		value, ok := input[def.name]
+		if !ok {
+			if def.def == nil {
+				if _, required := t.(*NonNull); required {
+					return nil, fmt.Errorf("variable $%s of type %s is required", def.name, t)
+				}
+				continue
+			}
+			value = def.def
+		}
+
+		coerced, err := coerce(value, t, nil)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.name, err)
+		}
+		vars[def.name] = coerced
+	}
+	return vars, nil
+}
+
+// coerce converts an input value to the Go form of t, substituting
+// variables
+func coerce(value interface{}, t Type, vars map[string]interface{}) (interface{}, error) {
+	if name, ok := value.(variable); ok {
+		value = vars[string(name)]
+		// Variables are coerced already
+		if value == nil {
+			if _, required := t.(*NonNull); required {
+				return nil, fmt.Errorf("expected %s, found null", t)
+			}
+		}
+		return value, nil
+	}
+
+	if nonNull, ok := t.(*NonNull); ok {
+		if value == nil {
+			return nil, fmt.Errorf("expected %s, found null", t)
+		}
+		return coerce(value, nonNull.OfType, vars)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		result := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			v, err := coerce(item, t.OfType, vars)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	case *Enum:
+		var s string
+		switch v := value.(type) {
+		case enumValue:
+			s = string(v)
+		case string:
+			// Enum variables arrive as JSON strings
+			if vars == nil {
+				s = v
+			}
+		}
+		if !t.has(s) {
+			return nil, fmt.Errorf("expected one of %s for %s", strings.Join(t.Values, ", "), t.Name)
+		}
+		return s, nil
+	case *Scalar:
+		v, ok := t.Parse(value)
+		if !ok {
+			return nil, fmt.Errorf("expected %s, found %v", t.Name, value)
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("%s cannot be used as an input", t)
+}
+
+// executor resolves a validated operation
+type executor struct {
+	doc    *document
+	vars   map[string]interface{}
+	errors []*Error
+}
+
+func (e *executor) fieldError(path []interface{}, msg string) {
+	e.errors = append(e.errors, &Error{Message: msg, Path: path})
+}
+
+// selectionSet resolves the selected fields of an object. It returns
+// false when a non-null field resolved to null, nulling the object.
+func (e *executor) selectionSet(ctx context.Context, obj *Object, source interface{}, sels []selection, path []interface{}) (*orderedMap, bool) {
+	result := &orderedMap{values: make(map[string]interface{})}
+
+	for _, group := range e.collectFields(obj, sels, nil, make(map[string]bool)) {
+		key := group[0].responseKey()
+		fieldPath := extend(path, key)
+
+		if group[0].name == "__typename" {
+			result.set(key, obj.Name)
+			continue
+		}
+
+		def := obj.Fields[group[0].name]
+		value, ok := e.field(ctx, obj, def, source, group, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		result.set(key, value)
+	}
+
+	return result, true
+}
+
+// collectFields groups the fields to resolve by response key, applying
+// fragments and @skip/@include
+func (e *executor) collectFields(obj *Object, sels []selection, groups [][]*field, visited map[string]bool) [][]*field {
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *field:
+			if !e.included(sel.directives) {
+				continue
+			}
+			merged := false
+			for i, group := range groups {
+				if group[0].responseKey() == sel.responseKey() {
+					groups[i] = append(group, sel)
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				groups = append(groups, []*field{sel})
+			}
+		case *fragmentSpread:
+			if !e.included(sel.directives) || visited[sel.name] {
+				continue
+			}
+			visited[sel.name] = true
+			groups = e.collectFields(obj, e.doc.fragments[sel.name].selections, groups, visited)
+		case *inlineFragment:
+			if !e.included(sel.directives) {
+				continue
+			}
+			groups = e.collectFields(obj, sel.selections, groups, visited)
+		}
+	}
+	return groups
+}
+
+// included evaluates @skip and @include
+func (e *executor) included(dirs []*directive) bool {
+	for _, dir := range dirs {
+		cond, _ := coerce(dir.args["if"], NewNonNull(Boolean), e.vars)
+		b, _ := cond.(bool)
+		if (dir.name == "skip" && b) || (dir.name == "include" && !b) {
+			return false
+		}
+	}
+	return true
+}
+
+// field resolves and completes one field
+func (e *executor) field(ctx context.Context, obj *Object, def *Field, source interface{}, group []*field, path []interface{}) (interface{}, bool) {
+	args, err := arguments(def, group[0], e.vars)
+	if err != nil {
+		e.fieldError(path, err.Error())
+		return e.nullFor(def.Type)
+	}
+
+	resolve := def.Resolve
+	if resolve == nil {
+		resolve = defaultResolver(group[0].name)
+	}
+
+	value, err := resolve(ctx, source, args)
+	if err != nil {
+		e.fieldError(path, err.Error())
+		return e.nullFor(def.Type)
+	}
+
+	var sels []selection
+	for _, f := range group {
+		sels = append(sels, f.selections...)
+	}
+
+	return e.complete(ctx, def.Type, value, sels, path)
+}
+
+// nullFor returns null for a failed field, propagating it when the field
+// is non-null
+func (e *executor) nullFor(t Type) (interface{}, bool) {
+	_, required := t.(*NonNull)
+	return nil, !required
+}
+
+// complete converts a resolved value to the response form of t
+func (e *executor) complete(ctx context.Context, t Type, value interface{}, sels []selection, path []interface{}) (interface{}, bool) {
+	if nonNull, ok := t.(*NonNull); ok {
+		result, ok := e.complete(ctx, nonNull.OfType, value, sels, path)
+		if !ok {
+			return nil, false
+		}
+		if result == nil {
+			if !isNil(value) {
+				// The inner type already reported the error
+				return nil, false
+			}
+			e.fieldError(path, fmt.Sprintf("non-null field of type %s resolved to null", t))
+			return nil, false
+		}
+		return result, true
+	}
+
+	if isNil(value) {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case *List:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fieldError(path, fmt.Sprintf("expected a list for %s", t))
+			return nil, true
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			item, ok := e.complete(ctx, t.OfType, rv.Index(i).Interface(), sels, extend(path, i))
+			if !ok {
+				return nil, true
+			}
+			items[i] = item
+		}
+		return items, true
+	case *Scalar:
+		result, ok := t.Serialize(value)
+		if !ok {
+			e.fieldError(path, fmt.Sprintf("cannot represent %v as %s", value, t.Name))
+			return nil, true
+		}
+		return result, true
+	case *Enum:
+		return fmt.Sprint(value), true
+	case *Object:
+		result, ok := e.selectionSet(ctx, t, value, sels, path)
+		if !ok {
+			return nil, true
+		}
+		return result, true
+	}
+
+	return nil, true
+}
+
+// extend returns a copy of path with elem appended
+func extend(path []interface{}, elem interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+1), path...), elem)
+}
+
+func isVariable(v interface{}) bool {
+	_, ok := v.(variable)
+	return ok
+}
+
+func hasVariable(vars map[string]interface{}, v interface{}) bool {
+	_, ok := vars[string(v.(variable))]
+	return ok
+}
+
+// isNil reports whether v is nil or a nil pointer, map or slice
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// defaultResolver reads the struct field whose json tag matches the
+// snake_case form of name, or the map entry named either way
+func defaultResolver(name string) ResolveFunc {
+	key := snakeCase(name)
+	return func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+		if m, ok := source.(map[string]interface{}); ok {
+			if v, ok := m[name]; ok {
+				return v, nil
+			}
+			return m[key], nil
+		}
+
+		rv := reflect.ValueOf(source)
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return nil, nil
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return nil, nil
+		}
+
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			tag := strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]
+			if tag == key || (tag == "" && rt.Field(i).Name == name) {
+				return rv.Field(i).Interface(), nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// snakeCase converts a camelCase field name to snake_case
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// orderedMap is a response object that keeps fields in query order
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON encodes the fields in order
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
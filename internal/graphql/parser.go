@@ -0,0 +1,664 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// document is a parsed GraphQL request document
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is a query, mutation or subscription
+type operation struct {
+	kind       string
+	name       string
+	vars       []*varDef
+	selections []selection
+}
+
+// varDef declares an operation variable
+type varDef struct {
+	name string
+	typ  *typeRef
+	def  interface{}
+}
+
+// typeRef is a type as written in a variable definition
+type typeRef struct {
+	name    string
+	list    *typeRef
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.list != nil {
+		s = "[" + t.list.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// selection is a field, fragment spread or inline fragment
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	directives []*directive
+	selections []selection
+}
+
+// responseKey is the key a field's value is returned under
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+}
+
+type inlineFragment struct {
+	typeCond   string
+	directives []*directive
+	selections []selection
+}
+
+type fragment struct {
+	name       string
+	typeCond   string
+	selections []selection
+}
+
+type directive struct {
+	name string
+	args map[string]interface{}
+}
+
+// Parsed values other than scalars, lists and objects
+type (
+	variable  string
+	enumValue string
+)
+
+// Token kinds
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+// lexer splits a document into tokens
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	// Skip whitespace, commas and comments
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.pos++
+			continue
+		}
+		if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokPunct, value: "...", pos: start}, nil
+	case strings.IndexByte("!$()/:=@[]{}|&", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, value: string(c), pos: start}, nil
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokName, value: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		return l.string()
+	}
+
+	return token{}, fmt.Errorf("unexpected character %q at %d", c, start)
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokFloat
+		l.pos++
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+	return token{kind: kind, value: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+
+	// Block strings are taken verbatim
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return token{}, fmt.Errorf("unterminated string at %d", start)
+		}
+		value := l.src[l.pos+3 : l.pos+3+end]
+		l.pos += end + 6
+		return token{kind: tokString, value: value, pos: start}, nil
+	}
+
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos += 2
+			continue
+		case '\n':
+			return token{}, fmt.Errorf("unterminated string at %d", start)
+		case '"':
+			l.pos++
+			value, err := strconv.Unquote(l.src[start:l.pos])
+			if err != nil {
+				return token{}, fmt.Errorf("invalid string at %d", start)
+			}
+			return token{kind: tokString, value: value, pos: start}, nil
+		}
+		l.pos++
+	}
+	return token{}, fmt.Errorf("unterminated string at %d", start)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parser builds a document from tokens
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// parse parses an executable GraphQL document
+func parse(src string) (*document, error) {
+	p := &parser{lex: &lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek(tokPunct, "{"):
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: sels})
+		case p.peek(tokName, "query"), p.peek(tokName, "mutation"), p.peek(tokName, "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peek(tokName, "fragment"):
+			frag, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[frag.name]; ok {
+				return nil, fmt.Errorf("fragment %q is defined more than once", frag.name)
+			}
+			doc.fragments[frag.name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document contains no operations")
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(kind int, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return fmt.Errorf("unexpected end of document")
+	}
+	return fmt.Errorf("unexpected %q at %d", p.tok.value, p.tok.pos)
+}
+
+// expect consumes a punctuator
+func (p *parser) expect(value string) error {
+	if !p.peek(tokPunct, value) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+// skip consumes a punctuator if present
+func (p *parser) skip(value string) (bool, error) {
+	if !p.peek(tokPunct, value) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected()
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.tok.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokName {
+		op.name = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peek(tokPunct, ")") {
+			def, err := p.varDef()
+			if err != nil {
+				return nil, err
+			}
+			op.vars = append(op.vars, def)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sels
+
+	return op, nil
+}
+
+func (p *parser) varDef() (*varDef, error) {
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	typ, err := p.typeRef()
+	if err != nil {
+		return nil, err
+	}
+
+	def := &varDef{name: name, typ: typ}
+	if ok, err := p.skip("="); err != nil {
+		return nil, err
+	} else if ok {
+		if def.def, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	return def, nil
+}
+
+func (p *parser) typeRef() (*typeRef, error) {
+	t := &typeRef{}
+	if ok, err := p.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		if t.list, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		if t.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	nonNull, err := p.skip("!")
+	if err != nil {
+		return nil, err
+	}
+	t.nonNull = nonNull
+
+	return t, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, fmt.Errorf("fragment cannot be named \"on\"")
+	}
+	if !p.peek(tokName, "on") {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	typeCond, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{name: name, typeCond: typeCond, selections: sels}, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var sels []selection
+	for !p.peek(tokPunct, "}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, fmt.Errorf("empty selection set at %d", p.tok.pos)
+	}
+
+	return sels, p.advance()
+}
+
+func (p *parser) selection() (selection, error) {
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.kind == tokName && p.tok.value != "on" {
+			name := p.tok.value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			dirs, err := p.directives()
+			if err != nil {
+				return nil, err
+			}
+			return &fragmentSpread{name: name, directives: dirs}, nil
+		}
+
+		frag := &inlineFragment{}
+		if p.peek(tokName, "on") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if frag.typeCond, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if frag.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		if frag.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+		return frag, nil
+	}
+
+	f := &field{}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	f.name = name
+
+	if f.args, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokPunct, "{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (p *parser) arguments() (map[string]interface{}, error) {
+	ok, err := p.skip("(")
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	args := make(map[string]interface{})
+	for !p.peek(tokPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := args[name]; ok {
+			return nil, fmt.Errorf("argument %q is given more than once", name)
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(false); err != nil {
+			return nil, err
+		}
+	}
+
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var dirs []*directive
+	for p.peek(tokPunct, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, &directive{name: name, args: args})
+	}
+	return dirs, nil
+}
+
+// value parses an input value; constant values may not hold variables
+func (p *parser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, fmt.Errorf("variables are not allowed in default values")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return variable(name), nil
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			list := []interface{}{}
+			for !p.peek(tokPunct, "]") {
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, p.advance()
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			obj := make(map[string]interface{})
+			for !p.peek(tokPunct, "}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if obj[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			return obj, p.advance()
+		}
+	case tokInt:
+		n, err := strconv.Atoi(tok.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s", tok.value)
+		}
+		return n, p.advance()
+	case tokFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %s", tok.value)
+		}
+		return f, p.advance()
+	case tokString:
+		return tok.value, p.advance()
+	case tokName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, p.advance()
+	}
+
+	return nil, p.unexpected()
+}
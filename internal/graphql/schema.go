@@ -0,0 +1,264 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Type is a GraphQL output or input type
+type Type interface {
+	String() string
+}
+
+// ResolveFunc resolves a field's value from its parent object's value
+type ResolveFunc func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error)
+
+// Scalar is a leaf type
+type Scalar struct {
+	Name string
+	// Serialize converts a resolved value to its JSON form
+	Serialize func(v interface{}) (interface{}, bool)
+	// Parse converts an argument or variable value to its Go form
+	Parse func(v interface{}) (interface{}, bool)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// Enum is a leaf type with a fixed set of string values
+type Enum struct {
+	Name   string
+	Values []string
+}
+
+func (e *Enum) String() string { return e.Name }
+
+func (e *Enum) has(v string) bool {
+	for _, value := range e.Values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Object is a type with fields
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+// List is a list of another type
+type List struct {
+	OfType Type
+}
+
+func (l *List) String() string { return "[" + l.OfType.String() + "]" }
+
+// NonNull is another type that may not be null
+type NonNull struct {
+	OfType Type
+}
+
+func (n *NonNull) String() string { return n.OfType.String() + "!" }
+
+// NewList returns a list of t
+func NewList(t Type) *List { return &List{OfType: t} }
+
+// NewNonNull returns a non-null t
+func NewNonNull(t Type) *NonNull { return &NonNull{OfType: t} }
+
+// Field is a field of an object
+type Field struct {
+	Type Type
+	Args map[string]*Argument
+	// Resolve defaults to reading the source's struct field whose json
+	// tag is the snake_case form of the field name
+	Resolve ResolveFunc
+	// Cost is what resolving the field adds to a query's cost, such as
+	// the reads it takes
+	Cost int
+	// Multiplier returns, from the field's arguments, how many items it
+	// may return at most, each resolving its selections. Fields without
+	// one resolve them once.
+	Multiplier func(args map[string]interface{}) int
+}
+
+// Argument is a field argument
+type Argument struct {
+	Type    Type
+	Default interface{}
+}
+
+// Built-in scalars
+var (
+	String = &Scalar{
+		Name: "String",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			switch s := v.(type) {
+			case string:
+				return s, true
+			case fmt.Stringer:
+				return s.String(), true
+			}
+			return fmt.Sprint(v), true
+		},
+		Parse: func(v interface{}) (interface{}, bool) {
+			s, ok := v.(string)
+			return s, ok
+		},
+	}
+
+	ID = &Scalar{
+		Name:      "ID",
+		Serialize: String.Serialize,
+		Parse: func(v interface{}) (interface{}, bool) {
+			switch id := v.(type) {
+			case string:
+				return id, true
+			case int:
+				return strconv.Itoa(id), true
+			}
+			return nil, false
+		},
+	}
+
+	Int = &Scalar{
+		Name: "Int",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			switch n := v.(type) {
+			case int:
+				return n, true
+			case int32:
+				return int(n), true
+			case int64:
+				return n, true
+			}
+			return nil, false
+		},
+		Parse: func(v interface{}) (interface{}, bool) {
+			switch n := v.(type) {
+			case int:
+				return n, true
+			case float64:
+				// JSON variables decode as float64
+				if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+					return int(n), true
+				}
+			}
+			return nil, false
+		},
+	}
+
+	Float = &Scalar{
+		Name: "Float",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			switch n := v.(type) {
+			case float64:
+				return n, true
+			case float32:
+				return float64(n), true
+			case int:
+				return float64(n), true
+			case int64:
+				return float64(n), true
+			}
+			return nil, false
+		},
+		Parse: func(v interface{}) (interface{}, bool) {
+			switch n := v.(type) {
+			case float64:
+				return n, true
+			case int:
+				return float64(n), true
+			}
+			return nil, false
+		},
+	}
+
+	Boolean = &Scalar{
+		Name: "Boolean",
+		Serialize: func(v interface{}) (interface{}, bool) {
+			b, ok := v.(bool)
+			return b, ok
+		},
+		Parse: func(v interface{}) (interface{}, bool) {
+			b, ok := v.(bool)
+			return b, ok
+		},
+	}
+)
+
+// Limits bound the queries a schema executes
+type Limits struct {
+	// Depth is how deeply selections may nest
+	Depth int
+	// Fields is how many fields a query may select, counting those of a
+	// fragment each time it is spread
+	Fields int
+	// Cost is the most a query may cost, adding up the cost of its
+	// fields
+	Cost int
+}
+
+// Schema is an executable schema of read-only queries
+type Schema struct {
+	query *Object
+	// inputs are the types variables may be declared with
+	inputs map[string]Type
+	limits Limits
+}
+
+// NewSchema creates a schema rooted at query. Queries over any of the
+// limits are rejected before anything is resolved.
+func NewSchema(query *Object, limits Limits) *Schema {
+	s := &Schema{
+		query:  query,
+		limits: limits,
+		inputs: map[string]Type{
+			String.Name:  String,
+			ID.Name:      ID,
+			Int.Name:     Int,
+			Float.Name:   Float,
+			Boolean.Name: Boolean,
+		},
+	}
+	s.collectInputs(query, make(map[*Object]bool))
+	return s
+}
+
+// collectInputs registers the enums used by arguments
+func (s *Schema) collectInputs(obj *Object, seen map[*Object]bool) {
+	if seen[obj] {
+		return
+	}
+	seen[obj] = true
+
+	for _, f := range obj.Fields {
+		for _, arg := range f.Args {
+			if enum, ok := namedType(arg.Type).(*Enum); ok {
+				s.inputs[enum.Name] = enum
+			}
+		}
+		if child, ok := namedType(f.Type).(*Object); ok {
+			s.collectInputs(child, seen)
+		}
+	}
+}
+
+// namedType unwraps lists and non-null types
+func namedType(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *List:
+			t = w.OfType
+		case *NonNull:
+			t = w.OfType
+		default:
+			return t
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package chromaprint computes and compares Chromaprint audio
+// fingerprints, using the fpcalc tool
+package chromaprint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// ItemDuration is the length of audio in seconds each fingerprint item
+// covers
+const ItemDuration = 0.1238
+
+// Fingerprint is a raw Chromaprint fingerprint, one 32 bit item per
+// ItemDuration of audio
+type Fingerprint []uint32
+
+// Fingerprinter runs fpcalc over media files
+type Fingerprinter struct {
+	binaryPath string
+	length     time.Duration
+}
+
+// NewFingerprinter creates a fingerprinter using the fpcalc binary at
+// binaryPath, fingerprinting up to length of each file's audio
+func NewFingerprinter(binaryPath string, length time.Duration) *Fingerprinter {
+	return &Fingerprinter{
+		binaryPath: binaryPath,
+		length:     length,
+	}
+}
+
+// Ping checks that fpcalc is installed
+func (f *Fingerprinter) Ping(ctx context.Context) error {
+	if err := exec.CommandContext(ctx, f.binaryPath, "-version").Run(); err != nil {
+		return fmt.Errorf("fpcalc not available: %w", err)
+	}
+	return nil
+}
+
+// fpcalcResult is fpcalc's JSON output
+type fpcalcResult struct {
+	Duration    float64       `json:"duration"`
+	Fingerprint []json.Number `json:"fingerprint"`
+}
+
+// Fingerprint computes the fingerprint of the audio in the file at path
+func (f *Fingerprinter) Fingerprint(ctx context.Context, path string) (Fingerprint, error) {
+	args := []string{
+		"-raw",
+		"-json",
+		"-length", strconv.Itoa(int(f.length.Seconds())),
+		path,
+	}
+	output, err := exec.CommandContext(ctx, f.binaryPath, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("fpcalc failed: %w", err)
+	}
+
+	var result fpcalcResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse fpcalc output: %w", err)
+	}
+	return ParseItems(result.Fingerprint)
+}
+
+// ParseItems converts fingerprint items, which older fpcalc versions
+// print as signed integers, to unsigned ones
+func ParseItems(items []json.Number) (Fingerprint, error) {
+	fp := make(Fingerprint, len(items))
+	for i, item := range items {
+		n, err := strconv.ParseInt(item.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fingerprint item %q: %w", item, err)
+		}
+		fp[i] = uint32(n)
+	}
+	return fp, nil
+}
@@ -0,0 +1,30 @@
+package chromaprint
+
+import "math/bits"
+
+// Compare finds the alignment at which a and b are most alike, comparing
+// them wherever they overlap by at least minOverlap items. It returns the
+// share of matching bits at that alignment, from 0 to 1, where unrelated
+// audio scores around 0.5, and the offset in items of b within a. The
+// score is zero if they can't overlap by minOverlap.
+func Compare(a, b Fingerprint, minOverlap int) (score float64, offset int) {
+	if minOverlap < 1 {
+		minOverlap = 1
+	}
+
+	for off := minOverlap - len(b); off <= len(a)-minOverlap; off++ {
+		start, end := max(off, 0), min(off+len(b), len(a))
+		if end-start < minOverlap {
+			continue
+		}
+
+		differing := 0
+		for i := start; i < end; i++ {
+			differing += bits.OnesCount32(a[i] ^ b[i-off])
+		}
+		if s := 1 - float64(differing)/float64(32*(end-start)); s > score {
+			score, offset = s, off
+		}
+	}
+	return score, offset
+}
@@ -0,0 +1,49 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// KeySize is the size of an HLS AES-128 content key
+const KeySize = 16
+
+// GenerateKey returns a random AES-128 content key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// SequenceIV returns the implicit HLS IV for a media sequence number: the
+// sequence number as a big-endian 128-bit integer
+func SequenceIV(sequence uint64) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], sequence)
+	return iv
+}
+
+// EncryptSegment encrypts a whole segment with AES-128-CBC and PKCS#7
+// padding, as required by the HLS AES-128 method
+func EncryptSegment(data, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	padded := make([]byte, len(data), len(data)+padding)
+	copy(padded, data)
+	padded = append(padded, bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	out := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, padded)
+
+	return out, nil
+}
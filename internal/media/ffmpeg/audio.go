@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/streaming-service/internal/config"
 	"github.com/streaming-service/internal/domain"
@@ -15,8 +16,10 @@ import (
 // AudioProcessor implements MediaProcessor for audio files
 type AudioProcessor struct {
 	binaryPath      string
+	probePath       string
 	tempDir         string
 	segmentDuration int
+	singleFile      bool
 }
 
 // NewAudioProcessor creates a new audio processor
@@ -25,8 +28,10 @@ func NewAudioProcessor(cfg config.FFMPEGConfig) *AudioProcessor {
 
 	return &AudioProcessor{
 		binaryPath:      cfg.BinaryPath,
+		probePath:       strings.Replace(cfg.BinaryPath, "ffmpeg", "ffprobe", 1),
 		tempDir:         cfg.TempDir,
 		segmentDuration: cfg.SegmentDuration,
+		singleFile:      cfg.AudioSingleFile,
 	}
 }
 
@@ -40,6 +45,8 @@ func (p *AudioProcessor) Process(ctx context.Context, input *processor.ProcessIn
 
 	// Create strategy executor
 	executor := processor.NewStrategyExecutor()
+	executor.SetProgress(input.Progress)
+	executor.SetPercent(input.Percent)
 
 	// Add audio-specific strategies
 	audioProfiles := []processor.ProfileConfig{
@@ -49,12 +56,19 @@ func (p *AudioProcessor) Process(ctx context.Context, input *processor.ProcessIn
 	}
 
 	for _, profile := range audioProfiles {
-		strategy := processor.NewAudioHLSTranscodeStrategy(profile, p.segmentDuration)
+		strategy := processor.NewAudioHLSTranscodeStrategy(profile, p.segmentDuration, p.singleFile)
 		executor.AddStrategy(strategy)
 	}
 
+	// Probing only adds metadata and cover art, and lets progress be
+	// reported, so audio that can't be probed is still published
+	info, probeErr := probe(ctx, p.probePath, input.SourcePath)
+
 	// Create command executor
 	cmdExecutor := &ffmpegExecutor{binaryPath: p.binaryPath}
+	if probeErr == nil {
+		cmdExecutor.duration = info.Duration
+	}
 
 	// Execute all strategies
 	renditions, err := executor.Execute(ctx, input.SourcePath, outputDir, cmdExecutor)
@@ -68,11 +82,22 @@ func (p *AudioProcessor) Process(ctx context.Context, input *processor.ProcessIn
 		return nil, fmt.Errorf("failed to generate master playlist: %w", err)
 	}
 
-	return &processor.ProcessOutput{
+	output := &processor.ProcessOutput{
 		MediaID:    input.MediaID,
 		Renditions: renditions,
 		MasterPath: masterPath,
-	}, nil
+	}
+
+	// Art that can't be decoded is left out
+	if probeErr == nil {
+		output.Duration = info.Duration
+		output.Source = info.Source
+		if stream := info.coverArtStream(); stream >= 0 && len(input.CoverArtSizes) > 0 {
+			output.CoverArt, _ = extractCoverArt(ctx, p.binaryPath, input.SourcePath, outputDir, stream, input.CoverArtSizes)
+		}
+	}
+
+	return output, nil
 }
 
 // GetSupportedFormats returns supported audio formats
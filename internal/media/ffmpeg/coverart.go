@@ -0,0 +1,39 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// extractCoverArt writes the first frame of a source stream as a JPEG in
+// each size to dir/cover/<size>.jpg, scaled to fit within a square of that
+// size. stream is the stream's index in the source. It returns the paths
+// by size.
+func extractCoverArt(ctx context.Context, binaryPath, source, dir string, stream int, sizes []int) (map[int]string, error) {
+	coverDir := filepath.Join(dir, "cover")
+	if err := os.MkdirAll(coverDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cover art directory: %w", err)
+	}
+
+	executor := &ffmpegExecutor{binaryPath: binaryPath}
+	paths := make(map[int]string, len(sizes))
+	for _, size := range sizes {
+		path := filepath.Join(coverDir, fmt.Sprintf("%d.jpg", size))
+		args := []string{
+			"-y",
+			"-i", source,
+			"-map", fmt.Sprintf("0:%d", stream),
+			"-frames:v", "1",
+			"-vf", fmt.Sprintf("scale=w=%d:h=%d:force_original_aspect_ratio=decrease", size, size),
+			"-q:v", "2",
+			path,
+		}
+		if err := executor.Execute(ctx, args); err != nil {
+			return nil, fmt.Errorf("failed to extract %dpx cover art: %w", size, err)
+		}
+		paths[size] = path
+	}
+	return paths, nil
+}
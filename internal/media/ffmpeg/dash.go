@@ -0,0 +1,75 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/streaming-service/internal/media/manifest"
+	"github.com/streaming-service/internal/media/processor"
+)
+
+// generateDASHManifest writes a DASH manifest listing the CMAF segments of
+// each rendition's HLS playlist
+func generateDASHManifest(path string, renditions []processor.RenditionOutput, profiles []processor.ProfileConfig, duration float64) error {
+	reps := make([]manifest.DASHRepresentation, 0, len(renditions))
+	for i, r := range renditions {
+		playlist, err := os.ReadFile(r.PlaylistPath)
+		if err != nil {
+			return fmt.Errorf("failed to read playlist of %s: %w", r.Name, err)
+		}
+
+		// Renditions are made from the profiles in order
+		profile := profiles[i]
+		videoBitrate, _ := processor.ParseBitrate(profile.VideoBitrate)
+		audioBitrate, _ := processor.ParseBitrate(profile.AudioBitrate)
+
+		reps = append(reps, manifest.DASHRepresentation{
+			ID:        r.Name,
+			Width:     r.Width,
+			Height:    r.Height,
+			Bandwidth: videoBitrate + audioBitrate,
+			Codecs:    dashCodecs(profile.Codec, profile.Height),
+			Playlist:  playlist,
+			BaseURL:   r.Name + "/",
+		})
+	}
+
+	mpd, err := manifest.DASHManifest(reps, duration)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, mpd, 0644)
+}
+
+// dashCodecs returns the codecs string of a rendition's video, at the
+// level ffmpeg picks for its height, and AAC-LC audio
+func dashCodecs(codec string, height int) string {
+	switch codec {
+	case "h264", "libx264":
+		// High profile
+		level := 0x33
+		switch {
+		case height <= 480:
+			level = 0x1e
+		case height <= 720:
+			level = 0x1f
+		case height <= 1080:
+			level = 0x28
+		}
+		return fmt.Sprintf("avc1.6400%02x,mp4a.40.2", level)
+	case "hevc", "libx265":
+		// Main profile, Main tier
+		level := 153
+		switch {
+		case height <= 480:
+			level = 90
+		case height <= 720:
+			level = 93
+		case height <= 1080:
+			level = 120
+		}
+		return fmt.Sprintf("hvc1.1.6.L%d.90,mp4a.40.2", level)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,40 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// frameSize bounds the sides of sampled frames in pixels
+const frameSize = 1280
+
+// SampleFrames writes a JPEG frame of source's video every interval to
+// dir, up to max frames, returning their paths in order
+func (p *Processor) SampleFrames(ctx context.Context, source, dir string, interval time.Duration, max int) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create frames directory: %w", err)
+	}
+
+	executor := &ffmpegExecutor{binaryPath: p.binaryPath}
+	args := []string{
+		"-y",
+		"-i", source,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("fps=1/%g,scale=w=%d:h=%d:force_original_aspect_ratio=decrease", interval.Seconds(), frameSize, frameSize),
+		"-frames:v", fmt.Sprintf("%d", max),
+		"-q:v", "3",
+		filepath.Join(dir, "frame_%04d.jpg"),
+	}
+	if err := executor.Execute(ctx, args); err != nil {
+		return nil, fmt.Errorf("failed to sample frames: %w", err)
+	}
+
+	frames, err := filepath.Glob(filepath.Join(dir, "frame_*.jpg"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find frames: %w", err)
+	}
+	return frames, nil
+}
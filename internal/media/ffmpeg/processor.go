@@ -1,10 +1,12 @@
 package ffmpeg
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -22,9 +24,15 @@ type Processor struct {
 	probePath       string
 	tempDir         string
 	segmentDuration int
+	packaging       string
+	storyboard      config.StoryboardConfig
 	profiles        []config.TranscodeProfile
 }
 
+// packagingCMAF packages video renditions as CMAF segments shared by HLS
+// and DASH
+const packagingCMAF = "cmaf"
+
 // NewProcessor creates a new FFMPEG processor
 func NewProcessor(cfg config.FFMPEGConfig) *Processor {
 	// Ensure temp directory exists
@@ -35,10 +43,20 @@ func NewProcessor(cfg config.FFMPEGConfig) *Processor {
 		probePath:       strings.Replace(cfg.BinaryPath, "ffmpeg", "ffprobe", 1),
 		tempDir:         cfg.TempDir,
 		segmentDuration: cfg.SegmentDuration,
+		packaging:       cfg.Packaging,
+		storyboard:      cfg.Storyboard,
 		profiles:        cfg.Profiles,
 	}
 }
 
+// Ping checks that the FFMPEG binary can be run
+func (p *Processor) Ping(ctx context.Context) error {
+	if err := exec.CommandContext(ctx, p.binaryPath, "-version").Run(); err != nil {
+		return fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+	return nil
+}
+
 // Process processes the input media file
 func (p *Processor) Process(ctx context.Context, input *processor.ProcessInput) (*processor.ProcessOutput, error) {
 	// Create output directory
@@ -55,15 +73,22 @@ func (p *Processor) Process(ctx context.Context, input *processor.ProcessInput)
 
 	// Create strategy executor
 	executor := processor.NewStrategyExecutor()
+	executor.SetProgress(input.Progress)
+	executor.SetPercent(input.Percent)
 
 	// Add strategies based on profiles
 	for _, profile := range input.Profiles {
-		strategy := processor.NewHLSTranscodeStrategy(profile, p.segmentDuration)
-		executor.AddStrategy(strategy)
+		if p.packaging == packagingCMAF {
+			strategy := processor.NewCMAFTranscodeStrategy(profile, p.segmentDuration)
+			strategy.SetCENC(input.CENC)
+			executor.AddStrategy(strategy)
+		} else {
+			executor.AddStrategy(processor.NewHLSTranscodeStrategy(profile, p.segmentDuration))
+		}
 	}
 
 	// Create command executor
-	cmdExecutor := &ffmpegExecutor{binaryPath: p.binaryPath}
+	cmdExecutor := &ffmpegExecutor{binaryPath: p.binaryPath, duration: info.Duration}
 
 	// Execute all strategies
 	renditions, err := executor.Execute(ctx, input.SourcePath, outputDir, cmdExecutor)
@@ -77,11 +102,37 @@ func (p *Processor) Process(ctx context.Context, input *processor.ProcessInput)
 		return nil, fmt.Errorf("failed to generate master playlist: %w", err)
 	}
 
+	// CMAF segments are listed in a DASH manifest too
+	var dashPath string
+	if p.packaging == packagingCMAF {
+		dashPath = filepath.Join(outputDir, "manifest.mpd")
+		if err := generateDASHManifest(dashPath, renditions, input.Profiles, info.Duration); err != nil {
+			return nil, fmt.Errorf("failed to generate DASH manifest: %w", err)
+		}
+	}
+
+	// Cover art is optional, so media whose art can't be decoded is still
+	// published without it
+	var coverArt map[int]string
+	if stream := info.coverArtStream(); stream >= 0 && len(input.CoverArtSizes) > 0 {
+		coverArt, _ = extractCoverArt(ctx, p.binaryPath, input.SourcePath, outputDir, stream, input.CoverArtSizes)
+	}
+
+	// Storyboards are optional too
+	var storyboard *processor.StoryboardOutput
+	if p.storyboard.Enabled && len(info.Source.Video) > 0 && info.Duration > 0 {
+		storyboard, _ = generateStoryboard(ctx, p.binaryPath, input.SourcePath, outputDir, info, p.storyboard)
+	}
+
 	return &processor.ProcessOutput{
 		MediaID:    input.MediaID,
 		Renditions: renditions,
 		Duration:   info.Duration,
 		MasterPath: masterPath,
+		DASHPath:   dashPath,
+		Source:     info.Source,
+		CoverArt:   coverArt,
+		Storyboard: storyboard,
 		Metadata: map[string]interface{}{
 			"width":      info.Width,
 			"height":     info.Height,
@@ -112,10 +163,71 @@ type MediaInfo struct {
 	Bitrate   int
 	Codec     string
 	FrameRate float64
+	// Source is everything the probe found
+	Source *domain.SourceMetadata
+	// CoverArtStream is the index of the source's embedded cover art, or
+	// -1 if it has none
+	CoverArtStream int
+}
+
+// coverArtStream returns the stream cover art is taken from: the embedded
+// cover art, or else the first video stream. It returns -1 if there is
+// neither.
+func (i *MediaInfo) coverArtStream() int {
+	if i.CoverArtStream >= 0 {
+		return i.CoverArtStream
+	}
+	if len(i.Source.Video) > 0 {
+		return i.Source.Video[0].Index
+	}
+	return -1
+}
+
+// probeResult is the part of ffprobe's JSON output that is kept
+type probeResult struct {
+	Streams []struct {
+		Index            int    `json:"index"`
+		CodecType        string `json:"codec_type"`
+		CodecName        string `json:"codec_name"`
+		Profile          string `json:"profile"`
+		Level            int    `json:"level"`
+		Width            int    `json:"width"`
+		Height           int    `json:"height"`
+		RFrameRate       string `json:"r_frame_rate"`
+		BitRate          string `json:"bit_rate"`
+		PixFmt           string `json:"pix_fmt"`
+		BitsPerRawSample string `json:"bits_per_raw_sample"`
+		ColorSpace       string `json:"color_space"`
+		ColorTransfer    string `json:"color_transfer"`
+		ColorPrimaries   string `json:"color_primaries"`
+		Channels         int    `json:"channels"`
+		ChannelLayout    string `json:"channel_layout"`
+		SampleRate       string `json:"sample_rate"`
+		Tags             struct {
+			Language string `json:"language"`
+		} `json:"tags"`
+		SideDataList []struct {
+			SideDataType string `json:"side_data_type"`
+		} `json:"side_data_list"`
+		Disposition struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+		Size       string `json:"size"`
+	} `json:"format"`
 }
 
 // probe gets media information using ffprobe
 func (p *Processor) probe(ctx context.Context, path string) (*MediaInfo, error) {
+	return probe(ctx, p.probePath, path)
+}
+
+// probe gets media information using the ffprobe binary at probePath
+func probe(ctx context.Context, probePath, path string) (*MediaInfo, error) {
 	args := []string{
 		"-v", "quiet",
 		"-print_format", "json",
@@ -124,64 +236,120 @@ func (p *Processor) probe(ctx context.Context, path string) (*MediaInfo, error)
 		path,
 	}
 
-	cmd := exec.CommandContext(ctx, p.probePath, args...)
+	cmd := exec.CommandContext(ctx, probePath, args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("ffprobe failed: %w", err)
 	}
 
-	var probeResult struct {
-		Streams []struct {
-			CodecType  string `json:"codec_type"`
-			CodecName  string `json:"codec_name"`
-			Width      int    `json:"width"`
-			Height     int    `json:"height"`
-			RFrameRate string `json:"r_frame_rate"`
-		} `json:"streams"`
-		Format struct {
-			Duration string `json:"duration"`
-			BitRate  string `json:"bit_rate"`
-		} `json:"format"`
-	}
-
-	if err := json.Unmarshal(output, &probeResult); err != nil {
+	var result probeResult
+	if err := json.Unmarshal(output, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse probe result: %w", err)
 	}
 
-	info := &MediaInfo{}
-
-	// Parse duration
-	if dur, err := strconv.ParseFloat(probeResult.Format.Duration, 64); err == nil {
-		info.Duration = dur
+	source := &domain.SourceMetadata{
+		Container: result.Format.FormatName,
 	}
-
-	// Parse bitrate
-	if br, err := strconv.Atoi(probeResult.Format.BitRate); err == nil {
-		info.Bitrate = br
+	source.Duration, _ = strconv.ParseFloat(result.Format.Duration, 64)
+	source.Bitrate, _ = strconv.Atoi(result.Format.BitRate)
+	source.Size, _ = strconv.ParseInt(result.Format.Size, 10, 64)
+
+	info := &MediaInfo{
+		Duration:       source.Duration,
+		Bitrate:        source.Bitrate,
+		Source:         source,
+		CoverArtStream: -1,
 	}
 
-	// Find video stream
-	for _, stream := range probeResult.Streams {
-		if stream.CodecType == "video" {
-			info.Width = stream.Width
-			info.Height = stream.Height
-			info.Codec = stream.CodecName
-
-			// Parse frame rate (format: "30000/1001" or "30/1")
-			if parts := strings.Split(stream.RFrameRate, "/"); len(parts) == 2 {
-				num, _ := strconv.ParseFloat(parts[0], 64)
-				den, _ := strconv.ParseFloat(parts[1], 64)
-				if den > 0 {
-					info.FrameRate = num / den
+	for _, stream := range result.Streams {
+		bitrate, _ := strconv.Atoi(stream.BitRate)
+		switch stream.CodecType {
+		case "video":
+			// Cover art is reported as a single frame video stream
+			if stream.Disposition.AttachedPic == 1 {
+				if info.CoverArtStream < 0 {
+					info.CoverArtStream = stream.Index
+				}
+				continue
+			}
+			bitDepth, _ := strconv.Atoi(stream.BitsPerRawSample)
+			video := domain.VideoStreamInfo{
+				Index:          stream.Index,
+				Codec:          stream.CodecName,
+				Profile:        stream.Profile,
+				Level:          stream.Level,
+				Width:          stream.Width,
+				Height:         stream.Height,
+				FrameRate:      parseFrameRate(stream.RFrameRate),
+				Bitrate:        bitrate,
+				PixelFormat:    stream.PixFmt,
+				BitDepth:       bitDepth,
+				ColorSpace:     stream.ColorSpace,
+				ColorTransfer:  stream.ColorTransfer,
+				ColorPrimaries: stream.ColorPrimaries,
+			}
+			for _, side := range stream.SideDataList {
+				if side.SideDataType == "DOVI configuration record" {
+					video.HDRFormat = domain.HDRFormatDolbyVision
 				}
 			}
-			break
+			if video.HDRFormat == "" {
+				video.HDRFormat = hdrFormat(stream.ColorTransfer)
+			}
+			source.Video = append(source.Video, video)
+
+		case "audio":
+			sampleRate, _ := strconv.Atoi(stream.SampleRate)
+			source.Audio = append(source.Audio, domain.AudioStreamInfo{
+				Index:         stream.Index,
+				Codec:         stream.CodecName,
+				Profile:       stream.Profile,
+				Channels:      stream.Channels,
+				ChannelLayout: stream.ChannelLayout,
+				SampleRate:    sampleRate,
+				Bitrate:       bitrate,
+				Language:      stream.Tags.Language,
+			})
 		}
 	}
 
+	if len(source.Video) > 0 {
+		video := source.Video[0]
+		info.Width = video.Width
+		info.Height = video.Height
+		info.Codec = video.Codec
+		info.FrameRate = video.FrameRate
+	}
+
 	return info, nil
 }
 
+// parseFrameRate parses a frame rate such as "30000/1001" or "30/1"
+func parseFrameRate(s string) float64 {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return 0
+	}
+	num, _ := strconv.ParseFloat(parts[0], 64)
+	den, _ := strconv.ParseFloat(parts[1], 64)
+	if den <= 0 {
+		return 0
+	}
+	return num / den
+}
+
+// hdrFormat names the HDR format a transfer characteristic signals, or
+// returns empty for SDR
+func hdrFormat(transfer string) string {
+	switch transfer {
+	case "smpte2084":
+		return domain.HDRFormatHDR10
+	case "arib-std-b67":
+		return domain.HDRFormatHLG
+	}
+	return ""
+}
+
 // generateMasterPlaylist creates the master HLS playlist
 func (p *Processor) generateMasterPlaylist(path string, renditions []processor.RenditionOutput) error {
 	var buf bytes.Buffer
@@ -217,14 +385,69 @@ func (p *Processor) generateMasterPlaylist(path string, renditions []processor.R
 // ffmpegExecutor implements CommandExecutor for FFMPEG
 type ffmpegExecutor struct {
 	binaryPath string
+	// duration is the source's length in seconds, which progress is
+	// measured against. Progress isn't reported when it's unknown.
+	duration float64
 }
 
 func (e *ffmpegExecutor) Execute(ctx context.Context, args []string) error {
-	cmd := exec.CommandContext(ctx, e.binaryPath, args...)
-	cmd.Stderr = os.Stderr // Log FFMPEG errors
+	return e.ExecuteWithProgress(ctx, args, nil)
+}
+
+// ExecuteWithProgress runs FFMPEG, reporting how far through the source
+// it has got from its -progress output
+func (e *ffmpegExecutor) ExecuteWithProgress(ctx context.Context, args []string, report func(percent float64)) error {
+	if report == nil || e.duration <= 0 {
+		cmd := exec.CommandContext(ctx, e.binaryPath, args...)
+		cmd.Stderr = os.Stderr // Log FFMPEG errors
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("ffmpeg command failed: %w", err)
+		}
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, e.binaryPath, append([]string{"-progress", "pipe:1", "-nostats"}, args...)...)
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open ffmpeg progress: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ffmpeg command failed: %w", err)
+	}
+	readProgress(stdout, e.duration, report)
 
-	if err := cmd.Run(); err != nil {
+	if err := cmd.Wait(); err != nil {
 		return fmt.Errorf("ffmpeg command failed: %w", err)
 	}
 	return nil
 }
+
+// readProgress reports the output time of each of FFMPEG's key=value
+// progress reports, which end with a progress line, as a percent of the
+// duration
+func readProgress(r io.Reader, duration float64, report func(percent float64)) {
+	scanner := bufio.NewScanner(r)
+	outTime := -1.0
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "out_time_us":
+			// N/A until the first frame is written
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+				outTime = float64(us) / 1e6
+			}
+		case "progress":
+			if outTime >= 0 {
+				report(min(100, outTime/duration*100))
+			}
+		}
+	}
+	// Keep draining so FFMPEG never blocks on a full pipe
+	_, _ = io.Copy(io.Discard, r)
+}
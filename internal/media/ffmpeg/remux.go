@@ -0,0 +1,25 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+)
+
+// RemuxMP4 copies the streams of a local HLS playlist into an MP4 at
+// output without re-encoding them, moving the index to the front so it
+// plays while downloading
+func (p *Processor) RemuxMP4(ctx context.Context, playlist, output string) error {
+	executor := &ffmpegExecutor{binaryPath: p.binaryPath}
+	args := []string{
+		"-y",
+		"-i", playlist,
+		"-c", "copy",
+		"-bsf:a", "aac_adtstoasc",
+		"-movflags", "+faststart",
+		output,
+	}
+	if err := executor.Execute(ctx, args); err != nil {
+		return fmt.Errorf("failed to remux to MP4: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+)
+
+// ExtractAudio writes the first audio stream of source to output as mono
+// 16 kHz FLAC, which is all speech recognition needs
+func (p *Processor) ExtractAudio(ctx context.Context, source, output string) error {
+	executor := &ffmpegExecutor{binaryPath: p.binaryPath}
+	args := []string{
+		"-y",
+		"-i", source,
+		"-map", "0:a:0",
+		"-vn",
+		"-ac", "1",
+		"-ar", "16000",
+		"-c:a", "flac",
+		output,
+	}
+	if err := executor.Execute(ctx, args); err != nil {
+		return fmt.Errorf("failed to extract audio: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/internal/media/webvtt"
+)
+
+// storyboardDir is the directory under the output storyboards are
+// written to
+const storyboardDir = "thumbnails"
+
+// generateStoryboard writes thumbnails of the source's video every
+// interval, tiled into sprite sheets, to dir/thumbnails/ with a
+// storyboard.vtt mapping each interval to its tile
+func generateStoryboard(ctx context.Context, binaryPath, source, dir string, info *MediaInfo, cfg config.StoryboardConfig) (*processor.StoryboardOutput, error) {
+	if info.Width <= 0 || info.Height <= 0 {
+		return nil, fmt.Errorf("video has no dimensions")
+	}
+	outDir := filepath.Join(dir, storyboardDir)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storyboard directory: %w", err)
+	}
+
+	// Thumbnails keep the video's aspect ratio at an even height
+	width := cfg.Width
+	height := int(math.Round(float64(width)*float64(info.Height)/float64(info.Width)/2)) * 2
+	if height < 2 {
+		height = 2
+	}
+
+	executor := &ffmpegExecutor{binaryPath: binaryPath}
+	args := []string{
+		"-y",
+		"-i", source,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("fps=1/%g,scale=%d:%d,tile=%dx%d", cfg.Interval.Seconds(), width, height, cfg.Columns, cfg.Rows),
+		"-q:v", "5",
+		filepath.Join(outDir, "sprite_%04d.jpg"),
+	}
+	if err := executor.Execute(ctx, args); err != nil {
+		return nil, fmt.Errorf("failed to make storyboard: %w", err)
+	}
+
+	sprites, err := filepath.Glob(filepath.Join(outDir, "sprite_*.jpg"))
+	if err != nil || len(sprites) == 0 {
+		return nil, fmt.Errorf("failed to find storyboard sprites: %v", err)
+	}
+
+	vttPath := filepath.Join(outDir, "storyboard.vtt")
+	vtt := storyboardVTT(sprites, info.Duration, cfg, width, height)
+	if err := os.WriteFile(vttPath, vtt, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write storyboard: %w", err)
+	}
+
+	return &processor.StoryboardOutput{VTTPath: vttPath, SpritePaths: sprites}, nil
+}
+
+// storyboardVTT renders a WebVTT file with a cue per thumbnail, whose
+// text is its sprite sheet with a media fragment locating the tile
+func storyboardVTT(sprites []string, duration float64, cfg config.StoryboardConfig, width, height int) []byte {
+	perSprite := cfg.Columns * cfg.Rows
+	end := time.Duration(duration * float64(time.Second))
+	count := int(math.Ceil(duration / cfg.Interval.Seconds()))
+	if max := len(sprites) * perSprite; count > max {
+		count = max
+	}
+
+	cues := make([]webvtt.Cue, 0, count)
+	for i := 0; i < count; i++ {
+		tile := i % perSprite
+		cue := webvtt.Cue{
+			Start: time.Duration(i) * cfg.Interval,
+			End:   time.Duration(i+1) * cfg.Interval,
+			Text: fmt.Sprintf("%s#xywh=%d,%d,%d,%d", filepath.Base(sprites[i/perSprite]),
+				(tile%cfg.Columns)*width, (tile/cfg.Columns)*height, width, height),
+		}
+		if cue.End > end {
+			cue.End = end
+		}
+		cues = append(cues, cue)
+	}
+	return webvtt.Write(cues)
+}
@@ -0,0 +1,110 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// cueTagPrefix matches every SCTE-35 style cue tag we emit
+const cueTagPrefix = "#EXT-X-CUE-"
+
+// InsertCueMarkers rewrites an HLS media playlist with EXT-X-CUE-OUT and
+// EXT-X-CUE-IN tags at the segment boundaries closest to each ad break.
+// Existing cue tags are removed first, so the call is idempotent.
+func InsertCueMarkers(playlist []byte, breaks []domain.AdBreak) []byte {
+	lines := strings.Split(strings.TrimRight(string(playlist), "\n"), "\n")
+
+	var out bytes.Buffer
+	var position float64
+	next := 0         // index of the next break to open
+	openUntil := -1.0 // end of the currently open avail, or -1
+	const epsilon = 0.001
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, cueTagPrefix) {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#EXTINF:") {
+			duration := parseExtinf(line)
+			// Snap to this boundary when it's the closest one to the break
+			tolerance := duration/2 + epsilon
+
+			if openUntil >= 0 && position >= openUntil-tolerance {
+				out.WriteString("#EXT-X-CUE-IN\n")
+				openUntil = -1
+			}
+
+			for openUntil < 0 && next < len(breaks) && position >= breaks[next].Offset-tolerance {
+				b := breaks[next]
+				next++
+				out.WriteString(fmt.Sprintf("#EXT-X-CUE-OUT:DURATION=%s\n", formatSeconds(b.Duration)))
+				if b.Duration == 0 {
+					out.WriteString("#EXT-X-CUE-IN\n")
+					continue
+				}
+				openUntil = b.Offset + b.Duration
+			}
+
+			position += duration
+		}
+
+		if line == "#EXT-X-ENDLIST" && openUntil >= 0 {
+			out.WriteString("#EXT-X-CUE-IN\n")
+			openUntil = -1
+		}
+
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	return out.Bytes()
+}
+
+// DASHEventStream renders ad breaks as a DASH EventStream carrying
+// SCTE-35 splice inserts, for inclusion in an MPD Period
+func DASHEventStream(breaks []domain.AdBreak) string {
+	if len(breaks) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<EventStream schemeIdUri="urn:scte:scte35:2013:xml" timescale="1000">` + "\n")
+	for i, b := range breaks {
+		buf.WriteString(fmt.Sprintf(`  <Event presentationTime="%d" duration="%d" id="%d">`+"\n",
+			int64(b.Offset*1000), int64(b.Duration*1000), i+1))
+		buf.WriteString(`    <scte35:SpliceInfoSection xmlns:scte35="http://www.scte.org/schemas/35/2016">` + "\n")
+		buf.WriteString(fmt.Sprintf(`      <scte35:SpliceInsert spliceEventId="%d" outOfNetworkIndicator="true" spliceImmediateFlag="false">`+"\n", i+1))
+		if b.Duration > 0 {
+			buf.WriteString(fmt.Sprintf(`        <scte35:BreakDuration autoReturn="true" duration="%d"/>`+"\n", int64(b.Duration*90000)))
+		}
+		buf.WriteString("      </scte35:SpliceInsert>\n")
+		buf.WriteString("    </scte35:SpliceInfoSection>\n")
+		buf.WriteString("  </Event>\n")
+	}
+	buf.WriteString("</EventStream>\n")
+
+	return buf.String()
+}
+
+// parseExtinf returns the duration of an #EXTINF tag
+func parseExtinf(line string) float64 {
+	value := strings.TrimPrefix(line, "#EXTINF:")
+	if idx := strings.Index(value, ","); idx >= 0 {
+		value = value[:idx]
+	}
+	d, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// formatSeconds formats seconds without trailing zeros
+func formatSeconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
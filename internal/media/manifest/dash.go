@@ -0,0 +1,148 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// dashTimescale is the MPD time unit, milliseconds
+const dashTimescale = 1000
+
+// DASHRepresentation is a rendition listed in a DASH manifest, described
+// by its HLS media playlist of CMAF segments
+type DASHRepresentation struct {
+	ID        string
+	Width     int
+	Height    int
+	Bandwidth int
+	// Codecs is the RFC 6381 codecs string; empty leaves it out
+	Codecs string
+	// Playlist is the rendition's HLS media playlist, whose init section
+	// and segments the representation references
+	Playlist []byte
+	// BaseURL is the directory of the playlist relative to the manifest,
+	// such as "720p/"
+	BaseURL string
+}
+
+// DASHManifest renders a static MPD with one period listing each
+// representation's segments, in the order and with the durations of its
+// HLS playlist. The playlists must use CMAF segments, which DASH players
+// can fetch as they are.
+func DASHManifest(reps []DASHRepresentation, duration float64) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(fmt.Sprintf(`<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-main:2011" type="static" mediaPresentationDuration="%s" minBufferTime="PT2S">`+"\n",
+		dashDuration(duration)))
+	buf.WriteString(`  <Period id="0" start="PT0S">` + "\n")
+	buf.WriteString(`    <AdaptationSet mimeType="video/mp4" segmentAlignment="true" startWithSAP="1">` + "\n")
+
+	for _, rep := range reps {
+		init, segments, durations := parseCMAFPlaylist(rep.Playlist)
+		if init == "" {
+			return nil, fmt.Errorf("playlist of %s has no init section", rep.ID)
+		}
+
+		buf.WriteString(fmt.Sprintf(`      <Representation id="%s" bandwidth="%d" width="%d" height="%d"`,
+			xmlEscape(rep.ID), rep.Bandwidth, rep.Width, rep.Height))
+		if rep.Codecs != "" {
+			buf.WriteString(fmt.Sprintf(` codecs="%s"`, xmlEscape(rep.Codecs)))
+		}
+		buf.WriteString(">\n")
+		buf.WriteString(fmt.Sprintf(`        <SegmentList timescale="%d">`+"\n", dashTimescale))
+		buf.WriteString(fmt.Sprintf(`          <Initialization sourceURL="%s"/>`+"\n", xmlEscape(rep.BaseURL+init)))
+		buf.WriteString("          <SegmentTimeline>\n")
+		writeSegmentTimeline(&buf, durations)
+		buf.WriteString("          </SegmentTimeline>\n")
+		for _, segment := range segments {
+			buf.WriteString(fmt.Sprintf(`          <SegmentURL media="%s"/>`+"\n", xmlEscape(rep.BaseURL+segment)))
+		}
+		buf.WriteString("        </SegmentList>\n")
+		buf.WriteString("      </Representation>\n")
+	}
+
+	buf.WriteString("    </AdaptationSet>\n")
+	buf.WriteString("  </Period>\n")
+	buf.WriteString("</MPD>\n")
+
+	return buf.Bytes(), nil
+}
+
+// SetDASHEvents replaces the ad break events of a manifest rendered by
+// DASHManifest with breaks, so the call is idempotent
+func SetDASHEvents(mpd []byte, breaks []domain.AdBreak) []byte {
+	s := string(mpd)
+	if start := strings.Index(s, "<EventStream"); start >= 0 {
+		if end := strings.Index(s[start:], "</EventStream>\n"); end >= 0 {
+			s = s[:start] + s[start+end+len("</EventStream>\n"):]
+		}
+	}
+
+	events := DASHEventStream(breaks)
+	if events == "" {
+		return []byte(s)
+	}
+	period := strings.Index(s, "<Period")
+	if period < 0 {
+		return []byte(s)
+	}
+	insertAt := period + strings.Index(s[period:], ">\n") + 2
+	return []byte(s[:insertAt] + events + s[insertAt:])
+}
+
+// parseCMAFPlaylist returns the init section URI, segment URIs and
+// segment durations of an HLS media playlist
+func parseCMAFPlaylist(playlist []byte) (init string, segments []string, durations []float64) {
+	var duration float64
+	for _, line := range strings.Split(string(playlist), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			init = attributeValue(line, "URI")
+		case strings.HasPrefix(line, "#EXTINF:"):
+			duration = parseExtinf(line)
+		case !strings.HasPrefix(line, "#"):
+			segments = append(segments, line)
+			durations = append(durations, duration)
+		}
+	}
+	return init, segments, durations
+}
+
+// writeSegmentTimeline writes S elements for segment durations, folding
+// runs of equal durations into repeats
+func writeSegmentTimeline(buf *bytes.Buffer, durations []float64) {
+	var t int64
+	for i := 0; i < len(durations); {
+		d := int64(math.Round(durations[i] * dashTimescale))
+		repeat := 0
+		for i+repeat+1 < len(durations) && int64(math.Round(durations[i+repeat+1]*dashTimescale)) == d {
+			repeat++
+		}
+		if repeat > 0 {
+			buf.WriteString(fmt.Sprintf(`            <S t="%d" d="%d" r="%d"/>`+"\n", t, d, repeat))
+		} else {
+			buf.WriteString(fmt.Sprintf(`            <S t="%d" d="%d"/>`+"\n", t, d))
+		}
+		t += d * int64(repeat+1)
+		i += repeat + 1
+	}
+}
+
+// dashDuration formats seconds as an xs:duration
+func dashDuration(seconds float64) string {
+	return fmt.Sprintf("PT%.3fS", seconds)
+}
+
+// xmlEscape escapes a string for an XML attribute value
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
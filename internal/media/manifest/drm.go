@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// cencNamespace is the namespace of the cenc: elements and attributes of a
+// DASH manifest
+const cencNamespace = "urn:mpeg:cenc:2013"
+
+// CENCProtection describes the Common Encryption of a media item's
+// segments for signalling it in manifests
+type CENCProtection struct {
+	// KeyID is the content key's ID as a UUID
+	KeyID string
+	// Systems are the DRM systems licensing the key, in order
+	Systems []CENCSystem
+}
+
+// CENCSystem is a DRM system with the PSSH box players pass to it
+type CENCSystem struct {
+	// ID is the DASH-IF system ID
+	ID   string
+	PSSH []byte
+}
+
+// SetDASHProtection signals the Common Encryption of a manifest rendered
+// by DASHManifest, with a ContentProtection element for the scheme and
+// one per DRM system. It replaces any earlier protection, so the call is
+// idempotent.
+func SetDASHProtection(mpd []byte, p *CENCProtection) []byte {
+	s := string(mpd)
+	for {
+		start := strings.Index(s, "      <ContentProtection")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "\n")
+		if end < 0 {
+			break
+		}
+		s = s[:start] + s[start+end+1:]
+	}
+	if !strings.Contains(s, "xmlns:cenc=") {
+		s = strings.Replace(s, `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011"`,
+			`<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="`+cencNamespace+`"`, 1)
+	}
+
+	set := strings.Index(s, "<AdaptationSet")
+	if set < 0 {
+		return []byte(s)
+	}
+	insertAt := set + strings.Index(s[set:], ">\n") + 2
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf(`      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="%s"/>`+"\n",
+		xmlEscape(p.KeyID)))
+	for _, system := range p.Systems {
+		buf.WriteString(fmt.Sprintf(`      <ContentProtection schemeIdUri="urn:uuid:%s"><cenc:pssh>%s</cenc:pssh></ContentProtection>`+"\n",
+			xmlEscape(system.ID), base64.StdEncoding.EncodeToString(system.PSSH)))
+	}
+
+	return []byte(s[:insertAt] + buf.String() + s[insertAt:])
+}
+
+// InsertCENCKeyTags adds an #EXT-X-KEY tag per DRM system ahead of the
+// init section of an HLS media playlist of CMAF segments, so players with
+// those systems can play the encrypted segments. Earlier key tags are
+// replaced.
+func InsertCENCKeyTags(playlist []byte, p *CENCProtection) []byte {
+	lines := strings.Split(strings.TrimRight(string(playlist), "\n"), "\n")
+	keyID := strings.ReplaceAll(p.KeyID, "-", "")
+
+	var out bytes.Buffer
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-KEY:") {
+			continue
+		}
+		if strings.HasPrefix(line, "#EXT-X-MAP:") {
+			for _, system := range p.Systems {
+				out.WriteString(fmt.Sprintf("#EXT-X-KEY:METHOD=SAMPLE-AES-CTR,URI=\"data:text/plain;base64,%s\",KEYID=0x%s,KEYFORMAT=\"urn:uuid:%s\",KEYFORMATVERSIONS=\"1\"\n",
+					base64.StdEncoding.EncodeToString(system.PSSH), keyID, system.ID))
+			}
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	return out.Bytes()
+}
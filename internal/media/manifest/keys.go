@@ -0,0 +1,43 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// InsertKeyTags adds #EXT-X-KEY tags to an HLS media playlist so that
+// every segmentsPerKey segments switch to the next key URI. Segment IVs
+// are left implicit (derived from the media sequence number).
+func InsertKeyTags(playlist []byte, keyURIs []string, segmentsPerKey int) []byte {
+	if len(keyURIs) == 0 || segmentsPerKey <= 0 {
+		return playlist
+	}
+
+	lines := strings.Split(strings.TrimRight(string(playlist), "\n"), "\n")
+
+	var out bytes.Buffer
+	segment := 0
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-KEY:") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#EXTINF:") {
+			if segment%segmentsPerKey == 0 {
+				idx := segment / segmentsPerKey
+				if idx >= len(keyURIs) {
+					idx = len(keyURIs) - 1
+				}
+				out.WriteString(fmt.Sprintf("#EXT-X-KEY:METHOD=AES-128,URI=\"%s\"\n", keyURIs[idx]))
+			}
+			segment++
+		}
+
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	return out.Bytes()
+}
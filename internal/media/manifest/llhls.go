@@ -0,0 +1,182 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// LLPart is a partial segment of a low-latency HLS playlist
+type LLPart struct {
+	URI         string
+	Duration    float64
+	Independent bool
+}
+
+// LLSegment is a media segment and the parts it is made of. Parts are
+// only rendered for the most recent segments.
+type LLSegment struct {
+	URI      string
+	Duration float64
+	Parts    []LLPart
+	// Complete is false for the segment still being produced, which is
+	// rendered as parts only
+	Complete bool
+}
+
+// LLPlaylist describes a low-latency HLS media playlist
+type LLPlaylist struct {
+	MediaSequence  int64
+	TargetDuration int
+	PartTarget     float64
+	MapURI         string
+	Segments       []LLSegment
+	// PreloadHint is the URI of the next part, if any
+	PreloadHint string
+	// PartSegments is how many trailing segments carry EXT-X-PART tags
+	PartSegments int
+	Ended        bool
+}
+
+// RenderLL renders a low-latency HLS media playlist with partial
+// segments, a preload hint and blocking reload server control
+func RenderLL(p *LLPlaylist) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#EXT-X-VERSION:9\n")
+	buf.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", p.TargetDuration))
+	buf.WriteString(fmt.Sprintf("#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=%s\n", formatSeconds(3*p.PartTarget)))
+	buf.WriteString(fmt.Sprintf("#EXT-X-PART-INF:PART-TARGET=%s\n", formatSeconds(p.PartTarget)))
+	buf.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence))
+	buf.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	if p.MapURI != "" {
+		buf.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", p.MapURI))
+	}
+
+	partsFrom := len(p.Segments) - p.PartSegments
+	for i, seg := range p.Segments {
+		if i >= partsFrom {
+			for _, part := range seg.Parts {
+				buf.WriteString(fmt.Sprintf("#EXT-X-PART:DURATION=%s,URI=\"%s\"", formatSeconds(part.Duration), part.URI))
+				if part.Independent {
+					buf.WriteString(",INDEPENDENT=YES")
+				}
+				buf.WriteString("\n")
+			}
+		}
+
+		if seg.Complete {
+			buf.WriteString(fmt.Sprintf("#EXTINF:%s,\n", formatSeconds(seg.Duration)))
+			buf.WriteString(seg.URI)
+			buf.WriteString("\n")
+		}
+	}
+
+	if p.Ended {
+		buf.WriteString("#EXT-X-ENDLIST\n")
+	} else if p.PreloadHint != "" {
+		buf.WriteString(fmt.Sprintf("#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"%s\"\n", p.PreloadHint))
+	}
+
+	return buf.Bytes()
+}
+
+// RenderMedia renders a regular HLS media playlist from the complete
+// segments of a low-latency playlist
+func RenderMedia(p *LLPlaylist) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#EXT-X-VERSION:7\n")
+	buf.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", p.TargetDuration))
+	buf.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence))
+	buf.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	if p.MapURI != "" {
+		buf.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", p.MapURI))
+	}
+
+	for _, seg := range p.Segments {
+		if !seg.Complete {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("#EXTINF:%s,\n", formatSeconds(seg.Duration)))
+		buf.WriteString(seg.URI)
+		buf.WriteString("\n")
+	}
+
+	if p.Ended {
+		buf.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return buf.Bytes()
+}
+
+// TargetDuration returns the EXT-X-TARGETDURATION for a segment duration
+func TargetDuration(seconds float64) int {
+	return int(math.Ceil(seconds))
+}
+
+// MediaPlaylist is a parsed HLS media playlist
+type MediaPlaylist struct {
+	MediaSequence int64
+	MapURI        string
+	Segments      []MediaSegment
+	Ended         bool
+}
+
+// MediaSegment is a segment entry of a media playlist
+type MediaSegment struct {
+	URI      string
+	Duration float64
+}
+
+// ParseMedia parses the tags of an HLS media playlist needed to repackage
+// its segments
+func ParseMedia(playlist []byte) *MediaPlaylist {
+	p := &MediaPlaylist{}
+
+	var duration float64
+	for _, line := range strings.Split(string(playlist), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			p.MediaSequence, _ = strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			p.MapURI = attributeValue(line, "URI")
+		case strings.HasPrefix(line, "#EXTINF:"):
+			duration = parseExtinf(line)
+		case line == "#EXT-X-ENDLIST":
+			p.Ended = true
+		case strings.HasPrefix(line, "#"):
+		default:
+			p.Segments = append(p.Segments, MediaSegment{URI: line, Duration: duration})
+			duration = 0
+		}
+	}
+
+	return p
+}
+
+// attributeValue returns a (possibly quoted) attribute from a tag line
+func attributeValue(line, name string) string {
+	idx := strings.Index(line, name+"=")
+	if idx < 0 {
+		return ""
+	}
+	value := line[idx+len(name)+1:]
+	if strings.HasPrefix(value, "\"") {
+		value = value[1:]
+		if end := strings.Index(value, "\""); end >= 0 {
+			return value[:end]
+		}
+		return value
+	}
+	if end := strings.Index(value, ","); end >= 0 {
+		return value[:end]
+	}
+	return value
+}
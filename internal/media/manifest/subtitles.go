@@ -0,0 +1,116 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// subtitleGroup is the GROUP-ID of the subtitle renditions in a master
+// playlist
+const subtitleGroup = "subs"
+
+// SubtitlePlaylist returns an HLS media playlist serving a subtitle
+// track as WebVTT segments of durations seconds, named by
+// domain.SubtitleSegmentName
+func SubtitlePlaylist(durations []float64) []byte {
+	var target float64
+	for _, d := range durations {
+		target = math.Max(target, d)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#EXT-X-VERSION:3\n")
+	buf.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target))))
+	buf.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	buf.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	for i, d := range durations {
+		buf.WriteString(fmt.Sprintf("#EXTINF:%s,\n", formatSeconds(d)))
+		buf.WriteString(domain.SubtitleSegmentName(i) + "\n")
+	}
+	buf.WriteString("#EXT-X-ENDLIST\n")
+	return buf.Bytes()
+}
+
+// InsertSubtitleTracks rewrites a master playlist to list tracks as
+// subtitle renditions, in one group every variant refers to. Subtitle
+// renditions already listed are replaced, so the call is idempotent.
+func InsertSubtitleTracks(master []byte, tracks []domain.SubtitleTrack) []byte {
+	lines := strings.Split(strings.TrimRight(string(master), "\n"), "\n")
+
+	var out bytes.Buffer
+	inserted := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-MEDIA:") && strings.Contains(line, "TYPE=SUBTITLES") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !inserted {
+				for _, track := range tracks {
+					out.WriteString(subtitleMedia(track))
+				}
+				inserted = true
+			}
+			line = removeAttribute(line, "SUBTITLES")
+			if len(tracks) > 0 {
+				line += fmt.Sprintf(",SUBTITLES=%q", subtitleGroup)
+			}
+		}
+
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	return out.Bytes()
+}
+
+// subtitleMedia returns the EXT-X-MEDIA tag of a subtitle track
+func subtitleMedia(track domain.SubtitleTrack) string {
+	yesNo := func(b bool) string {
+		if b {
+			return "YES"
+		}
+		return "NO"
+	}
+	name := strings.ReplaceAll(track.Name, `"`, "'")
+	return fmt.Sprintf("#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=%q,NAME=%q,LANGUAGE=%q,DEFAULT=%s,AUTOSELECT=YES,FORCED=NO,URI=%q\n",
+		subtitleGroup, name, track.Language, yesNo(track.Default), domain.SubtitlePath(track.ID)+"playlist.m3u8")
+}
+
+// removeAttribute drops an attribute from a tag's attribute list
+func removeAttribute(line, name string) string {
+	tag, attrs, ok := strings.Cut(line, ":")
+	if !ok {
+		return line
+	}
+
+	var kept []string
+	for _, attr := range splitAttributes(attrs) {
+		if !strings.HasPrefix(attr, name+"=") {
+			kept = append(kept, attr)
+		}
+	}
+	return tag + ":" + strings.Join(kept, ",")
+}
+
+// splitAttributes splits an attribute list on the commas outside quoted
+// values
+func splitAttributes(attrs string) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i, c := range attrs {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, attrs[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, attrs[start:])
+}
@@ -0,0 +1,43 @@
+package manifest
+
+import (
+	"bytes"
+	"strings"
+)
+
+// PrefixURIs rewrites a master playlist so its relative URIs, on their own
+// lines or in URI attributes, resolve from a directory prefix levels up:
+// prefixing "v2/" turns "720p/playlist.m3u8" into "v2/720p/playlist.m3u8".
+// Absolute URIs are left as they are.
+func PrefixURIs(master []byte, prefix string) []byte {
+	if prefix == "" {
+		return master
+	}
+
+	lines := strings.Split(strings.TrimRight(string(master), "\n"), "\n")
+
+	var out bytes.Buffer
+	for _, line := range lines {
+		switch {
+		case line == "":
+		case !strings.HasPrefix(line, "#"):
+			line = prefixURI(line, prefix)
+		default:
+			if uri := attributeValue(line, "URI"); uri != "" {
+				line = strings.Replace(line, `URI="`+uri+`"`, `URI="`+prefixURI(uri, prefix)+`"`, 1)
+			}
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	return out.Bytes()
+}
+
+// prefixURI prefixes a URI unless it's absolute
+func prefixURI(uri, prefix string) string {
+	if strings.HasPrefix(uri, "/") || strings.Contains(uri, "://") {
+		return uri
+	}
+	return prefix + uri
+}
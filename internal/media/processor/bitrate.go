@@ -0,0 +1,25 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseBitrate parses an FFMPEG bitrate such as "5000k", "2.5M" or
+// "128000" into bits per second
+func ParseBitrate(s string) (int, error) {
+	value, multiplier := strings.TrimSpace(s), 1.0
+	switch {
+	case strings.HasSuffix(value, "k"), strings.HasSuffix(value, "K"):
+		value, multiplier = value[:len(value)-1], 1e3
+	case strings.HasSuffix(value, "M"):
+		value, multiplier = value[:len(value)-1], 1e6
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q", s)
+	}
+	return int(n * multiplier), nil
+}
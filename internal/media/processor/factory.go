@@ -25,8 +25,32 @@ type ProcessInput struct {
 	SourceReader io.Reader
 	OutputDir    string
 	Profiles     []ProfileConfig
+	// Progress, when set, is told as each rendition starts and finishes
+	Progress ProgressFunc
+	// Percent, when set, is told how far each rendition's transcode has
+	// got, where the command executor can report it
+	Percent PercentFunc
+	// CoverArtSizes, when set, has cover art made in each size from the
+	// source's embedded art or else its first video frame
+	CoverArtSizes []int
+	// CENC, when set, encrypts CMAF segments with Common Encryption
+	CENC *CENCKey
 }
 
+// CENCKey is a Common Encryption content key
+type CENCKey struct {
+	// KeyID is the 16 byte key ID
+	KeyID []byte
+	Key   []byte
+}
+
+// ProgressFunc receives transcoding progress for a rendition
+type ProgressFunc func(rendition string, done bool)
+
+// PercentFunc receives how far through the source, from 0 to 100, a
+// rendition's transcode has got
+type PercentFunc func(rendition string, percent float64)
+
 // ProfileConfig defines a processing profile
 type ProfileConfig struct {
 	Name         string
@@ -43,7 +67,27 @@ type ProcessOutput struct {
 	Renditions []RenditionOutput
 	Duration   float64
 	MasterPath string
-	Metadata   map[string]interface{}
+	// DASHPath is the DASH manifest referencing the renditions' segments,
+	// when they're packaged as CMAF
+	DASHPath string
+	Metadata map[string]interface{}
+	// Source is the probed metadata of the source, when the processor
+	// probes it
+	Source *domain.SourceMetadata
+	// CoverArt holds the paths of the cover art images by size, and is
+	// empty when the source has nothing to make cover art from
+	CoverArt map[int]string
+	// Storyboard holds the thumbnail sprite sheets for scrubbing, when
+	// the processor makes them
+	Storyboard *StoryboardOutput
+}
+
+// StoryboardOutput is a set of thumbnail sprite sheets with the WebVTT
+// file mapping time ranges to their tiles, which refers to the sheets by
+// file name
+type StoryboardOutput struct {
+	VTTPath     string
+	SpritePaths []string
 }
 
 // RenditionOutput represents a single rendition output
@@ -57,6 +57,80 @@ func (s *HLSTranscodeStrategy) BuildCommand(input, outputDir string) []string {
 	}
 }
 
+// CMAF file names within a rendition directory. Segments are numbered
+// from zero.
+const (
+	CMAFInitName       = "init.mp4"
+	CMAFSegmentPattern = "segment_%04d.m4s"
+)
+
+// CMAFTranscodeStrategy implements transcoding to fragmented MP4 (CMAF)
+// segments with an HLS playlist. A DASH manifest can reference the same
+// segments, so one set of files serves both.
+type CMAFTranscodeStrategy struct {
+	profile         ProfileConfig
+	segmentDuration int
+	cenc            *CENCKey
+}
+
+// NewCMAFTranscodeStrategy creates a new CMAF transcoding strategy
+func NewCMAFTranscodeStrategy(profile ProfileConfig, segmentDuration int) *CMAFTranscodeStrategy {
+	return &CMAFTranscodeStrategy{
+		profile:         profile,
+		segmentDuration: segmentDuration,
+	}
+}
+
+// SetCENC encrypts the segments with Common Encryption (cenc-aes-ctr)
+// under key
+func (s *CMAFTranscodeStrategy) SetCENC(key *CENCKey) {
+	s.cenc = key
+}
+
+func (s *CMAFTranscodeStrategy) GetName() string {
+	return s.profile.Name
+}
+
+func (s *CMAFTranscodeStrategy) GetProfile() ProfileConfig {
+	return s.profile
+}
+
+func (s *CMAFTranscodeStrategy) BuildCommand(input, outputDir string) []string {
+	playlistPath := fmt.Sprintf("%s/%s/playlist.m3u8", outputDir, s.profile.Name)
+	segmentPath := fmt.Sprintf("%s/%s/%s", outputDir, s.profile.Name, CMAFSegmentPattern)
+
+	args := []string{
+		"-i", input,
+		"-vf", fmt.Sprintf("scale=%d:%d", s.profile.Width, s.profile.Height),
+		"-c:v", s.profile.Codec,
+		"-b:v", s.profile.VideoBitrate,
+	}
+	if s.profile.Codec == "hevc" || s.profile.Codec == "libx265" {
+		// Apple players only accept HEVC in fMP4 tagged hvc1
+		args = append(args, "-tag:v", "hvc1")
+	}
+
+	args = append(args,
+		"-c:a", "aac",
+		"-b:a", s.profile.AudioBitrate,
+		"-hls_time", fmt.Sprintf("%d", s.segmentDuration),
+		"-hls_list_size", "0",
+		"-hls_playlist_type", "vod",
+		"-hls_segment_type", "fmp4",
+		"-hls_fmp4_init_filename", CMAFInitName,
+		"-hls_segment_filename", segmentPath,
+	)
+	if s.cenc != nil {
+		// Passed to the MP4 muxer writing the init section and segments
+		args = append(args, "-hls_segment_options", fmt.Sprintf("encryption_scheme=cenc-aes-ctr:encryption_key=%x:encryption_kid=%x", s.cenc.Key, s.cenc.KeyID))
+	}
+
+	return append(args,
+		"-f", "hls",
+		playlistPath,
+	)
+}
+
 // AudioTranscodeStrategy implements transcoding for audio-only content
 type AudioTranscodeStrategy struct {
 	profile ProfileConfig
@@ -89,17 +163,26 @@ func (s *AudioTranscodeStrategy) BuildCommand(input, outputDir string) []string
 	}
 }
 
+// AudioSingleFileName is the name of the media file of a rendition
+// packaged as a single file
+const AudioSingleFileName = "audio.aac"
+
 // AudioHLSTranscodeStrategy implements HLS transcoding for audio
 type AudioHLSTranscodeStrategy struct {
 	profile         ProfileConfig
 	segmentDuration int
+	singleFile      bool
 }
 
-// NewAudioHLSTranscodeStrategy creates a new audio HLS transcoding strategy
-func NewAudioHLSTranscodeStrategy(profile ProfileConfig, segmentDuration int) *AudioHLSTranscodeStrategy {
+// NewAudioHLSTranscodeStrategy creates a new audio HLS transcoding
+// strategy. With singleFile, the segments are written to one file and the
+// playlist addresses them with EXT-X-BYTERANGE, which saves an object and
+// a request per segment.
+func NewAudioHLSTranscodeStrategy(profile ProfileConfig, segmentDuration int, singleFile bool) *AudioHLSTranscodeStrategy {
 	return &AudioHLSTranscodeStrategy{
 		profile:         profile,
 		segmentDuration: segmentDuration,
+		singleFile:      singleFile,
 	}
 }
 
@@ -115,22 +198,31 @@ func (s *AudioHLSTranscodeStrategy) BuildCommand(input, outputDir string) []stri
 	playlistPath := fmt.Sprintf("%s/%s/playlist.m3u8", outputDir, s.profile.Name)
 	segmentPath := fmt.Sprintf("%s/%s/segment_%%04d.aac", outputDir, s.profile.Name)
 
-	return []string{
+	args := []string{
 		"-i", input,
 		"-vn", // No video
 		"-c:a", "aac",
 		"-b:a", s.profile.AudioBitrate,
 		"-hls_time", fmt.Sprintf("%d", s.segmentDuration),
 		"-hls_list_size", "0",
+	}
+	if s.singleFile {
+		segmentPath = fmt.Sprintf("%s/%s/%s", outputDir, s.profile.Name, AudioSingleFileName)
+		args = append(args, "-hls_flags", "single_file")
+	}
+
+	return append(args,
 		"-hls_segment_filename", segmentPath,
 		"-f", "hls",
 		playlistPath,
-	}
+	)
 }
 
 // StrategyExecutor manages and executes transcoding strategies
 type StrategyExecutor struct {
 	strategies []TranscodeStrategy
+	progress   ProgressFunc
+	percent    PercentFunc
 }
 
 // NewStrategyExecutor creates a new strategy executor
@@ -145,6 +237,17 @@ func (e *StrategyExecutor) AddStrategy(strategy TranscodeStrategy) {
 	e.strategies = append(e.strategies, strategy)
 }
 
+// SetProgress reports each strategy's rendition as it starts and finishes
+func (e *StrategyExecutor) SetProgress(fn ProgressFunc) {
+	e.progress = fn
+}
+
+// SetPercent reports how far each strategy's transcode has got, when the
+// command executor is a ProgressExecutor
+func (e *StrategyExecutor) SetPercent(fn PercentFunc) {
+	e.percent = fn
+}
+
 // GetStrategies returns all registered strategies
 func (e *StrategyExecutor) GetStrategies() []TranscodeStrategy {
 	return e.strategies
@@ -161,11 +264,19 @@ func (e *StrategyExecutor) Execute(ctx context.Context, input string, outputDir
 		default:
 		}
 
+		if e.progress != nil {
+			e.progress(strategy.GetName(), false)
+		}
+
 		args := strategy.BuildCommand(input, outputDir)
-		if err := executor.Execute(ctx, args); err != nil {
+		if err := e.run(ctx, strategy.GetName(), args, executor); err != nil {
 			return nil, fmt.Errorf("strategy %s failed: %w", strategy.GetName(), err)
 		}
 
+		if e.progress != nil {
+			e.progress(strategy.GetName(), true)
+		}
+
 		profile := strategy.GetProfile()
 		result := RenditionOutput{
 			Name:         profile.Name,
@@ -180,7 +291,25 @@ func (e *StrategyExecutor) Execute(ctx context.Context, input string, outputDir
 	return results, nil
 }
 
+// run executes a strategy's command, reporting its progress if it can
+func (e *StrategyExecutor) run(ctx context.Context, name string, args []string, executor CommandExecutor) error {
+	pe, ok := executor.(ProgressExecutor)
+	if e.percent == nil || !ok {
+		return executor.Execute(ctx, args)
+	}
+	return pe.ExecuteWithProgress(ctx, args, func(percent float64) {
+		e.percent(name, percent)
+	})
+}
+
 // CommandExecutor interface for executing commands
 type CommandExecutor interface {
 	Execute(ctx context.Context, args []string) error
 }
+
+// ProgressExecutor is a CommandExecutor that can report how far through
+// its input, from 0 to 100, a command has got
+type ProgressExecutor interface {
+	CommandExecutor
+	ExecuteWithProgress(ctx context.Context, args []string, report func(percent float64)) error
+}
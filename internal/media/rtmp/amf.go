@@ -0,0 +1,217 @@
+package rtmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AMF0 type markers
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0A
+	amfDate        = 0x0B
+	amfLongString  = 0x0C
+)
+
+// errAMFTruncated is returned for values cut short
+var errAMFTruncated = errors.New("truncated AMF0 value")
+
+// amfUndefinedValue encodes as AMF0 undefined, where nil encodes as null
+type amfUndefinedValue struct{}
+
+// decodeAMF decodes consecutive AMF0 values, such as a command's name,
+// transaction ID and arguments. Numbers decode as float64, objects and
+// ECMA arrays as map[string]interface{} and null and undefined as nil.
+func decodeAMF(data []byte) ([]interface{}, error) {
+	r := bytes.NewReader(data)
+	var values []interface{}
+	for r.Len() > 0 {
+		v, err := decodeAMFValue(r)
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func decodeAMFValue(r *bytes.Reader) (interface{}, error) {
+	marker, err := r.ReadByte()
+	if err != nil {
+		return nil, errAMFTruncated
+	}
+
+	switch marker {
+	case amfNumber:
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, errAMFTruncated
+		}
+		return math.Float64frombits(bits), nil
+	case amfBoolean:
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, errAMFTruncated
+		}
+		return b != 0, nil
+	case amfString:
+		return readAMFString(r)
+	case amfLongString:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, errAMFTruncated
+		}
+		return readAMFBytes(r, int(n))
+	case amfObject:
+		return readAMFProperties(r)
+	case amfECMAArray:
+		// The count is advisory; the properties end with an end marker
+		if _, err := r.Seek(4, 1); err != nil {
+			return nil, errAMFTruncated
+		}
+		return readAMFProperties(r)
+	case amfStrictArray:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, errAMFTruncated
+		}
+		if int(n) > r.Len() {
+			return nil, errAMFTruncated
+		}
+		items := make([]interface{}, 0, n)
+		for i := uint32(0); i < n; i++ {
+			v, err := decodeAMFValue(r)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case amfDate:
+		// Milliseconds since the epoch, then an unused time zone
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, errAMFTruncated
+		}
+		if _, err := r.Seek(2, 1); err != nil {
+			return nil, errAMFTruncated
+		}
+		return math.Float64frombits(bits), nil
+	case amfNull, amfUndefined:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported AMF0 type 0x%02x", marker)
+	}
+}
+
+// readAMFProperties reads an object's properties up to its end marker
+func readAMFProperties(r *bytes.Reader) (map[string]interface{}, error) {
+	props := make(map[string]interface{})
+	for {
+		key, err := readAMFString(r)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			marker, err := r.ReadByte()
+			if err != nil {
+				return nil, errAMFTruncated
+			}
+			if marker == amfObjectEnd {
+				return props, nil
+			}
+			if err := r.UnreadByte(); err != nil {
+				return nil, err
+			}
+		}
+		v, err := decodeAMFValue(r)
+		if err != nil {
+			return nil, err
+		}
+		props[key] = v
+	}
+}
+
+func readAMFString(r *bytes.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", errAMFTruncated
+	}
+	return readAMFBytes(r, int(n))
+}
+
+func readAMFBytes(r *bytes.Reader, n int) (string, error) {
+	if n > r.Len() {
+		return "", errAMFTruncated
+	}
+	b := make([]byte, n)
+	if _, err := r.Read(b); err != nil {
+		return "", errAMFTruncated
+	}
+	return string(b), nil
+}
+
+// encodeAMF encodes values as consecutive AMF0 values. Maps are written
+// as objects with their keys sorted.
+func encodeAMF(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		writeAMFValue(&buf, v)
+	}
+	return buf.Bytes()
+}
+
+func writeAMFValue(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(amfNull)
+	case amfUndefinedValue:
+		buf.WriteByte(amfUndefined)
+	case bool:
+		buf.WriteByte(amfBoolean)
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case int:
+		writeAMFValue(buf, float64(v))
+	case float64:
+		buf.WriteByte(amfNumber)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case string:
+		buf.WriteByte(amfString)
+		writeAMFKey(buf, v)
+	case map[string]interface{}:
+		buf.WriteByte(amfObject)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeAMFKey(buf, key)
+			writeAMFValue(buf, v[key])
+		}
+		buf.Write([]byte{0, 0, amfObjectEnd})
+	default:
+		panic(fmt.Sprintf("rtmp: cannot encode %T as AMF0", v))
+	}
+}
+
+// writeAMFKey writes a string without its type marker, as object keys are
+func writeAMFKey(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
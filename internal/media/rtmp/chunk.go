@@ -0,0 +1,223 @@
+package rtmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// defaultChunkSize is the chunk size both sides start with
+	defaultChunkSize = 128
+	// maxChunkSize bounds the chunk size a peer may set
+	maxChunkSize = 1 << 24
+	// extendedTimestamp marks a timestamp carried in 4 extra bytes
+	extendedTimestamp = 0xFFFFFF
+)
+
+// chunkStream is the header state of one chunk stream, from which later
+// chunks' compressed headers are expanded, and its partial message
+type chunkStream struct {
+	timestamp uint32
+	delta     uint32
+	length    uint32
+	typeID    uint8
+	streamID  uint32
+	extended  bool
+
+	buf []byte
+}
+
+// chunkReader reassembles messages from interleaved chunks
+type chunkReader struct {
+	r         *bufio.Reader
+	chunkSize uint32
+	streams   map[uint32]*chunkStream
+}
+
+func newChunkReader(r io.Reader) *chunkReader {
+	return &chunkReader{
+		r:         bufio.NewReader(r),
+		chunkSize: defaultChunkSize,
+		streams:   make(map[uint32]*chunkStream),
+	}
+}
+
+// readMessage reads chunks until a message is complete
+func (c *chunkReader) readMessage() (*Message, error) {
+	for {
+		msg, err := c.readChunk()
+		if err != nil || msg != nil {
+			return msg, err
+		}
+	}
+}
+
+// readChunk reads one chunk, returning the message it completes, if any
+func (c *chunkReader) readChunk() (*Message, error) {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	format := b >> 6
+	csid := uint32(b & 0x3F)
+	switch csid {
+	case 0:
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		csid = 64 + uint32(b)
+	case 1:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return nil, err
+		}
+		csid = 64 + uint32(ext[0]) + uint32(ext[1])<<8
+	}
+
+	cs, ok := c.streams[csid]
+	if !ok {
+		if format != 0 {
+			return nil, fmt.Errorf("chunk stream %d starts without a full header", csid)
+		}
+		cs = &chunkStream{}
+		c.streams[csid] = cs
+	}
+
+	// Type 3 chunks continue a message or start one like the last
+	starting := len(cs.buf) == 0
+	var header [11]byte
+	sizes := [4]int{11, 7, 3, 0}
+	if _, err := io.ReadFull(c.r, header[:sizes[format]]); err != nil {
+		return nil, err
+	}
+
+	var ts uint32
+	if format < 3 {
+		ts = uint24(header[0:3])
+		cs.extended = ts == extendedTimestamp
+	}
+	if cs.extended {
+		var ext [4]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return nil, err
+		}
+		if format < 3 || starting {
+			ts = binary.BigEndian.Uint32(ext[:])
+		}
+	}
+
+	switch format {
+	case 0:
+		cs.length = uint24(header[3:6])
+		cs.typeID = header[6]
+		cs.streamID = binary.LittleEndian.Uint32(header[7:11])
+		cs.timestamp = ts
+		cs.delta = 0
+	case 1:
+		cs.length = uint24(header[3:6])
+		cs.typeID = header[6]
+		cs.delta = ts
+		cs.timestamp += ts
+	case 2:
+		cs.delta = ts
+		cs.timestamp += ts
+	case 3:
+		if starting {
+			if cs.extended {
+				cs.delta = ts
+			}
+			cs.timestamp += cs.delta
+		}
+	}
+	if format < 3 && !starting {
+		return nil, fmt.Errorf("chunk stream %d interrupted mid-message", csid)
+	}
+
+	n := cs.length - uint32(len(cs.buf))
+	if n > c.chunkSize {
+		n = c.chunkSize
+	}
+	if starting {
+		cs.buf = make([]byte, 0, cs.length)
+	}
+	start := len(cs.buf)
+	cs.buf = cs.buf[:start+int(n)]
+	if _, err := io.ReadFull(c.r, cs.buf[start:]); err != nil {
+		return nil, err
+	}
+	if uint32(len(cs.buf)) < cs.length {
+		return nil, nil
+	}
+
+	msg := &Message{
+		Type:      cs.typeID,
+		Timestamp: cs.timestamp,
+		StreamID:  cs.streamID,
+		Payload:   cs.buf,
+	}
+	cs.buf = nil
+	return msg, nil
+}
+
+// abort discards the partial message of a chunk stream
+func (c *chunkReader) abort(csid uint32) {
+	if cs, ok := c.streams[csid]; ok {
+		cs.buf = nil
+	}
+}
+
+// chunkWriter splits messages into chunks
+type chunkWriter struct {
+	w         *bufio.Writer
+	chunkSize uint32
+}
+
+func newChunkWriter(w io.Writer) *chunkWriter {
+	return &chunkWriter{w: bufio.NewWriter(w), chunkSize: defaultChunkSize}
+}
+
+// writeMessage writes a message on a chunk stream below 64, with a full
+// header on its first chunk, and flushes it
+func (c *chunkWriter) writeMessage(csid uint32, msg *Message) error {
+	var header [12]byte
+	header[0] = byte(csid)
+	putUint24(header[1:4], msg.Timestamp)
+	putUint24(header[4:7], uint32(len(msg.Payload)))
+	header[7] = msg.Type
+	binary.LittleEndian.PutUint32(header[8:12], msg.StreamID)
+	if _, err := c.w.Write(header[:]); err != nil {
+		return err
+	}
+
+	payload := msg.Payload
+	for {
+		n := uint32(len(payload))
+		if n > c.chunkSize {
+			n = c.chunkSize
+		}
+		if _, err := c.w.Write(payload[:n]); err != nil {
+			return err
+		}
+		payload = payload[n:]
+		if len(payload) == 0 {
+			break
+		}
+		if err := c.w.WriteByte(0xC0 | byte(csid)); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v >> 16)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v)
+}
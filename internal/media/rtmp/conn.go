@@ -0,0 +1,355 @@
+package rtmp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Message types
+const (
+	typeSetChunkSize     = 1
+	typeAbort            = 2
+	typeAck              = 3
+	typeUserControl      = 4
+	typeWindowAckSize    = 5
+	typeSetPeerBandwidth = 6
+	TypeAudio            = 8
+	TypeVideo            = 9
+	typeDataAMF3         = 15
+	typeCommandAMF3      = 17
+	TypeData             = 18
+	typeCommand          = 20
+)
+
+// Chunk streams of messages sent to the client
+const (
+	csidControl = 2
+	csidCommand = 3
+	csidStatus  = 5
+)
+
+const (
+	handshakeSize = 1536
+	// windowAckSize is the window acknowledged by, and asked of, the
+	// client, and the peer bandwidth it is granted
+	windowAckSize = 2500000
+	// serverChunkSize is the chunk size of messages sent to the client
+	serverChunkSize = 4096
+	// publishStreamID is the message stream created for publishing
+	publishStreamID = 1
+)
+
+// errUnpublished ends a connection whose client stopped publishing
+var errUnpublished = errors.New("client stopped publishing")
+
+// Message is an RTMP message, such as an audio or video frame
+type Message struct {
+	Type      uint8
+	Timestamp uint32
+	StreamID  uint32
+	Payload   []byte
+}
+
+// IsKeyframe reports whether the message is an FLV video keyframe
+func (m *Message) IsKeyframe() bool {
+	return m.Type == TypeVideo && len(m.Payload) > 0 && m.Payload[0]>>4 == 1
+}
+
+// conn is a client connection, which may publish a single stream
+type conn struct {
+	nc          net.Conn
+	handler     Handler
+	idleTimeout time.Duration
+
+	reader *chunkReader
+	writer *chunkWriter
+
+	// Bytes received, and acknowledged, for the client's ack window
+	received uint32
+	acked    uint32
+	window   uint32
+
+	app       string
+	publisher Publisher
+}
+
+// countingReader counts the bytes read from a connection
+type countingReader struct {
+	r io.Reader
+	n *uint32
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += uint32(n)
+	return n, err
+}
+
+func newConn(nc net.Conn, handler Handler, idleTimeout time.Duration) *conn {
+	c := &conn{
+		nc:          nc,
+		handler:     handler,
+		idleTimeout: idleTimeout,
+		writer:      newChunkWriter(nc),
+	}
+	c.reader = newChunkReader(countingReader{r: nc, n: &c.received})
+	return c
+}
+
+// serve runs the connection until the client leaves or ctx is cancelled.
+// A stream being published is closed with the reason it ended.
+func (c *conn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		c.nc.Close()
+	}()
+
+	err := c.run(ctx)
+	if c.publisher != nil {
+		if errors.Is(err, errUnpublished) {
+			err = nil
+		} else if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		c.publisher.Close(err)
+	}
+}
+
+func (c *conn) run(ctx context.Context) error {
+	if err := c.deadline(); err != nil {
+		return err
+	}
+	if err := c.handshake(); err != nil {
+		return fmt.Errorf("handshake failed: %w", err)
+	}
+
+	for {
+		if err := c.deadline(); err != nil {
+			return err
+		}
+		msg, err := c.reader.readMessage()
+		if err != nil {
+			return err
+		}
+		if err := c.acknowledge(); err != nil {
+			return err
+		}
+		if err := c.handleMessage(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// deadline drops clients that send nothing for the idle timeout
+func (c *conn) deadline() error {
+	if c.idleTimeout <= 0 {
+		return nil
+	}
+	return c.nc.SetDeadline(time.Now().Add(c.idleTimeout))
+}
+
+// handshake performs the plain handshake: C0 and C1 are answered with S0,
+// S1 and an echo of C1, then C2 is read. Clients accept an S1 without the
+// digest of the Flash Player 9 handshake when its version bytes are zero.
+func (c *conn) handshake() error {
+	c01 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(c.reader.r, c01); err != nil {
+		return err
+	}
+	if c01[0] != 3 {
+		return fmt.Errorf("unsupported RTMP version %d", c01[0])
+	}
+
+	s := make([]byte, 1+2*handshakeSize)
+	s[0] = 3
+	s1 := s[1 : 1+handshakeSize]
+	if _, err := rand.Read(s1[8:]); err != nil {
+		return err
+	}
+	copy(s[1+handshakeSize:], c01[1:])
+	if _, err := c.nc.Write(s); err != nil {
+		return err
+	}
+
+	c2 := make([]byte, handshakeSize)
+	_, err := io.ReadFull(c.reader.r, c2)
+	return err
+}
+
+// acknowledge sends an acknowledgement each time a window of bytes has
+// been received
+func (c *conn) acknowledge() error {
+	if c.window == 0 || c.received-c.acked < c.window {
+		return nil
+	}
+	c.acked = c.received
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, c.received)
+	return c.writer.writeMessage(csidControl, &Message{Type: typeAck, Payload: payload})
+}
+
+func (c *conn) handleMessage(ctx context.Context, msg *Message) error {
+	switch msg.Type {
+	case typeSetChunkSize:
+		if len(msg.Payload) < 4 {
+			return fmt.Errorf("invalid chunk size message")
+		}
+		size := binary.BigEndian.Uint32(msg.Payload) & 0x7FFFFFFF
+		if size == 0 || size > maxChunkSize {
+			return fmt.Errorf("invalid chunk size %d", size)
+		}
+		c.reader.chunkSize = size
+	case typeAbort:
+		if len(msg.Payload) >= 4 {
+			c.reader.abort(binary.BigEndian.Uint32(msg.Payload))
+		}
+	case typeWindowAckSize:
+		if len(msg.Payload) >= 4 {
+			c.window = binary.BigEndian.Uint32(msg.Payload)
+		}
+	case typeUserControl:
+		// Ping requests are answered so clients don't drop the connection
+		if len(msg.Payload) >= 6 && binary.BigEndian.Uint16(msg.Payload) == 6 {
+			pong := append([]byte{0, 7}, msg.Payload[2:6]...)
+			return c.writer.writeMessage(csidControl, &Message{Type: typeUserControl, Payload: pong})
+		}
+	case typeCommand:
+		return c.handleCommand(ctx, msg)
+	case typeCommandAMF3:
+		// AMF3 commands are AMF0 after a format byte
+		if len(msg.Payload) > 0 {
+			msg.Payload = msg.Payload[1:]
+		}
+		return c.handleCommand(ctx, msg)
+	case TypeAudio, TypeVideo, TypeData:
+		if c.publisher == nil {
+			return nil
+		}
+		return c.publisher.WriteMessage(msg)
+	case typeDataAMF3:
+		if c.publisher == nil || len(msg.Payload) == 0 {
+			return nil
+		}
+		msg.Type = TypeData
+		msg.Payload = msg.Payload[1:]
+		return c.publisher.WriteMessage(msg)
+	}
+	return nil
+}
+
+func (c *conn) handleCommand(ctx context.Context, msg *Message) error {
+	values, err := decodeAMF(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("invalid command: %w", err)
+	}
+	if len(values) < 2 {
+		return fmt.Errorf("invalid command")
+	}
+	name, _ := values[0].(string)
+	txID, _ := values[1].(float64)
+	args := values[2:]
+
+	switch name {
+	case "connect":
+		if len(args) > 0 {
+			if props, ok := args[0].(map[string]interface{}); ok {
+				c.app, _ = props["app"].(string)
+			}
+		}
+		return c.connect(txID)
+	case "releaseStream", "FCPublish":
+		return c.result(txID, nil, amfUndefinedValue{})
+	case "createStream":
+		return c.result(txID, nil, publishStreamID)
+	case "publish":
+		var streamName string
+		if len(args) > 1 {
+			streamName, _ = args[1].(string)
+		}
+		return c.publish(ctx, msg.StreamID, streamName)
+	case "FCUnpublish", "deleteStream", "closeStream":
+		if c.publisher != nil {
+			return errUnpublished
+		}
+	case "play":
+		return fmt.Errorf("playback is not supported")
+	}
+	return nil
+}
+
+// connect accepts the client's connection to an application
+func (c *conn) connect(txID float64) error {
+	ack := make([]byte, 4)
+	binary.BigEndian.PutUint32(ack, windowAckSize)
+	if err := c.writer.writeMessage(csidControl, &Message{Type: typeWindowAckSize, Payload: ack}); err != nil {
+		return err
+	}
+
+	// Dynamic limit type
+	bandwidth := append(append([]byte{}, ack...), 2)
+	if err := c.writer.writeMessage(csidControl, &Message{Type: typeSetPeerBandwidth, Payload: bandwidth}); err != nil {
+		return err
+	}
+
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, serverChunkSize)
+	if err := c.writer.writeMessage(csidControl, &Message{Type: typeSetChunkSize, Payload: size}); err != nil {
+		return err
+	}
+	c.writer.chunkSize = serverChunkSize
+
+	return c.result(txID,
+		map[string]interface{}{
+			"fmsVer":       "FMS/3,0,1,123",
+			"capabilities": 31,
+		},
+		map[string]interface{}{
+			"level":          "status",
+			"code":           "NetConnection.Connect.Success",
+			"description":    "Connection succeeded.",
+			"objectEncoding": 0,
+		},
+	)
+}
+
+// publish hands a stream to the handler, rejecting it when the handler
+// fails. The stream name is passed as sent, including any query string
+// the encoder appended.
+func (c *conn) publish(ctx context.Context, streamID uint32, name string) error {
+	if c.publisher != nil {
+		return fmt.Errorf("already publishing")
+	}
+
+	publisher, err := c.handler.Publish(ctx, c.app, name)
+	if err != nil {
+		_ = c.status(streamID, "error", "NetStream.Publish.BadName", err.Error())
+		return fmt.Errorf("publish rejected: %w", err)
+	}
+	c.publisher = publisher
+
+	return c.status(streamID, "status", "NetStream.Publish.Start", "Publishing started.")
+}
+
+// result replies to a command
+func (c *conn) result(txID float64, values ...interface{}) error {
+	payload := encodeAMF(append([]interface{}{"_result", txID}, values...)...)
+	return c.writer.writeMessage(csidCommand, &Message{Type: typeCommand, Payload: payload})
+}
+
+// status sends an onStatus event for a stream
+func (c *conn) status(streamID uint32, level, code, description string) error {
+	payload := encodeAMF("onStatus", 0, nil, map[string]interface{}{
+		"level":       level,
+		"code":        code,
+		"description": description,
+	})
+	return c.writer.writeMessage(csidStatus, &Message{Type: typeCommand, StreamID: streamID, Payload: payload})
+}
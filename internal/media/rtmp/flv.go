@@ -0,0 +1,60 @@
+package rtmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// flvHeader is the header of an FLV file with audio and video, followed
+// by the size of the non-existent previous tag
+var flvHeader = []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}
+
+// setDataFrame prefixes metadata sent by encoders, which FLV files store
+// without it
+var setDataFrame = encodeAMF("@setDataFrame")
+
+// FLVWriter writes a published stream's messages as an FLV file, such as
+// for ffmpeg to read from a pipe
+type FLVWriter struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+// NewFLVWriter creates a writer of FLV to w
+func NewFLVWriter(w io.Writer) *FLVWriter {
+	return &FLVWriter{w: w}
+}
+
+// WriteMessage writes an audio, video or data message as an FLV tag;
+// other messages are ignored
+func (f *FLVWriter) WriteMessage(msg *Message) error {
+	if msg.Type != TypeAudio && msg.Type != TypeVideo && msg.Type != TypeData {
+		return nil
+	}
+
+	payload := msg.Payload
+	if msg.Type == TypeData {
+		payload = bytes.TrimPrefix(payload, setDataFrame)
+	}
+
+	var buf bytes.Buffer
+	if !f.headerWritten {
+		buf.Write(flvHeader)
+	}
+
+	var header [11]byte
+	header[0] = msg.Type
+	putUint24(header[1:4], uint32(len(payload)))
+	putUint24(header[4:7], msg.Timestamp)
+	header[7] = byte(msg.Timestamp >> 24)
+	buf.Write(header[:])
+	buf.Write(payload)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(header)+len(payload)))
+
+	if _, err := f.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	f.headerWritten = true
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package rtmp is a minimal RTMP server for ingest: it accepts encoders
+// publishing a stream and hands each stream's audio, video and metadata
+// messages to a handler. Playback is not supported.
+package rtmp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed is returned by Serve after Close
+var ErrServerClosed = errors.New("rtmp: server closed")
+
+// Handler accepts published streams
+type Handler interface {
+	// Publish is called when a client publishes the stream name, as sent
+	// by the encoder, to app. The returned Publisher receives the stream;
+	// an error rejects it and closes the connection.
+	Publish(ctx context.Context, app, name string) (Publisher, error)
+}
+
+// Publisher receives the messages of a published stream
+type Publisher interface {
+	// WriteMessage receives each audio, video and data message. An error
+	// closes the connection.
+	WriteMessage(msg *Message) error
+	// Close is called once when the stream ends, with nil when the client
+	// stopped publishing or the error the connection ended with
+	Close(err error)
+}
+
+// Server accepts RTMP connections
+type Server struct {
+	handler     Handler
+	idleTimeout time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	closed    bool
+}
+
+// NewServer creates a server handing streams to handler. Clients sending
+// nothing for idleTimeout are dropped; zero disables the timeout.
+func NewServer(handler Handler, idleTimeout time.Duration) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		handler:     handler,
+		idleTimeout: idleTimeout,
+		ctx:         ctx,
+		cancel:      cancel,
+		listeners:   make(map[net.Listener]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until the server is closed, when it
+// returns ErrServerClosed
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer nc.Close()
+			newConn(nc, s.handler, s.idleTimeout).serve(s.ctx)
+		}()
+	}
+}
+
+// Close stops accepting connections, closes the open ones and waits for
+// their streams to be closed
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,147 @@
+// Package transcript filters caption text, masking profanity and
+// detecting personal information
+package transcript
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/webvtt"
+)
+
+// defaultProfanity is masked in addition to any configured words
+var defaultProfanity = []string{
+	"asshole", "bastard", "bitch", "bullshit", "cunt", "dickhead",
+	"fuck", "fucked", "fucker", "fucking", "motherfucker", "shit", "shitty",
+}
+
+// piiPatterns finds personal information by kind, in the order they are
+// tried; a match can't overlap one of an earlier kind
+var piiPatterns = []struct {
+	kind    domain.PIIKind
+	pattern *regexp.Regexp
+	valid   func(string) bool
+}{
+	{domain.PIIKindEmail, regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), nil},
+	{domain.PIIKindCard, regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), luhn},
+	{domain.PIIKindSSN, regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), nil},
+	{domain.PIIKindPhone, regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`), nil},
+}
+
+// Filter masks profanity and detects personal information in captions
+type Filter struct {
+	cfg       config.TranscriptConfig
+	profanity *regexp.Regexp
+}
+
+// NewFilter creates a filter applying cfg
+func NewFilter(cfg config.TranscriptConfig) *Filter {
+	words := append(append([]string(nil), defaultProfanity...), cfg.ProfanityWords...)
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	// Longest first, so a word isn't masked by a shorter one it contains
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+
+	return &Filter{
+		cfg:       cfg,
+		profanity: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+// Apply filters cues, returning the cleaned variant along with what was
+// found. Profanity is counted, and masked when configured to be; personal
+// information is only looked for, and masked, when configured to be. The
+// cleaned cues are a copy; cues is left as is.
+func (f *Filter) Apply(cues []webvtt.Cue) ([]webvtt.Cue, *domain.TranscriptFlags) {
+	flags := &domain.TranscriptFlags{}
+	cleaned := make([]webvtt.Cue, len(cues))
+
+	for i, cue := range cues {
+		text := f.profanity.ReplaceAllStringFunc(cue.Text, func(word string) string {
+			flags.Profanity++
+			if !f.cfg.MaskProfanity {
+				return word
+			}
+			runes := []rune(word)
+			return string(runes[0]) + strings.Repeat("*", len(runes)-1)
+		})
+
+		if f.cfg.DetectPII {
+			text = f.filterPII(text, flags)
+		}
+
+		cue.Text = text
+		cleaned[i] = cue
+	}
+
+	return cleaned, flags
+}
+
+// filterPII counts the personal information in text, masking it when
+// configured to
+func (f *Filter) filterPII(text string, flags *domain.TranscriptFlags) string {
+	type span struct {
+		start, end int
+		kind       domain.PIIKind
+	}
+	var found []span
+	overlaps := func(start, end int) bool {
+		for _, s := range found {
+			if start < s.end && s.start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, p := range piiPatterns {
+		for _, loc := range p.pattern.FindAllStringIndex(text, -1) {
+			if overlaps(loc[0], loc[1]) || (p.valid != nil && !p.valid(text[loc[0]:loc[1]])) {
+				continue
+			}
+			found = append(found, span{loc[0], loc[1], p.kind})
+			if flags.PII == nil {
+				flags.PII = make(map[domain.PIIKind]int)
+			}
+			flags.PII[p.kind]++
+		}
+	}
+	if !f.cfg.MaskPII || len(found) == 0 {
+		return text
+	}
+
+	// Mask from the end so earlier offsets stay valid
+	sort.Slice(found, func(i, j int) bool { return found[i].start > found[j].start })
+	for _, s := range found {
+		text = text[:s.start] + "[" + strings.ToUpper(string(s.kind)) + "]" + text[s.end:]
+	}
+	return text
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum card
+// numbers carry
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
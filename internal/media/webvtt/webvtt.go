@@ -0,0 +1,163 @@
+// Package webvtt reads and writes WebVTT captions
+package webvtt
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is a caption shown from Start to End
+type Cue struct {
+	// ID is the cue's optional identifier
+	ID    string
+	Start time.Duration
+	End   time.Duration
+	// Settings are the cue's position and alignment settings, as written
+	Settings string
+	Text     string
+}
+
+// Parse reads the cues of a WebVTT file. SRT files, which differ only in
+// their header and timestamp separators, are read too. NOTE, STYLE and
+// REGION blocks are dropped.
+func Parse(data []byte) ([]Cue, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\ufeff"))), "\r\n", "\n")
+	blocks := strings.Split(strings.TrimSpace(text), "\n\n")
+
+	var cues []Cue
+	for i, block := range blocks {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if i == 0 && strings.HasPrefix(lines[0], "WEBVTT") {
+			continue
+		}
+		if len(lines) == 0 || lines[0] == "" || isMetadataBlock(lines[0]) {
+			continue
+		}
+
+		var cue Cue
+		if !strings.Contains(lines[0], "-->") {
+			cue.ID = lines[0]
+			lines = lines[1:]
+		}
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("cue %q has no timing", cue.ID)
+		}
+		if err := parseTiming(lines[0], &cue); err != nil {
+			return nil, err
+		}
+		cue.Text = strings.Join(lines[1:], "\n")
+		cues = append(cues, cue)
+	}
+	return cues, nil
+}
+
+// isMetadataBlock reports whether a block starting with line holds no cue
+func isMetadataBlock(line string) bool {
+	for _, prefix := range []string{"NOTE", "STYLE", "REGION"} {
+		if line == prefix || strings.HasPrefix(line, prefix+" ") || strings.HasPrefix(line, prefix+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTiming reads a cue's "start --> end settings" line
+func parseTiming(line string, cue *Cue) error {
+	start, rest, ok := strings.Cut(line, "-->")
+	if !ok {
+		return fmt.Errorf("invalid cue timing %q", line)
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return fmt.Errorf("invalid cue timing %q", line)
+	}
+
+	var err error
+	if cue.Start, err = parseTimestamp(strings.TrimSpace(start)); err != nil {
+		return err
+	}
+	if cue.End, err = parseTimestamp(fields[0]); err != nil {
+		return err
+	}
+	if cue.End < cue.Start {
+		return fmt.Errorf("cue ends before it starts: %q", line)
+	}
+	cue.Settings = strings.Join(fields[1:], " ")
+	return nil
+}
+
+// parseTimestamp reads a [hh:]mm:ss.ttt timestamp, or an SRT one with a
+// comma before the milliseconds
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 || seconds >= 60 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	total := time.Duration(seconds * float64(time.Second))
+
+	unit := time.Minute
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		total += time.Duration(n) * unit
+		unit = time.Hour
+	}
+	return total.Round(time.Millisecond), nil
+}
+
+// Write formats cues as a WebVTT file
+func Write(cues []Cue) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		buf.WriteString("\n")
+		if cue.ID != "" {
+			buf.WriteString(cue.ID + "\n")
+		}
+		buf.WriteString(FormatTimestamp(cue.Start) + " --> " + FormatTimestamp(cue.End))
+		if cue.Settings != "" {
+			buf.WriteString(" " + cue.Settings)
+		}
+		buf.WriteString("\n" + cue.Text + "\n")
+	}
+	return buf.Bytes()
+}
+
+// FormatTimestamp formats d as an hh:mm:ss.ttt timestamp
+func FormatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// Segment splits cues spanning duration into segments of length d, as
+// segmented HLS subtitles are served. A cue overlapping several segments
+// is in each of them, which players show once; cue times stay relative
+// to the start of the media. There is always at least one segment.
+func Segment(cues []Cue, duration, d time.Duration) [][]Cue {
+	n := int((duration + d - 1) / d)
+	if n < 1 {
+		n = 1
+	}
+
+	segments := make([][]Cue, n)
+	for _, cue := range cues {
+		first, last := int(cue.Start/d), int((cue.End-1)/d)
+		if last < first {
+			last = first
+		}
+		for i := first; i <= last && i < n; i++ {
+			segments[i] = append(segments[i], cue)
+		}
+	}
+	return segments
+}
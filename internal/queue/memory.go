@@ -0,0 +1,279 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// admitRetry is how often a due scheduled job the admitter refused is
+// offered to it again
+const admitRetry = 5 * time.Second
+
+// MemoryQueue implements Queue in process memory, for running the API
+// and worker together without Redis. Jobs are lost when the process exits.
+type MemoryQueue struct {
+	mu         sync.Mutex
+	pending    []memoryJob
+	processing map[string]*Job
+	dead       []*Job
+	// scheduled holds jobs waiting for their time, each enqueued by a
+	// timer
+	scheduled map[string]*Job
+	seq       uint64
+	// ready is signalled when a job is enqueued
+	ready chan struct{}
+	// admitter, when set, admits due scheduled jobs before they are
+	// enqueued
+	admitter Admitter
+}
+
+// memoryJob is a pending job with its position in the queue
+type memoryJob struct {
+	job   *Job
+	score float64
+	seq   uint64
+}
+
+// NewMemoryQueue creates a new in-memory job queue
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		processing: make(map[string]*Job),
+		scheduled:  make(map[string]*Job),
+		ready:      make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds a job to the queue
+func (q *MemoryQueue) Enqueue(ctx context.Context, job *Job) error {
+	job.CreatedAt = time.Now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Order jobs the way RedisQueue does, breaking ties first-in first-out
+	q.seq++
+	q.pending = append(q.pending, memoryJob{
+		job:   copyJob(job),
+		score: float64(job.CreatedAt.Unix()) - float64(job.Priority*1000),
+		seq:   q.seq,
+	})
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		a, b := q.pending[i], q.pending[j]
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		return a.seq < b.seq
+	})
+	jobsEnqueued.Inc(string(job.Type))
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// EnqueueAt schedules a job to be enqueued at a time. A job due already
+// is enqueued now.
+func (q *MemoryQueue) EnqueueAt(ctx context.Context, job *Job, at time.Time) error {
+	delay := time.Until(at)
+	if delay <= 0 {
+		return q.Enqueue(ctx, job)
+	}
+
+	job.CreatedAt = time.Now()
+	job.RunAt = &at
+	scheduled := copyJob(job)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.scheduled[scheduled.ID] = scheduled
+	time.AfterFunc(delay, func() { q.promote(scheduled) })
+	return nil
+}
+
+// SetAdmitter has due scheduled jobs only enqueued once admitter admits
+// them, offering each again every admitRetry until it does
+func (q *MemoryQueue) SetAdmitter(admitter Admitter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.admitter = admitter
+}
+
+// promote enqueues a scheduled job that is due once it is admitted
+func (q *MemoryQueue) promote(job *Job) {
+	q.mu.Lock()
+	admitter := q.admitter
+	q.mu.Unlock()
+
+	if admitter != nil && !admitter.Admit(context.Background(), job) {
+		time.AfterFunc(admitRetry, func() { q.promote(job) })
+		return
+	}
+
+	q.mu.Lock()
+	delete(q.scheduled, job.ID)
+	q.mu.Unlock()
+
+	_ = q.Enqueue(context.Background(), job)
+}
+
+// Dequeue removes and returns the next job, waiting up to timeout for one
+func (q *MemoryQueue) Dequeue(ctx context.Context, timeout time.Duration) (*Job, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			job := q.pending[0].job
+			q.pending = q.pending[1:]
+			q.processing[job.ID] = job
+			more := len(q.pending) > 0
+			q.mu.Unlock()
+
+			// Wake another waiting worker if jobs remain
+			if more {
+				select {
+				case q.ready <- struct{}{}:
+				default:
+				}
+			}
+			return copyJob(job), nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-timer.C:
+			return nil, ErrNoJobAvailable
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Ack acknowledges successful job completion
+func (q *MemoryQueue) Ack(ctx context.Context, job *Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.processing, job.ID)
+	return nil
+}
+
+// Nack re-queues a failed job for retry
+func (q *MemoryQueue) Nack(ctx context.Context, job *Job) error {
+	q.mu.Lock()
+	delete(q.processing, job.ID)
+	job.Attempts++
+	if job.Attempts >= MaxAttempts {
+		// Move to dead letter queue after max attempts
+		q.dead = append(q.dead, copyJob(job))
+		q.mu.Unlock()
+		jobsDead.Inc(string(job.Type))
+		return nil
+	}
+	q.mu.Unlock()
+	jobsRetried.Inc(string(job.Type))
+
+	return q.Enqueue(ctx, job)
+}
+
+// Release returns a job that was interrupted rather than failed, such as
+// by the worker shutting down, to the queue without counting an attempt
+func (q *MemoryQueue) Release(ctx context.Context, job *Job) error {
+	q.mu.Lock()
+	delete(q.processing, job.ID)
+	q.mu.Unlock()
+
+	return q.Enqueue(ctx, job)
+}
+
+// Len returns the number of pending jobs
+func (q *MemoryQueue) Len(ctx context.Context) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return int64(len(q.pending)), nil
+}
+
+// Depth returns the number of jobs in each state
+func (q *MemoryQueue) Depth(ctx context.Context) (map[JobState]int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return map[JobState]int64{
+		JobStateScheduled:  int64(len(q.scheduled)),
+		JobStatePending:    int64(len(q.pending)),
+		JobStateProcessing: int64(len(q.processing)),
+		JobStateDead:       int64(len(q.dead)),
+	}, nil
+}
+
+// Jobs returns the jobs in a state, pending jobs in the order they will
+// be processed
+func (q *MemoryQueue) Jobs(ctx context.Context, state JobState) ([]*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var jobs []*Job
+	switch state {
+	case JobStateScheduled:
+		for _, job := range q.scheduled {
+			jobs = append(jobs, copyJob(job))
+		}
+	case JobStatePending:
+		for _, p := range q.pending {
+			jobs = append(jobs, copyJob(p.job))
+		}
+	case JobStateProcessing:
+		for _, job := range q.processing {
+			jobs = append(jobs, copyJob(job))
+		}
+	case JobStateDead:
+		for _, job := range q.dead {
+			jobs = append(jobs, copyJob(job))
+		}
+	default:
+		return nil, fmt.Errorf("unknown job state %q", state)
+	}
+	return jobs, nil
+}
+
+// PurgeDead discards every job in the dead letter queue, returning how
+// many there were
+func (q *MemoryQueue) PurgeDead(ctx context.Context) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := int64(len(q.dead))
+	q.dead = nil
+	return n, nil
+}
+
+// Ping always succeeds; the queue has no connection to lose
+func (q *MemoryQueue) Ping(ctx context.Context) error {
+	return nil
+}
+
+// copyJob copies a job so callers can't change it while it is queued
+func copyJob(job *Job) *Job {
+	c := *job
+	if job.RunAt != nil {
+		runAt := *job.RunAt
+		c.RunAt = &runAt
+	}
+	if job.Payload != nil {
+		c.Payload = make(map[string]string, len(job.Payload))
+		for k, v := range job.Payload {
+			c.Payload[k] = v
+		}
+	}
+	return &c
+}
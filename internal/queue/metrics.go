@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/streaming-service/pkg/metrics"
+)
+
+var (
+	jobsEnqueued = metrics.NewCounter("streaming_queue_jobs_enqueued_total",
+		"Jobs added to the pending queue, including retries, by type", "type")
+	jobsRetried = metrics.NewCounter("streaming_queue_jobs_retried_total",
+		"Failed job attempts re-enqueued for retry, by type", "type")
+	jobsDead = metrics.NewCounter("streaming_queue_jobs_dead_total",
+		"Jobs moved to the dead letter queue after MaxAttempts, by type", "type")
+)
+
+// Depther is a queue that can count its jobs in each state
+type Depther interface {
+	Depth(ctx context.Context) (map[JobState]int64, error)
+}
+
+var (
+	depthMu     sync.Mutex
+	depthSource Depther
+)
+
+// MeasureDepth reports q's job counts as the queue depth metric. Only the
+// last queue measured is reported.
+func MeasureDepth(q Depther) {
+	depthMu.Lock()
+	defer depthMu.Unlock()
+
+	depthSource = q
+}
+
+var _ = metrics.NewGaugeFunc("streaming_queue_depth", "Jobs in the queue, by state", "state",
+	func(ctx context.Context) (map[string]float64, error) {
+		depthMu.Lock()
+		q := depthSource
+		depthMu.Unlock()
+		if q == nil {
+			return nil, errors.New("no queue measured")
+		}
+
+		depths, err := q.Depth(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]float64, len(depths))
+		for state, n := range depths {
+			values[string(state)] = float64(n)
+		}
+		return values, nil
+	})
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -18,10 +19,26 @@ type JobType string
 // ErrNoJobAvailable is returned when no jobs are available in the queue.
 var ErrNoJobAvailable = errors.New("no job available")
 
+// ErrLeaseLost is returned when heartbeating a job no longer leased to
+// its worker, such as one already reaped as stalled
+var ErrLeaseLost = errors.New("job lease lost")
+
+// DefaultLease is how long a job stays leased to its worker without a
+// heartbeat unless the queue is given a lease
+const DefaultLease = 2 * time.Minute
+
+// MaxAttempts is how many times a failing job runs before it is moved to
+// the dead letter queue
+const MaxAttempts = 3
+
 const (
-	JobTypeTranscode JobType = "transcode"
-	JobTypeAudio     JobType = "audio"
-	JobTypeThumbnail JobType = "thumbnail"
+	JobTypeTranscode  JobType = "transcode"
+	JobTypeAudio      JobType = "audio"
+	JobTypeThumbnail  JobType = "thumbnail"
+	JobTypeTranslate  JobType = "translate"
+	JobTypeEnrich     JobType = "enrich"
+	JobTypeExport     JobType = "export"
+	JobTypeTranscribe JobType = "transcribe"
 )
 
 // Job represents a processing job
@@ -33,29 +50,108 @@ type Job struct {
 	Payload   map[string]string `json:"payload"`
 	CreatedAt time.Time         `json:"created_at"`
 	Attempts  int               `json:"attempts"`
+	// RequestID is the API request that queued the job, so the worker's
+	// logs can be correlated with it
+	RequestID string `json:"request_id,omitempty"`
+	// TenantID is the tenant the job's media belongs to; the worker
+	// processes it scoped to that tenant
+	TenantID string `json:"tenant_id,omitempty"`
+	// RunAt is when a scheduled job becomes pending
+	RunAt *time.Time `json:"run_at,omitempty"`
+}
+
+// LastAttempt reports whether the job's current attempt is its last, so
+// a failure moves it to the dead letter queue rather than retrying it
+func (j *Job) LastAttempt() bool {
+	return j.Attempts+1 >= MaxAttempts
 }
 
 // Queue defines the interface for a job queue
 type Queue interface {
 	Enqueue(ctx context.Context, job *Job) error
+	// EnqueueAt schedules a job to be enqueued at a time, or now if the
+	// time has passed
+	EnqueueAt(ctx context.Context, job *Job, at time.Time) error
 	Dequeue(ctx context.Context, timeout time.Duration) (*Job, error)
 	Ack(ctx context.Context, job *Job) error
 	Nack(ctx context.Context, job *Job) error
+	Release(ctx context.Context, job *Job) error
 	Len(ctx context.Context) (int64, error)
 }
 
+// Admitter decides when due scheduled jobs become pending, so what they
+// need to run can be claimed as they are about to rather than for their
+// whole wait
+type Admitter interface {
+	// Admit reports whether a due job may become pending now. A job
+	// refused stays scheduled and is offered again later.
+	Admit(ctx context.Context, job *Job) bool
+	// Withdraw undoes the admission of a job that was promoted elsewhere
+	// first
+	Withdraw(ctx context.Context, job *Job)
+}
+
+// Promoter is a queue whose scheduled jobs must be promoted to pending
+// once due
+type Promoter interface {
+	Promote(ctx context.Context) (int, error)
+}
+
+// Leaser is a queue that leases each job it hands out to its worker. A
+// worker that stops heartbeating, such as by crashing, loses the lease,
+// and reaping returns the job to the queue as a failed attempt.
+type Leaser interface {
+	Heartbeat(ctx context.Context, job *Job) error
+	Reap(ctx context.Context) ([]*Job, error)
+}
+
+// JobState is where a job is in the queue
+type JobState string
+
+const (
+	// JobStateScheduled jobs are waiting for their time to become pending
+	JobStateScheduled JobState = "scheduled"
+	// JobStatePending jobs are waiting for a worker
+	JobStatePending JobState = "pending"
+	// JobStateProcessing jobs have been taken by a worker
+	JobStateProcessing JobState = "processing"
+	// JobStateDead jobs failed MaxAttempts times
+	JobStateDead JobState = "dead"
+)
+
 // RedisQueue implements Queue using Redis
 type RedisQueue struct {
 	client        *redis.Client
 	queueKey      string
 	processingKey string
+	deadLetterKey string
+	// scheduledKey scores each scheduled job by when it is due
+	scheduledKey string
+	// leaseKey scores each processing job by when its lease expires
+	leaseKey string
+	lease    time.Duration
+	// admitter, when set, admits due scheduled jobs before promotion
+	admitter Admitter
 }
 
 const (
 	defaultQueueKey      = "streaming:jobs:pending"
 	defaultProcessingKey = "streaming:jobs:processing"
+	defaultDeadLetterKey = "streaming:jobs:dead"
+	defaultLeaseKey      = "streaming:jobs:leases"
+	defaultScheduledKey  = "streaming:jobs:scheduled"
 )
 
+// promoteScript moves a due job from the scheduled set to the pending
+// queue, once however many workers promote it
+var promoteScript = redis.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[2], ARGV[1])
+return 1
+`)
+
 // NewRedisQueue creates a new Redis-based job queue
 func NewRedisQueue(cfg config.RedisConfig) (*RedisQueue, error) {
 	client := redis.NewClient(&redis.Options{
@@ -76,9 +172,24 @@ func NewRedisQueue(cfg config.RedisConfig) (*RedisQueue, error) {
 		client:        client,
 		queueKey:      defaultQueueKey,
 		processingKey: defaultProcessingKey,
+		deadLetterKey: defaultDeadLetterKey,
+		scheduledKey:  defaultScheduledKey,
+		leaseKey:      defaultLeaseKey,
+		lease:         DefaultLease,
 	}, nil
 }
 
+// SetLease sets how long a dequeued job stays leased to its worker
+// without a heartbeat
+func (q *RedisQueue) SetLease(lease time.Duration) {
+	q.lease = lease
+}
+
+// leaseExpiry returns the lease score of a job leased or heartbeated now
+func (q *RedisQueue) leaseExpiry() float64 {
+	return float64(time.Now().Add(q.lease).Unix())
+}
+
 // Enqueue adds a job to the queue
 func (q *RedisQueue) Enqueue(ctx context.Context, job *Job) error {
 	job.CreatedAt = time.Now()
@@ -88,19 +199,94 @@ func (q *RedisQueue) Enqueue(ctx context.Context, job *Job) error {
 		return fmt.Errorf("failed to marshal job: %w", err)
 	}
 
-	// Use ZADD with priority as score (lower priority = higher score for processing first)
-	score := float64(time.Now().Unix()) - float64(job.Priority*1000)
-
 	if err := q.client.ZAdd(ctx, q.queueKey, redis.Z{
-		Score:  score,
+		Score:  pendingScore(job),
 		Member: string(data),
 	}).Err(); err != nil {
 		return fmt.Errorf("failed to enqueue job: %w", err)
 	}
+	jobsEnqueued.Inc(string(job.Type))
 
 	return nil
 }
 
+// pendingScore orders a job enqueued now among the pending jobs
+func pendingScore(job *Job) float64 {
+	// Lower priority = higher score for processing first
+	return float64(time.Now().Unix()) - float64(job.Priority*1000)
+}
+
+// EnqueueAt schedules a job to be enqueued at a time. A job due already
+// is enqueued now.
+func (q *RedisQueue) EnqueueAt(ctx context.Context, job *Job, at time.Time) error {
+	if !at.After(time.Now()) {
+		return q.Enqueue(ctx, job)
+	}
+
+	job.CreatedAt = time.Now()
+	job.RunAt = &at
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	if err := q.client.ZAdd(ctx, q.scheduledKey, redis.Z{
+		Score:  float64(at.Unix()),
+		Member: string(data),
+	}).Err(); err != nil {
+		return fmt.Errorf("failed to schedule job: %w", err)
+	}
+
+	return nil
+}
+
+// SetAdmitter has Promote only move the due jobs admitter admits, leaving
+// the others scheduled for the next promotion
+func (q *RedisQueue) SetAdmitter(admitter Admitter) {
+	q.admitter = admitter
+}
+
+// Promote moves scheduled jobs that are due to the pending queue,
+// returning how many it moved. Workers may promote concurrently; each job
+// is promoted once.
+func (q *RedisQueue) Promote(ctx context.Context) (int, error) {
+	due, err := q.client.ZRangeByScore(ctx, q.scheduledKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due jobs: %w", err)
+	}
+
+	promoted := 0
+	for _, data := range due {
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return promoted, fmt.Errorf("failed to unmarshal job: %w", err)
+		}
+		if q.admitter != nil && !q.admitter.Admit(ctx, &job) {
+			continue
+		}
+		moved, err := promoteScript.Run(ctx, q.client, []string{q.scheduledKey, q.queueKey}, data, pendingScore(&job)).Int()
+		if err != nil {
+			if q.admitter != nil {
+				q.admitter.Withdraw(ctx, &job)
+			}
+			return promoted, fmt.Errorf("failed to promote job: %w", err)
+		}
+		if moved == 0 && q.admitter != nil {
+			q.admitter.Withdraw(ctx, &job)
+		}
+		if moved == 1 {
+			jobsEnqueued.Inc(string(job.Type))
+		}
+		promoted += moved
+	}
+
+	return promoted, nil
+}
+
 // Dequeue removes and returns the next job from the queue
 func (q *RedisQueue) Dequeue(ctx context.Context, timeout time.Duration) (*Job, error) {
 	// Use BZPOPMIN for blocking pop from sorted set
@@ -122,8 +308,13 @@ func (q *RedisQueue) Dequeue(ctx context.Context, timeout time.Duration) (*Job,
 		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
 	}
 
-	// Move to processing set
-	if err := q.client.SAdd(ctx, q.processingKey, data).Err(); err != nil {
+	// Move to processing set, leased to this worker
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, q.processingKey, data)
+		pipe.ZAdd(ctx, q.leaseKey, redis.Z{Score: q.leaseExpiry(), Member: data})
+		return nil
+	})
+	if err != nil {
 		// Re-enqueue if we can't track processing - log but don't fail
 		if enqErr := q.Enqueue(ctx, &job); enqErr != nil {
 			return nil, fmt.Errorf("failed to re-enqueue job: %w", enqErr)
@@ -144,6 +335,7 @@ func (q *RedisQueue) Ack(ctx context.Context, job *Job) error {
 	if err := q.client.SRem(ctx, q.processingKey, string(data)).Err(); err != nil {
 		return fmt.Errorf("failed to ack job: %w", err)
 	}
+	q.endLease(ctx, string(data))
 
 	return nil
 }
@@ -159,27 +351,238 @@ func (q *RedisQueue) Nack(ctx context.Context, job *Job) error {
 	if err := q.client.SRem(ctx, q.processingKey, string(data)).Err(); err != nil {
 		return fmt.Errorf("failed to remove from processing: %w", err)
 	}
+	q.endLease(ctx, string(data))
+
+	return q.retry(ctx, job, string(data))
+}
 
+// retry re-enqueues a job removed from processing after a failed attempt,
+// counting the attempt, or moves it to the dead letter queue after
+// MaxAttempts. data is the job as it was processed.
+func (q *RedisQueue) retry(ctx context.Context, job *Job, data string) error {
 	// Re-enqueue with incremented attempts
 	job.Attempts++
-	if job.Attempts < 3 { // Max 3 attempts
+	if job.Attempts < MaxAttempts {
+		jobsRetried.Inc(string(job.Type))
 		return q.Enqueue(ctx, job)
 	}
 
 	// Move to dead letter queue after max attempts
-	deadLetterKey := "streaming:jobs:dead"
-	if err := q.client.SAdd(ctx, deadLetterKey, string(data)).Err(); err != nil {
+	if err := q.client.SAdd(ctx, q.deadLetterKey, data).Err(); err != nil {
 		return fmt.Errorf("failed to add to dead letter queue: %w", err)
 	}
+	jobsDead.Inc(string(job.Type))
 
 	return nil
 }
 
+// Release returns a job that was interrupted rather than failed, such as
+// by the worker shutting down, to the queue without counting an attempt
+func (q *RedisQueue) Release(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	if err := q.client.SRem(ctx, q.processingKey, string(data)).Err(); err != nil {
+		return fmt.Errorf("failed to remove from processing: %w", err)
+	}
+	q.endLease(ctx, string(data))
+
+	return q.Enqueue(ctx, job)
+}
+
+// endLease drops the lease of a job no longer processing. A lease left
+// behind is dropped by the next reap.
+func (q *RedisQueue) endLease(ctx context.Context, data string) {
+	_ = q.client.ZRem(ctx, q.leaseKey, data).Err()
+}
+
+// Heartbeat extends the lease of a job its worker is still processing.
+// ErrLeaseLost means the lease expired and the job was reaped, so it may
+// run again elsewhere.
+func (q *RedisQueue) Heartbeat(ctx context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	changed, err := q.client.ZAddArgs(ctx, q.leaseKey, redis.ZAddArgs{
+		XX:      true,
+		Ch:      true,
+		Members: []redis.Z{{Score: q.leaseExpiry(), Member: string(data)}},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to extend job lease: %w", err)
+	}
+	if changed == 0 {
+		// An unchanged score still holds the lease
+		if _, err := q.client.ZScore(ctx, q.leaseKey, string(data)).Result(); err != nil {
+			if errors.Is(err, redis.Nil) {
+				return ErrLeaseLost
+			}
+			return fmt.Errorf("failed to check job lease: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Reap returns processing jobs whose lease has expired to the queue,
+// counting the stalled attempt, or moves them to the dead letter queue
+// after MaxAttempts. Processing jobs without a lease, such as those
+// dequeued before leases, are leased first so they expire in turn. It
+// returns the reaped jobs as they were processed. Workers may reap
+// concurrently; each job is reaped once.
+func (q *RedisQueue) Reap(ctx context.Context) ([]*Job, error) {
+	processing, err := q.client.SMembers(ctx, q.processingKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processing jobs: %w", err)
+	}
+	if len(processing) > 0 {
+		members := make([]redis.Z, 0, len(processing))
+		for _, data := range processing {
+			members = append(members, redis.Z{Score: q.leaseExpiry(), Member: data})
+		}
+		if err := q.client.ZAddNX(ctx, q.leaseKey, members...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to lease processing jobs: %w", err)
+		}
+	}
+
+	expired, err := q.client.ZRangeByScore(ctx, q.leaseKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired leases: %w", err)
+	}
+
+	var reaped []*Job
+	for _, data := range expired {
+		// Removing the job from processing claims it, against its worker
+		// finishing it and other reapers
+		removed, err := q.client.SRem(ctx, q.processingKey, data).Result()
+		if err != nil {
+			return reaped, fmt.Errorf("failed to remove from processing: %w", err)
+		}
+		q.endLease(ctx, data)
+		if removed == 0 {
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return reaped, fmt.Errorf("failed to unmarshal job: %w", err)
+		}
+		stalled := job
+		if err := q.retry(ctx, &job, data); err != nil {
+			return reaped, err
+		}
+		reaped = append(reaped, &stalled)
+	}
+
+	return reaped, nil
+}
+
 // Len returns the number of pending jobs
 func (q *RedisQueue) Len(ctx context.Context) (int64, error) {
 	return q.client.ZCard(ctx, q.queueKey).Result()
 }
 
+// Depth returns the number of jobs in each state
+func (q *RedisQueue) Depth(ctx context.Context) (map[JobState]int64, error) {
+	pipe := q.client.Pipeline()
+	scheduled := pipe.ZCard(ctx, q.scheduledKey)
+	pending := pipe.ZCard(ctx, q.queueKey)
+	processing := pipe.SCard(ctx, q.processingKey)
+	dead := pipe.SCard(ctx, q.deadLetterKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	return map[JobState]int64{
+		JobStateScheduled:  scheduled.Val(),
+		JobStatePending:    pending.Val(),
+		JobStateProcessing: processing.Val(),
+		JobStateDead:       dead.Val(),
+	}, nil
+}
+
+// Jobs returns the jobs in a state, pending jobs in the order they will
+// be processed
+func (q *RedisQueue) Jobs(ctx context.Context, state JobState) ([]*Job, error) {
+	var members []string
+	var err error
+	switch state {
+	case JobStateScheduled:
+		members, err = q.client.ZRange(ctx, q.scheduledKey, 0, -1).Result()
+	case JobStatePending:
+		members, err = q.client.ZRange(ctx, q.queueKey, 0, -1).Result()
+	case JobStateProcessing:
+		members, err = q.client.SMembers(ctx, q.processingKey).Result()
+	case JobStateDead:
+		members, err = q.client.SMembers(ctx, q.deadLetterKey).Result()
+	default:
+		return nil, fmt.Errorf("unknown job state %q", state)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s jobs: %w", state, err)
+	}
+
+	jobs := make([]*Job, 0, len(members))
+	for _, data := range members {
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+// PurgeDead discards every job in the dead letter queue, returning how
+// many there were
+func (q *RedisQueue) PurgeDead(ctx context.Context) (int64, error) {
+	n, err := q.client.SCard(ctx, q.deadLetterKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count dead jobs: %w", err)
+	}
+	if err := q.client.Del(ctx, q.deadLetterKey).Err(); err != nil {
+		return 0, fmt.Errorf("failed to purge dead letter queue: %w", err)
+	}
+	return n, nil
+}
+
+// Verify checks that each queue key is unset or holds the type the queue
+// uses, since a key of another type fails every command on it
+func (q *RedisQueue) Verify(ctx context.Context) error {
+	keys := []struct {
+		key      string
+		wantType string
+	}{
+		{q.queueKey, "zset"},
+		{q.processingKey, "set"},
+		{q.deadLetterKey, "set"},
+		{q.leaseKey, "zset"},
+		{q.scheduledKey, "zset"},
+	}
+	for _, k := range keys {
+		keyType, err := q.client.Type(ctx, k.key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check key %s: %w", k.key, err)
+		}
+		if keyType != "none" && keyType != k.wantType {
+			return fmt.Errorf("key %s is a %s, expected a %s", k.key, keyType, k.wantType)
+		}
+	}
+	return nil
+}
+
+// Ping checks that Redis is reachable
+func (q *RedisQueue) Ping(ctx context.Context) error {
+	return q.client.Ping(ctx).Err()
+}
+
 // Close closes the Redis connection
 func (q *RedisQueue) Close() error {
 	return q.client.Close()
@@ -0,0 +1,98 @@
+package awsconfig
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	appconfig "github.com/streaming-service/internal/config"
+)
+
+// Load builds the AWS config shared by the service clients. Credentials
+// come from the static keys when provided, or the default chain, and are
+// then used to assume cfg.RoleARN when set. With a web identity token
+// file the role is assumed with that token instead, as on EKS with IRSA.
+// Failed calls are retried as configured by cfg.MaxAttempts, MaxBackoff and
+// RetryMode.
+func Load(ctx context.Context, cfg appconfig.AWSConfig) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	opts = append(opts, config.WithRegion(cfg.Region))
+
+	// Add credentials if provided
+	if cfg.AccessKeyID != "" && cfg.SecretAccessKey != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(
+				cfg.AccessKeyID,
+				cfg.SecretAccessKey,
+				"",
+			),
+		))
+	}
+
+	// Retry throttled and failed calls with backoff
+	opts = append(opts, config.WithRetryer(func() aws.Retryer {
+		return newRetryer(cfg)
+	}))
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	switch {
+	case cfg.WebIdentityTokenFile != "":
+		if cfg.RoleARN == "" {
+			return aws.Config{}, fmt.Errorf("a role ARN is required with a web identity token file")
+		}
+		provider := stscreds.NewWebIdentityRoleProvider(
+			sts.NewFromConfig(awsCfg),
+			cfg.RoleARN,
+			stscreds.IdentityTokenFile(cfg.WebIdentityTokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = cfg.RoleSessionName
+			},
+		)
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+
+	case cfg.RoleARN != "":
+		provider := stscreds.NewAssumeRoleProvider(
+			sts.NewFromConfig(awsCfg),
+			cfg.RoleARN,
+			func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = cfg.RoleSessionName
+				if cfg.ExternalID != "" {
+					o.ExternalID = aws.String(cfg.ExternalID)
+				}
+			},
+		)
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return awsCfg, nil
+}
+
+// newRetryer builds the retryer for cfg. Adaptive mode additionally rate
+// limits calls client-side while a service is throttling them.
+func newRetryer(cfg appconfig.AWSConfig) aws.Retryer {
+	standard := func(o *retry.StandardOptions) {
+		if cfg.MaxAttempts > 0 {
+			o.MaxAttempts = cfg.MaxAttempts
+		}
+		if cfg.MaxBackoff > 0 {
+			o.MaxBackoff = cfg.MaxBackoff
+		}
+	}
+
+	if aws.RetryMode(cfg.RetryMode) == aws.RetryModeAdaptive {
+		return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+			o.StandardOptions = append(o.StandardOptions, standard)
+		})
+	}
+	return retry.NewStandard(standard)
+}
@@ -0,0 +1,96 @@
+package awsconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+
+	appconfig "github.com/streaming-service/internal/config"
+)
+
+// ErrCircuitOpen is returned without calling AWS while a service's
+// circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// isTransient reports whether err is the kind of failure retries are for:
+// throttling, 5xx responses and connection errors. Client errors such as
+// missing keys or failed conditions say nothing about the service's health.
+var isTransient = retry.IsErrorRetryables(retry.DefaultRetryables)
+
+// breaker opens after threshold consecutive transient failures, failing
+// calls fast for cooldown. After that a single call is let through to probe
+// the service, closing the breaker if it succeeds and reopening it if not.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a call may go ahead
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record records the outcome of a call allow let through
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// CircuitBreaker returns an API option adding a circuit breaker to every
+// call made by the client it is given to, configured by cfg.BreakerThreshold
+// and cfg.BreakerCooldown. It sits outside the retryer, so only calls that
+// still fail once retries are exhausted count against the service. A
+// threshold of zero disables the breaker.
+func CircuitBreaker(service string, cfg appconfig.AWSConfig) func(*middleware.Stack) error {
+	if cfg.BreakerThreshold <= 0 {
+		return func(*middleware.Stack) error { return nil }
+	}
+	b := &breaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown}
+
+	handler := middleware.InitializeMiddlewareFunc("CircuitBreaker", func(
+		ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+	) (middleware.InitializeOutput, middleware.Metadata, error) {
+		if !b.allow() {
+			return middleware.InitializeOutput{}, middleware.Metadata{}, fmt.Errorf("%s: %w", service, ErrCircuitOpen)
+		}
+
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		b.record(err != nil && ctx.Err() == nil && isTransient.IsErrorRetryable(err) == aws.TrueTernary)
+		return out, metadata, err
+	})
+
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(handler, middleware.Before)
+	}
+}
@@ -0,0 +1,82 @@
+package cloudfront
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+// Client wraps the AWS CloudFront client
+type Client struct {
+	client         *cloudfront.Client
+	distributionID string
+}
+
+// NewClient creates a new CloudFront client
+func NewClient(ctx context.Context, cfg appconfig.AWSConfig) (*Client, error) {
+	// Build AWS config
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client:         cloudfront.NewFromConfig(awsCfg),
+		distributionID: cfg.CloudFrontDistributionID,
+	}, nil
+}
+
+// URL returns the public URL of an object served from domain. The domain
+// is used as a base URL when it includes a scheme, so a local server can
+// stand in for the CDN.
+func URL(domain, key string) string {
+	if strings.Contains(domain, "://") {
+		return strings.TrimSuffix(domain, "/") + "/" + key
+	}
+	return fmt.Sprintf("https://%s/%s", domain, key)
+}
+
+// InvalidatePaths creates an invalidation for the given paths and returns its ID
+func (c *Client) InvalidatePaths(ctx context.Context, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	items := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		items = append(items, p)
+	}
+
+	result, err := c.client.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(c.distributionID),
+		InvalidationBatch: &types.InvalidationBatch{
+			CallerReference: aws.String(fmt.Sprintf("%d-%s", time.Now().UnixNano(), items[0])),
+			Paths: &types.Paths{
+				Quantity: aws.Int32(int32(len(items))),
+				Items:    items,
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create invalidation: %w", err)
+	}
+
+	return aws.ToString(result.Invalidation.Id), nil
+}
+
+// InvalidateMedia invalidates every cached object under a media's output
+// prefix, as returned by Media.GetOutputPrefix
+func (c *Client) InvalidateMedia(ctx context.Context, prefix string) (string, error) {
+	return c.InvalidatePaths(ctx, []string{"/" + prefix + "*"})
+}
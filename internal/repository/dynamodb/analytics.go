@@ -0,0 +1,496 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// Analytics items share one table keyed by (pk, sk):
+//
+//	views#<day>           / <mediaID>    daily view counter
+//	seen#<day>#<mediaID>  / <sessionID>  per-session dedup marker (TTL)
+//	media#<mediaID>       / day#<day>    per-media daily views
+//	media#<mediaID>       / pos#<sec>    watch time per heatmap bucket (ms)
+//	media#<mediaID>       / device#<d>   starts per device class
+//	media#<mediaID>       / geo#<cc>     starts per country
+//	media#<mediaID>       / starts       playback starts
+//	media#<mediaID>       / completions  playback completions
+//	media#<mediaID>       / cdn_bytes    bytes delivered by the CDN
+//	media#<mediaID>       / cdn_requests requests served by the CDN
+//	cflog                 / <objectKey>  CloudFront log objects claimed whole (legacy)
+//	cflog                 / <objectKey>#<mediaID>#<n> a log object's counts applied (TTL)
+//	viewer#<sessionID>    / <mediaID>    media recently viewed in a session (TTL)
+//	coview#<mediaID>      / <mediaID>    views shared with another media item
+//	like#<userID>         / <mediaID>    a user's like of a media item
+//	likes#<userID>        / <at>#<mediaID> a user's likes in the order made
+const (
+	viewsPrefix  = "views#"
+	seenPrefix   = "seen#"
+	statsPrefix  = "media#"
+	cdnLogPrefix = "cflog"
+	viewerPrefix = "viewer#"
+	coViewPrefix = "coview#"
+
+	// seenTTL keeps dedup markers around slightly longer than a day
+	seenTTL = 48 * time.Hour
+	// viewerTTL is how long a session's views count as viewed together
+	viewerTTL = 7 * 24 * time.Hour
+	// maxViewerHistory bounds the earlier views a new view is paired with
+	maxViewerHistory = 20
+	// logMarkerTTL is how long the markers of applied CDN log counts are
+	// kept; an ingested log is moved out of the way well within it
+	logMarkerTTL = 7 * 24 * time.Hour
+	// logCountersPerWrite is how many counters are applied in one
+	// transaction alongside its marker, within DynamoDB's 100 items
+	logCountersPerWrite = 99
+)
+
+// dayKey formats a time as the UTC day used in analytics keys
+func dayKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// RecordView counts a view for the media unless the session was already
+// counted on the same day. It returns false for a deduplicated view. The
+// session's marker and the view counters are written in one transaction,
+// so a view is either counted whole or can be recorded again. It returns
+// domain.ErrMediaNotFound, counting nothing, when the media record is
+// gone or belongs to another tenant.
+func (c *Client) RecordView(ctx context.Context, mediaID, sessionID string, at time.Time) (bool, error) {
+	day := dayKey(at)
+
+	increment := expression.Add(expression.Name("count"), expression.Value(1))
+	counterExpr, err := expression.NewBuilder().WithUpdate(increment).Build()
+	if err != nil {
+		return false, fmt.Errorf("failed to build expression: %w", err)
+	}
+	views := expression.Add(expression.Name("view_count"), expression.Value(1))
+	viewsExpr, err := expression.NewBuilder().WithUpdate(views).Build()
+	if err != nil {
+		return false, fmt.Errorf("failed to build expression: %w", err)
+	}
+	mediaExpr, err := expression.NewBuilder().WithUpdate(views).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return false, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	items := []types.TransactWriteItem{
+		// The (day, media, session) marker drops repeated views
+		{Put: &types.Put{
+			TableName: aws.String(c.analyticsTable),
+			Item: map[string]types.AttributeValue{
+				"pk":         &types.AttributeValueMemberS{Value: seenPrefix + day + "#" + mediaID},
+				"sk":         &types.AttributeValueMemberS{Value: sessionID},
+				"expires_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(at.Add(seenTTL).Unix(), 10)},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(pk)"),
+		}},
+		// Daily counter used for trending windows
+		{Update: &types.Update{
+			TableName: aws.String(c.analyticsTable),
+			Key: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: viewsPrefix + day},
+				"sk": &types.AttributeValueMemberS{Value: mediaID},
+			},
+			ExpressionAttributeNames:  viewsExpr.Names(),
+			ExpressionAttributeValues: viewsExpr.Values(),
+			UpdateExpression:          viewsExpr.Update(),
+		}},
+		// Per-media daily counter used for views over time
+		{Update: &types.Update{
+			TableName:                 aws.String(c.analyticsTable),
+			Key:                       statsKey(mediaID, "day#"+day),
+			ExpressionAttributeNames:  counterExpr.Names(),
+			ExpressionAttributeValues: counterExpr.Values(),
+			UpdateExpression:          counterExpr.Update(),
+		}},
+		// Lifetime counter on the media record, which must still exist
+		{Update: &types.Update{
+			TableName: aws.String(c.tableName),
+			Key: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: mediaID},
+			},
+			ConditionExpression:       mediaExpr.Condition(),
+			ExpressionAttributeNames:  mediaExpr.Names(),
+			ExpressionAttributeValues: mediaExpr.Values(),
+			UpdateExpression:          mediaExpr.Update(),
+		}},
+	}
+
+	_, err = c.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	switch {
+	case err == nil:
+		return true, nil
+	case conditionFailedAt(err, 0):
+		return false, nil
+	case conditionFailedAt(err, 3):
+		return false, domain.ErrMediaNotFound
+	}
+	return false, fmt.Errorf("failed to record view: %w", err)
+}
+
+// GetDailyViews returns view counts per media ID for a single day
+func (c *Client) GetDailyViews(ctx context.Context, day time.Time) (map[string]int64, error) {
+	keyExpr := expression.Key("pk").Equal(expression.Value(viewsPrefix + dayKey(day)))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	counts := make(map[string]int64)
+	paginator := dynamodb.NewQueryPaginator(c.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.analyticsTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query daily views: %w", err)
+		}
+		for _, item := range page.Items {
+			sk, ok := item["sk"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			n, ok := item["view_count"].(*types.AttributeValueMemberN)
+			if !ok {
+				continue
+			}
+			count, err := strconv.ParseInt(n.Value, 10, 64)
+			if err != nil {
+				continue
+			}
+			counts[sk.Value] = count
+		}
+	}
+
+	return counts, nil
+}
+
+// RecordCoView pairs a view with the other media the session viewed in
+// the last week, counting each pair in both directions, and adds it to
+// the session's history
+func (c *Client) RecordCoView(ctx context.Context, mediaID, sessionID string, at time.Time) error {
+	keyExpr := expression.Key("pk").Equal(expression.Value(viewerPrefix + sessionID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.analyticsTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(maxViewerHistory),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query session views: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.analyticsTable),
+		Item: map[string]types.AttributeValue{
+			"pk":         &types.AttributeValueMemberS{Value: viewerPrefix + sessionID},
+			"sk":         &types.AttributeValueMemberS{Value: mediaID},
+			"expires_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(at.Add(viewerTTL).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record session view: %w", err)
+	}
+
+	for _, item := range result.Items {
+		sk, ok := item["sk"].(*types.AttributeValueMemberS)
+		if !ok || sk.Value == mediaID {
+			continue
+		}
+		// The TTL sweep lags, so skip views that have already expired
+		if exp, ok := item["expires_at"].(*types.AttributeValueMemberN); ok {
+			if n, err := strconv.ParseInt(exp.Value, 10, 64); err == nil && n < at.Unix() {
+				continue
+			}
+		}
+		if err := c.addCounter(ctx, c.analyticsTable, coViewKey(mediaID, sk.Value), "count", 1); err != nil {
+			return fmt.Errorf("failed to increment co-views: %w", err)
+		}
+		if err := c.addCounter(ctx, c.analyticsTable, coViewKey(sk.Value, mediaID), "count", 1); err != nil {
+			return fmt.Errorf("failed to increment co-views: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetCoViews returns, per media ID, how often sessions viewing a media
+// item also viewed it
+func (c *Client) GetCoViews(ctx context.Context, mediaID string) (map[string]int64, error) {
+	keyExpr := expression.Key("pk").Equal(expression.Value(coViewPrefix + mediaID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	counts := make(map[string]int64)
+	paginator := dynamodb.NewQueryPaginator(c.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.analyticsTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query co-views: %w", err)
+		}
+		for _, item := range page.Items {
+			sk, ok := item["sk"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			n, ok := item["count"].(*types.AttributeValueMemberN)
+			if !ok {
+				continue
+			}
+			count, err := strconv.ParseInt(n.Value, 10, 64)
+			if err != nil {
+				continue
+			}
+			counts[sk.Value] = count
+		}
+	}
+
+	return counts, nil
+}
+
+// RecordPlaybackEvent folds a player event into the per-media aggregates
+func (c *Client) RecordPlaybackEvent(ctx context.Context, event *domain.PlaybackEvent) error {
+	counters := make(map[string]int64)
+
+	switch event.Type {
+	case domain.PlaybackEventStart:
+		counters["starts"] = 1
+		counters["device#"+event.Device] = 1
+		counters["geo#"+event.Country] = 1
+	case domain.PlaybackEventProgress:
+		if event.Watched > 0 {
+			bucket := int(event.Position) / domain.HeatmapBucketSeconds * domain.HeatmapBucketSeconds
+			counters[fmt.Sprintf("pos#%06d", bucket)] = int64(event.Watched * 1000)
+		}
+	case domain.PlaybackEventComplete:
+		counters["completions"] = 1
+	default:
+		return domain.ErrInvalidInput
+	}
+
+	if err := c.AddMediaCounters(ctx, event.MediaID, counters); err != nil {
+		return fmt.Errorf("failed to record playback event: %w", err)
+	}
+
+	return nil
+}
+
+// AddMediaCounters adds deltas to per-media aggregate counters keyed by sort key
+func (c *Client) AddMediaCounters(ctx context.Context, mediaID string, counters map[string]int64) error {
+	for sk, delta := range counters {
+		if delta == 0 {
+			continue
+		}
+		if err := c.addCounter(ctx, c.analyticsTable, statsKey(mediaID, sk), "count", delta); err != nil {
+			return fmt.Errorf("failed to add counter %s: %w", sk, err)
+		}
+	}
+	return nil
+}
+
+// LogObjectClaimed reports whether a CDN log object was claimed whole by
+// ingestion before counts were applied per media item, and so is already
+// counted
+func (c *Client) LogObjectClaimed(ctx context.Context, key string) (bool, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.analyticsTable),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: cdnLogPrefix},
+			"sk": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get log claim: %w", err)
+	}
+	return result.Item != nil, nil
+}
+
+// ApplyLogCounters adds the counters a CDN log object holds for a media
+// item once, however often the object is ingested: each transaction of
+// counters writes a marker of the object and media item, and is skipped
+// when its marker already exists
+func (c *Client) ApplyLogCounters(ctx context.Context, key, mediaID string, counters map[string]int64) error {
+	sks := make([]string, 0, len(counters))
+	for sk, delta := range counters {
+		if delta != 0 {
+			sks = append(sks, sk)
+		}
+	}
+	// Sorted so a retry splits the counters into the same transactions
+	sort.Strings(sks)
+
+	expiresAt := strconv.FormatInt(time.Now().Add(logMarkerTTL).Unix(), 10)
+	for n := 0; n*logCountersPerWrite < len(sks); n++ {
+		chunk := sks[n*logCountersPerWrite : min((n+1)*logCountersPerWrite, len(sks))]
+
+		items := []types.TransactWriteItem{{Put: &types.Put{
+			TableName: aws.String(c.analyticsTable),
+			Item: map[string]types.AttributeValue{
+				"pk":         &types.AttributeValueMemberS{Value: cdnLogPrefix},
+				"sk":         &types.AttributeValueMemberS{Value: fmt.Sprintf("%s#%s#%d", key, mediaID, n)},
+				"expires_at": &types.AttributeValueMemberN{Value: expiresAt},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(sk)"),
+		}}}
+		for _, sk := range chunk {
+			update := expression.Add(expression.Name("count"), expression.Value(counters[sk]))
+			expr, err := expression.NewBuilder().WithUpdate(update).Build()
+			if err != nil {
+				return fmt.Errorf("failed to build expression: %w", err)
+			}
+			items = append(items, types.TransactWriteItem{Update: &types.Update{
+				TableName:                 aws.String(c.analyticsTable),
+				Key:                       statsKey(mediaID, sk),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				UpdateExpression:          expr.Update(),
+			}})
+		}
+
+		_, err := c.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+		if err != nil && !isConditionFailed(err) {
+			return fmt.Errorf("failed to apply log counters: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetMediaAnalytics reads all aggregates stored for a media item
+func (c *Client) GetMediaAnalytics(ctx context.Context, mediaID string) (*domain.MediaAnalytics, error) {
+	keyExpr := expression.Key("pk").Equal(expression.Value(statsPrefix + mediaID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	stats := &domain.MediaAnalytics{
+		MediaID:   mediaID,
+		Devices:   make(map[string]int64),
+		Countries: make(map[string]int64),
+	}
+
+	// Sort keys are returned in order, so days and buckets come back sorted
+	paginator := dynamodb.NewQueryPaginator(c.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.analyticsTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query media analytics: %w", err)
+		}
+		for _, item := range page.Items {
+			sk, ok := item["sk"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			n, ok := item["count"].(*types.AttributeValueMemberN)
+			if !ok {
+				continue
+			}
+			count, err := strconv.ParseInt(n.Value, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			switch key := sk.Value; {
+			case strings.HasPrefix(key, "day#"):
+				stats.Views = append(stats.Views, domain.DailyCount{Date: strings.TrimPrefix(key, "day#"), Count: count})
+				stats.TotalViews += count
+			case strings.HasPrefix(key, "pos#"):
+				start, err := strconv.Atoi(strings.TrimPrefix(key, "pos#"))
+				if err != nil {
+					continue
+				}
+				stats.Heatmap = append(stats.Heatmap, domain.HeatmapBucket{
+					Start:        start,
+					End:          start + domain.HeatmapBucketSeconds,
+					WatchSeconds: float64(count) / 1000,
+				})
+			case strings.HasPrefix(key, "device#"):
+				stats.Devices[strings.TrimPrefix(key, "device#")] = count
+			case strings.HasPrefix(key, "geo#"):
+				stats.Countries[strings.TrimPrefix(key, "geo#")] = count
+			case key == "starts":
+				stats.Starts = count
+			case key == "completions":
+				stats.Completions = count
+			case key == "cdn_bytes":
+				stats.DeliveredBytes = count
+			case key == "cdn_requests":
+				stats.CDNRequests = count
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// statsKey builds the key of a per-media aggregate item
+func statsKey(mediaID, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: statsPrefix + mediaID},
+		"sk": &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+// coViewKey builds the key of the counter of views a media item shares
+// with another
+func coViewKey(mediaID, otherID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: coViewPrefix + mediaID},
+		"sk": &types.AttributeValueMemberS{Value: otherID},
+	}
+}
+
+// addCounter atomically adds delta to a numeric attribute
+func (c *Client) addCounter(ctx context.Context, table string, key map[string]types.AttributeValue, attr string, delta int64) error {
+	update := expression.Add(expression.Name(attr), expression.Value(delta))
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(table),
+		Key:                       key,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+	return err
+}
@@ -0,0 +1,174 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// CreateAPIKey creates a new API key record in ctx's tenant
+func (c *Client) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	key.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal api key: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.apiKeysTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+// GetAPIKey retrieves an API key by ID
+func (c *Client) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.apiKeysTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+
+	var key domain.APIKey
+	if err := attributevalue.UnmarshalMap(result.Item, &key); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal api key: %w", err)
+	}
+
+	if !owns(ctx, key.TenantID) {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+
+	return &key, nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its secret. It
+// looks across tenants, since the key is what establishes its caller's
+// tenant.
+func (c *Client) GetAPIKeyByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	keyExpr := expression.Key("key_hash").Equal(expression.Value(keyHash))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.apiKeysTable),
+		IndexName:                 aws.String("key_hash-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api key: %w", err)
+	}
+
+	if len(result.Items) == 0 {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+
+	var key domain.APIKey
+	if err := attributevalue.UnmarshalMap(result.Items[0], &key); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal api key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// ListAPIKeysByUser retrieves the API keys owned by a user
+func (c *Client) ListAPIKeysByUser(ctx context.Context, userID string, limit int32) ([]*domain.APIKey, error) {
+	keyExpr := expression.Key("user_id").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.apiKeysTable),
+		IndexName:                 aws.String("user_id-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+
+	var keys []*domain.APIKey
+	for _, item := range result.Items {
+		var key domain.APIKey
+		if err := attributevalue.UnmarshalMap(item, &key); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal api key: %w", err)
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) error {
+	return c.setAPIKeyTime(ctx, id, "revoked_at", "failed to revoke api key")
+}
+
+// TouchAPIKey records that an API key was used
+func (c *Client) TouchAPIKey(ctx context.Context, id string) error {
+	return c.setAPIKeyTime(ctx, id, "last_used_at", "failed to update api key")
+}
+
+// setAPIKeyTime sets a timestamp attribute on an API key
+func (c *Client) setAPIKeyTime(ctx context.Context, id, attr, errMsg string) error {
+	update := expression.Set(
+		expression.Name(attr),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.apiKeysTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// PutAuditEntry stores an audit entry in ctx's tenant
+func (c *Client) PutAuditEntry(ctx context.Context, entry *domain.AuditEntry) error {
+	entry.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.auditTable),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuditEntries retrieves a page of audit entries, newest first. The
+// filter's actor, resource or day selects the index queried; action and
+// outcome are filtered, so a page may hold fewer than limit entries.
+func (c *Client) ListAuditEntries(ctx context.Context, filter *domain.AuditFilter, limit int32, cursor string) ([]*domain.AuditEntry, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var index string
+	var keyExpr expression.KeyConditionBuilder
+	switch {
+	case filter.ActorID != "":
+		index = "actor_id-index"
+		keyExpr = expression.Key("actor_id").Equal(expression.Value(filter.ActorID))
+	case filter.Resource != "":
+		index = "resource-index"
+		keyExpr = expression.Key("resource").Equal(expression.Value(filter.Resource))
+	default:
+		index = "day-index"
+		keyExpr = expression.Key("day").Equal(expression.Value(filter.Day))
+	}
+
+	since := formatTime(filter.Since)
+	until := formatTime(filter.Until)
+	switch {
+	case since != "" && until != "":
+		keyExpr = keyExpr.And(expression.Key("created_at").Between(expression.Value(since), expression.Value(until)))
+	case since != "":
+		keyExpr = keyExpr.And(expression.Key("created_at").GreaterThanEqual(expression.Value(since)))
+	case until != "":
+		keyExpr = keyExpr.And(expression.Key("created_at").LessThanEqual(expression.Value(until)))
+	}
+
+	builder := expression.NewBuilder().WithKeyCondition(keyExpr)
+	conds := []expression.ConditionBuilder{tenantCondition(ctx)}
+	if filter.Action != "" {
+		conds = append(conds, expression.Name("action").Equal(expression.Value(filter.Action)))
+	}
+	if filter.Outcome != "" {
+		conds = append(conds, expression.Name("outcome").Equal(expression.Value(filter.Outcome)))
+	}
+	if len(conds) == 1 {
+		builder = builder.WithFilter(conds[0])
+	} else {
+		builder = builder.WithFilter(expression.And(conds[0], conds[1], conds[2:]...))
+	}
+
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.auditTable),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query audit entries: %w", err)
+	}
+
+	entries := make([]*domain.AuditEntry, 0, len(result.Items))
+	for _, item := range result.Items {
+		var entry domain.AuditEntry
+		if err := attributevalue.UnmarshalMap(item, &entry); err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal audit entry: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return entries, next, nil
+}
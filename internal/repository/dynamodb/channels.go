@@ -0,0 +1,167 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// CreateChannel creates a new channel record in ctx's tenant
+func (c *Client) CreateChannel(ctx context.Context, channel *domain.Channel) error {
+	channel.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(channel)
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.channelsTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create channel: %w", err)
+	}
+
+	return nil
+}
+
+// GetChannel retrieves a channel by ID
+func (c *Client) GetChannel(ctx context.Context, id string) (*domain.Channel, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.channelsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, domain.ErrChannelNotFound
+	}
+
+	var channel domain.Channel
+	if err := attributevalue.UnmarshalMap(result.Item, &channel); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal channel: %w", err)
+	}
+
+	if !owns(ctx, channel.TenantID) {
+		return nil, domain.ErrChannelNotFound
+	}
+
+	return &channel, nil
+}
+
+// UpdateChannel replaces an existing channel record
+func (c *Client) UpdateChannel(ctx context.Context, channel *domain.Channel) error {
+	channel.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(channel)
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(c.channelsTable),
+		Item:                      av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrChannelNotFound
+		}
+		return fmt.Errorf("failed to update channel: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteChannel removes a channel record
+func (c *Client) DeleteChannel(ctx context.Context, id string) error {
+	expr, err := expression.NewBuilder().WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.channelsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrChannelNotFound
+		}
+		return fmt.Errorf("failed to delete channel: %w", err)
+	}
+
+	return nil
+}
+
+// ListChannelsByUser retrieves a page of a user's channels, newest
+// first, returning the cursor of the next page
+func (c *Client) ListChannelsByUser(ctx context.Context, userID string, limit int32, cursor string) ([]*domain.Channel, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("user_id").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.channelsTable),
+		IndexName:                 aws.String("user_id-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query channels: %w", err)
+	}
+
+	channels := make([]*domain.Channel, 0, len(result.Items))
+	for _, item := range result.Items {
+		var channel domain.Channel
+		if err := attributevalue.UnmarshalMap(item, &channel); err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal channel: %w", err)
+		}
+		channels = append(channels, &channel)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return channels, next, nil
+}
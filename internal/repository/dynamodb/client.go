@@ -3,11 +3,10 @@ package dynamodb
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -15,56 +14,136 @@ import (
 
 	appconfig "github.com/streaming-service/internal/config"
 	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/awsconfig"
+	"github.com/streaming-service/internal/repository/dynamodb/embedded"
+	"github.com/streaming-service/internal/tenant"
 )
 
+// api is the part of the DynamoDB API the client uses, served by the AWS
+// SDK or by the embedded store
+type api interface {
+	GetItem(ctx context.Context, in *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, in *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, in *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchGetItem(ctx context.Context, in *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, in *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	DescribeTable(ctx context.Context, in *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
 // Client wraps the AWS DynamoDB client
 type Client struct {
-	client    *dynamodb.Client
-	tableName string
+	client           api
+	tableName        string
+	analyticsTable   string
+	keysTable        string
+	liveTable        string
+	apiKeysTable     string
+	tagsTable        string
+	collectionsTable string
+	channelsTable    string
+	idempotencyTable string
+	auditTable       string
+	quotasTable      string
+	preferencesTable string
+	outboxTable      string
+	webhooksTable    string
+	deliveriesTable  string
+	jobsTable        string
 }
 
 // NewClient creates a new DynamoDB client
 func NewClient(ctx context.Context, cfg appconfig.AWSConfig) (*Client, error) {
 	// Build AWS config
-	var opts []func(*config.LoadOptions) error
-	opts = append(opts, config.WithRegion(cfg.Region))
-
-	// Add credentials if provided
-	if cfg.AccessKeyID != "" && cfg.SecretAccessKey != "" {
-		opts = append(opts, config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(
-				cfg.AccessKeyID,
-				cfg.SecretAccessKey,
-				"",
-			),
-		))
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	client := dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+		o.APIOptions = append(o.APIOptions, awsconfig.CircuitBreaker("DynamoDB", cfg))
+	})
+
+	return newClient(client, cfg), nil
+}
+
+// NewEmbeddedClient creates a client backed by an embedded store saved at
+// path, for running without AWS. An empty path keeps everything in
+// memory.
+func NewEmbeddedClient(cfg appconfig.AWSConfig, path string) (*Client, error) {
+	store, err := embedded.Open(path, schemas(cfg))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, fmt.Errorf("failed to open embedded store: %w", err)
 	}
+	return newClient(store, cfg), nil
+}
 
-	client := dynamodb.NewFromConfig(awsCfg)
-
+func newClient(client api, cfg appconfig.AWSConfig) *Client {
 	return &Client{
-		client:    client,
-		tableName: cfg.DynamoDBTable,
-	}, nil
+		client:           client,
+		tableName:        cfg.DynamoDBTable,
+		analyticsTable:   cfg.AnalyticsTable,
+		keysTable:        cfg.KeysTable,
+		liveTable:        cfg.LiveTable,
+		apiKeysTable:     cfg.APIKeysTable,
+		tagsTable:        cfg.TagsTable,
+		collectionsTable: cfg.CollectionsTable,
+		channelsTable:    cfg.ChannelsTable,
+		idempotencyTable: cfg.IdempotencyTable,
+		auditTable:       cfg.AuditTable,
+		quotasTable:      cfg.QuotasTable,
+		preferencesTable: cfg.PreferencesTable,
+		outboxTable:      cfg.OutboxTable,
+		webhooksTable:    cfg.WebhooksTable,
+		deliveriesTable:  cfg.DeliveriesTable,
+		jobsTable:        cfg.JobsTable,
+	}
+}
+
+// Ping checks that the media table is reachable and serving requests. A
+// table being updated still serves them; one being created, deleted or
+// archived, or whose encryption key is inaccessible, doesn't.
+func (c *Client) Ping(ctx context.Context) error {
+	result, err := c.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(c.tableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe table: %w", err)
+	}
+	switch status := result.Table.TableStatus; status {
+	case types.TableStatusActive, types.TableStatusUpdating:
+		return nil
+	default:
+		return fmt.Errorf("table %s is %s", c.tableName, status)
+	}
 }
 
-// CreateMedia creates a new media record
-func (c *Client) CreateMedia(ctx context.Context, media *domain.Media) error {
-	av, err := attributevalue.MarshalMap(media)
+// CreateMedia creates a new media record in ctx's tenant, writing any
+// events to the outbox in the same transaction
+func (c *Client) CreateMedia(ctx context.Context, media *domain.Media, events ...*domain.Event) error {
+	media.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(media)
 	if err != nil {
 		return fmt.Errorf("failed to marshal media: %w", err)
 	}
 
-	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+	put := &types.Put{
 		TableName:           aws.String(c.tableName),
 		Item:                av,
 		ConditionExpression: aws.String("attribute_not_exists(id)"),
-	})
+	}
+	if len(events) > 0 {
+		err = c.transact(ctx, types.TransactWriteItem{Put: put}, events)
+	} else {
+		_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           put.TableName,
+			Item:                put.Item,
+			ConditionExpression: put.ConditionExpression,
+		})
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create media: %w", err)
 	}
@@ -93,31 +172,103 @@ func (c *Client) GetMedia(ctx context.Context, id string) (*domain.Media, error)
 		return nil, fmt.Errorf("failed to unmarshal media: %w", err)
 	}
 
+	if !owns(ctx, media.TenantID) {
+		return nil, domain.ErrMediaNotFound
+	}
+
 	return &media, nil
 }
 
+// BatchGetMedia retrieves media records by ID, in the order given. IDs
+// without a record in ctx's tenant are skipped.
+func (c *Client) BatchGetMedia(ctx context.Context, ids []string) ([]*domain.Media, error) {
+	byID := make(map[string]*domain.Media, len(ids))
+	for start := 0; start < len(ids); start += batchGetSize {
+		end := start + batchGetSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		keys := make([]map[string]types.AttributeValue, 0, end-start)
+		seen := make(map[string]bool, end-start)
+		for _, id := range ids[start:end] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			keys = append(keys, map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: id},
+			})
+		}
+
+		pending := map[string]types.KeysAndAttributes{c.tableName: {Keys: keys}}
+		for len(pending) > 0 {
+			result, err := c.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: pending,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get media: %w", err)
+			}
+
+			for _, item := range result.Responses[c.tableName] {
+				var media domain.Media
+				if err := attributevalue.UnmarshalMap(item, &media); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal media: %w", err)
+				}
+				if owns(ctx, media.TenantID) {
+					byID[media.ID] = &media
+				}
+			}
+
+			pending = result.UnprocessedKeys
+		}
+	}
+
+	mediaList := make([]*domain.Media, 0, len(ids))
+	for _, id := range ids {
+		if media, ok := byID[id]; ok {
+			mediaList = append(mediaList, media)
+		}
+	}
+
+	return mediaList, nil
+}
+
 // UpdateMedia updates an existing media record
 func (c *Client) UpdateMedia(ctx context.Context, media *domain.Media) error {
 	media.UpdatedAt = time.Now()
+	media.TenantID = tenant.FromContext(ctx)
 
-	av, err := attributevalue.MarshalMap(media)
+	av, err := marshalMap(media)
 	if err != nil {
 		return fmt.Errorf("failed to marshal media: %w", err)
 	}
 
+	expr, err := expression.NewBuilder().WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
 	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(c.tableName),
-		Item:      av,
+		TableName:                 aws.String(c.tableName),
+		Item:                      av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
 	})
 	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
 		return fmt.Errorf("failed to update media: %w", err)
 	}
 
 	return nil
 }
 
-// UpdateMediaStatus updates only the status and timestamp
-func (c *Client) UpdateMediaStatus(ctx context.Context, id string, status domain.MediaStatus) error {
+// UpdateMediaStatus updates only the status and timestamp, writing any
+// events to the outbox in the same transaction
+func (c *Client) UpdateMediaStatus(ctx context.Context, id string, status domain.MediaStatus, events ...*domain.Event) error {
 	update := expression.Set(
 		expression.Name("status"),
 		expression.Value(status),
@@ -133,7 +284,54 @@ func (c *Client) UpdateMediaStatus(ctx context.Context, id string, status domain
 		)
 	}
 
-	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	write := &types.Update{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+	if len(events) > 0 {
+		err = c.transact(ctx, types.TransactWriteItem{Update: write}, events)
+	} else {
+		_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 write.TableName,
+			Key:                       write.Key,
+			ExpressionAttributeNames:  write.ExpressionAttributeNames,
+			ExpressionAttributeValues: write.ExpressionAttributeValues,
+			UpdateExpression:          write.UpdateExpression,
+			ConditionExpression:       write.ConditionExpression,
+		})
+	}
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMediaProcessing records the worker's progress through processing
+func (c *Client) UpdateMediaProcessing(ctx context.Context, id string, progress *domain.ProcessingProgress) error {
+	update := expression.Set(
+		expression.Name("processing"),
+		expression.Value(progress),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
 	if err != nil {
 		return fmt.Errorf("failed to build expression: %w", err)
 	}
@@ -146,123 +344,742 @@ func (c *Client) UpdateMediaStatus(ctx context.Context, id string, status domain
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to update status: %w", err)
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update processing progress: %w", err)
+	}
+
+	return nil
+}
+
+// RetryMedia moves failed media back to pending with its processing
+// queued, replacing its retry history, or returns ErrInvalidMediaStatus
+// if it isn't failed. Only one of concurrent retries succeeds.
+func (c *Client) RetryMedia(ctx context.Context, id string, retries []domain.ProcessingRetry) error {
+	update := expression.Set(
+		expression.Name("status"),
+		expression.Value(domain.MediaStatusPending),
+	).Set(
+		expression.Name("processing"),
+		expression.Value(domain.NewProcessingProgress(domain.ProcessingStageQueued)),
+	).Set(
+		expression.Name("retries"),
+		expression.Value(retries),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+	cond := ownedCondition(ctx).And(expression.Name("status").Equal(expression.Value(domain.MediaStatusFailed)))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrInvalidMediaStatus
+		}
+		return fmt.Errorf("failed to retry media: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMediaVisibility updates only the visibility and timestamp
+func (c *Client) UpdateMediaVisibility(ctx context.Context, id string, visibility domain.Visibility) error {
+	update := expression.Set(
+		expression.Name("visibility"),
+		expression.Value(visibility),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update visibility: %w", err)
+	}
+
+	return nil
+}
+
+// SetMediaAdBreaks replaces only the ad breaks of a media item, clearing
+// them when breaks is empty, so a write while the media is processing
+// leaves the worker's progress and the counters alone
+func (c *Client) SetMediaAdBreaks(ctx context.Context, id string, breaks []domain.AdBreak) error {
+	update := expression.Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+	if len(breaks) > 0 {
+		update = update.Set(expression.Name("ad_breaks"), expression.Value(breaks))
+	} else {
+		update = update.Remove(expression.Name("ad_breaks"))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update ad breaks: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMediaDetails updates the title and description given, leaving
+// those that are nil
+func (c *Client) UpdateMediaDetails(ctx context.Context, id string, title, description *string) error {
+	update := expression.Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+	if title != nil {
+		update = update.Set(expression.Name("title"), expression.Value(*title))
+	}
+	if description != nil {
+		update = update.Set(expression.Name("description"), expression.Value(*description))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update details: %w", err)
+	}
+
+	return nil
+}
+
+// SetMediaMetadata records what probing a media item's source found,
+// along with the summary fields taken from its first video stream
+func (c *Client) SetMediaMetadata(ctx context.Context, id string, metadata *domain.SourceMetadata) error {
+	update := expression.Set(
+		expression.Name("source_metadata"),
+		expression.Value(metadata),
+	).Set(
+		expression.Name("duration"),
+		expression.Value(metadata.Duration),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+	if metadata.Bitrate > 0 {
+		update = update.Set(expression.Name("bitrate"), expression.Value(metadata.Bitrate))
+	}
+	if len(metadata.Video) > 0 {
+		video := metadata.Video[0]
+		update = update.Set(expression.Name("width"), expression.Value(video.Width)).
+			Set(expression.Name("height"), expression.Value(video.Height)).
+			Set(expression.Name("codec"), expression.Value(video.Codec))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	return nil
+}
+
+// SetMediaCopyright records the result of matching a media item's audio
+// against the copyright reference set
+func (c *Client) SetMediaCopyright(ctx context.Context, id string, check *domain.CopyrightCheck) error {
+	update := expression.Set(
+		expression.Name("copyright"),
+		expression.Value(check),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update copyright check: %w", err)
+	}
+
+	return nil
+}
+
+// SetMediaEnrichment records the summary and chapters generated for a
+// media item
+func (c *Client) SetMediaEnrichment(ctx context.Context, id string, enrichment *domain.Enrichment) error {
+	update := expression.Set(
+		expression.Name("enrichment"),
+		expression.Value(enrichment),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update enrichment: %w", err)
 	}
 
 	return nil
 }
 
-// DeleteMedia removes a media record
-func (c *Client) DeleteMedia(ctx context.Context, id string) error {
-	_, err := c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+// SetMediaTranscriptFlags records what filtering a media item's generated
+// captions found
+func (c *Client) SetMediaTranscriptFlags(ctx context.Context, id string, flags *domain.TranscriptFlags) error {
+	update := expression.Set(
+		expression.Name("transcript_flags"),
+		expression.Value(flags),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(c.tableName),
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberS{Value: id},
 		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
 	})
 	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update transcript flags: %w", err)
+	}
+
+	return nil
+}
+
+// SetMediaCaptioning records the state of a media item's automatic
+// captions
+func (c *Client) SetMediaCaptioning(ctx context.Context, id string, captioning *domain.Captioning) error {
+	update := expression.Set(
+		expression.Name("captioning"),
+		expression.Value(captioning),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update captioning: %w", err)
+	}
+
+	return nil
+}
+
+// SetMediaSubtitles replaces the subtitle tracks of a media item
+func (c *Client) SetMediaSubtitles(ctx context.Context, id string, tracks []domain.SubtitleTrack) error {
+	update := expression.Set(
+		expression.Name("subtitles"),
+		expression.Value(tracks),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update subtitles: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMediaChannel publishes a media item to a channel, or unpublishes
+// it, along with any episode, when channelID is empty
+func (c *Client) UpdateMediaChannel(ctx context.Context, id, channelID string) error {
+	update := expression.Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+	if channelID != "" {
+		update = update.Set(expression.Name("channel_id"), expression.Value(channelID))
+	} else {
+		update = update.Remove(expression.Name("channel_id")).Remove(expression.Name("episode"))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update channel: %w", err)
+	}
+
+	return nil
+}
+
+// SetMediaEpisode publishes a media item to a channel as an episode
+func (c *Client) SetMediaEpisode(ctx context.Context, id, channelID string, episode *domain.Episode) error {
+	update := expression.Set(
+		expression.Name("channel_id"),
+		expression.Value(channelID),
+	).Set(
+		expression.Name("episode"),
+		expression.Value(episode),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	return c.updateEpisode(ctx, id, update)
+}
+
+// RemoveMediaEpisode unpublishes a media item's episode, leaving it in its
+// channel
+func (c *Client) RemoveMediaEpisode(ctx context.Context, id string) error {
+	update := expression.Remove(
+		expression.Name("episode"),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	return c.updateEpisode(ctx, id, update)
+}
+
+func (c *Client) updateEpisode(ctx context.Context, id string, update expression.UpdateBuilder) error {
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update episode: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMedia removes a media record, writing any events to the outbox in
+// the same transaction. Media under legal hold is kept with ErrLegalHold.
+func (c *Client) DeleteMedia(ctx context.Context, id string, events ...*domain.Event) error {
+	expr, err := expression.NewBuilder().WithCondition(unheldCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	del := &types.Delete{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	if len(events) > 0 {
+		err = c.transact(ctx, types.TransactWriteItem{Delete: del}, events)
+	} else {
+		_, err = c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:                 del.TableName,
+			Key:                       del.Key,
+			ConditionExpression:       del.ConditionExpression,
+			ExpressionAttributeNames:  del.ExpressionAttributeNames,
+			ExpressionAttributeValues: del.ExpressionAttributeValues,
+		})
+	}
+	if err != nil {
+		if isConditionFailed(err) {
+			return c.heldOrMissing(ctx, id)
+		}
 		return fmt.Errorf("failed to delete media: %w", err)
 	}
 
 	return nil
 }
 
-// ListMediaByUser retrieves all media for a user
-func (c *Client) ListMediaByUser(ctx context.Context, userID string, limit int32) ([]*domain.Media, error) {
+// ListMediaByUser retrieves a page of media for a user matching filter.
+// The creation date range and order are applied by the index; other
+// criteria are filtered server-side, so a page may hold fewer than limit
+// items. Pass the returned cursor back to fetch the next page; it is
+// empty after the last page.
+func (c *Client) ListMediaByUser(ctx context.Context, userID string, filter *domain.MediaFilter, limit int32, cursor string) ([]*domain.Media, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if filter == nil {
+		filter = &domain.MediaFilter{}
+	}
+
 	keyExpr := expression.Key("user_id").Equal(expression.Value(userID))
-	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	createdAfter := formatTime(filter.CreatedAfter)
+	createdBefore := formatTime(filter.CreatedBefore)
+	switch {
+	case createdAfter != "" && createdBefore != "":
+		keyExpr = keyExpr.And(expression.Key("created_at").Between(expression.Value(createdAfter), expression.Value(createdBefore)))
+	case createdAfter != "":
+		keyExpr = keyExpr.And(expression.Key("created_at").GreaterThanEqual(expression.Value(createdAfter)))
+	case createdBefore != "":
+		keyExpr = keyExpr.And(expression.Key("created_at").LessThanEqual(expression.Value(createdBefore)))
+	}
+
+	cond, ok := mediaFilterCondition(filter)
+	builder := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(withTenant(ctx, cond, ok))
+
+	expr, err := builder.Build()
 	if err != nil {
-		return nil, fmt.Errorf("failed to build expression: %w", err)
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
 	}
 
 	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:                 aws.String(c.tableName),
 		IndexName:                 aws.String("user_id-index"),
 		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(filter.Ascending),
 		Limit:                     aws.Int32(limit),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to query media: %w", err)
+		return nil, "", fmt.Errorf("failed to query media: %w", err)
 	}
 
 	var mediaList []*domain.Media
 	for _, item := range result.Items {
 		var media domain.Media
 		if err := attributevalue.UnmarshalMap(item, &media); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal media: %w", err)
+			return nil, "", fmt.Errorf("failed to unmarshal media: %w", err)
 		}
 		mediaList = append(mediaList, &media)
 	}
 
-	return mediaList, nil
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return mediaList, next, nil
 }
 
-// ListMediaByStatus retrieves media by processing status
-func (c *Client) ListMediaByStatus(ctx context.Context, status domain.MediaStatus, limit int32) ([]*domain.Media, error) {
-	keyExpr := expression.Key("status").Equal(expression.Value(string(status)))
-	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+// ListMediaByChannel retrieves a page of media published to a channel,
+// newest first. With publicOnly, media that is unprocessed or left out of
+// public listings is filtered out, so a page may hold fewer than limit
+// items.
+func (c *Client) ListMediaByChannel(ctx context.Context, channelID string, publicOnly bool, limit int32, cursor string) ([]*domain.Media, string, error) {
+	startKey, err := decodeCursor(cursor)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build expression: %w", err)
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("channel_id").Equal(expression.Value(channelID))
+	var cond expression.ConditionBuilder
+	if publicOnly {
+		cond = expression.And(
+			expression.Name("status").Equal(expression.Value(domain.MediaStatusCompleted)),
+			expression.Or(
+				expression.Name("visibility").Equal(expression.Value(domain.VisibilityPublic)),
+				expression.AttributeNotExists(expression.Name("visibility")),
+			),
+		)
+	}
+	builder := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(withTenant(ctx, cond, publicOnly))
+
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
 	}
 
 	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:                 aws.String(c.tableName),
-		IndexName:                 aws.String("status-index"),
+		IndexName:                 aws.String("channel_id-index"),
 		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(false),
 		Limit:                     aws.Int32(limit),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to query media: %w", err)
+		return nil, "", fmt.Errorf("failed to query media: %w", err)
 	}
 
 	var mediaList []*domain.Media
 	for _, item := range result.Items {
 		var media domain.Media
 		if err := attributevalue.UnmarshalMap(item, &media); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal media: %w", err)
+			return nil, "", fmt.Errorf("failed to unmarshal media: %w", err)
 		}
 		mediaList = append(mediaList, &media)
 	}
 
-	return mediaList, nil
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return mediaList, next, nil
 }
 
-// AddRendition adds a rendition to a media record
-func (c *Client) AddRendition(ctx context.Context, id string, rendition domain.Rendition) error {
-	update := expression.Set(
-		expression.Name("renditions"),
-		expression.ListAppend(
-			expression.Name("renditions"),
-			expression.Value([]domain.Rendition{rendition}),
-		),
-	).Set(
-		expression.Name("updated_at"),
-		expression.Value(time.Now()),
-	)
+// mediaFilterCondition builds the filter expression for the non-key
+// criteria of a media filter
+func mediaFilterCondition(filter *domain.MediaFilter) (expression.ConditionBuilder, bool) {
+	var conds []expression.ConditionBuilder
+
+	if filter.Status != "" {
+		conds = append(conds, expression.Name("status").Equal(expression.Value(filter.Status)))
+	}
+	if filter.Type != "" {
+		conds = append(conds, expression.Name("type").Equal(expression.Value(filter.Type)))
+	}
+	if filter.Tag != "" {
+		if key, value, ok := strings.Cut(filter.Tag, ":"); ok {
+			conds = append(conds, expression.Name("tags."+key).Equal(expression.Value(value)))
+		} else {
+			conds = append(conds, expression.AttributeExists(expression.Name("tags."+key)))
+		}
+	}
+
+	switch len(conds) {
+	case 0:
+		return expression.ConditionBuilder{}, false
+	case 1:
+		return conds[0], true
+	default:
+		return expression.And(conds[0], conds[1], conds[2:]...), true
+	}
+}
+
+// timeLayout is how times are stored: in UTC with fixed-width fractional
+// seconds, so they sort as strings where they are index range keys.
+// time.RFC3339Nano trims trailing zeros, which sorts "05Z" after "05.1Z",
+// and keeps the zone, which doesn't sort at all.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// formatTime formats a time as stored, such as a bound on a timestamp
+// attribute, or returns an empty string for the zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+// marshalMap marshals a record for storage, with its times in timeLayout
+func marshalMap(in interface{}) (map[string]types.AttributeValue, error) {
+	return attributevalue.MarshalMapWithOptions(in, func(o *attributevalue.EncoderOptions) {
+		o.EncodeTime = func(t time.Time) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberS{Value: t.UTC().Format(timeLayout)}, nil
+		}
+	})
+}
 
-	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+// ListMediaByStatus retrieves media by processing status
+func (c *Client) ListMediaByStatus(ctx context.Context, status domain.MediaStatus, limit int32) ([]*domain.Media, error) {
+	keyExpr := expression.Key("status").Equal(expression.Value(string(status)))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
 	if err != nil {
-		return fmt.Errorf("failed to build expression: %w", err)
+		return nil, fmt.Errorf("failed to build expression: %w", err)
 	}
 
-	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(c.tableName),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id},
-		},
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.tableName),
+		IndexName:                 aws.String("status-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
-		UpdateExpression:          expr.Update(),
+		Limit:                     aws.Int32(limit),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to add rendition: %w", err)
+		return nil, fmt.Errorf("failed to query media: %w", err)
 	}
 
-	return nil
+	var mediaList []*domain.Media
+	for _, item := range result.Items {
+		var media domain.Media
+		if err := attributevalue.UnmarshalMap(item, &media); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal media: %w", err)
+		}
+		mediaList = append(mediaList, &media)
+	}
+
+	return mediaList, nil
 }
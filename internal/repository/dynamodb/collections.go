@@ -0,0 +1,167 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// CreateCollection creates a new collection record in ctx's tenant
+func (c *Client) CreateCollection(ctx context.Context, collection *domain.Collection) error {
+	collection.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(collection)
+	if err != nil {
+		return fmt.Errorf("failed to marshal collection: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.collectionsTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	return nil
+}
+
+// GetCollection retrieves a collection by ID
+func (c *Client) GetCollection(ctx context.Context, id string) (*domain.Collection, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.collectionsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, domain.ErrCollectionNotFound
+	}
+
+	var collection domain.Collection
+	if err := attributevalue.UnmarshalMap(result.Item, &collection); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal collection: %w", err)
+	}
+
+	if !owns(ctx, collection.TenantID) {
+		return nil, domain.ErrCollectionNotFound
+	}
+
+	return &collection, nil
+}
+
+// UpdateCollection replaces an existing collection record
+func (c *Client) UpdateCollection(ctx context.Context, collection *domain.Collection) error {
+	collection.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(collection)
+	if err != nil {
+		return fmt.Errorf("failed to marshal collection: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(c.collectionsTable),
+		Item:                      av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrCollectionNotFound
+		}
+		return fmt.Errorf("failed to update collection: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCollection removes a collection record
+func (c *Client) DeleteCollection(ctx context.Context, id string) error {
+	expr, err := expression.NewBuilder().WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.collectionsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrCollectionNotFound
+		}
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+
+	return nil
+}
+
+// ListCollectionsByUser retrieves a page of a user's collections, newest
+// first, returning the cursor of the next page
+func (c *Client) ListCollectionsByUser(ctx context.Context, userID string, limit int32, cursor string) ([]*domain.Collection, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("user_id").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.collectionsTable),
+		IndexName:                 aws.String("user_id-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query collections: %w", err)
+	}
+
+	collections := make([]*domain.Collection, 0, len(result.Items))
+	for _, item := range result.Items {
+		var collection domain.Collection
+		if err := attributevalue.UnmarshalMap(item, &collection); err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal collection: %w", err)
+		}
+		collections = append(collections, &collection)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return collections, next, nil
+}
@@ -0,0 +1,56 @@
+package dynamodb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// encodeCursor turns a query's LastEvaluatedKey into an opaque page
+// cursor. It returns an empty cursor on the last page.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	var values map[string]interface{}
+	if err := attributevalue.UnmarshalMap(key, &values); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor turns a page cursor back into an ExclusiveStartKey. Cursors
+// that cannot be decoded yield ErrInvalidInput.
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil || len(values) == 0 {
+		return nil, domain.ErrInvalidInput
+	}
+
+	key, err := attributevalue.MarshalMap(values)
+	if err != nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	return key, nil
+}
@@ -0,0 +1,1010 @@
+package embedded
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// token kinds of the expression language
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+// tokenize splits an expression into names, placeholders, list indexes
+// and punctuation
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':' || c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[i:j]})
+			i = j
+		case unicode.IsDigit(c):
+			j := i + 1
+			for j < len(s) && unicode.IsDigit(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[i:j]})
+			i = j
+		case strings.HasPrefix(s[i:], "<>"), strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			tokens = append(tokens, token{kind: tokenPunct, text: s[i : i+2]})
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", c):
+			tokens = append(tokens, token{kind: tokenPunct, text: string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q in expression", c)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// pathElem is one step of a document path: an attribute name or, when
+// name is empty, a list index
+type pathElem struct {
+	name  string
+	index int
+}
+
+type path []pathElem
+
+func (p path) String() string {
+	var b strings.Builder
+	for i, e := range p {
+		switch {
+		case e.name == "":
+			fmt.Fprintf(&b, "[%d]", e.index)
+		case i > 0:
+			b.WriteString("." + e.name)
+		default:
+			b.WriteString(e.name)
+		}
+	}
+	return b.String()
+}
+
+// resolve looks a path up in an item
+func (p path) resolve(it item) (types.AttributeValue, bool) {
+	var cur types.AttributeValue = &types.AttributeValueMemberM{Value: it}
+	for _, e := range p {
+		switch v := cur.(type) {
+		case *types.AttributeValueMemberM:
+			if e.name == "" {
+				return nil, false
+			}
+			next, ok := v.Value[e.name]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case *types.AttributeValueMemberL:
+			if e.name != "" || e.index >= len(v.Value) {
+				return nil, false
+			}
+			cur = v.Value[e.index]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// parent returns the map or list holding the last element of the path
+func (p path) parent(it item) (types.AttributeValue, error) {
+	parent := path(p[:len(p)-1])
+	if len(parent) == 0 {
+		return &types.AttributeValueMemberM{Value: it}, nil
+	}
+	av, ok := parent.resolve(it)
+	if !ok {
+		return nil, fmt.Errorf("the document path %s does not exist", parent)
+	}
+	return av, nil
+}
+
+// set stores a value at the path, creating its last element
+func (p path) set(it item, av types.AttributeValue) error {
+	parent, err := p.parent(it)
+	if err != nil {
+		return err
+	}
+	last := p[len(p)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if last.name == "" {
+			return fmt.Errorf("the document path %s indexes a map", p)
+		}
+		v.Value[last.name] = av
+	case *types.AttributeValueMemberL:
+		if last.name != "" {
+			return fmt.Errorf("the document path %s names a list element", p)
+		}
+		if last.index >= len(v.Value) {
+			v.Value = append(v.Value, av)
+		} else {
+			v.Value[last.index] = av
+		}
+	default:
+		return fmt.Errorf("the document path %s is not in a map or list", p)
+	}
+	return nil
+}
+
+// remove deletes the value at the path, if there is one
+func (p path) remove(it item) {
+	parent, err := p.parent(it)
+	if err != nil {
+		return
+	}
+	last := p[len(p)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		delete(v.Value, last.name)
+	case *types.AttributeValueMemberL:
+		if last.name == "" && last.index < len(v.Value) {
+			v.Value = append(v.Value[:last.index], v.Value[last.index+1:]...)
+		}
+	}
+}
+
+// parser reads an expression's tokens, substituting placeholders
+type parser struct {
+	tokens []token
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newParser(expr string, names map[string]string, values map[string]types.AttributeValue) (*parser, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens, names: names, values: values}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is the keyword kw
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokenIdent && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// punct consumes the next token if it is the punctuation s
+func (p *parser) punct(s string) bool {
+	t := p.peek()
+	if t.kind == tokenPunct && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.punct(s) {
+		return fmt.Errorf("expected %q, got %q", s, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) done() error {
+	if t := p.peek(); t.kind != tokenEOF {
+		return fmt.Errorf("unexpected %q in expression", t.text)
+	}
+	return nil
+}
+
+// isFunction reports whether the next tokens call the function name
+func (p *parser) isFunction(name string) bool {
+	t := p.peek()
+	if t.kind != tokenIdent || !strings.EqualFold(t.text, name) {
+		return false
+	}
+	n := p.tokens[p.pos+1]
+	return n.kind == tokenPunct && n.text == "("
+}
+
+// path parses a document path such as #0.#1[2]
+func (p *parser) path() (path, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	result := path{{name: name}}
+	for {
+		switch {
+		case p.punct("."):
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, pathElem{name: name})
+		case p.punct("["):
+			t := p.next()
+			if t.kind != tokenNumber {
+				return nil, fmt.Errorf("expected a list index, got %q", t.text)
+			}
+			index, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			result = append(result, pathElem{index: index})
+		default:
+			return result, nil
+		}
+	}
+}
+
+// name parses an attribute name or #placeholder
+func (p *parser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokenIdent || strings.HasPrefix(t.text, ":") {
+		return "", fmt.Errorf("expected an attribute name, got %q", t.text)
+	}
+	if strings.HasPrefix(t.text, "#") {
+		name, ok := p.names[t.text]
+		if !ok {
+			return "", fmt.Errorf("expression attribute name %s is not defined", t.text)
+		}
+		return name, nil
+	}
+	return t.text, nil
+}
+
+// operand is a value an expression reads: a path, a :placeholder or a
+// function of them
+type operand func(it item) (types.AttributeValue, bool, error)
+
+// operand parses a path, :placeholder or size() call
+func (p *parser) operand() (operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenIdent && strings.HasPrefix(t.text, ":"):
+		p.next()
+		av, ok := p.values[t.text]
+		if !ok {
+			return nil, fmt.Errorf("expression attribute value %s is not defined", t.text)
+		}
+		return func(item) (types.AttributeValue, bool, error) { return av, true, nil }, nil
+	case p.isFunction("size"):
+		p.next()
+		p.next()
+		target, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(it item) (types.AttributeValue, bool, error) {
+			av, ok := target.resolve(it)
+			if !ok {
+				return nil, false, nil
+			}
+			n, err := size(av)
+			if err != nil {
+				return nil, false, err
+			}
+			return &types.AttributeValueMemberN{Value: strconv.Itoa(n)}, true, nil
+		}, nil
+	default:
+		target, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return func(it item) (types.AttributeValue, bool, error) {
+			av, ok := target.resolve(it)
+			return av, ok, nil
+		}, nil
+	}
+}
+
+// size implements the size() function
+func size(av types.AttributeValue) (int, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value), nil
+	case *types.AttributeValueMemberB:
+		return len(v.Value), nil
+	case *types.AttributeValueMemberM:
+		return len(v.Value), nil
+	case *types.AttributeValueMemberL:
+		return len(v.Value), nil
+	case *types.AttributeValueMemberSS:
+		return len(v.Value), nil
+	case *types.AttributeValueMemberNS:
+		return len(v.Value), nil
+	case *types.AttributeValueMemberBS:
+		return len(v.Value), nil
+	}
+	return 0, fmt.Errorf("size() does not apply to %s values", typeName(av))
+}
+
+// condition is a parsed condition, key condition or filter expression
+type condition func(it item) (bool, error)
+
+// parseCondition parses a condition expression. An empty expression
+// matches every item.
+func parseCondition(expr *string, names map[string]string, values map[string]types.AttributeValue) (condition, error) {
+	if expr == nil || strings.TrimSpace(*expr) == "" {
+		return func(item) (bool, error) { return true, nil }, nil
+	}
+	p, err := newParser(*expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.done(); err != nil {
+		return nil, err
+	}
+	return cond, nil
+}
+
+func (p *parser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(it item) (bool, error) {
+			ok, err := l(it)
+			if err != nil || ok {
+				return ok, err
+			}
+			return right(it)
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(it item) (bool, error) {
+			ok, err := l(it)
+			if err != nil || !ok {
+				return ok, err
+			}
+			return right(it)
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (condition, error) {
+	if p.keyword("NOT") {
+		inner, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(it item) (bool, error) {
+			ok, err := inner(it)
+			return !ok, err
+		}, nil
+	}
+	return p.predicate()
+}
+
+// predicate parses a parenthesized condition, a function call or a
+// comparison
+func (p *parser) predicate() (condition, error) {
+	if p.punct("(") {
+		cond, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expect(")")
+	}
+
+	for _, fn := range []string{"attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains"} {
+		if p.isFunction(fn) {
+			return p.function(fn)
+		}
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.keyword("BETWEEN"):
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, fmt.Errorf("expected AND in BETWEEN")
+		}
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(it item) (bool, error) {
+			v, lo, hi, ok, err := evalAll(it, left, low, high)
+			if err != nil || !ok {
+				return false, err
+			}
+			c1, ok1 := compare(v, lo)
+			c2, ok2 := compare(v, hi)
+			return ok1 && ok2 && c1 >= 0 && c2 <= 0, nil
+		}, nil
+
+	case p.keyword("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var candidates []operand
+		for {
+			c, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, c)
+			if !p.punct(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(it item) (bool, error) {
+			v, ok, err := left(it)
+			if err != nil || !ok {
+				return false, err
+			}
+			for _, c := range candidates {
+				w, ok, err := c(it)
+				if err != nil {
+					return false, err
+				}
+				if ok && equal(v, w) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}
+
+	op := p.next()
+	if op.kind != tokenPunct {
+		return nil, fmt.Errorf("expected a comparison, got %q", op.text)
+	}
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch op.text {
+	case "=":
+		return func(it item) (bool, error) {
+			a, b, _, ok, err := evalAll(it, left, right, right)
+			return ok && equal(a, b), err
+		}, nil
+	case "<>":
+		// A missing attribute is not equal to anything
+		return func(it item) (bool, error) {
+			a, b, _, ok, err := evalAll(it, left, right, right)
+			return !ok || !equal(a, b), err
+		}, nil
+	case "<", "<=", ">", ">=":
+		return func(it item) (bool, error) {
+			a, b, _, ok, err := evalAll(it, left, right, right)
+			if err != nil || !ok {
+				return false, err
+			}
+			cmp, comparable := compare(a, b)
+			if !comparable {
+				return false, nil
+			}
+			switch op.text {
+			case "<":
+				return cmp < 0, nil
+			case "<=":
+				return cmp <= 0, nil
+			case ">":
+				return cmp > 0, nil
+			default:
+				return cmp >= 0, nil
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown comparison %q", op.text)
+}
+
+// evalAll evaluates three operands, with ok false if any is missing
+func evalAll(it item, a, b, c operand) (types.AttributeValue, types.AttributeValue, types.AttributeValue, bool, error) {
+	va, okA, err := a(it)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	vb, okB, err := b(it)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	vc, okC, err := c(it)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	return va, vb, vc, okA && okB && okC, nil
+}
+
+// function parses a condition function call
+func (p *parser) function(fn string) (condition, error) {
+	p.next()
+	p.next()
+	target, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	var arg operand
+	if fn != "attribute_exists" && fn != "attribute_not_exists" {
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if arg, err = p.operand(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return func(it item) (bool, error) {
+		v, exists := target.resolve(it)
+		switch fn {
+		case "attribute_exists":
+			return exists, nil
+		case "attribute_not_exists":
+			return !exists, nil
+		}
+		if !exists {
+			return false, nil
+		}
+		a, ok, err := arg(it)
+		if err != nil || !ok {
+			return false, err
+		}
+		switch fn {
+		case "attribute_type":
+			s, isS := a.(*types.AttributeValueMemberS)
+			return isS && typeName(v) == s.Value, nil
+		case "begins_with":
+			switch x := v.(type) {
+			case *types.AttributeValueMemberS:
+				y, ok := a.(*types.AttributeValueMemberS)
+				return ok && strings.HasPrefix(x.Value, y.Value), nil
+			case *types.AttributeValueMemberB:
+				y, ok := a.(*types.AttributeValueMemberB)
+				return ok && strings.HasPrefix(string(x.Value), string(y.Value)), nil
+			}
+			return false, nil
+		default: // contains
+			switch x := v.(type) {
+			case *types.AttributeValueMemberS:
+				y, ok := a.(*types.AttributeValueMemberS)
+				return ok && strings.Contains(x.Value, y.Value), nil
+			case *types.AttributeValueMemberL:
+				for _, e := range x.Value {
+					if equal(e, a) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+			if members, ok := setMembers(v); ok {
+				key, err := keyString(a)
+				if err != nil {
+					return false, nil
+				}
+				for _, m := range members {
+					if m == key {
+						return true, nil
+					}
+				}
+			}
+			return false, nil
+		}
+	}, nil
+}
+
+// update actions, in the order DynamoDB applies them
+const (
+	actionSet = iota
+	actionRemove
+	actionAdd
+	actionDelete
+)
+
+// updateAction is one action of an update expression
+type updateAction struct {
+	kind  int
+	path  path
+	value operand
+}
+
+// parseUpdate parses an update expression
+func parseUpdate(expr *string, names map[string]string, values map[string]types.AttributeValue) ([]updateAction, error) {
+	if expr == nil || strings.TrimSpace(*expr) == "" {
+		return nil, nil
+	}
+	p, err := newParser(*expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []updateAction
+	for p.peek().kind != tokenEOF {
+		var kind int
+		switch {
+		case p.keyword("SET"):
+			kind = actionSet
+		case p.keyword("REMOVE"):
+			kind = actionRemove
+		case p.keyword("ADD"):
+			kind = actionAdd
+		case p.keyword("DELETE"):
+			kind = actionDelete
+		default:
+			return nil, fmt.Errorf("expected SET, REMOVE, ADD or DELETE, got %q", p.peek().text)
+		}
+
+		for {
+			target, err := p.path()
+			if err != nil {
+				return nil, err
+			}
+			action := updateAction{kind: kind, path: target}
+			switch kind {
+			case actionSet:
+				if err := p.expect("="); err != nil {
+					return nil, err
+				}
+				if action.value, err = p.setValue(); err != nil {
+					return nil, err
+				}
+			case actionAdd, actionDelete:
+				if action.value, err = p.operand(); err != nil {
+					return nil, err
+				}
+			}
+			actions = append(actions, action)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+	return actions, nil
+}
+
+// setValue parses the right-hand side of a SET action
+func (p *parser) setValue() (operand, error) {
+	left, err := p.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	var sign int64
+	switch {
+	case p.punct("+"):
+		sign = 1
+	case p.punct("-"):
+		sign = -1
+	default:
+		return left, nil
+	}
+	right, err := p.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) (types.AttributeValue, bool, error) {
+		a, b, _, ok, err := evalAll(it, left, right, right)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, fmt.Errorf("an operand in the update expression does not exist")
+		}
+		sum, err := addNumbers(a, b, sign)
+		return sum, err == nil, err
+	}, nil
+}
+
+// setOperand parses an operand of a SET action, which may call
+// if_not_exists or list_append
+func (p *parser) setOperand() (operand, error) {
+	switch {
+	case p.isFunction("if_not_exists"):
+		p.next()
+		p.next()
+		target, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(it item) (types.AttributeValue, bool, error) {
+			if av, ok := target.resolve(it); ok {
+				return av, true, nil
+			}
+			return fallback(it)
+		}, nil
+
+	case p.isFunction("list_append"):
+		p.next()
+		p.next()
+		first, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		second, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(it item) (types.AttributeValue, bool, error) {
+			a, b, _, ok, err := evalAll(it, first, second, second)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				return nil, false, fmt.Errorf("an operand of list_append does not exist")
+			}
+			x, okA := a.(*types.AttributeValueMemberL)
+			y, okB := b.(*types.AttributeValueMemberL)
+			if !okA || !okB {
+				return nil, false, fmt.Errorf("list_append operands must be lists")
+			}
+			joined := append(append([]types.AttributeValue{}, x.Value...), y.Value...)
+			return &types.AttributeValueMemberL{Value: joined}, true, nil
+		}, nil
+	}
+	return p.operand()
+}
+
+// addNumbers returns a + sign*b
+func addNumbers(a, b types.AttributeValue, sign int64) (types.AttributeValue, error) {
+	x, okA := a.(*types.AttributeValueMemberN)
+	y, okB := b.(*types.AttributeValueMemberN)
+	if !okA || !okB {
+		return nil, fmt.Errorf("arithmetic operands must be numbers")
+	}
+	rx, err := parseNumber(x.Value)
+	if err != nil {
+		return nil, err
+	}
+	ry, err := parseNumber(y.Value)
+	if err != nil {
+		return nil, err
+	}
+	ry.Mul(ry, new(big.Rat).SetInt64(sign))
+	return &types.AttributeValueMemberN{Value: formatNumber(rx.Add(rx, ry))}, nil
+}
+
+// applyUpdate applies actions to it in place. Values are read from the
+// item as it was before the update, as DynamoDB does.
+func applyUpdate(it item, actions []updateAction) error {
+	before := cloneItem(it)
+	for _, action := range actions {
+		var value types.AttributeValue
+		if action.value != nil {
+			v, ok, err := action.value(before)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("the value for %s does not exist", action.path)
+			}
+			value = clone(v)
+		}
+
+		switch action.kind {
+		case actionSet:
+			if err := action.path.set(it, value); err != nil {
+				return err
+			}
+		case actionRemove:
+			action.path.remove(it)
+		case actionAdd:
+			current, exists := action.path.resolve(it)
+			if !exists {
+				if err := action.path.set(it, value); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, isN := current.(*types.AttributeValueMemberN); isN {
+				sum, err := addNumbers(current, value, 1)
+				if err != nil {
+					return err
+				}
+				if err := action.path.set(it, sum); err != nil {
+					return err
+				}
+				continue
+			}
+			merged, err := mergeSets(current, value, true)
+			if err != nil {
+				return err
+			}
+			if err := action.path.set(it, merged); err != nil {
+				return err
+			}
+		case actionDelete:
+			current, exists := action.path.resolve(it)
+			if !exists {
+				continue
+			}
+			remaining, err := mergeSets(current, value, false)
+			if err != nil {
+				return err
+			}
+			if remaining == nil {
+				action.path.remove(it)
+			} else if err := action.path.set(it, remaining); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeSets adds b's members to a, or removes them, returning nil for an
+// empty set
+func mergeSets(a, b types.AttributeValue, add bool) (types.AttributeValue, error) {
+	if setType(a) == "" || setType(a) != setType(b) {
+		return nil, fmt.Errorf("ADD and DELETE need a number or sets of the same type")
+	}
+	remove := make(map[string]bool)
+	if !add {
+		members, _ := setMembers(b)
+		for _, m := range members {
+			remove[m] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	keep := func(av types.AttributeValue) bool {
+		members, _ := setMembers(av)
+		m := members[0]
+		if seen[m] || remove[m] {
+			return false
+		}
+		seen[m] = true
+		return true
+	}
+
+	switch x := a.(type) {
+	case *types.AttributeValueMemberSS:
+		var out []string
+		for _, s := range append(append([]string{}, x.Value...), addedStrings(b, add)...) {
+			if keep(&types.AttributeValueMemberSS{Value: []string{s}}) {
+				out = append(out, s)
+			}
+		}
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberSS{Value: out}, nil
+	case *types.AttributeValueMemberNS:
+		var out []string
+		for _, n := range append(append([]string{}, x.Value...), addedStrings(b, add)...) {
+			if keep(&types.AttributeValueMemberNS{Value: []string{n}}) {
+				out = append(out, n)
+			}
+		}
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberNS{Value: out}, nil
+	default:
+		bs := a.(*types.AttributeValueMemberBS)
+		var added [][]byte
+		if add {
+			added = b.(*types.AttributeValueMemberBS).Value
+		}
+		var out [][]byte
+		for _, v := range append(append([][]byte{}, bs.Value...), added...) {
+			if keep(&types.AttributeValueMemberBS{Value: [][]byte{v}}) {
+				out = append(out, v)
+			}
+		}
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberBS{Value: out}, nil
+	}
+}
+
+// addedStrings returns the members of a string or number set being added
+func addedStrings(b types.AttributeValue, add bool) []string {
+	if !add {
+		return nil
+	}
+	switch v := b.(type) {
+	case *types.AttributeValueMemberSS:
+		return v.Value
+	case *types.AttributeValueMemberNS:
+		return v.Value
+	}
+	return nil
+}
+
+// updatedPaths returns the top-level attributes an update touches, for
+// UPDATED_NEW and UPDATED_OLD return values
+func updatedPaths(actions []updateAction) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, a := range actions {
+		if name := a.path[0].name; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,677 @@
+// Package embedded is a single-process stand-in for DynamoDB. It serves
+// the subset of the DynamoDB API the repository uses from memory,
+// optionally saving every table to a JSON file, so the service can run
+// without AWS.
+package embedded
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TableSchema describes a table's primary key and global secondary indexes
+type TableSchema struct {
+	Name     string
+	HashKey  string
+	RangeKey string
+	Indexes  []IndexSchema
+	// TTLAttribute is the attribute DynamoDB expires items by. Like
+	// DynamoDB, which deletes expired items lazily, the store keeps them;
+	// readers already check expiry themselves.
+	TTLAttribute string
+}
+
+// IndexSchema describes a global secondary index. Indexes project every
+// attribute and, like DynamoDB's, only hold items that have their keys.
+type IndexSchema struct {
+	Name     string
+	HashKey  string
+	RangeKey string
+}
+
+type table struct {
+	schema TableSchema
+	items  map[string]item
+}
+
+// Store holds the tables. It is safe for concurrent use.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	tables map[string]*table
+}
+
+// Open creates a store with the given tables, loading any items saved at
+// path. Every write is saved back to path; an empty path keeps the store
+// in memory only.
+func Open(path string, schemas []TableSchema) (*Store, error) {
+	s := &Store{path: path, tables: make(map[string]*table, len(schemas))}
+	for _, schema := range schemas {
+		s.tables[schema.Name] = &table{schema: schema, items: make(map[string]item)}
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+
+	var saved map[string][]map[string]*jsonValue
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to decode store: %w", err)
+	}
+	for name, items := range saved {
+		t, ok := s.tables[name]
+		if !ok {
+			// The table is no longer configured
+			continue
+		}
+		for _, m := range items {
+			it, err := itemFromJSON(m)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s item: %w", name, err)
+			}
+			key, err := t.key(it)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s item: %w", name, err)
+			}
+			t.items[key] = it
+		}
+	}
+	return s, nil
+}
+
+// save writes every table to the store's file. The caller must hold mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	saved := make(map[string][]map[string]*jsonValue, len(s.tables))
+	for name, t := range s.tables {
+		items := make([]map[string]*jsonValue, 0, len(t.items))
+		for _, it := range t.items {
+			items = append(items, itemToJSON(it))
+		}
+		saved[name] = items
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn store
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to save store: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save store: %w", err)
+	}
+	return nil
+}
+
+// table looks a table up by name. The caller must hold mu.
+func (s *Store) table(name *string) (*table, error) {
+	t, ok := s.tables[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{
+			Message: aws.String(fmt.Sprintf("Requested resource not found: Table: %s not found", aws.ToString(name))),
+		}
+	}
+	return t, nil
+}
+
+// key encodes an item's primary key
+func (t *table) key(it item) (string, error) {
+	return compositeKey(it, t.schema.HashKey, t.schema.RangeKey)
+}
+
+// compositeKey encodes the hash and range attributes of an item
+func compositeKey(it item, hashKey, rangeKey string) (string, error) {
+	hash, ok := it[hashKey]
+	if !ok {
+		return "", fmt.Errorf("missing the key attribute %s", hashKey)
+	}
+	key, err := keyString(hash)
+	if err != nil {
+		return "", err
+	}
+	if rangeKey == "" {
+		return key, nil
+	}
+	rng, ok := it[rangeKey]
+	if !ok {
+		return "", fmt.Errorf("missing the key attribute %s", rangeKey)
+	}
+	r, err := keyString(rng)
+	if err != nil {
+		return "", err
+	}
+	return key + "\x00" + r, nil
+}
+
+// keyAttributes returns the primary key attributes of an item
+func (t *table) keyAttributes(it item) item {
+	key := item{t.schema.HashKey: clone(it[t.schema.HashKey])}
+	if t.schema.RangeKey != "" {
+		key[t.schema.RangeKey] = clone(it[t.schema.RangeKey])
+	}
+	return key
+}
+
+// conditionFailed is the error DynamoDB returns when a condition is false
+func conditionFailed() error {
+	return &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+}
+
+// check evaluates a condition expression against an existing item, or an
+// empty one when there is none
+func check(expr *string, names map[string]string, values map[string]types.AttributeValue, existing item) error {
+	cond, err := parseCondition(expr, names, values)
+	if err != nil {
+		return fmt.Errorf("invalid ConditionExpression: %w", err)
+	}
+	if existing == nil {
+		existing = item{}
+	}
+	ok, err := cond(existing)
+	if err != nil {
+		return fmt.Errorf("invalid ConditionExpression: %w", err)
+	}
+	if !ok {
+		return conditionFailed()
+	}
+	return nil
+}
+
+// GetItem returns the item with the given key
+func (s *Store) GetItem(ctx context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.key(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.GetItemOutput{Item: cloneItem(t.items[key])}, nil
+}
+
+// PutItem creates or replaces an item
+func (s *Store) PutItem(ctx context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out, err := s.putItem(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// putItem applies a put without saving. The caller must hold mu.
+func (s *Store) putItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	t, err := s.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.key(in.Item)
+	if err != nil {
+		return nil, err
+	}
+	existing := t.items[key]
+	if err := check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, existing); err != nil {
+		return nil, err
+	}
+
+	t.items[key] = cloneItem(in.Item)
+
+	out := &dynamodb.PutItemOutput{}
+	if in.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = cloneItem(existing)
+	}
+	return out, nil
+}
+
+// UpdateItem edits an item, creating it when it doesn't exist
+func (s *Store) UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out, err := s.updateItem(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// updateItem applies an update without saving. The caller must hold mu.
+func (s *Store) updateItem(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	t, err := s.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.key(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	existing := t.items[key]
+	if err := check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, existing); err != nil {
+		return nil, err
+	}
+
+	actions, err := parseUpdate(in.UpdateExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UpdateExpression: %w", err)
+	}
+
+	updated := cloneItem(existing)
+	if updated == nil {
+		updated = t.keyAttributes(in.Key)
+	}
+	if err := applyUpdate(updated, actions); err != nil {
+		return nil, fmt.Errorf("invalid UpdateExpression: %w", err)
+	}
+	if newKey, err := t.key(updated); err != nil || newKey != key {
+		return nil, fmt.Errorf("invalid UpdateExpression: cannot update attribute that is part of the key")
+	}
+
+	t.items[key] = updated
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch in.ReturnValues {
+	case types.ReturnValueAllNew:
+		out.Attributes = cloneItem(updated)
+	case types.ReturnValueAllOld:
+		out.Attributes = cloneItem(existing)
+	case types.ReturnValueUpdatedNew, types.ReturnValueUpdatedOld:
+		source := updated
+		if in.ReturnValues == types.ReturnValueUpdatedOld {
+			source = existing
+		}
+		out.Attributes = item{}
+		for _, name := range updatedPaths(actions) {
+			if av, ok := source[name]; ok {
+				out.Attributes[name] = clone(av)
+			}
+		}
+	}
+	return out, nil
+}
+
+// DeleteItem removes an item
+func (s *Store) DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out, err := s.deleteItem(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// deleteItem applies a delete without saving. The caller must hold mu.
+func (s *Store) deleteItem(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	t, err := s.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.key(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	existing := t.items[key]
+	if err := check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, existing); err != nil {
+		return nil, err
+	}
+
+	delete(t.items, key)
+
+	out := &dynamodb.DeleteItemOutput{}
+	if in.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = cloneItem(existing)
+	}
+	return out, nil
+}
+
+// queryKey orders the items of a query: by the sort key, then by the
+// table's primary key
+type queryKey struct {
+	sort    types.AttributeValue
+	primary string
+}
+
+func (a queryKey) less(b queryKey) bool {
+	if a.sort != nil && b.sort != nil {
+		if cmp, ok := compare(a.sort, b.sort); ok && cmp != 0 {
+			return cmp < 0
+		}
+	}
+	return a.primary < b.primary
+}
+
+// Query returns the items of a table or index matching a key condition, in
+// sort key order
+func (s *Store) Query(ctx context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	hashKey, rangeKey := t.schema.HashKey, t.schema.RangeKey
+	if in.IndexName != nil {
+		found := false
+		for _, index := range t.schema.Indexes {
+			if index.Name == *in.IndexName {
+				hashKey, rangeKey, found = index.HashKey, index.RangeKey, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the table %s has no index %s", t.schema.Name, *in.IndexName)
+		}
+	}
+
+	keyCond, err := parseCondition(in.KeyConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KeyConditionExpression: %w", err)
+	}
+	filter, err := parseCondition(in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FilterExpression: %w", err)
+	}
+
+	type match struct {
+		key  queryKey
+		item item
+	}
+	var matches []match
+	for primary, it := range t.items {
+		// Indexes are sparse: items without the index keys aren't in them
+		if _, ok := it[hashKey]; !ok {
+			continue
+		}
+		if _, ok := it[rangeKey]; rangeKey != "" && !ok {
+			continue
+		}
+		ok, err := keyCond(it)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KeyConditionExpression: %w", err)
+		}
+		if ok {
+			matches = append(matches, match{key: queryKey{sort: it[rangeKey], primary: primary}, item: it})
+		}
+	}
+
+	forward := in.ScanIndexForward == nil || *in.ScanIndexForward
+	sort.Slice(matches, func(i, j int) bool {
+		if forward {
+			return matches[i].key.less(matches[j].key)
+		}
+		return matches[j].key.less(matches[i].key)
+	})
+
+	if in.ExclusiveStartKey != nil {
+		primary, err := t.key(in.ExclusiveStartKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ExclusiveStartKey: %w", err)
+		}
+		start := queryKey{sort: in.ExclusiveStartKey[rangeKey], primary: primary}
+		// Resume after the start key even if its item has since gone
+		i := sort.Search(len(matches), func(i int) bool {
+			if forward {
+				return start.less(matches[i].key)
+			}
+			return matches[i].key.less(start)
+		})
+		matches = matches[i:]
+	}
+
+	scanned := matches
+	if in.Limit != nil && int(*in.Limit) < len(matches) {
+		scanned = matches[:*in.Limit]
+	}
+
+	out := &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{}}
+	for _, m := range scanned {
+		ok, err := filter(m.item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FilterExpression: %w", err)
+		}
+		if ok {
+			out.Items = append(out.Items, cloneItem(m.item))
+		}
+	}
+	out.Count = int32(len(out.Items))
+	out.ScannedCount = int32(len(scanned))
+
+	if len(scanned) < len(matches) && len(scanned) > 0 {
+		last := scanned[len(scanned)-1].item
+		lastKey := t.keyAttributes(last)
+		lastKey[hashKey] = clone(last[hashKey])
+		if rangeKey != "" {
+			lastKey[rangeKey] = clone(last[rangeKey])
+		}
+		out.LastEvaluatedKey = lastKey
+	}
+	return out, nil
+}
+
+// BatchGetItem returns the items with the given keys across tables
+func (s *Store) BatchGetItem(ctx context.Context, in *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]types.AttributeValue)}
+	for name, request := range in.RequestItems {
+		t, err := s.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+		items := []map[string]types.AttributeValue{}
+		for _, k := range request.Keys {
+			key, err := t.key(k)
+			if err != nil {
+				return nil, err
+			}
+			if it, ok := t.items[key]; ok {
+				items = append(items, cloneItem(it))
+			}
+		}
+		out.Responses[name] = items
+	}
+	return out, nil
+}
+
+// BatchWriteItem puts and deletes items across tables
+func (s *Store) BatchWriteItem(ctx context.Context, in *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, requests := range in.RequestItems {
+		t, err := s.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+		for _, request := range requests {
+			switch {
+			case request.PutRequest != nil:
+				key, err := t.key(request.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				t.items[key] = cloneItem(request.PutRequest.Item)
+			case request.DeleteRequest != nil:
+				key, err := t.key(request.DeleteRequest.Key)
+				if err != nil {
+					return nil, err
+				}
+				delete(t.items, key)
+			}
+		}
+	}
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+// TransactWriteItems applies a group of writes all or nothing. If any
+// condition fails none are applied, and the error gives the reason for
+// each write, as DynamoDB's does.
+func (s *Store) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Remember what each write replaces, to roll back
+	type previous struct {
+		t    *table
+		key  string
+		item item
+	}
+	var undo []previous
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undo[i].item == nil {
+				delete(undo[i].t.items, undo[i].key)
+			} else {
+				undo[i].t.items[undo[i].key] = undo[i].item
+			}
+		}
+	}
+
+	reasons := make([]types.CancellationReason, len(in.TransactItems))
+	for i := range reasons {
+		reasons[i].Code = aws.String("None")
+	}
+	for i, w := range in.TransactItems {
+		var tableName *string
+		var key map[string]types.AttributeValue
+		switch {
+		case w.Put != nil:
+			tableName, key = w.Put.TableName, w.Put.Item
+		case w.Update != nil:
+			tableName, key = w.Update.TableName, w.Update.Key
+		case w.Delete != nil:
+			tableName, key = w.Delete.TableName, w.Delete.Key
+		case w.ConditionCheck != nil:
+			tableName, key = w.ConditionCheck.TableName, w.ConditionCheck.Key
+		default:
+			rollback()
+			return nil, fmt.Errorf("transact item %d has no operation", i)
+		}
+		t, err := s.table(tableName)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		k, err := t.key(key)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		undo = append(undo, previous{t: t, key: k, item: t.items[k]})
+
+		switch {
+		case w.Put != nil:
+			_, err = s.putItem(&dynamodb.PutItemInput{
+				TableName:                 w.Put.TableName,
+				Item:                      w.Put.Item,
+				ConditionExpression:       w.Put.ConditionExpression,
+				ExpressionAttributeNames:  w.Put.ExpressionAttributeNames,
+				ExpressionAttributeValues: w.Put.ExpressionAttributeValues,
+			})
+		case w.Update != nil:
+			_, err = s.updateItem(&dynamodb.UpdateItemInput{
+				TableName:                 w.Update.TableName,
+				Key:                       w.Update.Key,
+				UpdateExpression:          w.Update.UpdateExpression,
+				ConditionExpression:       w.Update.ConditionExpression,
+				ExpressionAttributeNames:  w.Update.ExpressionAttributeNames,
+				ExpressionAttributeValues: w.Update.ExpressionAttributeValues,
+			})
+		case w.Delete != nil:
+			_, err = s.deleteItem(&dynamodb.DeleteItemInput{
+				TableName:                 w.Delete.TableName,
+				Key:                       w.Delete.Key,
+				ConditionExpression:       w.Delete.ConditionExpression,
+				ExpressionAttributeNames:  w.Delete.ExpressionAttributeNames,
+				ExpressionAttributeValues: w.Delete.ExpressionAttributeValues,
+			})
+		default:
+			err = check(w.ConditionCheck.ConditionExpression, w.ConditionCheck.ExpressionAttributeNames,
+				w.ConditionCheck.ExpressionAttributeValues, t.items[k])
+		}
+		if err != nil {
+			rollback()
+			var condErr *types.ConditionalCheckFailedException
+			if !errors.As(err, &condErr) {
+				return nil, err
+			}
+			reasons[i] = types.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: condErr.Message,
+			}
+			return nil, &types.TransactionCanceledException{
+				Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons"),
+				CancellationReasons: reasons,
+			}
+		}
+	}
+
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// DescribeTable reports a table's name, status and size
+func (s *Store) DescribeTable(ctx context.Context, in *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+		TableName:   aws.String(t.schema.Name),
+		TableStatus: types.TableStatusActive,
+		ItemCount:   aws.Int64(int64(len(t.items))),
+	}}, nil
+}
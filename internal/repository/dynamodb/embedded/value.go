@@ -0,0 +1,352 @@
+package embedded
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// item is a stored record, keyed by attribute name
+type item = map[string]types.AttributeValue
+
+// parseNumber parses a DynamoDB number exactly
+func parseNumber(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return r, nil
+}
+
+// formatNumber formats a number the way DynamoDB returns it: integers
+// without a fraction and decimals without trailing zeros
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := strings.TrimRight(r.FloatString(20), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// keyString encodes a key attribute so equal keys encode the same, such as
+// the numbers "1" and "1.0"
+func keyString(av types.AttributeValue) (string, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S" + v.Value, nil
+	case *types.AttributeValueMemberN:
+		r, err := parseNumber(v.Value)
+		if err != nil {
+			return "", err
+		}
+		return "N" + r.RatString(), nil
+	case *types.AttributeValueMemberB:
+		return "B" + string(v.Value), nil
+	default:
+		return "", fmt.Errorf("key attributes must be strings, numbers or binary, got %T", av)
+	}
+}
+
+// compare orders two scalar values of the same type. ok is false when they
+// can't be ordered, such as a string and a number.
+func compare(a, b types.AttributeValue) (cmp int, ok bool) {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		if y, isS := b.(*types.AttributeValueMemberS); isS {
+			return strings.Compare(x.Value, y.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if y, isN := b.(*types.AttributeValueMemberN); isN {
+			rx, err := parseNumber(x.Value)
+			if err != nil {
+				return 0, false
+			}
+			ry, err := parseNumber(y.Value)
+			if err != nil {
+				return 0, false
+			}
+			return rx.Cmp(ry), true
+		}
+	case *types.AttributeValueMemberB:
+		if y, isB := b.(*types.AttributeValueMemberB); isB {
+			return bytes.Compare(x.Value, y.Value), true
+		}
+	}
+	return 0, false
+}
+
+// equal reports whether two values are the same, comparing sets without
+// regard to order
+func equal(a, b types.AttributeValue) bool {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS, *types.AttributeValueMemberN, *types.AttributeValueMemberB:
+		cmp, ok := compare(a, b)
+		return ok && cmp == 0
+	case *types.AttributeValueMemberBOOL:
+		y, ok := b.(*types.AttributeValueMemberBOOL)
+		return ok && x.Value == y.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := b.(*types.AttributeValueMemberNULL)
+		return ok
+	case *types.AttributeValueMemberM:
+		y, ok := b.(*types.AttributeValueMemberM)
+		if !ok || len(x.Value) != len(y.Value) {
+			return false
+		}
+		for k, v := range x.Value {
+			w, ok := y.Value[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberL:
+		y, ok := b.(*types.AttributeValueMemberL)
+		if !ok || len(x.Value) != len(y.Value) {
+			return false
+		}
+		for i := range x.Value {
+			if !equal(x.Value[i], y.Value[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		xs, xok := setMembers(a)
+		ys, yok := setMembers(b)
+		if !xok || !yok || setType(a) != setType(b) || len(xs) != len(ys) {
+			return false
+		}
+		sort.Strings(xs)
+		sort.Strings(ys)
+		for i := range xs {
+			if xs[i] != ys[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// setType names the type of a set value, or "" for other values
+func setType(av types.AttributeValue) string {
+	switch av.(type) {
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	}
+	return ""
+}
+
+// setMembers returns the members of a set as key strings
+func setMembers(av types.AttributeValue) ([]string, bool) {
+	var members []string
+	switch v := av.(type) {
+	case *types.AttributeValueMemberSS:
+		for _, s := range v.Value {
+			members = append(members, "S"+s)
+		}
+	case *types.AttributeValueMemberNS:
+		for _, n := range v.Value {
+			key, err := keyString(&types.AttributeValueMemberN{Value: n})
+			if err != nil {
+				return nil, false
+			}
+			members = append(members, key)
+		}
+	case *types.AttributeValueMemberBS:
+		for _, b := range v.Value {
+			members = append(members, "B"+string(b))
+		}
+	default:
+		return nil, false
+	}
+	return members, true
+}
+
+// typeName is the DynamoDB type descriptor of a value, such as "S" or "M"
+func typeName(av types.AttributeValue) string {
+	switch av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberM:
+		return "M"
+	case *types.AttributeValueMemberL:
+		return "L"
+	}
+	return setType(av)
+}
+
+// clone deep-copies a value, so stored items never share state with the
+// items callers are given
+func clone(av types.AttributeValue) types.AttributeValue {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte(nil), v.Value...)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: cloneItem(v.Value)}
+	case *types.AttributeValueMemberL:
+		l := make([]types.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			l[i] = clone(e)
+		}
+		return &types.AttributeValueMemberL{Value: l}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberBS:
+		bs := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			bs[i] = append([]byte(nil), b...)
+		}
+		return &types.AttributeValueMemberBS{Value: bs}
+	}
+	return av
+}
+
+// cloneItem deep-copies an item
+func cloneItem(it item) item {
+	if it == nil {
+		return nil
+	}
+	out := make(item, len(it))
+	for k, v := range it {
+		out[k] = clone(v)
+	}
+	return out
+}
+
+// jsonValue is a value in DynamoDB's JSON format, as the store is saved
+type jsonValue struct {
+	S    *string                `json:"S,omitempty"`
+	N    *string                `json:"N,omitempty"`
+	B    *[]byte                `json:"B,omitempty"`
+	BOOL *bool                  `json:"BOOL,omitempty"`
+	NULL *bool                  `json:"NULL,omitempty"`
+	M    *map[string]*jsonValue `json:"M,omitempty"`
+	L    *[]*jsonValue          `json:"L,omitempty"`
+	SS   []string               `json:"SS,omitempty"`
+	NS   []string               `json:"NS,omitempty"`
+	BS   [][]byte               `json:"BS,omitempty"`
+}
+
+// toJSON converts a value to its JSON form
+func toJSON(av types.AttributeValue) *jsonValue {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return &jsonValue{S: &v.Value}
+	case *types.AttributeValueMemberN:
+		return &jsonValue{N: &v.Value}
+	case *types.AttributeValueMemberB:
+		return &jsonValue{B: &v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return &jsonValue{BOOL: &v.Value}
+	case *types.AttributeValueMemberNULL:
+		null := true
+		return &jsonValue{NULL: &null}
+	case *types.AttributeValueMemberM:
+		m := itemToJSON(v.Value)
+		return &jsonValue{M: &m}
+	case *types.AttributeValueMemberL:
+		l := make([]*jsonValue, len(v.Value))
+		for i, e := range v.Value {
+			l[i] = toJSON(e)
+		}
+		return &jsonValue{L: &l}
+	case *types.AttributeValueMemberSS:
+		return &jsonValue{SS: v.Value}
+	case *types.AttributeValueMemberNS:
+		return &jsonValue{NS: v.Value}
+	case *types.AttributeValueMemberBS:
+		return &jsonValue{BS: v.Value}
+	}
+	return &jsonValue{}
+}
+
+// fromJSON converts a value back from its JSON form
+func fromJSON(v *jsonValue) (types.AttributeValue, error) {
+	switch {
+	case v == nil:
+		return nil, fmt.Errorf("missing value")
+	case v.S != nil:
+		return &types.AttributeValueMemberS{Value: *v.S}, nil
+	case v.N != nil:
+		return &types.AttributeValueMemberN{Value: *v.N}, nil
+	case v.B != nil:
+		return &types.AttributeValueMemberB{Value: *v.B}, nil
+	case v.BOOL != nil:
+		return &types.AttributeValueMemberBOOL{Value: *v.BOOL}, nil
+	case v.NULL != nil:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case v.M != nil:
+		m, err := itemFromJSON(*v.M)
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	case v.L != nil:
+		l := make([]types.AttributeValue, len(*v.L))
+		for i, e := range *v.L {
+			av, err := fromJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: l}, nil
+	case v.SS != nil:
+		return &types.AttributeValueMemberSS{Value: v.SS}, nil
+	case v.NS != nil:
+		return &types.AttributeValueMemberNS{Value: v.NS}, nil
+	case v.BS != nil:
+		return &types.AttributeValueMemberBS{Value: v.BS}, nil
+	}
+	return nil, fmt.Errorf("value has no type")
+}
+
+// itemToJSON converts an item to its JSON form
+func itemToJSON(it item) map[string]*jsonValue {
+	out := make(map[string]*jsonValue, len(it))
+	for k, v := range it {
+		out[k] = toJSON(v)
+	}
+	return out
+}
+
+// itemFromJSON converts an item back from its JSON form
+func itemFromJSON(m map[string]*jsonValue) (item, error) {
+	out := make(item, len(m))
+	for k, v := range m {
+		av, err := fromJSON(v)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", k, err)
+		}
+		out[k] = av
+	}
+	return out, nil
+}
@@ -0,0 +1,55 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/streaming-service/internal/domain"
+)
+
+// SetMediaExport records a media item's export. A pending export replaces
+// the one before it; any other status only updates the export with the
+// same ID, so a late finish can't overwrite a newer request.
+func (c *Client) SetMediaExport(ctx context.Context, id string, export *domain.MediaExport) error {
+	update := expression.Set(
+		expression.Name("export"),
+		expression.Value(export),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	cond := ownedCondition(ctx)
+	if export.Status != domain.ExportStatusPending {
+		cond = cond.And(expression.Name("export.id").Equal(expression.Value(export.ID)))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to set export: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// ClaimIdempotencyKey stores a pending record unless its key is already
+// held by an unexpired record, in which case that record is returned with
+// domain.ErrIdempotencyKeyUsed. Expired records are only removed by the
+// table TTL eventually, so they are overwritten here.
+func (c *Client) ClaimIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	av, err := marshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.idempotencyTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id) OR expires_at < :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+	if err == nil {
+		return nil, nil
+	}
+
+	var condErr *types.ConditionalCheckFailedException
+	if !errors.As(err, &condErr) {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.idempotencyTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: record.Key},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+	if result.Item == nil {
+		// Released between the two calls; the caller may retry
+		return nil, domain.ErrIdempotencyKeyUsed
+	}
+
+	var existing domain.IdempotencyRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &existing); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+	}
+
+	return &existing, domain.ErrIdempotencyKeyUsed
+}
+
+// CompleteIdempotencyKey stores the response of a claimed key
+func (c *Client) CompleteIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) error {
+	av, err := marshalMap(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.idempotencyTable),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey removes a claimed key so the request can be retried
+func (c *Client) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.idempotencyTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// The jobs table records each processing job as the API queues it and
+// the worker runs it. Records are upserted on every change of state, so a
+// job queued without one, such as before the table existed, still gets
+// one once a worker starts it. Finished jobs expire after the retention.
+
+// SaveJobState records a job's status and attempts in ctx's tenant,
+// creating its record if there is none. The creation time is kept from
+// the first record; the other times, the error and the expiry are only
+// changed when set.
+func (c *Client) SaveJobState(ctx context.Context, job *domain.Job) error {
+	update := expression.Set(expression.Name("type"), expression.Value(job.Type)).
+		Set(expression.Name("media_id"), expression.Value(job.MediaID)).
+		Set(expression.Name("tenant_id"), expression.Value(tenant.FromContext(ctx))).
+		Set(expression.Name("status"), expression.Value(job.Status)).
+		Set(expression.Name("attempts"), expression.Value(job.Attempts)).
+		Set(expression.Name("updated_at"), expression.Value(job.UpdatedAt)).
+		Set(expression.Name("created_at"), expression.IfNotExists(expression.Name("created_at"), expression.Value(formatTime(job.CreatedAt))))
+	if job.Error != "" {
+		update = update.Set(expression.Name("error"), expression.Value(job.Error))
+	}
+	if job.RunAt != nil {
+		update = update.Set(expression.Name("run_at"), expression.Value(job.RunAt))
+	}
+	if job.StartedAt != nil {
+		update = update.Set(expression.Name("started_at"), expression.Value(job.StartedAt))
+	}
+	if job.FinishedAt != nil {
+		update = update.Set(expression.Name("finished_at"), expression.Value(job.FinishedAt))
+	}
+	if job.ExpiresAt != 0 {
+		update = update.Set(expression.Name(ttlAttribute), expression.Value(job.ExpiresAt))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.jobsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: job.ID},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+
+	return nil
+}
+
+// GetJob retrieves a job record by ID
+func (c *Client) GetJob(ctx context.Context, id string) (*domain.Job, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.jobsTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, domain.ErrJobNotFound
+	}
+
+	var job domain.Job
+	if err := attributevalue.UnmarshalMap(result.Item, &job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
+	}
+
+	if !owns(ctx, job.TenantID) {
+		return nil, domain.ErrJobNotFound
+	}
+
+	return &job, nil
+}
+
+// ListJobsByMedia retrieves a page of a media item's jobs, newest first,
+// returning the cursor of the next page
+func (c *Client) ListJobsByMedia(ctx context.Context, mediaID string, limit int32, cursor string) ([]*domain.Job, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("media_id").Equal(expression.Value(mediaID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.jobsTable),
+		IndexName:                 aws.String("media_id-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query jobs: %w", err)
+	}
+
+	jobs := make([]*domain.Job, 0, len(result.Items))
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &jobs); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal jobs: %w", err)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return jobs, next, nil
+}
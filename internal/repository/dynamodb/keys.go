@@ -0,0 +1,106 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// contentKeyItem is a KMS-encrypted content key stored per media
+type contentKeyItem struct {
+	MediaID      string    `dynamodbav:"media_id"`
+	KeyID        string    `dynamodbav:"key_id"`
+	EncryptedKey []byte    `dynamodbav:"encrypted_key"`
+	CreatedAt    time.Time `dynamodbav:"created_at"`
+}
+
+// PutContentKey stores an encrypted content key
+func (c *Client) PutContentKey(ctx context.Context, mediaID, keyID string, encryptedKey []byte) error {
+	av, err := marshalMap(contentKeyItem{
+		MediaID:      mediaID,
+		KeyID:        keyID,
+		EncryptedKey: encryptedKey,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal content key: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.keysTable),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store content key: %w", err)
+	}
+
+	return nil
+}
+
+// GetContentKey returns the encrypted content key for a media item
+func (c *Client) GetContentKey(ctx context.Context, mediaID, keyID string) ([]byte, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.keysTable),
+		Key: map[string]types.AttributeValue{
+			"media_id": &types.AttributeValueMemberS{Value: mediaID},
+			"key_id":   &types.AttributeValueMemberS{Value: keyID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content key: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, domain.ErrKeyNotFound
+	}
+
+	var item contentKeyItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal content key: %w", err)
+	}
+
+	return item.EncryptedKey, nil
+}
+
+// SetMediaEncryption records the encryption applied to a media's outputs
+func (c *Client) SetMediaEncryption(ctx context.Context, id string, info *domain.EncryptionInfo) error {
+	update := expression.Set(
+		expression.Name("encryption"),
+		expression.Value(info),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update encryption: %w", err)
+	}
+
+	return nil
+}
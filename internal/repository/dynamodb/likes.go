@@ -0,0 +1,162 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Likes live in the analytics table as two items: a marker keyed by media
+// ID, to check and remove a like, and a listing entry keyed by the time
+// of the like, to list a user's likes newest first
+const (
+	likePrefix     = "like#"
+	likeListPrefix = "likes#"
+)
+
+// PutLike records a user's like of a media item and counts it on the
+// media record. It returns false if the user already liked it.
+func (c *Client) PutLike(ctx context.Context, userID, mediaID string, at time.Time) (bool, error) {
+	likedAt := formatTime(at)
+
+	_, err := c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.analyticsTable),
+		Item: map[string]types.AttributeValue{
+			"pk":       &types.AttributeValueMemberS{Value: likePrefix + userID},
+			"sk":       &types.AttributeValueMemberS{Value: mediaID},
+			"liked_at": &types.AttributeValueMemberS{Value: likedAt},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to put like: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.analyticsTable),
+		Item: map[string]types.AttributeValue{
+			"pk":       &types.AttributeValueMemberS{Value: likeListPrefix + userID},
+			"sk":       &types.AttributeValueMemberS{Value: likedAt + "#" + mediaID},
+			"media_id": &types.AttributeValueMemberS{Value: mediaID},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list like: %w", err)
+	}
+
+	if err := c.addCounter(ctx, c.tableName, map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: mediaID},
+	}, "like_count", 1); err != nil {
+		return false, fmt.Errorf("failed to increment media likes: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteLike removes a user's like of a media item and its count on the
+// media record. It returns false if the user hadn't liked it.
+func (c *Client) DeleteLike(ctx context.Context, userID, mediaID string) (bool, error) {
+	result, err := c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.analyticsTable),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: likePrefix + userID},
+			"sk": &types.AttributeValueMemberS{Value: mediaID},
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete like: %w", err)
+	}
+	likedAt, ok := result.Attributes["liked_at"].(*types.AttributeValueMemberS)
+	if !ok {
+		return false, nil
+	}
+
+	_, err = c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.analyticsTable),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: likeListPrefix + userID},
+			"sk": &types.AttributeValueMemberS{Value: likedAt.Value + "#" + mediaID},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to unlist like: %w", err)
+	}
+
+	if err := c.addCounter(ctx, c.tableName, map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: mediaID},
+	}, "like_count", -1); err != nil {
+		return false, fmt.Errorf("failed to decrement media likes: %w", err)
+	}
+
+	return true, nil
+}
+
+// HasLike reports whether a user liked a media item
+func (c *Client) HasLike(ctx context.Context, userID, mediaID string) (bool, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.analyticsTable),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: likePrefix + userID},
+			"sk": &types.AttributeValueMemberS{Value: mediaID},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get like: %w", err)
+	}
+
+	return result.Item != nil, nil
+}
+
+// ListLikedMediaIDs retrieves a page of the media IDs a user liked, most
+// recently liked first. Pass the returned cursor back to fetch the next
+// page; it is empty after the last page.
+func (c *Client) ListLikedMediaIDs(ctx context.Context, userID string, limit int32, cursor string) ([]string, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("pk").Equal(expression.Value(likeListPrefix + userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.analyticsTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query likes: %w", err)
+	}
+
+	ids := make([]string, 0, len(result.Items))
+	for _, item := range result.Items {
+		if id, ok := item["media_id"].(*types.AttributeValueMemberS); ok {
+			ids = append(ids, id.Value)
+		}
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return ids, next, nil
+}
@@ -0,0 +1,327 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// CreateLiveStream creates a new live stream record in ctx's tenant
+func (c *Client) CreateLiveStream(ctx context.Context, stream *domain.LiveStream) error {
+	stream.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(stream)
+	if err != nil {
+		return fmt.Errorf("failed to marshal live stream: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.liveTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create live stream: %w", err)
+	}
+
+	return nil
+}
+
+// GetLiveStream retrieves a live stream by ID
+func (c *Client) GetLiveStream(ctx context.Context, id string) (*domain.LiveStream, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.liveTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live stream: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, domain.ErrStreamNotFound
+	}
+
+	var stream domain.LiveStream
+	if err := attributevalue.UnmarshalMap(result.Item, &stream); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal live stream: %w", err)
+	}
+
+	if !owns(ctx, stream.TenantID) {
+		return nil, domain.ErrStreamNotFound
+	}
+
+	return &stream, nil
+}
+
+// GetLiveStreamByKey retrieves a live stream by its stream key. It looks
+// across tenants, since the key is what establishes the broadcaster's
+// tenant.
+func (c *Client) GetLiveStreamByKey(ctx context.Context, streamKey string) (*domain.LiveStream, error) {
+	keyExpr := expression.Key("stream_key").Equal(expression.Value(streamKey))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.liveTable),
+		IndexName:                 aws.String("stream_key-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query live stream: %w", err)
+	}
+
+	if len(result.Items) == 0 {
+		return nil, domain.ErrStreamNotFound
+	}
+
+	var stream domain.LiveStream
+	if err := attributevalue.UnmarshalMap(result.Items[0], &stream); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal live stream: %w", err)
+	}
+
+	return &stream, nil
+}
+
+// ListLiveStreamsByUser retrieves all live streams owned by a user
+func (c *Client) ListLiveStreamsByUser(ctx context.Context, userID string, limit int32) ([]*domain.LiveStream, error) {
+	keyExpr := expression.Key("user_id").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.liveTable),
+		IndexName:                 aws.String("user_id-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query live streams: %w", err)
+	}
+
+	var streams []*domain.LiveStream
+	for _, item := range result.Items {
+		var stream domain.LiveStream
+		if err := attributevalue.UnmarshalMap(item, &stream); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal live stream: %w", err)
+		}
+		streams = append(streams, &stream)
+	}
+
+	return streams, nil
+}
+
+// StartLiveStream marks a stream live for the given ingest protocol. It
+// fails with ErrStreamAlreadyLive if another session is publishing.
+func (c *Client) StartLiveStream(ctx context.Context, id string, protocol domain.IngestProtocol) error {
+	now := time.Now()
+	update := expression.Set(
+		expression.Name("status"),
+		expression.Value(domain.LiveStreamStatusLive),
+	).Set(
+		expression.Name("ingest_protocol"),
+		expression.Value(protocol),
+	).Set(
+		expression.Name("started_at"),
+		expression.Value(now),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(now),
+	).Remove(
+		// Health and restream status are reported afresh for each session
+		expression.Name("health"),
+	).Remove(
+		expression.Name("restreams"),
+	)
+
+	cond := expression.And(
+		ownedCondition(ctx),
+		expression.Name("status").NotEqual(expression.Value(domain.LiveStreamStatusLive)),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.liveTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+		UpdateExpression:          expr.Update(),
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return domain.ErrStreamAlreadyLive
+		}
+		return fmt.Errorf("failed to start live stream: %w", err)
+	}
+
+	return nil
+}
+
+// EndLiveStream marks a stream as ended
+func (c *Client) EndLiveStream(ctx context.Context, id string) error {
+	now := time.Now()
+	update := expression.Set(
+		expression.Name("status"),
+		expression.Value(domain.LiveStreamStatusEnded),
+	).Set(
+		expression.Name("ended_at"),
+		expression.Value(now),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(now),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.liveTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrStreamNotFound
+		}
+		return fmt.Errorf("failed to end live stream: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateLiveStreamHealth stores the latest ingest health snapshot
+func (c *Client) UpdateLiveStreamHealth(ctx context.Context, id string, health *domain.LiveHealth) error {
+	update := expression.Set(
+		expression.Name("health"),
+		expression.Value(health),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.liveTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrStreamNotFound
+		}
+		return fmt.Errorf("failed to update live stream health: %w", err)
+	}
+
+	return nil
+}
+
+// IncrementLiveStreamDisconnects adds to the stream's disconnect counter
+func (c *Client) IncrementLiveStreamDisconnects(ctx context.Context, id string, delta int64) error {
+	if err := c.addCounter(ctx, c.liveTable, map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: id},
+	}, "disconnects", delta); err != nil {
+		return fmt.Errorf("failed to increment disconnects: %w", err)
+	}
+	return nil
+}
+
+// SetLiveStreamDestinations replaces a stream's restream destinations
+func (c *Client) SetLiveStreamDestinations(ctx context.Context, id string, destinations []domain.RestreamDestination) error {
+	update := expression.Set(
+		expression.Name("destinations"),
+		expression.Value(destinations),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+	if len(destinations) == 0 {
+		update = expression.Remove(expression.Name("destinations")).Set(
+			expression.Name("updated_at"),
+			expression.Value(time.Now()),
+		)
+	}
+
+	return c.updateLiveStream(ctx, id, update, "restream destinations")
+}
+
+// SetLiveStreamRestreams stores the push status of a stream's
+// destinations, replacing the previous report
+func (c *Client) SetLiveStreamRestreams(ctx context.Context, id string, restreams map[string]*domain.RestreamStatus) error {
+	update := expression.Set(
+		expression.Name("restreams"),
+		expression.Value(restreams),
+	)
+	if len(restreams) == 0 {
+		update = expression.Remove(expression.Name("restreams"))
+	}
+
+	return c.updateLiveStream(ctx, id, update, "restream status")
+}
+
+// updateLiveStream applies an update of what to a stream in ctx's tenant
+func (c *Client) updateLiveStream(ctx context.Context, id string, update expression.UpdateBuilder, what string) error {
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.liveTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrStreamNotFound
+		}
+		return fmt.Errorf("failed to update %s: %w", what, err)
+	}
+	return nil
+}
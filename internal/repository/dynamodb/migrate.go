@@ -0,0 +1,376 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/awsconfig"
+	"github.com/streaming-service/internal/repository/dynamodb/embedded"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// migratePollInterval is how often Migrate checks on tables and indexes
+// being created
+const migratePollInterval = 5 * time.Second
+
+// Migrator creates the tables and indexes the service needs, and checks
+// existing ones match
+type Migrator struct {
+	client  *dynamodb.Client
+	schemas []embedded.TableSchema
+	log     *logger.Logger
+}
+
+// NewMigrator creates a new table migrator
+func NewMigrator(ctx context.Context, cfg appconfig.AWSConfig, log *logger.Logger) (*Migrator, error) {
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		client:  dynamodb.NewFromConfig(awsCfg),
+		schemas: schemas(cfg),
+		log:     log,
+	}, nil
+}
+
+// Migrate creates missing tables and global secondary indexes and enables
+// time to live where it is off, waiting for each to become active. A table
+// whose primary key differs from the schema is an error, as it can only be
+// fixed by recreating it. With dryRun set it only reports what it would
+// change.
+func (m *Migrator) Migrate(ctx context.Context, dryRun bool) error {
+	for _, schema := range m.schemas {
+		if err := m.migrateTable(ctx, schema, dryRun); err != nil {
+			return fmt.Errorf("table %s: %w", schema.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) migrateTable(ctx context.Context, schema embedded.TableSchema, dryRun bool) error {
+	result, err := m.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(schema.Name),
+	})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		if dryRun {
+			m.log.Info("would create table", "table", schema.Name, "indexes", len(schema.Indexes))
+		} else if err := m.createTable(ctx, schema); err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("failed to describe table: %w", err)
+	default:
+		if err := checkKeys(result.Table.KeySchema, schema.HashKey, schema.RangeKey); err != nil {
+			return err
+		}
+		if err := m.migrateIndexes(ctx, schema, result.Table, dryRun); err != nil {
+			return err
+		}
+	}
+
+	if schema.TTLAttribute == "" {
+		return nil
+	}
+	return m.migrateTTL(ctx, schema, dryRun)
+}
+
+// createTable creates a table with its indexes and waits for it
+func (m *Migrator) createTable(ctx context.Context, schema embedded.TableSchema) error {
+	input := &dynamodb.CreateTableInput{
+		TableName:            aws.String(schema.Name),
+		BillingMode:          types.BillingModePayPerRequest,
+		KeySchema:            keySchema(schema.HashKey, schema.RangeKey),
+		AttributeDefinitions: attributeDefinitions(schema),
+		SSESpecification:     &types.SSESpecification{Enabled: aws.Bool(true)},
+	}
+	for _, index := range schema.Indexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:  aws.String(index.Name),
+			KeySchema:  keySchema(index.HashKey, index.RangeKey),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+
+	if _, err := m.client.CreateTable(ctx, input); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+	m.log.Info("creating table", "table", schema.Name, "indexes", len(schema.Indexes))
+	return m.waitActive(ctx, schema.Name)
+}
+
+// migrateIndexes creates the indexes a table is missing. DynamoDB creates
+// one index per update, so each is waited for before the next.
+func (m *Migrator) migrateIndexes(ctx context.Context, schema embedded.TableSchema, table *types.TableDescription, dryRun bool) error {
+	existing := make(map[string][]types.KeySchemaElement, len(table.GlobalSecondaryIndexes))
+	for _, index := range table.GlobalSecondaryIndexes {
+		existing[aws.ToString(index.IndexName)] = index.KeySchema
+	}
+
+	for _, index := range schema.Indexes {
+		if keys, ok := existing[index.Name]; ok {
+			if err := checkKeys(keys, index.HashKey, index.RangeKey); err != nil {
+				return fmt.Errorf("index %s: %w", index.Name, err)
+			}
+			continue
+		}
+
+		if dryRun {
+			m.log.Info("would create index", "table", schema.Name, "index", index.Name)
+			continue
+		}
+
+		_, err := m.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(schema.Name),
+			AttributeDefinitions: attributeDefinitions(schema),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+				Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName:  aws.String(index.Name),
+					KeySchema:  keySchema(index.HashKey, index.RangeKey),
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create index %s: %w", index.Name, err)
+		}
+		m.log.Info("creating index", "table", schema.Name, "index", index.Name)
+		if err := m.waitActive(ctx, schema.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTTL enables time to live on the schema's attribute
+func (m *Migrator) migrateTTL(ctx context.Context, schema embedded.TableSchema, dryRun bool) error {
+	result, err := m.client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(schema.Name),
+	})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound) && dryRun:
+		// The table would have been created without time to live
+	case err != nil:
+		return fmt.Errorf("failed to describe time to live: %w", err)
+	default:
+		ttl := result.TimeToLiveDescription
+		if ttl != nil && aws.ToString(ttl.AttributeName) == schema.TTLAttribute &&
+			(ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled || ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+			return nil
+		}
+	}
+
+	if dryRun {
+		m.log.Info("would enable time to live", "table", schema.Name, "attribute", schema.TTLAttribute)
+		return nil
+	}
+
+	_, err = m.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(schema.Name),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(schema.TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable time to live: %w", err)
+	}
+	m.log.Info("enabled time to live", "table", schema.Name, "attribute", schema.TTLAttribute)
+	return nil
+}
+
+// waitActive polls until a table and all its indexes are active
+func (m *Migrator) waitActive(ctx context.Context, name string) error {
+	ticker := time.NewTicker(migratePollInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := m.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(name),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe table: %w", err)
+		}
+		active := result.Table.TableStatus == types.TableStatusActive
+		for _, index := range result.Table.GlobalSecondaryIndexes {
+			active = active && index.IndexStatus == types.IndexStatusActive
+		}
+		if active {
+			m.log.Info("table active", "table", name)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for table to become active: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkKeys reports a key schema that differs from the expected keys
+func checkKeys(keys []types.KeySchemaElement, hashKey, rangeKey string) error {
+	var hash, rng string
+	for _, k := range keys {
+		switch k.KeyType {
+		case types.KeyTypeHash:
+			hash = aws.ToString(k.AttributeName)
+		case types.KeyTypeRange:
+			rng = aws.ToString(k.AttributeName)
+		}
+	}
+	if hash != hashKey || rng != rangeKey {
+		return fmt.Errorf("key schema is (%s, %s), expected (%s, %s); recreate it to fix", hash, rng, hashKey, rangeKey)
+	}
+	return nil
+}
+
+func keySchema(hashKey, rangeKey string) []types.KeySchemaElement {
+	keys := []types.KeySchemaElement{{AttributeName: aws.String(hashKey), KeyType: types.KeyTypeHash}}
+	if rangeKey != "" {
+		keys = append(keys, types.KeySchemaElement{AttributeName: aws.String(rangeKey), KeyType: types.KeyTypeRange})
+	}
+	return keys
+}
+
+// attributeDefinitions declares every key attribute of a table and its
+// indexes. All keys are strings.
+func attributeDefinitions(schema embedded.TableSchema) []types.AttributeDefinition {
+	var defs []types.AttributeDefinition
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			defs = append(defs, types.AttributeDefinition{
+				AttributeName: aws.String(name),
+				AttributeType: types.ScalarAttributeTypeS,
+			})
+		}
+	}
+
+	add(schema.HashKey)
+	add(schema.RangeKey)
+	for _, index := range schema.Indexes {
+		add(index.HashKey)
+		add(index.RangeKey)
+	}
+	return defs
+}
+
+// RewriteTimes rewrites the times index range keys hold in the format of
+// earlier versions, with their zone and trailing zeros trimmed, to
+// timeLayout, so they sort with the times written since. Each is only
+// rewritten if it is unchanged since it was read. With dryRun set it only
+// counts them.
+func (m *Migrator) RewriteTimes(ctx context.Context, dryRun bool) error {
+	for _, schema := range m.schemas {
+		attrs := timeRangeKeys(schema)
+		if len(attrs) == 0 {
+			continue
+		}
+
+		n, err := m.rewriteTimes(ctx, schema, attrs, dryRun)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", schema.Name, err)
+		}
+		if dryRun {
+			m.log.Info("would rewrite times", "table", schema.Name, "count", n)
+		} else {
+			m.log.Info("rewrote times", "table", schema.Name, "count", n)
+		}
+	}
+	return nil
+}
+
+// timeRangeKeys returns the range keys of a table's indexes that may hold
+// times. Primary key attributes can't be rewritten, so they are left out.
+func timeRangeKeys(schema embedded.TableSchema) []string {
+	var attrs []string
+	seen := map[string]bool{schema.HashKey: true, schema.RangeKey: true}
+	for _, index := range schema.Indexes {
+		if index.RangeKey != "" && !seen[index.RangeKey] {
+			seen[index.RangeKey] = true
+			attrs = append(attrs, index.RangeKey)
+		}
+	}
+	return attrs
+}
+
+// rewriteTimes rewrites the times of attrs in a table, returning how many
+// it rewrote, or would have
+func (m *Migrator) rewriteTimes(ctx context.Context, schema embedded.TableSchema, attrs []string, dryRun bool) (int, error) {
+	count := 0
+	paginator := dynamodb.NewScanPaginator(m.client, &dynamodb.ScanInput{
+		TableName: aws.String(schema.Name),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return count, fmt.Errorf("failed to scan table: %w", err)
+		}
+
+		for _, item := range page.Items {
+			for _, attr := range attrs {
+				stored, ok := item[attr].(*types.AttributeValueMemberS)
+				if !ok {
+					continue
+				}
+				t, err := time.Parse(time.RFC3339Nano, stored.Value)
+				if err != nil {
+					continue // Not a time
+				}
+				want := t.UTC().Format(timeLayout)
+				if want == stored.Value {
+					continue
+				}
+
+				count++
+				if dryRun {
+					continue
+				}
+				if err := m.rewriteTime(ctx, schema, item, attr, stored.Value, want); err != nil {
+					return count, err
+				}
+			}
+		}
+	}
+	return count, nil
+}
+
+// rewriteTime sets an item's attribute to want unless it no longer holds
+// stored
+func (m *Migrator) rewriteTime(ctx context.Context, schema embedded.TableSchema, item map[string]types.AttributeValue, attr, stored, want string) error {
+	key := map[string]types.AttributeValue{schema.HashKey: item[schema.HashKey]}
+	if schema.RangeKey != "" {
+		key[schema.RangeKey] = item[schema.RangeKey]
+	}
+
+	_, err := m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(schema.Name),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET #attr = :want"),
+		ConditionExpression: aws.String("#attr = :stored"),
+		ExpressionAttributeNames: map[string]string{
+			"#attr": attr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":want":   &types.AttributeValueMemberS{Value: want},
+			":stored": &types.AttributeValueMemberS{Value: stored},
+		},
+	})
+	if err != nil && !isConditionFailed(err) {
+		return fmt.Errorf("failed to rewrite %s: %w", attr, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// SetMediaModeration records the moderation result of a media item, along
+// with its status for the moderation index
+func (c *Client) SetMediaModeration(ctx context.Context, id string, moderation *domain.Moderation) error {
+	update := expression.Set(
+		expression.Name("moderation"),
+		expression.Value(moderation),
+	).Set(
+		expression.Name("moderation_status"),
+		expression.Value(moderation.Status),
+	).Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update moderation: %w", err)
+	}
+
+	return nil
+}
+
+// ListMediaByModerationStatus lists a page of media with a moderation
+// status, oldest first so reviewers work through the queue in order
+func (c *Client) ListMediaByModerationStatus(ctx context.Context, status domain.ModerationStatus, limit int32, cursor string) ([]*domain.Media, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("moderation_status").Equal(expression.Value(status))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.tableName),
+		IndexName:                 aws.String("moderation_status-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query media: %w", err)
+	}
+
+	var mediaList []*domain.Media
+	for _, item := range result.Items {
+		var media domain.Media
+		if err := attributevalue.UnmarshalMap(item, &media); err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal media: %w", err)
+		}
+		mediaList = append(mediaList, &media)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return mediaList, next, nil
+}
@@ -0,0 +1,225 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// The outbox table holds events written in the same transaction as the
+// change they describe, so neither is lost without the other. The relay
+// finds pending entries through status-index, claims each for a lease
+// with claimed_until so concurrent relays don't deliver it twice, and
+// marks it delivered; delivered entries expire after the retention.
+
+// outboxItem marshals a pending outbox entry for an event, filling in its
+// ID, time and tenant when unset
+func outboxItem(ctx context.Context, event *domain.Event) (map[string]types.AttributeValue, error) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.TenantID == "" {
+		event.TenantID = tenant.FromContext(ctx)
+	}
+
+	av, err := marshalMap(&domain.OutboxEntry{
+		ID:        event.ID,
+		Status:    domain.OutboxStatusPending,
+		Event:     *event,
+		CreatedAt: event.Time,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+	return av, nil
+}
+
+// transact applies a write together with outbox entries for events, all
+// or nothing
+func (c *Client) transact(ctx context.Context, write types.TransactWriteItem, events []*domain.Event) error {
+	items := []types.TransactWriteItem{write}
+	for _, event := range events {
+		av, err := outboxItem(ctx, event)
+		if err != nil {
+			return err
+		}
+		items = append(items, types.TransactWriteItem{Put: &types.Put{
+			TableName: aws.String(c.outboxTable),
+			Item:      av,
+		}})
+	}
+
+	_, err := c.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	return err
+}
+
+// PutEvents writes events to the outbox on their own, for events that
+// don't go with a change to a record
+func (c *Client) PutEvents(ctx context.Context, events ...*domain.Event) error {
+	for _, event := range events {
+		av, err := outboxItem(ctx, event)
+		if err != nil {
+			return err
+		}
+		_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(c.outboxTable),
+			Item:      av,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to put outbox entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListPendingEvents returns a page of undelivered outbox entries, oldest
+// first, and the cursor of the next page. Claimed entries are included.
+func (c *Client) ListPendingEvents(ctx context.Context, limit int32, cursor string) ([]*domain.OutboxEntry, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(c.outboxTable),
+		IndexName:              aws.String("status-index"),
+		KeyConditionExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: string(domain.OutboxStatusPending)},
+		},
+		ScanIndexForward:  aws.Bool(true),
+		Limit:             aws.Int32(limit),
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query outbox: %w", err)
+	}
+
+	var entries []*domain.OutboxEntry
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &entries); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal outbox entries: %w", err)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return entries, next, nil
+}
+
+// ClaimEvent claims a pending outbox entry for delivery until the given
+// time, reporting false if it was delivered or another relay holds it
+func (c *Client) ClaimEvent(ctx context.Context, id string, until time.Time) (bool, error) {
+	_, err := c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(c.outboxTable),
+		Key:                 map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+		UpdateExpression:    aws.String("SET claimed_until = :until"),
+		ConditionExpression: aws.String("#status = :pending AND (attribute_not_exists(claimed_until) OR claimed_until < :now)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":until":   &types.AttributeValueMemberN{Value: strconv.FormatInt(until.Unix(), 10)},
+			":now":     &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+			":pending": &types.AttributeValueMemberS{Value: string(domain.OutboxStatusPending)},
+		},
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim outbox entry: %w", err)
+	}
+	return true, nil
+}
+
+// MarkEventDelivered marks an outbox entry delivered, to expire after
+// retention
+func (c *Client) MarkEventDelivered(ctx context.Context, id string, retention time.Duration) error {
+	now := time.Now()
+	_, err := c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(c.outboxTable),
+		Key:              map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+		UpdateExpression: aws.String("SET #status = :delivered, delivered_at = :at, " + ttlAttribute + " = :expires REMOVE claimed_until"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":delivered": &types.AttributeValueMemberS{Value: string(domain.OutboxStatusDelivered)},
+			":at":        &types.AttributeValueMemberS{Value: formatTime(now)},
+			":expires":   &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(retention).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entry delivered: %w", err)
+	}
+	return nil
+}
+
+// RecordEventFailure counts a failed delivery of an outbox entry. It stays
+// claimed until its lease runs out, which spaces out the retries, unless
+// this was its maxAttempts-th failure: then it is marked failed, leaving
+// the pending entries for good, and true is returned.
+func (c *Client) RecordEventFailure(ctx context.Context, id string, cause error, maxAttempts int) (bool, error) {
+	result, err := c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(c.outboxTable),
+		Key:              map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+		UpdateExpression: aws.String("SET last_error = :error ADD attempts :one"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":error": &types.AttributeValueMemberS{Value: cause.Error()},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to record outbox delivery failure: %w", err)
+	}
+
+	var attempts int
+	if err := attributevalue.Unmarshal(result.Attributes["attempts"], &attempts); err != nil {
+		return false, fmt.Errorf("failed to unmarshal outbox attempts: %w", err)
+	}
+	if attempts < maxAttempts {
+		return false, nil
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(c.outboxTable),
+		Key:                 map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+		UpdateExpression:    aws.String("SET #status = :failed REMOVE claimed_until"),
+		ConditionExpression: aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":failed":  &types.AttributeValueMemberS{Value: string(domain.OutboxStatusFailed)},
+			":pending": &types.AttributeValueMemberS{Value: string(domain.OutboxStatusPending)},
+		},
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to mark outbox entry failed: %w", err)
+	}
+	return true, nil
+}
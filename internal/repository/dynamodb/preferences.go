@@ -0,0 +1,66 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// preferencesKey is the key of a user's preferences in ctx's tenant, as
+// user IDs are only unique within a tenant
+func preferencesKey(ctx context.Context, userID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: tenant.FromContext(ctx) + "#" + userID},
+	}
+}
+
+// GetPreferences retrieves a user's preferences, or nil if they saved none
+func (c *Client) GetPreferences(ctx context.Context, userID string) (*domain.UserPreferences, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.preferencesTable),
+		Key:       preferencesKey(ctx, userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var prefs domain.UserPreferences
+	if err := attributevalue.UnmarshalMap(result.Item, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal preferences: %w", err)
+	}
+
+	return &prefs, nil
+}
+
+// PutPreferences stores a user's preferences in ctx's tenant
+func (c *Client) PutPreferences(ctx context.Context, prefs *domain.UserPreferences) error {
+	prefs.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(prefs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal preferences: %w", err)
+	}
+	for k, v := range preferencesKey(ctx, prefs.UserID) {
+		av[k] = v
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.preferencesTable),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put preferences: %w", err)
+	}
+
+	return nil
+}
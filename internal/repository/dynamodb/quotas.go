@@ -0,0 +1,143 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// Quota usage is kept as one counter item per tenant and counter, such as
+// "acme#storage" or "acme#transcode#2026-10"
+
+// quotaCounterKey is the key of one of ctx's tenant's usage counters
+func quotaCounterKey(ctx context.Context, counter string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: tenant.FromContext(ctx) + "#" + counter},
+	}
+}
+
+// AddQuotaUsage adds delta to one of ctx's tenant's usage counters and
+// returns its new value. With a positive limit, an increase that would
+// take the counter past it fails with domain.ErrQuotaExceeded. Decreases
+// stop at zero. A non-zero expiresAt lets the table TTL remove the counter
+// once its period is over.
+func (c *Client) AddQuotaUsage(ctx context.Context, counter string, delta, limit, expiresAt int64) (int64, error) {
+	if limit > 0 && delta > limit {
+		return 0, domain.ErrQuotaExceeded
+	}
+
+	update := expression.Add(expression.Name("usage"), expression.Value(delta))
+	if expiresAt > 0 {
+		update = update.Set(expression.Name("expires_at"), expression.Value(expiresAt))
+	}
+	builder := expression.NewBuilder().WithUpdate(update)
+
+	usage := expression.Name("usage")
+	switch {
+	case delta > 0 && limit > 0:
+		builder = builder.WithCondition(expression.Or(
+			expression.AttributeNotExists(usage),
+			usage.LessThanEqual(expression.Value(limit-delta)),
+		))
+	case delta < 0:
+		builder = builder.WithCondition(usage.GreaterThanEqual(expression.Value(-delta)))
+	}
+
+	expr, err := builder.Build()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(c.quotasTable),
+		Key:                       quotaCounterKey(ctx, counter),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		if !isConditionFailed(err) {
+			return 0, fmt.Errorf("failed to update quota usage: %w", err)
+		}
+		if delta > 0 {
+			return 0, domain.ErrQuotaExceeded
+		}
+		// Releasing more than was counted, such as for files stored
+		// before quotas were enabled
+		return 0, c.resetQuotaUsage(ctx, counter)
+	}
+
+	return usageValue(result.Attributes), nil
+}
+
+// resetQuotaUsage zeroes one of ctx's tenant's usage counters
+func (c *Client) resetQuotaUsage(ctx context.Context, counter string) error {
+	_, err := c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(c.quotasTable),
+		Key:                      quotaCounterKey(ctx, counter),
+		UpdateExpression:         aws.String("SET #usage = :zero"),
+		ExpressionAttributeNames: map[string]string{"#usage": "usage"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset quota usage: %w", err)
+	}
+	return nil
+}
+
+// GetQuotaUsage returns the current value of each of ctx's tenant's usage
+// counters, which is zero for counters never used
+func (c *Client) GetQuotaUsage(ctx context.Context, counters ...string) (map[string]int64, error) {
+	keys := make([]map[string]types.AttributeValue, 0, len(counters))
+	byID := make(map[string]string, len(counters))
+	for _, counter := range counters {
+		key := quotaCounterKey(ctx, counter)
+		keys = append(keys, key)
+		byID[key["id"].(*types.AttributeValueMemberS).Value] = counter
+	}
+
+	result, err := c.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{
+			c.quotasTable: {Keys: keys, ConsistentRead: aws.Bool(true)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+
+	usage := make(map[string]int64, len(counters))
+	for _, counter := range counters {
+		usage[counter] = 0
+	}
+	for _, item := range result.Responses[c.quotasTable] {
+		id, ok := item["id"].(*types.AttributeValueMemberS)
+		if !ok {
+			continue
+		}
+		usage[byID[id.Value]] = usageValue(item)
+	}
+
+	return usage, nil
+}
+
+// usageValue reads the usage attribute of a counter item
+func usageValue(item map[string]types.AttributeValue) int64 {
+	n, ok := item["usage"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0
+	}
+	v, _ := strconv.ParseInt(n.Value, 10, 64)
+	return v
+}
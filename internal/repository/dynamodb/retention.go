@@ -0,0 +1,226 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/streaming-service/internal/domain"
+)
+
+// SetMediaExpiration sets when a media item expires, or clears it when at
+// is nil. Either way a warning already sent is forgotten.
+func (c *Client) SetMediaExpiration(ctx context.Context, id string, at *time.Time) error {
+	var media domain.Media
+	media.SetExpiration(at)
+
+	var update expression.UpdateBuilder
+	if media.ExpiresAt == nil {
+		update = expression.Remove(expression.Name("expires_at")).
+			Remove(expression.Name("expiring"))
+	} else {
+		update = expression.Set(expression.Name("expires_at"), expression.Value(formatTime(*media.ExpiresAt))).
+			Set(expression.Name("expiring"), expression.Value(media.Expiring))
+	}
+	update = update.Remove(expression.Name("expiry_warned_at")).
+		Set(expression.Name("updated_at"), expression.Value(time.Now()))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to set expiration: %w", err)
+	}
+
+	return nil
+}
+
+// ListExpiringMedia returns a page of the media of every tenant expiring
+// before the given time, soonest first, and the cursor of the next page
+func (c *Client) ListExpiringMedia(ctx context.Context, before time.Time, limit int32, cursor string) ([]*domain.Media, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("expiring").Equal(expression.Value(domain.ExpiringIndexKey)).
+		And(expression.Key("expires_at").LessThanEqual(expression.Value(formatTime(before.Truncate(time.Second)))))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.tableName),
+		IndexName:                 aws.String("expiring-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(true),
+		Limit:                     aws.Int32(limit),
+		ExclusiveStartKey:         startKey,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query expiring media: %w", err)
+	}
+
+	var mediaList []*domain.Media
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &mediaList); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal media: %w", err)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return mediaList, next, nil
+}
+
+// MarkExpiryWarned records that the warning before a media item expires
+// was sent
+func (c *Client) MarkExpiryWarned(ctx context.Context, id string, at time.Time) error {
+	update := expression.Set(expression.Name("expiry_warned_at"), expression.Value(at.UTC()))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to mark expiry warned: %w", err)
+	}
+
+	return nil
+}
+
+// SetMediaLegalHold places a media item under legal hold, or releases it
+// when hold is nil
+func (c *Client) SetMediaLegalHold(ctx context.Context, id string, hold *domain.LegalHold) error {
+	var update expression.UpdateBuilder
+	if hold == nil {
+		update = expression.Remove(expression.Name("legal_hold"))
+	} else {
+		update = expression.Set(expression.Name("legal_hold"), expression.Value(hold))
+	}
+	update = update.Set(expression.Name("updated_at"), expression.Value(time.Now()))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to set legal hold: %w", err)
+	}
+
+	return nil
+}
+
+// ArchiveMedia marks a media item archived once its processed files are
+// gone, dropping its renditions, output versions, cover art, DASH manifest
+// and expiration. Media under legal hold is left alone with ErrLegalHold.
+func (c *Client) ArchiveMedia(ctx context.Context, id string) error {
+	now := time.Now()
+	update := expression.Set(expression.Name("status"), expression.Value(domain.MediaStatusArchived)).
+		Set(expression.Name("archived_at"), expression.Value(now.UTC())).
+		Set(expression.Name("output_size"), expression.Value(0)).
+		Set(expression.Name("updated_at"), expression.Value(now)).
+		Remove(expression.Name("renditions")).
+		Remove(expression.Name("versions")).
+		Remove(expression.Name("output_version")).
+		Remove(expression.Name("cover_art_key")).
+		Remove(expression.Name("dash_key")).
+		Remove(expression.Name("storyboard_key")).
+		Remove(expression.Name("drm")).
+		Remove(expression.Name("expires_at")).
+		Remove(expression.Name("expiring")).
+		Remove(expression.Name("expiry_warned_at"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(unheldCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return c.heldOrMissing(ctx, id)
+		}
+		return fmt.Errorf("failed to archive media: %w", err)
+	}
+
+	return nil
+}
+
+// unheldCondition matches a media record of ctx's tenant that isn't under
+// legal hold, so a hold placed after the media was read still stops a
+// delete or archive
+func unheldCondition(ctx context.Context) expression.ConditionBuilder {
+	return ownedCondition(ctx).And(expression.AttributeNotExists(expression.Name("legal_hold")))
+}
+
+// heldOrMissing tells why a write under unheldCondition failed: the media
+// is under legal hold, or isn't there
+func (c *Client) heldOrMissing(ctx context.Context, id string) error {
+	media, err := c.GetMedia(ctx, id)
+	if err == nil && media.LegalHold != nil {
+		return domain.ErrLegalHold
+	}
+	return domain.ErrMediaNotFound
+}
@@ -0,0 +1,86 @@
+package dynamodb
+
+import (
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/dynamodb/embedded"
+)
+
+// ttlAttribute holds the Unix time an item expires at in the tables with
+// time to live enabled
+const ttlAttribute = "expires_at"
+
+// schemas describes the keys, indexes and time to live of every table,
+// matching deployments/terraform/dynamodb.tf
+func schemas(cfg appconfig.AWSConfig) []embedded.TableSchema {
+	byUser := embedded.IndexSchema{Name: "user_id-index", HashKey: "user_id", RangeKey: "created_at"}
+
+	return []embedded.TableSchema{
+		{
+			Name:    cfg.DynamoDBTable,
+			HashKey: "id",
+			Indexes: []embedded.IndexSchema{
+				byUser,
+				{Name: "channel_id-index", HashKey: "channel_id", RangeKey: "created_at"},
+				{Name: "status-index", HashKey: "status", RangeKey: "created_at"},
+				{Name: "moderation_status-index", HashKey: "moderation_status", RangeKey: "created_at"},
+				{Name: "expiring-index", HashKey: "expiring", RangeKey: "expires_at"},
+			},
+		},
+		{Name: cfg.AnalyticsTable, HashKey: "pk", RangeKey: "sk", TTLAttribute: ttlAttribute},
+		{Name: cfg.KeysTable, HashKey: "media_id", RangeKey: "key_id"},
+		{
+			Name:    cfg.LiveTable,
+			HashKey: "id",
+			Indexes: []embedded.IndexSchema{
+				{Name: "stream_key-index", HashKey: "stream_key"},
+				byUser,
+			},
+		},
+		{
+			Name:    cfg.APIKeysTable,
+			HashKey: "id",
+			Indexes: []embedded.IndexSchema{
+				{Name: "key_hash-index", HashKey: "key_hash"},
+				byUser,
+			},
+		},
+		{Name: cfg.TagsTable, HashKey: "tag", RangeKey: "media_id"},
+		{Name: cfg.CollectionsTable, HashKey: "id", Indexes: []embedded.IndexSchema{byUser}},
+		{Name: cfg.ChannelsTable, HashKey: "id", Indexes: []embedded.IndexSchema{byUser}},
+		{Name: cfg.IdempotencyTable, HashKey: "id", TTLAttribute: ttlAttribute},
+		{
+			Name:    cfg.AuditTable,
+			HashKey: "id",
+			Indexes: []embedded.IndexSchema{
+				{Name: "actor_id-index", HashKey: "actor_id", RangeKey: "created_at"},
+				{Name: "resource-index", HashKey: "resource", RangeKey: "created_at"},
+				{Name: "day-index", HashKey: "day", RangeKey: "created_at"},
+			},
+			TTLAttribute: ttlAttribute,
+		},
+		{Name: cfg.QuotasTable, HashKey: "id", TTLAttribute: ttlAttribute},
+		{Name: cfg.PreferencesTable, HashKey: "id"},
+		{
+			Name:         cfg.OutboxTable,
+			HashKey:      "id",
+			Indexes:      []embedded.IndexSchema{{Name: "status-index", HashKey: "status", RangeKey: "created_at"}},
+			TTLAttribute: ttlAttribute,
+		},
+		{Name: cfg.WebhooksTable, HashKey: "id", Indexes: []embedded.IndexSchema{byUser}},
+		{
+			Name:    cfg.DeliveriesTable,
+			HashKey: "id",
+			Indexes: []embedded.IndexSchema{
+				{Name: "webhook_id-index", HashKey: "webhook_id", RangeKey: "created_at"},
+				{Name: "status-index", HashKey: "status", RangeKey: "next_attempt_at"},
+			},
+			TTLAttribute: ttlAttribute,
+		},
+		{
+			Name:         cfg.JobsTable,
+			HashKey:      "id",
+			Indexes:      []embedded.IndexSchema{{Name: "media_id-index", HashKey: "media_id", RangeKey: "created_at"}},
+			TTLAttribute: ttlAttribute,
+		},
+	}
+}
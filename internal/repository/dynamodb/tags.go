@@ -0,0 +1,297 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// batchGetSize is the most keys DynamoDB accepts in one batch get
+const batchGetSize = 100
+
+// tagCountsPartition holds the count of listed media under each tag,
+// keyed by the tag in place of a media ID. Tag keys can't contain ':', so
+// no tag index entry shares its key.
+const tagCountsPartition = ":counts"
+
+// tagItem is an entry in the tag index, keyed by tag then media ID.
+// Listed entries are those counted under their tag, of media in public
+// listings; entries written before this was recorded were all counted.
+type tagItem struct {
+	Tag       string    `dynamodbav:"tag"`
+	MediaID   string    `dynamodbav:"media_id"`
+	Listed    bool      `dynamodbav:"listed"`
+	CreatedAt time.Time `dynamodbav:"created_at"`
+}
+
+// countedCondition matches a tag index entry counted under its tag
+func countedCondition() expression.ConditionBuilder {
+	return expression.Or(
+		expression.AttributeNotExists(expression.Name("listed")),
+		expression.Name("listed").Equal(expression.Value(true)),
+	)
+}
+
+// tagKey qualifies a tag index entry with ctx's tenant. Tag keys can't
+// contain ':', so no unqualified entry of the default tenant starts with
+// one and qualified entries can't collide with them.
+func tagKey(ctx context.Context, tag string) string {
+	id := tenant.FromContext(ctx)
+	if id == tenant.Default {
+		return tag
+	}
+	return ":" + id + ":" + tag
+}
+
+// tagCountKey is the key of a tag's count of media
+func tagCountKey(ctx context.Context, tag string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"tag":      &types.AttributeValueMemberS{Value: tagKey(ctx, tagCountsPartition)},
+		"media_id": &types.AttributeValueMemberS{Value: tag},
+	}
+}
+
+// SetMediaTags replaces the tags on a media record
+func (c *Client) SetMediaTags(ctx context.Context, id string, tags map[string]string) error {
+	update := expression.Set(
+		expression.Name("updated_at"),
+		expression.Value(time.Now()),
+	)
+	if len(tags) > 0 {
+		update = update.Set(expression.Name("tags"), expression.Value(tags))
+	} else {
+		update = update.Remove(expression.Name("tags"))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to update tags: %w", err)
+	}
+
+	return nil
+}
+
+// PutTagEntries adds a media item to the tag index under each tag and,
+// if it's listed, counts it under each tag it wasn't already indexed under
+func (c *Client) PutTagEntries(ctx context.Context, mediaID string, tags []string, listed bool) error {
+	now := time.Now()
+
+	for _, tag := range tags {
+		av, err := marshalMap(tagItem{Tag: tagKey(ctx, tag), MediaID: mediaID, Listed: listed, CreatedAt: now})
+		if err != nil {
+			return fmt.Errorf("failed to marshal tag entry: %w", err)
+		}
+
+		_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           aws.String(c.tagsTable),
+			Item:                av,
+			ConditionExpression: aws.String("attribute_not_exists(media_id)"),
+		})
+		if err != nil {
+			if isConditionFailed(err) {
+				continue
+			}
+			return fmt.Errorf("failed to put tag entry: %w", err)
+		}
+		if !listed {
+			continue
+		}
+
+		if err := c.addCounter(ctx, c.tagsTable, tagCountKey(ctx, tag), "media_count", 1); err != nil {
+			return fmt.Errorf("failed to count tag: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteTagEntries removes a media item from the tag index under each
+// tag, and from the count of each tag it was counted under
+func (c *Client) DeleteTagEntries(ctx context.Context, mediaID string, tags []string) error {
+	for _, tag := range tags {
+		result, err := c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(c.tagsTable),
+			Key: map[string]types.AttributeValue{
+				"tag":      &types.AttributeValueMemberS{Value: tagKey(ctx, tag)},
+				"media_id": &types.AttributeValueMemberS{Value: mediaID},
+			},
+			ReturnValues: types.ReturnValueAllOld,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete tag entry: %w", err)
+		}
+		if len(result.Attributes) == 0 {
+			continue
+		}
+		if listed, ok := result.Attributes["listed"].(*types.AttributeValueMemberBOOL); ok && !listed.Value {
+			continue
+		}
+
+		if err := c.addCounter(ctx, c.tagsTable, tagCountKey(ctx, tag), "media_count", -1); err != nil {
+			return fmt.Errorf("failed to uncount tag: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SetTagEntriesListed marks a media item's entries under each tag listed
+// or not, after its visibility or moderation changed, counting or
+// uncounting it under each tag whose entry changed
+func (c *Client) SetTagEntriesListed(ctx context.Context, mediaID string, tags []string, listed bool) error {
+	// Only entries changing are written, so each is counted once however
+	// often this is repeated
+	cond := expression.Name("listed").Equal(expression.Value(false))
+	delta := int64(1)
+	if !listed {
+		cond = countedCondition()
+		delta = -1
+	}
+	cond = expression.AttributeExists(expression.Name("media_id")).And(cond)
+	update := expression.Set(expression.Name("listed"), expression.Value(listed))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	for _, tag := range tags {
+		_, err := c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(c.tagsTable),
+			Key: map[string]types.AttributeValue{
+				"tag":      &types.AttributeValueMemberS{Value: tagKey(ctx, tag)},
+				"media_id": &types.AttributeValueMemberS{Value: mediaID},
+			},
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+		})
+		if err != nil {
+			if isConditionFailed(err) {
+				continue
+			}
+			return fmt.Errorf("failed to update tag entry: %w", err)
+		}
+
+		if err := c.addCounter(ctx, c.tagsTable, tagCountKey(ctx, tag), "media_count", delta); err != nil {
+			return fmt.Errorf("failed to count tag: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListTagCounts returns how many listed media items carry each tag of
+// ctx's tenant, by tag key and "key:value". Tags no longer on any listed
+// media may be counted as zero.
+func (c *Client) ListTagCounts(ctx context.Context) (map[string]int64, error) {
+	keyExpr := expression.Key("tag").Equal(expression.Value(tagKey(ctx, tagCountsPartition)))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	counts := make(map[string]int64)
+	paginator := dynamodb.NewQueryPaginator(c.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.tagsTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query tag counts: %w", err)
+		}
+		for _, item := range page.Items {
+			tag, ok := item["media_id"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			n, ok := item["media_count"].(*types.AttributeValueMemberN)
+			if !ok {
+				continue
+			}
+			count, err := strconv.ParseInt(n.Value, 10, 64)
+			if err != nil {
+				continue
+			}
+			counts[tag.Value] = count
+		}
+	}
+
+	return counts, nil
+}
+
+// ListMediaIDsByTag retrieves a page of media IDs carrying a tag, given
+// as a key or "key:value". Pass the returned cursor back to fetch the
+// next page; it is empty after the last page.
+func (c *Client) ListMediaIDsByTag(ctx context.Context, tag string, limit int32, cursor string) ([]string, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("tag").Equal(expression.Value(tagKey(ctx, tag)))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.tagsTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query tag index: %w", err)
+	}
+
+	ids := make([]string, 0, len(result.Items))
+	for _, item := range result.Items {
+		var entry tagItem
+		if err := attributevalue.UnmarshalMap(item, &entry); err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal tag entry: %w", err)
+		}
+		ids = append(ids, entry.MediaID)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return ids, next, nil
+}
@@ -0,0 +1,79 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/tenant"
+)
+
+// Records are isolated by the tenant of the context they are accessed
+// under. Records created are stamped with it; records of other tenants
+// read as missing, are filtered out of listings, and writes to them fail
+// their condition. Records written before tenancy carry no tenant_id and
+// belong to the default tenant.
+//
+// Analytics counters and content keys are keyed by media ID and only
+// reached through a media item already read under the caller's tenant.
+
+// tenantCondition matches records belonging to ctx's tenant
+func tenantCondition(ctx context.Context) expression.ConditionBuilder {
+	id := tenant.FromContext(ctx)
+	cond := expression.Name("tenant_id").Equal(expression.Value(id))
+	if id == tenant.Default {
+		cond = expression.Or(cond, expression.AttributeNotExists(expression.Name("tenant_id")))
+	}
+	return cond
+}
+
+// ownedCondition matches an existing record belonging to ctx's tenant, so
+// writes by key can neither touch another tenant's record nor create one
+func ownedCondition(ctx context.Context) expression.ConditionBuilder {
+	return expression.And(expression.AttributeExists(expression.Name("id")), tenantCondition(ctx))
+}
+
+// withTenant adds the tenant condition to a listing's filter
+func withTenant(ctx context.Context, filter expression.ConditionBuilder, ok bool) expression.ConditionBuilder {
+	if !ok {
+		return tenantCondition(ctx)
+	}
+	return expression.And(filter, tenantCondition(ctx))
+}
+
+// owns reports whether a record stored with tenantID belongs to ctx's
+// tenant
+func owns(ctx context.Context, tenantID string) bool {
+	return tenant.Of(tenantID) == tenant.FromContext(ctx)
+}
+
+// isConditionFailed reports whether a write, or a write of a
+// transaction, failed its condition
+func isConditionFailed(err error) bool {
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return true
+	}
+	var txErr *types.TransactionCanceledException
+	if errors.As(err, &txErr) {
+		for _, reason := range txErr.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conditionFailedAt reports whether a transaction was cancelled because
+// its i-th write failed its condition
+func conditionFailedAt(err error, i int) bool {
+	var txErr *types.TransactionCanceledException
+	if !errors.As(err, &txErr) || i >= len(txErr.CancellationReasons) {
+		return false
+	}
+	return aws.ToString(txErr.CancellationReasons[i].Code) == "ConditionalCheckFailed"
+}
@@ -0,0 +1,96 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/streaming-service/internal/domain"
+)
+
+// SetMediaVersions stores a media item's output versions and activates
+// one, copying its renditions, cover art, DASH manifest, storyboard and
+// DRM to the record and totalling the versions' size as the output size.
+// With expected set, the write only happens while that version is still
+// active and fails with ErrVersionConflict otherwise.
+func (c *Client) SetMediaVersions(ctx context.Context, id string, versions []domain.OutputVersion, active, expected int) error {
+	var version *domain.OutputVersion
+	var size int64
+	for i := range versions {
+		if versions[i].Number == active {
+			version = &versions[i]
+		}
+		size += versions[i].Size
+	}
+	if version == nil {
+		return domain.ErrVersionNotFound
+	}
+
+	update := expression.Set(expression.Name("versions"), expression.Value(versions)).
+		Set(expression.Name("output_version"), expression.Value(active)).
+		Set(expression.Name("renditions"), expression.Value(version.Renditions)).
+		Set(expression.Name("output_size"), expression.Value(size)).
+		Set(expression.Name("updated_at"), expression.Value(time.Now()))
+	if version.CoverArtKey != "" {
+		update = update.Set(expression.Name("cover_art_key"), expression.Value(version.CoverArtKey))
+	} else {
+		update = update.Remove(expression.Name("cover_art_key"))
+	}
+	if version.DASHKey != "" {
+		update = update.Set(expression.Name("dash_key"), expression.Value(version.DASHKey))
+	} else {
+		update = update.Remove(expression.Name("dash_key"))
+	}
+	if version.StoryboardKey != "" {
+		update = update.Set(expression.Name("storyboard_key"), expression.Value(version.StoryboardKey))
+	} else {
+		update = update.Remove(expression.Name("storyboard_key"))
+	}
+	if version.DRM != nil {
+		update = update.Set(expression.Name("drm"), expression.Value(version.DRM))
+	} else {
+		update = update.Remove(expression.Name("drm"))
+	}
+
+	cond := ownedCondition(ctx)
+	if expected > 0 {
+		current := expression.Name("output_version").Equal(expression.Value(expected))
+		if expected == 1 {
+			// Records processed before output was versioned have no
+			// output_version and are on version 1
+			current = current.Or(expression.AttributeNotExists(expression.Name("output_version")))
+		}
+		cond = cond.And(current)
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			if expected > 0 {
+				return domain.ErrVersionConflict
+			}
+			return domain.ErrMediaNotFound
+		}
+		return fmt.Errorf("failed to set versions: %w", err)
+	}
+
+	return nil
+}
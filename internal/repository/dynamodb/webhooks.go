@@ -0,0 +1,322 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// The deliveries table logs each event sent to a webhook. Pending
+// deliveries carry next_attempt_at, which puts them in status-index for
+// the worker to find once due; the worker claims one by moving its next
+// attempt past the request's timeout, so concurrent workers don't send it
+// twice. Finished deliveries expire after the retention.
+
+// CreateWebhook creates a new webhook record in ctx's tenant
+func (c *Client) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	webhook.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(webhook)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.webhooksTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID
+func (c *Client) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	result, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.webhooksTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, domain.ErrWebhookNotFound
+	}
+
+	var webhook domain.Webhook
+	if err := attributevalue.UnmarshalMap(result.Item, &webhook); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook: %w", err)
+	}
+
+	if !owns(ctx, webhook.TenantID) {
+		return nil, domain.ErrWebhookNotFound
+	}
+
+	return &webhook, nil
+}
+
+// UpdateWebhook replaces an existing webhook record
+func (c *Client) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	webhook.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(webhook)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook: %w", err)
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(c.webhooksTable),
+		Item:                      av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrWebhookNotFound
+		}
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteWebhook removes a webhook record. Its delivery log expires on its
+// own.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	expr, err := expression.NewBuilder().WithCondition(ownedCondition(ctx)).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.webhooksTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return domain.ErrWebhookNotFound
+		}
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return nil
+}
+
+// ListWebhooksByUser retrieves the webhooks of a user, newest first
+func (c *Client) ListWebhooksByUser(ctx context.Context, userID string, limit int32) ([]*domain.Webhook, error) {
+	keyExpr := expression.Key("user_id").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.webhooksTable),
+		IndexName:                 aws.String("user_id-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+
+	webhooks := make([]*domain.Webhook, 0, len(result.Items))
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &webhooks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// CreateWebhookDelivery records a new delivery in ctx's tenant, reporting
+// false if one with its ID was already recorded
+func (c *Client) CreateWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) (bool, error) {
+	delivery.TenantID = tenant.FromContext(ctx)
+
+	av, err := marshalMap(delivery)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal webhook delivery: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.deliveriesTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	return true, nil
+}
+
+// SaveWebhookDelivery replaces a delivery record after an attempt
+func (c *Client) SaveWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	av, err := marshalMap(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook delivery: %w", err)
+	}
+
+	_, err = c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.deliveriesTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ListWebhookDeliveries retrieves a page of a webhook's delivery log,
+// newest first, returning the cursor of the next page
+func (c *Client) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int32, cursor string) ([]*domain.WebhookDelivery, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("webhook_id").Equal(expression.Value(webhookID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).WithFilter(tenantCondition(ctx)).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.deliveriesTable),
+		IndexName:                 aws.String("webhook_id-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         startKey,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int32(limit),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+
+	deliveries := make([]*domain.WebhookDelivery, 0, len(result.Items))
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &deliveries); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal webhook deliveries: %w", err)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return deliveries, next, nil
+}
+
+// ListDueWebhookDeliveries returns a page of the pending deliveries of
+// every tenant due by the given time, longest overdue first, and the
+// cursor of the next page. Claimed deliveries are due once their claim
+// runs out.
+func (c *Client) ListDueWebhookDeliveries(ctx context.Context, by time.Time, limit int32, cursor string) ([]*domain.WebhookDelivery, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyExpr := expression.Key("status").Equal(expression.Value(domain.WebhookDeliveryPending)).
+		And(expression.Key("next_attempt_at").LessThanEqual(expression.Value(formatTime(by.Truncate(time.Second)))))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyExpr).Build()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := c.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(c.deliveriesTable),
+		IndexName:                 aws.String("status-index"),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(true),
+		Limit:                     aws.Int32(limit),
+		ExclusiveStartKey:         startKey,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query due webhook deliveries: %w", err)
+	}
+
+	deliveries := make([]*domain.WebhookDelivery, 0, len(result.Items))
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &deliveries); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal webhook deliveries: %w", err)
+	}
+
+	next, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return deliveries, next, nil
+}
+
+// ClaimWebhookDelivery claims a pending delivery that is due by moving its
+// next attempt to until, reporting false if it finished or another worker
+// claimed it first
+func (c *Client) ClaimWebhookDelivery(ctx context.Context, id string, now, until time.Time) (bool, error) {
+	update := expression.Set(expression.Name("next_attempt_at"), expression.Value(formatTime(until.Truncate(time.Second))))
+	cond := expression.Name("status").Equal(expression.Value(domain.WebhookDeliveryPending)).
+		And(expression.Name("next_attempt_at").LessThanEqual(expression.Value(formatTime(now.Truncate(time.Second)))))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return false, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = c.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.deliveriesTable),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+
+	return true, nil
+}
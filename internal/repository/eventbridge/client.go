@@ -0,0 +1,66 @@
+package eventbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+// Client wraps Amazon EventBridge
+type Client struct {
+	client   *eventbridge.Client
+	eventBus string
+	source   string
+}
+
+// NewClient creates a new EventBridge client putting events on the
+// configured bus
+func NewClient(ctx context.Context, cfg appconfig.AWSConfig, events appconfig.EventsConfig) (*Client, error) {
+	// Build AWS config
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client:   eventbridge.NewFromConfig(awsCfg),
+		eventBus: events.EventBus,
+		source:   events.Source,
+	}, nil
+}
+
+// PublishEvent puts an event on the bus, with its type as the detail type
+// and the event itself as the detail
+func (c *Client) PublishEvent(ctx context.Context, event *domain.Event) error {
+	detail, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	result, err := c.client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []types.PutEventsRequestEntry{{
+			EventBusName: aws.String(c.eventBus),
+			Source:       aws.String(c.source),
+			DetailType:   aws.String(string(event.Type)),
+			Detail:       aws.String(string(detail)),
+			Time:         aws.Time(event.Time),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put event: %w", err)
+	}
+	if result.FailedEntryCount > 0 && len(result.Entries) > 0 {
+		entry := result.Entries[0]
+		return fmt.Errorf("failed to put event: %s: %s", aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage))
+	}
+
+	return nil
+}
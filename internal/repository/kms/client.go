@@ -0,0 +1,58 @@
+package kms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+// Client wraps the AWS KMS client for envelope encryption of content keys
+type Client struct {
+	client *kms.Client
+	keyID  string
+}
+
+// NewClient creates a new KMS client that encrypts with keyID
+func NewClient(ctx context.Context, cfg appconfig.AWSConfig, keyID string) (*Client, error) {
+	// Build AWS config
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client: kms.NewFromConfig(awsCfg),
+		keyID:  keyID,
+	}, nil
+}
+
+// Encrypt encrypts plaintext with the configured KMS key
+func (c *Client) Encrypt(ctx context.Context, plaintext []byte, encryptionContext map[string]string) ([]byte, error) {
+	result, err := c.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(c.keyID),
+		Plaintext:         plaintext,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt with KMS: %w", err)
+	}
+	return result.CiphertextBlob, nil
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt
+func (c *Client) Decrypt(ctx context.Context, ciphertext []byte, encryptionContext map[string]string) ([]byte, error) {
+	result, err := c.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(c.keyID),
+		CiphertextBlob:    ciphertext,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with KMS: %w", err)
+	}
+	return result.Plaintext, nil
+}
@@ -0,0 +1,107 @@
+// Package llm is a minimal client for OpenAI-compatible chat completions
+// APIs, which most hosted and self-hosted language models offer
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/streaming-service/internal/config"
+)
+
+// Client sends prompts to a chat completions API
+type Client struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// Message is one message of a chat
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type completionRequest struct {
+	Model          string         `json:"model"`
+	Messages       []Message      `json:"messages"`
+	ResponseFormat responseFormat `json:"response_format"`
+}
+
+type responseFormat struct {
+	Type string `json:"type"`
+}
+
+type completionResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+}
+
+// NewClient creates a new chat completions client
+func NewClient(cfg config.EnrichmentConfig) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(cfg.URL, "/"),
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Model returns the model prompts are sent to
+func (c *Client) Model() string {
+	return c.model
+}
+
+// CompleteJSON sends a system prompt and a user prompt and returns the
+// model's reply, which it is asked to give as a JSON object
+func (c *Client) CompleteJSON(ctx context.Context, system, user string) (string, error) {
+	in := completionRequest{
+		Model: c.model,
+		Messages: []Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		ResponseFormat: responseFormat{Type: "json_object"},
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send completion request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("completions API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var out completionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode completion: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("completions API returned no choices")
+	}
+
+	return out.Choices[0].Message.Content, nil
+}
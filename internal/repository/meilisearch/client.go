@@ -0,0 +1,268 @@
+package meilisearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/streaming-service/internal/config"
+)
+
+// Client is a minimal Meilisearch client scoped to a single index and
+// the transcript index beside it
+type Client struct {
+	baseURL    string
+	apiKey     string
+	index      string
+	httpClient *http.Client
+}
+
+// Document is a media item as stored in the search index
+type Document struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Type        string   `json:"type"`
+	Status      string   `json:"status"`
+	Visibility  string   `json:"visibility"`
+	UserID      string   `json:"user_id"`
+	TenantID    string   `json:"tenant_id"`
+	Duration    float64  `json:"duration"`
+	// CreatedAt is a Unix timestamp so it can be sorted and filtered
+	CreatedAt int64 `json:"created_at"`
+}
+
+// TranscriptDocument is a caption cue as stored in the transcript index,
+// which sits beside the media index
+type TranscriptDocument struct {
+	ID       string `json:"id"`
+	MediaID  string `json:"media_id"`
+	Language string `json:"language"`
+	TenantID string `json:"tenant_id"`
+	// Start and End are the cue's position in seconds
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// TranscriptHit is a matched caption cue
+type TranscriptHit struct {
+	TranscriptDocument
+	RankingScore float64 `json:"_rankingScore"`
+}
+
+// TranscriptSearchResponse holds relevance-ordered transcript hits
+type TranscriptSearchResponse struct {
+	Hits               []TranscriptHit `json:"hits"`
+	EstimatedTotalHits int             `json:"estimatedTotalHits"`
+}
+
+// SearchRequest is a query against the index
+type SearchRequest struct {
+	Query  string `json:"q"`
+	Filter string `json:"filter,omitempty"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// Hit is a matched document with its relevance score
+type Hit struct {
+	Document
+	RankingScore float64 `json:"_rankingScore"`
+}
+
+// SearchResponse holds relevance-ordered hits
+type SearchResponse struct {
+	Hits               []Hit `json:"hits"`
+	EstimatedTotalHits int   `json:"estimatedTotalHits"`
+	ProcessingTimeMs   int   `json:"processingTimeMs"`
+}
+
+// NewClient creates a new Meilisearch client
+func NewClient(cfg config.SearchConfig) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(cfg.URL, "/"),
+		apiKey:     cfg.APIKey,
+		index:      cfg.Index,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// EnsureIndex applies the index settings, creating the index if needed.
+// Titles outrank tags, which outrank descriptions.
+func (c *Client) EnsureIndex(ctx context.Context) error {
+	settings := map[string]interface{}{
+		"searchableAttributes": []string{"title", "tags", "description"},
+		"filterableAttributes": []string{"visibility", "status", "type", "user_id", "tenant_id", "tags"},
+		"sortableAttributes":   []string{"created_at", "duration"},
+	}
+
+	if err := c.do(ctx, http.MethodPatch, c.indexPath("/settings"), settings, nil); err != nil {
+		return fmt.Errorf("failed to update index settings: %w", err)
+	}
+
+	transcriptSettings := map[string]interface{}{
+		"searchableAttributes": []string{"text"},
+		"filterableAttributes": []string{"media_id", "language", "tenant_id"},
+		"sortableAttributes":   []string{"start"},
+	}
+
+	if err := c.do(ctx, http.MethodPatch, c.transcriptPath("/settings"), transcriptSettings, nil); err != nil {
+		return fmt.Errorf("failed to update transcript index settings: %w", err)
+	}
+
+	return nil
+}
+
+// IndexDocuments adds or replaces documents by ID
+func (c *Client) IndexDocuments(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	if err := c.do(ctx, http.MethodPost, c.indexPath("/documents?primaryKey=id"), docs, nil); err != nil {
+		return fmt.Errorf("failed to index documents: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDocument removes a document by ID
+func (c *Client) DeleteDocument(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, c.indexPath("/documents/"+url.PathEscape(id)), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+
+	return nil
+}
+
+// Search runs a relevance-ranked query
+func (c *Client) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	body := struct {
+		*SearchRequest
+		ShowRankingScore bool `json:"showRankingScore"`
+	}{req, true}
+
+	var resp SearchResponse
+	if err := c.do(ctx, http.MethodPost, c.indexPath("/search"), body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// IndexTranscript adds or replaces caption cues by ID
+func (c *Client) IndexTranscript(ctx context.Context, docs []TranscriptDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	if err := c.do(ctx, http.MethodPost, c.transcriptPath("/documents?primaryKey=id"), docs, nil); err != nil {
+		return fmt.Errorf("failed to index transcript: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTranscripts removes the caption cues matching filter, such as
+// those of one media item
+func (c *Client) DeleteTranscripts(ctx context.Context, filter string) error {
+	body := map[string]string{"filter": filter}
+	if err := c.do(ctx, http.MethodPost, c.transcriptPath("/documents/delete"), body, nil); err != nil {
+		return fmt.Errorf("failed to delete transcript: %w", err)
+	}
+
+	return nil
+}
+
+// TranscriptDocuments returns up to limit caption cues matching filter
+func (c *Client) TranscriptDocuments(ctx context.Context, filter string, limit int) ([]TranscriptDocument, error) {
+	body := map[string]interface{}{"filter": filter, "limit": limit}
+
+	var resp struct {
+		Results []TranscriptDocument `json:"results"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.transcriptPath("/documents/fetch"), body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch transcript: %w", err)
+	}
+
+	return resp.Results, nil
+}
+
+// SearchTranscripts runs a relevance-ranked query over caption cues
+func (c *Client) SearchTranscripts(ctx context.Context, req *SearchRequest) (*TranscriptSearchResponse, error) {
+	body := struct {
+		*SearchRequest
+		ShowRankingScore bool `json:"showRankingScore"`
+	}{req, true}
+
+	var resp TranscriptSearchResponse
+	if err := c.do(ctx, http.MethodPost, c.transcriptPath("/search"), body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to search transcripts: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// Ping checks that Meilisearch is available
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health", nil, nil)
+}
+
+// indexPath returns a path below the configured index
+func (c *Client) indexPath(suffix string) string {
+	return "/indexes/" + url.PathEscape(c.index) + suffix
+}
+
+// transcriptPath returns a path below the transcript index, named after
+// the configured index
+func (c *Client) transcriptPath(suffix string) string {
+	return "/indexes/" + url.PathEscape(c.index+"_transcripts") + suffix
+}
+
+// do sends a JSON request and decodes the response into out when set.
+// Writes are queued as tasks by Meilisearch and applied asynchronously.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("meilisearch returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
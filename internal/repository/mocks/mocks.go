@@ -0,0 +1,882 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: repository.go
+//
+// Generated by this command:
+//
+//	mockgen -source=repository.go -destination=mocks/mocks.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	io "io"
+	reflect "reflect"
+	time "time"
+
+	types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	domain "github.com/streaming-service/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockUploader is a mock of Uploader interface.
+type MockUploader struct {
+	ctrl     *gomock.Controller
+	recorder *MockUploaderMockRecorder
+	isgomock struct{}
+}
+
+// MockUploaderMockRecorder is the mock recorder for MockUploader.
+type MockUploaderMockRecorder struct {
+	mock *MockUploader
+}
+
+// NewMockUploader creates a new mock instance.
+func NewMockUploader(ctrl *gomock.Controller) *MockUploader {
+	mock := &MockUploader{ctrl: ctrl}
+	mock.recorder = &MockUploaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUploader) EXPECT() *MockUploaderMockRecorder {
+	return m.recorder
+}
+
+// Upload mocks base method.
+func (m *MockUploader) Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upload", ctx, bucket, key, body, contentType)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Upload indicates an expected call of Upload.
+func (mr *MockUploaderMockRecorder) Upload(ctx, bucket, key, body, contentType any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upload", reflect.TypeOf((*MockUploader)(nil).Upload), ctx, bucket, key, body, contentType)
+}
+
+// UploadRaw mocks base method.
+func (m *MockUploader) UploadRaw(ctx context.Context, key string, body io.Reader, contentType string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadRaw", ctx, key, body, contentType)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UploadRaw indicates an expected call of UploadRaw.
+func (mr *MockUploaderMockRecorder) UploadRaw(ctx, key, body, contentType any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadRaw", reflect.TypeOf((*MockUploader)(nil).UploadRaw), ctx, key, body, contentType)
+}
+
+// MockDownloader is a mock of Downloader interface.
+type MockDownloader struct {
+	ctrl     *gomock.Controller
+	recorder *MockDownloaderMockRecorder
+	isgomock struct{}
+}
+
+// MockDownloaderMockRecorder is the mock recorder for MockDownloader.
+type MockDownloaderMockRecorder struct {
+	mock *MockDownloader
+}
+
+// NewMockDownloader creates a new mock instance.
+func NewMockDownloader(ctrl *gomock.Controller) *MockDownloader {
+	mock := &MockDownloader{ctrl: ctrl}
+	mock.recorder = &MockDownloaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDownloader) EXPECT() *MockDownloaderMockRecorder {
+	return m.recorder
+}
+
+// Download mocks base method.
+func (m *MockDownloader) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Download", ctx, bucket, key)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Download indicates an expected call of Download.
+func (mr *MockDownloaderMockRecorder) Download(ctx, bucket, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockDownloader)(nil).Download), ctx, bucket, key)
+}
+
+// Size mocks base method.
+func (m *MockDownloader) Size(ctx context.Context, bucket, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Size", ctx, bucket, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Size indicates an expected call of Size.
+func (mr *MockDownloaderMockRecorder) Size(ctx, bucket, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Size", reflect.TypeOf((*MockDownloader)(nil).Size), ctx, bucket, key)
+}
+
+// MockObjectStore is a mock of ObjectStore interface.
+type MockObjectStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockObjectStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockObjectStoreMockRecorder is the mock recorder for MockObjectStore.
+type MockObjectStoreMockRecorder struct {
+	mock *MockObjectStore
+}
+
+// NewMockObjectStore creates a new mock instance.
+func NewMockObjectStore(ctrl *gomock.Controller) *MockObjectStore {
+	mock := &MockObjectStore{ctrl: ctrl}
+	mock.recorder = &MockObjectStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockObjectStore) EXPECT() *MockObjectStoreMockRecorder {
+	return m.recorder
+}
+
+// AbortMultipartUpload mocks base method.
+func (m *MockObjectStore) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AbortMultipartUpload", ctx, key, uploadID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AbortMultipartUpload indicates an expected call of AbortMultipartUpload.
+func (mr *MockObjectStoreMockRecorder) AbortMultipartUpload(ctx, key, uploadID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AbortMultipartUpload", reflect.TypeOf((*MockObjectStore)(nil).AbortMultipartUpload), ctx, key, uploadID)
+}
+
+// CompleteMultipartUpload mocks base method.
+func (m *MockObjectStore) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []types.CompletedPart) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteMultipartUpload", ctx, key, uploadID, parts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteMultipartUpload indicates an expected call of CompleteMultipartUpload.
+func (mr *MockObjectStoreMockRecorder) CompleteMultipartUpload(ctx, key, uploadID, parts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteMultipartUpload", reflect.TypeOf((*MockObjectStore)(nil).CompleteMultipartUpload), ctx, key, uploadID, parts)
+}
+
+// CreateMultipartUpload mocks base method.
+func (m *MockObjectStore) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMultipartUpload", ctx, key, contentType)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMultipartUpload indicates an expected call of CreateMultipartUpload.
+func (mr *MockObjectStoreMockRecorder) CreateMultipartUpload(ctx, key, contentType any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMultipartUpload", reflect.TypeOf((*MockObjectStore)(nil).CreateMultipartUpload), ctx, key, contentType)
+}
+
+// Delete mocks base method.
+func (m *MockObjectStore) Delete(ctx context.Context, bucket, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, bucket, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockObjectStoreMockRecorder) Delete(ctx, bucket, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockObjectStore)(nil).Delete), ctx, bucket, key)
+}
+
+// Download mocks base method.
+func (m *MockObjectStore) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Download", ctx, bucket, key)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Download indicates an expected call of Download.
+func (mr *MockObjectStoreMockRecorder) Download(ctx, bucket, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockObjectStore)(nil).Download), ctx, bucket, key)
+}
+
+// GetPresignedPartURL mocks base method.
+func (m *MockObjectStore) GetPresignedPartURL(ctx context.Context, key, uploadID string, partNumber int32, expiresIn time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPresignedPartURL", ctx, key, uploadID, partNumber, expiresIn)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPresignedPartURL indicates an expected call of GetPresignedPartURL.
+func (mr *MockObjectStoreMockRecorder) GetPresignedPartURL(ctx, key, uploadID, partNumber, expiresIn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPresignedPartURL", reflect.TypeOf((*MockObjectStore)(nil).GetPresignedPartURL), ctx, key, uploadID, partNumber, expiresIn)
+}
+
+// GetPresignedUploadURL mocks base method.
+func (m *MockObjectStore) GetPresignedUploadURL(ctx context.Context, key, contentType string, expiresIn time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPresignedUploadURL", ctx, key, contentType, expiresIn)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPresignedUploadURL indicates an expected call of GetPresignedUploadURL.
+func (mr *MockObjectStoreMockRecorder) GetPresignedUploadURL(ctx, key, contentType, expiresIn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPresignedUploadURL", reflect.TypeOf((*MockObjectStore)(nil).GetPresignedUploadURL), ctx, key, contentType, expiresIn)
+}
+
+// GetProcessedBucket mocks base method.
+func (m *MockObjectStore) GetProcessedBucket() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProcessedBucket")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetProcessedBucket indicates an expected call of GetProcessedBucket.
+func (mr *MockObjectStoreMockRecorder) GetProcessedBucket() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProcessedBucket", reflect.TypeOf((*MockObjectStore)(nil).GetProcessedBucket))
+}
+
+// GetRawBucket mocks base method.
+func (m *MockObjectStore) GetRawBucket() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRawBucket")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetRawBucket indicates an expected call of GetRawBucket.
+func (mr *MockObjectStoreMockRecorder) GetRawBucket() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRawBucket", reflect.TypeOf((*MockObjectStore)(nil).GetRawBucket))
+}
+
+// ListObjects mocks base method.
+func (m *MockObjectStore) ListObjects(ctx context.Context, bucket, prefix string) ([]types.Object, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListObjects", ctx, bucket, prefix)
+	ret0, _ := ret[0].([]types.Object)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListObjects indicates an expected call of ListObjects.
+func (mr *MockObjectStoreMockRecorder) ListObjects(ctx, bucket, prefix any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListObjects", reflect.TypeOf((*MockObjectStore)(nil).ListObjects), ctx, bucket, prefix)
+}
+
+// Size mocks base method.
+func (m *MockObjectStore) Size(ctx context.Context, bucket, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Size", ctx, bucket, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Size indicates an expected call of Size.
+func (mr *MockObjectStoreMockRecorder) Size(ctx, bucket, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Size", reflect.TypeOf((*MockObjectStore)(nil).Size), ctx, bucket, key)
+}
+
+// Upload mocks base method.
+func (m *MockObjectStore) Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upload", ctx, bucket, key, body, contentType)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Upload indicates an expected call of Upload.
+func (mr *MockObjectStoreMockRecorder) Upload(ctx, bucket, key, body, contentType any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upload", reflect.TypeOf((*MockObjectStore)(nil).Upload), ctx, bucket, key, body, contentType)
+}
+
+// UploadRaw mocks base method.
+func (m *MockObjectStore) UploadRaw(ctx context.Context, key string, body io.Reader, contentType string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadRaw", ctx, key, body, contentType)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UploadRaw indicates an expected call of UploadRaw.
+func (mr *MockObjectStoreMockRecorder) UploadRaw(ctx, key, body, contentType any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadRaw", reflect.TypeOf((*MockObjectStore)(nil).UploadRaw), ctx, key, body, contentType)
+}
+
+// MockMediaStore is a mock of MediaStore interface.
+type MockMediaStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockMediaStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockMediaStoreMockRecorder is the mock recorder for MockMediaStore.
+type MockMediaStoreMockRecorder struct {
+	mock *MockMediaStore
+}
+
+// NewMockMediaStore creates a new mock instance.
+func NewMockMediaStore(ctrl *gomock.Controller) *MockMediaStore {
+	mock := &MockMediaStore{ctrl: ctrl}
+	mock.recorder = &MockMediaStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMediaStore) EXPECT() *MockMediaStoreMockRecorder {
+	return m.recorder
+}
+
+// ArchiveMedia mocks base method.
+func (m *MockMediaStore) ArchiveMedia(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchiveMedia", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ArchiveMedia indicates an expected call of ArchiveMedia.
+func (mr *MockMediaStoreMockRecorder) ArchiveMedia(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveMedia", reflect.TypeOf((*MockMediaStore)(nil).ArchiveMedia), ctx, id)
+}
+
+// BatchGetMedia mocks base method.
+func (m *MockMediaStore) BatchGetMedia(ctx context.Context, ids []string) ([]*domain.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchGetMedia", ctx, ids)
+	ret0, _ := ret[0].([]*domain.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BatchGetMedia indicates an expected call of BatchGetMedia.
+func (mr *MockMediaStoreMockRecorder) BatchGetMedia(ctx, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchGetMedia", reflect.TypeOf((*MockMediaStore)(nil).BatchGetMedia), ctx, ids)
+}
+
+// CreateMedia mocks base method.
+func (m *MockMediaStore) CreateMedia(ctx context.Context, media *domain.Media, events ...*domain.Event) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, media}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateMedia", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMedia indicates an expected call of CreateMedia.
+func (mr *MockMediaStoreMockRecorder) CreateMedia(ctx, media any, events ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, media}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMedia", reflect.TypeOf((*MockMediaStore)(nil).CreateMedia), varargs...)
+}
+
+// DeleteMedia mocks base method.
+func (m *MockMediaStore) DeleteMedia(ctx context.Context, id string, events ...*domain.Event) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, id}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteMedia", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMedia indicates an expected call of DeleteMedia.
+func (mr *MockMediaStoreMockRecorder) DeleteMedia(ctx, id any, events ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, id}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMedia", reflect.TypeOf((*MockMediaStore)(nil).DeleteMedia), varargs...)
+}
+
+// GetMedia mocks base method.
+func (m *MockMediaStore) GetMedia(ctx context.Context, id string) (*domain.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMedia", ctx, id)
+	ret0, _ := ret[0].(*domain.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMedia indicates an expected call of GetMedia.
+func (mr *MockMediaStoreMockRecorder) GetMedia(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMedia", reflect.TypeOf((*MockMediaStore)(nil).GetMedia), ctx, id)
+}
+
+// ListMediaByChannel mocks base method.
+func (m *MockMediaStore) ListMediaByChannel(ctx context.Context, channelID string, publicOnly bool, limit int32, cursor string) ([]*domain.Media, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMediaByChannel", ctx, channelID, publicOnly, limit, cursor)
+	ret0, _ := ret[0].([]*domain.Media)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListMediaByChannel indicates an expected call of ListMediaByChannel.
+func (mr *MockMediaStoreMockRecorder) ListMediaByChannel(ctx, channelID, publicOnly, limit, cursor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMediaByChannel", reflect.TypeOf((*MockMediaStore)(nil).ListMediaByChannel), ctx, channelID, publicOnly, limit, cursor)
+}
+
+// ListMediaByUser mocks base method.
+func (m *MockMediaStore) ListMediaByUser(ctx context.Context, userID string, filter *domain.MediaFilter, limit int32, cursor string) ([]*domain.Media, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMediaByUser", ctx, userID, filter, limit, cursor)
+	ret0, _ := ret[0].([]*domain.Media)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListMediaByUser indicates an expected call of ListMediaByUser.
+func (mr *MockMediaStoreMockRecorder) ListMediaByUser(ctx, userID, filter, limit, cursor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMediaByUser", reflect.TypeOf((*MockMediaStore)(nil).ListMediaByUser), ctx, userID, filter, limit, cursor)
+}
+
+// RetryMedia mocks base method.
+func (m *MockMediaStore) RetryMedia(ctx context.Context, id string, retries []domain.ProcessingRetry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryMedia", ctx, id, retries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryMedia indicates an expected call of RetryMedia.
+func (mr *MockMediaStoreMockRecorder) RetryMedia(ctx, id, retries any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryMedia", reflect.TypeOf((*MockMediaStore)(nil).RetryMedia), ctx, id, retries)
+}
+
+// SetMediaEncryption mocks base method.
+func (m *MockMediaStore) SetMediaEncryption(ctx context.Context, id string, info *domain.EncryptionInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMediaEncryption", ctx, id, info)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMediaEncryption indicates an expected call of SetMediaEncryption.
+func (mr *MockMediaStoreMockRecorder) SetMediaEncryption(ctx, id, info any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMediaEncryption", reflect.TypeOf((*MockMediaStore)(nil).SetMediaEncryption), ctx, id, info)
+}
+
+// SetMediaLegalHold mocks base method.
+func (m *MockMediaStore) SetMediaLegalHold(ctx context.Context, id string, hold *domain.LegalHold) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMediaLegalHold", ctx, id, hold)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMediaLegalHold indicates an expected call of SetMediaLegalHold.
+func (mr *MockMediaStoreMockRecorder) SetMediaLegalHold(ctx, id, hold any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMediaLegalHold", reflect.TypeOf((*MockMediaStore)(nil).SetMediaLegalHold), ctx, id, hold)
+}
+
+// SetMediaMetadata mocks base method.
+func (m *MockMediaStore) SetMediaMetadata(ctx context.Context, id string, metadata *domain.SourceMetadata) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMediaMetadata", ctx, id, metadata)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMediaMetadata indicates an expected call of SetMediaMetadata.
+func (mr *MockMediaStoreMockRecorder) SetMediaMetadata(ctx, id, metadata any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMediaMetadata", reflect.TypeOf((*MockMediaStore)(nil).SetMediaMetadata), ctx, id, metadata)
+}
+
+// SetMediaVersions mocks base method.
+func (m *MockMediaStore) SetMediaVersions(ctx context.Context, id string, versions []domain.OutputVersion, active, expected int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMediaVersions", ctx, id, versions, active, expected)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMediaVersions indicates an expected call of SetMediaVersions.
+func (mr *MockMediaStoreMockRecorder) SetMediaVersions(ctx, id, versions, active, expected any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMediaVersions", reflect.TypeOf((*MockMediaStore)(nil).SetMediaVersions), ctx, id, versions, active, expected)
+}
+
+// UpdateMediaDetails mocks base method.
+func (m *MockMediaStore) UpdateMediaDetails(ctx context.Context, id string, title, description *string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMediaDetails", ctx, id, title, description)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateMediaDetails indicates an expected call of UpdateMediaDetails.
+func (mr *MockMediaStoreMockRecorder) UpdateMediaDetails(ctx, id, title, description any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMediaDetails", reflect.TypeOf((*MockMediaStore)(nil).UpdateMediaDetails), ctx, id, title, description)
+}
+
+// UpdateMediaProcessing mocks base method.
+func (m *MockMediaStore) UpdateMediaProcessing(ctx context.Context, id string, progress *domain.ProcessingProgress) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMediaProcessing", ctx, id, progress)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateMediaProcessing indicates an expected call of UpdateMediaProcessing.
+func (mr *MockMediaStoreMockRecorder) UpdateMediaProcessing(ctx, id, progress any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMediaProcessing", reflect.TypeOf((*MockMediaStore)(nil).UpdateMediaProcessing), ctx, id, progress)
+}
+
+// UpdateMediaStatus mocks base method.
+func (m *MockMediaStore) UpdateMediaStatus(ctx context.Context, id string, status domain.MediaStatus, events ...*domain.Event) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, id, status}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateMediaStatus", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateMediaStatus indicates an expected call of UpdateMediaStatus.
+func (mr *MockMediaStoreMockRecorder) UpdateMediaStatus(ctx, id, status any, events ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, id, status}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMediaStatus", reflect.TypeOf((*MockMediaStore)(nil).UpdateMediaStatus), varargs...)
+}
+
+// UpdateMediaVisibility mocks base method.
+func (m *MockMediaStore) UpdateMediaVisibility(ctx context.Context, id string, visibility domain.Visibility) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMediaVisibility", ctx, id, visibility)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateMediaVisibility indicates an expected call of UpdateMediaVisibility.
+func (mr *MockMediaStoreMockRecorder) UpdateMediaVisibility(ctx, id, visibility any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMediaVisibility", reflect.TypeOf((*MockMediaStore)(nil).UpdateMediaVisibility), ctx, id, visibility)
+}
+
+// MockTagStore is a mock of TagStore interface.
+type MockTagStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockTagStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockTagStoreMockRecorder is the mock recorder for MockTagStore.
+type MockTagStoreMockRecorder struct {
+	mock *MockTagStore
+}
+
+// NewMockTagStore creates a new mock instance.
+func NewMockTagStore(ctrl *gomock.Controller) *MockTagStore {
+	mock := &MockTagStore{ctrl: ctrl}
+	mock.recorder = &MockTagStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTagStore) EXPECT() *MockTagStoreMockRecorder {
+	return m.recorder
+}
+
+// DeleteTagEntries mocks base method.
+func (m *MockTagStore) DeleteTagEntries(ctx context.Context, mediaID string, tags []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTagEntries", ctx, mediaID, tags)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTagEntries indicates an expected call of DeleteTagEntries.
+func (mr *MockTagStoreMockRecorder) DeleteTagEntries(ctx, mediaID, tags any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTagEntries", reflect.TypeOf((*MockTagStore)(nil).DeleteTagEntries), ctx, mediaID, tags)
+}
+
+// ListMediaIDsByTag mocks base method.
+func (m *MockTagStore) ListMediaIDsByTag(ctx context.Context, tag string, limit int32, cursor string) ([]string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMediaIDsByTag", ctx, tag, limit, cursor)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListMediaIDsByTag indicates an expected call of ListMediaIDsByTag.
+func (mr *MockTagStoreMockRecorder) ListMediaIDsByTag(ctx, tag, limit, cursor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMediaIDsByTag", reflect.TypeOf((*MockTagStore)(nil).ListMediaIDsByTag), ctx, tag, limit, cursor)
+}
+
+// ListTagCounts mocks base method.
+func (m *MockTagStore) ListTagCounts(ctx context.Context) (map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTagCounts", ctx)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTagCounts indicates an expected call of ListTagCounts.
+func (mr *MockTagStoreMockRecorder) ListTagCounts(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTagCounts", reflect.TypeOf((*MockTagStore)(nil).ListTagCounts), ctx)
+}
+
+// PutTagEntries mocks base method.
+func (m *MockTagStore) PutTagEntries(ctx context.Context, mediaID string, tags []string, listed bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutTagEntries", ctx, mediaID, tags, listed)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutTagEntries indicates an expected call of PutTagEntries.
+func (mr *MockTagStoreMockRecorder) PutTagEntries(ctx, mediaID, tags, listed any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutTagEntries", reflect.TypeOf((*MockTagStore)(nil).PutTagEntries), ctx, mediaID, tags, listed)
+}
+
+// SetMediaTags mocks base method.
+func (m *MockTagStore) SetMediaTags(ctx context.Context, id string, tags map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMediaTags", ctx, id, tags)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMediaTags indicates an expected call of SetMediaTags.
+func (mr *MockTagStoreMockRecorder) SetMediaTags(ctx, id, tags any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMediaTags", reflect.TypeOf((*MockTagStore)(nil).SetMediaTags), ctx, id, tags)
+}
+
+// SetTagEntriesListed mocks base method.
+func (m *MockTagStore) SetTagEntriesListed(ctx context.Context, mediaID string, tags []string, listed bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTagEntriesListed", ctx, mediaID, tags, listed)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTagEntriesListed indicates an expected call of SetTagEntriesListed.
+func (mr *MockTagStoreMockRecorder) SetTagEntriesListed(ctx, mediaID, tags, listed any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTagEntriesListed", reflect.TypeOf((*MockTagStore)(nil).SetTagEntriesListed), ctx, mediaID, tags, listed)
+}
+
+// MockCoViewReader is a mock of CoViewReader interface.
+type MockCoViewReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockCoViewReaderMockRecorder
+	isgomock struct{}
+}
+
+// MockCoViewReaderMockRecorder is the mock recorder for MockCoViewReader.
+type MockCoViewReaderMockRecorder struct {
+	mock *MockCoViewReader
+}
+
+// NewMockCoViewReader creates a new mock instance.
+func NewMockCoViewReader(ctrl *gomock.Controller) *MockCoViewReader {
+	mock := &MockCoViewReader{ctrl: ctrl}
+	mock.recorder = &MockCoViewReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCoViewReader) EXPECT() *MockCoViewReaderMockRecorder {
+	return m.recorder
+}
+
+// GetCoViews mocks base method.
+func (m *MockCoViewReader) GetCoViews(ctx context.Context, mediaID string) (map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCoViews", ctx, mediaID)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCoViews indicates an expected call of GetCoViews.
+func (mr *MockCoViewReaderMockRecorder) GetCoViews(ctx, mediaID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCoViews", reflect.TypeOf((*MockCoViewReader)(nil).GetCoViews), ctx, mediaID)
+}
+
+// MockLikeStore is a mock of LikeStore interface.
+type MockLikeStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockLikeStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockLikeStoreMockRecorder is the mock recorder for MockLikeStore.
+type MockLikeStoreMockRecorder struct {
+	mock *MockLikeStore
+}
+
+// NewMockLikeStore creates a new mock instance.
+func NewMockLikeStore(ctrl *gomock.Controller) *MockLikeStore {
+	mock := &MockLikeStore{ctrl: ctrl}
+	mock.recorder = &MockLikeStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLikeStore) EXPECT() *MockLikeStoreMockRecorder {
+	return m.recorder
+}
+
+// DeleteLike mocks base method.
+func (m *MockLikeStore) DeleteLike(ctx context.Context, userID, mediaID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLike", ctx, userID, mediaID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteLike indicates an expected call of DeleteLike.
+func (mr *MockLikeStoreMockRecorder) DeleteLike(ctx, userID, mediaID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLike", reflect.TypeOf((*MockLikeStore)(nil).DeleteLike), ctx, userID, mediaID)
+}
+
+// HasLike mocks base method.
+func (m *MockLikeStore) HasLike(ctx context.Context, userID, mediaID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasLike", ctx, userID, mediaID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasLike indicates an expected call of HasLike.
+func (mr *MockLikeStoreMockRecorder) HasLike(ctx, userID, mediaID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasLike", reflect.TypeOf((*MockLikeStore)(nil).HasLike), ctx, userID, mediaID)
+}
+
+// ListLikedMediaIDs mocks base method.
+func (m *MockLikeStore) ListLikedMediaIDs(ctx context.Context, userID string, limit int32, cursor string) ([]string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLikedMediaIDs", ctx, userID, limit, cursor)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListLikedMediaIDs indicates an expected call of ListLikedMediaIDs.
+func (mr *MockLikeStoreMockRecorder) ListLikedMediaIDs(ctx, userID, limit, cursor any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLikedMediaIDs", reflect.TypeOf((*MockLikeStore)(nil).ListLikedMediaIDs), ctx, userID, limit, cursor)
+}
+
+// PutLike mocks base method.
+func (m *MockLikeStore) PutLike(ctx context.Context, userID, mediaID string, at time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutLike", ctx, userID, mediaID, at)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutLike indicates an expected call of PutLike.
+func (mr *MockLikeStoreMockRecorder) PutLike(ctx, userID, mediaID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutLike", reflect.TypeOf((*MockLikeStore)(nil).PutLike), ctx, userID, mediaID, at)
+}
+
+// MockLiveStreamReader is a mock of LiveStreamReader interface.
+type MockLiveStreamReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockLiveStreamReaderMockRecorder
+	isgomock struct{}
+}
+
+// MockLiveStreamReaderMockRecorder is the mock recorder for MockLiveStreamReader.
+type MockLiveStreamReaderMockRecorder struct {
+	mock *MockLiveStreamReader
+}
+
+// NewMockLiveStreamReader creates a new mock instance.
+func NewMockLiveStreamReader(ctrl *gomock.Controller) *MockLiveStreamReader {
+	mock := &MockLiveStreamReader{ctrl: ctrl}
+	mock.recorder = &MockLiveStreamReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLiveStreamReader) EXPECT() *MockLiveStreamReaderMockRecorder {
+	return m.recorder
+}
+
+// GetLiveStream mocks base method.
+func (m *MockLiveStreamReader) GetLiveStream(ctx context.Context, id string) (*domain.LiveStream, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLiveStream", ctx, id)
+	ret0, _ := ret[0].(*domain.LiveStream)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLiveStream indicates an expected call of GetLiveStream.
+func (mr *MockLiveStreamReaderMockRecorder) GetLiveStream(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLiveStream", reflect.TypeOf((*MockLiveStreamReader)(nil).GetLiveStream), ctx, id)
+}
@@ -0,0 +1,62 @@
+package rekognition
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+// Client wraps Amazon Rekognition's image moderation
+type Client struct {
+	client        *rekognition.Client
+	minConfidence float32
+}
+
+// NewClient creates a new Rekognition client reporting labels found with
+// at least minConfidence, from 0 to 100
+func NewClient(ctx context.Context, cfg appconfig.AWSConfig, minConfidence float64) (*Client, error) {
+	// Build AWS config
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client:        rekognition.NewFromConfig(awsCfg),
+		minConfidence: float32(minConfidence),
+	}, nil
+}
+
+// Name identifies Rekognition on moderation results
+func (c *Client) Name() string {
+	return "rekognition"
+}
+
+// ModerateImage returns the moderation labels found in a JPEG or PNG
+// image
+func (c *Client) ModerateImage(ctx context.Context, image []byte) ([]domain.ModerationLabel, error) {
+	result, err := c.client.DetectModerationLabels(ctx, &rekognition.DetectModerationLabelsInput{
+		Image:         &types.Image{Bytes: image},
+		MinConfidence: aws.Float32(c.minConfidence),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect moderation labels: %w", err)
+	}
+
+	labels := make([]domain.ModerationLabel, 0, len(result.ModerationLabels))
+	for _, l := range result.ModerationLabels {
+		labels = append(labels, domain.ModerationLabel{
+			Name:       aws.ToString(l.Name),
+			Parent:     aws.ToString(l.ParentName),
+			Confidence: float64(aws.ToFloat32(l.Confidence)),
+		})
+	}
+	return labels, nil
+}
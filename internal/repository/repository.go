@@ -0,0 +1,99 @@
+// Package repository defines the storage interfaces the services consume.
+// The S3 and DynamoDB clients implement them; the mocks package has
+// generated mocks for unit tests.
+package repository
+
+//go:generate go run go.uber.org/mock/mockgen@v0.6.0 -source=repository.go -destination=mocks/mocks.go -package=mocks
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// Uploader stores objects
+type Uploader interface {
+	// Upload stores an object in bucket
+	Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) error
+	// UploadRaw stores an object in the raw media bucket
+	UploadRaw(ctx context.Context, key string, body io.Reader, contentType string) error
+}
+
+// Downloader reads objects
+type Downloader interface {
+	// Download opens an object for reading; the caller closes it
+	Download(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	// Size returns the size of an object in bytes
+	Size(ctx context.Context, bucket, key string) (int64, error)
+}
+
+// ObjectStore is the object storage holding raw and processed media
+type ObjectStore interface {
+	Uploader
+	Downloader
+	Delete(ctx context.Context, bucket, key string) error
+	ListObjects(ctx context.Context, bucket, prefix string) ([]types.Object, error)
+	GetPresignedUploadURL(ctx context.Context, key string, contentType string, expiresIn time.Duration) (string, error)
+	// Multipart uploads to the raw media bucket, for sources too large to
+	// send in one request
+	CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error)
+	GetPresignedPartURL(ctx context.Context, key, uploadID string, partNumber int32, expiresIn time.Duration) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []types.CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+	GetRawBucket() string
+	GetProcessedBucket() string
+}
+
+// MediaStore reads and writes media records
+type MediaStore interface {
+	// CreateMedia, UpdateMediaStatus and DeleteMedia write any events to
+	// the outbox in the same transaction as the change
+	CreateMedia(ctx context.Context, media *domain.Media, events ...*domain.Event) error
+	GetMedia(ctx context.Context, id string) (*domain.Media, error)
+	BatchGetMedia(ctx context.Context, ids []string) ([]*domain.Media, error)
+	ListMediaByUser(ctx context.Context, userID string, filter *domain.MediaFilter, limit int32, cursor string) ([]*domain.Media, string, error)
+	ListMediaByChannel(ctx context.Context, channelID string, publicOnly bool, limit int32, cursor string) ([]*domain.Media, string, error)
+	UpdateMediaStatus(ctx context.Context, id string, status domain.MediaStatus, events ...*domain.Event) error
+	UpdateMediaProcessing(ctx context.Context, id string, progress *domain.ProcessingProgress) error
+	RetryMedia(ctx context.Context, id string, retries []domain.ProcessingRetry) error
+	UpdateMediaVisibility(ctx context.Context, id string, visibility domain.Visibility) error
+	UpdateMediaDetails(ctx context.Context, id string, title, description *string) error
+	SetMediaMetadata(ctx context.Context, id string, metadata *domain.SourceMetadata) error
+	SetMediaEncryption(ctx context.Context, id string, info *domain.EncryptionInfo) error
+	DeleteMedia(ctx context.Context, id string, events ...*domain.Event) error
+	ArchiveMedia(ctx context.Context, id string) error
+	SetMediaLegalHold(ctx context.Context, id string, hold *domain.LegalHold) error
+	SetMediaVersions(ctx context.Context, id string, versions []domain.OutputVersion, active, expected int) error
+}
+
+// TagStore keeps media tags and the tag index used to browse by tag
+type TagStore interface {
+	SetMediaTags(ctx context.Context, id string, tags map[string]string) error
+	PutTagEntries(ctx context.Context, mediaID string, tags []string, listed bool) error
+	DeleteTagEntries(ctx context.Context, mediaID string, tags []string) error
+	SetTagEntriesListed(ctx context.Context, mediaID string, tags []string, listed bool) error
+	ListMediaIDsByTag(ctx context.Context, tag string, limit int32, cursor string) ([]string, string, error)
+	ListTagCounts(ctx context.Context) (map[string]int64, error)
+}
+
+// CoViewReader reads how often media were viewed in the same sessions
+type CoViewReader interface {
+	GetCoViews(ctx context.Context, mediaID string) (map[string]int64, error)
+}
+
+// LikeStore keeps the media users liked
+type LikeStore interface {
+	PutLike(ctx context.Context, userID, mediaID string, at time.Time) (bool, error)
+	DeleteLike(ctx context.Context, userID, mediaID string) (bool, error)
+	HasLike(ctx context.Context, userID, mediaID string) (bool, error)
+	ListLikedMediaIDs(ctx context.Context, userID string, limit int32, cursor string) ([]string, string, error)
+}
+
+// LiveStreamReader reads live stream records
+type LiveStreamReader interface {
+	GetLiveStream(ctx context.Context, id string) (*domain.LiveStream, error)
+}
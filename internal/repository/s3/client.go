@@ -7,18 +7,46 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/awsconfig"
+	"github.com/streaming-service/internal/repository/s3/filesystem"
+	"github.com/streaming-service/pkg/metrics"
 )
 
+// api is the part of the S3 API the client uses, served by the AWS SDK or
+// by a filesystem store
+type api interface {
+	s3.ListObjectsV2APIClient
+	HeadBucket(ctx context.Context, in *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	CopyObject(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// presigner creates presigned object URLs
+type presigner interface {
+	PresignPutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignGetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignUploadPart(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// operationErrors counts failed S3 API calls
+var operationErrors = metrics.NewCounter("streaming_s3_operation_errors_total",
+	"S3 API calls that failed, by operation", "operation")
+
 // Client wraps the AWS S3 client
 type Client struct {
-	client          *s3.Client
-	presignClient   *s3.PresignClient
+	client          api
+	presignClient   presigner
 	rawBucket       string
 	processedBucket string
 }
@@ -26,26 +54,15 @@ type Client struct {
 // NewClient creates a new S3 client
 func NewClient(ctx context.Context, cfg appconfig.AWSConfig) (*Client, error) {
 	// Build AWS config
-	var opts []func(*config.LoadOptions) error
-	opts = append(opts, config.WithRegion(cfg.Region))
-
-	// Add credentials if provided
-	if cfg.AccessKeyID != "" && cfg.SecretAccessKey != "" {
-		opts = append(opts, config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(
-				cfg.AccessKeyID,
-				cfg.SecretAccessKey,
-				"",
-			),
-		))
-	}
-
-	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	awsCfg, err := awsconfig.Load(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, err
 	}
 
-	client := s3.NewFromConfig(awsCfg)
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, awsconfig.CircuitBreaker("S3", cfg))
+		o.UsePathStyle = cfg.S3UsePathStyle
+	})
 	presignClient := s3.NewPresignClient(client)
 
 	return &Client{
@@ -56,6 +73,36 @@ func NewClient(ctx context.Context, cfg appconfig.AWSConfig) (*Client, error) {
 	}, nil
 }
 
+// NewFilesystemClient creates a client that keeps the buckets in dir, for
+// running without AWS. Presigned URLs point at baseURL, where the returned
+// store's Handler must be served.
+func NewFilesystemClient(cfg appconfig.AWSConfig, dir, baseURL string) (*Client, *filesystem.Store, error) {
+	store, err := filesystem.New(dir, baseURL, cfg.S3RawBucket, cfg.S3ProcessedBucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Client{
+		client:          store,
+		presignClient:   store,
+		rawBucket:       cfg.S3RawBucket,
+		processedBucket: cfg.S3ProcessedBucket,
+	}, store, nil
+}
+
+// Ping checks that the raw and processed buckets are reachable
+func (c *Client) Ping(ctx context.Context) error {
+	for _, bucket := range []string{c.rawBucket, c.processedBucket} {
+		if _, err := c.client.HeadBucket(ctx, &s3.HeadBucketInput{
+			Bucket: aws.String(bucket),
+		}); err != nil {
+			operationErrors.Inc("head_bucket")
+			return fmt.Errorf("failed to head bucket %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
 // Upload uploads a file to S3
 func (c *Client) Upload(ctx context.Context, bucket, key string, body io.Reader, contentType string) error {
 	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
@@ -65,6 +112,23 @@ func (c *Client) Upload(ctx context.Context, bucket, key string, body io.Reader,
 		ContentType: aws.String(contentType),
 	})
 	if err != nil {
+		operationErrors.Inc("put_object")
+		return fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	return nil
+}
+
+// UploadWithCacheControl uploads a file to S3 with a Cache-Control header
+func (c *Client) UploadWithCacheControl(ctx context.Context, bucket, key string, body io.Reader, contentType, cacheControl string) error {
+	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		Body:         body,
+		ContentType:  aws.String(contentType),
+		CacheControl: aws.String(cacheControl),
+	})
+	if err != nil {
+		operationErrors.Inc("put_object")
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
 	return nil
@@ -87,11 +151,25 @@ func (c *Client) Download(ctx context.Context, bucket, key string) (io.ReadClose
 		Key:    aws.String(key),
 	})
 	if err != nil {
+		operationErrors.Inc("get_object")
 		return nil, fmt.Errorf("failed to download from S3: %w", err)
 	}
 	return result.Body, nil
 }
 
+// Size returns the size in bytes of an object
+func (c *Client) Size(ctx context.Context, bucket, key string) (int64, error) {
+	result, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		operationErrors.Inc("head_object")
+		return 0, fmt.Errorf("failed to head object: %w", err)
+	}
+	return aws.ToInt64(result.ContentLength), nil
+}
+
 // DownloadRaw downloads a file from the raw media bucket
 func (c *Client) DownloadRaw(ctx context.Context, key string) (io.ReadCloser, error) {
 	return c.Download(ctx, c.rawBucket, key)
@@ -109,6 +187,7 @@ func (c *Client) Delete(ctx context.Context, bucket, key string) error {
 		Key:    aws.String(key),
 	})
 	if err != nil {
+		operationErrors.Inc("delete_object")
 		return fmt.Errorf("failed to delete from S3: %w", err)
 	}
 	return nil
@@ -122,6 +201,7 @@ func (c *Client) GetPresignedUploadURL(ctx context.Context, key string, contentT
 		ContentType: aws.String(contentType),
 	}, s3.WithPresignExpires(expiresIn))
 	if err != nil {
+		operationErrors.Inc("presign_put_object")
 		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
 	}
 	return result.URL, nil
@@ -134,6 +214,7 @@ func (c *Client) GetPresignedDownloadURL(ctx context.Context, bucket, key string
 		Key:    aws.String(key),
 	}, s3.WithPresignExpires(expiresIn))
 	if err != nil {
+		operationErrors.Inc("presign_get_object")
 		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
 	}
 	return result.URL, nil
@@ -150,6 +231,7 @@ func (c *Client) ListObjects(ctx context.Context, bucket, prefix string) ([]type
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
+			operationErrors.Inc("list_objects")
 			return nil, fmt.Errorf("failed to list objects: %w", err)
 		}
 		objects = append(objects, page.Contents...)
@@ -166,6 +248,7 @@ func (c *Client) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, d
 		CopySource: aws.String(fmt.Sprintf("%s/%s", srcBucket, srcKey)),
 	})
 	if err != nil {
+		operationErrors.Inc("copy_object")
 		return fmt.Errorf("failed to copy object: %w", err)
 	}
 	return nil
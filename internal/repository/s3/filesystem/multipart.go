@@ -0,0 +1,234 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// uploadsDir holds the parts of multipart uploads in progress, a
+	// directory per upload under tmpDir
+	uploadsDir = "uploads"
+	// targetFile names the bucket and key an upload completes to
+	targetFile = "target"
+	// minPartSize is the least every part but the last must hold, as in S3
+	minPartSize = 5 << 20
+	// maxPartNumber is the highest part number S3 accepts
+	maxPartNumber = 10000
+)
+
+// uploadPath returns the directory of a multipart upload, which must
+// exist and complete to bucket and key
+func (s *Store) uploadPath(bucket, key, uploadID *string) (string, error) {
+	id := aws.ToString(uploadID)
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return "", &types.NoSuchUpload{Message: aws.String("no upload " + id)}
+	}
+	dir := filepath.Join(s.dir, tmpDir, uploadsDir, id)
+
+	target, err := os.ReadFile(filepath.Join(dir, targetFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", &types.NoSuchUpload{Message: aws.String("no upload " + id)}
+	}
+	if err != nil {
+		return "", err
+	}
+	if string(target) != aws.ToString(bucket)+"/"+aws.ToString(key) {
+		return "", &types.NoSuchUpload{Message: aws.String("no upload " + id + " for " + aws.ToString(key))}
+	}
+	return dir, nil
+}
+
+func partFile(dir string, number int32) string {
+	return filepath.Join(dir, fmt.Sprintf("part-%05d", number))
+}
+
+// CreateMultipartUpload starts a multipart upload
+func (s *Store) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if _, err := s.objectPath(in.Bucket, in.Key); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(b)
+
+	dir := filepath.Join(s.dir, tmpDir, uploadsDir, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	target := aws.ToString(in.Bucket) + "/" + aws.ToString(in.Key)
+	if err := os.WriteFile(filepath.Join(dir, targetFile), []byte(target), 0o644); err != nil {
+		return nil, err
+	}
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   in.Bucket,
+		Key:      in.Key,
+		UploadId: aws.String(id),
+	}, nil
+}
+
+// writePart stores a part of a multipart upload, replacing any earlier
+// upload of it, and returns its ETag, the quoted MD5 of its content as in
+// S3
+func (s *Store) writePart(bucket, key, uploadID *string, number int32, body io.Reader) (string, error) {
+	if number < 1 || number > maxPartNumber {
+		return "", fmt.Errorf("part number must be between 1 and %d", maxPartNumber)
+	}
+	dir, err := s.uploadPath(bucket, key, uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, tmpDir), "part-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), partFile(dir, number)); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// CompleteMultipartUpload assembles the listed parts, which must be in
+// order of part number and match the ETags their uploads returned, into
+// the upload's object
+func (s *Store) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	dir, err := s.uploadPath(in.Bucket, in.Key, in.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []types.CompletedPart
+	if in.MultipartUpload != nil {
+		parts = in.MultipartUpload.Parts
+	}
+	if len(parts) == 0 {
+		return nil, &smithy.GenericAPIError{Code: "InvalidRequest", Message: "no parts listed"}
+	}
+
+	files := make([]*os.File, 0, len(parts))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	readers := make([]io.Reader, 0, len(parts))
+	for i, part := range parts {
+		number := aws.ToInt32(part.PartNumber)
+		if i > 0 && number <= aws.ToInt32(parts[i-1].PartNumber) {
+			return nil, &smithy.GenericAPIError{Code: "InvalidPartOrder", Message: "parts must be listed in ascending order"}
+		}
+
+		f, err := os.Open(partFile(dir, number))
+		if err != nil {
+			return nil, &smithy.GenericAPIError{Code: "InvalidPart", Message: fmt.Sprintf("part %d was not uploaded", number)}
+		}
+		files = append(files, f)
+
+		hash := md5.New()
+		size, err := io.Copy(hash, f)
+		if err != nil {
+			return nil, err
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != strings.Trim(aws.ToString(part.ETag), `"`) {
+			return nil, &smithy.GenericAPIError{Code: "InvalidPart", Message: fmt.Sprintf("part %d does not match its ETag", number)}
+		}
+		if size < minPartSize && i < len(parts)-1 {
+			return nil, &smithy.GenericAPIError{Code: "EntityTooSmall", Message: fmt.Sprintf("part %d is smaller than 5MB", number)}
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		readers = append(readers, f)
+	}
+
+	if err := s.write(in.Bucket, in.Key, io.MultiReader(readers...)); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	return &s3.CompleteMultipartUploadOutput{Bucket: in.Bucket, Key: in.Key}, nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts
+func (s *Store) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	dir, err := s.uploadPath(in.Bucket, in.Key, in.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// PresignUploadPart returns a URL Handler accepts a part of a multipart
+// upload at
+func (s *Store) PresignUploadPart(ctx context.Context, in *s3.UploadPartInput, _ ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	if _, err := s.uploadPath(in.Bucket, in.Key, in.UploadId); err != nil {
+		return nil, err
+	}
+	query := url.Values{
+		"partNumber": {strconv.Itoa(int(aws.ToInt32(in.PartNumber)))},
+		"uploadId":   {aws.ToString(in.UploadId)},
+	}
+	return &v4.PresignedHTTPRequest{
+		URL:    s.url(in.Bucket, in.Key) + "?" + query.Encode(),
+		Method: http.MethodPut,
+	}, nil
+}
+
+// putPart serves the upload of a part to a presigned part URL
+func (s *Store) putPart(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	number, err := strconv.ParseInt(r.URL.Query().Get("partNumber"), 10, 32)
+	if err != nil {
+		http.Error(w, "invalid part number", http.StatusBadRequest)
+		return
+	}
+
+	uploadID := r.URL.Query().Get("uploadId")
+	etag, err := s.writePart(aws.String(bucket), aws.String(key), aws.String(uploadID), int32(number), r.Body)
+	if err != nil {
+		var noUpload *types.NoSuchUpload
+		if errors.As(err, &noUpload) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
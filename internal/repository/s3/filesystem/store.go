@@ -0,0 +1,343 @@
+// Package filesystem is a single-process stand-in for S3 that keeps each
+// bucket in a directory. It serves the subset of the S3 API the
+// repository uses, and an HTTP handler for the URLs it presigns, so the
+// service can run without AWS.
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// tmpDir holds objects while they are written, outside every bucket so
+// listings never see partial objects
+const tmpDir = ".tmp"
+
+// contentTypes covers the streaming formats mime doesn't know
+var contentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mpd":  "application/dash+xml",
+	".vtt":  "text/vtt",
+}
+
+// Store keeps objects at <dir>/<bucket>/<key>
+type Store struct {
+	dir     string
+	baseURL string
+}
+
+// New creates a store in dir with the given buckets. Presigned URLs point
+// at baseURL, where Handler should be served.
+func New(dir, baseURL string, buckets ...string) (*Store, error) {
+	for _, bucket := range append(buckets, tmpDir) {
+		if err := os.MkdirAll(filepath.Join(dir, bucket), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+		}
+	}
+	return &Store{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// bucketPath returns a bucket's directory
+func (s *Store) bucketPath(bucket *string) (string, error) {
+	name := aws.ToString(bucket)
+	dir := filepath.Join(s.dir, name)
+	if name == "" || name == tmpDir || strings.ContainsAny(name, `/\`) {
+		return "", &types.NoSuchBucket{Message: aws.String("invalid bucket " + name)}
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", &types.NoSuchBucket{Message: aws.String("no bucket " + name)}
+	}
+	return dir, nil
+}
+
+// objectPath returns the file of an object, refusing keys that would
+// escape the bucket
+func (s *Store) objectPath(bucket, key *string) (string, error) {
+	dir, err := s.bucketPath(bucket)
+	if err != nil {
+		return "", err
+	}
+	k := aws.ToString(key)
+	clean := path.Clean("/" + k)
+	if k == "" || clean == "/" || strings.HasSuffix(k, "/") {
+		return "", fmt.Errorf("invalid key %q", k)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// write stores body as an object, replacing it atomically
+func (s *Store) write(bucket, key *string, body io.Reader) error {
+	file, err := s.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, tmpDir), "object-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if body != nil {
+		if _, err := io.Copy(tmp, body); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// HeadBucket checks that a bucket exists
+func (s *Store) HeadBucket(ctx context.Context, in *s3.HeadBucketInput, _ ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if _, err := s.bucketPath(in.Bucket); err != nil {
+		return nil, err
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+// PutObject stores an object
+func (s *Store) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if err := s.write(in.Bucket, in.Key, in.Body); err != nil {
+		return nil, err
+	}
+	return &s3.PutObjectOutput{}, nil
+}
+
+// GetObject opens an object
+func (s *Store) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	file, err := s.objectPath(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &types.NoSuchKey{Message: aws.String("no object " + aws.ToString(in.Key))}
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &s3.GetObjectOutput{
+		Body:          f,
+		ContentLength: aws.Int64(info.Size()),
+		ContentType:   aws.String(contentType(file)),
+		LastModified:  aws.Time(info.ModTime()),
+	}, nil
+}
+
+// HeadObject describes an object
+func (s *Store) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	file, err := s.objectPath(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		return nil, &types.NotFound{Message: aws.String("no object " + aws.ToString(in.Key))}
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(info.Size()),
+		ContentType:   aws.String(contentType(file)),
+		LastModified:  aws.Time(info.ModTime()),
+	}, nil
+}
+
+// DeleteObject removes an object. Like S3, deleting a missing object
+// succeeds.
+func (s *Store) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	file, err := s.objectPath(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// CopyObject copies an object named by a "bucket/key" source
+func (s *Store) CopyObject(ctx context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	srcBucket, srcKey, ok := strings.Cut(strings.TrimPrefix(aws.ToString(in.CopySource), "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid copy source %q", aws.ToString(in.CopySource))
+	}
+	src, err := s.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(srcBucket), Key: aws.String(srcKey)})
+	if err != nil {
+		return nil, err
+	}
+	defer src.Body.Close()
+
+	if err := s.write(in.Bucket, in.Key, src.Body); err != nil {
+		return nil, err
+	}
+	return &s3.CopyObjectOutput{}, nil
+}
+
+// ListObjectsV2 lists every object with a prefix in one page
+func (s *Store) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	dir, err := s.bucketPath(in.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	prefix := aws.ToString(in.Prefix)
+
+	var objects []types.Object
+	err = filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, types.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(info.Size()),
+			LastModified: aws.Time(info.ModTime()),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return *objects[i].Key < *objects[j].Key })
+	return &s3.ListObjectsV2Output{
+		Contents:    objects,
+		KeyCount:    aws.Int32(int32(len(objects))),
+		IsTruncated: aws.Bool(false),
+	}, nil
+}
+
+// PresignPutObject returns a URL Handler accepts uploads at. URLs are not
+// signed: the store is for local use only.
+func (s *Store) PresignPutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	if _, err := s.objectPath(in.Bucket, in.Key); err != nil {
+		return nil, err
+	}
+	return &v4.PresignedHTTPRequest{
+		URL:    s.url(in.Bucket, in.Key),
+		Method: http.MethodPut,
+	}, nil
+}
+
+// PresignGetObject returns a URL Handler serves an object at
+func (s *Store) PresignGetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	if _, err := s.objectPath(in.Bucket, in.Key); err != nil {
+		return nil, err
+	}
+	return &v4.PresignedHTTPRequest{
+		URL:    s.url(in.Bucket, in.Key),
+		Method: http.MethodGet,
+	}, nil
+}
+
+func (s *Store) url(bucket, key *string) string {
+	return fmt.Sprintf("%s/%s/%s", s.baseURL, aws.ToString(bucket), aws.ToString(key))
+}
+
+// Handler serves GET and PUT of /<bucket>/<key>, and PUT of the parts of
+// multipart uploads, the URLs the store presigns. Mount it at the store's
+// base URL with the prefix stripped.
+func (s *Store) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket, key, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			file, err := s.objectPath(aws.String(bucket), aws.String(key))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			defer f.Close()
+			info, err := f.Stat()
+			if err != nil || info.IsDir() {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", contentType(file))
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			http.ServeContent(w, r, "", info.ModTime(), f)
+
+		case http.MethodPut:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
+			if r.URL.Query().Has("uploadId") {
+				s.putPart(w, r, bucket, key)
+				return
+			}
+			if err := s.write(aws.String(bucket), aws.String(key), r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+
+		case http.MethodOptions:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, PUT")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// contentType guesses an object's type from its extension
+func contentType(file string) string {
+	ext := strings.ToLower(filepath.Ext(file))
+	if t, ok := contentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,230 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/awsconfig"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// migrateWaitTimeout bounds waiting for a new bucket to become visible
+const migrateWaitTimeout = 2 * time.Minute
+
+// rawLifecycleRules are the raw bucket's lifecycle rules, matching
+// deployments/terraform/s3.tf
+var rawLifecycleRules = []types.LifecycleRule{
+	{
+		ID:     aws.String("cleanup-incomplete-uploads"),
+		Status: types.ExpirationStatusEnabled,
+		Filter: &types.LifecycleRuleFilter{Prefix: aws.String("")},
+		AbortIncompleteMultipartUpload: &types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int32(7),
+		},
+	},
+	{
+		ID:     aws.String("transition-to-glacier"),
+		Status: types.ExpirationStatusEnabled,
+		Filter: &types.LifecycleRuleFilter{Prefix: aws.String("")},
+		Transitions: []types.Transition{{
+			Days:         aws.Int32(90),
+			StorageClass: types.TransitionStorageClassGlacier,
+		}},
+	},
+}
+
+// rawCORSRules let browsers upload to the raw bucket with presigned URLs
+var rawCORSRules = []types.CORSRule{{
+	AllowedHeaders: []string{"*"},
+	AllowedMethods: []string{"GET", "PUT", "POST"},
+	AllowedOrigins: []string{"*"},
+	ExposeHeaders:  []string{"ETag"},
+	MaxAgeSeconds:  aws.Int32(3000),
+}}
+
+// Migrator creates the buckets the service needs and applies the rules
+// they are missing
+type Migrator struct {
+	client          *s3.Client
+	region          string
+	rawBucket       string
+	processedBucket string
+	log             *logger.Logger
+}
+
+// NewMigrator creates a new bucket migrator
+func NewMigrator(ctx context.Context, cfg appconfig.AWSConfig, log *logger.Logger) (*Migrator, error) {
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		client: s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+			o.UsePathStyle = cfg.S3UsePathStyle
+		}),
+		region:          cfg.Region,
+		rawBucket:       cfg.S3RawBucket,
+		processedBucket: cfg.S3ProcessedBucket,
+		log:             log,
+	}, nil
+}
+
+// Migrate creates missing buckets with versioning and encryption, and
+// gives the raw bucket its CORS and lifecycle rules. Existing CORS rules
+// are left alone, and lifecycle rules are only added to. With dryRun set
+// it only reports what it would change.
+func (m *Migrator) Migrate(ctx context.Context, dryRun bool) error {
+	for _, bucket := range []string{m.rawBucket, m.processedBucket} {
+		if err := m.migrateBucket(ctx, bucket, dryRun); err != nil {
+			return fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+	}
+	if err := m.migrateCORS(ctx, m.rawBucket, dryRun); err != nil {
+		return fmt.Errorf("bucket %s: %w", m.rawBucket, err)
+	}
+	if err := m.migrateLifecycle(ctx, m.rawBucket, dryRun); err != nil {
+		return fmt.Errorf("bucket %s: %w", m.rawBucket, err)
+	}
+	return nil
+}
+
+func (m *Migrator) migrateBucket(ctx context.Context, bucket string, dryRun bool) error {
+	_, err := m.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return nil
+	}
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to head bucket: %w", err)
+	}
+
+	if dryRun {
+		m.log.Info("would create bucket", "bucket", bucket)
+		return nil
+	}
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	// us-east-1 is the default location and can't be named
+	if m.region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(m.region),
+		}
+	}
+	if _, err := m.client.CreateBucket(ctx, input); err != nil {
+		return fmt.Errorf("failed to create bucket: %w", err)
+	}
+	if err := s3.NewBucketExistsWaiter(m.client).Wait(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}, migrateWaitTimeout); err != nil {
+		return fmt.Errorf("waiting for bucket: %w", err)
+	}
+
+	if _, err := m.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucket),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	}); err != nil {
+		return fmt.Errorf("failed to enable versioning: %w", err)
+	}
+	if _, err := m.client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: aws.String(bucket),
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{
+					SSEAlgorithm: types.ServerSideEncryptionAes256,
+				},
+			}},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to enable encryption: %w", err)
+	}
+
+	m.log.Info("created bucket", "bucket", bucket)
+	return nil
+}
+
+// migrateCORS sets CORS rules on a bucket that has none
+func (m *Migrator) migrateCORS(ctx context.Context, bucket string, dryRun bool) error {
+	_, err := m.client.GetBucketCors(ctx, &s3.GetBucketCorsInput{Bucket: aws.String(bucket)})
+	switch {
+	case err == nil:
+		return nil
+	case !isErrorCode(err, "NoSuchCORSConfiguration", "NoSuchBucket"):
+		return fmt.Errorf("failed to get CORS rules: %w", err)
+	case dryRun:
+		m.log.Info("would set CORS rules", "bucket", bucket)
+		return nil
+	}
+
+	if _, err := m.client.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+		Bucket:            aws.String(bucket),
+		CORSConfiguration: &types.CORSConfiguration{CORSRules: rawCORSRules},
+	}); err != nil {
+		return fmt.Errorf("failed to set CORS rules: %w", err)
+	}
+	m.log.Info("set CORS rules", "bucket", bucket)
+	return nil
+}
+
+// migrateLifecycle adds the lifecycle rules a bucket is missing, by ID,
+// keeping any others
+func (m *Migrator) migrateLifecycle(ctx context.Context, bucket string, dryRun bool) error {
+	var rules []types.LifecycleRule
+	result, err := m.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	switch {
+	case err == nil:
+		rules = result.Rules
+	case !isErrorCode(err, "NoSuchLifecycleConfiguration", "NoSuchBucket"):
+		return fmt.Errorf("failed to get lifecycle rules: %w", err)
+	}
+
+	existing := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		existing[aws.ToString(rule.ID)] = true
+	}
+	var missing []string
+	for _, rule := range rawLifecycleRules {
+		if !existing[aws.ToString(rule.ID)] {
+			rules = append(rules, rule)
+			missing = append(missing, aws.ToString(rule.ID))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if dryRun {
+		m.log.Info("would add lifecycle rules", "bucket", bucket, "rules", missing)
+		return nil
+	}
+	if _, err := m.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+	}); err != nil {
+		return fmt.Errorf("failed to set lifecycle rules: %w", err)
+	}
+	m.log.Info("added lifecycle rules", "bucket", bucket, "rules", missing)
+	return nil
+}
+
+// isErrorCode reports whether err is an S3 API error with one of codes
+func isErrorCode(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// multipartError maps the errors of a multipart upload the caller can fix
+// to domain errors
+func multipartError(err error) error {
+	switch {
+	case isErrorCode(err, "NoSuchUpload"):
+		return domain.ErrUploadNotFound
+	case isErrorCode(err, "InvalidPart", "InvalidPartOrder", "EntityTooSmall"):
+		return domain.ErrInvalidInput
+	}
+	return nil
+}
+
+// CreateMultipartUpload starts a multipart upload to the raw bucket,
+// returning its upload ID
+func (c *Client) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	result, err := c.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(c.rawBucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		operationErrors.Inc("create_multipart_upload")
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return aws.ToString(result.UploadId), nil
+}
+
+// GetPresignedPartURL generates a presigned URL for uploading a part of a
+// multipart upload. S3 returns the part's ETag, needed to complete the
+// upload, in the response's ETag header.
+func (c *Client) GetPresignedPartURL(ctx context.Context, key, uploadID string, partNumber int32, expiresIn time.Duration) (string, error) {
+	result, err := c.presignClient.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(c.rawBucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+	}, s3.WithPresignExpires(expiresIn))
+	if err != nil {
+		operationErrors.Inc("presign_upload_part")
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+	return result.URL, nil
+}
+
+// CompleteMultipartUpload assembles the parts of a multipart upload, in
+// order of part number, into its object. It returns
+// domain.ErrUploadNotFound for an upload that was completed, aborted or
+// never started, and domain.ErrInvalidInput for parts that weren't
+// uploaded as listed or, but for the last, are under 5MB.
+func (c *Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []types.CompletedPart) error {
+	_, err := c.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.rawBucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		if mapped := multipartError(err); mapped != nil {
+			return mapped
+		}
+		operationErrors.Inc("complete_multipart_upload")
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts. It
+// returns domain.ErrUploadNotFound for an upload that was completed,
+// aborted or never started.
+func (c *Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, err := c.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(c.rawBucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		if mapped := multipartError(err); mapped != nil {
+			return mapped
+		}
+		operationErrors.Inc("abort_multipart_upload")
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+const (
+	// secretsManagerPrefix and ssmPrefix mark references by name or path
+	secretsManagerPrefix = "secretsmanager:"
+	ssmPrefix            = "ssm:"
+	// secretsManagerARN and ssmARN mark references by ARN
+	secretsManagerARN = "arn:aws:secretsmanager:"
+	ssmARN            = "arn:aws:ssm:"
+)
+
+// Client resolves secrets held in AWS Secrets Manager and SSM Parameter
+// Store
+type Client struct {
+	secretsManager *secretsmanager.Client
+	ssm            *ssm.Client
+}
+
+// NewClient creates a new secrets client
+func NewClient(ctx context.Context, cfg appconfig.AWSConfig) (*Client, error) {
+	// Build AWS config
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		secretsManager: secretsmanager.NewFromConfig(awsCfg),
+		ssm:            ssm.NewFromConfig(awsCfg),
+	}, nil
+}
+
+// ResolveConfig resolves the references among cfg's secrets, connecting to
+// AWS only if there are any
+func ResolveConfig(ctx context.Context, cfg *appconfig.Config) error {
+	settings := cfg.Secrets()
+
+	var refs []*string
+	for _, setting := range settings {
+		if IsReference(*setting) {
+			refs = append(refs, setting)
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	client, err := NewClient(ctx, cfg.AWS)
+	if err != nil {
+		return err
+	}
+	return client.Resolve(ctx, refs...)
+}
+
+// IsReference returns true if value refers to a secret rather than holding
+// one. References take the forms:
+//
+//	arn:aws:secretsmanager:<region>:<account>:secret:<name>[#<json key>]
+//	secretsmanager:<name>[#<json key>]
+//	arn:aws:ssm:<region>:<account>:parameter/<path>
+//	ssm:/<path>
+func IsReference(value string) bool {
+	for _, prefix := range []string{secretsManagerPrefix, ssmPrefix, secretsManagerARN, ssmARN} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve replaces each setting holding a reference with the secret it
+// refers to. Settings holding anything else are left as they are.
+func (c *Client) Resolve(ctx context.Context, settings ...*string) error {
+	for _, setting := range settings {
+		if !IsReference(*setting) {
+			continue
+		}
+
+		value, err := c.lookup(ctx, *setting)
+		if err != nil {
+			return err
+		}
+		*setting = value
+	}
+	return nil
+}
+
+// lookup fetches the secret a reference refers to
+func (c *Client) lookup(ctx context.Context, ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, ssmARN):
+		return c.parameter(ctx, ref)
+	case strings.HasPrefix(ref, ssmPrefix):
+		return c.parameter(ctx, strings.TrimPrefix(ref, ssmPrefix))
+	case strings.HasPrefix(ref, secretsManagerPrefix):
+		return c.secret(ctx, strings.TrimPrefix(ref, secretsManagerPrefix))
+	default:
+		return c.secret(ctx, ref)
+	}
+}
+
+// parameter fetches a Parameter Store value, decrypting SecureStrings
+func (c *Client) parameter(ctx context.Context, name string) (string, error) {
+	result, err := c.ssm.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get parameter %s: %w", name, err)
+	}
+	return aws.ToString(result.Parameter.Value), nil
+}
+
+// secret fetches a Secrets Manager secret. A "#key" suffix selects one
+// field of a JSON secret.
+func (c *Client) secret(ctx context.Context, id string) (string, error) {
+	id, key, _ := strings.Cut(id, "#")
+
+	result, err := c.secretsManager.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", id, err)
+	}
+
+	value := aws.ToString(result.SecretString)
+	if key == "" {
+		return value, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", id, err)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", id, key)
+	}
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(field), nil
+}
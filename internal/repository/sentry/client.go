@@ -0,0 +1,85 @@
+package sentry
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap/zapcore"
+
+	appconfig "github.com/streaming-service/internal/config"
+)
+
+// tagFields are log fields sent as searchable tags rather than extra data
+var tagFields = map[string]bool{
+	"request_id": true,
+	"job_id":     true,
+	"media_id":   true,
+	"stream_id":  true,
+	"user_id":    true,
+	"worker_id":  true,
+}
+
+// Client reports error log entries to Sentry or a compatible backend. It
+// implements logger.Reporter.
+type Client struct {
+	hub *sentry.Hub
+}
+
+// NewClient creates a new Sentry client for cfg.DSN
+func NewClient(cfg appconfig.ErrorReportingConfig, app appconfig.AppConfig) (*Client, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: app.Environment,
+		Release:     app.Name + "@" + app.Version,
+		SampleRate:  cfg.SampleRate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sentry client: %w", err)
+	}
+
+	return &Client{hub: sentry.NewHub(client, sentry.NewScope())}, nil
+}
+
+// Report sends a log entry as an event. The request, job and media IDs
+// among its fields become tags; its error, if any, the exception.
+func (c *Client) Report(entry zapcore.Entry, fields map[string]interface{}) {
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	if entry.Level > zapcore.ErrorLevel {
+		event.Level = sentry.LevelFatal
+	}
+	event.Message = entry.Message
+	event.Timestamp = entry.Time
+	if entry.Caller.Defined {
+		event.Extra["caller"] = entry.Caller.TrimmedPath()
+	}
+
+	for key, value := range fields {
+		if tagFields[key] {
+			event.Tags[key] = fmt.Sprint(value)
+			continue
+		}
+		event.Extra[key] = value
+	}
+
+	// Events are grouped by log message, so one failure mode with varying
+	// error text is a single issue
+	exception := sentry.Exception{
+		Type:       entry.Message,
+		Stacktrace: sentry.NewStacktrace(),
+	}
+	if err, ok := fields["error"]; ok {
+		exception.Value = fmt.Sprint(err)
+	} else if p, ok := fields["panic"]; ok {
+		exception.Value = fmt.Sprint(p)
+	}
+	event.Exception = []sentry.Exception{exception}
+
+	c.hub.CaptureEvent(event)
+}
+
+// Flush waits up to timeout for queued events to be sent
+func (c *Client) Flush(timeout time.Duration) bool {
+	return c.hub.Flush(timeout)
+}
@@ -0,0 +1,55 @@
+package ses
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+// Client wraps Amazon SES
+type Client struct {
+	client *sesv2.Client
+	from   string
+}
+
+// NewClient creates a new Amazon SES client sending from the given
+// address, which must be verified with SES
+func NewClient(ctx context.Context, cfg appconfig.AWSConfig, from string) (*Client, error) {
+	// Build AWS config
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client: sesv2.NewFromConfig(awsCfg),
+		from:   from,
+	}, nil
+}
+
+// Send sends a plain text email
+func (c *Client) Send(ctx context.Context, to, subject, body string) error {
+	_, err := c.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(c.from),
+		Destination:      &types.Destination{ToAddresses: []string{to}},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String(subject), Charset: aws.String("UTF-8")},
+				Body: &types.Body{
+					Text: &types.Content{Data: aws.String(body), Charset: aws.String("UTF-8")},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+// Package smtp sends email through an SMTP server
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	appconfig "github.com/streaming-service/internal/config"
+)
+
+// Client sends email through an SMTP server
+type Client struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// NewClient creates a new SMTP client sending from the given address. It
+// authenticates when a username is configured.
+func NewClient(cfg appconfig.SMTPConfig, from string) *Client {
+	c := &Client{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		host: cfg.Host,
+		from: from,
+	}
+	if cfg.Username != "" {
+		c.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return c
+}
+
+// Send sends a plain text email. net/smtp can't be cancelled, so ctx is
+// only checked before sending.
+func (c *Client) Send(ctx context.Context, to, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", c.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(c.addr, c.auth, c.from, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package sns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+// Client wraps Amazon SNS
+type Client struct {
+	client   *sns.Client
+	topicARN string
+}
+
+// NewClient creates a new SNS client publishing to the configured topic
+func NewClient(ctx context.Context, cfg appconfig.AWSConfig, events appconfig.EventsConfig) (*Client, error) {
+	// Build AWS config
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client:   sns.NewFromConfig(awsCfg),
+		topicARN: events.TopicARN,
+	}, nil
+}
+
+// PublishEvent publishes an event to the topic as JSON. Its type is also
+// set as the "type" message attribute, so subscriptions can filter on it.
+func (c *Client) PublishEvent(ctx context.Context, event *domain.Event) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	_, err = c.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(c.topicARN),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"type": {DataType: aws.String("String"), StringValue: aws.String(string(event.Type))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
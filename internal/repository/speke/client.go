@@ -0,0 +1,174 @@
+// Package speke is a minimal client for SPEKE key servers, which hand out
+// Common Encryption content keys and the DRM systems' protection headers
+// for them in CPIX documents
+package speke
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+)
+
+// CPIX namespaces
+const (
+	cpixNamespace = "urn:dashif:org:cpix"
+	pskcNamespace = "urn:ietf:params:xml:ns:keyprov:pskc"
+)
+
+// Client requests content keys from a SPEKE endpoint
+type Client struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// ContentKey is a plaintext content key with the PSSH box of each DRM
+// system it's licensed by
+type ContentKey struct {
+	// KeyID is the key's ID as a UUID
+	KeyID string
+	Key   []byte
+	PSSH  map[domain.DRMSystem][]byte
+}
+
+// cpixRequest asks for one content key and its DRM systems' PSSH boxes.
+// Prefixed names are written as they are, as SPEKE servers expect.
+type cpixRequest struct {
+	XMLName     xml.Name         `xml:"cpix:CPIX"`
+	ID          string           `xml:"id,attr"`
+	CPIX        string           `xml:"xmlns:cpix,attr"`
+	PSKC        string           `xml:"xmlns:pskc,attr"`
+	ContentKeys []cpixRequestKey `xml:"cpix:ContentKeyList>cpix:ContentKey"`
+	DRMSystems  []cpixRequestDRM `xml:"cpix:DRMSystemList>cpix:DRMSystem"`
+}
+
+type cpixRequestKey struct {
+	KID string `xml:"kid,attr"`
+}
+
+type cpixRequestDRM struct {
+	KID      string `xml:"kid,attr"`
+	SystemID string `xml:"systemId,attr"`
+	PSSH     string `xml:"cpix:PSSH"`
+}
+
+// cpixResponse is the part of a key server's CPIX reply that is read
+type cpixResponse struct {
+	ContentKeys []struct {
+		KID  string `xml:"kid,attr"`
+		Data struct {
+			Secret struct {
+				PlainValue string `xml:"PlainValue"`
+			} `xml:"Secret"`
+		} `xml:"Data"`
+	} `xml:"ContentKeyList>ContentKey"`
+	DRMSystems []struct {
+		KID      string `xml:"kid,attr"`
+		SystemID string `xml:"systemId,attr"`
+		PSSH     string `xml:"PSSH"`
+	} `xml:"DRMSystemList>DRMSystem"`
+}
+
+// NewClient creates a new SPEKE client
+func NewClient(cfg config.DRMConfig) *Client {
+	return &Client{
+		url:        cfg.KeyServerURL,
+		token:      cfg.KeyServerToken,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// GetKey requests a new content key for a resource, such as a media item,
+// licensed by systems
+func (c *Client) GetKey(ctx context.Context, resourceID string, systems []domain.DRMSystem) (*ContentKey, error) {
+	kid := uuid.New().String()
+	in := cpixRequest{
+		ID:          resourceID,
+		CPIX:        cpixNamespace,
+		PSKC:        pskcNamespace,
+		ContentKeys: []cpixRequestKey{{KID: kid}},
+	}
+	for _, system := range systems {
+		in.DRMSystems = append(in.DRMSystems, cpixRequestDRM{KID: kid, SystemID: system.SystemID()})
+	}
+	data, err := xml.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(append([]byte(xml.Header), data...)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request content key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key server response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key server returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var out cpixResponse
+	if err := xml.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode key server response: %w", err)
+	}
+	return parseKey(&out, kid, systems)
+}
+
+// parseKey reads the content key with ID kid, and its systems' PSSH
+// boxes, from a response
+func parseKey(out *cpixResponse, kid string, systems []domain.DRMSystem) (*ContentKey, error) {
+	key := &ContentKey{KeyID: kid, PSSH: make(map[domain.DRMSystem][]byte, len(systems))}
+	for _, k := range out.ContentKeys {
+		if !strings.EqualFold(k.KID, kid) {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(k.Data.Secret.PlainValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content key: %w", err)
+		}
+		key.Key = value
+	}
+	if len(key.Key) != 16 {
+		return nil, fmt.Errorf("key server returned no 128-bit key for %s", kid)
+	}
+
+	for _, system := range systems {
+		for _, d := range out.DRMSystems {
+			if !strings.EqualFold(d.KID, kid) || !strings.EqualFold(d.SystemID, system.SystemID()) {
+				continue
+			}
+			pssh, err := base64.StdEncoding.DecodeString(d.PSSH)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s PSSH: %w", system, err)
+			}
+			key.PSSH[system] = pssh
+		}
+		if len(key.PSSH[system]) == 0 {
+			return nil, fmt.Errorf("key server returned no %s PSSH for %s", system, kid)
+		}
+	}
+
+	return key, nil
+}
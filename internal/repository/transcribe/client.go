@@ -0,0 +1,190 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/transcribe"
+	"github.com/aws/aws-sdk-go-v2/service/transcribe/types"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+// Client wraps Amazon Transcribe
+type Client struct {
+	client *transcribe.Client
+	http   *http.Client
+	cfg    appconfig.TranscribeConfig
+}
+
+// NewClient creates a new Amazon Transcribe client
+func NewClient(ctx context.Context, awsCfg appconfig.AWSConfig, cfg appconfig.TranscribeConfig) (*Client, error) {
+	loaded, err := awsconfig.Load(ctx, awsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client: transcribe.NewFromConfig(loaded),
+		http:   &http.Client{Timeout: time.Minute},
+		cfg:    cfg,
+	}, nil
+}
+
+// transcriptFile is the part of a Transcribe transcript that is read
+type transcriptFile struct {
+	Results struct {
+		Items []struct {
+			Type         string `json:"type"`
+			StartTime    string `json:"start_time"`
+			EndTime      string `json:"end_time"`
+			Alternatives []struct {
+				Content string `json:"content"`
+			} `json:"alternatives"`
+		} `json:"items"`
+	} `json:"results"`
+}
+
+// Transcribe transcribes the FLAC audio at an S3 URI, waiting for the job
+// to finish. The speech's language is the configured one, or identified
+// from the audio when none is. The job is named name, which must be
+// unique, and deleted once its transcript is read.
+func (c *Client) Transcribe(ctx context.Context, name, mediaURI string) (*domain.Transcript, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	input := &transcribe.StartTranscriptionJobInput{
+		TranscriptionJobName: aws.String(name),
+		Media:                &types.Media{MediaFileUri: aws.String(mediaURI)},
+		MediaFormat:          types.MediaFormatFlac,
+	}
+	if c.cfg.Language != "" {
+		input.LanguageCode = types.LanguageCode(c.cfg.Language)
+	} else {
+		input.IdentifyLanguage = aws.Bool(true)
+	}
+	if _, err := c.client.StartTranscriptionJob(ctx, input); err != nil {
+		return nil, fmt.Errorf("failed to start transcription: %w", err)
+	}
+	defer func() {
+		// The job is deleted even if waiting was cut short
+		deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		_, _ = c.client.DeleteTranscriptionJob(deleteCtx, &transcribe.DeleteTranscriptionJobInput{
+			TranscriptionJobName: aws.String(name),
+		})
+	}()
+
+	job, err := c.wait(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if job.Transcript == nil || job.Transcript.TranscriptFileUri == nil {
+		return nil, fmt.Errorf("transcription %s has no transcript", name)
+	}
+
+	file, err := c.fetch(ctx, aws.ToString(job.Transcript.TranscriptFileUri))
+	if err != nil {
+		return nil, err
+	}
+	transcript, err := parseTranscript(file)
+	if err != nil {
+		return nil, err
+	}
+	transcript.Language = string(job.LanguageCode)
+	return transcript, nil
+}
+
+// wait polls a transcription job until it completes or fails
+func (c *Client) wait(ctx context.Context, name string) (*types.TranscriptionJob, error) {
+	ticker := time.NewTicker(c.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := c.client.GetTranscriptionJob(ctx, &transcribe.GetTranscriptionJobInput{
+			TranscriptionJobName: aws.String(name),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transcription: %w", err)
+		}
+		job := result.TranscriptionJob
+		switch job.TranscriptionJobStatus {
+		case types.TranscriptionJobStatusCompleted:
+			return job, nil
+		case types.TranscriptionJobStatusFailed:
+			return nil, fmt.Errorf("transcription failed: %s", aws.ToString(job.FailureReason))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("transcription did not finish: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetch downloads a transcript from the presigned URL Transcribe gives
+func (c *Client) fetch(ctx context.Context, url string) (*transcriptFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcript request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download transcript: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download transcript: status %d", resp.StatusCode)
+	}
+
+	var file transcriptFile
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode transcript: %w", err)
+	}
+	return &file, nil
+}
+
+// parseTranscript turns a transcript's items into words, taking the most
+// likely alternative of each
+func parseTranscript(file *transcriptFile) (*domain.Transcript, error) {
+	transcript := &domain.Transcript{}
+	for _, item := range file.Results.Items {
+		if len(item.Alternatives) == 0 {
+			continue
+		}
+		word := domain.TranscriptWord{
+			Text:        item.Alternatives[0].Content,
+			Punctuation: item.Type == "punctuation",
+		}
+		if !word.Punctuation {
+			start, err := parseSeconds(item.StartTime)
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseSeconds(item.EndTime)
+			if err != nil {
+				return nil, err
+			}
+			word.Start, word.End = start, end
+		}
+		transcript.Words = append(transcript.Words, word)
+	}
+	return transcript, nil
+}
+
+// parseSeconds parses a transcript time, in seconds
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid transcript time %q: %w", s, err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,55 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/translate"
+
+	appconfig "github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/repository/awsconfig"
+)
+
+// Client wraps Amazon Translate
+type Client struct {
+	client *translate.Client
+}
+
+// NewClient creates a new Amazon Translate client
+func NewClient(ctx context.Context, cfg appconfig.AWSConfig) (*Client, error) {
+	// Build AWS config
+	awsCfg, err := awsconfig.Load(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client: translate.NewFromConfig(awsCfg),
+	}, nil
+}
+
+// Translate translates texts from the source language to the target one
+// at a time, as caption cues are short and must stay apart. Blank texts
+// are kept as they are.
+func (c *Client) Translate(ctx context.Context, texts []string, source, target string) ([]string, error) {
+	translated := make([]string, len(texts))
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			translated[i] = text
+			continue
+		}
+
+		result, err := c.client.TranslateText(ctx, &translate.TranslateTextInput{
+			Text:               aws.String(text),
+			SourceLanguageCode: aws.String(source),
+			TargetLanguageCode: aws.String(target),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate text: %w", err)
+		}
+		translated[i] = aws.ToString(result.TranslatedText)
+	}
+	return translated, nil
+}
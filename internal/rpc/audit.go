@@ -0,0 +1,125 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/streaming-service/internal/auth"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/audit"
+	"github.com/streaming-service/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// callState collects what the interceptors inside the audit one learn
+// about a call for its audit entry: who made it and for which tenant
+type callState struct {
+	claims   *auth.Claims
+	tenantID string
+}
+
+type callStateKey struct{}
+
+// SetAudit records an audit entry for every call of a method needing a
+// write scope, as the REST API does for its mutating calls
+func (s *Server) SetAudit(svc *audit.Service) {
+	s.audit = svc
+}
+
+// audited reports whether calls of a method requiring scope mutate, and
+// so are audited
+func audited(scope string) bool {
+	return strings.HasSuffix(scope, ":write")
+}
+
+// noteClaims notes the caller of an audited call
+func noteClaims(ctx context.Context, claims *auth.Claims) {
+	if state, ok := ctx.Value(callStateKey{}).(*callState); ok {
+		state.claims = claims
+	}
+}
+
+// noteTenant notes the tenant an audited call was resolved to
+func noteTenant(ctx context.Context) {
+	if state, ok := ctx.Value(callStateKey{}).(*callState); ok {
+		state.tenantID = tenant.FromContext(ctx)
+	}
+}
+
+// auditCalls records an audit entry for each call of a mutating method,
+// however it ends. Calls rejected before the tenant is resolved are
+// recorded under the default tenant.
+func (s *Server) auditCalls(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.audit == nil || !audited(s.scopes[info.FullMethod]) {
+		return handler(ctx, req)
+	}
+
+	state := &callState{}
+	resp, err := handler(context.WithValue(ctx, callStateKey{}, state), req)
+
+	code := status.Code(err)
+	entry := &domain.AuditEntry{
+		ActorID:   "anonymous",
+		Action:    "gRPC " + info.FullMethod,
+		Resource:  "media",
+		IP:        remoteIP(ctx),
+		UserAgent: first(metadataOf(ctx), "user-agent"),
+		Status:    httpStatus(code),
+		Outcome:   domain.AuditOutcomeForStatus(httpStatus(code)),
+	}
+	if r, ok := req.(interface{ GetMediaId() string }); ok && r.GetMediaId() != "" {
+		entry.Resource = "media/" + r.GetMediaId()
+	}
+	if state.claims != nil {
+		entry.ActorID = state.claims.UserID
+		entry.APIKeyID = state.claims.APIKeyID
+	}
+	s.audit.Record(tenant.WithID(context.Background(), state.tenantID), entry)
+
+	return resp, err
+}
+
+// httpStatus returns the HTTP status equivalent to a gRPC code, so audit
+// entries of both APIs share their statuses and outcomes
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// remoteIP returns the caller's address without its port
+func remoteIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,252 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/auth"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/apikeys"
+	"github.com/streaming-service/internal/service/audit"
+	"github.com/streaming-service/pkg/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // Accept gzip compressed messages
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves the gRPC API. Authentication, tenant resolution and
+// auditing run as interceptors around every unary call.
+type Server struct {
+	grpc *grpc.Server
+	// scopes holds the scope each method requires of API key callers,
+	// keyed by full method name; methods without one are open to
+	// anonymous callers
+	scopes   map[string]string
+	verifier *auth.Verifier
+	apiKeys  *apikeys.Service
+	audit    *audit.Service
+	log      *logger.Logger
+}
+
+// NewServer creates a server authenticating callers with verifier. Without
+// a verifier (auth disabled) the x-user-id metadata is trusted.
+// Connections idle for idleTimeout are closed.
+func NewServer(verifier *auth.Verifier, idleTimeout time.Duration, log *logger.Logger) *Server {
+	s := &Server{
+		scopes:   make(map[string]string),
+		verifier: verifier,
+		log:      log,
+	}
+
+	s.grpc = grpc.NewServer(
+		grpc.KeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: idleTimeout}),
+		// Auditing wraps authentication, so it sees how every call ends,
+		// including those refused
+		grpc.ChainUnaryInterceptor(
+			s.recoverPanics,
+			s.auditCalls,
+			s.authenticate,
+			s.resolveTenant,
+			s.statusErrors,
+		),
+	)
+	reflection.Register(s.grpc)
+	return s
+}
+
+// SetAPIKeys enables authentication with the x-api-key metadata
+func (s *Server) SetAPIKeys(svc *apikeys.Service) {
+	s.apiKeys = svc
+}
+
+// require sets the scope API key callers need to call a method
+func (s *Server) require(fullMethod, scope string) {
+	s.scopes[fullMethod] = scope
+}
+
+// Serve accepts connections on lis until Stop or GracefulStop
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown stops accepting calls and waits for those in flight to
+// finish, cancelling them once ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+// recoverPanics turns a panicking handler into an internal error rather
+// than taking the server down
+func (s *Server) recoverPanics(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.log.Error("panic handling grpc call",
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()),
+				"method", info.FullMethod,
+			)
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// authenticate attaches the caller's claims to the context. Methods with
+// a scope require an authenticated caller. The claims of an API key
+// lacking the scope are still noted for the call's audit entry.
+func (s *Server) authenticate(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	scope := s.scopes[info.FullMethod]
+	md := metadataOf(ctx)
+
+	if secret := first(md, "x-api-key"); secret != "" && s.apiKeys != nil {
+		key, err := s.apiKeys.Authenticate(ctx, secret)
+		if err != nil {
+			switch err {
+			case domain.ErrUnauthorized:
+				return nil, status.Error(codes.Unauthenticated, "invalid api key")
+			case domain.ErrRateLimited:
+				return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+			}
+			return nil, s.toStatus(info.FullMethod, err)
+		}
+		claims := &auth.Claims{
+			UserID:   key.UserID,
+			Scopes:   key.Scopes,
+			APIKeyID: key.ID,
+			TenantID: key.TenantID,
+		}
+		noteClaims(ctx, claims)
+		if scope != "" && !contains(key.Scopes, scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "api key lacks scope %s", scope)
+		}
+		return handler(auth.WithClaims(ctx, claims), req)
+	}
+
+	if s.verifier == nil {
+		if userID := first(md, "x-user-id"); userID != "" {
+			claims := &auth.Claims{
+				UserID:   userID,
+				TenantID: first(md, auth.TenantHeader),
+			}
+			noteClaims(ctx, claims)
+			ctx = auth.WithClaims(ctx, claims)
+		}
+		return handler(ctx, req)
+	}
+
+	if raw, ok := strings.CutPrefix(first(md, "authorization"), "Bearer "); ok {
+		claims, err := s.verifier.Verify(ctx, strings.TrimSpace(raw))
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+		}
+		noteClaims(ctx, claims)
+		return handler(auth.WithClaims(ctx, claims), req)
+	}
+
+	if scope != "" {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	return handler(ctx, req)
+}
+
+// resolveTenant scopes the call to the caller's tenant, or the one named
+// by the x-tenant-id metadata for callers without one
+func (s *Server) resolveTenant(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := auth.ResolveTenant(ctx, first(metadataOf(ctx), auth.TenantHeader))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid tenant")
+	}
+	noteTenant(ctx)
+	return handler(ctx, req)
+}
+
+// statusErrors converts the errors handlers return to gRPC statuses
+func (s *Server) statusErrors(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, s.toStatus(info.FullMethod, err)
+	}
+	return resp, nil
+}
+
+// toStatus maps errors to gRPC statuses, logging unexpected ones
+func (s *Server) toStatus(method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch err {
+	case domain.ErrInvalidInput:
+		return status.Error(codes.InvalidArgument, "invalid argument")
+	case domain.ErrFileTypeNotAllowed:
+		return status.Error(codes.InvalidArgument, "file type not allowed")
+	case domain.ErrMediaNotFound:
+		return status.Error(codes.NotFound, "media not found")
+	case domain.ErrUnauthorized:
+		return status.Error(codes.PermissionDenied, "unauthorized")
+	case domain.ErrMediaBusy:
+		return status.Error(codes.Unavailable, "media is being processed")
+	case domain.ErrQuotaExceeded:
+		return status.Error(codes.ResourceExhausted, "quota exceeded")
+	case domain.ErrLegalHold:
+		return status.Error(codes.FailedPrecondition, "media is under legal hold")
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	case context.Canceled:
+		return status.Error(codes.Canceled, "call cancelled")
+	}
+
+	s.log.Error("grpc call failed", "error", err, "method", method)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// metadataOf returns the call's incoming metadata
+func metadataOf(ctx context.Context) metadata.MD {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return md
+}
+
+// first returns the first value of a metadata key, or "" without one
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// userID returns the authenticated user from the call context
+func userID(ctx context.Context) string {
+	if claims, ok := auth.FromContext(ctx); ok {
+		return claims.UserID
+	}
+	return "anonymous"
+}
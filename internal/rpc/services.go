@@ -0,0 +1,234 @@
+package rpc
+
+import (
+	"context"
+
+	streamingv1 "github.com/streaming-service/api/proto/streaming/v1"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/keys"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/service/upload"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// uploadServer implements streaming.v1.UploadService
+type uploadServer struct {
+	streamingv1.UnimplementedUploadServiceServer
+	svc *upload.Service
+}
+
+// RegisterUploadService serves streaming.v1.UploadService
+func (s *Server) RegisterUploadService(svc *upload.Service) {
+	streamingv1.RegisterUploadServiceServer(s.grpc, &uploadServer{svc: svc})
+	s.require(streamingv1.UploadService_CreateUploadURL_FullMethodName, domain.ScopeMediaWrite)
+	s.require(streamingv1.UploadService_ConfirmUpload_FullMethodName, domain.ScopeMediaWrite)
+}
+
+// CreateUploadURL returns a presigned URL to upload a source file to
+func (u *uploadServer) CreateUploadURL(ctx context.Context, req *streamingv1.CreateUploadURLRequest) (*streamingv1.CreateUploadURLResponse, error) {
+	if req.Filename == "" || req.ContentType == "" {
+		return nil, status.Error(codes.InvalidArgument, "filename and content_type are required")
+	}
+
+	resp, err := u.svc.GetPresignedUploadURL(ctx, userID(ctx), req.Filename, req.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamingv1.CreateUploadURLResponse{MediaId: resp.MediaID, UploadUrl: resp.UploadURL}, nil
+}
+
+// ConfirmUpload creates the media record of an uploaded file
+func (u *uploadServer) ConfirmUpload(ctx context.Context, req *streamingv1.ConfirmUploadRequest) (*streamingv1.ConfirmUploadResponse, error) {
+	if req.MediaId == "" {
+		return nil, status.Error(codes.InvalidArgument, "media_id is required")
+	}
+	if v := domain.Visibility(req.Visibility); v != "" && !v.IsValid() {
+		return nil, status.Error(codes.InvalidArgument, "visibility must be public, unlisted or private")
+	}
+
+	resp, err := u.svc.ConfirmUpload(ctx, &upload.UploadRequest{
+		Title:       req.Title,
+		Description: req.Description,
+		UserID:      userID(ctx),
+		Visibility:  domain.Visibility(req.Visibility),
+		Filename:    req.Filename,
+	}, req.MediaId)
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			return nil, status.Error(codes.InvalidArgument, "invalid upload request")
+		}
+		return nil, err
+	}
+
+	return &streamingv1.ConfirmUploadResponse{MediaId: resp.MediaID, Status: string(resp.Status)}, nil
+}
+
+// mediaServer implements streaming.v1.MediaService
+type mediaServer struct {
+	streamingv1.UnimplementedMediaServiceServer
+	svc *stream.Service
+}
+
+// RegisterMediaService serves streaming.v1.MediaService
+func (s *Server) RegisterMediaService(svc *stream.Service) {
+	streamingv1.RegisterMediaServiceServer(s.grpc, &mediaServer{svc: svc})
+	s.require(streamingv1.MediaService_ListMedia_FullMethodName, domain.ScopeMediaRead)
+	s.require(streamingv1.MediaService_DeleteMedia_FullMethodName, domain.ScopeMediaWrite)
+	s.require(streamingv1.MediaService_SetVisibility_FullMethodName, domain.ScopeMediaWrite)
+}
+
+// GetMedia returns a media item the caller may view
+func (m *mediaServer) GetMedia(ctx context.Context, req *streamingv1.GetMediaRequest) (*streamingv1.Media, error) {
+	if req.MediaId == "" {
+		return nil, status.Error(codes.InvalidArgument, "media_id is required")
+	}
+
+	info, err := m.svc.GetMedia(ctx, req.MediaId, userID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return toMedia(info), nil
+}
+
+// ListMedia returns a page of the caller's media
+func (m *mediaServer) ListMedia(ctx context.Context, req *streamingv1.ListMediaRequest) (*streamingv1.ListMediaResponse, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = 20
+	}
+	if limit < 1 || limit > 100 {
+		return nil, status.Error(codes.InvalidArgument, "limit must be between 1 and 100")
+	}
+
+	filter := &domain.MediaFilter{
+		Status: domain.MediaStatus(req.Status),
+		Type:   domain.MediaType(req.Type),
+		Tag:    req.Tag,
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, status.Error(codes.InvalidArgument, "status must be pending, processing, completed or failed")
+	}
+	if filter.Type != "" && !filter.Type.IsValid() {
+		return nil, status.Error(codes.InvalidArgument, "type must be video or audio")
+	}
+
+	items, next, err := m.svc.ListMedia(ctx, userID(ctx), filter, limit, req.Cursor)
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			return nil, status.Error(codes.InvalidArgument, "invalid cursor or filter")
+		}
+		return nil, err
+	}
+
+	resp := &streamingv1.ListMediaResponse{NextCursor: next}
+	for _, info := range items {
+		resp.Items = append(resp.Items, toMedia(info))
+	}
+	return resp, nil
+}
+
+// DeleteMedia deletes one of the caller's media items
+func (m *mediaServer) DeleteMedia(ctx context.Context, req *streamingv1.DeleteMediaRequest) (*streamingv1.DeleteMediaResponse, error) {
+	if req.MediaId == "" {
+		return nil, status.Error(codes.InvalidArgument, "media_id is required")
+	}
+
+	if err := m.svc.DeleteMedia(ctx, req.MediaId, userID(ctx)); err != nil {
+		return nil, err
+	}
+
+	return &streamingv1.DeleteMediaResponse{}, nil
+}
+
+// SetVisibility changes who may view one of the caller's media items
+func (m *mediaServer) SetVisibility(ctx context.Context, req *streamingv1.SetVisibilityRequest) (*streamingv1.SetVisibilityResponse, error) {
+	if req.MediaId == "" {
+		return nil, status.Error(codes.InvalidArgument, "media_id is required")
+	}
+
+	if err := m.svc.SetVisibility(ctx, req.MediaId, userID(ctx), domain.Visibility(req.Visibility)); err != nil {
+		if err == domain.ErrInvalidInput {
+			return nil, status.Error(codes.InvalidArgument, "visibility must be public, unlisted or private")
+		}
+		return nil, err
+	}
+
+	return &streamingv1.SetVisibilityResponse{MediaId: req.MediaId, Visibility: req.Visibility}, nil
+}
+
+// streamServer implements streaming.v1.StreamService
+type streamServer struct {
+	streamingv1.UnimplementedStreamServiceServer
+	svc  *stream.Service
+	keys *keys.Service
+}
+
+// RegisterStreamService serves streaming.v1.StreamService. keysSvc may be
+// nil when encryption is disabled.
+func (s *Server) RegisterStreamService(svc *stream.Service, keysSvc *keys.Service) {
+	streamingv1.RegisterStreamServiceServer(s.grpc, &streamServer{svc: svc, keys: keysSvc})
+}
+
+// GetPlayback resolves the playback URL of a media item
+func (p *streamServer) GetPlayback(ctx context.Context, req *streamingv1.GetPlaybackRequest) (*streamingv1.GetPlaybackResponse, error) {
+	if req.MediaId == "" {
+		return nil, status.Error(codes.InvalidArgument, "media_id is required")
+	}
+
+	session := &stream.PlaybackSession{
+		UserID: userID(ctx),
+		Params: req.Params,
+	}
+	if session.Params == nil {
+		session.Params = make(map[string]string)
+	}
+
+	playback, err := p.svc.GetPlayback(ctx, req.MediaId, session)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &streamingv1.GetPlaybackResponse{PlaybackUrl: playback.URL}
+
+	// Encrypted renditions need a token to fetch content keys
+	if p.keys != nil {
+		tok, err := p.keys.IssuePlaybackToken(req.MediaId, session.UserID)
+		if err != nil {
+			return nil, err
+		}
+		resp.KeyToken = tok
+	}
+
+	return resp, nil
+}
+
+// toMedia converts media information to its protobuf form
+func toMedia(info *stream.MediaInfo) *streamingv1.Media {
+	media := &streamingv1.Media{
+		Id:          info.ID,
+		Title:       info.Title,
+		Description: info.Description,
+		Type:        string(info.Type),
+		Status:      string(info.Status),
+		Visibility:  string(info.Visibility),
+		Duration:    info.Duration,
+		Tags:        info.Tags,
+		ChannelId:   info.ChannelID,
+		PlaybackUrl: info.PlaybackURL,
+		CreatedAt:   timestamppb.New(info.CreatedAt),
+	}
+	for _, r := range info.Renditions {
+		media.Renditions = append(media.Renditions, &streamingv1.Rendition{
+			Name:      r.Name,
+			Width:     int32(r.Width),
+			Height:    int32(r.Height),
+			Bitrate:   int32(r.Bitrate),
+			StreamUrl: r.StreamURL,
+		})
+	}
+	return media
+}
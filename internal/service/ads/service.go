@@ -0,0 +1,124 @@
+package ads
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/manifest"
+	"github.com/streaming-service/internal/repository/cloudfront"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Service handles ad break configuration and manifest conditioning
+type Service struct {
+	s3Client     *s3.Client
+	dynamoClient *dynamodb.Client
+	cdn          *cloudfront.Client
+	provider     SSAIProvider
+	// targeting holds the names of the playback parameters forwarded to
+	// the provider
+	targeting map[string]bool
+	log       *logger.Logger
+}
+
+// NewService creates a new ads service
+func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, log *logger.Logger) *Service {
+	return &Service{
+		s3Client:     s3Client,
+		dynamoClient: dynamoClient,
+		log:          log,
+	}
+}
+
+// SetCDN sets the CloudFront client used for cache invalidation
+func (s *Service) SetCDN(cdn *cloudfront.Client) {
+	s.cdn = cdn
+}
+
+// SetAdBreaks replaces the ad breaks of a media item and re-conditions
+// already published playlists
+func (s *Service) SetAdBreaks(ctx context.Context, mediaID, userID string, breaks []domain.AdBreak) error {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+
+	if media.UserID != userID {
+		return domain.ErrUnauthorized
+	}
+
+	if err := domain.ValidateAdBreaks(breaks, media.Duration); err != nil {
+		return err
+	}
+
+	if err := s.dynamoClient.SetMediaAdBreaks(ctx, mediaID, breaks); err != nil {
+		return fmt.Errorf("failed to save ad breaks: %w", err)
+	}
+
+	// Media still processing picks the breaks up during transcode
+	if !media.IsProcessed() {
+		return nil
+	}
+
+	bucket := s.s3Client.GetProcessedBucket()
+	for _, r := range media.Renditions {
+		if err := s.conditionPlaylist(ctx, bucket, r.PlaylistKey, breaks); err != nil {
+			return fmt.Errorf("failed to update playlist %s: %w", r.PlaylistKey, err)
+		}
+	}
+	paths := []string{"/" + media.GetOutputPrefix() + "*.m3u8"}
+	if media.DASHKey != "" {
+		if err := s.conditionDASHManifest(ctx, bucket, media.DASHKey, breaks); err != nil {
+			return fmt.Errorf("failed to update manifest %s: %w", media.DASHKey, err)
+		}
+		paths = append(paths, "/"+media.DASHKey)
+	}
+
+	if s.cdn != nil {
+		if _, err := s.cdn.InvalidatePaths(ctx, paths); err != nil {
+			s.log.Error("failed to invalidate playlists", "error", err, "media_id", mediaID)
+		}
+	}
+
+	s.log.Info("ad breaks updated", "media_id", mediaID, "count", len(breaks))
+
+	return nil
+}
+
+// conditionPlaylist rewrites a published media playlist with cue markers
+func (s *Service) conditionPlaylist(ctx context.Context, bucket, key string, breaks []domain.AdBreak) error {
+	reader, err := s.s3Client.Download(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	playlist, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	conditioned := manifest.InsertCueMarkers(playlist, breaks)
+	return s.s3Client.Upload(ctx, bucket, key, bytes.NewReader(conditioned), "application/x-mpegURL")
+}
+
+// conditionDASHManifest rewrites a published DASH manifest with an event
+// stream for the breaks
+func (s *Service) conditionDASHManifest(ctx context.Context, bucket, key string, breaks []domain.AdBreak) error {
+	reader, err := s.s3Client.Download(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	mpd, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	conditioned := manifest.SetDASHEvents(mpd, breaks)
+	return s.s3Client.Upload(ctx, bucket, key, bytes.NewReader(conditioned), "application/dash+xml")
+}
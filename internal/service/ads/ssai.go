@@ -0,0 +1,174 @@
+package ads
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+)
+
+// SSAI provider names accepted in AdsConfig.SSAIProvider
+const (
+	ProviderHTTP   = "http"
+	ProviderPrefix = "prefix"
+)
+
+// maxTargetingValueLength caps the targeting parameter values forwarded
+// to the provider; longer values are dropped
+const maxTargetingValueLength = 256
+
+// StitchRequest describes a playback session to stitch ads into
+type StitchRequest struct {
+	MediaID     string            `json:"media_id"`
+	ManifestURL string            `json:"manifest_url"`
+	SessionID   string            `json:"session_id"`
+	UserAgent   string            `json:"user_agent"`
+	ClientIP    string            `json:"client_ip"`
+	AdBreaks    []domain.AdBreak  `json:"ad_breaks,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
+}
+
+// SSAIProvider returns a stitched manifest URL for a playback session
+type SSAIProvider interface {
+	StitchManifest(ctx context.Context, req *StitchRequest) (string, error)
+}
+
+// NewSSAIProvider creates the provider selected in config, or nil when
+// server-side ad insertion is disabled
+func NewSSAIProvider(cfg config.AdsConfig) (SSAIProvider, error) {
+	switch cfg.SSAIProvider {
+	case "":
+		return nil, nil
+	case ProviderHTTP:
+		if cfg.SSAIEndpoint == "" {
+			return nil, fmt.Errorf("ssai endpoint is required for provider %q", cfg.SSAIProvider)
+		}
+		return &HTTPProvider{
+			endpoint: cfg.SSAIEndpoint,
+			client:   &http.Client{Timeout: cfg.SSAITimeout},
+		}, nil
+	case ProviderPrefix:
+		if cfg.SSAIPrefix == "" {
+			return nil, fmt.Errorf("ssai prefix is required for provider %q", cfg.SSAIProvider)
+		}
+		return &PrefixProvider{prefix: strings.TrimRight(cfg.SSAIPrefix, "/")}, nil
+	default:
+		return nil, fmt.Errorf("unknown ssai provider: %s", cfg.SSAIProvider)
+	}
+}
+
+// HTTPProvider initializes sessions by POSTing the request as JSON to an
+// endpoint that responds with {"manifest_url": "..."}
+type HTTPProvider struct {
+	endpoint string
+	client   *http.Client
+}
+
+// StitchManifest implements SSAIProvider
+func (p *HTTPProvider) StitchManifest(ctx context.Context, req *StitchRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to build session request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("ssai session request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("ssai provider returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		ManifestURL string `json:"manifest_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode ssai response: %w", err)
+	}
+	if result.ManifestURL == "" {
+		return "", fmt.Errorf("ssai provider returned no manifest url")
+	}
+
+	return result.ManifestURL, nil
+}
+
+// PrefixProvider rewrites the manifest URL onto a stitching proxy prefix
+// (MediaTailor-style implicit sessions), forwarding targeting parameters.
+// The session ID is set after them, so they can't replace it.
+type PrefixProvider struct {
+	prefix string
+}
+
+// StitchManifest implements SSAIProvider
+func (p *PrefixProvider) StitchManifest(ctx context.Context, req *StitchRequest) (string, error) {
+	u, err := url.Parse(req.ManifestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid manifest url: %w", err)
+	}
+
+	query := url.Values{}
+	for k, v := range req.Params {
+		query.Set(k, v)
+	}
+	query.Set("session_id", req.SessionID)
+
+	return p.prefix + u.Path + "?" + query.Encode(), nil
+}
+
+// SetProvider sets the SSAI provider used to condition playback manifests
+func (s *Service) SetProvider(p SSAIProvider) {
+	s.provider = p
+}
+
+// SetTargetingParams sets the playback parameters forwarded to the
+// provider for ad targeting. Any others a player sends are dropped.
+func (s *Service) SetTargetingParams(names []string) {
+	s.targeting = make(map[string]bool, len(names))
+	for _, name := range names {
+		s.targeting[name] = true
+	}
+}
+
+// targetingParams returns the allowed targeting parameters among a
+// session's, leaving out values too long to be targeting
+func (s *Service) targetingParams(params map[string]string) map[string]string {
+	result := make(map[string]string)
+	for k, v := range params {
+		if s.targeting[k] && len(v) <= maxTargetingValueLength {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// Condition implements stream.ManifestConditioner
+func (s *Service) Condition(ctx context.Context, media *domain.Media, manifestURL string, session *stream.PlaybackSession) (string, error) {
+	if s.provider == nil {
+		return manifestURL, nil
+	}
+
+	return s.provider.StitchManifest(ctx, &StitchRequest{
+		MediaID:     media.ID,
+		ManifestURL: manifestURL,
+		SessionID:   session.SessionID,
+		UserAgent:   session.UserAgent,
+		ClientIP:    session.ClientIP,
+		AdBreaks:    media.AdBreaks,
+		Params:      s.targetingParams(session.Params),
+	})
+}
@@ -0,0 +1,280 @@
+package analytics
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// LogIngester ingests CloudFront standard access logs from S3 and
+// attributes delivered segments to media items. Ingested logs are moved
+// from the log prefix to the archive prefix, so each run only lists logs
+// still to be ingested.
+type LogIngester struct {
+	s3Client        *s3.Client
+	dynamoClient    *dynamodb.Client
+	bucket          string
+	prefix          string
+	archivePrefix   string
+	segmentDuration int
+	log             *logger.Logger
+}
+
+// NewLogIngester creates a new CloudFront log ingester. archivePrefix
+// mustn't be under prefix.
+func NewLogIngester(s3Client *s3.Client, dynamoClient *dynamodb.Client, bucket, prefix, archivePrefix string, segmentDuration int, log *logger.Logger) *LogIngester {
+	return &LogIngester{
+		s3Client:        s3Client,
+		dynamoClient:    dynamoClient,
+		bucket:          bucket,
+		prefix:          prefix,
+		archivePrefix:   archivePrefix,
+		segmentDuration: segmentDuration,
+		log:             log,
+	}
+}
+
+// mediaDelivery accumulates log lines for one media item
+type mediaDelivery struct {
+	tenantID string
+	counters map[string]int64
+	sessions map[string]time.Time
+}
+
+// Run ingests new log objects every interval until ctx is cancelled
+func (i *LogIngester) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := i.IngestPending(ctx); err != nil && ctx.Err() == nil {
+			i.log.Error("cloudfront log ingestion failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IngestPending ingests the log objects under the log prefix, archiving
+// each once its counts are applied. A log that fails stays in place to be
+// ingested again; counts it already applied aren't added twice.
+func (i *LogIngester) IngestPending(ctx context.Context) error {
+	objects, err := i.s3Client.ListObjects(ctx, i.bucket, i.prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list log objects: %w", err)
+	}
+
+	for _, obj := range objects {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		key := *obj.Key
+		if err := i.ingest(ctx, key); err != nil {
+			i.log.Error("failed to ingest log object", "error", err, "key", key)
+		}
+	}
+
+	return nil
+}
+
+// ingest ingests a log object and archives it
+func (i *LogIngester) ingest(ctx context.Context, key string) error {
+	// Logs claimed whole by earlier versions are already counted
+	claimed, err := i.dynamoClient.LogObjectClaimed(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		if err := i.IngestObject(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	archived := i.archivePrefix + strings.TrimPrefix(key, i.prefix)
+	if err := i.s3Client.CopyObject(ctx, i.bucket, key, i.bucket, archived); err != nil {
+		return fmt.Errorf("failed to archive log object: %w", err)
+	}
+	return i.s3Client.Delete(ctx, i.bucket, key)
+}
+
+// IngestObject parses a single log object and records its aggregates.
+// Ingesting an object again only records what it didn't the first time.
+func (i *LogIngester) IngestObject(ctx context.Context, key string) error {
+	reader, err := i.s3Client.Download(ctx, i.bucket, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var body io.Reader = reader
+	if strings.HasSuffix(key, ".gz") {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip log: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	deliveries, err := i.parse(body)
+	if err != nil {
+		return err
+	}
+
+	for mediaID, d := range deliveries {
+		ctx := tenant.WithID(ctx, d.tenantID)
+		if _, err := i.dynamoClient.GetMedia(ctx, mediaID); err != nil {
+			if errors.Is(err, domain.ErrMediaNotFound) {
+				continue // Deleted media or unrelated paths
+			}
+			return err
+		}
+
+		if err := i.dynamoClient.ApplyLogCounters(ctx, key, mediaID, d.counters); err != nil {
+			return err
+		}
+
+		// Each distinct viewer fingerprint counts as a view, deduplicated
+		// against beacon views for the same session and day
+		for session, at := range d.sessions {
+			if _, err := i.dynamoClient.RecordView(ctx, mediaID, session, at); err != nil {
+				if errors.Is(err, domain.ErrMediaNotFound) {
+					break // Deleted since it was read
+				}
+				return err
+			}
+		}
+	}
+
+	i.log.Info("ingested cloudfront log", "key", key, "media_count", len(deliveries))
+
+	return nil
+}
+
+// parse reads a W3C-style CloudFront log and groups requests by media ID
+func (i *LogIngester) parse(r io.Reader) (map[string]*mediaDelivery, error) {
+	deliveries := make(map[string]*mediaDelivery)
+	fields := map[string]int{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#Fields:") {
+			for idx, name := range strings.Fields(strings.TrimPrefix(line, "#Fields:")) {
+				fields[name] = idx
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cols := strings.Split(line, "\t")
+		get := func(name string) string {
+			idx, ok := fields[name]
+			if !ok || idx >= len(cols) {
+				return ""
+			}
+			return cols[idx]
+		}
+
+		status := get("sc-status")
+		if status != "200" && status != "206" {
+			continue
+		}
+
+		tenantID, mediaID, file := splitMediaPath(get("cs-uri-stem"))
+		if mediaID == "" {
+			continue
+		}
+
+		d, ok := deliveries[mediaID]
+		if !ok {
+			d = &mediaDelivery{tenantID: tenantID, counters: map[string]int64{}, sessions: map[string]time.Time{}}
+			deliveries[mediaID] = d
+		}
+
+		bytes, _ := strconv.ParseInt(get("sc-bytes"), 10, 64)
+		d.counters["cdn_bytes"] += bytes
+		d.counters["cdn_requests"]++
+
+		userAgent, _ := url.QueryUnescape(get("cs(User-Agent)"))
+		switch {
+		case file == "master.m3u8":
+			// A master playlist fetch marks the start of a playback session
+			at, err := time.Parse("2006-01-02 15:04:05", get("date")+" "+get("time"))
+			if err != nil {
+				at = time.Now()
+			}
+			sum := sha256.Sum256([]byte(get("c-ip") + "|" + userAgent))
+			session := hex.EncodeToString(sum[:16])
+			if _, seen := d.sessions[session]; !seen {
+				d.sessions[session] = at
+				d.counters["starts"]++
+				d.counters["device#"+DeviceClass(userAgent)]++
+			}
+		case strings.HasPrefix(file, "segment_"):
+			// Segment fetches approximate watch time at the segment's position
+			if idx, ok := segmentIndex(file); ok && i.segmentDuration > 0 {
+				position := idx * i.segmentDuration
+				bucket := position / domain.HeatmapBucketSeconds * domain.HeatmapBucketSeconds
+				d.counters[fmt.Sprintf("pos#%06d", bucket)] += int64(i.segmentDuration) * 1000
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// splitMediaPath extracts the tenant, media ID and file name from a
+// request path such as /{mediaID}/{rendition}/segment_0001.ts, or
+// /tenants/{tenantID}/{mediaID}/... for media outside the default tenant
+func splitMediaPath(uri string) (string, string, string) {
+	parts := strings.Split(strings.Trim(uri, "/"), "/")
+	tenantID := ""
+	if len(parts) > 2 && parts[0] == "tenants" {
+		tenantID, parts = parts[1], parts[2:]
+	}
+	if len(parts) < 2 || (tenantID != "" && !tenant.Valid(tenantID)) {
+		return "", "", ""
+	}
+	return tenantID, parts[0], path.Base(uri)
+}
+
+// segmentIndex parses the sequence number from names like segment_0001.ts
+func segmentIndex(file string) (int, bool) {
+	name := strings.TrimPrefix(file, "segment_")
+	name = strings.TrimSuffix(name, path.Ext(name))
+	idx, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, false
+	}
+	return idx, true
+}
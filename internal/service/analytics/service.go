@@ -0,0 +1,247 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// MaxTrendingDays is the longest window, in days, trending can be
+// computed over
+const MaxTrendingDays = 30
+
+// Service handles view tracking and trending
+type Service struct {
+	dynamoClient *dynamodb.Client
+	log          *logger.Logger
+}
+
+// NewService creates a new analytics service
+func NewService(dynamoClient *dynamodb.Client, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		log:          log,
+	}
+}
+
+// TrendingItem is a media item ranked by views within a window
+type TrendingItem struct {
+	ID    string           `json:"id"`
+	Title string           `json:"title"`
+	Type  domain.MediaType `json:"type"`
+	Views int64            `json:"views"`
+}
+
+// deviceClasses are the device categories events are aggregated under;
+// any other device a player reports is counted as "other"
+var deviceClasses = map[string]bool{
+	"desktop": true,
+	"mobile":  true,
+	"tablet":  true,
+	"tv":      true,
+	"unknown": true,
+}
+
+// RecordView counts a view by userID, deduplicated per session per day
+func (s *Service) RecordView(ctx context.Context, mediaID, sessionID, userID string) (bool, error) {
+	if sessionID == "" {
+		return false, domain.ErrInvalidInput
+	}
+
+	// Make sure the media exists, and is visible to the viewer, before
+	// creating counters for it
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return false, err
+	}
+	if !media.CanView(userID) {
+		return false, domain.ErrMediaNotFound
+	}
+
+	counted, err := s.dynamoClient.RecordView(ctx, mediaID, sessionID, time.Now())
+	if err != nil {
+		// Deleted since it was read
+		if err == domain.ErrMediaNotFound {
+			return false, err
+		}
+		return false, fmt.Errorf("failed to record view: %w", err)
+	}
+
+	// Co-views feed related media; losing one isn't worth failing the view
+	if counted {
+		if err := s.dynamoClient.RecordCoView(ctx, mediaID, sessionID, time.Now()); err != nil {
+			s.log.Error("failed to record co-view", "error", err, "media_id", mediaID)
+		}
+	}
+
+	return counted, nil
+}
+
+// RecordEvent ingests a player beacon from userID into the media's
+// aggregates. Positions and watch time are clamped to the media's
+// duration, and devices and countries outside the known set are folded
+// together, so a client can't create unbounded aggregates.
+func (s *Service) RecordEvent(ctx context.Context, event *domain.PlaybackEvent, userID string) error {
+	if !event.Type.IsValid() || event.Position < 0 || event.Watched < 0 {
+		return domain.ErrInvalidInput
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, event.MediaID)
+	if err != nil {
+		return err
+	}
+	if !media.CanView(userID) {
+		return domain.ErrMediaNotFound
+	}
+
+	event.Position = min(event.Position, media.Duration)
+	event.Watched = min(event.Watched, media.Duration)
+
+	switch {
+	case event.Device == "":
+		event.Device = "unknown"
+	case !deviceClasses[event.Device]:
+		event.Device = "other"
+	}
+	event.Country = countryCode(event.Country)
+
+	if err := s.dynamoClient.RecordPlaybackEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+
+	return nil
+}
+
+// GetMediaAnalytics returns analytics for a media item owned by userID,
+// with views reported for the last days days
+func (s *Service) GetMediaAnalytics(ctx context.Context, mediaID, userID string, days int) (*domain.MediaAnalytics, error) {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if media.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	stats, err := s.dynamoClient.GetMediaAnalytics(ctx, mediaID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analytics: %w", err)
+	}
+
+	// Report a dense series so charts don't have to fill gaps
+	byDay := make(map[string]int64, len(stats.Views))
+	for _, v := range stats.Views {
+		byDay[v.Date] = v.Count
+	}
+	now := time.Now().UTC()
+	series := make([]domain.DailyCount, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		series = append(series, domain.DailyCount{Date: date, Count: byDay[date]})
+	}
+	stats.Views = series
+
+	if stats.Starts > 0 {
+		stats.CompletionRate = float64(stats.Completions) / float64(stats.Starts)
+	}
+
+	return stats, nil
+}
+
+// Trending returns the most viewed media over the last days UTC days,
+// today's counting as the first. Counters are bucketed per UTC day, so
+// windows are whole days.
+func (s *Service) Trending(ctx context.Context, days int, limit int) ([]TrendingItem, error) {
+	if days < 1 || days > MaxTrendingDays {
+		return nil, domain.ErrInvalidInput
+	}
+
+	now := time.Now()
+
+	totals := make(map[string]int64)
+	for i := 0; i < days; i++ {
+		counts, err := s.dynamoClient.GetDailyViews(ctx, now.AddDate(0, 0, -i))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get daily views: %w", err)
+		}
+		for mediaID, n := range counts {
+			totals[mediaID] += n
+		}
+	}
+
+	ranked := make([]TrendingItem, 0, len(totals))
+	for mediaID, views := range totals {
+		ranked = append(ranked, TrendingItem{ID: mediaID, Views: views})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Views == ranked[j].Views {
+			return ranked[i].ID < ranked[j].ID
+		}
+		return ranked[i].Views > ranked[j].Views
+	})
+
+	// Resolve titles for the top entries, skipping media deleted since and
+	// media that is not publicly listed
+	result := make([]TrendingItem, 0, limit)
+	for _, item := range ranked {
+		if len(result) >= limit {
+			break
+		}
+		media, err := s.dynamoClient.GetMedia(ctx, item.ID)
+		if err != nil {
+			if errors.Is(err, domain.ErrMediaNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if !media.IsListed() {
+			continue
+		}
+		item.Title = media.Title
+		item.Type = media.Type
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// countryCode returns country as an upper case ISO 3166-1 alpha-2 code,
+// or "unknown" when it isn't one
+func countryCode(country string) string {
+	if len(country) != 2 {
+		return "unknown"
+	}
+	code := strings.ToUpper(country)
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return "unknown"
+		}
+	}
+	return code
+}
+
+// DeviceClass buckets a user agent into a coarse device category
+func DeviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "smart-tv") || strings.Contains(ua, "smarttv") ||
+		strings.Contains(ua, "appletv") || strings.Contains(ua, "roku") || strings.Contains(ua, "tizen"):
+		return "tv"
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet"):
+		return "tablet"
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		return "mobile"
+	default:
+		return "desktop"
+	}
+}
@@ -0,0 +1,57 @@
+package apikeys
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter enforces per-key request rates with token buckets. Limits are
+// tracked per API instance.
+type limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// bucket holds up to a minute's worth of requests, refilled continuously
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter creates an empty limiter
+func newLimiter() *limiter {
+	return &limiter{buckets: make(map[string]*bucket)}
+}
+
+// allow takes a token from the key's bucket. A perMinute of zero means the
+// key is unlimited.
+func (l *limiter) allow(keyID string, perMinute int) bool {
+	if perMinute <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	capacity := float64(perMinute)
+
+	b, ok := l.buckets[keyID]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[keyID] = b
+	}
+
+	b.tokens += now.Sub(b.last).Minutes() * capacity
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
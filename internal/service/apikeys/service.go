@@ -0,0 +1,235 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// keyBytes is the amount of randomness in an API key
+	keyBytes = 32
+	// keyPrefix marks API key secrets so they are recognisable in leaks
+	keyPrefix = "ak_"
+	// displayPrefixLen is how much of the secret is kept for display
+	displayPrefixLen = len(keyPrefix) + 8
+)
+
+// Service issues, revokes and authenticates API keys
+type Service struct {
+	dynamoClient *dynamodb.Client
+	cacheTTL     time.Duration
+	limiter      *limiter
+
+	// mu guards the rate limits, which can be reloaded, and the cache
+	mu               sync.Mutex
+	defaultRateLimit int
+	maxRateLimit     int
+	cache            map[string]cachedKey
+
+	log *logger.Logger
+}
+
+// cachedKey is a key looked up by hash, kept to avoid a DynamoDB read on
+// every request
+type cachedKey struct {
+	key       *domain.APIKey
+	fetchedAt time.Time
+}
+
+// NewService creates a new API key service
+func NewService(dynamoClient *dynamodb.Client, cfg config.APIKeysConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient:     dynamoClient,
+		defaultRateLimit: cfg.DefaultRateLimit,
+		maxRateLimit:     cfg.MaxRateLimit,
+		cacheTTL:         cfg.CacheTTL,
+		limiter:          newLimiter(),
+		cache:            make(map[string]cachedKey),
+		log:              log,
+	}
+}
+
+// SetRateLimits changes the rate limits given to new keys. Existing keys
+// keep the limit they were issued with.
+func (s *Service) SetRateLimits(cfg config.APIKeysConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaultRateLimit = cfg.DefaultRateLimit
+	s.maxRateLimit = cfg.MaxRateLimit
+}
+
+// CreateKeyRequest contains the fields for a new API key
+type CreateKeyRequest struct {
+	Name   string
+	UserID string
+	Scopes []string
+	// RateLimit is in requests per minute; zero uses the default
+	RateLimit int
+}
+
+// CreateKey issues an API key. The secret is only returned here.
+func (s *Service) CreateKey(ctx context.Context, req *CreateKeyRequest) (*domain.APIKey, string, error) {
+	if req.Name == "" || len(req.Scopes) == 0 {
+		return nil, "", domain.ErrInvalidInput
+	}
+	for _, scope := range req.Scopes {
+		if !domain.IsValidScope(scope) {
+			return nil, "", domain.ErrInvalidInput
+		}
+	}
+
+	s.mu.Lock()
+	defaultRateLimit, maxRateLimit := s.defaultRateLimit, s.maxRateLimit
+	s.mu.Unlock()
+
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = defaultRateLimit
+	}
+	if rateLimit < 0 || (maxRateLimit > 0 && rateLimit > maxRateLimit) {
+		return nil, "", domain.ErrInvalidInput
+	}
+
+	secret, err := generateKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &domain.APIKey{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		UserID:    req.UserID,
+		Prefix:    secret[:displayPrefixLen],
+		KeyHash:   hashKey(secret),
+		Scopes:    req.Scopes,
+		RateLimit: rateLimit,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.dynamoClient.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	s.log.Info("api key created", "key_id", key.ID, "user_id", req.UserID, "scopes", req.Scopes)
+
+	return key, secret, nil
+}
+
+// ListKeys returns the API keys owned by the user
+func (s *Service) ListKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	return s.dynamoClient.ListAPIKeysByUser(ctx, userID, 100)
+}
+
+// RevokeKey revokes an API key owned by the user
+func (s *Service) RevokeKey(ctx context.Context, keyID, userID string) error {
+	key, err := s.dynamoClient.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	if key.UserID != userID {
+		return domain.ErrUnauthorized
+	}
+
+	if key.IsRevoked() {
+		return nil
+	}
+
+	if err := s.dynamoClient.RevokeAPIKey(ctx, keyID); err != nil {
+		return err
+	}
+
+	// Drop it from this instance's cache; others see it within the TTL
+	s.mu.Lock()
+	delete(s.cache, key.KeyHash)
+	s.mu.Unlock()
+
+	s.log.Info("api key revoked", "key_id", keyID, "user_id", userID)
+
+	return nil
+}
+
+// Authenticate resolves an API key secret and applies its rate limit.
+// Unknown and revoked keys yield ErrUnauthorized.
+func (s *Service) Authenticate(ctx context.Context, secret string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return nil, domain.ErrUnauthorized
+	}
+
+	key, err := s.lookup(ctx, hashKey(secret))
+	if err != nil {
+		if err == domain.ErrAPIKeyNotFound {
+			return nil, domain.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	if key.IsRevoked() {
+		return nil, domain.ErrUnauthorized
+	}
+
+	if !s.limiter.allow(key.ID, key.RateLimit) {
+		return key, domain.ErrRateLimited
+	}
+
+	return key, nil
+}
+
+// lookup returns a key by hash, from the cache while it is fresh
+func (s *Service) lookup(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[keyHash]
+	s.mu.Unlock()
+
+	if ok && time.Since(cached.fetchedAt) < s.cacheTTL {
+		return cached.key, nil
+	}
+
+	key, err := s.dynamoClient.GetAPIKeyByHash(ctx, keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[keyHash] = cachedKey{key: key, fetchedAt: time.Now()}
+	s.mu.Unlock()
+
+	// Usage is recorded once per cache refresh rather than per request
+	if !key.IsRevoked() {
+		if err := s.dynamoClient.TouchAPIKey(tenant.WithID(ctx, key.TenantID), key.ID); err != nil {
+			s.log.Error("failed to record api key use", "key_id", key.ID, "error", err)
+		}
+	}
+
+	return key, nil
+}
+
+// generateKey returns a random API key secret
+func generateKey() (string, error) {
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return keyPrefix + hex.EncodeToString(b), nil
+}
+
+// hashKey returns the stored form of a secret. The secrets are random, so
+// an unsalted fast hash is sufficient.
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -102,7 +102,8 @@ func (s *Service) ExtractAudio(ctx context.Context, mediaID string) error {
 	}
 	defer masterFile.Close()
 
-	masterKey := fmt.Sprintf("%s/audio/master.m3u8", mediaID)
+	prefix := media.GetOutputPrefix() + "audio/"
+	masterKey := prefix + "master.m3u8"
 	if err := s.s3Client.Upload(ctx, bucket, masterKey, masterFile, "application/x-mpegURL"); err != nil {
 		return fmt.Errorf("failed to upload audio master: %w", err)
 	}
@@ -114,15 +115,16 @@ func (s *Service) ExtractAudio(ctx context.Context, mediaID string) error {
 		// Upload playlist
 		playlistPath := filepath.Join(renditionDir, "playlist.m3u8")
 		if file, err := os.Open(playlistPath); err == nil {
-			key := fmt.Sprintf("%s/audio/%s/playlist.m3u8", mediaID, r.Name)
+			key := prefix + r.Name + "/playlist.m3u8"
 			if uploadErr := s.s3Client.Upload(ctx, bucket, key, file, "application/x-mpegURL"); uploadErr != nil {
 				s.log.Error("failed to upload playlist", "error", uploadErr, "key", key)
 			}
 			file.Close()
 		}
 
-		// Upload segments
-		segments, err := filepath.Glob(filepath.Join(renditionDir, "segment_*.aac"))
+		// Upload segments, or the single media file of byte-range
+		// renditions
+		segments, err := filepath.Glob(filepath.Join(renditionDir, "*.aac"))
 		if err != nil {
 			s.log.Error("failed to glob segments", "error", err)
 			continue
@@ -130,7 +132,7 @@ func (s *Service) ExtractAudio(ctx context.Context, mediaID string) error {
 		for _, seg := range segments {
 			if file, err := os.Open(seg); err == nil {
 				segName := filepath.Base(seg)
-				key := fmt.Sprintf("%s/audio/%s/%s", mediaID, r.Name, segName)
+				key := prefix + r.Name + "/" + segName
 				if uploadErr := s.s3Client.Upload(ctx, bucket, key, file, "audio/aac"); uploadErr != nil {
 					s.log.Error("failed to upload segment", "error", uploadErr, "key", key)
 				}
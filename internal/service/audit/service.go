@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// dayFormat is the UTC date entries are partitioned by
+	dayFormat = "2006-01-02"
+	// writeTimeout bounds storing an entry after its request has finished
+	writeTimeout = 5 * time.Second
+)
+
+// Service records mutating API calls and queries their history
+type Service struct {
+	dynamoClient *dynamodb.Client
+	retention    time.Duration
+	log          *logger.Logger
+}
+
+// NewService creates a new audit service
+func NewService(dynamoClient *dynamodb.Client, cfg config.AuditConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		retention:    cfg.Retention,
+		log:          log,
+	}
+}
+
+// Record stores an audit entry in ctx's tenant. It is written in the
+// background so responses aren't held up; entries that can't be stored are
+// logged in full instead.
+func (s *Service) Record(ctx context.Context, entry *domain.AuditEntry) {
+	now := time.Now().UTC()
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = now
+	entry.Day = now.Format(dayFormat)
+	entry.ExpiresAt = now.Add(s.retention).Unix()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(tenant.Detach(ctx), writeTimeout)
+		defer cancel()
+
+		if err := s.dynamoClient.PutAuditEntry(ctx, entry); err != nil {
+			s.log.Error("failed to store audit entry", "error", err,
+				"actor_id", entry.ActorID, "api_key_id", entry.APIKeyID, "action", entry.Action,
+				"resource", entry.Resource, "ip", entry.IP, "status", entry.Status, "request_id", entry.RequestID)
+		}
+	}()
+}
+
+// List returns a page of audit entries matching filter, newest first,
+// with the cursor of the next page
+func (s *Service) List(ctx context.Context, filter *domain.AuditFilter, limit int32, cursor string) ([]*domain.AuditEntry, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	if filter.Outcome != "" && !filter.Outcome.IsValid() {
+		return nil, "", domain.ErrInvalidInput
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Since.After(filter.Until) {
+		return nil, "", domain.ErrInvalidInput
+	}
+	if filter.Day == "" {
+		filter.Day = time.Now().UTC().Format(dayFormat)
+	} else if _, err := time.Parse(dayFormat, filter.Day); err != nil {
+		return nil, "", domain.ErrInvalidInput
+	}
+
+	return s.dynamoClient.ListAuditEntries(ctx, filter, limit, cursor)
+}
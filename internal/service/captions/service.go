@@ -0,0 +1,221 @@
+// Package captions manages the subtitle tracks of media: storing their
+// WebVTT files, listing them in master playlists, translating them and
+// transcribing them from speech
+package captions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/manifest"
+	"github.com/streaming-service/internal/media/transcript"
+	"github.com/streaming-service/internal/media/webvtt"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/cloudfront"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/service/search"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// defaultSegmentDuration is the length of subtitle segments unless set
+const defaultSegmentDuration = 6 * time.Second
+
+// Service manages subtitle tracks
+type Service struct {
+	s3Client        *s3.Client
+	dynamoClient    *dynamodb.Client
+	queue           queue.Queue
+	translator      Translator
+	transcriber     Transcriber
+	extractor       AudioExtractor
+	filter          *transcript.Filter
+	cdn             *cloudfront.Client
+	search          *search.Service
+	segmentDuration time.Duration
+	log             *logger.Logger
+}
+
+// NewService creates a new captions service
+func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, log *logger.Logger) *Service {
+	return &Service{
+		s3Client:        s3Client,
+		dynamoClient:    dynamoClient,
+		segmentDuration: defaultSegmentDuration,
+		log:             log,
+	}
+}
+
+// SetSegmentDuration sets the length of subtitle segments, which should
+// match the video's
+func (s *Service) SetSegmentDuration(d time.Duration) {
+	if d > 0 {
+		s.segmentDuration = d
+	}
+}
+
+// TranslationsEnabled reports whether translation jobs can be queued
+func (s *Service) TranslationsEnabled() bool {
+	return s.queue != nil
+}
+
+// SetQueue sets the queue translation jobs are sent to
+func (s *Service) SetQueue(q queue.Queue) {
+	s.queue = q
+}
+
+// SetTranslator sets the provider translation jobs use
+func (s *Service) SetTranslator(t Translator) {
+	s.translator = t
+}
+
+// SetCDN sets the CloudFront client used for cache invalidation
+func (s *Service) SetCDN(cdn *cloudfront.Client) {
+	s.cdn = cdn
+}
+
+// SetSearch indexes stored tracks for transcript search
+func (s *Service) SetSearch(svc *search.Service) {
+	s.search = svc
+}
+
+// AddTrack stores cues as a subtitle track of a media item, replacing any
+// track with the same ID, and lists it in the master playlist once the
+// media is processed. The whole WebVTT file is kept for reading the
+// track back, and a segmented copy is served to players.
+func (s *Service) AddTrack(ctx context.Context, media *domain.Media, track domain.SubtitleTrack, cues []webvtt.Cue) error {
+	bucket := s.s3Client.GetProcessedBucket()
+	if err := s.s3Client.Upload(ctx, bucket, media.GetSubtitleKey(track.ID), bytes.NewReader(webvtt.Write(cues)), "text/vtt"); err != nil {
+		return fmt.Errorf("failed to upload subtitles: %w", err)
+	}
+	durations, err := s.uploadSegments(ctx, media, track.ID, cues)
+	if err != nil {
+		return err
+	}
+	playlist := manifest.SubtitlePlaylist(durations)
+	if err := s.s3Client.Upload(ctx, bucket, media.GetSubtitlePlaylistKey(track.ID), bytes.NewReader(playlist), "application/x-mpegURL"); err != nil {
+		return fmt.Errorf("failed to upload subtitle playlist: %w", err)
+	}
+
+	if track.CreatedAt.IsZero() {
+		track.CreatedAt = time.Now().UTC()
+	}
+	tracks := make([]domain.SubtitleTrack, 0, len(media.Subtitles)+1)
+	for _, t := range media.Subtitles {
+		if t.ID != track.ID {
+			tracks = append(tracks, t)
+		}
+	}
+	tracks = append(tracks, track)
+
+	if err := s.dynamoClient.SetMediaSubtitles(ctx, media.ID, tracks); err != nil {
+		return err
+	}
+	media.Subtitles = tracks
+
+	// Media still processing gets the tracks listed during transcode
+	if media.IsProcessed() {
+		if err := s.updateMaster(ctx, media); err != nil {
+			return err
+		}
+	}
+
+	if s.search != nil {
+		if err := s.search.IndexTranscript(ctx, media, track.Language, cues); err != nil {
+			s.log.Error("failed to index transcript", "error", err, "media_id", media.ID, "track", track.ID)
+		}
+	}
+
+	s.log.Info("subtitle track added", "media_id", media.ID, "track", track.ID, "source", track.Source, "cues", len(cues))
+
+	return nil
+}
+
+// uploadSegments uploads cues as WebVTT segments spanning the media,
+// returning the segments' durations in seconds. Media not yet processed
+// has no duration, so its segments span the cues instead.
+func (s *Service) uploadSegments(ctx context.Context, media *domain.Media, trackID string, cues []webvtt.Cue) ([]float64, error) {
+	duration := time.Duration(media.Duration * float64(time.Second))
+	if duration <= 0 {
+		for _, cue := range cues {
+			if cue.End > duration {
+				duration = cue.End
+			}
+		}
+	}
+
+	bucket := s.s3Client.GetProcessedBucket()
+	segments := webvtt.Segment(cues, duration, s.segmentDuration)
+	durations := make([]float64, len(segments))
+	for i, segment := range segments {
+		key := media.GetSubtitleSegmentKey(trackID, i)
+		if err := s.s3Client.Upload(ctx, bucket, key, bytes.NewReader(webvtt.Write(segment)), "text/vtt"); err != nil {
+			return nil, fmt.Errorf("failed to upload subtitle segment: %w", err)
+		}
+		durations[i] = s.segmentDuration.Seconds()
+	}
+	if last := duration - time.Duration(len(segments)-1)*s.segmentDuration; last > 0 {
+		durations[len(durations)-1] = last.Seconds()
+	}
+	return durations, nil
+}
+
+// Cues reads the cues of a media item's subtitle track
+func (s *Service) Cues(ctx context.Context, media *domain.Media, trackID string) ([]webvtt.Cue, error) {
+	reader, err := s.s3Client.Download(ctx, s.s3Client.GetProcessedBucket(), media.GetSubtitleKey(trackID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download subtitles: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtitles: %w", err)
+	}
+	return webvtt.Parse(data)
+}
+
+// updateMaster relists the media's subtitle tracks in its published
+// master playlist
+func (s *Service) updateMaster(ctx context.Context, media *domain.Media) error {
+	bucket := s.s3Client.GetProcessedBucket()
+	key := media.GetMasterPlaylistKey()
+
+	reader, err := s.s3Client.Download(ctx, bucket, key)
+	if err != nil {
+		return fmt.Errorf("failed to download master playlist: %w", err)
+	}
+	master, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read master playlist: %w", err)
+	}
+
+	updated := manifest.InsertSubtitleTracks(master, media.Subtitles)
+	if err := s.s3Client.Upload(ctx, bucket, key, bytes.NewReader(updated), "application/x-mpegURL"); err != nil {
+		return fmt.Errorf("failed to upload master playlist: %w", err)
+	}
+
+	if s.cdn != nil {
+		if _, err := s.cdn.InvalidatePaths(ctx, []string{"/" + key}); err != nil {
+			s.log.Error("failed to invalidate master playlist", "error", err, "media_id", media.ID)
+		}
+	}
+	return nil
+}
+
+// languageName returns the name of a language in that language, as
+// shown in players' subtitle menus
+func languageName(tag language.Tag) string {
+	if name := display.Self.Name(tag); name != "" {
+		return name
+	}
+	return tag.String()
+}
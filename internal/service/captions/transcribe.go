@@ -0,0 +1,339 @@
+package captions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+
+	"github.com/streaming-service/internal/correlation"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/transcript"
+	"github.com/streaming-service/internal/media/webvtt"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Cues made from a transcript hold up to two 42 character lines' worth of
+// words, spoken within maxCueDuration, and end at the end of a sentence
+// or a pause
+const (
+	maxCueLength   = 84
+	maxCueDuration = 6 * time.Second
+	cuePause       = time.Second
+)
+
+// Transcriber transcribes speech
+type Transcriber interface {
+	// Transcribe transcribes the FLAC audio at an S3 URI in a job named
+	// name, which must be unique
+	Transcribe(ctx context.Context, name, mediaURI string) (*domain.Transcript, error)
+}
+
+// AudioExtractor extracts the audio of a local media file for
+// transcription
+type AudioExtractor interface {
+	ExtractAudio(ctx context.Context, source, output string) error
+}
+
+// SetTranscriber sets the provider transcription jobs use, with what
+// extracts the audio it's sent
+func (s *Service) SetTranscriber(t Transcriber, extractor AudioExtractor) {
+	s.transcriber = t
+	s.extractor = extractor
+}
+
+// SetTranscriptFilter sets the filter applied to transcribed captions
+func (s *Service) SetTranscriptFilter(f *transcript.Filter) {
+	s.filter = f
+}
+
+// TranscriptionEnabled reports whether automatic captions can be queued
+func (s *Service) TranscriptionEnabled() bool {
+	return s.queue != nil && s.transcriber != nil
+}
+
+// RequestCaptions queues a job transcribing a processed media item that
+// was uploaded with automatic captions requested. Captions are only
+// transcribed once; reprocessing media retries them if they failed.
+func (s *Service) RequestCaptions(ctx context.Context, mediaID string) error {
+	if !s.TranscriptionEnabled() {
+		return nil
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	if !media.AutoCaptions || !media.IsProcessed() {
+		return nil
+	}
+	if media.Captioning != nil && media.Captioning.Status != domain.CaptioningStatusFailed {
+		return nil
+	}
+
+	// The status is queued first, as the job only runs for queued media
+	if err := s.setCaptioning(ctx, mediaID, &domain.Captioning{Status: domain.CaptioningStatusQueued}); err != nil {
+		return err
+	}
+	job := &queue.Job{
+		ID:        uuid.New().String(),
+		Type:      queue.JobTypeTranscribe,
+		MediaID:   mediaID,
+		RequestID: correlation.ID(ctx),
+		TenantID:  tenant.FromContext(ctx),
+	}
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		_ = s.setCaptioning(ctx, mediaID, &domain.Captioning{Status: domain.CaptioningStatusFailed, Error: "failed to queue transcription"})
+		return err
+	}
+
+	logger.FromContext(ctx, s.log).Info("transcription queued", "media_id", mediaID, "job_id", job.ID)
+
+	return nil
+}
+
+// Transcribe runs a transcription job: it sends the media's audio to the
+// transcriber and adds the captions made from the transcript as a
+// subtitle track. Media whose captions aren't queued is skipped. The
+// final attempt marks the captions failed.
+func (s *Service) Transcribe(ctx context.Context, mediaID string, final bool) error {
+	if s.transcriber == nil {
+		return fmt.Errorf("no transcription provider configured")
+	}
+	log := logger.FromContext(ctx, s.log)
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	if media.Captioning == nil || media.Captioning.Status != domain.CaptioningStatusQueued {
+		log.Info("captions not queued, skipping transcription", "media_id", mediaID)
+		return nil
+	}
+
+	captioning, err := s.transcribe(ctx, media)
+	if err != nil {
+		if final {
+			failed := &domain.Captioning{Status: domain.CaptioningStatusFailed, Error: err.Error()}
+			if err := s.setCaptioning(ctx, mediaID, failed); err != nil {
+				log.Error("failed to mark captions failed", "error", err, "media_id", mediaID)
+			}
+		}
+		return err
+	}
+	if err := s.setCaptioning(ctx, mediaID, captioning); err != nil {
+		return err
+	}
+
+	log.Info("captions transcribed", "media_id", mediaID, "language", captioning.Language, "track", captioning.Track)
+
+	return nil
+}
+
+// transcribe transcribes the media's audio and adds the captions,
+// returning their completed status
+func (s *Service) transcribe(ctx context.Context, media *domain.Media) (*domain.Captioning, error) {
+	if s.extractor == nil {
+		return nil, fmt.Errorf("no audio extractor configured")
+	}
+
+	dir, err := os.MkdirTemp("", "transcribe-"+media.ID+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "source"+media.SourceFormat)
+	if err := s.download(ctx, media.SourceBucket, media.SourceKey, source); err != nil {
+		return nil, err
+	}
+	audio := filepath.Join(dir, "audio.flac")
+	if err := s.extractor.ExtractAudio(ctx, source, audio); err != nil {
+		return nil, err
+	}
+
+	// The audio sits beside the source until it's transcribed
+	f, err := os.Open(audio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio: %w", err)
+	}
+	audioKey := media.SourceKey + ".speech.flac"
+	err = s.s3Client.Upload(ctx, media.SourceBucket, audioKey, f, "audio/flac")
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload audio: %w", err)
+	}
+	defer func() {
+		if err := s.s3Client.Delete(context.WithoutCancel(ctx), media.SourceBucket, audioKey); err != nil {
+			s.log.Error("failed to delete transcription audio", "error", err, "media_id", media.ID)
+		}
+	}()
+
+	name := fmt.Sprintf("%s-%d", media.ID, time.Now().UnixNano())
+	result, err := s.transcriber.Transcribe(ctx, name, fmt.Sprintf("s3://%s/%s", media.SourceBucket, audioKey))
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := language.Parse(result.Language)
+	if err != nil {
+		tag = language.Und
+	}
+	captioning := &domain.Captioning{
+		Status:   domain.CaptioningStatusCompleted,
+		Language: tag.String(),
+	}
+
+	// Audio without speech is captioned with nothing
+	cues := transcriptCues(result.Words)
+	if len(cues) == 0 {
+		return captioning, nil
+	}
+
+	track, err := s.addTranscribedTracks(ctx, media, tag, cues)
+	if err != nil {
+		return nil, err
+	}
+	captioning.Track = track
+	return captioning, nil
+}
+
+// addTranscribedTracks adds transcribed cues as a subtitle track,
+// returning its ID. With a filter the track is the cleaned variant, and
+// the original is kept as a second track when filtering changed it.
+// Tracks not transcribed are never replaced; the transcribed track takes
+// a different ID instead.
+func (s *Service) addTranscribedTracks(ctx context.Context, media *domain.Media, tag language.Tag, cues []webvtt.Cue) (string, error) {
+	id := tag.String()
+	if t := media.GetSubtitleTrack(id); t != nil && t.Source != domain.SubtitleSourceTranscribe {
+		id += "-auto"
+	}
+	name := languageName(tag) + " (auto-generated)"
+
+	hasDefault := false
+	for _, t := range media.Subtitles {
+		if t.Default && t.ID != id {
+			hasDefault = true
+		}
+	}
+
+	cleaned := cues
+	if s.filter != nil {
+		var flags *domain.TranscriptFlags
+		cleaned, flags = s.filter.Apply(cues)
+		if err := s.dynamoClient.SetMediaTranscriptFlags(ctx, media.ID, flags); err != nil {
+			return "", err
+		}
+		media.TranscriptFlags = flags
+	}
+
+	if !sameText(cues, cleaned) {
+		original := domain.SubtitleTrack{
+			ID:       id + "-original",
+			Language: tag.String(),
+			Name:     name + ", unfiltered",
+			Source:   domain.SubtitleSourceTranscribe,
+		}
+		if err := s.AddTrack(ctx, media, original, cues); err != nil {
+			return "", err
+		}
+	}
+
+	track := domain.SubtitleTrack{
+		ID:       id,
+		Language: tag.String(),
+		Name:     name,
+		Source:   domain.SubtitleSourceTranscribe,
+		Default:  !hasDefault,
+	}
+	if err := s.AddTrack(ctx, media, track, cleaned); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// download saves an object to a local file
+func (s *Service) download(ctx context.Context, bucket, key, path string) error {
+	reader, err := s.s3Client.Download(ctx, bucket, key)
+	if err != nil {
+		return fmt.Errorf("failed to download source: %w", err)
+	}
+	defer reader.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := io.Copy(f, reader); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save source: %w", err)
+	}
+	return f.Close()
+}
+
+// setCaptioning records the state of a media item's automatic captions
+func (s *Service) setCaptioning(ctx context.Context, mediaID string, captioning *domain.Captioning) error {
+	captioning.UpdatedAt = time.Now().UTC()
+	return s.dynamoClient.SetMediaCaptioning(ctx, mediaID, captioning)
+}
+
+// transcriptCues groups a transcript's words into cues
+func transcriptCues(words []domain.TranscriptWord) []webvtt.Cue {
+	var cues []webvtt.Cue
+	var cue *webvtt.Cue
+	flush := func() {
+		if cue != nil {
+			cues = append(cues, *cue)
+			cue = nil
+		}
+	}
+
+	for _, word := range words {
+		if word.Punctuation {
+			if cue == nil {
+				continue
+			}
+			cue.Text += word.Text
+			if strings.ContainsAny(word.Text, ".?!") {
+				flush()
+			}
+			continue
+		}
+
+		if cue != nil && (word.Start-cue.End > cuePause ||
+			word.End-cue.Start > maxCueDuration ||
+			len(cue.Text)+1+len(word.Text) > maxCueLength) {
+			flush()
+		}
+		if cue == nil {
+			cue = &webvtt.Cue{Start: word.Start, End: word.End, Text: word.Text}
+			continue
+		}
+		cue.Text += " " + word.Text
+		cue.End = word.End
+	}
+	flush()
+
+	return cues
+}
+
+// sameText reports whether two variants of cues have the same text
+func sameText(a, b []webvtt.Cue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Text != b[i].Text {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package captions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+
+	"github.com/streaming-service/internal/correlation"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/webvtt"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// MaxTranslationLanguages bounds the languages one request translates a
+// track into
+const MaxTranslationLanguages = 10
+
+// Translator translates text between languages
+type Translator interface {
+	// Translate translates texts from the source language to the target,
+	// returning the translations in the same order
+	Translate(ctx context.Context, texts []string, source, target string) ([]string, error)
+}
+
+// RequestTranslation queues a job translating a subtitle track of the
+// user's media item into languages, returning the job's ID. Each
+// translation becomes a track with the target language as its ID.
+func (s *Service) RequestTranslation(ctx context.Context, mediaID, userID, trackID string, languages []string) (string, error) {
+	if s.queue == nil {
+		return "", domain.ErrQueueUnavailable
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return "", err
+	}
+	if media.UserID != userID {
+		return "", domain.ErrUnauthorized
+	}
+	track := media.GetSubtitleTrack(trackID)
+	if track == nil {
+		return "", domain.ErrSubtitleNotFound
+	}
+
+	targets, err := parseLanguages(languages, track.Language)
+	if err != nil {
+		return "", err
+	}
+	// Translations replace earlier translations, but not other tracks
+	for _, target := range targets {
+		if t := media.GetSubtitleTrack(target); t != nil && t.Source != domain.SubtitleSourceTranslation {
+			return "", domain.ErrInvalidInput
+		}
+	}
+
+	job := &queue.Job{
+		ID:      uuid.New().String(),
+		Type:    queue.JobTypeTranslate,
+		MediaID: mediaID,
+		Payload: map[string]string{
+			"track":     trackID,
+			"languages": strings.Join(targets, ","),
+		},
+		RequestID: correlation.ID(ctx),
+		TenantID:  tenant.FromContext(ctx),
+	}
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		return "", err
+	}
+
+	s.log.Info("subtitle translation queued", "media_id", mediaID, "track", trackID, "languages", targets)
+
+	return job.ID, nil
+}
+
+// parseLanguages canonicalizes the target languages, rejecting invalid
+// and duplicate ones and the source language itself
+func parseLanguages(languages []string, source string) ([]string, error) {
+	if len(languages) == 0 || len(languages) > MaxTranslationLanguages {
+		return nil, domain.ErrInvalidInput
+	}
+
+	seen := map[string]bool{source: true}
+	targets := make([]string, 0, len(languages))
+	for _, l := range languages {
+		tag, err := language.Parse(l)
+		if err != nil {
+			return nil, domain.ErrInvalidInput
+		}
+		target := tag.String()
+		if seen[target] {
+			return nil, domain.ErrInvalidInput
+		}
+		seen[target] = true
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// Translate runs a translation job: it translates a subtitle track's cues
+// into each language and adds the translations as tracks
+func (s *Service) Translate(ctx context.Context, mediaID, trackID string, languages []string) error {
+	if s.translator == nil {
+		return fmt.Errorf("no translation provider configured")
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	source := media.GetSubtitleTrack(trackID)
+	if source == nil {
+		return domain.ErrSubtitleNotFound
+	}
+	sourceLanguage := source.Language
+
+	cues, err := s.Cues(ctx, media, trackID)
+	if err != nil {
+		return err
+	}
+	texts := make([]string, len(cues))
+	for i, cue := range cues {
+		texts[i] = cue.Text
+	}
+
+	for _, target := range languages {
+		tag, err := language.Parse(target)
+		if err != nil {
+			return fmt.Errorf("invalid language %q: %w", target, err)
+		}
+
+		translated, err := s.translator.Translate(ctx, texts, sourceLanguage, tag.String())
+		if err != nil {
+			return fmt.Errorf("failed to translate into %s: %w", target, err)
+		}
+		if len(translated) != len(cues) {
+			return fmt.Errorf("translation into %s returned %d cues, want %d", target, len(translated), len(cues))
+		}
+
+		translatedCues := make([]webvtt.Cue, len(cues))
+		for i, cue := range cues {
+			cue.Text = translated[i]
+			translatedCues[i] = cue
+		}
+
+		track := domain.SubtitleTrack{
+			ID:             tag.String(),
+			Language:       tag.String(),
+			Name:           languageName(tag),
+			Source:         domain.SubtitleSourceTranslation,
+			TranslatedFrom: trackID,
+		}
+		if err := s.AddTrack(ctx, media, track, translatedCues); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
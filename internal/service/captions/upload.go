@@ -0,0 +1,83 @@
+package captions
+
+import (
+	"context"
+
+	"golang.org/x/text/language"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/webvtt"
+)
+
+// TrackUpload is a subtitle file uploaded as a track
+type TrackUpload struct {
+	// ID names the track; empty uses the language
+	ID string
+	// Language is a BCP 47 language tag
+	Language string
+	// Name is shown in players' subtitle menus; empty uses the name of
+	// the language in that language
+	Name string
+	// Default makes the track the one players select, in place of any
+	// other default
+	Default bool
+	// Data is the SRT or WebVTT file
+	Data []byte
+}
+
+// IsValidLanguage reports whether tag is a BCP 47 language tag
+func IsValidLanguage(tag string) bool {
+	_, err := language.Parse(tag)
+	return err == nil
+}
+
+// UploadTrack adds an uploaded SRT or WebVTT file as a subtitle track of
+// the user's media item, replacing any track with the same ID. Files
+// without cues fail with ErrInvalidInput.
+func (s *Service) UploadTrack(ctx context.Context, mediaID, userID string, upload *TrackUpload) (*domain.SubtitleTrack, error) {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if media.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	tag, err := language.Parse(upload.Language)
+	if err != nil {
+		return nil, domain.ErrInvalidInput
+	}
+	cues, err := webvtt.Parse(upload.Data)
+	if err != nil || len(cues) == 0 {
+		return nil, domain.ErrInvalidInput
+	}
+
+	track := domain.SubtitleTrack{
+		ID:       upload.ID,
+		Language: tag.String(),
+		Name:     upload.Name,
+		Source:   domain.SubtitleSourceUpload,
+		Default:  upload.Default,
+	}
+	if track.ID == "" {
+		track.ID = track.Language
+	}
+	if track.Name == "" {
+		track.Name = languageName(tag)
+	}
+
+	// Only one track is the default
+	if track.Default {
+		tracks := make([]domain.SubtitleTrack, len(media.Subtitles))
+		copy(tracks, media.Subtitles)
+		for i := range tracks {
+			tracks[i].Default = false
+		}
+		media.Subtitles = tracks
+	}
+
+	if err := s.AddTrack(ctx, media, track, cues); err != nil {
+		return nil, err
+	}
+	return media.GetSubtitleTrack(track.ID), nil
+}
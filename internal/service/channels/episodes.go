@@ -0,0 +1,159 @@
+package channels
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/service/stream"
+)
+
+// maxEpisodePages bounds how many pages of a channel's media are read to
+// collect its episodes
+const maxEpisodePages = 10
+
+// EpisodeRequest describes an episode being published
+type EpisodeRequest struct {
+	// PublishAt schedules the release; zero releases it now
+	PublishAt time.Time
+	Season    int
+	Number    int
+	Explicit  bool
+}
+
+// PublishEpisode publishes an audio media item to a channel as a podcast
+// episode, or changes its episode details. The user must own both.
+func (s *Service) PublishEpisode(ctx context.Context, channelID, mediaID, userID string, req *EpisodeRequest) (*domain.Episode, error) {
+	if req.Season < 0 || req.Number < 0 {
+		return nil, domain.ErrInvalidInput
+	}
+
+	if _, err := s.getOwned(ctx, channelID, userID); err != nil {
+		return nil, err
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if media.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+	if media.Type != domain.MediaTypeAudio {
+		return nil, domain.ErrInvalidMediaType
+	}
+
+	episode := &domain.Episode{
+		PublishAt: req.PublishAt.UTC(),
+		Season:    req.Season,
+		Number:    req.Number,
+		Explicit:  req.Explicit,
+	}
+	if episode.PublishAt.IsZero() {
+		episode.PublishAt = time.Now().UTC()
+	}
+
+	if err := s.dynamoClient.SetMediaEpisode(ctx, mediaID, channelID, episode); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("episode published", "channel_id", channelID, "media_id", mediaID, "publish_at", episode.PublishAt)
+
+	return episode, nil
+}
+
+// UnpublishEpisode withdraws an episode from a channel owned by the user.
+// The media stays published to the channel.
+func (s *Service) UnpublishEpisode(ctx context.Context, channelID, mediaID, userID string) error {
+	if _, err := s.getOwned(ctx, channelID, userID); err != nil {
+		return err
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	if media.ChannelID != channelID || media.Episode == nil {
+		return domain.ErrMediaNotFound
+	}
+
+	if err := s.dynamoClient.RemoveMediaEpisode(ctx, mediaID); err != nil {
+		return err
+	}
+
+	s.log.Info("episode unpublished", "channel_id", channelID, "media_id", mediaID)
+
+	return nil
+}
+
+// ListEpisodes lists a page of a channel's episodes, latest first. The
+// owner also sees scheduled and unprocessed episodes; others see released
+// episodes of processed public media.
+func (s *Service) ListEpisodes(ctx context.Context, channelID, userID string, limit int32, cursor string) ([]*stream.MediaInfo, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset := 0
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 0 {
+			return nil, "", domain.ErrInvalidInput
+		}
+		offset = n
+	}
+
+	channel, err := s.dynamoClient.GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	episodes, err := s.episodes(ctx, channelID, channel.UserID == userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if offset >= len(episodes) {
+		return []*stream.MediaInfo{}, "", nil
+	}
+	end := offset + int(limit)
+	next := strconv.Itoa(end)
+	if end >= len(episodes) {
+		end, next = len(episodes), ""
+	}
+
+	return episodes[offset:end], next, nil
+}
+
+// episodes collects a channel's episodes, latest first. Unless includeAll
+// is set, only released episodes of processed public media are included.
+func (s *Service) episodes(ctx context.Context, channelID string, includeAll bool) ([]*stream.MediaInfo, error) {
+	now := time.Now()
+	var episodes []*stream.MediaInfo
+
+	cursor := ""
+	for i := 0; i < maxEpisodePages; i++ {
+		mediaList, next, err := s.stream.ListChannelMedia(ctx, channelID, includeAll, 100, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, media := range mediaList {
+			if media.Episode == nil || (!includeAll && !media.Episode.IsReleased(now)) {
+				continue
+			}
+			episodes = append(episodes, media)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	sort.SliceStable(episodes, func(i, j int) bool {
+		return episodes[i].Episode.PublishAt.After(episodes[j].Episode.PublishAt)
+	})
+
+	return episodes, nil
+}
@@ -0,0 +1,120 @@
+package channels
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// itunesNamespace is the namespace of the podcast extensions to RSS
+const itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// hlsContentType is the type of the HLS playlists episodes are enclosed as
+const hlsContentType = "application/vnd.apple.mpegurl"
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string       `xml:"title"`
+	Description   string       `xml:"description"`
+	LastBuildDate string       `xml:"lastBuildDate"`
+	Image         *itunesImage `xml:"itunes:image,omitempty"`
+	Explicit      bool         `xml:"itunes:explicit"`
+	Items         []rssItem    `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Description string       `xml:"description,omitempty"`
+	GUID        rssGUID      `xml:"guid"`
+	PubDate     string       `xml:"pubDate"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+	Duration    int          `xml:"itunes:duration,omitempty"`
+	Season      int          `xml:"itunes:season,omitempty"`
+	Episode     int          `xml:"itunes:episode,omitempty"`
+	Explicit    bool         `xml:"itunes:explicit"`
+	Image       *itunesImage `xml:"itunes:image,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// Feed renders a channel's released episodes as a podcast RSS feed. The
+// channel is marked explicit when any of its episodes is.
+func (s *Service) Feed(ctx context.Context, channelID string) ([]byte, error) {
+	channel, err := s.Get(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	episodes, err := s.episodes(ctx, channelID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		ITunes:  itunesNamespace,
+		Channel: rssChannel{
+			Title:         channel.Title,
+			Description:   channel.Description,
+			LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+	if channel.ArtworkURL != "" {
+		feed.Channel.Image = &itunesImage{Href: channel.ArtworkURL}
+	}
+
+	largest := strconv.Itoa(domain.CoverArtSizes[0])
+	for _, media := range episodes {
+		if media.PlaybackURL == "" {
+			continue
+		}
+
+		item := rssItem{
+			Title:       media.Title,
+			Description: media.Description,
+			GUID:        rssGUID{Value: media.ID},
+			PubDate:     media.Episode.PublishAt.Format(time.RFC1123Z),
+			// The length of an HLS presentation isn't known up front
+			Enclosure: rssEnclosure{URL: media.PlaybackURL, Type: hlsContentType},
+			Duration:  int(media.Duration),
+			Season:    media.Episode.Season,
+			Episode:   media.Episode.Number,
+			Explicit:  media.Episode.Explicit,
+		}
+		if url := media.CoverArt[largest]; url != "" {
+			item.Image = &itunesImage{Href: url}
+		}
+		feed.Channel.Explicit = feed.Channel.Explicit || item.Explicit
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render feed: %w", err)
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
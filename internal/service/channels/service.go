@@ -0,0 +1,287 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/cloudfront"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// artworkTypes maps accepted artwork content types to file extensions
+var artworkTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// Service manages channels and the media published to them
+type Service struct {
+	s3Client         *s3.Client
+	dynamoClient     *dynamodb.Client
+	stream           *stream.Service
+	cloudFrontDomain string
+	log              *logger.Logger
+}
+
+// NewService creates a new channels service
+func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, streamService *stream.Service, cloudFrontDomain string, log *logger.Logger) *Service {
+	return &Service{
+		s3Client:         s3Client,
+		dynamoClient:     dynamoClient,
+		stream:           streamService,
+		cloudFrontDomain: cloudFrontDomain,
+		log:              log,
+	}
+}
+
+// UpdateRequest contains the channel fields to change; nil fields are
+// left as they are
+type UpdateRequest struct {
+	Title       *string
+	Description *string
+}
+
+// Create creates a channel owned by the user
+func (s *Service) Create(ctx context.Context, userID, title, description string) (*domain.Channel, error) {
+	if title == "" {
+		return nil, domain.ErrInvalidInput
+	}
+
+	now := time.Now()
+	channel := &domain.Channel{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Title:       title,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.dynamoClient.CreateChannel(ctx, channel); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("channel created", "channel_id", channel.ID, "user_id", userID)
+
+	return channel, nil
+}
+
+// Get returns a channel. Channels are public.
+func (s *Service) Get(ctx context.Context, channelID string) (*domain.Channel, error) {
+	channel, err := s.dynamoClient.GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setArtworkURL(channel)
+
+	return channel, nil
+}
+
+// List lists a page of the user's channels, newest first
+func (s *Service) List(ctx context.Context, userID string, limit int32, cursor string) ([]*domain.Channel, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	channels, next, err := s.dynamoClient.ListChannelsByUser(ctx, userID, limit, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, channel := range channels {
+		s.setArtworkURL(channel)
+	}
+
+	return channels, next, nil
+}
+
+// Update changes a channel owned by the user
+func (s *Service) Update(ctx context.Context, channelID, userID string, req *UpdateRequest) (*domain.Channel, error) {
+	channel, err := s.getOwned(ctx, channelID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != nil {
+		if *req.Title == "" {
+			return nil, domain.ErrInvalidInput
+		}
+		channel.Title = *req.Title
+	}
+	if req.Description != nil {
+		channel.Description = *req.Description
+	}
+
+	channel.UpdatedAt = time.Now()
+	if err := s.dynamoClient.UpdateChannel(ctx, channel); err != nil {
+		return nil, err
+	}
+
+	s.setArtworkURL(channel)
+
+	return channel, nil
+}
+
+// SetArtwork replaces a channel's artwork image
+func (s *Service) SetArtwork(ctx context.Context, channelID, userID string, body io.Reader, contentType string) (*domain.Channel, error) {
+	ext, ok := artworkTypes[contentType]
+	if !ok {
+		return nil, domain.ErrInvalidInput
+	}
+
+	channel, err := s.getOwned(ctx, channelID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldKey := channel.ArtworkKey
+	channel.ArtworkKey = channel.GetArtworkKey(time.Now().Unix(), ext)
+
+	if err := s.s3Client.UploadWithCacheControl(ctx, s.s3Client.GetProcessedBucket(), channel.ArtworkKey, body, contentType, "public, max-age=31536000, immutable"); err != nil {
+		return nil, fmt.Errorf("failed to upload artwork: %w", err)
+	}
+
+	channel.UpdatedAt = time.Now()
+	if err := s.dynamoClient.UpdateChannel(ctx, channel); err != nil {
+		return nil, err
+	}
+
+	if oldKey != "" && oldKey != channel.ArtworkKey {
+		if err := s.s3Client.Delete(ctx, s.s3Client.GetProcessedBucket(), oldKey); err != nil {
+			s.log.Error("failed to delete old artwork", "error", err, "key", oldKey)
+		}
+	}
+
+	s.setArtworkURL(channel)
+
+	s.log.Info("channel artwork updated", "channel_id", channelID)
+
+	return channel, nil
+}
+
+// Delete deletes a channel owned by the user, unpublishing its media
+func (s *Service) Delete(ctx context.Context, channelID, userID string) error {
+	channel, err := s.getOwned(ctx, channelID, userID)
+	if err != nil {
+		return err
+	}
+
+	cursor := ""
+	for {
+		mediaList, next, err := s.dynamoClient.ListMediaByChannel(ctx, channelID, false, 100, cursor)
+		if err != nil {
+			return err
+		}
+		for _, media := range mediaList {
+			if err := s.dynamoClient.UpdateMediaChannel(ctx, media.ID, ""); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if err := s.dynamoClient.DeleteChannel(ctx, channelID); err != nil {
+		return err
+	}
+
+	if channel.ArtworkKey != "" {
+		if err := s.s3Client.Delete(ctx, s.s3Client.GetProcessedBucket(), channel.ArtworkKey); err != nil {
+			s.log.Error("failed to delete artwork", "error", err, "key", channel.ArtworkKey)
+		}
+	}
+
+	s.log.Info("channel deleted", "channel_id", channelID)
+
+	return nil
+}
+
+// Publish publishes a media item to a channel. The user must own both; a
+// media item belongs to at most one channel.
+func (s *Service) Publish(ctx context.Context, channelID, mediaID, userID string) error {
+	if _, err := s.getOwned(ctx, channelID, userID); err != nil {
+		return err
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	if media.UserID != userID {
+		return domain.ErrUnauthorized
+	}
+
+	if err := s.dynamoClient.UpdateMediaChannel(ctx, mediaID, channelID); err != nil {
+		return err
+	}
+
+	s.log.Info("media published to channel", "channel_id", channelID, "media_id", mediaID)
+
+	return nil
+}
+
+// Unpublish removes a media item from a channel owned by the user
+func (s *Service) Unpublish(ctx context.Context, channelID, mediaID, userID string) error {
+	if _, err := s.getOwned(ctx, channelID, userID); err != nil {
+		return err
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	if media.ChannelID != channelID {
+		return domain.ErrMediaNotFound
+	}
+
+	if err := s.dynamoClient.UpdateMediaChannel(ctx, mediaID, ""); err != nil {
+		return err
+	}
+
+	s.log.Info("media unpublished from channel", "channel_id", channelID, "media_id", mediaID)
+
+	return nil
+}
+
+// ListMedia lists a page of a channel's media, newest first. The owner
+// sees everything published to it; others see processed public media.
+func (s *Service) ListMedia(ctx context.Context, channelID, userID string, limit int32, cursor string) ([]*stream.MediaInfo, string, error) {
+	channel, err := s.dynamoClient.GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.stream.ListChannelMedia(ctx, channelID, channel.UserID == userID, limit, cursor)
+}
+
+// getOwned loads a channel, checking it belongs to the user
+func (s *Service) getOwned(ctx context.Context, channelID, userID string) (*domain.Channel, error) {
+	channel, err := s.dynamoClient.GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if channel.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	return channel, nil
+}
+
+// setArtworkURL fills in the public artwork URL when a CDN is configured
+func (s *Service) setArtworkURL(channel *domain.Channel) {
+	if channel.ArtworkKey != "" && s.cloudFrontDomain != "" {
+		channel.ArtworkURL = cloudfront.URL(s.cloudFrontDomain, channel.ArtworkKey)
+	}
+}
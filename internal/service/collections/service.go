@@ -0,0 +1,235 @@
+package collections
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Service manages collections of media
+type Service struct {
+	dynamoClient *dynamodb.Client
+	stream       *stream.Service
+	log          *logger.Logger
+}
+
+// NewService creates a new collections service
+func NewService(dynamoClient *dynamodb.Client, streamService *stream.Service, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		stream:       streamService,
+		log:          log,
+	}
+}
+
+// CreateRequest contains the fields for a new collection
+type CreateRequest struct {
+	Title       string
+	Description string
+	// Visibility defaults to public when empty
+	Visibility domain.Visibility
+	MediaIDs   []string
+}
+
+// UpdateRequest contains the collection fields to change; nil fields are
+// left as they are
+type UpdateRequest struct {
+	Title       *string
+	Description *string
+	Visibility  *domain.Visibility
+	// MediaIDs replaces the collection's items and their order
+	MediaIDs *[]string
+}
+
+// Create creates a collection owned by the user
+func (s *Service) Create(ctx context.Context, userID string, req *CreateRequest) (*domain.Collection, error) {
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = domain.VisibilityPublic
+	}
+	if req.Title == "" || !visibility.IsValid() {
+		return nil, domain.ErrInvalidInput
+	}
+
+	mediaIDs := req.MediaIDs
+	if mediaIDs == nil {
+		mediaIDs = []string{}
+	}
+	if err := s.validateItems(ctx, userID, mediaIDs); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	collection := &domain.Collection{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Title:       req.Title,
+		Description: req.Description,
+		Visibility:  visibility,
+		MediaIDs:    mediaIDs,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.dynamoClient.CreateCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("collection created", "collection_id", collection.ID, "user_id", userID, "items", len(mediaIDs))
+
+	return collection, nil
+}
+
+// Get returns a collection visible to the user. Private collections of
+// other users are reported as not found.
+func (s *Service) Get(ctx context.Context, collectionID, userID string) (*domain.Collection, error) {
+	collection, err := s.dynamoClient.GetCollection(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !collection.CanView(userID) {
+		return nil, domain.ErrCollectionNotFound
+	}
+
+	return collection, nil
+}
+
+// List lists a page of the user's collections, newest first
+func (s *Service) List(ctx context.Context, userID string, limit int32, cursor string) ([]*domain.Collection, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.dynamoClient.ListCollectionsByUser(ctx, userID, limit, cursor)
+}
+
+// Update changes a collection owned by the user
+func (s *Service) Update(ctx context.Context, collectionID, userID string, req *UpdateRequest) (*domain.Collection, error) {
+	collection, err := s.getOwned(ctx, collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != nil {
+		if *req.Title == "" {
+			return nil, domain.ErrInvalidInput
+		}
+		collection.Title = *req.Title
+	}
+	if req.Description != nil {
+		collection.Description = *req.Description
+	}
+	if req.Visibility != nil {
+		if !req.Visibility.IsValid() {
+			return nil, domain.ErrInvalidInput
+		}
+		collection.Visibility = *req.Visibility
+	}
+	if req.MediaIDs != nil {
+		mediaIDs := *req.MediaIDs
+		if mediaIDs == nil {
+			mediaIDs = []string{}
+		}
+		if err := s.validateItems(ctx, userID, mediaIDs); err != nil {
+			return nil, err
+		}
+		collection.MediaIDs = mediaIDs
+	}
+
+	collection.UpdatedAt = time.Now()
+	if err := s.dynamoClient.UpdateCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("collection updated", "collection_id", collectionID, "items", len(collection.MediaIDs))
+
+	return collection, nil
+}
+
+// Delete deletes a collection owned by the user. Its media are untouched.
+func (s *Service) Delete(ctx context.Context, collectionID, userID string) error {
+	if _, err := s.getOwned(ctx, collectionID, userID); err != nil {
+		return err
+	}
+
+	if err := s.dynamoClient.DeleteCollection(ctx, collectionID); err != nil {
+		return err
+	}
+
+	s.log.Info("collection deleted", "collection_id", collectionID)
+
+	return nil
+}
+
+// Playback returns a collection with the playback URL of each playable
+// item, in collection order. Items that have since been deleted, made
+// private or are still processing are left out.
+func (s *Service) Playback(ctx context.Context, collectionID string, session *stream.PlaybackSession) (*domain.Collection, []*stream.PlaybackItem, error) {
+	var userID string
+	if session != nil {
+		userID = session.UserID
+	}
+
+	collection, err := s.Get(ctx, collectionID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items, err := s.stream.GetPlaybackItems(ctx, collection.MediaIDs, session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return collection, items, nil
+}
+
+// getOwned loads a collection, checking it belongs to the user
+func (s *Service) getOwned(ctx context.Context, collectionID, userID string) (*domain.Collection, error) {
+	collection, err := s.dynamoClient.GetCollection(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	return collection, nil
+}
+
+// validateItems checks a collection's items exist and are visible to its
+// owner
+func (s *Service) validateItems(ctx context.Context, userID string, mediaIDs []string) error {
+	if len(mediaIDs) > domain.MaxCollectionItems {
+		return domain.ErrInvalidInput
+	}
+	for _, id := range mediaIDs {
+		if id == "" {
+			return domain.ErrInvalidInput
+		}
+	}
+
+	mediaList, err := s.dynamoClient.BatchGetMedia(ctx, mediaIDs)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool, len(mediaList))
+	for _, media := range mediaList {
+		if media.CanView(userID) {
+			found[media.ID] = true
+		}
+	}
+	for _, id := range mediaIDs {
+		if !found[id] {
+			return domain.ErrMediaNotFound
+		}
+	}
+
+	return nil
+}
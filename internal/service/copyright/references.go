@@ -0,0 +1,48 @@
+package copyright
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/streaming-service/internal/media/chromaprint"
+)
+
+// Reference is a copyrighted recording media is matched against
+type Reference struct {
+	ID          string
+	Title       string
+	Fingerprint chromaprint.Fingerprint
+}
+
+// LoadReferences reads the reference set from the file at path, a JSON
+// array of objects with an id, a title and the recording's raw
+// fingerprint as printed by fpcalc -raw
+func LoadReferences(path string) ([]Reference, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read references: %w", err)
+	}
+
+	var refs []struct {
+		ID          string        `json:"id"`
+		Title       string        `json:"title"`
+		Fingerprint []json.Number `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return nil, fmt.Errorf("failed to parse references: %w", err)
+	}
+
+	references := make([]Reference, 0, len(refs))
+	for _, ref := range refs {
+		if ref.ID == "" || len(ref.Fingerprint) == 0 {
+			return nil, fmt.Errorf("reference %q needs an id and a fingerprint", ref.ID)
+		}
+		fp, err := chromaprint.ParseItems(ref.Fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("reference %s: %w", ref.ID, err)
+		}
+		references = append(references, Reference{ID: ref.ID, Title: ref.Title, Fingerprint: fp})
+	}
+	return references, nil
+}
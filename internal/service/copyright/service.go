@@ -0,0 +1,95 @@
+// Package copyright matches the audio of ingested media against a
+// reference set of copyrighted recordings
+package copyright
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/chromaprint"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Fingerprinter computes audio fingerprints of media files
+type Fingerprinter interface {
+	Fingerprint(ctx context.Context, path string) (chromaprint.Fingerprint, error)
+}
+
+// Service fingerprints media audio and flags matches with references
+type Service struct {
+	dynamoClient  *dynamodb.Client
+	fingerprinter Fingerprinter
+	references    []Reference
+	cfg           config.CopyrightConfig
+	log           *logger.Logger
+}
+
+// NewService creates a new copyright service matching against
+// references
+func NewService(dynamoClient *dynamodb.Client, fingerprinter Fingerprinter, references []Reference, cfg config.CopyrightConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient:  dynamoClient,
+		fingerprinter: fingerprinter,
+		references:    references,
+		cfg:           cfg,
+		log:           log,
+	}
+}
+
+// Check fingerprints the audio of the source at sourcePath, matches it
+// against the references and records the result on the media. Matches
+// only flag the media; they don't hold it back.
+func (s *Service) Check(ctx context.Context, mediaID, sourcePath string) (*domain.CopyrightCheck, error) {
+	result := &domain.CopyrightCheck{
+		Status:    domain.CopyrightStatusClear,
+		CheckedAt: time.Now().UTC(),
+	}
+
+	fp, err := s.fingerprinter.Fingerprint(ctx, sourcePath)
+	if err != nil {
+		result.Status = domain.CopyrightStatusError
+		result.Error = err.Error()
+	} else if result.Matches = s.match(fp); len(result.Matches) > 0 {
+		result.Status = domain.CopyrightStatusConflict
+	}
+
+	if err := s.dynamoClient.SetMediaCopyright(ctx, mediaID, result); err != nil {
+		return nil, err
+	}
+
+	if result.Status == domain.CopyrightStatusConflict {
+		s.log.Warn("media matches copyrighted references", "media_id", mediaID,
+			"matches", len(result.Matches), "reference_id", result.Matches[0].ReferenceID)
+	}
+
+	return result, err
+}
+
+// match returns the references fp scores at least the minimum score
+// against, best first
+func (s *Service) match(fp chromaprint.Fingerprint) []domain.CopyrightMatch {
+	minOverlap := int(s.cfg.MinOverlap.Seconds() / chromaprint.ItemDuration)
+
+	var matches []domain.CopyrightMatch
+	for _, ref := range s.references {
+		score, offset := chromaprint.Compare(fp, ref.Fingerprint, minOverlap)
+		if score < s.cfg.MinScore {
+			continue
+		}
+		matches = append(matches, domain.CopyrightMatch{
+			ReferenceID: ref.ID,
+			Title:       ref.Title,
+			Score:       score,
+			Offset:      float64(offset) * chromaprint.ItemDuration,
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
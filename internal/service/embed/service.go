@@ -0,0 +1,154 @@
+package embed
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/token"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// MaxOrigins bounds the sites a single embed token may be restricted to
+const MaxOrigins = 10
+
+// Service issues and verifies embed tokens, which grant playback of a
+// single media item to third-party sites without user authentication
+type Service struct {
+	dynamoClient *dynamodb.Client
+	signer       *token.Signer
+	defaultTTL   time.Duration
+	maxTTL       time.Duration
+	log          *logger.Logger
+}
+
+// NewService creates a new embed token service
+func NewService(dynamoClient *dynamodb.Client, signer *token.Signer, cfg config.PlaybackConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		signer:       signer,
+		defaultTTL:   cfg.EmbedTokenTTL,
+		maxTTL:       cfg.MaxEmbedTokenTTL,
+		log:          log,
+	}
+}
+
+// MaxTTL returns the longest lifetime an embed token may be issued for
+func (s *Service) MaxTTL() time.Duration {
+	return s.maxTTL
+}
+
+// Token is an issued embed token
+type Token struct {
+	Token     string    `json:"token"`
+	MediaID   string    `json:"media_id"`
+	Origins   []string  `json:"origins,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IssueRequest contains the fields for a new embed token
+type IssueRequest struct {
+	MediaID string
+	UserID  string
+	// TTL is how long the token is valid; zero uses the default
+	TTL time.Duration
+	// Origins restricts the sites that may present the token
+	Origins []string
+}
+
+// Issue signs an embed token for a media item owned by the user
+func (s *Service) Issue(ctx context.Context, req *IssueRequest) (*Token, error) {
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = s.defaultTTL
+	}
+	if ttl < 0 || ttl > s.maxTTL || len(req.Origins) > MaxOrigins {
+		return nil, domain.ErrInvalidInput
+	}
+
+	origins := make([]string, 0, len(req.Origins))
+	for _, origin := range req.Origins {
+		normalized, ok := NormalizeOrigin(origin)
+		if !ok {
+			return nil, domain.ErrInvalidInput
+		}
+		origins = append(origins, normalized)
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, req.MediaID)
+	if err != nil {
+		return nil, err
+	}
+	if !media.CanView(req.UserID) {
+		return nil, domain.ErrMediaNotFound
+	}
+	if media.UserID != req.UserID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	tok, err := s.signer.Sign(token.Claims{
+		MediaID:   media.ID,
+		UserID:    req.UserID,
+		Scope:     token.ScopeEmbed,
+		ExpiresAt: expiresAt.Unix(),
+		Origins:   origins,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("embed token issued", "media_id", media.ID, "user_id", req.UserID, "expires_at", expiresAt)
+
+	return &Token{
+		Token:     tok,
+		MediaID:   media.ID,
+		Origins:   origins,
+		ExpiresAt: expiresAt.UTC().Truncate(time.Second),
+	}, nil
+}
+
+// Verify checks that tok grants playback of mediaID to a page on origin,
+// the request's Origin header. Tokens restricted to sites are refused
+// without one.
+func (s *Service) Verify(tok, mediaID, origin string) error {
+	claims, err := s.signer.Verify(tok)
+	if err != nil {
+		return domain.ErrUnauthorized
+	}
+
+	if claims.Scope != token.ScopeEmbed || claims.MediaID != mediaID {
+		return domain.ErrUnauthorized
+	}
+
+	if len(claims.Origins) == 0 {
+		return nil
+	}
+	origin, ok := NormalizeOrigin(origin)
+	if !ok {
+		return domain.ErrUnauthorized
+	}
+	for _, allowed := range claims.Origins {
+		if allowed == origin {
+			return nil
+		}
+	}
+	return domain.ErrUnauthorized
+}
+
+// NormalizeOrigin reduces a site such as "https://Example.com/" to its
+// origin, reporting false when it is not an http or https origin
+func NormalizeOrigin(origin string) (string, bool) {
+	u, err := url.Parse(strings.TrimSuffix(origin, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", false
+	}
+	return u.Scheme + "://" + strings.ToLower(u.Host), true
+}
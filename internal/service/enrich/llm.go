@@ -0,0 +1,105 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/webvtt"
+	"github.com/streaming-service/internal/repository/llm"
+)
+
+// maxTranscriptLength bounds the transcript sent to the model, in bytes;
+// longer transcripts are cut short
+const maxTranscriptLength = 60000
+
+const systemPrompt = `You write summaries and chapter markers for videos from their transcripts.
+Each transcript line starts with the time it is spoken as [h:mm:ss].
+Reply with a JSON object of the form {"summary": "...", "chapters": [{"start": "h:mm:ss", "title": "..."}]}.
+The summary is two or three sentences. Chapters follow the main topics in order, the first starting at 0:00:00, with titles of a few words.
+Write in the language of the transcript.`
+
+// LLMProvider generates summaries and chapters with a language model
+type LLMProvider struct {
+	client *llm.Client
+}
+
+// NewLLMProvider creates a provider prompting the model behind client
+func NewLLMProvider(client *llm.Client) *LLMProvider {
+	return &LLMProvider{client: client}
+}
+
+// Name returns the model used
+func (p *LLMProvider) Name() string {
+	return p.client.Model()
+}
+
+// Enrich asks the model for a summary and chapters of the transcript
+func (p *LLMProvider) Enrich(ctx context.Context, cues []webvtt.Cue, maxChapters int) (string, []domain.Chapter, error) {
+	prompt := fmt.Sprintf("Write a summary and at most %d chapters for this transcript.\n\n%s", maxChapters, transcript(cues))
+
+	reply, err := p.client.CompleteJSON(ctx, systemPrompt, prompt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var out struct {
+		Summary  string `json:"summary"`
+		Chapters []struct {
+			Start string `json:"start"`
+			Title string `json:"title"`
+		} `json:"chapters"`
+	}
+	if err := json.Unmarshal([]byte(reply), &out); err != nil {
+		return "", nil, fmt.Errorf("failed to parse model reply: %w", err)
+	}
+
+	chapters := make([]domain.Chapter, 0, len(out.Chapters))
+	for _, c := range out.Chapters {
+		start, ok := parseClock(c.Start)
+		if !ok {
+			continue
+		}
+		chapters = append(chapters, domain.Chapter{Start: start, Title: c.Title})
+	}
+
+	return out.Summary, chapters, nil
+}
+
+// transcript formats cues as timestamped lines, cut short at
+// maxTranscriptLength
+func transcript(cues []webvtt.Cue) string {
+	var b strings.Builder
+	for _, cue := range cues {
+		text := strings.Join(strings.Fields(cue.Text), " ")
+		if text == "" {
+			continue
+		}
+		s := int(cue.Start.Seconds())
+		line := fmt.Sprintf("[%d:%02d:%02d] %s\n", s/3600, s/60%60, s%60, text)
+		if b.Len()+len(line) > maxTranscriptLength {
+			break
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// parseClock parses a time of the form h:mm:ss or mm:ss into seconds
+func parseClock(s string) (float64, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var total float64
+	for _, part := range parts {
+		var n float64
+		if _, err := fmt.Sscanf(part, "%g", &n); err != nil || n < 0 {
+			return 0, false
+		}
+		total = total*60 + n
+	}
+	return total, true
+}
@@ -0,0 +1,251 @@
+// Package enrich generates summaries and chapter markers for media from
+// their transcripts
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/correlation"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/webvtt"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/cloudfront"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/service/captions"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// maxTitleLength bounds the length of a chapter title
+const maxTitleLength = 100
+
+// Provider generates a summary and chapters from a transcript
+type Provider interface {
+	// Enrich returns a short summary of the transcript and up to
+	// maxChapters chapters, in any order
+	Enrich(ctx context.Context, cues []webvtt.Cue, maxChapters int) (string, []domain.Chapter, error)
+	// Name identifies the provider on the results
+	Name() string
+}
+
+// Service queues and runs enrichment jobs
+type Service struct {
+	s3Client     *s3.Client
+	dynamoClient *dynamodb.Client
+	captions     *captions.Service
+	queue        queue.Queue
+	provider     Provider
+	cdn          *cloudfront.Client
+	cfg          config.EnrichmentConfig
+	log          *logger.Logger
+}
+
+// NewService creates a new enrichment service; it reads transcripts
+// through captionsService
+func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, captionsService *captions.Service, cfg config.EnrichmentConfig, log *logger.Logger) *Service {
+	return &Service{
+		s3Client:     s3Client,
+		dynamoClient: dynamoClient,
+		captions:     captionsService,
+		cfg:          cfg,
+		log:          log,
+	}
+}
+
+// SetQueue sets the queue enrichment jobs are sent to
+func (s *Service) SetQueue(q queue.Queue) {
+	s.queue = q
+}
+
+// SetProvider sets the provider enrichment jobs use
+func (s *Service) SetProvider(p Provider) {
+	s.provider = p
+}
+
+// SetCDN sets the CloudFront client used for cache invalidation
+func (s *Service) SetCDN(cdn *cloudfront.Client) {
+	s.cdn = cdn
+}
+
+// RequestEnrichment queues a job generating a summary and chapters for
+// the user's media item from one of its subtitle tracks, returning the
+// job's ID. Without a track it uses the default track, or the first.
+func (s *Service) RequestEnrichment(ctx context.Context, mediaID, userID, trackID string) (string, error) {
+	if s.queue == nil {
+		return "", domain.ErrQueueUnavailable
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return "", err
+	}
+	if media.UserID != userID {
+		return "", domain.ErrUnauthorized
+	}
+	track := transcriptTrack(media, trackID)
+	if track == nil {
+		return "", domain.ErrSubtitleNotFound
+	}
+
+	job := &queue.Job{
+		ID:        uuid.New().String(),
+		Type:      queue.JobTypeEnrich,
+		MediaID:   mediaID,
+		Payload:   map[string]string{"track": track.ID},
+		RequestID: correlation.ID(ctx),
+		TenantID:  tenant.FromContext(ctx),
+	}
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		return "", err
+	}
+
+	s.log.Info("enrichment queued", "media_id", mediaID, "track", track.ID)
+
+	return job.ID, nil
+}
+
+// transcriptTrack returns the track with trackID or, when it's empty, the
+// default track or else the first
+func transcriptTrack(media *domain.Media, trackID string) *domain.SubtitleTrack {
+	if trackID != "" {
+		return media.GetSubtitleTrack(trackID)
+	}
+	for i := range media.Subtitles {
+		if media.Subtitles[i].Default {
+			return &media.Subtitles[i]
+		}
+	}
+	if len(media.Subtitles) > 0 {
+		return &media.Subtitles[0]
+	}
+	return nil
+}
+
+// Enrich runs an enrichment job: it generates a summary and chapters from
+// a subtitle track, stores them on the media record and writes the
+// chapters as a WebVTT chapters track
+func (s *Service) Enrich(ctx context.Context, mediaID, trackID string) error {
+	if s.provider == nil {
+		return fmt.Errorf("no enrichment provider configured")
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	if media.GetSubtitleTrack(trackID) == nil {
+		return domain.ErrSubtitleNotFound
+	}
+
+	cues, err := s.captions.Cues(ctx, media, trackID)
+	if err != nil {
+		return err
+	}
+	if len(cues) == 0 {
+		return fmt.Errorf("subtitle track %s has no cues", trackID)
+	}
+
+	summary, chapters, err := s.provider.Enrich(ctx, cues, s.cfg.MaxChapters)
+	if err != nil {
+		return fmt.Errorf("failed to generate enrichment: %w", err)
+	}
+
+	duration := media.Duration
+	if duration <= 0 {
+		duration = cues[len(cues)-1].End.Seconds()
+	}
+	chapters = cleanChapters(chapters, duration, s.cfg.MaxChapters)
+
+	if len(chapters) > 0 {
+		key := media.GetChaptersKey()
+		body := webvtt.Write(chapterCues(chapters, duration))
+		if err := s.s3Client.Upload(ctx, s.s3Client.GetProcessedBucket(), key, bytes.NewReader(body), "text/vtt"); err != nil {
+			return fmt.Errorf("failed to upload chapters: %w", err)
+		}
+		if s.cdn != nil {
+			if _, err := s.cdn.InvalidatePaths(ctx, []string{"/" + key}); err != nil {
+				s.log.Error("failed to invalidate chapters", "error", err, "media_id", mediaID)
+			}
+		}
+	}
+
+	enrichment := &domain.Enrichment{
+		Summary:     strings.TrimSpace(summary),
+		Chapters:    chapters,
+		Track:       trackID,
+		Provider:    s.provider.Name(),
+		GeneratedAt: time.Now().UTC(),
+	}
+	if err := s.dynamoClient.SetMediaEnrichment(ctx, mediaID, enrichment); err != nil {
+		return err
+	}
+
+	s.log.Info("media enriched", "media_id", mediaID, "track", trackID, "chapters", len(chapters))
+
+	return nil
+}
+
+// cleanChapters orders chapters, drops untitled ones and those outside
+// the media, keeps the first of any starting together and caps them at
+// max. The first chapter is moved to the start so chapters cover the
+// whole media.
+func cleanChapters(chapters []domain.Chapter, duration float64, max int) []domain.Chapter {
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapters[i].Start < chapters[j].Start
+	})
+
+	cleaned := make([]domain.Chapter, 0, len(chapters))
+	for _, c := range chapters {
+		c.Title = strings.Join(strings.Fields(c.Title), " ")
+		if c.Title == "" || c.Start < 0 || c.Start >= duration {
+			continue
+		}
+		if r := []rune(c.Title); len(r) > maxTitleLength {
+			c.Title = string(r[:maxTitleLength])
+		}
+		if n := len(cleaned); n > 0 && c.Start-cleaned[n-1].Start < 1 {
+			continue
+		}
+		cleaned = append(cleaned, c)
+		if len(cleaned) == max {
+			break
+		}
+	}
+
+	if len(cleaned) > 0 {
+		cleaned[0].Start = 0
+	}
+	return cleaned
+}
+
+// chapterCues turns chapters into cues, each running until the next
+// chapter or the end of the media
+func chapterCues(chapters []domain.Chapter, duration float64) []webvtt.Cue {
+	cues := make([]webvtt.Cue, len(chapters))
+	for i, c := range chapters {
+		end := duration
+		if i+1 < len(chapters) {
+			end = chapters[i+1].Start
+		}
+		cues[i] = webvtt.Cue{
+			ID:    fmt.Sprintf("chapter-%d", i+1),
+			Start: seconds(c.Start),
+			End:   seconds(end),
+			Text:  c.Title,
+		}
+	}
+	return cues
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,121 @@
+package estimate
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// referencePixels is the frame size ffmpeg.encodespeed is measured at
+	referencePixels = 1920 * 1080
+	// decodeCost is the cost of decoding a frame relative to encoding one
+	// of the same size. Every rendition decodes the whole source.
+	decodeCost = 0.25
+	// containerOverhead is the MPEG-TS packaging added to the media bitrate
+	containerOverhead = 1.06
+)
+
+// codecSpeeds are how fast each codec encodes relative to H.264
+var codecSpeeds = map[string]float64{
+	"h264":    1,
+	"libx264": 1,
+	"hevc":    0.25,
+	"libx265": 0.25,
+}
+
+// Source is what probing a media file reports
+type Source struct {
+	// Duration is in seconds
+	Duration float64
+	Width    int
+	Height   int
+}
+
+// Service forecasts the transcode time and output size of processing a
+// source with a rendition ladder, so callers can compare ladders before
+// committing to one
+type Service struct {
+	// mu guards the ladder and speed, which can be reloaded
+	mu          sync.RWMutex
+	profiles    []config.TranscodeProfile
+	encodeSpeed float64
+
+	log *logger.Logger
+}
+
+// NewService creates a new estimate service
+func NewService(cfg config.FFMPEGConfig, log *logger.Logger) *Service {
+	s := &Service{log: log}
+	s.SetProfiles(cfg)
+	return s
+}
+
+// SetProfiles changes the configured ladder and encode speed
+func (s *Service) SetProfiles(cfg config.FFMPEGConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.profiles = cfg.Profiles
+	s.encodeSpeed = cfg.EncodeSpeed
+}
+
+// Estimate forecasts processing src with profiles, or with the configured
+// ladder when profiles is empty. Renditions are transcoded one after
+// another, so their times add up.
+func (s *Service) Estimate(src Source, profiles []config.TranscodeProfile) (*domain.TranscodeEstimate, error) {
+	s.mu.RLock()
+	speed := s.encodeSpeed
+	if len(profiles) == 0 {
+		profiles = s.profiles
+	}
+	s.mu.RUnlock()
+
+	estimate := &domain.TranscodeEstimate{
+		Duration:     src.Duration,
+		Width:        src.Width,
+		Height:       src.Height,
+		Renditions:   make([]domain.RenditionEstimate, 0, len(profiles)),
+		QuotaMinutes: src.Duration / 60,
+	}
+	decode := float64(src.Width*src.Height) / referencePixels * decodeCost
+
+	for _, p := range profiles {
+		videoBitrate, err := processor.ParseBitrate(p.VideoBitrate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: profile %s: %v", domain.ErrInvalidInput, p.Name, err)
+		}
+		audioBitrate, err := processor.ParseBitrate(p.AudioBitrate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: profile %s: %v", domain.ErrInvalidInput, p.Name, err)
+		}
+		codecSpeed, ok := codecSpeeds[p.Codec]
+		if !ok {
+			return nil, fmt.Errorf("%w: profile %s: unknown codec %q", domain.ErrInvalidInput, p.Name, p.Codec)
+		}
+
+		bitrate := videoBitrate + audioBitrate
+		encode := float64(p.Width*p.Height) / referencePixels / codecSpeed
+		rendition := domain.RenditionEstimate{
+			Name:             p.Name,
+			Width:            p.Width,
+			Height:           p.Height,
+			Codec:            p.Codec,
+			Bitrate:          bitrate,
+			OutputBytes:      int64(float64(bitrate) / 8 * src.Duration * containerOverhead),
+			TranscodeSeconds: math.Round(src.Duration * (encode + decode) / speed),
+			Upscaled:         p.Width > src.Width || p.Height > src.Height,
+		}
+
+		estimate.Renditions = append(estimate.Renditions, rendition)
+		estimate.OutputBytes += rendition.OutputBytes
+		estimate.TranscodeSeconds += rendition.TranscodeSeconds
+	}
+
+	return estimate, nil
+}
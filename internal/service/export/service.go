@@ -0,0 +1,395 @@
+// Package export packages media for download, for customers leaving the
+// service or keeping backups: a zip of the source or an MP4, the HLS
+// manifests, the captions and the metadata, built by the worker and
+// handed out through a presigned link.
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/correlation"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Remuxer copies the streams of a local HLS playlist into an MP4
+type Remuxer interface {
+	RemuxMP4(ctx context.Context, playlist, output string) error
+}
+
+// Service requests, builds and hands out media export packages
+type Service struct {
+	s3Client     *s3.Client
+	dynamoClient *dynamodb.Client
+	queue        queue.Queue
+	remuxer      Remuxer
+	cfg          config.ExportConfig
+	log          *logger.Logger
+}
+
+// NewService creates a new export service
+func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, cfg config.ExportConfig, log *logger.Logger) *Service {
+	return &Service{
+		s3Client:     s3Client,
+		dynamoClient: dynamoClient,
+		cfg:          cfg,
+		log:          log,
+	}
+}
+
+// SetQueue sets the job queue packages are built through
+func (s *Service) SetQueue(q queue.Queue) {
+	s.queue = q
+}
+
+// SetRemuxer sets what makes the MP4 of MP4 exports
+func (s *Service) SetRemuxer(r Remuxer) {
+	s.remuxer = r
+}
+
+// Request queues a package of the user's media item to be built,
+// replacing any earlier one. MP4 packages need processed, unencrypted
+// media.
+func (s *Service) Request(ctx context.Context, mediaID, userID string, format domain.ExportFormat) (*domain.MediaExport, error) {
+	if s.queue == nil {
+		return nil, domain.ErrQueueUnavailable
+	}
+	if !format.IsValid() {
+		return nil, domain.ErrInvalidInput
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if media.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+	if format == domain.ExportFormatMP4 && (!media.IsProcessed() || media.Encryption != nil) {
+		return nil, domain.ErrInvalidMediaStatus
+	}
+	if media.Export != nil && media.Export.Status == domain.ExportStatusPending {
+		return nil, domain.ErrMediaBusy
+	}
+
+	export := &domain.MediaExport{
+		ID:          uuid.New().String(),
+		Status:      domain.ExportStatusPending,
+		Format:      format,
+		RequestedAt: time.Now().UTC(),
+	}
+	if err := s.dynamoClient.SetMediaExport(ctx, mediaID, export); err != nil {
+		return nil, err
+	}
+
+	job := &queue.Job{
+		ID:        uuid.New().String(),
+		Type:      queue.JobTypeExport,
+		MediaID:   mediaID,
+		Payload:   map[string]string{"export_id": export.ID},
+		RequestID: correlation.ID(ctx),
+		TenantID:  tenant.FromContext(ctx),
+	}
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		s.fail(ctx, mediaID, export)
+		return nil, err
+	}
+
+	s.log.Info("export queued", "media_id", mediaID, "export_id", export.ID, "format", format)
+
+	return export, nil
+}
+
+// Get returns the latest export of the user's media item, with a download
+// link once it's built
+func (s *Service) Get(ctx context.Context, mediaID, userID string) (*domain.MediaExport, error) {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if media.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+	export := media.Export
+	if export == nil {
+		return nil, domain.ErrExportNotFound
+	}
+
+	if export.Status == domain.ExportStatusCompleted {
+		url, err := s.s3Client.GetPresignedDownloadURL(ctx, s.s3Client.GetRawBucket(), export.Key, s.cfg.URLExpiry)
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := time.Now().UTC().Add(s.cfg.URLExpiry)
+		export.URL, export.URLExpiresAt = url, &expiresAt
+	}
+
+	return export, nil
+}
+
+// Build runs an export job: it writes the package to a temporary file and
+// stores it in the raw media bucket. An export replaced since it was
+// queued is skipped. The final attempt marks the export failed.
+func (s *Service) Build(ctx context.Context, mediaID, exportID string, final bool) error {
+	log := logger.FromContext(ctx, s.log)
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	export := media.Export
+	if export == nil || export.ID != exportID {
+		log.Info("export superseded, skipping", "export_id", exportID)
+		return nil
+	}
+
+	if err := s.build(ctx, media, export); err != nil {
+		if final {
+			s.fail(ctx, mediaID, export)
+		}
+		return err
+	}
+
+	log.Info("export built", "export_id", exportID, "size", export.Size)
+
+	return nil
+}
+
+// build writes, uploads and records the package
+func (s *Service) build(ctx context.Context, media *domain.Media, export *domain.MediaExport) error {
+	dir, err := os.MkdirTemp("", "export-"+media.ID+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	packagePath := filepath.Join(dir, "package.zip")
+	if err := s.writePackage(ctx, media, export.Format, dir, packagePath); err != nil {
+		return err
+	}
+
+	f, err := os.Open(packagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open package: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat package: %w", err)
+	}
+
+	key := media.GetExportKey(export.ID)
+	if err := s.s3Client.Upload(ctx, s.s3Client.GetRawBucket(), key, f, "application/zip"); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	export.Status = domain.ExportStatusCompleted
+	export.Key = key
+	export.Size = info.Size()
+	export.CompletedAt = &now
+	return s.dynamoClient.SetMediaExport(ctx, media.ID, export)
+}
+
+// writePackage writes the zip: the media file, the manifests under
+// manifests/, the captions under captions/ and metadata.json
+func (s *Service) writePackage(ctx context.Context, media *domain.Media, format domain.ExportFormat, dir, packagePath string) error {
+	f, err := os.Create(packagePath)
+	if err != nil {
+		return fmt.Errorf("failed to create package: %w", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	switch format {
+	case domain.ExportFormatMP4:
+		mp4Path, err := s.remux(ctx, media, dir)
+		if err != nil {
+			return err
+		}
+		if err := addFile(zw, "media.mp4", mp4Path); err != nil {
+			return err
+		}
+	default:
+		body, err := s.s3Client.Download(ctx, media.SourceBucket, media.SourceKey)
+		if err != nil {
+			return err
+		}
+		err = addEntry(zw, "source"+media.SourceFormat, body, zip.Store)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	processedBucket := s.s3Client.GetProcessedBucket()
+	prefix := media.GetOutputPrefix()
+	objects, err := s.s3Client.ListObjects(ctx, processedBucket, prefix)
+	if err != nil {
+		return err
+	}
+	// Only the published master playlist and the renditions and DASH
+	// manifest of the active output version are packaged. Storyboards
+	// aren't captions, so they're left out.
+	skip := make(map[string]bool)
+	for _, v := range media.GetVersions() {
+		skip[media.GetVersionMasterKey(v.Number)] = true
+		skip[v.StoryboardKey] = true
+		if v.Number == media.GetActiveVersion() {
+			continue
+		}
+		for _, r := range v.Renditions {
+			skip[r.PlaylistKey] = true
+		}
+		if v.DASHKey != "" {
+			skip[v.DASHKey] = true
+		}
+	}
+	for _, obj := range objects {
+		key := *obj.Key
+		var name string
+		switch {
+		case skip[key]:
+			continue
+		case strings.HasSuffix(key, ".m3u8"), strings.HasSuffix(key, ".mpd"):
+			name = "manifests/" + strings.TrimPrefix(key, prefix)
+		case strings.HasSuffix(key, ".vtt") && !strings.HasPrefix(path.Base(key), "segment_"):
+			// Subtitle segments are left out for the whole track files
+			name = "captions/" + strings.TrimPrefix(key, prefix)
+		default:
+			continue
+		}
+		body, err := s.s3Client.Download(ctx, processedBucket, key)
+		if err != nil {
+			return err
+		}
+		err = addEntry(zw, name, body, zip.Deflate)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	metadata, err := json.MarshalIndent(media, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := addEntry(zw, "metadata.json", strings.NewReader(string(metadata)), zip.Deflate); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write package: %w", err)
+	}
+	return f.Close()
+}
+
+// remux downloads the highest rendition and remuxes it into an MP4,
+// returning its path
+func (s *Service) remux(ctx context.Context, media *domain.Media, dir string) (string, error) {
+	if s.remuxer == nil {
+		return "", fmt.Errorf("no remuxer configured")
+	}
+	if len(media.Renditions) == 0 {
+		return "", fmt.Errorf("media has no renditions")
+	}
+	best := media.Renditions[0]
+	for _, r := range media.Renditions[1:] {
+		if r.Bitrate > best.Bitrate {
+			best = r
+		}
+	}
+
+	hlsDir := filepath.Join(dir, "hls")
+	if err := os.MkdirAll(hlsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create rendition directory: %w", err)
+	}
+	processedBucket := s.s3Client.GetProcessedBucket()
+	objects, err := s.s3Client.ListObjects(ctx, processedBucket, path.Dir(best.PlaylistKey)+"/")
+	if err != nil {
+		return "", err
+	}
+	for _, obj := range objects {
+		if err := s.download(ctx, processedBucket, *obj.Key, filepath.Join(hlsDir, path.Base(*obj.Key))); err != nil {
+			return "", err
+		}
+	}
+
+	output := filepath.Join(dir, "media.mp4")
+	if err := s.remuxer.RemuxMP4(ctx, filepath.Join(hlsDir, path.Base(best.PlaylistKey)), output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// download copies an object to a local file
+func (s *Service) download(ctx context.Context, bucket, key, dest string) error {
+	body, err := s.s3Client.Download(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return f.Close()
+}
+
+// fail marks an export failed. The cause is only logged; users see that
+// the package couldn't be built.
+func (s *Service) fail(ctx context.Context, mediaID string, export *domain.MediaExport) {
+	export.Status = domain.ExportStatusFailed
+	export.Error = "failed to build the package"
+	if err := s.dynamoClient.SetMediaExport(ctx, mediaID, export); err != nil {
+		logger.FromContext(ctx, s.log).Error("failed to mark export failed", "error", err, "export_id", export.ID)
+	}
+}
+
+// addFile adds a local file to the zip, stored as is since media files
+// are already compressed
+func addFile(zw *zip.Writer, name, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer f.Close()
+	return addEntry(zw, name, f, zip.Store)
+}
+
+// addEntry adds an entry to the zip
+func addEntry(zw *zip.Writer, name string, body io.Reader, method uint16) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to package: %w", name, err)
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("failed to write %s to package: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// MaxResponseSize caps the responses stored for replay, keeping records
+// well within DynamoDB's item size limit
+const MaxResponseSize = 256 << 10
+
+// Service remembers requests made with an Idempotency-Key so retries
+// replay the original response instead of repeating its side effects
+type Service struct {
+	dynamoClient *dynamodb.Client
+	ttl          time.Duration
+	lease        time.Duration
+	log          *logger.Logger
+}
+
+// NewService creates a new idempotency service
+func NewService(dynamoClient *dynamodb.Client, cfg config.IdempotencyConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		ttl:          cfg.TTL,
+		lease:        cfg.Lease,
+		log:          log,
+	}
+}
+
+// Response is a finished response stored for replay
+type Response struct {
+	Status int
+	Header map[string]string
+	Body   []byte
+}
+
+// Begin claims a user's key for a request identified by fingerprint. It
+// returns nil when the request should run, after which the caller must
+// Complete or Release the key, or the stored response of an earlier run.
+// A key still running or used with a different request fails with
+// domain.ErrRequestInProgress or domain.ErrIdempotencyReused. The claim
+// only lasts the lease, so a request that never completes or releases its
+// key, as when the process dies, holds it until the lease runs out rather
+// than for the whole TTL.
+func (s *Service) Begin(ctx context.Context, userID, key, fingerprint string) (*Response, error) {
+	now := time.Now()
+	existing, err := s.dynamoClient.ClaimIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		Key:         recordKey(ctx, userID, key),
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.lease).Unix(),
+	})
+	if err != domain.ErrIdempotencyKeyUsed {
+		return nil, err
+	}
+
+	switch {
+	case existing == nil || !existing.Completed:
+		return nil, domain.ErrRequestInProgress
+	case existing.Fingerprint != fingerprint:
+		return nil, domain.ErrIdempotencyReused
+	}
+
+	return &Response{
+		Status: existing.Status,
+		Header: existing.Header,
+		Body:   existing.Body,
+	}, nil
+}
+
+// Complete stores the response of a request started with Begin, keeping
+// it for the full TTL
+func (s *Service) Complete(ctx context.Context, userID, key, fingerprint string, resp *Response) error {
+	now := time.Now()
+	return s.dynamoClient.CompleteIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		Key:         recordKey(ctx, userID, key),
+		Fingerprint: fingerprint,
+		Completed:   true,
+		Status:      resp.Status,
+		Header:      resp.Header,
+		Body:        resp.Body,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl).Unix(),
+	})
+}
+
+// Release frees a key whose request failed so it can be retried
+func (s *Service) Release(ctx context.Context, userID, key string) error {
+	return s.dynamoClient.ReleaseIdempotencyKey(ctx, recordKey(ctx, userID, key))
+}
+
+// recordKey scopes a key to its tenant and user so callers can't replay
+// each other's responses. Default tenant keys keep their original form.
+func recordKey(ctx context.Context, userID, key string) string {
+	if id := tenant.FromContext(ctx); id != tenant.Default {
+		return id + "#" + userID + "#" + key
+	}
+	return userID + "#" + key
+}
@@ -0,0 +1,195 @@
+// Package jobs keeps the record of each processing job, as it is queued,
+// started and finished, so callers can follow a job after it leaves the
+// queue and look back over a media item's history
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// recordTimeout bounds recording a job's state, which happens outside the
+// context of the job itself
+const recordTimeout = 5 * time.Second
+
+// Service records processing jobs and serves their records
+type Service struct {
+	dynamoClient *dynamodb.Client
+	retention    time.Duration
+	log          *logger.Logger
+}
+
+// NewService creates a new jobs service keeping finished jobs' records
+// for retention
+func NewService(dynamoClient *dynamodb.Client, retention time.Duration, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		retention:    retention,
+		log:          log,
+	}
+}
+
+// recordingQueue records the jobs enqueued through it
+type recordingQueue struct {
+	queue.Queue
+	jobs *Service
+}
+
+// Queue wraps q so every job enqueued through it is recorded as queued.
+// A job that can't be enqueued is recorded as failed.
+func (s *Service) Queue(q queue.Queue) queue.Queue {
+	return &recordingQueue{Queue: q, jobs: s}
+}
+
+// Enqueue records the job before enqueuing it, so its record exists
+// before any worker can start it
+func (q *recordingQueue) Enqueue(ctx context.Context, job *queue.Job) error {
+	q.jobs.Queued(job)
+	if err := q.Queue.Enqueue(ctx, job); err != nil {
+		q.jobs.failed(job, err)
+		return err
+	}
+	return nil
+}
+
+// EnqueueAt records the job as queued before scheduling it
+func (q *recordingQueue) EnqueueAt(ctx context.Context, job *queue.Job, at time.Time) error {
+	if at.After(time.Now()) {
+		job.RunAt = &at
+	}
+	q.jobs.Queued(job)
+	if err := q.Queue.EnqueueAt(ctx, job, at); err != nil {
+		q.jobs.failed(job, err)
+		return err
+	}
+	return nil
+}
+
+// Queued records a job waiting for a worker: newly queued, or put back
+// in the queue without its attempt counting, such as by the worker
+// shutting down
+func (s *Service) Queued(job *queue.Job) {
+	now := time.Now().UTC()
+	s.save(job, &domain.Job{
+		Status:    domain.JobStatusQueued,
+		Attempts:  job.Attempts,
+		CreatedAt: now,
+		RunAt:     job.RunAt,
+		UpdatedAt: now,
+	})
+}
+
+// failed records a job that couldn't be queued
+func (s *Service) failed(job *queue.Job, err error) {
+	now := time.Now().UTC()
+	s.save(job, &domain.Job{
+		Status:     domain.JobStatusFailed,
+		Attempts:   job.Attempts,
+		Error:      err.Error(),
+		CreatedAt:  now,
+		FinishedAt: &now,
+		UpdatedAt:  now,
+		ExpiresAt:  now.Add(s.retention).Unix(),
+	})
+}
+
+// Started records a worker starting an attempt at a job
+func (s *Service) Started(job *queue.Job) {
+	now := time.Now().UTC()
+	s.save(job, &domain.Job{
+		Status:    domain.JobStatusProcessing,
+		Attempts:  job.Attempts + 1,
+		CreatedAt: now,
+		StartedAt: &now,
+		UpdatedAt: now,
+	})
+}
+
+// Finished records the outcome of an attempt at a job: success, a
+// failure to be retried or, on the last attempt, a failure it won't be
+// retried after. Finished jobs expire after the retention.
+func (s *Service) Finished(job *queue.Job, err error, last bool) {
+	now := time.Now().UTC()
+	record := &domain.Job{
+		Attempts:  job.Attempts + 1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	switch {
+	case err == nil:
+		record.Status = domain.JobStatusSucceeded
+	case last:
+		record.Status = domain.JobStatusFailed
+		record.Error = err.Error()
+	default:
+		record.Status = domain.JobStatusRetrying
+		record.Error = err.Error()
+	}
+	if record.Status != domain.JobStatusRetrying {
+		record.FinishedAt = &now
+		record.ExpiresAt = now.Add(s.retention).Unix()
+	}
+	s.save(job, record)
+}
+
+// save records a job's state in its tenant. Failing to is logged rather
+// than returned, so it can't hold up the job.
+func (s *Service) save(job *queue.Job, record *domain.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	ctx = tenant.WithID(ctx, job.TenantID)
+
+	record.ID = job.ID
+	record.Type = string(job.Type)
+	record.MediaID = job.MediaID
+	if err := s.dynamoClient.SaveJobState(ctx, record); err != nil {
+		s.log.Error("failed to record job", "error", err, "job_id", job.ID, "status", record.Status)
+	}
+}
+
+// GetJob returns a job of one of the user's media items
+func (s *Service) GetJob(ctx context.Context, jobID, userID string) (*domain.Job, error) {
+	job, err := s.dynamoClient.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getOwnedMedia(ctx, job.MediaID, userID); err != nil {
+		if err == domain.ErrMediaNotFound {
+			return nil, domain.ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// ListMediaJobs returns a page of the jobs of the user's media item,
+// newest first, and the cursor of the next page
+func (s *Service) ListMediaJobs(ctx context.Context, mediaID, userID string, limit int32, cursor string) ([]*domain.Job, string, error) {
+	if _, err := s.getOwnedMedia(ctx, mediaID, userID); err != nil {
+		return nil, "", err
+	}
+
+	return s.dynamoClient.ListJobsByMedia(ctx, mediaID, limit, cursor)
+}
+
+// getOwnedMedia returns a media item if it belongs to the user
+func (s *Service) getOwnedMedia(ctx context.Context, mediaID, userID string) (*domain.Media, error) {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if media.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	return media, nil
+}
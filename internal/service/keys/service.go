@@ -0,0 +1,122 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/encryption"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/kms"
+	"github.com/streaming-service/internal/token"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Service manages HLS content keys and their authenticated delivery
+type Service struct {
+	dynamoClient   *dynamodb.Client
+	kmsClient      *kms.Client
+	signer         *token.Signer
+	keyURLBase     string
+	segmentsPerKey int
+	tokenTTL       time.Duration
+	log            *logger.Logger
+}
+
+// NewService creates a new key service
+func NewService(dynamoClient *dynamodb.Client, kmsClient *kms.Client, signer *token.Signer, cfg config.EncryptionConfig, tokenTTL time.Duration, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient:   dynamoClient,
+		kmsClient:      kmsClient,
+		signer:         signer,
+		keyURLBase:     strings.TrimRight(cfg.KeyURLBase, "/"),
+		segmentsPerKey: cfg.SegmentsPerKey,
+		tokenTTL:       tokenTTL,
+		log:            log,
+	}
+}
+
+// ContentKey is a plaintext content key with its delivery URI
+type ContentKey struct {
+	ID  string
+	Key []byte
+	URI string
+}
+
+// SegmentsPerKey returns how many segments are encrypted with each key
+// before rotating to the next one
+func (s *Service) SegmentsPerKey() int {
+	return s.segmentsPerKey
+}
+
+// CreateKeys generates count keys for a media item and stores them
+// encrypted with KMS. The plaintext keys are only returned to the caller.
+func (s *Service) CreateKeys(ctx context.Context, mediaID string, count int) ([]ContentKey, error) {
+	keys := make([]ContentKey, 0, count)
+	for i := 0; i < count; i++ {
+		key, err := encryption.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+
+		keyID := uuid.New().String()
+		encrypted, err := s.kmsClient.Encrypt(ctx, key, encryptionContext(mediaID, keyID))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.dynamoClient.PutContentKey(ctx, mediaID, keyID, encrypted); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, ContentKey{
+			ID:  keyID,
+			Key: key,
+			URI: fmt.Sprintf("%s/api/v1/keys/%s/%s", s.keyURLBase, mediaID, keyID),
+		})
+	}
+
+	return keys, nil
+}
+
+// IssuePlaybackToken issues a token authorizing key requests for a media item
+func (s *Service) IssuePlaybackToken(mediaID, userID string) (string, error) {
+	return s.signer.Issue(mediaID, userID, token.ScopePlayback, s.tokenTTL)
+}
+
+// GetKey returns the plaintext content key if the playback token grants
+// access to the media item
+func (s *Service) GetKey(ctx context.Context, mediaID, keyID, tok string) ([]byte, error) {
+	claims, err := s.signer.Verify(tok)
+	if err != nil {
+		return nil, domain.ErrUnauthorized
+	}
+
+	if claims.MediaID != mediaID || claims.Scope != token.ScopePlayback {
+		return nil, domain.ErrUnauthorized
+	}
+
+	encrypted, err := s.dynamoClient.GetContentKey(ctx, mediaID, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.kmsClient.Decrypt(ctx, encrypted, encryptionContext(mediaID, keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt content key: %w", err)
+	}
+
+	return key, nil
+}
+
+// encryptionContext binds a wrapped key to its media and key IDs
+func encryptionContext(mediaID, keyID string) map[string]string {
+	return map[string]string{
+		"media_id": mediaID,
+		"key_id":   keyID,
+	}
+}
@@ -0,0 +1,153 @@
+package live
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// MaxDestinations is how many restream destinations a stream may have
+const MaxDestinations = 5
+
+// AddDestinationRequest contains the fields for a new restream destination
+type AddDestinationRequest struct {
+	Name    string
+	URL     string
+	Enabled bool
+}
+
+// DestinationInfo is a restream destination with its push status
+type DestinationInfo struct {
+	domain.RestreamDestination
+	Status *domain.RestreamStatus `json:"status,omitempty"`
+}
+
+// ValidDestinationURL reports whether u is an rtmp or rtmps URL that can
+// be pushed to
+func ValidDestinationURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "rtmp" && parsed.Scheme != "rtmps") {
+		return false
+	}
+	return parsed.Host != "" && parsed.Path != "" && parsed.Path != "/"
+}
+
+// ListDestinations returns a stream's restream destinations with the
+// status of their pushes
+func (s *Service) ListDestinations(ctx context.Context, streamID, userID string) ([]DestinationInfo, error) {
+	stream, err := s.GetStream(ctx, streamID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]DestinationInfo, 0, len(stream.Destinations))
+	for _, dest := range stream.Destinations {
+		infos = append(infos, DestinationInfo{RestreamDestination: dest, Status: stream.Restreams[dest.ID]})
+	}
+	return infos, nil
+}
+
+// AddDestination adds a restream destination to a stream. Enabled
+// destinations of a live stream are pushed to within a health interval.
+func (s *Service) AddDestination(ctx context.Context, streamID, userID string, req *AddDestinationRequest) (*domain.RestreamDestination, error) {
+	if req.Name == "" || !ValidDestinationURL(req.URL) {
+		return nil, domain.ErrInvalidInput
+	}
+
+	stream, err := s.GetStream(ctx, streamID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(stream.Destinations) >= MaxDestinations {
+		return nil, domain.ErrInvalidInput
+	}
+
+	dest := domain.RestreamDestination{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		URL:       req.URL,
+		Enabled:   req.Enabled,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.dynamoClient.SetLiveStreamDestinations(ctx, streamID, append(stream.Destinations, dest)); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("restream destination added", "stream_id", streamID, "destination_id", dest.ID)
+
+	return &dest, nil
+}
+
+// SetDestinationEnabled enables or disables a stream's restream
+// destination. Pushes start or stop within a health interval.
+func (s *Service) SetDestinationEnabled(ctx context.Context, streamID, destinationID, userID string, enabled bool) (*domain.RestreamDestination, error) {
+	stream, err := s.GetStream(ctx, streamID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	dest := stream.GetDestination(destinationID)
+	if dest == nil {
+		return nil, domain.ErrDestinationNotFound
+	}
+	if dest.Enabled == enabled {
+		return dest, nil
+	}
+	dest.Enabled = enabled
+	if err := s.dynamoClient.SetLiveStreamDestinations(ctx, streamID, stream.Destinations); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("restream destination updated", "stream_id", streamID, "destination_id", destinationID, "enabled", enabled)
+
+	return dest, nil
+}
+
+// RemoveDestination removes a stream's restream destination, stopping any
+// push to it
+func (s *Service) RemoveDestination(ctx context.Context, streamID, destinationID, userID string) error {
+	stream, err := s.GetStream(ctx, streamID, userID)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]domain.RestreamDestination, 0, len(stream.Destinations))
+	for _, dest := range stream.Destinations {
+		if dest.ID != destinationID {
+			kept = append(kept, dest)
+		}
+	}
+	if len(kept) == len(stream.Destinations) {
+		return domain.ErrDestinationNotFound
+	}
+	if err := s.dynamoClient.SetLiveStreamDestinations(ctx, streamID, kept); err != nil {
+		return err
+	}
+
+	s.log.Info("restream destination removed", "stream_id", streamID, "destination_id", destinationID)
+
+	return nil
+}
+
+// syncRestreams starts and stops a session's pushes to match the stream's
+// enabled destinations, and stores their status. After the session stops
+// it records every push as stopped.
+func (s *Service) syncRestreams(ctx context.Context, streamID string, session *PackagerSession) {
+	stream, err := s.dynamoClient.GetLiveStream(ctx, streamID)
+	if err != nil {
+		s.log.Error("failed to read restream destinations", "stream_id", streamID, "error", err)
+		return
+	}
+
+	statuses := session.restream.reconcile(stream.Destinations, time.Now().UTC())
+	if len(statuses) == 0 && len(stream.Restreams) == 0 {
+		return
+	}
+	if err := s.dynamoClient.SetLiveStreamRestreams(ctx, streamID, statuses); err != nil {
+		s.log.Error("failed to report restream status", "stream_id", streamID, "error", err)
+	}
+}
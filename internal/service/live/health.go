@@ -0,0 +1,205 @@
+package live
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// keyframeSamples is how many keyframe intervals are averaged
+const keyframeSamples = 10
+
+// healthTracker accumulates ingest statistics for a session from the
+// RTP packets or RTMP frames it receives
+type healthTracker struct {
+	mu sync.Mutex
+
+	bytes        int64
+	lastSnapshot time.Time
+
+	frames        int64
+	droppedFrames int64
+	frameDamaged  bool
+
+	// Per-SSRC sequence tracking for loss detection
+	lastSeq     map[uint32]uint16
+	packetsLost int64
+
+	lastKeyframe time.Time
+	intervals    []time.Duration
+
+	disconnects int64
+}
+
+// newHealthTracker creates an empty tracker
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		lastSnapshot: time.Now(),
+		lastSeq:      make(map[uint32]uint16),
+	}
+}
+
+// observeVideo records an H.264 RTP packet
+func (h *healthTracker) observeVideo(packet *rtp.Packet, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.observeSequence(packet) {
+		h.frameDamaged = true
+	}
+
+	// A keyframe spans many packets, so only its first is counted
+	if isH264Keyframe(packet.Payload) && (h.lastKeyframe.IsZero() || at.Sub(h.lastKeyframe) > 100*time.Millisecond) {
+		h.keyframe(at)
+	}
+
+	// The marker bit ends a frame
+	if packet.Marker {
+		h.frames++
+		if h.frameDamaged {
+			h.droppedFrames++
+			h.frameDamaged = false
+		}
+	}
+}
+
+// observeFrame records a whole audio or video frame, as received over
+// RTMP, where the transport leaves no packets to lose
+func (h *healthTracker) observeFrame(size int, video, keyframe bool, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.bytes += int64(size)
+	if !video {
+		return
+	}
+	h.frames++
+	if keyframe {
+		h.keyframe(at)
+	}
+}
+
+// keyframe records a keyframe's arrival for the interval average
+func (h *healthTracker) keyframe(at time.Time) {
+	if !h.lastKeyframe.IsZero() {
+		h.intervals = append(h.intervals, at.Sub(h.lastKeyframe))
+		if len(h.intervals) > keyframeSamples {
+			h.intervals = h.intervals[1:]
+		}
+	}
+	h.lastKeyframe = at
+}
+
+// observeAudio records an audio RTP packet
+func (h *healthTracker) observeAudio(packet *rtp.Packet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.observeSequence(packet)
+}
+
+// observeSequence counts payload bytes and sequence gaps. It reports
+// whether packets were lost before this one.
+func (h *healthTracker) observeSequence(packet *rtp.Packet) bool {
+	h.bytes += int64(len(packet.Payload))
+
+	last, ok := h.lastSeq[packet.SSRC]
+	if !ok {
+		h.lastSeq[packet.SSRC] = packet.SequenceNumber
+		return false
+	}
+
+	diff := packet.SequenceNumber - last
+	switch {
+	case diff == 0:
+		return false
+	case diff < 0x8000:
+		h.lastSeq[packet.SSRC] = packet.SequenceNumber
+		if diff > 1 {
+			h.packetsLost += int64(diff - 1)
+			return true
+		}
+	default:
+		// A late packet fills a gap counted earlier
+		if h.packetsLost > 0 {
+			h.packetsLost--
+		}
+	}
+
+	return false
+}
+
+// disconnected records a transient connection loss
+func (h *healthTracker) disconnected() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.disconnects++
+}
+
+// snapshot returns the current health, with the bitrate measured since
+// the previous snapshot
+func (h *healthTracker) snapshot(now time.Time) *domain.LiveHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	health := &domain.LiveHealth{
+		VideoFrames:    h.frames,
+		DroppedFrames:  h.droppedFrames,
+		PacketsLost:    h.packetsLost,
+		LastKeyframeAt: h.lastKeyframe,
+		Disconnects:    h.disconnects,
+		UpdatedAt:      now,
+	}
+
+	if elapsed := now.Sub(h.lastSnapshot).Seconds(); elapsed > 0 {
+		health.Bitrate = int64(float64(h.bytes*8) / elapsed)
+	}
+	h.bytes = 0
+	h.lastSnapshot = now
+
+	if len(h.intervals) > 0 {
+		var total time.Duration
+		for _, d := range h.intervals {
+			total += d
+		}
+		health.KeyframeInterval = (total / time.Duration(len(h.intervals))).Seconds()
+	}
+
+	return health
+}
+
+// isH264Keyframe reports whether an RTP payload carries an IDR slice,
+// either as a single NAL unit, inside a STAP-A or at the start of a FU-A
+func isH264Keyframe(payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+
+	const (
+		nalIDR  = 5
+		nalSTAP = 24
+		nalFUA  = 28
+	)
+
+	switch payload[0] & 0x1F {
+	case nalIDR:
+		return true
+	case nalSTAP:
+		for i := 1; i+2 < len(payload); {
+			size := int(payload[i])<<8 | int(payload[i+1])
+			i += 2
+			if payload[i]&0x1F == nalIDR {
+				return true
+			}
+			i += size
+		}
+	case nalFUA:
+		return len(payload) > 1 && payload[1]&0x80 != 0 && payload[1]&0x1F == nalIDR
+	}
+
+	return false
+}
@@ -0,0 +1,509 @@
+package live
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/manifest"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// llPartSegments is how many trailing segments list their parts
+	llPartSegments = 3
+	// llScanInterval is how often ffmpeg's part playlists are polled
+	llScanInterval = 100 * time.Millisecond
+
+	llPartsPlaylist  = "parts.m3u8"
+	llPartsMaster    = "parts_master.m3u8"
+	llMediaPlaylist  = "playlist.m3u8"
+	llMasterPlaylist = "master.m3u8"
+)
+
+// ErrPlaylistTimeout is returned when a blocking request is not satisfied
+// within the hold time
+var ErrPlaylistTimeout = errors.New("timed out waiting for playlist update")
+
+// lowLatency assembles the fMP4 parts written by ffmpeg into LL-HLS
+// playlists and full segments, and answers blocking playlist reloads.
+// It also writes a regular playlist of full segments for the publisher.
+type lowLatency struct {
+	dir             string
+	segmentDuration float64
+	partTarget      float64
+	partsPerSegment int64
+	windowSegments  int
+
+	mu            sync.Mutex
+	renditions    map[string]*llRendition
+	notify        chan struct{}
+	ended         bool
+	masterWritten bool
+	finished      chan struct{}
+
+	log *logger.Logger
+}
+
+// llRendition is the assembly state of one rendition
+type llRendition struct {
+	name     string
+	mapURI   string
+	parts    map[int64]manifest.MediaSegment
+	lastPart int64
+	next     int64
+	segments []llSegment
+}
+
+// llSegment is an assembled full segment
+type llSegment struct {
+	msn      int64
+	duration float64
+	uri      string
+}
+
+// newLowLatency creates the LL-HLS assembler for a packager directory
+func newLowLatency(dir string, segmentDuration int, partDuration time.Duration, windowSegments int, log *logger.Logger) *lowLatency {
+	part := partDuration.Seconds()
+	pps := int64(math.Round(float64(segmentDuration) / part))
+	if pps < 1 {
+		pps = 1
+	}
+
+	return &lowLatency{
+		dir:             dir,
+		segmentDuration: float64(segmentDuration),
+		partTarget:      part,
+		partsPerSegment: pps,
+		windowSegments:  windowSegments,
+		renditions:      make(map[string]*llRendition),
+		notify:          make(chan struct{}),
+		finished:        make(chan struct{}),
+		log:             log,
+	}
+}
+
+// run polls the output directory until done is closed, then finalizes
+// the playlists
+func (l *lowLatency) run(done <-chan struct{}) {
+	defer close(l.finished)
+
+	ticker := time.NewTicker(llScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.scan(false); err != nil {
+				l.log.Error("failed to assemble low-latency output", "dir", l.dir, "error", err)
+			}
+		case <-done:
+			if err := l.scan(true); err != nil {
+				l.log.Error("failed to finalize low-latency output", "dir", l.dir, "error", err)
+			}
+			return
+		}
+	}
+}
+
+// scan picks up new parts, assembles complete segments and wakes any
+// blocked playlist requests
+func (l *lowLatency) scan(final bool) error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changed := false
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(l.dir, entry.Name(), llPartsPlaylist))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read parts playlist: %w", err)
+		}
+
+		r, ok := l.renditions[entry.Name()]
+		if !ok {
+			r = &llRendition{
+				name:     entry.Name(),
+				parts:    make(map[int64]manifest.MediaSegment),
+				lastPart: -1,
+				next:     -1,
+			}
+			l.renditions[entry.Name()] = r
+		}
+
+		updated, err := l.update(r, manifest.ParseMedia(data), final)
+		if err != nil {
+			return err
+		}
+		changed = changed || updated
+	}
+
+	if !l.masterWritten {
+		if err := l.writeMaster(); err == nil {
+			l.masterWritten = true
+			changed = true
+		}
+	}
+
+	if final {
+		l.ended = true
+		changed = true
+	}
+
+	if changed {
+		for _, r := range l.renditions {
+			if err := writeFileAtomic(filepath.Join(l.dir, r.name, llMediaPlaylist), manifest.RenderMedia(l.playlist(r))); err != nil {
+				return err
+			}
+		}
+		close(l.notify)
+		l.notify = make(chan struct{})
+	}
+
+	return nil
+}
+
+// update merges a parsed parts playlist into the rendition state
+func (l *lowLatency) update(r *llRendition, parts *manifest.MediaPlaylist, final bool) (bool, error) {
+	changed := false
+	r.mapURI = parts.MapURI
+
+	for i, part := range parts.Segments {
+		idx := parts.MediaSequence + int64(i)
+		if idx <= r.lastPart {
+			continue
+		}
+		r.parts[idx] = part
+		r.lastPart = idx
+		changed = true
+	}
+
+	// Forget parts ffmpeg has already deleted
+	for idx := range r.parts {
+		if idx < parts.MediaSequence {
+			delete(r.parts, idx)
+		}
+	}
+
+	// Assemble every segment whose parts are all available
+	for {
+		msn := l.nextMSN(r)
+		first := msn * l.partsPerSegment
+		last := first + l.partsPerSegment - 1
+		if r.lastPart < last {
+			if final && r.lastPart >= first {
+				last = r.lastPart
+			} else {
+				break
+			}
+		}
+
+		if err := l.assemble(r, msn, first, last); err != nil {
+			// Skip the segment rather than stalling the stream
+			l.log.Warn("failed to assemble segment", "rendition", r.name, "msn", msn, "error", err)
+		}
+		r.next = msn + 1
+		changed = true
+	}
+
+	l.expire(r)
+
+	return changed, nil
+}
+
+// nextMSN returns the media sequence number of the next segment to
+// assemble: the one after the last attempted segment, or the first
+// segment whose parts all appeared in this session
+func (l *lowLatency) nextMSN(r *llRendition) int64 {
+	if r.next >= 0 {
+		return r.next
+	}
+
+	oldest := r.lastPart
+	for idx := range r.parts {
+		if idx < oldest {
+			oldest = idx
+		}
+	}
+	return (oldest + l.partsPerSegment - 1) / l.partsPerSegment
+}
+
+// assemble concatenates parts first..last into a full segment
+func (l *lowLatency) assemble(r *llRendition, msn, first, last int64) error {
+	var buf bytes.Buffer
+	var duration float64
+
+	for idx := first; idx <= last; idx++ {
+		part, ok := r.parts[idx]
+		if !ok {
+			return fmt.Errorf("part %d of %s is missing", idx, r.name)
+		}
+
+		f, err := os.Open(filepath.Join(l.dir, r.name, part.URI))
+		if err != nil {
+			return fmt.Errorf("failed to open part: %w", err)
+		}
+		_, err = io.Copy(&buf, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read part: %w", err)
+		}
+
+		duration += part.Duration
+	}
+
+	uri := fmt.Sprintf("segment_%05d.m4s", msn)
+	if err := writeFileAtomic(filepath.Join(l.dir, r.name, uri), buf.Bytes()); err != nil {
+		return err
+	}
+
+	r.segments = append(r.segments, llSegment{
+		msn:      msn,
+		duration: math.Round(duration*1000) / 1000,
+		uri:      uri,
+	})
+
+	return nil
+}
+
+// expire drops segments that slid out of the window, keeping a few
+// extra on disk for players and the publisher still catching up
+func (l *lowLatency) expire(r *llRendition) {
+	keep := l.windowSegments + 2
+	for len(r.segments) > keep {
+		_ = os.Remove(filepath.Join(l.dir, r.name, r.segments[0].uri))
+		r.segments = r.segments[1:]
+	}
+}
+
+// writeMaster derives the master playlist from ffmpeg's, pointing the
+// variants at the assembled playlists
+func (l *lowLatency) writeMaster() error {
+	data, err := os.ReadFile(filepath.Join(l.dir, llPartsMaster))
+	if err != nil {
+		return err
+	}
+	master := bytes.ReplaceAll(data, []byte("/"+llPartsPlaylist), []byte("/"+llMediaPlaylist))
+	return writeFileAtomic(filepath.Join(l.dir, llMasterPlaylist), master)
+}
+
+// playlist builds the playlist model of a rendition. Callers hold l.mu.
+func (l *lowLatency) playlist(r *llRendition) *manifest.LLPlaylist {
+	p := &manifest.LLPlaylist{
+		TargetDuration: manifest.TargetDuration(l.segmentDuration),
+		PartTarget:     l.partTarget,
+		MapURI:         r.mapURI,
+		PartSegments:   llPartSegments,
+		Ended:          l.ended,
+	}
+
+	segments := r.segments
+	if len(segments) > l.windowSegments {
+		segments = segments[len(segments)-l.windowSegments:]
+	}
+
+	next := l.nextMSN(r)
+	p.MediaSequence = next
+	if len(segments) > 0 {
+		p.MediaSequence = segments[0].msn
+	}
+
+	for _, seg := range segments {
+		p.Segments = append(p.Segments, manifest.LLSegment{
+			URI:      seg.uri,
+			Duration: seg.duration,
+			Parts:    l.parts(r, seg.msn*l.partsPerSegment, (seg.msn+1)*l.partsPerSegment-1),
+			Complete: true,
+		})
+	}
+
+	// Parts of the segment still being produced
+	if !l.ended {
+		p.Segments = append(p.Segments, manifest.LLSegment{
+			Parts: l.parts(r, next*l.partsPerSegment, r.lastPart),
+		})
+		p.PreloadHint = partURI(max(r.lastPart+1, next*l.partsPerSegment))
+	}
+
+	return p
+}
+
+// parts lists the available parts in [first, last]
+func (l *lowLatency) parts(r *llRendition, first, last int64) []manifest.LLPart {
+	var parts []manifest.LLPart
+	for idx := first; idx <= last; idx++ {
+		part, ok := r.parts[idx]
+		if !ok {
+			continue
+		}
+		parts = append(parts, manifest.LLPart{
+			URI:         partURI(idx),
+			Duration:    part.Duration,
+			Independent: idx%l.partsPerSegment == 0,
+		})
+	}
+	return parts
+}
+
+// Playlist renders a rendition's LL-HLS playlist. When msn is not
+// negative the request blocks until that segment (or, with part not
+// negative, that part of it) is available, as per _HLS_msn/_HLS_part.
+func (l *lowLatency) Playlist(ctx context.Context, rendition string, msn, part int64) ([]byte, error) {
+	timeout := time.NewTimer(time.Duration(3*l.segmentDuration) * time.Second)
+	defer timeout.Stop()
+
+	for {
+		l.mu.Lock()
+		r, ok := l.renditions[rendition]
+		if !ok {
+			l.mu.Unlock()
+			return nil, domain.ErrStreamNotFound
+		}
+
+		current := r.lastPart / l.partsPerSegment
+		if msn > current+2 {
+			l.mu.Unlock()
+			return nil, domain.ErrInvalidInput
+		}
+
+		ready := l.ended || msn < 0
+		if !ready {
+			if part >= 0 {
+				ready = r.lastPart >= msn*l.partsPerSegment+part
+			} else {
+				n := len(r.segments)
+				ready = n > 0 && r.segments[n-1].msn >= msn
+			}
+		}
+
+		if ready {
+			data := manifest.RenderLL(l.playlist(r))
+			l.mu.Unlock()
+			return data, nil
+		}
+
+		notify := l.notify
+		l.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timeout.C:
+			return nil, ErrPlaylistTimeout
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Master returns the master playlist once ffmpeg has written it
+func (l *lowLatency) Master() ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(l.dir, llMasterPlaylist))
+	if err != nil {
+		return nil, domain.ErrStreamNotFound
+	}
+	return data, nil
+}
+
+// File returns the path of a part, segment or init file of a rendition.
+// Requests for the preload-hinted part block until it is written.
+func (l *lowLatency) File(ctx context.Context, rendition, name string) (string, error) {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", domain.ErrInvalidInput
+	}
+
+	idx, isPart := parsePartURI(name)
+
+	timeout := time.NewTimer(time.Duration(3*l.partTarget*float64(time.Second)) + time.Second)
+	defer timeout.Stop()
+
+	for {
+		l.mu.Lock()
+		r, ok := l.renditions[rendition]
+		if !ok {
+			l.mu.Unlock()
+			return "", domain.ErrStreamNotFound
+		}
+
+		if !isPart {
+			l.mu.Unlock()
+			path := filepath.Join(l.dir, rendition, name)
+			if _, err := os.Stat(path); err != nil {
+				return "", domain.ErrStreamNotFound
+			}
+			return path, nil
+		}
+
+		if part, ok := r.parts[idx]; ok {
+			l.mu.Unlock()
+			return filepath.Join(l.dir, rendition, part.URI), nil
+		}
+
+		// Only the next part may be waited for
+		if l.ended || idx != r.lastPart+1 {
+			l.mu.Unlock()
+			return "", domain.ErrStreamNotFound
+		}
+
+		notify := l.notify
+		l.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timeout.C:
+			return "", ErrPlaylistTimeout
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// partURI names a part in LL-HLS playlists
+func partURI(idx int64) string {
+	return "p" + strconv.FormatInt(idx, 10) + ".m4s"
+}
+
+// parsePartURI parses a name produced by partURI
+func parsePartURI(name string) (int64, bool) {
+	if !strings.HasPrefix(name, "p") || !strings.HasSuffix(name, ".m4s") {
+		return 0, false
+	}
+	idx, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, "p"), ".m4s"), 10, 64)
+	if err != nil || idx < 0 {
+		return 0, false
+	}
+	return idx, true
+}
+
+// writeFileAtomic writes a file via a temporary file and rename so
+// readers never see partial content
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package live
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Source describes the ffmpeg inputs of an ingest session
+type Source struct {
+	// InputArgs are the ffmpeg input arguments, e.g. -f h264 -i pipe:3
+	InputArgs []string
+	// Files are passed to ffmpeg as extra descriptors starting at fd 3
+	Files []*os.File
+	// VideoInput and AudioInput are the ffmpeg input indexes to map
+	VideoInput int
+	AudioInput int
+}
+
+// Packager transcodes live input to the HLS ladder with ffmpeg and
+// publishes the rolling playlists and segments to S3
+type Packager struct {
+	s3Client        *s3.Client
+	binaryPath      string
+	outputDir       string
+	segmentDuration int
+	playlistSize    int
+	publishInterval time.Duration
+	stopTimeout     time.Duration
+	lowLatency      bool
+	partDuration    time.Duration
+	previewInterval time.Duration
+	previewWidth    int
+	profiles        []config.TranscodeProfile
+	log             *logger.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*PackagerSession
+}
+
+// NewPackager creates a new live packager. With a nil S3 client output is
+// only written to the local output directory.
+func NewPackager(s3Client *s3.Client, ffmpegCfg config.FFMPEGConfig, liveCfg config.LiveConfig, log *logger.Logger) *Packager {
+	return &Packager{
+		s3Client:        s3Client,
+		binaryPath:      ffmpegCfg.BinaryPath,
+		outputDir:       liveCfg.OutputDir,
+		segmentDuration: liveCfg.SegmentDuration,
+		playlistSize:    liveCfg.PlaylistSize,
+		publishInterval: liveCfg.PublishInterval,
+		stopTimeout:     liveCfg.StopTimeout,
+		lowLatency:      liveCfg.LowLatency && liveCfg.PartDuration > 0,
+		partDuration:    liveCfg.PartDuration,
+		previewInterval: liveCfg.PreviewInterval,
+		previewWidth:    liveCfg.PreviewWidth,
+		profiles:        ffmpegCfg.Profiles,
+		log:             log,
+		sessions:        make(map[string]*PackagerSession),
+	}
+}
+
+// PackagerSession is a running ffmpeg packaging process
+type PackagerSession struct {
+	cmd         *exec.Cmd
+	dir         string
+	stopTimeout time.Duration
+	stderr      bytes.Buffer
+	ll          *lowLatency
+	restream    *restreamer
+	done        chan struct{}
+	published   chan struct{}
+	err         error
+	stopOnce    sync.Once
+}
+
+// Ping checks that ffmpeg can be run to package live streams
+func (p *Packager) Ping(ctx context.Context) error {
+	if err := exec.CommandContext(ctx, p.binaryPath, "-version").Run(); err != nil {
+		return fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+	return nil
+}
+
+// Start launches ffmpeg for a stream, writing the ladder under the
+// stream's output directory
+func (p *Packager) Start(stream *domain.LiveStream, src *Source) (*PackagerSession, error) {
+	streamID := stream.ID
+	dir := filepath.Join(p.outputDir, streamID)
+	for _, profile := range p.profiles {
+		if err := os.MkdirAll(filepath.Join(dir, profile.Name), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	session := &PackagerSession{
+		dir:         dir,
+		stopTimeout: p.stopTimeout,
+		done:        make(chan struct{}),
+		published:   make(chan struct{}),
+	}
+
+	listSize := p.listSize(stream)
+	playlist := "playlist.m3u8"
+	if p.lowLatency {
+		session.ll = newLowLatency(dir, p.segmentDuration, p.partDuration, listSize, p.log)
+		playlist = llPartsPlaylist
+	}
+	session.restream = newRestreamer(p.binaryPath, filepath.Join(dir, p.topProfile(), playlist), p.log)
+
+	cmd := exec.Command(p.binaryPath, p.buildArgs(dir, src, listSize)...)
+	cmd.ExtraFiles = src.Files
+	cmd.Stderr = &session.stderr
+	session.cmd = cmd
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start live packager: %w", err)
+	}
+
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			err = fmt.Errorf("live packager exited: %w, stderr: %s", err, tail(session.stderr.String(), 2048))
+		}
+		session.err = err
+		close(session.done)
+	}()
+
+	if session.ll != nil {
+		go session.ll.run(session.done)
+	}
+	go p.publish(session, stream.GetOutputPrefix())
+
+	p.mu.Lock()
+	p.sessions[streamID] = session
+	p.mu.Unlock()
+
+	p.log.Info("live packager started", "stream_id", streamID, "output", dir)
+
+	return session, nil
+}
+
+// publish mirrors the session output to S3 until ffmpeg exits, then
+// removes the local copy
+func (p *Packager) publish(session *PackagerSession, prefix string) {
+	defer close(session.published)
+
+	// With LL-HLS the publisher consumes the assembled output, which is
+	// final only once the assembler has finished
+	done := session.done
+	if session.ll != nil {
+		done = session.ll.finished
+	}
+
+	defer func() {
+		p.mu.Lock()
+		if p.sessions[filepath.Base(session.dir)] == session {
+			delete(p.sessions, filepath.Base(session.dir))
+		}
+		p.mu.Unlock()
+	}()
+
+	if p.s3Client == nil {
+		<-done
+		return
+	}
+
+	interval := p.publishInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	retention := time.Duration(p.segmentDuration*p.playlistSize) * time.Second
+
+	pub := newPublisher(p.s3Client, session.dir, prefix, retention, p.previewInterval, p.log)
+	pub.run(context.Background(), interval, done)
+
+	if err := os.RemoveAll(session.dir); err != nil {
+		p.log.Error("failed to remove live output", "dir", session.dir, "error", err)
+	}
+}
+
+// listSize returns how many segments the playlists keep: the configured
+// playlist size, grown to cover the stream's DVR window
+func (p *Packager) listSize(stream *domain.LiveStream) int {
+	size := p.playlistSize
+	if stream.DVRWindow > 0 && p.segmentDuration > 0 {
+		dvr := (stream.DVRWindow + p.segmentDuration - 1) / p.segmentDuration
+		if dvr > size {
+			size = dvr
+		}
+	}
+	return size
+}
+
+// buildArgs builds a single ffmpeg invocation producing every rendition
+// plus a master playlist
+func (p *Packager) buildArgs(dir string, src *Source, listSize int) []string {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, src.InputArgs...)
+
+	// Split the video once and scale a branch per profile, plus one
+	// sampled branch for the preview image
+	preview := p.previewInterval > 0
+	branches := len(p.profiles)
+	if preview {
+		branches++
+	}
+
+	var filter strings.Builder
+	filter.WriteString(fmt.Sprintf("[%d:v]split=%d", src.VideoInput, branches))
+	for i := range p.profiles {
+		filter.WriteString(fmt.Sprintf("[v%d]", i))
+	}
+	if preview {
+		filter.WriteString("[vpreview]")
+	}
+	for i, profile := range p.profiles {
+		filter.WriteString(fmt.Sprintf(";[v%d]scale=%d:%d[v%dout]", i, profile.Width, profile.Height, i))
+	}
+	if preview {
+		filter.WriteString(fmt.Sprintf(";[vpreview]fps=1/%s,scale=%d:-2[preview]",
+			strconv.FormatFloat(p.previewInterval.Seconds(), 'f', -1, 64), p.previewWidth))
+	}
+	args = append(args, "-filter_complex", filter.String())
+
+	// Keyframes on segment boundaries so every rendition switches cleanly
+	gop := fmt.Sprintf("expr:gte(t,n_forced*%d)", p.segmentDuration)
+
+	streamMap := make([]string, 0, len(p.profiles))
+	for i, profile := range p.profiles {
+		args = append(args,
+			"-map", fmt.Sprintf("[v%dout]", i),
+			"-map", fmt.Sprintf("%d:a", src.AudioInput),
+			fmt.Sprintf("-c:v:%d", i), "libx264",
+			fmt.Sprintf("-b:v:%d", i), profile.VideoBitrate,
+			fmt.Sprintf("-c:a:%d", i), "aac",
+			fmt.Sprintf("-b:a:%d", i), profile.AudioBitrate,
+		)
+		streamMap = append(streamMap, fmt.Sprintf("v:%d,a:%d,name:%s", i, i, profile.Name))
+	}
+
+	args = append(args,
+		"-preset", "veryfast",
+		"-tune", "zerolatency",
+		"-force_key_frames", gop,
+		"-ar", "48000",
+	)
+
+	// LL-HLS: ffmpeg cuts fMP4 parts on time; full segments are assembled
+	// from them on keyframe boundaries
+	if p.lowLatency {
+		pps := int(math.Round(float64(p.segmentDuration) / p.partDuration.Seconds()))
+		args = append(args,
+			"-f", "hls",
+			"-hls_time", strconv.FormatFloat(p.partDuration.Seconds(), 'f', -1, 64),
+			"-hls_list_size", fmt.Sprintf("%d", pps*(llPartSegments+1)),
+			"-hls_segment_type", "fmp4",
+			"-hls_fmp4_init_filename", "init_%v.mp4",
+			"-hls_flags", "delete_segments+split_by_time",
+			"-hls_segment_filename", filepath.Join(dir, "%v", "part_%05d.m4s"),
+			"-master_pl_name", llPartsMaster,
+			"-var_stream_map", strings.Join(streamMap, " "),
+			filepath.Join(dir, "%v", llPartsPlaylist),
+		)
+	} else {
+		args = append(args,
+			"-f", "hls",
+			"-hls_time", fmt.Sprintf("%d", p.segmentDuration),
+			"-hls_list_size", fmt.Sprintf("%d", listSize),
+			"-hls_flags", "delete_segments+independent_segments",
+			"-hls_segment_filename", filepath.Join(dir, "%v", "segment_%05d.ts"),
+			"-master_pl_name", "master.m3u8",
+			"-var_stream_map", strings.Join(streamMap, " "),
+			filepath.Join(dir, "%v", "playlist.m3u8"),
+		)
+	}
+
+	// The preview is overwritten in place, atomically so the publisher
+	// never reads a partial image
+	if preview {
+		args = append(args,
+			"-map", "[preview]",
+			"-c:v", "mjpeg",
+			"-q:v", "5",
+			"-f", "image2",
+			"-update", "1",
+			"-atomic_writing", "1",
+			filepath.Join(dir, domain.LivePreviewFile),
+		)
+	}
+
+	return args
+}
+
+// topProfile returns the name of the tallest rendition, which is pushed
+// to restream destinations
+func (p *Packager) topProfile() string {
+	top := 0
+	for i, profile := range p.profiles {
+		if profile.Height > p.profiles[top].Height {
+			top = i
+		}
+	}
+	if len(p.profiles) == 0 {
+		return ""
+	}
+	return p.profiles[top].Name
+}
+
+// LowLatency returns the LL-HLS assembler of the stream's active session
+// on this instance
+func (p *Packager) LowLatency(streamID string) (*lowLatency, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	session, ok := p.sessions[streamID]
+	if !ok || session.ll == nil {
+		return nil, false
+	}
+	return session.ll, true
+}
+
+// Dir returns the directory the session writes HLS output to
+func (s *PackagerSession) Dir() string {
+	return s.dir
+}
+
+// Done is closed when the ffmpeg process exits
+func (s *PackagerSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the process exit error once Done is closed
+func (s *PackagerSession) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Stop disconnects restream destinations, asks ffmpeg to finish the
+// playlists and waits for it to exit, killing it after the stop timeout,
+// and for the final publish
+func (s *PackagerSession) Stop() error {
+	s.stopOnce.Do(func() {
+		s.restream.stop()
+
+		if s.cmd.Process != nil {
+			_ = s.cmd.Process.Signal(syscall.SIGINT)
+		}
+
+		select {
+		case <-s.done:
+		case <-time.After(s.stopTimeout):
+			_ = s.cmd.Process.Kill()
+			<-s.done
+		}
+		<-s.published
+	})
+
+	return s.err
+}
+
+// tail returns the last n bytes of s
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
@@ -0,0 +1,226 @@
+package live
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/manifest"
+	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	playlistContentType = "application/x-mpegURL"
+	segmentContentType  = "video/MP2T"
+	fmp4ContentType     = "video/mp4"
+	previewContentType  = "image/jpeg"
+
+	// Live playlists change every segment; segments never change
+	playlistCacheControl = "max-age=1"
+	segmentCacheControl  = "max-age=31536000, immutable"
+)
+
+// publisher mirrors a packager's rolling HLS output to S3
+type publisher struct {
+	s3Client *s3.Client
+	dir      string
+	prefix   string
+	// retention is how long a segment stays in S3 after it leaves the
+	// playlist, so players holding an older playlist can still fetch it
+	retention time.Duration
+
+	uploaded      map[string]bool
+	expired       map[string]time.Time
+	playlists     map[string][]byte
+	masterWritten bool
+
+	// previewCacheControl lets viewers refetch the preview as often as it
+	// is captured
+	previewCacheControl string
+	previewModified     time.Time
+
+	log *logger.Logger
+}
+
+// newPublisher creates a publisher for a packager output directory
+func newPublisher(s3Client *s3.Client, dir, prefix string, retention, previewInterval time.Duration, log *logger.Logger) *publisher {
+	return &publisher{
+		s3Client:            s3Client,
+		dir:                 dir,
+		prefix:              prefix,
+		retention:           retention,
+		uploaded:            make(map[string]bool),
+		expired:             make(map[string]time.Time),
+		playlists:           make(map[string][]byte),
+		previewCacheControl: fmt.Sprintf("max-age=%d", int(previewInterval.Seconds())),
+		log:                 log,
+	}
+}
+
+// run syncs the output directory every interval until done is closed,
+// then performs a final sync so the ended playlists are published
+func (p *publisher) run(ctx context.Context, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.sync(ctx); err != nil {
+				p.log.Error("failed to publish live output", "prefix", p.prefix, "error", err)
+			}
+		case <-done:
+			if err := p.sync(ctx); err != nil {
+				p.log.Error("failed to publish final live output", "prefix", p.prefix, "error", err)
+			}
+			return
+		}
+	}
+}
+
+// sync uploads new segments before the playlists that reference them and
+// removes segments that have aged out of the playlists
+func (p *publisher) sync(ctx context.Context) error {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	current := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		rendition := entry.Name()
+
+		// Snapshot the playlist so the uploaded copy matches the segments
+		data, err := os.ReadFile(filepath.Join(p.dir, rendition, "playlist.m3u8"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read playlist: %w", err)
+		}
+
+		parsed := manifest.ParseMedia(data)
+		files := make([]string, 0, len(parsed.Segments)+1)
+		if parsed.MapURI != "" {
+			files = append(files, parsed.MapURI)
+		}
+		for _, segment := range parsed.Segments {
+			files = append(files, segment.URI)
+		}
+
+		for _, file := range files {
+			rel := rendition + "/" + file
+			current[rel] = true
+			if p.uploaded[rel] {
+				continue
+			}
+
+			contentType := segmentContentType
+			if !strings.HasSuffix(file, ".ts") {
+				contentType = fmp4ContentType
+			}
+			if err := p.uploadFile(ctx, rel, contentType, segmentCacheControl); err != nil {
+				return err
+			}
+			p.uploaded[rel] = true
+		}
+
+		// DVR playlists are large, so only upload them when they change
+		if bytes.Equal(p.playlists[rendition], data) {
+			continue
+		}
+		if err := p.upload(ctx, rendition+"/playlist.m3u8", data, playlistContentType, playlistCacheControl); err != nil {
+			return err
+		}
+		p.playlists[rendition] = data
+	}
+
+	// The master playlist is written once ffmpeg knows every variant
+	if !p.masterWritten {
+		if data, err := os.ReadFile(filepath.Join(p.dir, "master.m3u8")); err == nil {
+			if err := p.upload(ctx, "master.m3u8", data, playlistContentType, playlistCacheControl); err != nil {
+				return err
+			}
+			p.masterWritten = true
+		}
+	}
+
+	if err := p.syncPreview(ctx); err != nil {
+		return err
+	}
+
+	p.expire(ctx, current)
+
+	return nil
+}
+
+// syncPreview uploads the preview image whenever ffmpeg replaces it
+func (p *publisher) syncPreview(ctx context.Context) error {
+	info, err := os.Stat(filepath.Join(p.dir, domain.LivePreviewFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat preview: %w", err)
+	}
+	if !info.ModTime().After(p.previewModified) {
+		return nil
+	}
+
+	if err := p.uploadFile(ctx, domain.LivePreviewFile, previewContentType, p.previewCacheControl); err != nil {
+		return err
+	}
+	p.previewModified = info.ModTime()
+
+	return nil
+}
+
+// expire deletes segments that left the playlists more than the
+// retention period ago
+func (p *publisher) expire(ctx context.Context, current map[string]bool) {
+	now := time.Now()
+	for rel := range p.uploaded {
+		if current[rel] {
+			continue
+		}
+
+		removed, ok := p.expired[rel]
+		if !ok {
+			p.expired[rel] = now
+			continue
+		}
+		if now.Sub(removed) < p.retention {
+			continue
+		}
+
+		if err := p.s3Client.Delete(ctx, p.s3Client.GetProcessedBucket(), p.prefix+rel); err != nil {
+			p.log.Error("failed to delete expired live segment", "key", p.prefix+rel, "error", err)
+			continue
+		}
+		delete(p.uploaded, rel)
+		delete(p.expired, rel)
+	}
+}
+
+// uploadFile uploads a file from the output directory
+func (p *publisher) uploadFile(ctx context.Context, rel, contentType, cacheControl string) error {
+	data, err := os.ReadFile(filepath.Join(p.dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", rel, err)
+	}
+	return p.upload(ctx, rel, data, contentType, cacheControl)
+}
+
+// upload writes an object under the stream's prefix
+func (p *publisher) upload(ctx context.Context, rel string, data []byte, contentType, cacheControl string) error {
+	return p.s3Client.UploadWithCacheControl(ctx, p.s3Client.GetProcessedBucket(), p.prefix+rel, bytes.NewReader(data), contentType, cacheControl)
+}
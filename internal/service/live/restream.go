@@ -0,0 +1,278 @@
+package live
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// restreamRetryDelay is how long a failed push waits to be retried
+	restreamRetryDelay = 10 * time.Second
+	// restreamStopTimeout bounds how long a push may take to disconnect
+	restreamStopTimeout = 5 * time.Second
+)
+
+// restreamer pushes a packager session's top rendition to external RTMP
+// destinations with an ffmpeg process each, remuxing without transcoding
+type restreamer struct {
+	binaryPath string
+	// playlist is the local rendition playlist that is pushed
+	playlist string
+	log      *logger.Logger
+
+	mu      sync.Mutex
+	pushes  map[string]*restreamPush
+	stopped bool
+}
+
+// restreamPush is the push to one destination
+type restreamPush struct {
+	url      string
+	cmd      *exec.Cmd
+	stderr   bytes.Buffer
+	done     chan struct{}
+	failedAt time.Time
+
+	// status is updated from ffmpeg's progress reports
+	mu     sync.Mutex
+	status domain.RestreamStatus
+}
+
+// newRestreamer creates a restreamer for a rendition playlist
+func newRestreamer(binaryPath, playlist string, log *logger.Logger) *restreamer {
+	return &restreamer{
+		binaryPath: binaryPath,
+		playlist:   playlist,
+		log:        log,
+		pushes:     make(map[string]*restreamPush),
+	}
+}
+
+// reconcile starts pushes to enabled destinations, restarts failed ones
+// after the retry delay and stops the rest, returning the status of each
+// enabled destination. Pushes wait for the playlist's first segment.
+// Once stopped, the last status of each push is returned as stopped.
+func (r *restreamer) reconcile(destinations []domain.RestreamDestination, now time.Time) map[string]*domain.RestreamStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make(map[string]*domain.RestreamStatus)
+	if r.stopped {
+		for id, push := range r.pushes {
+			status := push.snapshot(now)
+			status.State = domain.RestreamStateStopped
+			status.Bitrate = 0
+			status.Speed = 0
+			status.Error = ""
+			statuses[id] = status
+		}
+		return statuses
+	}
+
+	enabled := make(map[string]bool)
+	for _, dest := range destinations {
+		if !dest.Enabled {
+			continue
+		}
+		enabled[dest.ID] = true
+
+		push, ok := r.pushes[dest.ID]
+		if ok && push.url != dest.URL {
+			push.stop()
+			delete(r.pushes, dest.ID)
+			ok = false
+		}
+
+		switch {
+		case !ok:
+			if _, err := os.Stat(r.playlist); err != nil {
+				statuses[dest.ID] = &domain.RestreamStatus{State: domain.RestreamStateConnecting, UpdatedAt: now}
+				continue
+			}
+			r.pushes[dest.ID] = r.start(dest, 0, now)
+		case push.exited() && now.Sub(push.failedAt) >= restreamRetryDelay:
+			restarts := push.snapshot(now).Restarts + 1
+			r.log.Warn("restarting restream", "destination_id", dest.ID, "restarts", restarts)
+			r.pushes[dest.ID] = r.start(dest, restarts, now)
+		}
+		statuses[dest.ID] = r.pushes[dest.ID].snapshot(now)
+	}
+
+	for id, push := range r.pushes {
+		if !enabled[id] {
+			push.stop()
+			delete(r.pushes, id)
+		}
+	}
+
+	return statuses
+}
+
+// start launches a push to a destination. A push that can't be started
+// is returned already failed, to be retried like one that exits.
+func (r *restreamer) start(dest domain.RestreamDestination, restarts int, now time.Time) *restreamPush {
+	push := &restreamPush{
+		url:  dest.URL,
+		done: make(chan struct{}),
+		status: domain.RestreamStatus{
+			State:     domain.RestreamStateConnecting,
+			Restarts:  restarts,
+			StartedAt: now,
+			UpdatedAt: now,
+		},
+	}
+
+	cmd := exec.Command(r.binaryPath,
+		"-hide_banner", "-loglevel", "error", "-nostats",
+		"-progress", "pipe:1",
+		"-live_start_index", "-1",
+		"-i", r.playlist,
+		"-map", "0",
+		"-c", "copy",
+		"-f", "flv",
+		"-flvflags", "no_duration_filesize",
+		dest.URL,
+	)
+	cmd.Stderr = &push.stderr
+	push.cmd = cmd
+
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		push.fail(fmt.Errorf("failed to start restream: %w", err), now)
+		close(push.done)
+		return push
+	}
+
+	go func() {
+		push.readProgress(stdout)
+		err := cmd.Wait()
+		if err == nil {
+			// The playlist ended, so the destination is done too
+			err = fmt.Errorf("restream ended")
+		} else {
+			err = fmt.Errorf("restream exited: %w, stderr: %s", err, tail(strings.TrimSpace(push.stderr.String()), 512))
+		}
+		push.fail(err, time.Now())
+		close(push.done)
+	}()
+
+	r.log.Info("restream started", "destination_id", dest.ID, "name", dest.Name)
+
+	return push
+}
+
+// stop disconnects from every destination; later reconciles only report
+// the pushes as stopped
+func (r *restreamer) stop() {
+	r.mu.Lock()
+	r.stopped = true
+	pushes := make([]*restreamPush, 0, len(r.pushes))
+	for _, push := range r.pushes {
+		pushes = append(pushes, push)
+	}
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, push := range pushes {
+		wg.Add(1)
+		go func(p *restreamPush) {
+			defer wg.Done()
+			p.stop()
+		}(push)
+	}
+	wg.Wait()
+}
+
+// readProgress updates the push's status from ffmpeg's key=value progress
+// reports, each of which ends with a progress line
+func (p *restreamPush) readProgress(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	var bitrate int64
+	var speed float64
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "bitrate":
+			// e.g. 2500.1kbits/s, or N/A before any output
+			if kbps, err := strconv.ParseFloat(strings.TrimSuffix(value, "kbits/s"), 64); err == nil {
+				bitrate = int64(kbps * 1000)
+			}
+		case "speed":
+			if x, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+				speed = x
+			}
+		case "progress":
+			p.mu.Lock()
+			p.status.State = domain.RestreamStateLive
+			p.status.Bitrate = bitrate
+			p.status.Speed = speed
+			p.mu.Unlock()
+		}
+	}
+}
+
+// fail records why the push ended
+func (p *restreamPush) fail(err error, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failedAt = at
+	p.status.State = domain.RestreamStateFailed
+	p.status.Bitrate = 0
+	p.status.Speed = 0
+	p.status.Error = err.Error()
+}
+
+// exited reports whether ffmpeg has exited
+func (p *restreamPush) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// snapshot returns a copy of the push's status
+func (p *restreamPush) snapshot(now time.Time) *domain.RestreamStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := p.status
+	status.UpdatedAt = now
+	return &status
+}
+
+// stop asks ffmpeg to disconnect and waits for it to exit, killing it
+// after the stop timeout
+func (p *restreamPush) stop() {
+	if p.exited() {
+		return
+	}
+	_ = p.cmd.Process.Signal(syscall.SIGINT)
+
+	select {
+	case <-p.done:
+	case <-time.After(restreamStopTimeout):
+		_ = p.cmd.Process.Kill()
+		<-p.done
+	}
+}
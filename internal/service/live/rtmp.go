@@ -0,0 +1,195 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/rtmp"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// errPackagerExited disconnects an encoder whose packager has exited
+var errPackagerExited = errors.New("live packager exited")
+
+// RTMPIngest accepts encoders pushing over RTMP, to any application with
+// the stream key as the stream name, and feeds them to the live packager
+type RTMPIngest struct {
+	service    *Service
+	server     *rtmp.Server
+	healthTick time.Duration
+	log        *logger.Logger
+}
+
+// rtmpSession is a single RTMP publisher. The FLV it is sent is remuxed
+// by the packager without decoding.
+type rtmpSession struct {
+	ingest    *RTMPIngest
+	streamID  string
+	tenantID  string
+	packager  *PackagerSession
+	input     *os.File
+	flv       *rtmp.FLVWriter
+	health    *healthTracker
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// context returns a background context acting as the stream's tenant,
+// for the session's writes outside any request
+func (s *rtmpSession) context() context.Context {
+	return tenant.WithID(context.Background(), s.tenantID)
+}
+
+// NewRTMPIngest creates an RTMP ingest server backed by the live service
+func NewRTMPIngest(service *Service, cfg config.LiveConfig, log *logger.Logger) *RTMPIngest {
+	ingest := &RTMPIngest{
+		service:    service,
+		healthTick: cfg.HealthInterval,
+		log:        log,
+	}
+	ingest.server = rtmp.NewServer(ingest, cfg.RTMPTimeout)
+	return ingest
+}
+
+// ListenAndServe accepts RTMP connections on addr until Shutdown
+func (r *RTMPIngest) ListenAndServe(addr string) error {
+	return r.server.ListenAndServe(addr)
+}
+
+// Shutdown disconnects all encoders and waits for their sessions to end
+func (r *RTMPIngest) Shutdown() {
+	_ = r.server.Close()
+}
+
+// Publish authenticates a stream key and starts the packager, reading
+// the stream as FLV. Encoders may append a query string to the key.
+func (r *RTMPIngest) Publish(ctx context.Context, app, name string) (rtmp.Publisher, error) {
+	streamKey, _, _ := strings.Cut(name, "?")
+	if streamKey == "" {
+		return nil, domain.ErrUnauthorized
+	}
+
+	stream, err := r.service.startIngest(ctx, streamKey, domain.IngestProtocolRTMP)
+	if err != nil {
+		r.log.Warn("rtmp publish rejected", "app", app, "error", err)
+		return nil, err
+	}
+
+	session, err := r.startSession(stream)
+	if err != nil {
+		r.service.endIngest(tenant.WithID(context.Background(), stream.TenantID), stream.ID)
+		return nil, err
+	}
+
+	go r.reportHealth(session)
+
+	return session, nil
+}
+
+// startSession starts a packager reading FLV from a pipe
+func (r *RTMPIngest) startSession(stream *domain.LiveStream) (*rtmpSession, error) {
+	inputR, inputW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input pipe: %w", err)
+	}
+
+	packager, err := r.service.packager.Start(stream, &Source{
+		InputArgs:  []string{"-f", "flv", "-i", "pipe:3"},
+		Files:      []*os.File{inputR},
+		VideoInput: 0,
+		AudioInput: 0,
+	})
+	// The child holds its own copy of the read end
+	inputR.Close()
+	if err != nil {
+		inputW.Close()
+		return nil, err
+	}
+
+	return &rtmpSession{
+		ingest:   r,
+		streamID: stream.ID,
+		tenantID: stream.TenantID,
+		packager: packager,
+		input:    inputW,
+		flv:      rtmp.NewFLVWriter(inputW),
+		health:   newHealthTracker(),
+		closed:   make(chan struct{}),
+	}, nil
+}
+
+// WriteMessage passes a frame to the packager. Once ffmpeg has exited
+// the write fails, which disconnects the encoder.
+func (s *rtmpSession) WriteMessage(msg *rtmp.Message) error {
+	if msg.Type != rtmp.TypeData {
+		s.health.observeFrame(len(msg.Payload), msg.Type == rtmp.TypeVideo, msg.IsKeyframe(), time.Now())
+	}
+	if err := s.flv.WriteMessage(msg); err != nil {
+		select {
+		case <-s.packager.Done():
+			return fmt.Errorf("%w: %v", errPackagerExited, s.packager.Err())
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Close lets ffmpeg flush the playlists and marks the stream as ended.
+// An encoder that went away without unpublishing is counted as a
+// disconnect.
+func (s *rtmpSession) Close(err error) {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+
+		switch {
+		case errors.Is(err, errPackagerExited):
+			s.ingest.log.Error("live packager failed", "stream_id", s.streamID, "error", err)
+		case err != nil && !errors.Is(err, context.Canceled):
+			s.health.disconnected()
+			s.ingest.service.recordDisconnect(s.context(), s.streamID)
+		}
+
+		// EOF on the input makes ffmpeg finish the playlists
+		s.input.Close()
+		if err := s.packager.Stop(); err != nil {
+			s.ingest.log.Debug("live packager stopped", "stream_id", s.streamID, "error", err)
+		}
+
+		s.ingest.service.reportHealth(s.context(), s.streamID, s.health.snapshot(time.Now()))
+		s.ingest.service.syncRestreams(s.context(), s.streamID, s.packager)
+
+		s.ingest.service.endIngest(s.context(), s.streamID)
+		s.ingest.log.Info("rtmp session closed", "stream_id", s.streamID)
+	})
+}
+
+// reportHealth periodically stores the session's ingest health, and
+// keeps its restreams in line with the stream's destinations
+func (r *RTMPIngest) reportHealth(session *rtmpSession) {
+	interval := r.healthTick
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			r.service.reportHealth(session.context(), session.streamID, session.health.snapshot(now))
+			r.service.syncRestreams(session.context(), session.streamID, session.packager)
+		case <-session.closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package live
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// streamKeyBytes is the amount of randomness in a stream key
+const streamKeyBytes = 24
+
+// Service manages live streams and their ingest sessions
+type Service struct {
+	dynamoClient *dynamodb.Client
+	packager     *Packager
+	dvrWindow    time.Duration
+	maxDVRWindow time.Duration
+	log          *logger.Logger
+}
+
+// NewService creates a new live service
+func NewService(dynamoClient *dynamodb.Client, packager *Packager, cfg config.LiveConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		packager:     packager,
+		dvrWindow:    cfg.DVRWindow,
+		maxDVRWindow: cfg.MaxDVRWindow,
+		log:          log,
+	}
+}
+
+// CreateStreamRequest contains the fields for a new live stream
+type CreateStreamRequest struct {
+	Title       string
+	Description string
+	UserID      string
+	// DVRWindow overrides the configured DVR window when set
+	DVRWindow *time.Duration
+}
+
+// CreateStream creates a live stream with a fresh stream key
+func (s *Service) CreateStream(ctx context.Context, req *CreateStreamRequest) (*domain.LiveStream, error) {
+	if req.Title == "" {
+		return nil, domain.ErrInvalidInput
+	}
+
+	dvrWindow := s.dvrWindow
+	if req.DVRWindow != nil {
+		dvrWindow = *req.DVRWindow
+	}
+	if dvrWindow < 0 || (s.maxDVRWindow > 0 && dvrWindow > s.maxDVRWindow) {
+		return nil, domain.ErrInvalidInput
+	}
+
+	key, err := generateStreamKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stream := &domain.LiveStream{
+		ID:          uuid.New().String(),
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      domain.LiveStreamStatusIdle,
+		StreamKey:   key,
+		DVRWindow:   int(dvrWindow / time.Second),
+		UserID:      req.UserID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.dynamoClient.CreateLiveStream(ctx, stream); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("live stream created", "stream_id", stream.ID, "user_id", req.UserID)
+
+	return stream, nil
+}
+
+// GetStream returns a live stream owned by the user
+func (s *Service) GetStream(ctx context.Context, streamID, userID string) (*domain.LiveStream, error) {
+	stream, err := s.dynamoClient.GetLiveStream(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	if stream.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	return stream, nil
+}
+
+// ListStreams returns the live streams owned by the user
+func (s *Service) ListStreams(ctx context.Context, userID string, limit int32) ([]*domain.LiveStream, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.dynamoClient.ListLiveStreamsByUser(ctx, userID, limit)
+}
+
+// startIngest authenticates a stream key and marks the stream live
+func (s *Service) startIngest(ctx context.Context, streamKey string, protocol domain.IngestProtocol) (*domain.LiveStream, error) {
+	stream, err := s.dynamoClient.GetLiveStreamByKey(ctx, streamKey)
+	if err != nil {
+		if err == domain.ErrStreamNotFound {
+			return nil, domain.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	// Stream keys are looked up across tenants; act as the stream's own
+	ctx = tenant.WithID(ctx, stream.TenantID)
+	if err := s.dynamoClient.StartLiveStream(ctx, stream.ID, protocol); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("live ingest started", "stream_id", stream.ID, "protocol", protocol)
+
+	return stream, nil
+}
+
+// GetHealth returns the ingest health of a stream owned by the user
+func (s *Service) GetHealth(ctx context.Context, streamID, userID string) (*HealthReport, error) {
+	stream, err := s.GetStream(ctx, streamID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HealthReport{
+		StreamID:    stream.ID,
+		Status:      stream.Status,
+		Disconnects: stream.Disconnects,
+		Health:      stream.Health,
+	}, nil
+}
+
+// HealthReport is the ingest health of a live stream
+type HealthReport struct {
+	StreamID    string                  `json:"stream_id"`
+	Status      domain.LiveStreamStatus `json:"status"`
+	Disconnects int64                   `json:"disconnects"`
+	Health      *domain.LiveHealth      `json:"health,omitempty"`
+}
+
+// reportHealth stores an ingest health snapshot
+func (s *Service) reportHealth(ctx context.Context, streamID string, health *domain.LiveHealth) {
+	if err := s.dynamoClient.UpdateLiveStreamHealth(ctx, streamID, health); err != nil {
+		s.log.Error("failed to report live health", "stream_id", streamID, "error", err)
+	}
+}
+
+// recordDisconnect counts an encoder disconnect for the stream
+func (s *Service) recordDisconnect(ctx context.Context, streamID string) {
+	if err := s.dynamoClient.IncrementLiveStreamDisconnects(ctx, streamID, 1); err != nil {
+		s.log.Error("failed to record live disconnect", "stream_id", streamID, "error", err)
+	}
+	s.log.Warn("live encoder disconnected", "stream_id", streamID)
+}
+
+// LowLatencyPlaylist returns a rendition's LL-HLS playlist, blocking for
+// the requested segment or part when msn is not negative
+func (s *Service) LowLatencyPlaylist(ctx context.Context, streamID, rendition string, msn, part int64) ([]byte, error) {
+	ll, err := s.lowLatency(streamID)
+	if err != nil {
+		return nil, err
+	}
+	return ll.Playlist(ctx, rendition, msn, part)
+}
+
+// LowLatencyMaster returns the master playlist of a stream's LL-HLS output
+func (s *Service) LowLatencyMaster(streamID string) ([]byte, error) {
+	ll, err := s.lowLatency(streamID)
+	if err != nil {
+		return nil, err
+	}
+	return ll.Master()
+}
+
+// LowLatencyFile returns the local path of a part, segment or init file
+func (s *Service) LowLatencyFile(ctx context.Context, streamID, rendition, name string) (string, error) {
+	ll, err := s.lowLatency(streamID)
+	if err != nil {
+		return "", err
+	}
+	return ll.File(ctx, rendition, name)
+}
+
+// lowLatency returns the LL-HLS output of a stream ingested by this
+// instance
+func (s *Service) lowLatency(streamID string) (*lowLatency, error) {
+	if s.packager == nil {
+		return nil, domain.ErrStreamNotLive
+	}
+	ll, ok := s.packager.LowLatency(streamID)
+	if !ok {
+		return nil, domain.ErrStreamNotLive
+	}
+	return ll, nil
+}
+
+// endIngest marks the stream as ended
+func (s *Service) endIngest(ctx context.Context, streamID string) {
+	if err := s.dynamoClient.EndLiveStream(ctx, streamID); err != nil {
+		s.log.Error("failed to end live stream", "stream_id", streamID, "error", err)
+		return
+	}
+
+	s.log.Info("live ingest ended", "stream_id", streamID)
+}
+
+// generateStreamKey returns a random stream key
+func generateStreamKey() (string, error) {
+	b := make([]byte, streamKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate stream key: %w", err)
+	}
+	return "sk_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,472 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media/h264writer"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// WHIP sessions accept H.264 video and Opus audio, which can be handed to
+// ffmpeg without decoding: video as Annex B and audio as Ogg
+var (
+	whipVideoFeedback = []webrtc.RTCPFeedback{
+		{Type: "goog-remb"},
+		{Type: "ccm", Parameter: "fir"},
+		{Type: "nack"},
+		{Type: "nack", Parameter: "pli"},
+	}
+
+	whipH264Profiles = []string{
+		"level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f",
+		"level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		"level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=4d001f",
+		"level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032",
+	}
+)
+
+// WHIPIngest accepts WebRTC contributions using the WHIP protocol and
+// feeds them to the live packager
+type WHIPIngest struct {
+	service    *Service
+	api        *webrtc.API
+	iceServers []webrtc.ICEServer
+	keyframe   time.Duration
+	healthTick time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*whipSession
+
+	log *logger.Logger
+}
+
+// whipSession is a single WHIP publisher
+type whipSession struct {
+	id        string
+	streamKey string
+	streamID  string
+	tenantID  string
+	pc        *webrtc.PeerConnection
+	packager  *PackagerSession
+	video     *os.File
+	audio     *os.File
+	health    *healthTracker
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// context returns a background context acting as the stream's tenant,
+// for the session's writes outside any request
+func (s *whipSession) context() context.Context {
+	return tenant.WithID(context.Background(), s.tenantID)
+}
+
+// WHIPSession is returned to the publisher after a successful offer
+type WHIPSession struct {
+	ID       string
+	StreamID string
+	Answer   string
+}
+
+// NewWHIPIngest creates a WHIP ingest endpoint backed by the live service
+func NewWHIPIngest(service *Service, cfg config.LiveConfig, log *logger.Logger) (*WHIPIngest, error) {
+	m := &webrtc.MediaEngine{}
+	for i, fmtp := range whipH264Profiles {
+		if err := m.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:     webrtc.MimeTypeH264,
+				ClockRate:    90000,
+				SDPFmtpLine:  fmtp,
+				RTCPFeedback: whipVideoFeedback,
+			},
+			PayloadType: webrtc.PayloadType(102 + 2*i),
+		}, webrtc.RTPCodecTypeVideo); err != nil {
+			return nil, fmt.Errorf("failed to register H.264 codec: %w", err)
+		}
+	}
+	if err := m.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeOpus,
+			ClockRate:   48000,
+			Channels:    2,
+			SDPFmtpLine: "minptime=10;useinbandfec=1",
+		},
+		PayloadType: 111,
+	}, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, fmt.Errorf("failed to register Opus codec: %w", err)
+	}
+
+	registry := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(m, registry); err != nil {
+		return nil, fmt.Errorf("failed to register interceptors: %w", err)
+	}
+
+	settings := webrtc.SettingEngine{}
+	if len(cfg.PublicIPs) > 0 {
+		settings.SetNAT1To1IPs(cfg.PublicIPs, webrtc.ICECandidateTypeHost)
+	}
+	if cfg.UDPPortMin > 0 && cfg.UDPPortMax > 0 {
+		if err := settings.SetEphemeralUDPPortRange(cfg.UDPPortMin, cfg.UDPPortMax); err != nil {
+			return nil, fmt.Errorf("invalid UDP port range: %w", err)
+		}
+	}
+
+	var iceServers []webrtc.ICEServer
+	if len(cfg.ICEServers) > 0 {
+		iceServers = []webrtc.ICEServer{{URLs: cfg.ICEServers}}
+	}
+
+	return &WHIPIngest{
+		service: service,
+		api: webrtc.NewAPI(
+			webrtc.WithMediaEngine(m),
+			webrtc.WithInterceptorRegistry(registry),
+			webrtc.WithSettingEngine(settings),
+		),
+		iceServers: iceServers,
+		keyframe:   time.Duration(cfg.SegmentDuration) * time.Second,
+		healthTick: cfg.HealthInterval,
+		sessions:   make(map[string]*whipSession),
+		log:        log,
+	}, nil
+}
+
+// Publish handles a WHIP offer: it authenticates the stream key, starts
+// the packager and returns the SDP answer
+func (w *WHIPIngest) Publish(ctx context.Context, streamKey, offer string) (*WHIPSession, error) {
+	// The packager needs both inputs, so require audio and video up front
+	if !strings.Contains(offer, "m=video") || !strings.Contains(offer, "m=audio") {
+		return nil, domain.ErrInvalidInput
+	}
+
+	stream, err := w.service.startIngest(ctx, streamKey, domain.IngestProtocolWHIP)
+	if err != nil {
+		return nil, err
+	}
+
+	session, answer, err := w.startSession(ctx, stream, streamKey, offer)
+	if err != nil {
+		w.service.endIngest(tenant.WithID(context.Background(), stream.TenantID), stream.ID)
+		return nil, err
+	}
+
+	w.mu.Lock()
+	w.sessions[session.id] = session
+	w.mu.Unlock()
+
+	go w.reportHealth(session)
+
+	// Tear down when ffmpeg exits on its own
+	go func() {
+		select {
+		case <-session.packager.Done():
+			if err := session.packager.Err(); err != nil {
+				w.log.Error("live packager failed", "stream_id", stream.ID, "error", err)
+			}
+			w.closeSession(session)
+		case <-session.closed:
+		}
+	}()
+
+	return &WHIPSession{
+		ID:       session.id,
+		StreamID: stream.ID,
+		Answer:   answer,
+	}, nil
+}
+
+// startSession negotiates the peer connection and wires its tracks to a
+// new packager process
+func (w *WHIPIngest) startSession(ctx context.Context, stream *domain.LiveStream, streamKey, offer string) (*whipSession, string, error) {
+	videoR, videoW, err := os.Pipe()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create video pipe: %w", err)
+	}
+	audioR, audioW, err := os.Pipe()
+	if err != nil {
+		videoR.Close()
+		videoW.Close()
+		return nil, "", fmt.Errorf("failed to create audio pipe: %w", err)
+	}
+
+	packager, err := w.service.packager.Start(stream, &Source{
+		InputArgs: []string{
+			"-use_wallclock_as_timestamps", "1", "-f", "h264", "-i", "pipe:3",
+			"-use_wallclock_as_timestamps", "1", "-f", "ogg", "-i", "pipe:4",
+		},
+		Files:      []*os.File{videoR, audioR},
+		VideoInput: 0,
+		AudioInput: 1,
+	})
+	// The child holds its own copies of the read ends
+	videoR.Close()
+	audioR.Close()
+	if err != nil {
+		videoW.Close()
+		audioW.Close()
+		return nil, "", err
+	}
+
+	session := &whipSession{
+		id:        uuid.New().String(),
+		streamKey: streamKey,
+		streamID:  stream.ID,
+		tenantID:  stream.TenantID,
+		packager:  packager,
+		video:     videoW,
+		audio:     audioW,
+		health:    newHealthTracker(),
+		closed:    make(chan struct{}),
+	}
+
+	answer, err := w.negotiate(ctx, session, offer)
+	if err != nil {
+		videoW.Close()
+		audioW.Close()
+		_ = packager.Stop()
+		return nil, "", err
+	}
+
+	return session, answer, nil
+}
+
+// negotiate creates the peer connection and returns the SDP answer once
+// ICE gathering completes (WHIP does not require trickle ICE)
+func (w *WHIPIngest) negotiate(ctx context.Context, session *whipSession, offer string) (string, error) {
+	pc, err := w.api.NewPeerConnection(webrtc.Configuration{ICEServers: w.iceServers})
+	if err != nil {
+		return "", fmt.Errorf("failed to create peer connection: %w", err)
+	}
+	session.pc = pc
+
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeVideo, webrtc.RTPCodecTypeAudio} {
+		if _, err := pc.AddTransceiverFromKind(kind, webrtc.RTPTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionRecvonly,
+		}); err != nil {
+			pc.Close()
+			return "", fmt.Errorf("failed to add transceiver: %w", err)
+		}
+	}
+
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		w.handleTrack(session, track)
+	})
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		w.log.Debug("whip connection state", "session_id", session.id, "state", state.String())
+		switch state {
+		case webrtc.PeerConnectionStateDisconnected:
+			session.health.disconnected()
+			go w.service.recordDisconnect(session.context(), session.streamID)
+		case webrtc.PeerConnectionStateFailed:
+			go w.closeSession(session)
+		case webrtc.PeerConnectionStateClosed:
+			go w.closeSession(session)
+		}
+	})
+
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  offer,
+	}); err != nil {
+		pc.Close()
+		return "", fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		pc.Close()
+		return "", fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		pc.Close()
+		return "", fmt.Errorf("failed to set local description: %w", err)
+	}
+
+	select {
+	case <-gatherComplete:
+	case <-ctx.Done():
+		pc.Close()
+		return "", ctx.Err()
+	}
+
+	return pc.LocalDescription().SDP, nil
+}
+
+// handleTrack copies a remote track into the matching packager input
+func (w *WHIPIngest) handleTrack(session *whipSession, track *webrtc.TrackRemote) {
+	mime := track.Codec().MimeType
+
+	var write func(*rtp.Packet) error
+	switch {
+	case strings.EqualFold(mime, webrtc.MimeTypeH264):
+		writer := h264writer.NewWith(session.video)
+		write = func(p *rtp.Packet) error {
+			session.health.observeVideo(p, time.Now())
+			return writer.WriteRTP(p)
+		}
+
+		// Request keyframes at segment cadence so the packager can cut
+		go w.requestKeyframes(session, track)
+	case strings.EqualFold(mime, webrtc.MimeTypeOpus):
+		writer, err := oggwriter.NewWith(session.audio, 48000, track.Codec().Channels)
+		if err != nil {
+			w.log.Error("failed to create ogg writer", "session_id", session.id, "error", err)
+			return
+		}
+		write = func(p *rtp.Packet) error {
+			session.health.observeAudio(p)
+			return writer.WriteRTP(p)
+		}
+	default:
+		w.log.Warn("unsupported whip track codec", "session_id", session.id, "codec", mime)
+		return
+	}
+
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			if err != io.EOF {
+				w.log.Debug("whip track ended", "session_id", session.id, "error", err)
+			}
+			return
+		}
+
+		if err := write(packet); err != nil {
+			// The packager has gone away; the session is being torn down
+			return
+		}
+	}
+}
+
+// requestKeyframes sends periodic PLIs for a video track until the
+// session closes
+func (w *WHIPIngest) requestKeyframes(session *whipSession, track *webrtc.TrackRemote) {
+	interval := w.keyframe
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := session.pc.WriteRTCP([]rtcp.Packet{
+				&rtcp.PictureLossIndication{MediaSSRC: uint32(track.SSRC())},
+			}); err != nil {
+				return
+			}
+		case <-session.closed:
+			return
+		}
+	}
+}
+
+// reportHealth periodically stores the session's ingest health, and
+// keeps its restreams in line with the stream's destinations
+func (w *WHIPIngest) reportHealth(session *whipSession) {
+	interval := w.healthTick
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			w.service.reportHealth(session.context(), session.streamID, session.health.snapshot(now))
+			w.service.syncRestreams(session.context(), session.streamID, session.packager)
+		case <-session.closed:
+			return
+		}
+	}
+}
+
+// Stop ends a WHIP session. The stream key must match the one the
+// session was published with.
+func (w *WHIPIngest) Stop(sessionID, streamKey string) error {
+	w.mu.Lock()
+	session, ok := w.sessions[sessionID]
+	w.mu.Unlock()
+
+	if !ok {
+		return domain.ErrSessionNotFound
+	}
+	if session.streamKey != streamKey {
+		return domain.ErrUnauthorized
+	}
+
+	w.closeSession(session)
+	return nil
+}
+
+// Shutdown ends all active sessions
+func (w *WHIPIngest) Shutdown() {
+	w.mu.Lock()
+	sessions := make([]*whipSession, 0, len(w.sessions))
+	for _, session := range w.sessions {
+		sessions = append(sessions, session)
+	}
+	w.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(s *whipSession) {
+			defer wg.Done()
+			w.closeSession(s)
+		}(session)
+	}
+	wg.Wait()
+}
+
+// closeSession tears down the peer connection, lets ffmpeg flush the
+// playlists and marks the stream as ended
+func (w *WHIPIngest) closeSession(session *whipSession) {
+	session.closeOnce.Do(func() {
+		close(session.closed)
+
+		w.mu.Lock()
+		delete(w.sessions, session.id)
+		w.mu.Unlock()
+
+		if session.pc != nil {
+			_ = session.pc.Close()
+		}
+
+		// EOF on both inputs makes ffmpeg finish the playlists
+		session.video.Close()
+		session.audio.Close()
+		if err := session.packager.Stop(); err != nil {
+			w.log.Debug("live packager stopped", "stream_id", session.streamID, "error", err)
+		}
+
+		w.service.reportHealth(session.context(), session.streamID, session.health.snapshot(time.Now()))
+		w.service.syncRestreams(session.context(), session.streamID, session.packager)
+
+		w.service.endIngest(session.context(), session.streamID)
+		w.log.Info("whip session closed", "session_id", session.id, "stream_id", session.streamID)
+	})
+}
@@ -0,0 +1,221 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/service/search"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Provider checks images against content policy
+type Provider interface {
+	// Name identifies the provider on moderation results
+	Name() string
+	// ModerateImage returns the policy violations found in a JPEG image
+	ModerateImage(ctx context.Context, image []byte) ([]domain.ModerationLabel, error)
+}
+
+// AudioProvider is implemented by providers that can also check a
+// source's audio, such as for hate speech
+type AudioProvider interface {
+	ModerateAudio(ctx context.Context, sourcePath string) ([]domain.ModerationLabel, error)
+}
+
+// FrameSampler extracts still frames from video
+type FrameSampler interface {
+	SampleFrames(ctx context.Context, source, dir string, interval time.Duration, max int) ([]string, error)
+}
+
+// Service scans media against content policy and records reviewers'
+// decisions
+type Service struct {
+	dynamoClient *dynamodb.Client
+	provider     Provider
+	sampler      FrameSampler
+	search       *search.Service
+	cfg          config.ModerationConfig
+	log          *logger.Logger
+}
+
+// NewService creates a new moderation service. Scanning also needs
+// SetScanner; reviewing doesn't.
+func NewService(dynamoClient *dynamodb.Client, cfg config.ModerationConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		cfg:          cfg,
+		log:          log,
+	}
+}
+
+// SetScanner sets the provider media is checked with and the sampler
+// taking frames from video for it
+func (s *Service) SetScanner(provider Provider, sampler FrameSampler) {
+	s.provider = provider
+	s.sampler = sampler
+}
+
+// SetSearch keeps the search index in step with review decisions
+func (s *Service) SetSearch(svc *search.Service) {
+	s.search = svc
+}
+
+// Scan checks the source of a media item at sourcePath and records the
+// result. Media with violations is flagged, or held for review when
+// configured to be; so is media whose scan failed, as it may not be
+// published unchecked.
+func (s *Service) Scan(ctx context.Context, media *domain.Media, sourcePath string) (*domain.Moderation, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("no moderation provider configured")
+	}
+
+	now := time.Now().UTC()
+	result := &domain.Moderation{
+		Status:    domain.ModerationStatusApproved,
+		Provider:  s.provider.Name(),
+		ScannedAt: &now,
+	}
+
+	labels, scanErr := s.scan(ctx, media, sourcePath)
+	switch {
+	case scanErr != nil:
+		if !s.cfg.HoldForReview {
+			return nil, scanErr
+		}
+		result.Status = domain.ModerationStatusPendingReview
+		result.Error = scanErr.Error()
+	case len(labels) > 0:
+		result.Labels = labels
+		result.Status = domain.ModerationStatusFlagged
+		if s.cfg.HoldForReview {
+			result.Status = domain.ModerationStatusPendingReview
+		}
+	}
+
+	if err := s.dynamoClient.SetMediaModeration(ctx, media.ID, result); err != nil {
+		return nil, err
+	}
+	media.Moderation = result
+	s.relistTags(ctx, media)
+
+	if result.Status != domain.ModerationStatusApproved {
+		s.log.Warn("media flagged by moderation", "media_id", media.ID, "status", result.Status,
+			"labels", len(result.Labels), "error", result.Error)
+	}
+
+	return result, scanErr
+}
+
+// scan runs the provider over sampled video frames and, when it supports
+// it, the audio
+func (s *Service) scan(ctx context.Context, media *domain.Media, sourcePath string) ([]domain.ModerationLabel, error) {
+	var labels []domain.ModerationLabel
+
+	if media.Type == domain.MediaTypeVideo {
+		dir, err := os.MkdirTemp("", "moderation-"+media.ID+"-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create frames directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+
+		frames, err := s.sampler.SampleFrames(ctx, sourcePath, dir, s.cfg.FrameInterval, s.cfg.MaxFrames)
+		if err != nil {
+			return nil, err
+		}
+		for i, frame := range frames {
+			image, err := os.ReadFile(frame)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read frame %s: %w", filepath.Base(frame), err)
+			}
+			found, err := s.provider.ModerateImage(ctx, image)
+			if err != nil {
+				return nil, err
+			}
+			for _, label := range found {
+				label.Timestamp = float64(i) * s.cfg.FrameInterval.Seconds()
+				label.Source = "frame"
+				labels = append(labels, label)
+			}
+		}
+	}
+
+	if audio, ok := s.provider.(AudioProvider); ok {
+		found, err := audio.ModerateAudio(ctx, sourcePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, label := range found {
+			label.Source = "audio"
+			labels = append(labels, label)
+		}
+	}
+
+	return labels, nil
+}
+
+// Review records a reviewer approving or rejecting a media item, which
+// releases or keeps holding it
+func (s *Service) Review(ctx context.Context, mediaID, reviewerID string, status domain.ModerationStatus) (*domain.Moderation, error) {
+	if status != domain.ModerationStatusApproved && status != domain.ModerationStatusRejected {
+		return nil, domain.ErrInvalidInput
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := media.Moderation
+	if result == nil {
+		// Media can be reviewed without having been scanned
+		result = &domain.Moderation{}
+	}
+	now := time.Now().UTC()
+	result.Status = status
+	result.ReviewedBy = reviewerID
+	result.ReviewedAt = &now
+
+	if err := s.dynamoClient.SetMediaModeration(ctx, mediaID, result); err != nil {
+		return nil, err
+	}
+	media.Moderation = result
+	s.relistTags(ctx, media)
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+
+	s.log.Info("media reviewed", "media_id", mediaID, "status", status, "reviewer", reviewerID)
+
+	return result, nil
+}
+
+// relistTags counts media under its tags only while moderation doesn't
+// hold it, as popular tags only count listed media. A failure is logged:
+// the moderation result stands either way.
+func (s *Service) relistTags(ctx context.Context, media *domain.Media) {
+	if len(media.Tags) == 0 {
+		return
+	}
+	if err := s.dynamoClient.SetTagEntriesListed(ctx, media.ID, domain.TagIndexKeys(media.Tags), media.IsListed()); err != nil {
+		s.log.Error("failed to update tag index", "error", err, "media_id", media.ID)
+	}
+}
+
+// List lists a page of media with a moderation status, oldest first
+func (s *Service) List(ctx context.Context, status domain.ModerationStatus, limit int32, cursor string) ([]*domain.Media, string, error) {
+	if !status.IsValid() {
+		return nil, "", domain.ErrInvalidInput
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	return s.dynamoClient.ListMediaByModerationStatus(ctx, status, limit, cursor)
+}
@@ -0,0 +1,153 @@
+// Package notify emails users about their media, such as when an upload
+// finishes processing, according to their preferences
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/cloudfront"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Sender delivers plain text emails
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// Service manages notification preferences and sends notifications
+type Service struct {
+	dynamoClient     *dynamodb.Client
+	sender           Sender
+	cfg              config.NotificationsConfig
+	cloudFrontDomain string
+	log              *logger.Logger
+}
+
+// NewService creates a new notification service. sender may be nil where
+// only preferences are managed, such as in the API.
+func NewService(dynamoClient *dynamodb.Client, sender Sender, cfg config.NotificationsConfig, cloudFrontDomain string, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient:     dynamoClient,
+		sender:           sender,
+		cfg:              cfg,
+		cloudFrontDomain: cloudFrontDomain,
+		log:              log,
+	}
+}
+
+// GetPreferences returns a user's notification preferences, or the
+// defaults if they saved none
+func (s *Service) GetPreferences(ctx context.Context, userID string) (*domain.UserPreferences, error) {
+	prefs, err := s.dynamoClient.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs == nil {
+		prefs = domain.DefaultPreferences(userID)
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences replaces a user's notification preferences. An email
+// is required while any notification is on.
+func (s *Service) UpdatePreferences(ctx context.Context, userID string, notifications domain.NotificationPreferences) (*domain.UserPreferences, error) {
+	notifications.Email = strings.TrimSpace(notifications.Email)
+	if notifications.Email == "" && (notifications.MediaProcessed || notifications.MediaFailed) {
+		return nil, domain.ErrInvalidInput
+	}
+
+	prefs := &domain.UserPreferences{
+		UserID:        userID,
+		Notifications: notifications,
+		UpdatedAt:     time.Now().UTC(),
+	}
+	if err := s.dynamoClient.PutPreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+
+	return prefs, nil
+}
+
+// HandleEvent emails the owner of an event's media item when processing
+// completed or failed, if their preferences ask for it
+func (s *Service) HandleEvent(ctx context.Context, event *domain.Event) error {
+	if s.sender == nil {
+		return nil
+	}
+	if event.Type != domain.EventMediaProcessed && event.Type != domain.EventMediaFailed {
+		return nil
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, event.MediaID)
+	if err != nil {
+		return fmt.Errorf("failed to get media: %w", err)
+	}
+	prefs, err := s.GetPreferences(ctx, media.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get preferences: %w", err)
+	}
+
+	n := prefs.Notifications
+	var subject, body string
+	switch event.Type {
+	case domain.EventMediaProcessed:
+		if !n.MediaProcessed {
+			return nil
+		}
+		subject, body = s.processedEmail(media)
+	case domain.EventMediaFailed:
+		if !n.MediaFailed {
+			return nil
+		}
+		subject, body = failedEmail(media)
+	}
+	if n.Email == "" {
+		return nil
+	}
+
+	if err := s.sender.Send(ctx, n.Email, subject, body); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx, s.log).Info("notification sent",
+		"media_id", media.ID, "user_id", media.UserID, "event", event.Type)
+
+	return nil
+}
+
+// processedEmail is the email sent when a media item is ready to watch
+func (s *Service) processedEmail(media *domain.Media) (string, string) {
+	subject := fmt.Sprintf("%q is ready to watch", media.Title)
+	body := fmt.Sprintf("Your upload %q has finished processing and is ready to watch:\n\n%s\n",
+		media.Title, s.watchURL(media))
+	return subject, body
+}
+
+// failedEmail is the email sent when processing a media item failed. The
+// error itself is internal, so only the stage is given.
+func failedEmail(media *domain.Media) (string, string) {
+	subject := fmt.Sprintf("Processing %q failed", media.Title)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "We couldn't process your upload %q.\n", media.Title)
+	if media.Processing != nil && media.Processing.FailedStage != "" {
+		fmt.Fprintf(&body, "\nIt failed while %s.\n", media.Processing.FailedStage)
+	}
+	body.WriteString("\nYou can try uploading it again.\n")
+	return subject, body.String()
+}
+
+// watchURL links to a media item: the configured watch page, or else its
+// master playlist
+func (s *Service) watchURL(media *domain.Media) string {
+	if s.cfg.WatchURL != "" {
+		return strings.ReplaceAll(s.cfg.WatchURL, "{id}", media.ID)
+	}
+	return cloudfront.URL(s.cloudFrontDomain, media.GetMasterPlaylistKey())
+}
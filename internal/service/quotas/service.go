@@ -0,0 +1,235 @@
+package quotas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// Usage counters; transcode minutes are counted in seconds per month
+	storageCounter   = "storage"
+	jobsCounter      = "jobs"
+	transcodeCounter = "transcode#"
+
+	// periodFormat is the UTC month transcode minutes are counted in
+	periodFormat = "2006-01"
+	// periodRetention keeps monthly counters around for a year
+	periodRetention = 400 * 24 * time.Hour
+)
+
+// Service enforces per-tenant limits on storage, monthly transcode
+// minutes and concurrent processing jobs, warning by webhook as usage
+// approaches them. Limits are checked for the tenant of the context.
+type Service struct {
+	dynamoClient *dynamodb.Client
+	cfg          config.QuotasConfig
+	client       *http.Client
+	log          *logger.Logger
+}
+
+// NewService creates a new quota service
+func NewService(dynamoClient *dynamodb.Client, cfg config.QuotasConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		cfg:          cfg,
+		client:       &http.Client{Timeout: cfg.WebhookTimeout},
+		log:          log,
+	}
+}
+
+// ReserveStorage counts size bytes against the tenant's storage quota,
+// failing with domain.ErrQuotaExceeded if they don't fit
+func (s *Service) ReserveStorage(ctx context.Context, size int64) error {
+	limit := s.cfg.For(tenant.FromContext(ctx)).StorageBytes
+	usage, err := s.dynamoClient.AddQuotaUsage(ctx, storageCounter, size, limit, 0)
+	if err != nil {
+		return err
+	}
+	s.warn(ctx, domain.QuotaStorage, "", float64(usage-size), float64(usage), limit)
+	return nil
+}
+
+// AddStorage counts size bytes already stored, such as processed output,
+// without enforcing the limit. A negative size releases storage.
+func (s *Service) AddStorage(ctx context.Context, size int64) {
+	if size == 0 {
+		return
+	}
+	usage, err := s.dynamoClient.AddQuotaUsage(ctx, storageCounter, size, 0, 0)
+	if err != nil {
+		logger.FromContext(ctx, s.log).Error("failed to record storage usage", "error", err, "bytes", size)
+		return
+	}
+	limit := s.cfg.For(tenant.FromContext(ctx)).StorageBytes
+	s.warn(ctx, domain.QuotaStorage, "", float64(usage-size), float64(usage), limit)
+}
+
+// CheckStorage fails with domain.ErrQuotaExceeded once the tenant's
+// storage quota is used up, for uploads whose size isn't known yet
+func (s *Service) CheckStorage(ctx context.Context) error {
+	limit := s.cfg.For(tenant.FromContext(ctx)).StorageBytes
+	if limit == 0 {
+		return nil
+	}
+	usage, err := s.dynamoClient.GetQuotaUsage(ctx, storageCounter)
+	if err != nil {
+		return err
+	}
+	if usage[storageCounter] >= limit {
+		return domain.ErrQuotaExceeded
+	}
+	return nil
+}
+
+// StartJob claims one of the tenant's concurrent job slots for a job about
+// to be queued. It fails with domain.ErrQuotaExceeded when every slot is
+// taken or the month's transcode minutes are used up. The slot is freed
+// with EndJob.
+func (s *Service) StartJob(ctx context.Context) error {
+	limits := s.cfg.For(tenant.FromContext(ctx))
+
+	if limits.TranscodeMinutes > 0 {
+		counter := transcodeCounter + period(time.Now())
+		usage, err := s.dynamoClient.GetQuotaUsage(ctx, counter)
+		if err != nil {
+			return err
+		}
+		if usage[counter] >= limits.TranscodeMinutes*60 {
+			return domain.ErrQuotaExceeded
+		}
+	}
+
+	usage, err := s.dynamoClient.AddQuotaUsage(ctx, jobsCounter, 1, limits.ConcurrentJobs, 0)
+	if err != nil {
+		return err
+	}
+	s.warn(ctx, domain.QuotaConcurrentJobs, "", float64(usage-1), float64(usage), limits.ConcurrentJobs)
+	return nil
+}
+
+// EndJob frees the slot of a job that has finished for good, whether it
+// succeeded or ran out of attempts
+func (s *Service) EndJob(ctx context.Context) {
+	if _, err := s.dynamoClient.AddQuotaUsage(ctx, jobsCounter, -1, 0, 0); err != nil {
+		logger.FromContext(ctx, s.log).Error("failed to release job slot", "error", err)
+	}
+}
+
+// RecordTranscode counts the duration of media transcoded against the
+// month's transcode minutes. The job was admitted by StartJob, so the
+// limit may be overrun by the jobs running when it is reached.
+func (s *Service) RecordTranscode(ctx context.Context, duration time.Duration) {
+	seconds := int64(math.Ceil(duration.Seconds()))
+	if seconds <= 0 {
+		return
+	}
+
+	now := time.Now()
+	month := period(now)
+	usage, err := s.dynamoClient.AddQuotaUsage(ctx, transcodeCounter+month, seconds, 0, now.Add(periodRetention).Unix())
+	if err != nil {
+		logger.FromContext(ctx, s.log).Error("failed to record transcode minutes", "error", err, "seconds", seconds)
+		return
+	}
+	limit := s.cfg.For(tenant.FromContext(ctx)).TranscodeMinutes
+	s.warn(ctx, domain.QuotaTranscodeMinutes, month, float64(usage-seconds)/60, float64(usage)/60, limit)
+}
+
+// Usage returns the tenant's current usage and limits
+func (s *Service) Usage(ctx context.Context) (*domain.QuotaUsage, error) {
+	id := tenant.FromContext(ctx)
+	limits := s.cfg.For(id)
+	month := period(time.Now())
+
+	usage, err := s.dynamoClient.GetQuotaUsage(ctx, storageCounter, jobsCounter, transcodeCounter+month)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.QuotaUsage{
+		TenantID:              id,
+		Period:                month,
+		StorageBytes:          usage[storageCounter],
+		StorageLimit:          limits.StorageBytes,
+		TranscodeMinutes:      float64(usage[transcodeCounter+month]) / 60,
+		TranscodeMinutesLimit: limits.TranscodeMinutes,
+		ActiveJobs:            usage[jobsCounter],
+		ConcurrentJobsLimit:   limits.ConcurrentJobs,
+	}, nil
+}
+
+// warn sends a usage warning when a change from before to after crosses
+// the warning threshold of a limit, so each crossing is reported once
+func (s *Service) warn(ctx context.Context, quota domain.Quota, month string, before, after float64, limit int64) {
+	if limit <= 0 || s.cfg.WarnAt <= 0 {
+		return
+	}
+	threshold := s.cfg.WarnAt * float64(limit)
+	if before >= threshold || after < threshold {
+		return
+	}
+
+	warning := &domain.QuotaWarning{
+		TenantID: tenant.FromContext(ctx),
+		Quota:    quota,
+		Usage:    after,
+		Limit:    limit,
+		Period:   month,
+		At:       time.Now().UTC(),
+	}
+	log := logger.FromContext(ctx, s.log)
+	log.Warn("tenant approaching quota", "tenant_id", warning.TenantID, "quota", quota, "usage", after, "limit", limit)
+
+	if s.cfg.WebhookURL == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.WebhookTimeout)
+		defer cancel()
+
+		if err := s.sendWarning(ctx, warning); err != nil {
+			log.Error("failed to send quota warning", "error", err, "tenant_id", warning.TenantID, "quota", quota)
+		}
+	}()
+}
+
+// sendWarning POSTs a warning to the webhook as JSON
+func (s *Service) sendWarning(ctx context.Context, warning *domain.QuotaWarning) error {
+	body, err := json.Marshal(warning)
+	if err != nil {
+		return fmt.Errorf("failed to marshal warning: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// period returns the month transcode minutes at t are counted in
+func period(t time.Time) string {
+	return t.UTC().Format(periodFormat)
+}
@@ -0,0 +1,196 @@
+// Package retention expires media after its tenant's retention or the time
+// set on it, warning by webhook before deleting or archiving it.
+package retention
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/service/stream"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// pageSize is how many expiring media are read at a time
+const pageSize = 25
+
+// Service expires media under its tenant's retention policy or the
+// expiration set on it, deleting or archiving it once a warning has been
+// sent
+type Service struct {
+	dynamoClient  *dynamodb.Client
+	streamService *stream.Service
+	cfg           config.RetentionConfig
+	client        *http.Client
+	log           *logger.Logger
+}
+
+// NewService creates a new retention service
+func NewService(dynamoClient *dynamodb.Client, streamService *stream.Service, cfg config.RetentionConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient:  dynamoClient,
+		streamService: streamService,
+		cfg:           cfg,
+		client:        &http.Client{Timeout: cfg.WebhookTimeout},
+		log:           log,
+	}
+}
+
+// SetExpiration sets when a media item owned by the user expires, or
+// keeps it indefinitely when at is nil
+func (s *Service) SetExpiration(ctx context.Context, mediaID, userID string, at *time.Time) error {
+	if at != nil && !at.After(time.Now()) {
+		return domain.ErrInvalidInput
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	if media.UserID != userID {
+		return domain.ErrUnauthorized
+	}
+
+	return s.dynamoClient.SetMediaExpiration(ctx, mediaID, at)
+}
+
+// Run expires media every interval until ctx is cancelled
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.ExpirePending(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error("media expiration failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpirePending warns about the media of every tenant expiring within the
+// warning period and expires the media whose time has come. Media is only
+// expired once its warning was sent, so media whose expiration was set
+// inside the warning period is warned about now and expired on a later
+// pass. Media under legal hold is skipped.
+func (s *Service) ExpirePending(ctx context.Context) error {
+	now := time.Now()
+	cursor := ""
+	for {
+		mediaList, next, err := s.dynamoClient.ListExpiringMedia(ctx, now.Add(s.cfg.WarnBefore), pageSize, cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, media := range mediaList {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// Held media waits, unwarned, until its hold is released
+			if media.LegalHold != nil {
+				continue
+			}
+
+			mediaCtx := tenant.WithID(ctx, media.TenantID)
+			if s.cfg.WarnBefore > 0 && media.ExpiryWarnedAt == nil {
+				s.warn(mediaCtx, media)
+				continue
+			}
+			if media.ExpiresAt.After(now) {
+				continue
+			}
+			s.expire(mediaCtx, media)
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// expire deletes or archives a media item as the policy says
+func (s *Service) expire(ctx context.Context, media *domain.Media) {
+	log := logger.FromContext(ctx, s.log)
+
+	var err error
+	if s.action() == domain.ExpirationActionArchive {
+		err = s.streamService.ArchiveMedia(ctx, media.ID)
+	} else {
+		err = s.streamService.DeleteMedia(ctx, media.ID, media.UserID)
+	}
+	if err != nil {
+		log.Error("failed to expire media", "error", err, "media_id", media.ID, "action", s.action())
+		return
+	}
+
+	log.Info("media expired", "media_id", media.ID, "tenant_id", media.TenantID, "action", s.action())
+}
+
+// warn sends the warning that a media item is about to expire, recording
+// it once the webhook accepted it
+func (s *Service) warn(ctx context.Context, media *domain.Media) {
+	warning := &domain.ExpirationWarning{
+		MediaID:   media.ID,
+		TenantID:  tenant.Of(media.TenantID),
+		UserID:    media.UserID,
+		Title:     media.Title,
+		ExpiresAt: *media.ExpiresAt,
+		Action:    s.action(),
+	}
+	log := logger.FromContext(ctx, s.log)
+	log.Warn("media expiring", "media_id", media.ID, "tenant_id", warning.TenantID, "expires_at", warning.ExpiresAt, "action", warning.Action)
+
+	if s.cfg.WebhookURL != "" {
+		if err := s.sendWarning(ctx, warning); err != nil {
+			log.Error("failed to send expiration warning", "error", err, "media_id", media.ID)
+			return
+		}
+	}
+
+	if err := s.dynamoClient.MarkExpiryWarned(ctx, media.ID, time.Now()); err != nil {
+		log.Error("failed to record expiration warning", "error", err, "media_id", media.ID)
+	}
+}
+
+// sendWarning POSTs a warning to the webhook as JSON
+func (s *Service) sendWarning(ctx context.Context, warning *domain.ExpirationWarning) error {
+	body, err := json.Marshal(warning)
+	if err != nil {
+		return fmt.Errorf("failed to marshal warning: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// action returns what happens to expired media
+func (s *Service) action() domain.ExpirationAction {
+	return domain.ExpirationAction(s.cfg.Action)
+}
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxKeywordCues bounds the cues of a transcript read for keywords
+	maxKeywordCues = 1000
+	// maxKeywords is how many keywords of a transcript are searched for
+	maxKeywords = 8
+	// minKeywordLength is the shortest word, in letters, taken as a keyword
+	minKeywordLength = 4
+)
+
+// stopwords are common English words too frequent to be keywords
+var stopwords = map[string]bool{
+	"about": true, "after": true, "again": true, "also": true, "because": true,
+	"been": true, "before": true, "being": true, "could": true, "does": true,
+	"doing": true, "down": true, "each": true, "even": true, "from": true,
+	"going": true, "gonna": true, "have": true, "here": true, "into": true,
+	"just": true, "know": true, "like": true, "little": true, "look": true,
+	"make": true, "many": true, "more": true, "most": true, "much": true,
+	"need": true, "okay": true, "only": true, "other": true, "over": true,
+	"really": true, "right": true, "said": true, "same": true, "should": true,
+	"some": true, "something": true, "still": true, "such": true, "take": true,
+	"than": true, "that": true, "their": true, "them": true, "then": true,
+	"there": true, "these": true, "they": true, "thing": true, "things": true,
+	"think": true, "this": true, "those": true, "through": true, "very": true,
+	"want": true, "well": true, "were": true, "what": true, "when": true,
+	"where": true, "which": true, "while": true, "will": true, "with": true,
+	"would": true, "yeah": true, "your": true,
+}
+
+// RelatedByTranscript returns public, playable media whose transcripts
+// share keywords with the transcript of a media item, best match first.
+// Media without an indexed transcript has none.
+func (s *Service) RelatedByTranscript(ctx context.Context, mediaID string, limit int) ([]*TranscriptResult, error) {
+	docs, err := s.index.TranscriptDocuments(ctx, fmt.Sprintf("media_id = %q", mediaID), maxKeywordCues)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.Text
+	}
+
+	words := keywords(texts, maxKeywords)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	// Meilisearch drops query words from the end until enough cues match,
+	// so the most frequent keywords go first
+	results, err := s.SearchTranscripts(ctx, strings.Join(words, " "), MaxLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*TranscriptResult, 0, limit)
+	for _, item := range results.Items {
+		if item.ID == mediaID {
+			continue
+		}
+		items = append(items, item)
+		if len(items) == limit {
+			break
+		}
+	}
+	return items, nil
+}
+
+// keywords returns up to n of the most frequent words in texts, leaving
+// out short words and stopwords
+func keywords(texts []string, n int) []string {
+	counts := make(map[string]int)
+	for _, text := range texts {
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		for _, word := range words {
+			if len([]rune(word)) >= minKeywordLength && !stopwords[word] {
+				counts[word]++
+			}
+		}
+	}
+
+	words := make([]string, 0, len(counts))
+	for word := range counts {
+		words = append(words, word)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if counts[words[i]] == counts[words[j]] {
+			return words[i] < words[j]
+		}
+		return counts[words[i]] > counts[words[j]]
+	})
+
+	if len(words) > n {
+		words = words[:n]
+	}
+	return words
+}
@@ -0,0 +1,190 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/repository/meilisearch"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+const (
+	// DefaultLimit is the page size when none is requested
+	DefaultLimit = 20
+	// MaxLimit caps the page size
+	MaxLimit = 100
+	// indexTimeout bounds a background index update
+	indexTimeout = 10 * time.Second
+)
+
+// searchableFilter restricts results to media anyone may find
+var searchableFilter = "visibility = " + string(domain.VisibilityPublic) +
+	" AND status = " + string(domain.MediaStatusCompleted)
+
+// Service indexes the media catalog and serves full-text queries
+type Service struct {
+	dynamoClient *dynamodb.Client
+	index        *meilisearch.Client
+	log          *logger.Logger
+}
+
+// Result is a media item matching a query
+type Result struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	Tags        []string `json:"tags,omitempty"`
+	Duration    float64  `json:"duration"`
+	// Score is the relevance of the match between 0 and 1
+	Score float64 `json:"score"`
+}
+
+// Results is a page of relevance-ordered matches
+type Results struct {
+	Items []*Result `json:"items"`
+	// Total is an estimate of the number of matches
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// NewService creates a new search service
+func NewService(dynamoClient *dynamodb.Client, index *meilisearch.Client, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		index:        index,
+		log:          log,
+	}
+}
+
+// Init configures the search index
+func (s *Service) Init(ctx context.Context) error {
+	return s.index.EnsureIndex(ctx)
+}
+
+// MediaChanged refreshes a media item's index entry from its record, or
+// removes it once the record is gone. The update runs in the background,
+// as ctx's tenant, so the catalog keeps working when the search engine is
+// unavailable.
+func (s *Service) MediaChanged(ctx context.Context, mediaID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(tenant.Detach(ctx), indexTimeout)
+		defer cancel()
+
+		if err := s.reindex(ctx, mediaID); err != nil {
+			s.log.Error("failed to update search index", "error", err, "media_id", mediaID)
+		}
+	}()
+}
+
+// reindex synchronises a media item's index entry
+func (s *Service) reindex(ctx context.Context, mediaID string) error {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err == domain.ErrMediaNotFound {
+		if err := s.index.DeleteTranscripts(ctx, fmt.Sprintf("media_id = %q", mediaID)); err != nil {
+			return err
+		}
+		return s.index.DeleteDocument(ctx, mediaID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.index.IndexDocuments(ctx, []meilisearch.Document{toDocument(media)})
+}
+
+// Search returns public, playable media matching the query, most relevant
+// first
+func (s *Service) Search(ctx context.Context, query string, limit, offset int) (*Results, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || offset < 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	resp, err := s.index.Search(ctx, &meilisearch.SearchRequest{
+		Query:  query,
+		Filter: searchableFilter + " AND " + tenantFilter(tenant.FromContext(ctx)),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*Result, 0, len(resp.Hits))
+	for _, hit := range resp.Hits {
+		items = append(items, &Result{
+			ID:          hit.ID,
+			Title:       hit.Title,
+			Description: hit.Description,
+			Type:        hit.Type,
+			Tags:        hit.Tags,
+			Duration:    hit.Duration,
+			Score:       hit.RankingScore,
+		})
+	}
+
+	return &Results{
+		Items:  items,
+		Total:  resp.EstimatedTotalHits,
+		Limit:  limit,
+		Offset: offset,
+	}, nil
+}
+
+// tenantFilter restricts results to a tenant's media. Media indexed
+// before tenants were introduced belongs to the default tenant.
+func tenantFilter(id string) string {
+	filter := fmt.Sprintf("tenant_id = %q", id)
+	if id == tenant.Default {
+		filter = "(" + filter + " OR tenant_id NOT EXISTS)"
+	}
+	return filter
+}
+
+// toDocument converts a media record to its index form. Tags are indexed
+// as both "key:value" and the bare value so either matches.
+func toDocument(media *domain.Media) meilisearch.Document {
+	// Held media stays out of results until it is reviewed
+	visibility := media.GetVisibility()
+	if media.IsHeld() {
+		visibility = domain.VisibilityPrivate
+	}
+
+	tags := make([]string, 0, len(media.Tags)*2)
+	for key, value := range media.Tags {
+		if value == "" {
+			tags = append(tags, key)
+			continue
+		}
+		tags = append(tags, key+":"+value, value)
+	}
+	sort.Strings(tags)
+
+	return meilisearch.Document{
+		ID:          media.ID,
+		Title:       media.Title,
+		Description: media.Description,
+		Tags:        tags,
+		Type:        string(media.Type),
+		Status:      string(media.Status),
+		Visibility:  string(visibility),
+		UserID:      media.UserID,
+		TenantID:    tenant.Of(media.TenantID),
+		Duration:    media.Duration,
+		CreatedAt:   media.CreatedAt.Unix(),
+	}
+}
@@ -0,0 +1,148 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/webvtt"
+	"github.com/streaming-service/internal/repository/meilisearch"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// TranscriptMatch is a caption cue matching a query, locating the spoken
+// phrase so playback can start there
+type TranscriptMatch struct {
+	Language string `json:"language"`
+	// Start and End are the cue's position in seconds
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// TranscriptResult is a media item whose transcript matches a query
+type TranscriptResult struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	Type     string  `json:"type"`
+	Duration float64 `json:"duration"`
+	// Score is the relevance of the best match between 0 and 1
+	Score float64 `json:"score"`
+	// Matches are the matching cues, most relevant first
+	Matches []TranscriptMatch `json:"matches"`
+}
+
+// TranscriptResults is a page of matching cues grouped by media item
+type TranscriptResults struct {
+	Items []*TranscriptResult `json:"items"`
+	// Total is an estimate of the number of matching cues
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// IndexTranscript replaces the indexed transcript of a media item in a
+// language with cues
+func (s *Service) IndexTranscript(ctx context.Context, media *domain.Media, language string, cues []webvtt.Cue) error {
+	filter := fmt.Sprintf("media_id = %q AND language = %q", media.ID, language)
+	if err := s.index.DeleteTranscripts(ctx, filter); err != nil {
+		return err
+	}
+
+	docs := make([]meilisearch.TranscriptDocument, 0, len(cues))
+	for i, cue := range cues {
+		text := strings.TrimSpace(cue.Text)
+		if text == "" {
+			continue
+		}
+		docs = append(docs, meilisearch.TranscriptDocument{
+			ID:       fmt.Sprintf("%s_%s_%d", media.ID, language, i),
+			MediaID:  media.ID,
+			Language: language,
+			TenantID: tenant.Of(media.TenantID),
+			Start:    cue.Start.Seconds(),
+			End:      cue.End.Seconds(),
+			Text:     strings.ReplaceAll(text, "\n", " "),
+		})
+	}
+
+	return s.index.IndexTranscript(ctx, docs)
+}
+
+// SearchTranscripts returns public, playable media whose transcripts
+// match the query, with the position of each matching cue. limit and
+// offset page through the matching cues, which are grouped by media item
+// in order of their best match.
+func (s *Service) SearchTranscripts(ctx context.Context, query string, limit, offset int) (*TranscriptResults, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || offset < 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	resp, err := s.index.SearchTranscripts(ctx, &meilisearch.SearchRequest{
+		Query:  query,
+		Filter: tenantFilter(tenant.FromContext(ctx)),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	byID := make(map[string]*TranscriptResult)
+	for _, hit := range resp.Hits {
+		result, ok := byID[hit.MediaID]
+		if !ok {
+			result = &TranscriptResult{ID: hit.MediaID, Score: hit.RankingScore}
+			byID[hit.MediaID] = result
+			ids = append(ids, hit.MediaID)
+		}
+		result.Matches = append(result.Matches, TranscriptMatch{
+			Language: hit.Language,
+			Start:    hit.Start,
+			End:      hit.End,
+			Text:     hit.Text,
+		})
+	}
+
+	// Visibility isn't indexed with cues, so it is checked on the records
+	items := make([]*TranscriptResult, 0, len(ids))
+	if len(ids) > 0 {
+		mediaList, err := s.dynamoClient.BatchGetMedia(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		searchable := make(map[string]*domain.Media, len(mediaList))
+		for _, media := range mediaList {
+			if media.IsListed() && media.Status == domain.MediaStatusCompleted {
+				searchable[media.ID] = media
+			}
+		}
+		for _, id := range ids {
+			media, ok := searchable[id]
+			if !ok {
+				continue
+			}
+			result := byID[id]
+			result.Title = media.Title
+			result.Type = string(media.Type)
+			result.Duration = media.Duration
+			items = append(items, result)
+		}
+	}
+
+	return &TranscriptResults{
+		Items:  items,
+		Total:  resp.EstimatedTotalHits,
+		Limit:  limit,
+		Offset: offset,
+	}, nil
+}
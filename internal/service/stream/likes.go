@@ -0,0 +1,94 @@
+package stream
+
+import (
+	"context"
+	"time"
+)
+
+// LikeStatus is whether a user likes a media item, and its like count
+type LikeStatus struct {
+	Liked     bool  `json:"liked"`
+	LikeCount int64 `json:"like_count"`
+}
+
+// Like records the user's like of a media item visible to them. Liking
+// it again changes nothing.
+func (s *Service) Like(ctx context.Context, mediaID, userID string) (*LikeStatus, error) {
+	media, err := s.getViewableMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := s.dynamoClient.PutLike(ctx, userID, media.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	count := media.LikeCount
+	if added {
+		count++
+	}
+	return &LikeStatus{Liked: true, LikeCount: count}, nil
+}
+
+// Unlike removes the user's like of a media item visible to them, if any
+func (s *Service) Unlike(ctx context.Context, mediaID, userID string) (*LikeStatus, error) {
+	media, err := s.getViewableMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := s.dynamoClient.DeleteLike(ctx, userID, media.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	count := media.LikeCount
+	if removed && count > 0 {
+		count--
+	}
+	return &LikeStatus{Liked: false, LikeCount: count}, nil
+}
+
+// GetLike reports whether the user likes a media item visible to them
+func (s *Service) GetLike(ctx context.Context, mediaID, userID string) (*LikeStatus, error) {
+	media, err := s.getViewableMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	liked, err := s.dynamoClient.HasLike(ctx, userID, media.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LikeStatus{Liked: liked, LikeCount: media.LikeCount}, nil
+}
+
+// ListFavorites lists a page of the media the user liked, most recently
+// liked first. Media deleted or hidden from the user since is left out,
+// so a page may hold fewer than limit items.
+func (s *Service) ListFavorites(ctx context.Context, userID string, limit int32, cursor string) ([]*MediaInfo, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	ids, next, err := s.dynamoClient.ListLikedMediaIDs(ctx, userID, limit, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mediaList, err := s.dynamoClient.BatchGetMedia(ctx, ids)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := make([]*MediaInfo, 0, len(mediaList))
+	for _, media := range mediaList {
+		if media.CanView(userID) {
+			result = append(result, s.summarize(media))
+		}
+	}
+
+	return result, next, nil
+}
@@ -0,0 +1,157 @@
+package stream
+
+import (
+	"context"
+	"maps"
+	"time"
+	"unicode/utf8"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// metadataPageSize is how many media are read at a time when exporting
+// a catalog
+const metadataPageSize = 100
+
+// MetadataRecord is the editable metadata of a media item and what
+// identifies it, as exported in bulk
+type MetadataRecord struct {
+	ID          string             `json:"id"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Visibility  domain.Visibility  `json:"visibility"`
+	Tags        map[string]string  `json:"tags"`
+	Type        domain.MediaType   `json:"type"`
+	Status      domain.MediaStatus `json:"status"`
+	Duration    float64            `json:"duration"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// MetadataUpdate changes the metadata of a media item. Nil fields are
+// left as they are; non-nil Tags replace all of the item's tags.
+type MetadataUpdate struct {
+	Title       *string
+	Description *string
+	Visibility  domain.Visibility
+	Tags        map[string]string
+}
+
+// ExportMetadata calls fn with the metadata of every media item the user
+// owns, newest first, stopping at the first error
+func (s *Service) ExportMetadata(ctx context.Context, userID string, fn func(*MetadataRecord) error) error {
+	cursor := ""
+	for {
+		mediaList, next, err := s.dynamoClient.ListMediaByUser(ctx, userID, nil, metadataPageSize, cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, media := range mediaList {
+			tags := media.Tags
+			if tags == nil {
+				tags = map[string]string{}
+			}
+			record := &MetadataRecord{
+				ID:          media.ID,
+				Title:       media.Title,
+				Description: media.Description,
+				Visibility:  media.GetVisibility(),
+				Tags:        tags,
+				Type:        media.Type,
+				Status:      media.Status,
+				Duration:    media.Duration,
+				CreatedAt:   media.CreatedAt,
+				UpdatedAt:   media.UpdatedAt,
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// UpdateMetadata applies an update to a media item owned by the user and
+// returns the fields it changed. With dryRun, the changes are worked out
+// and checked but not made.
+func (s *Service) UpdateMetadata(ctx context.Context, mediaID, userID string, update *MetadataUpdate, dryRun bool) ([]string, error) {
+	if update.Title != nil && utf8.RuneCountInString(*update.Title) > domain.MaxTitleLength {
+		return nil, domain.ErrInvalidInput
+	}
+	if update.Description != nil && utf8.RuneCountInString(*update.Description) > domain.MaxDescriptionLength {
+		return nil, domain.ErrInvalidInput
+	}
+	if update.Visibility != "" && !update.Visibility.IsValid() {
+		return nil, domain.ErrInvalidInput
+	}
+	if len(update.Tags) > domain.MaxTagsPerMedia {
+		return nil, domain.ErrInvalidInput
+	}
+	for key, value := range update.Tags {
+		if !domain.IsValidTag(key, value) {
+			return nil, domain.ErrInvalidInput
+		}
+	}
+
+	media, err := s.getOwnedMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	var title, description *string
+	if update.Title != nil && *update.Title != media.Title {
+		title = update.Title
+		changed = append(changed, "title")
+	}
+	if update.Description != nil && *update.Description != media.Description {
+		description = update.Description
+		changed = append(changed, "description")
+	}
+	setVisibility := update.Visibility != "" && update.Visibility != media.GetVisibility()
+	if setVisibility {
+		changed = append(changed, "visibility")
+	}
+	setTags := update.Tags != nil && !maps.Equal(update.Tags, media.Tags)
+	if setTags {
+		changed = append(changed, "tags")
+	}
+
+	if dryRun || len(changed) == 0 {
+		return changed, nil
+	}
+
+	if title != nil || description != nil {
+		if err := s.dynamoClient.UpdateMediaDetails(ctx, mediaID, title, description); err != nil {
+			return nil, err
+		}
+	}
+	if setVisibility {
+		if err := s.dynamoClient.UpdateMediaVisibility(ctx, mediaID, update.Visibility); err != nil {
+			return nil, err
+		}
+		// Before updating the tags, so new ones are indexed as listed or
+		// not by the new visibility
+		media.Visibility = update.Visibility
+		if err := s.relistTags(ctx, media); err != nil {
+			return nil, err
+		}
+	}
+	if setTags {
+		// updateTags tells search about the change
+		if err := s.updateTags(ctx, media, update.Tags); err != nil {
+			return nil, err
+		}
+	} else if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+
+	s.log.Info("media metadata updated", "media_id", mediaID, "fields", changed)
+
+	return changed, nil
+}
@@ -0,0 +1,171 @@
+package stream
+
+import (
+	"context"
+	"sort"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// MaxRelated caps the related media returned for a media item
+const MaxRelated = 50
+
+const (
+	// maxRelatedTags bounds the tags of a media item looked up in the
+	// tag index
+	maxRelatedTags = 10
+	// relatedCandidates is how many media each lookup contributes
+	relatedCandidates = 50
+
+	// Weights of the signals relating media; each signal scores a
+	// candidate between 0 and its weight
+	tagWeight        = 1.0
+	transcriptWeight = 1.0
+	coViewWeight     = 1.5
+)
+
+// Reasons a media item is related to another
+const (
+	RelatedByTags       = "tags"
+	RelatedByTranscript = "transcript"
+	RelatedByCoViews    = "coviews"
+)
+
+// RelatedMedia is a media item related to another
+type RelatedMedia struct {
+	*MediaInfo
+	// Score orders related media; it isn't comparable across media items
+	Score float64 `json:"score"`
+	// Reasons lists the signals relating the media
+	Reasons []string `json:"reasons"`
+}
+
+// relatedCandidate accumulates the signals relating a media item
+type relatedCandidate struct {
+	score   float64
+	reasons []string
+}
+
+// Related returns up to limit media similar to a media item visible to
+// the user, most related first. Media are related by shared tags,
+// transcripts sharing keywords (when search is set) and being viewed in
+// the same sessions. Only listed, playable media is returned, and the
+// user's own media.
+func (s *Service) Related(ctx context.Context, mediaID, userID string, limit int) ([]*RelatedMedia, error) {
+	if limit <= 0 || limit > MaxRelated {
+		limit = 10
+	}
+
+	media, err := s.getViewableMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]*relatedCandidate)
+	add := func(id, reason string, score float64) {
+		if id == media.ID || score <= 0 {
+			return
+		}
+		c, ok := candidates[id]
+		if !ok {
+			c = &relatedCandidate{}
+			candidates[id] = c
+		}
+		c.score += score
+		if n := len(c.reasons); n == 0 || c.reasons[n-1] != reason {
+			c.reasons = append(c.reasons, reason)
+		}
+	}
+
+	// Tags with a value are matched on the value, so every media item
+	// carrying a bare key like "genre" isn't related
+	tags := make([]string, 0, len(media.Tags))
+	for key, value := range media.Tags {
+		if value == "" {
+			tags = append(tags, key)
+		} else {
+			tags = append(tags, key+":"+value)
+		}
+	}
+	sort.Strings(tags)
+	if len(tags) > maxRelatedTags {
+		tags = tags[:maxRelatedTags]
+	}
+	for _, tag := range tags {
+		ids, _, err := s.dynamoClient.ListMediaIDsByTag(ctx, tag, relatedCandidates, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			add(id, RelatedByTags, tagWeight/float64(len(tags)))
+		}
+	}
+
+	// Transcript matches are a bonus; search being down shouldn't fail
+	// the request
+	if s.search != nil {
+		matches, err := s.search.RelatedByTranscript(ctx, media.ID, relatedCandidates)
+		if err != nil {
+			s.log.Error("failed to find media with related transcripts", "error", err, "media_id", media.ID)
+		}
+		for _, match := range matches {
+			add(match.ID, RelatedByTranscript, transcriptWeight*match.Score)
+		}
+	}
+
+	coViews, err := s.dynamoClient.GetCoViews(ctx, media.ID)
+	if err != nil {
+		return nil, err
+	}
+	var maxCoViews int64
+	for _, n := range coViews {
+		if n > maxCoViews {
+			maxCoViews = n
+		}
+	}
+	for id, n := range coViews {
+		add(id, RelatedByCoViews, coViewWeight*float64(n)/float64(maxCoViews))
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := candidates[ids[i]], candidates[ids[j]]
+		if a.score == b.score {
+			return ids[i] < ids[j]
+		}
+		return a.score > b.score
+	})
+
+	// Records are read in ranked batches until enough are recommendable
+	result := make([]*RelatedMedia, 0, limit)
+	for start := 0; start < len(ids) && len(result) < limit; start += relatedCandidates {
+		end := min(start+relatedCandidates, len(ids))
+		mediaList, err := s.dynamoClient.BatchGetMedia(ctx, ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[string]*domain.Media, len(mediaList))
+		for _, m := range mediaList {
+			byID[m.ID] = m
+		}
+		for _, id := range ids[start:end] {
+			m, ok := byID[id]
+			if !ok || !m.IsProcessed() || (!m.IsListed() && m.UserID != userID) {
+				continue
+			}
+			result = append(result, &RelatedMedia{
+				MediaInfo: s.summarize(m),
+				Score:     candidates[id].score,
+				Reasons:   candidates[id].reasons,
+			})
+			if len(result) == limit {
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -3,24 +3,64 @@ package stream
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/streaming-service/internal/domain"
-	"github.com/streaming-service/internal/repository/dynamodb"
-	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/events"
+	"github.com/streaming-service/internal/repository"
+	"github.com/streaming-service/internal/repository/cloudfront"
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/internal/service/search"
 	"github.com/streaming-service/pkg/logger"
 )
 
 // Service handles streaming operations
 type Service struct {
-	s3Client         *s3.Client
-	dynamoClient     *dynamodb.Client
+	s3Client         repository.ObjectStore
+	dynamoClient     Store
 	cloudFrontDomain string
+	cdn              *cloudfront.Client
+	conditioner      ManifestConditioner
+	licenses         map[domain.DRMSystem]string
+	search           *search.Service
+	quotas           *quotas.Service
+	events           *events.Dispatcher
+	outbox           bool
 	log              *logger.Logger
 }
 
+// Store is the metadata the streaming service reads and writes
+type Store interface {
+	repository.MediaStore
+	repository.TagStore
+	repository.CoViewReader
+	repository.LikeStore
+	repository.LiveStreamReader
+}
+
+// PlaybackSession describes the viewer requesting playback
+type PlaybackSession struct {
+	SessionID string
+	UserID    string
+	UserAgent string
+	ClientIP  string
+	// Params carries client-supplied targeting parameters
+	Params map[string]string
+	// Embedded is set once an embed token for the media was verified,
+	// granting playback whatever its visibility, unless moderation holds
+	// it
+	Embedded bool
+}
+
+// ManifestConditioner can replace a raw manifest URL with a per-session one,
+// e.g. a stitched manifest from a server-side ad insertion provider
+type ManifestConditioner interface {
+	Condition(ctx context.Context, media *domain.Media, manifestURL string, session *PlaybackSession) (string, error)
+}
+
 // NewService creates a new streaming service
-func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, cloudFrontDomain string, log *logger.Logger) *Service {
+func NewService(s3Client repository.ObjectStore, dynamoClient Store, cloudFrontDomain string, log *logger.Logger) *Service {
 	return &Service{
 		s3Client:         s3Client,
 		dynamoClient:     dynamoClient,
@@ -29,17 +69,107 @@ func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, cloudFrontDo
 	}
 }
 
+// SetCDN sets the CloudFront client used for cache invalidation
+func (s *Service) SetCDN(cdn *cloudfront.Client) {
+	s.cdn = cdn
+}
+
+// SetSearch keeps the search index in step with visibility changes and
+// deletions
+func (s *Service) SetSearch(svc *search.Service) {
+	s.search = svc
+}
+
+// SetEvents publishes media.deleted for each deletion
+func (s *Service) SetEvents(d *events.Dispatcher) {
+	s.events = d
+}
+
+// SetOutbox writes media.deleted to the outbox in the same transaction as
+// each deletion, for the worker's relay to deliver, instead of publishing
+// it afterwards
+func (s *Service) SetOutbox(enabled bool) {
+	s.outbox = enabled
+}
+
+// SetQuotas returns the storage of deleted media to the tenant's quota
+func (s *Service) SetQuotas(svc *quotas.Service) {
+	s.quotas = svc
+}
+
+// SetDRMLicenses sets the license server URL of each DRM system, given to
+// players of media whose segments are protected
+func (s *Service) SetDRMLicenses(licenses map[string]string) {
+	s.licenses = make(map[domain.DRMSystem]string, len(licenses))
+	for system, url := range licenses {
+		s.licenses[domain.DRMSystem(system)] = url
+	}
+}
+
+// SetManifestConditioner sets the hook applied to playback manifest URLs
+func (s *Service) SetManifestConditioner(c ManifestConditioner) {
+	s.conditioner = c
+}
+
 // MediaInfo contains media information for playback
 type MediaInfo struct {
-	ID          string             `json:"id"`
-	Title       string             `json:"title"`
-	Description string             `json:"description"`
-	Type        domain.MediaType   `json:"type"`
-	Status      domain.MediaStatus `json:"status"`
-	Duration    float64            `json:"duration"`
-	Renditions  []RenditionInfo    `json:"renditions,omitempty"`
-	PlaybackURL string             `json:"playback_url,omitempty"`
-	CreatedAt   time.Time          `json:"created_at"`
+	ID            string             `json:"id"`
+	Title         string             `json:"title"`
+	Description   string             `json:"description"`
+	Type          domain.MediaType   `json:"type"`
+	Status        domain.MediaStatus `json:"status"`
+	Visibility    domain.Visibility  `json:"visibility"`
+	Duration      float64            `json:"duration"`
+	LikeCount     int64              `json:"like_count"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+	ChannelID     string             `json:"channel_id,omitempty"`
+	Episode       *domain.Episode    `json:"episode,omitempty"`
+	Renditions    []RenditionInfo    `json:"renditions,omitempty"`
+	PlaybackURL   string             `json:"playback_url,omitempty"`
+	DASHURL       string             `json:"dash_url,omitempty"`
+	StoryboardURL string             `json:"storyboard_url,omitempty"`
+	ExpiresAt     *time.Time         `json:"expires_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	// CoverArt maps each cover art size in pixels to its URL
+	CoverArt map[string]string `json:"cover_art,omitempty"`
+	// Processing details the worker's progress; only the full form of a
+	// media item carries it
+	Processing *domain.ProcessingProgress `json:"processing,omitempty"`
+	// Retries are the latest retries of failed processing; only the full
+	// form carries them
+	Retries []domain.ProcessingRetry `json:"retries,omitempty"`
+	// Metadata is what probing the source found, for debugging and
+	// client playback heuristics; only the full form carries it
+	Metadata *domain.SourceMetadata `json:"metadata,omitempty"`
+	// Moderation is the content policy scan result and any review; only
+	// the full form carries it
+	Moderation *domain.Moderation `json:"moderation,omitempty"`
+	// Copyright lists the copyrighted recordings the audio matched; only
+	// the full form carries it
+	Copyright *domain.CopyrightCheck `json:"copyright,omitempty"`
+	// Subtitles are the subtitle tracks, which the master playlist also
+	// lists; only the full form carries them
+	Subtitles []SubtitleInfo `json:"subtitles,omitempty"`
+	// Enrichment is the summary and chapters generated from the
+	// transcript; only the full form carries it
+	Enrichment *EnrichmentInfo `json:"enrichment,omitempty"`
+	// Captioning is the state of the automatic captions requested on
+	// upload; only the full form carries it
+	Captioning *domain.Captioning `json:"captioning,omitempty"`
+}
+
+// EnrichmentInfo is a media item's summary and chapters with the URL of
+// its WebVTT chapters track
+type EnrichmentInfo struct {
+	domain.Enrichment
+	ChaptersURL string `json:"chapters_url,omitempty"`
+}
+
+// SubtitleInfo is a subtitle track with the URL of its WebVTT file
+type SubtitleInfo struct {
+	domain.SubtitleTrack
+	URL string `json:"url"`
 }
 
 // RenditionInfo contains rendition details
@@ -51,27 +181,79 @@ type RenditionInfo struct {
 	StreamURL string `json:"stream_url"`
 }
 
-// GetMedia retrieves media information
-func (s *Service) GetMedia(ctx context.Context, mediaID string) (*MediaInfo, error) {
-	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+// GetMedia retrieves media information visible to the user
+func (s *Service) GetMedia(ctx context.Context, mediaID, userID string) (*MediaInfo, error) {
+	media, err := s.getViewableMedia(ctx, mediaID, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	info := &MediaInfo{
-		ID:          media.ID,
-		Title:       media.Title,
-		Description: media.Description,
-		Type:        media.Type,
-		Status:      media.Status,
-		Duration:    media.Duration,
-		CreatedAt:   media.CreatedAt,
+	return s.describe(media), nil
+}
+
+// ProgressInfo is a media item's status with its progress through
+// processing
+type ProgressInfo struct {
+	MediaID    string                     `json:"media_id"`
+	Status     domain.MediaStatus         `json:"status"`
+	Processing *domain.ProcessingProgress `json:"processing,omitempty"`
+}
+
+// GetProgress returns how far processing a media item has got
+func (s *Service) GetProgress(ctx context.Context, mediaID, userID string) (*ProgressInfo, error) {
+	media, err := s.getViewableMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add playback URL if processed
-	if media.IsProcessed() {
-		info.PlaybackURL = s.buildPlaybackURL(media.GetMasterPlaylistKey())
+	return &ProgressInfo{
+		MediaID:    media.ID,
+		Status:     media.Status,
+		Processing: media.Processing,
+	}, nil
+}
+
+// GetMediaBatch returns the media visible to the user among mediaIDs, in
+// the order given. Missing and hidden items are left out.
+func (s *Service) GetMediaBatch(ctx context.Context, mediaIDs []string, userID string) ([]*MediaInfo, error) {
+	mediaList, err := s.dynamoClient.BatchGetMedia(ctx, mediaIDs)
+	if err != nil {
+		return nil, err
+	}
 
+	result := make([]*MediaInfo, 0, len(mediaList))
+	for _, media := range mediaList {
+		if media.CanView(userID) {
+			result = append(result, s.describe(media))
+		}
+	}
+
+	return result, nil
+}
+
+// describe builds the full form of a media item, with renditions
+func (s *Service) describe(media *domain.Media) *MediaInfo {
+	info := s.summarize(media)
+	info.Processing = media.Processing
+	info.Retries = media.Retries
+	info.Metadata = media.SourceMetadata
+	info.Moderation = media.Moderation
+	info.Copyright = media.Copyright
+	info.Captioning = media.Captioning
+	for _, track := range media.Subtitles {
+		info.Subtitles = append(info.Subtitles, SubtitleInfo{
+			SubtitleTrack: track,
+			URL:           s.buildPlaybackURL(media.GetSubtitleKey(track.ID)),
+		})
+	}
+	if media.Enrichment != nil {
+		info.Enrichment = &EnrichmentInfo{Enrichment: *media.Enrichment}
+		if len(media.Enrichment.Chapters) > 0 {
+			info.Enrichment.ChaptersURL = s.buildPlaybackURL(media.GetChaptersKey())
+		}
+	}
+
+	if media.IsProcessed() {
 		for _, r := range media.Renditions {
 			info.Renditions = append(info.Renditions, RenditionInfo{
 				Name:      r.Name,
@@ -83,50 +265,325 @@ func (s *Service) GetMedia(ctx context.Context, mediaID string) (*MediaInfo, err
 		}
 	}
 
-	return info, nil
+	return info
 }
 
-// GetPlaybackURL returns the playback URL for a media item
-func (s *Service) GetPlaybackURL(ctx context.Context, mediaID string) (string, error) {
-	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+// Playback holds the URLs a media item plays from
+type Playback struct {
+	URL string
+	// DASHURL is the DASH manifest of media packaged as CMAF, or empty
+	DASHURL string
+	// StoryboardURL is the WebVTT file of thumbnails for scrubbing, or
+	// empty
+	StoryboardURL string
+	// DRM is set when the segments are protected with Common Encryption
+	DRM *DRMPlayback
+}
+
+// DRMPlayback is what players need to license a media item's content keys
+type DRMPlayback struct {
+	KeyIDs []string `json:"key_ids"`
+	// Licenses maps each DRM system to its license server URL
+	Licenses map[domain.DRMSystem]string `json:"licenses"`
+}
+
+// GetPlayback returns the playback URLs for a media item
+func (s *Service) GetPlayback(ctx context.Context, mediaID string, session *PlaybackSession) (*Playback, error) {
+	var media *domain.Media
+	var err error
+	if session != nil && session.Embedded {
+		// Owners embed private media too, so a token outlives a change of
+		// visibility, but not moderation holding or rejecting the media
+		media, err = s.dynamoClient.GetMedia(ctx, mediaID)
+		if err == nil && media.IsHeld() {
+			err = domain.ErrMediaNotFound
+		}
+	} else {
+		var userID string
+		if session != nil {
+			userID = session.UserID
+		}
+		media, err = s.getViewableMedia(ctx, mediaID, userID)
+	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if !media.IsProcessed() {
-		return "", fmt.Errorf("media not yet processed")
+		return nil, fmt.Errorf("media not yet processed")
 	}
 
-	return s.buildPlaybackURL(media.GetMasterPlaylistKey()), nil
+	return &Playback{
+		URL:           s.playbackURL(ctx, media, session),
+		DASHURL:       s.dashURL(media),
+		StoryboardURL: s.storyboardURL(media),
+		DRM:           s.drmPlayback(media),
+	}, nil
 }
 
-// ListMedia lists media for a user
-func (s *Service) ListMedia(ctx context.Context, userID string, limit int32) ([]*MediaInfo, error) {
-	mediaList, err := s.dynamoClient.ListMediaByUser(ctx, userID, limit)
+// PlaybackItem is one entry of a multi-item playback response
+type PlaybackItem struct {
+	MediaID     string           `json:"media_id"`
+	Title       string           `json:"title"`
+	Type        domain.MediaType `json:"type"`
+	Duration    float64          `json:"duration"`
+	PlaybackURL string           `json:"playback_url"`
+	DASHURL     string           `json:"dash_url,omitempty"`
+	DRM         *DRMPlayback     `json:"drm,omitempty"`
+	// KeyToken is set by callers when content keys require a token
+	KeyToken string `json:"key_token,omitempty"`
+}
+
+// GetPlaybackItems resolves playback URLs for several media items in the
+// order given. Items the viewer may not see, and items not yet processed,
+// are left out.
+func (s *Service) GetPlaybackItems(ctx context.Context, mediaIDs []string, session *PlaybackSession) ([]*PlaybackItem, error) {
+	var userID string
+	if session != nil {
+		userID = session.UserID
+	}
+
+	mediaList, err := s.dynamoClient.BatchGetMedia(ctx, mediaIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]*MediaInfo, 0, len(mediaList))
+	items := make([]*PlaybackItem, 0, len(mediaList))
 	for _, media := range mediaList {
-		info := &MediaInfo{
-			ID:          media.ID,
+		if !media.CanView(userID) || !media.IsProcessed() {
+			continue
+		}
+		items = append(items, &PlaybackItem{
+			MediaID:     media.ID,
 			Title:       media.Title,
-			Description: media.Description,
 			Type:        media.Type,
-			Status:      media.Status,
 			Duration:    media.Duration,
-			CreatedAt:   media.CreatedAt,
+			PlaybackURL: s.playbackURL(ctx, media, session),
+			DASHURL:     s.dashURL(media),
+			DRM:         s.drmPlayback(media),
+		})
+	}
+
+	return items, nil
+}
+
+// playbackURL returns the master playlist URL of a processed media item,
+// conditioned for the session when a conditioner is set
+func (s *Service) playbackURL(ctx context.Context, media *domain.Media, session *PlaybackSession) string {
+	url := s.buildPlaybackURL(media.GetMasterPlaylistKey())
+
+	if s.conditioner != nil && url != "" && session != nil {
+		conditioned, err := s.conditioner.Condition(ctx, media, url, session)
+		if err != nil {
+			// Fall back to the raw manifest rather than blocking playback
+			s.log.Error("manifest conditioning failed", "error", err, "media_id", media.ID)
+			return url
 		}
+		return conditioned
+	}
 
-		if media.IsProcessed() {
-			info.PlaybackURL = s.buildPlaybackURL(media.GetMasterPlaylistKey())
+	return url
+}
+
+// dashURL returns the DASH manifest URL of a processed media item packaged
+// as CMAF, or empty. Ad breaks are signalled in the manifest itself, so it
+// isn't conditioned.
+func (s *Service) dashURL(media *domain.Media) string {
+	if media.DASHKey == "" {
+		return ""
+	}
+	return s.buildPlaybackURL(media.DASHKey)
+}
+
+// drmPlayback returns the license servers of a processed media item's
+// protected segments, or nil
+func (s *Service) drmPlayback(media *domain.Media) *DRMPlayback {
+	if media.DRM == nil {
+		return nil
+	}
+	playback := &DRMPlayback{
+		KeyIDs:   media.DRM.KeyIDs,
+		Licenses: make(map[domain.DRMSystem]string, len(media.DRM.Systems)),
+	}
+	for _, system := range media.DRM.Systems {
+		if url := s.licenses[system]; url != "" {
+			playback.Licenses[system] = url
 		}
+	}
+	return playback
+}
 
-		result = append(result, info)
+// storyboardURL returns the URL of a processed media item's thumbnails
+// WebVTT file, or empty
+func (s *Service) storyboardURL(media *domain.Media) string {
+	if media.StoryboardKey == "" {
+		return ""
 	}
+	return s.buildPlaybackURL(media.StoryboardKey)
+}
 
-	return result, nil
+// GetLivePlaybackURL returns the playback URL of a live stream that is
+// currently broadcasting
+func (s *Service) GetLivePlaybackURL(ctx context.Context, streamID string) (string, error) {
+	stream, err := s.dynamoClient.GetLiveStream(ctx, streamID)
+	if err != nil {
+		return "", err
+	}
+
+	if !stream.IsLive() {
+		return "", domain.ErrStreamNotLive
+	}
+
+	return s.buildPlaybackURL(stream.GetMasterPlaylistKey()), nil
+}
+
+// GetLivePreviewURL returns the URL of the refreshing preview image of a
+// live stream that is currently broadcasting
+func (s *Service) GetLivePreviewURL(ctx context.Context, streamID string) (string, error) {
+	stream, err := s.dynamoClient.GetLiveStream(ctx, streamID)
+	if err != nil {
+		return "", err
+	}
+
+	if !stream.IsLive() {
+		return "", domain.ErrStreamNotLive
+	}
+
+	return s.buildPlaybackURL(stream.GetPreviewKey()), nil
+}
+
+// ListMedia lists a page of media for a user matching filter, returning
+// the cursor of the next page
+func (s *Service) ListMedia(ctx context.Context, userID string, filter *domain.MediaFilter, limit int32, cursor string) ([]*MediaInfo, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	if filter != nil {
+		if (filter.Status != "" && !filter.Status.IsValid()) || (filter.Type != "" && !filter.Type.IsValid()) {
+			return nil, "", domain.ErrInvalidInput
+		}
+		if !filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero() && filter.CreatedAfter.After(filter.CreatedBefore) {
+			return nil, "", domain.ErrInvalidInput
+		}
+	}
+
+	mediaList, next, err := s.dynamoClient.ListMediaByUser(ctx, userID, filter, limit, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := make([]*MediaInfo, 0, len(mediaList))
+	for _, media := range mediaList {
+		result = append(result, s.summarize(media))
+	}
+
+	return result, next, nil
+}
+
+// ListChannelMedia lists a page of media published to a channel, newest
+// first. Unless includeAll is set, only processed public media not held
+// by moderation is listed.
+func (s *Service) ListChannelMedia(ctx context.Context, channelID string, includeAll bool, limit int32, cursor string) ([]*MediaInfo, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	mediaList, next, err := s.dynamoClient.ListMediaByChannel(ctx, channelID, !includeAll, limit, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := make([]*MediaInfo, 0, len(mediaList))
+	for _, media := range mediaList {
+		if !includeAll && media.IsHeld() {
+			continue
+		}
+		result = append(result, s.summarize(media))
+	}
+
+	return result, next, nil
+}
+
+// summarize builds the listing form of a media item, without renditions
+func (s *Service) summarize(media *domain.Media) *MediaInfo {
+	info := &MediaInfo{
+		ID:          media.ID,
+		Title:       media.Title,
+		Description: media.Description,
+		Type:        media.Type,
+		Status:      media.Status,
+		Visibility:  media.GetVisibility(),
+		Duration:    media.Duration,
+		LikeCount:   media.LikeCount,
+		Tags:        media.Tags,
+		ChannelID:   media.ChannelID,
+		Episode:     media.Episode,
+		ExpiresAt:   media.ExpiresAt,
+		CreatedAt:   media.CreatedAt,
+		UpdatedAt:   media.UpdatedAt,
+	}
+
+	if media.IsProcessed() {
+		info.PlaybackURL = s.buildPlaybackURL(media.GetMasterPlaylistKey())
+		info.DASHURL = s.dashURL(media)
+		info.StoryboardURL = s.storyboardURL(media)
+	}
+	if media.CoverArtKey != "" && s.cloudFrontDomain != "" {
+		info.CoverArt = make(map[string]string, len(domain.CoverArtSizes))
+		for _, size := range domain.CoverArtSizes {
+			info.CoverArt[strconv.Itoa(size)] = s.buildPlaybackURL(media.GetCoverArtKey(size))
+		}
+	}
+
+	return info
+}
+
+// SetVisibility changes the visibility of a media item owned by the user
+func (s *Service) SetVisibility(ctx context.Context, mediaID, userID string, visibility domain.Visibility) error {
+	if !visibility.IsValid() {
+		return domain.ErrInvalidInput
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+
+	if media.UserID != userID {
+		return domain.ErrUnauthorized
+	}
+
+	if err := s.dynamoClient.UpdateMediaVisibility(ctx, mediaID, visibility); err != nil {
+		return err
+	}
+	media.Visibility = visibility
+	if err := s.relistTags(ctx, media); err != nil {
+		return err
+	}
+
+	s.log.Info("media visibility changed", "media_id", mediaID, "visibility", visibility)
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+
+	return nil
+}
+
+// getViewableMedia loads a media item, hiding private media from anyone
+// but its owner as if it did not exist
+func (s *Service) getViewableMedia(ctx context.Context, mediaID, userID string) (*domain.Media, error) {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !media.CanView(userID) {
+		return nil, domain.ErrMediaNotFound
+	}
+
+	return media, nil
 }
 
 // DeleteMedia deletes a media item
@@ -140,12 +597,31 @@ func (s *Service) DeleteMedia(ctx context.Context, mediaID, userID string) error
 	if media.UserID != userID {
 		return domain.ErrUnauthorized
 	}
+	if media.LegalHold != nil {
+		return domain.ErrLegalHold
+	}
 
 	// Delete from DynamoDB
-	if err := s.dynamoClient.DeleteMedia(ctx, mediaID); err != nil {
+	deleted := &domain.Event{Type: domain.EventMediaDeleted, MediaID: mediaID, UserID: media.UserID}
+	var outboxed []*domain.Event
+	if s.outbox {
+		outboxed = append(outboxed, deleted)
+	}
+	if err := s.dynamoClient.DeleteMedia(ctx, mediaID, outboxed...); err != nil {
+		// Held since it was read
+		if err == domain.ErrLegalHold {
+			return err
+		}
 		return fmt.Errorf("failed to delete media record: %w", err)
 	}
 
+	// Drop it from the tag index
+	if len(media.Tags) > 0 {
+		if err := s.dynamoClient.DeleteTagEntries(ctx, mediaID, domain.TagIndexKeys(media.Tags)); err != nil {
+			s.log.Error("failed to delete tag entries", "error", err, "media_id", mediaID)
+		}
+	}
+
 	// Delete source file from S3
 	if media.SourceKey != "" {
 		if err := s.s3Client.Delete(ctx, media.SourceBucket, media.SourceKey); err != nil {
@@ -153,13 +629,19 @@ func (s *Service) DeleteMedia(ctx context.Context, mediaID, userID string) error
 		}
 	}
 
-	// Delete processed files
-	processedBucket := s.s3Client.GetProcessedBucket()
-	objects, err := s.s3Client.ListObjects(ctx, processedBucket, mediaID+"/")
-	if err == nil {
-		for _, obj := range objects {
-			_ = s.s3Client.Delete(ctx, processedBucket, *obj.Key)
-		}
+	s.deleteProcessedFiles(ctx, media)
+	s.invalidateCDN(ctx, media)
+
+	if s.quotas != nil {
+		s.quotas.AddStorage(ctx, -(media.SourceSize + media.OutputSize))
+	}
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+
+	if s.events != nil && !s.outbox {
+		s.events.Publish(ctx, deleted)
 	}
 
 	s.log.Info("media deleted", "media_id", mediaID)
@@ -167,10 +649,112 @@ func (s *Service) DeleteMedia(ctx context.Context, mediaID, userID string) error
 	return nil
 }
 
+// ArchiveMedia deletes the processed files of a media item but keeps its
+// record and source, so it can be processed again. The retention job
+// archives expired media this way.
+func (s *Service) ArchiveMedia(ctx context.Context, mediaID string) error {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	if media.LegalHold != nil {
+		return domain.ErrLegalHold
+	}
+
+	if err := s.dynamoClient.ArchiveMedia(ctx, mediaID); err != nil {
+		return err
+	}
+
+	s.deleteProcessedFiles(ctx, media)
+	s.invalidateCDN(ctx, media)
+
+	if s.quotas != nil {
+		s.quotas.AddStorage(ctx, -media.OutputSize)
+	}
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+
+	s.log.Info("media archived", "media_id", mediaID)
+
+	return nil
+}
+
+// GetLegalHold returns the legal hold on a media item, or nil if it has
+// none
+func (s *Service) GetLegalHold(ctx context.Context, mediaID string) (*domain.LegalHold, error) {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	return media.LegalHold, nil
+}
+
+// ApplyLegalHold places a media item under legal hold, keeping it from
+// being deleted or expired whoever owns it. Applying a hold again
+// replaces its reason.
+func (s *Service) ApplyLegalHold(ctx context.Context, mediaID, actorID, reason string) (*domain.LegalHold, error) {
+	if reason == "" {
+		return nil, domain.ErrInvalidInput
+	}
+
+	hold := &domain.LegalHold{
+		Reason:    reason,
+		AppliedBy: actorID,
+		AppliedAt: time.Now().UTC(),
+	}
+	if err := s.dynamoClient.SetMediaLegalHold(ctx, mediaID, hold); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("legal hold applied", "media_id", mediaID, "actor_id", actorID)
+
+	return hold, nil
+}
+
+// ReleaseLegalHold releases the legal hold on a media item
+func (s *Service) ReleaseLegalHold(ctx context.Context, mediaID, actorID string) error {
+	if err := s.dynamoClient.SetMediaLegalHold(ctx, mediaID, nil); err != nil {
+		return err
+	}
+
+	s.log.Info("legal hold released", "media_id", mediaID, "actor_id", actorID)
+
+	return nil
+}
+
+// deleteProcessedFiles deletes the renditions, manifests and cover art of
+// a media item
+func (s *Service) deleteProcessedFiles(ctx context.Context, media *domain.Media) {
+	processedBucket := s.s3Client.GetProcessedBucket()
+	objects, err := s.s3Client.ListObjects(ctx, processedBucket, media.GetOutputPrefix())
+	if err != nil {
+		s.log.Error("failed to list processed files", "error", err, "media_id", media.ID)
+		return
+	}
+	for _, obj := range objects {
+		_ = s.s3Client.Delete(ctx, processedBucket, *obj.Key)
+	}
+}
+
 // buildPlaybackURL constructs the CloudFront playback URL
 func (s *Service) buildPlaybackURL(key string) string {
 	if s.cloudFrontDomain == "" {
 		return "" // No CDN configured
 	}
-	return fmt.Sprintf("https://%s/%s", s.cloudFrontDomain, key)
+	return cloudfront.URL(s.cloudFrontDomain, key)
+}
+
+// invalidateCDN drops cached manifests and segments for a media item
+func (s *Service) invalidateCDN(ctx context.Context, media *domain.Media) {
+	if s.cdn == nil {
+		return
+	}
+	id, err := s.cdn.InvalidateMedia(ctx, media.GetOutputPrefix())
+	if err != nil {
+		s.log.Error("failed to invalidate CDN cache", "error", err, "media_id", media.ID)
+		return
+	}
+	s.log.Info("CDN invalidation created", "media_id", media.ID, "invalidation_id", id)
 }
@@ -0,0 +1,113 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/mock/gomock"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/mocks"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// mockStore combines the repository mocks into the service's Store
+type mockStore struct {
+	*mocks.MockMediaStore
+	*mocks.MockTagStore
+	*mocks.MockCoViewReader
+	*mocks.MockLikeStore
+	*mocks.MockLiveStreamReader
+}
+
+func TestDeleteMedia(t *testing.T) {
+	errStore := errors.New("store unavailable")
+
+	tests := []struct {
+		name   string
+		userID string
+		// media is returned by the store, or getErr in its place
+		media  *domain.Media
+		getErr error
+		// deleteErr is returned by the store deleting the record
+		deleteErr error
+		wantErr   error
+		// wantFiles reports whether the media's files are deleted
+		wantFiles bool
+	}{
+		{
+			name:      "owner deletes the record, tags and files",
+			userID:    "user-1",
+			media:     &domain.Media{ID: "media-1", UserID: "user-1", SourceBucket: "raw-bucket", SourceKey: "raw/media-1.mp4", Tags: map[string]string{"genre": "drama"}},
+			wantFiles: true,
+		},
+		{
+			name:    "refuses another user",
+			userID:  "user-2",
+			media:   &domain.Media{ID: "media-1", UserID: "user-1"},
+			wantErr: domain.ErrUnauthorized,
+		},
+		{
+			name:    "passes on missing media",
+			userID:  "user-1",
+			getErr:  domain.ErrMediaNotFound,
+			wantErr: domain.ErrMediaNotFound,
+		},
+		{
+			name:    "refuses media under legal hold",
+			userID:  "user-1",
+			media:   &domain.Media{ID: "media-1", UserID: "user-1", LegalHold: &domain.LegalHold{Reason: "litigation"}},
+			wantErr: domain.ErrLegalHold,
+		},
+		{
+			name:      "keeps files of media held since it was read",
+			userID:    "user-1",
+			media:     &domain.Media{ID: "media-1", UserID: "user-1", SourceBucket: "raw-bucket", SourceKey: "raw/media-1.mp4"},
+			deleteErr: domain.ErrLegalHold,
+			wantErr:   domain.ErrLegalHold,
+		},
+		{
+			name:      "keeps files when the record can't be deleted",
+			userID:    "user-1",
+			media:     &domain.Media{ID: "media-1", UserID: "user-1", SourceBucket: "raw-bucket", SourceKey: "raw/media-1.mp4"},
+			deleteErr: errStore,
+			wantErr:   errStore,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			objects := mocks.NewMockObjectStore(ctrl)
+			store := &mockStore{
+				MockMediaStore:       mocks.NewMockMediaStore(ctrl),
+				MockTagStore:         mocks.NewMockTagStore(ctrl),
+				MockCoViewReader:     mocks.NewMockCoViewReader(ctrl),
+				MockLikeStore:        mocks.NewMockLikeStore(ctrl),
+				MockLiveStreamReader: mocks.NewMockLiveStreamReader(ctrl),
+			}
+			svc := NewService(objects, store, "", logger.New("error", "json"))
+
+			store.MockMediaStore.EXPECT().GetMedia(gomock.Any(), "media-1").Return(tt.media, tt.getErr)
+			if tt.wantFiles || tt.deleteErr != nil {
+				store.MockMediaStore.EXPECT().DeleteMedia(gomock.Any(), "media-1").Return(tt.deleteErr)
+			}
+			if tt.wantFiles {
+				store.MockTagStore.EXPECT().DeleteTagEntries(gomock.Any(), "media-1", domain.TagIndexKeys(tt.media.Tags)).Return(nil)
+				objects.EXPECT().GetProcessedBucket().Return("processed-bucket").AnyTimes()
+				objects.EXPECT().ListObjects(gomock.Any(), "processed-bucket", "media-1/").
+					Return([]types.Object{{Key: aws.String("media-1/master.m3u8")}}, nil)
+				objects.EXPECT().Delete(gomock.Any(), "raw-bucket", "raw/media-1.mp4").Return(nil)
+				objects.EXPECT().Delete(gomock.Any(), "processed-bucket", "media-1/master.m3u8").Return(nil)
+			}
+
+			err := svc.DeleteMedia(context.Background(), "media-1", tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteMedia() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
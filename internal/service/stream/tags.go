@@ -0,0 +1,214 @@
+package stream
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// TagCount is a tag and how many media items carry it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// AddTags merges tags into a media item owned by the user, replacing the
+// values of existing keys, and returns the resulting tags
+func (s *Service) AddTags(ctx context.Context, mediaID, userID string, tags map[string]string) (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	for key, value := range tags {
+		if !domain.IsValidTag(key, value) {
+			return nil, domain.ErrInvalidInput
+		}
+	}
+
+	media, err := s.getOwnedMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := make(map[string]string, len(media.Tags)+len(tags))
+	for key, value := range media.Tags {
+		updated[key] = value
+	}
+	for key, value := range tags {
+		updated[key] = value
+	}
+	if len(updated) > domain.MaxTagsPerMedia {
+		return nil, domain.ErrInvalidInput
+	}
+
+	if err := s.updateTags(ctx, media, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// RemoveTag removes a tag by key from a media item owned by the user and
+// returns the remaining tags
+func (s *Service) RemoveTag(ctx context.Context, mediaID, userID, key string) (map[string]string, error) {
+	media, err := s.getOwnedMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := media.Tags[key]; !ok {
+		return media.Tags, nil
+	}
+
+	updated := make(map[string]string, len(media.Tags))
+	for k, v := range media.Tags {
+		if k != key {
+			updated[k] = v
+		}
+	}
+
+	if err := s.updateTags(ctx, media, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// ListMediaByTag lists a page of media carrying a tag, given as a key or
+// "key:value". Media left out of public listings is only included for
+// its owner, so a page may hold fewer than limit items.
+func (s *Service) ListMediaByTag(ctx context.Context, tag, userID string, limit int32, cursor string) ([]*MediaInfo, string, error) {
+	if tag == "" {
+		return nil, "", domain.ErrInvalidInput
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	ids, next, err := s.dynamoClient.ListMediaIDsByTag(ctx, tag, limit, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mediaList, err := s.dynamoClient.BatchGetMedia(ctx, ids)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := make([]*MediaInfo, 0, len(mediaList))
+	for _, media := range mediaList {
+		if !media.IsListed() && media.UserID != userID {
+			continue
+		}
+		result = append(result, s.summarize(media))
+	}
+
+	return result, next, nil
+}
+
+// PopularTags returns up to limit of the tags on the most media, most
+// first. Given a key, it returns that key's most used "key:value" tags;
+// otherwise both tag keys and "key:value" tags are ranked together. Only
+// listed media is counted, so the tags of private, unlisted and held
+// media aren't revealed.
+func (s *Service) PopularTags(ctx context.Context, key string, limit int) ([]TagCount, error) {
+	if strings.Contains(key, ":") {
+		return nil, domain.ErrInvalidInput
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	counts, err := s.dynamoClient.ListTagCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]TagCount, 0, len(counts))
+	for tag, n := range counts {
+		if n <= 0 {
+			continue
+		}
+		if key != "" && !strings.HasPrefix(tag, key+":") {
+			continue
+		}
+		tags = append(tags, TagCount{Tag: tag, Count: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count == tags[j].Count {
+			return tags[i].Tag < tags[j].Tag
+		}
+		return tags[i].Count > tags[j].Count
+	})
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+// relistTags counts or uncounts a media item under its tags after its
+// visibility changed, as popular tags only count listed media
+func (s *Service) relistTags(ctx context.Context, media *domain.Media) error {
+	if len(media.Tags) == 0 {
+		return nil
+	}
+	return s.dynamoClient.SetTagEntriesListed(ctx, media.ID, domain.TagIndexKeys(media.Tags), media.IsListed())
+}
+
+// getOwnedMedia loads a media item, checking it belongs to the user
+func (s *Service) getOwnedMedia(ctx context.Context, mediaID, userID string) (*domain.Media, error) {
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if media.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	return media, nil
+}
+
+// updateTags stores a media item's new tags and brings the tag index in
+// line with them
+func (s *Service) updateTags(ctx context.Context, media *domain.Media, tags map[string]string) error {
+	if err := s.dynamoClient.SetMediaTags(ctx, media.ID, tags); err != nil {
+		return err
+	}
+
+	oldKeys := make(map[string]bool)
+	for _, key := range domain.TagIndexKeys(media.Tags) {
+		oldKeys[key] = true
+	}
+
+	var added []string
+	for _, key := range domain.TagIndexKeys(tags) {
+		if oldKeys[key] {
+			delete(oldKeys, key)
+			continue
+		}
+		added = append(added, key)
+	}
+
+	removed := make([]string, 0, len(oldKeys))
+	for key := range oldKeys {
+		removed = append(removed, key)
+	}
+
+	if err := s.dynamoClient.PutTagEntries(ctx, media.ID, added, media.IsListed()); err != nil {
+		return err
+	}
+	if err := s.dynamoClient.DeleteTagEntries(ctx, media.ID, removed); err != nil {
+		return err
+	}
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, media.ID)
+	}
+
+	s.log.Info("media tags updated", "media_id", media.ID, "added", len(added), "removed", len(removed))
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/manifest"
+)
+
+// VersionList is a media item's output versions and which one plays
+type VersionList struct {
+	MediaID  string                 `json:"media_id"`
+	Active   int                    `json:"active"`
+	Versions []domain.OutputVersion `json:"versions"`
+}
+
+// ListVersions returns the output versions of a media item owned by the
+// user, oldest first
+func (s *Service) ListVersions(ctx context.Context, mediaID, userID string) (*VersionList, error) {
+	media, err := s.getOwnedMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return versionList(media), nil
+}
+
+// ActivateVersion makes an output version of a media item owned by the
+// user the one that plays, publishing its master playlist with the media's
+// current subtitle tracks. Media being processed can't be switched, since
+// the run will activate a version of its own.
+func (s *Service) ActivateVersion(ctx context.Context, mediaID, userID string, number int) (*VersionList, error) {
+	media, err := s.getOwnedMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.activateVersion(ctx, media, number)
+}
+
+// RollbackVersion activates the newest output version of a media item
+// older than the active one
+func (s *Service) RollbackVersion(ctx context.Context, mediaID, userID string) (*VersionList, error) {
+	media, err := s.getOwnedMedia(ctx, mediaID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := 0
+	for _, v := range media.GetVersions() {
+		if v.Number < media.GetActiveVersion() && v.Number > previous {
+			previous = v.Number
+		}
+	}
+	if previous == 0 {
+		return nil, domain.ErrVersionNotFound
+	}
+	return s.activateVersion(ctx, media, previous)
+}
+
+// activateVersion publishes an output version and records it as active.
+// The record is only updated if no other switch got there first; if it
+// can't be, the master playlist of the version recorded is published
+// again.
+func (s *Service) activateVersion(ctx context.Context, media *domain.Media, number int) (*VersionList, error) {
+	if media.Status == domain.MediaStatusPending || media.Status == domain.MediaStatusProcessing {
+		return nil, domain.ErrMediaBusy
+	}
+	if media.GetVersion(number) == nil {
+		return nil, domain.ErrVersionNotFound
+	}
+	active := media.GetActiveVersion()
+	if number == active {
+		return versionList(media), nil
+	}
+
+	if err := s.publishVersionMaster(ctx, media, number); err != nil {
+		return nil, err
+	}
+	versions := media.GetVersions()
+	if err := s.dynamoClient.SetMediaVersions(ctx, media.ID, versions, number, active); err != nil {
+		// Put back the master playlist of whichever version is recorded
+		// as active now
+		current := media
+		if err == domain.ErrVersionConflict {
+			if latest, getErr := s.dynamoClient.GetMedia(ctx, media.ID); getErr == nil {
+				current = latest
+			}
+		}
+		if restoreErr := s.publishVersionMaster(ctx, current, current.GetActiveVersion()); restoreErr != nil {
+			s.log.Error("failed to restore master playlist", "error", restoreErr, "media_id", media.ID)
+		}
+		return nil, err
+	}
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, media.ID)
+	}
+	s.invalidateCDN(ctx, media)
+
+	s.log.Info("output version activated", "media_id", media.ID, "version", number, "previous", active)
+
+	media.OutputVersion = number
+	media.Versions = versions
+	return versionList(media), nil
+}
+
+// publishVersionMaster publishes the master playlist of an output version
+// with the media's subtitle tracks
+func (s *Service) publishVersionMaster(ctx context.Context, media *domain.Media, number int) error {
+	bucket := s.s3Client.GetProcessedBucket()
+
+	reader, err := s.s3Client.Download(ctx, bucket, media.GetVersionMasterKey(number))
+	if err != nil {
+		return fmt.Errorf("failed to download version master playlist: %w", err)
+	}
+	master, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read version master playlist: %w", err)
+	}
+
+	published := manifest.InsertSubtitleTracks(master, media.Subtitles)
+	if err := s.s3Client.Upload(ctx, bucket, media.GetMasterPlaylistKey(), bytes.NewReader(published), "application/x-mpegURL"); err != nil {
+		return fmt.Errorf("failed to upload master playlist: %w", err)
+	}
+	return nil
+}
+
+func versionList(media *domain.Media) *VersionList {
+	versions := media.GetVersions()
+	if versions == nil {
+		versions = []domain.OutputVersion{}
+	}
+	return &VersionList{
+		MediaID:  media.ID,
+		Active:   media.GetActiveVersion(),
+		Versions: versions,
+	}
+}
@@ -0,0 +1,86 @@
+package transcode
+
+import (
+	"sort"
+	"time"
+
+	"github.com/streaming-service/internal/queue"
+)
+
+// ActiveJob is a job the worker is running
+type ActiveJob struct {
+	ID        string        `json:"id"`
+	Type      queue.JobType `json:"type"`
+	MediaID   string        `json:"media_id"`
+	TenantID  string        `json:"tenant_id,omitempty"`
+	Attempt   int           `json:"attempt"`
+	StartedAt time.Time     `json:"started_at"`
+	Running   string        `json:"running"`
+}
+
+// TypeSlots is how many of a job type's slots are in use
+type TypeSlots struct {
+	Limit int `json:"limit"`
+	InUse int `json:"in_use"`
+}
+
+// Stats is a snapshot of the worker's internals, for diagnostics
+type Stats struct {
+	Concurrency int                         `json:"concurrency"`
+	Healthy     bool                        `json:"healthy"`
+	Active      []ActiveJob                 `json:"active"`
+	TypeSlots   map[queue.JobType]TypeSlots `json:"type_slots,omitempty"`
+}
+
+// Stats returns the worker's concurrency, health, and the jobs it is
+// running, longest running first
+func (w *Worker) Stats() Stats {
+	w.mu.Lock()
+	concurrency := w.concurrency
+	w.mu.Unlock()
+
+	stats := Stats{
+		Concurrency: concurrency,
+		Healthy:     w.healthy.Load(),
+		Active:      []ActiveJob{},
+	}
+
+	w.activeMu.Lock()
+	for _, job := range w.active {
+		job.Running = time.Since(job.StartedAt).Round(time.Second).String()
+		stats.Active = append(stats.Active, job)
+	}
+	w.activeMu.Unlock()
+	sort.Slice(stats.Active, func(i, j int) bool {
+		return stats.Active[i].StartedAt.Before(stats.Active[j].StartedAt)
+	})
+
+	if len(w.slots) > 0 {
+		stats.TypeSlots = make(map[queue.JobType]TypeSlots, len(w.slots))
+		for jobType, slots := range w.slots {
+			stats.TypeSlots[jobType] = TypeSlots{Limit: cap(slots), InUse: len(slots)}
+		}
+	}
+	return stats
+}
+
+// track records a job as running until the returned function is called
+func (w *Worker) track(job *queue.Job) (done func()) {
+	w.activeMu.Lock()
+	defer w.activeMu.Unlock()
+
+	w.active[job.ID] = ActiveJob{
+		ID:        job.ID,
+		Type:      job.Type,
+		MediaID:   job.MediaID,
+		TenantID:  job.TenantID,
+		Attempt:   job.Attempts + 1,
+		StartedAt: time.Now().UTC(),
+	}
+	return func() {
+		w.activeMu.Lock()
+		defer w.activeMu.Unlock()
+
+		delete(w.active, job.ID)
+	}
+}
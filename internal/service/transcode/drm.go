@@ -0,0 +1,80 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/manifest"
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/internal/repository/speke"
+)
+
+// SetDRM encrypts the CMAF segments of video with Common Encryption, under
+// a content key from keyServer licensed by systems, for each version
+func (s *Service) SetDRM(keyServer *speke.Client, systems []domain.DRMSystem) {
+	s.drm = keyServer
+	s.drmSystems = systems
+}
+
+// contentKey requests a new content key for a media item's next version,
+// or returns nil when its segments aren't protected
+func (s *Service) contentKey(ctx context.Context, media *domain.Media) (*speke.ContentKey, error) {
+	if s.drm == nil || media.Type != domain.MediaTypeVideo {
+		return nil, nil
+	}
+	key, err := s.drm.GetKey(ctx, media.ID, s.drmSystems)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content key: %w", err)
+	}
+	return key, nil
+}
+
+// cencKey converts a content key for the processor
+func cencKey(key *speke.ContentKey) (*processor.CENCKey, error) {
+	id, err := uuid.Parse(key.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key ID %q: %w", key.KeyID, err)
+	}
+	return &processor.CENCKey{KeyID: id[:], Key: key.Key}, nil
+}
+
+// protectRenditions signals the Common Encryption of the processed CMAF
+// segments in the DASH manifest and each rendition's HLS playlist,
+// returning what to record on the version
+func (s *Service) protectRenditions(output *processor.ProcessOutput, key *speke.ContentKey) (*domain.DRMInfo, error) {
+	protection := &manifest.CENCProtection{KeyID: key.KeyID}
+	for _, system := range s.drmSystems {
+		protection.Systems = append(protection.Systems, manifest.CENCSystem{
+			ID:   system.SystemID(),
+			PSSH: key.PSSH[system],
+		})
+	}
+
+	mpd, err := os.ReadFile(output.DASHPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DASH manifest: %w", err)
+	}
+	if err := os.WriteFile(output.DASHPath, manifest.SetDASHProtection(mpd, protection), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write DASH manifest: %w", err)
+	}
+
+	for _, r := range output.Renditions {
+		playlist, err := os.ReadFile(r.PlaylistPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read playlist: %w", err)
+		}
+		if err := os.WriteFile(r.PlaylistPath, manifest.InsertCENCKeyTags(playlist, protection), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write playlist: %w", err)
+		}
+	}
+
+	return &domain.DRMInfo{
+		Scheme:  "cenc",
+		KeyIDs:  []string{key.KeyID},
+		Systems: s.drmSystems,
+	}, nil
+}
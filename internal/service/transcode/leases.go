@@ -0,0 +1,106 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// errJobStalled fails the attempt of a job whose worker stopped
+// heartbeating, such as by crashing
+var errJobStalled = errors.New("job stalled: its worker stopped heartbeating")
+
+// SetLeases heartbeats the jobs the worker processes so they stay leased
+// to it, three times a lease, and every reapInterval returns jobs whose
+// lease expired to the queue
+func (w *Worker) SetLeases(l queue.Leaser, lease, reapInterval time.Duration) {
+	w.leaser = l
+	w.heartbeatInterval = lease / 3
+	w.reapInterval = reapInterval
+}
+
+// heartbeat keeps a job leased to the worker until the returned function
+// is called
+func (w *Worker) heartbeat(job *queue.Job, log *logger.Logger) (stop func()) {
+	if w.leaser == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(w.heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), queueTimeout)
+				err := w.leaser.Heartbeat(ctx, job)
+				cancel()
+				if errors.Is(err, queue.ErrLeaseLost) {
+					log.Warn("job lease lost, it may run again")
+					return
+				}
+				if err != nil {
+					log.Error("failed to heartbeat job", "error", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// reapStalled reaps stalled jobs every reap interval until ctx is done
+func (w *Worker) reapStalled(ctx context.Context) {
+	ticker := time.NewTicker(w.reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.reap(ctx)
+		}
+	}
+}
+
+// reap returns jobs whose lease expired to the queue, and records their
+// stalled attempts as failed. A media processing job out of attempts
+// fails its media, as its worker would have.
+func (w *Worker) reap(ctx context.Context) {
+	reapCtx, cancel := context.WithTimeout(ctx, queueTimeout)
+	defer cancel()
+
+	reaped, err := w.leaser.Reap(reapCtx)
+	if err != nil {
+		w.log.Error("failed to reap stalled jobs", "error", err)
+	}
+
+	for _, job := range reaped {
+		last := job.LastAttempt()
+		w.log.Warn("stalled job reaped", "job_id", job.ID, "media_id", job.MediaID, "attempts", job.Attempts+1, "dead", last)
+
+		if w.jobs != nil {
+			w.jobs.Finished(job, errJobStalled, last)
+		}
+		if !last {
+			continue
+		}
+		if processesMedia(job) && !w.service.outbox {
+			jobCtx := tenant.WithID(reapCtx, job.TenantID)
+			if err := w.service.dynamoClient.UpdateMediaStatus(jobCtx, job.MediaID, domain.MediaStatusFailed); err != nil {
+				w.log.Error("failed to update media status", "error", err, "media_id", job.MediaID)
+			}
+		}
+		w.publish(job, domain.EventMediaFailed, errJobStalled)
+		w.endJob(reapCtx, job)
+	}
+}
@@ -0,0 +1,32 @@
+package transcode
+
+import (
+	"errors"
+	"time"
+
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/pkg/metrics"
+)
+
+var (
+	jobDuration = metrics.NewHistogram("streaming_job_duration_seconds",
+		"Time taken by each attempt at a job, by type and outcome",
+		metrics.ExponentialBuckets(1, 2, 14), "type", "outcome")
+	transcodeFailures = metrics.NewCounter("streaming_transcode_failures_total",
+		"Transcodes that failed, by the rendition being transcoded, or none if none had started", "rendition")
+)
+
+// observeJob records how long an attempt at a job took and how it ended
+func (w *Worker) observeJob(job *queue.Job, start time.Time, err error) {
+	outcome := "succeeded"
+	switch {
+	case err == nil:
+	case w.jobsCtx.Err() != nil:
+		outcome = "interrupted"
+	case errors.Is(err, errJobTimedOut):
+		outcome = "timed_out"
+	default:
+		outcome = "failed"
+	}
+	jobDuration.Since(start, string(job.Type), outcome)
+}
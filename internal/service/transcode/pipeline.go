@@ -0,0 +1,395 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/internal/repository/speke"
+	"github.com/streaming-service/internal/workflow"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// Workflow steps, in the order they run
+const (
+	StepValidate    = "validate"
+	StepDownload    = "download"
+	StepTranscode   = "transcode"
+	StepModerate    = "moderate"
+	StepFingerprint = "fingerprint"
+	StepEncrypt     = "encrypt"
+	StepUpload      = "upload"
+	StepThumbnails  = "thumbnails"
+	StepPublish     = "publish"
+	StepCaptions    = "captions"
+)
+
+// pipeline is one run of a media item's processing workflow, holding what
+// each step leaves for the next
+type pipeline struct {
+	s        *Service
+	media    *domain.Media
+	progress *progressTracker
+	log      *logger.Logger
+
+	tempPath   string
+	input      *processor.ProcessInput
+	contentKey *speke.ContentKey
+	output     *processor.ProcessOutput
+	drm        *domain.DRMInfo
+	version    *domain.OutputVersion
+	prefix     string
+}
+
+// ProcessMedia processes a media file through the processing workflow,
+// recording each step's status on the media as it runs
+func (s *Service) ProcessMedia(ctx context.Context, mediaID string) error {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("starting media processing", "media_id", mediaID)
+
+	// Get media record
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return fmt.Errorf("failed to get media: %w", err)
+	}
+
+	// Update status to processing
+	var processing []*domain.Event
+	if s.outbox {
+		processing = append(processing, &domain.Event{Type: domain.EventMediaProcessing, MediaID: mediaID, UserID: media.UserID})
+	}
+	if err := s.dynamoClient.UpdateMediaStatus(ctx, mediaID, domain.MediaStatusProcessing, processing...); err != nil {
+		log.Error("failed to update status", "error", err)
+	}
+
+	p := &pipeline{
+		s:        s,
+		media:    media,
+		progress: s.newProgressTracker(ctx, mediaID),
+		log:      log,
+	}
+	defer p.cleanup()
+
+	steps := p.steps()
+	p.progress.workflow(workflow.Pending(steps))
+	if err := workflow.Run(ctx, steps, p.progress.step(ctx)); err != nil {
+		s.markFailed(ctx, mediaID, p.progress)
+		return err
+	}
+
+	log.Info("media processing completed", "media_id", mediaID)
+
+	return nil
+}
+
+// steps returns the workflow's steps. Moderation, fingerprinting,
+// thumbnails and captions only log their failures, as processing did
+// before they were steps.
+func (p *pipeline) steps() []workflow.Step {
+	s := p.s
+	return []workflow.Step{
+		{Name: StepValidate, Run: p.validate},
+		{Name: StepDownload, Run: p.download, Retries: 2},
+		{Name: StepTranscode, Run: p.transcode},
+		{
+			Name:     StepModerate,
+			When:     func() bool { return s.moderation != nil },
+			Run:      p.moderate,
+			Optional: true,
+		},
+		{
+			// Sources known to have no audio are skipped
+			Name: StepFingerprint,
+			When: func() bool {
+				return s.copyright != nil && (p.output.Source == nil || len(p.output.Source.Audio) > 0)
+			},
+			Run:      p.fingerprint,
+			Optional: true,
+		},
+		{
+			Name: StepEncrypt,
+			When: func() bool {
+				return s.keys != nil || (p.contentKey != nil && p.output.DASHPath != "")
+			},
+			Run: p.encrypt,
+		},
+		{Name: StepUpload, Run: p.upload, Retries: 2},
+		{
+			Name:     StepThumbnails,
+			When:     func() bool { return len(p.output.CoverArt) > 0 || p.output.Storyboard != nil },
+			Run:      p.thumbnails,
+			Optional: true,
+		},
+		{Name: StepPublish, Run: p.publish},
+		{
+			// Captions are transcribed from the published media
+			Name: StepCaptions,
+			When: func() bool {
+				return s.captions != nil && s.captions.TranscriptionEnabled() && p.media.AutoCaptions
+			},
+			Run:      p.captions,
+			Optional: true,
+		},
+	}
+}
+
+// validate checks the media has a source to process
+func (p *pipeline) validate(ctx context.Context) error {
+	if p.media.SourceBucket == "" || p.media.SourceKey == "" {
+		return errors.New("media has no source file")
+	}
+	return nil
+}
+
+// download saves the source file to a temp file
+func (p *pipeline) download(ctx context.Context) error {
+	p.progress.stage(ctx, domain.ProcessingStageDownloading)
+	reader, err := p.s.s3Client.Download(ctx, p.media.SourceBucket, p.media.SourceKey)
+	if err != nil {
+		return fmt.Errorf("failed to download source: %w", err)
+	}
+	defer reader.Close()
+
+	tempPath := filepath.Join(os.TempDir(), "streaming", p.media.ID+p.media.SourceFormat)
+	if err := os.MkdirAll(filepath.Dir(tempPath), 0755); err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	tempFile, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	if _, err := io.Copy(tempFile, reader); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to save source: %w", err)
+	}
+	tempFile.Close()
+	p.tempPath = tempPath
+	return nil
+}
+
+// transcode produces the renditions, cover art and storyboard, and
+// conditions the playlists with ad cue markers
+func (p *pipeline) transcode(ctx context.Context) error {
+	s := p.s
+
+	// Configure processing profiles
+	s.mu.RLock()
+	profiles := s.profiles
+	s.mu.RUnlock()
+
+	p.input = &processor.ProcessInput{
+		MediaID:       p.media.ID,
+		SourcePath:    p.tempPath,
+		OutputDir:     filepath.Join(os.TempDir(), "streaming", p.media.ID),
+		Profiles:      profiles,
+		Progress:      p.progress.rendition(ctx),
+		Percent:       p.progress.percent(ctx),
+		CoverArtSizes: domain.CoverArtSizes,
+	}
+
+	// Each version is encrypted under a new content key
+	contentKey, err := s.contentKey(ctx, p.media)
+	if err != nil {
+		return err
+	}
+	if contentKey != nil {
+		if p.input.CENC, err = cencKey(contentKey); err != nil {
+			return err
+		}
+	}
+	p.contentKey = contentKey
+
+	p.progress.transcoding(ctx, profiles)
+	output, err := s.processor.Process(ctx, p.input)
+	if err != nil {
+		// A transcode cut short by shutdown or the job timeout didn't fail
+		if ctx.Err() == nil {
+			transcodeFailures.Inc(p.progress.current())
+		}
+		return fmt.Errorf("processing failed: %w", err)
+	}
+	p.output = output
+
+	if output.Source != nil {
+		if err := s.dynamoClient.SetMediaMetadata(ctx, p.media.ID, output.Source); err != nil {
+			p.log.Error("failed to record source metadata", "error", err)
+		}
+	}
+
+	// Condition rendition playlists with ad cue markers
+	if len(p.media.AdBreaks) > 0 {
+		s.applyAdBreaks(ctx, output, p.media.AdBreaks)
+	}
+	return nil
+}
+
+// moderate checks the source against content policy; a failed scan holds
+// the media when holding is configured
+func (p *pipeline) moderate(ctx context.Context) error {
+	p.progress.stage(ctx, domain.ProcessingStageModerating)
+	if _, err := p.s.moderation.Scan(ctx, p.media, p.tempPath); err != nil {
+		p.log.Error("moderation scan failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+// fingerprint flags audio matching copyrighted recordings
+func (p *pipeline) fingerprint(ctx context.Context) error {
+	p.progress.stage(ctx, domain.ProcessingStageFingerprinting)
+	if _, err := p.s.copyright.Check(ctx, p.media.ID, p.tempPath); err != nil {
+		p.log.Error("copyright check failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+// encrypt encrypts segments with rotating content keys, and signals the
+// Common Encryption of CMAF segments in their manifests
+func (p *pipeline) encrypt(ctx context.Context) error {
+	s := p.s
+	if s.keys != nil {
+		p.progress.stage(ctx, domain.ProcessingStageEncrypting)
+		info, err := s.encryptRenditions(ctx, p.media.ID, p.output)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt renditions: %w", err)
+		}
+		if info != nil {
+			if err := s.dynamoClient.SetMediaEncryption(ctx, p.media.ID, info); err != nil {
+				p.log.Error("failed to record encryption", "error", err)
+			}
+		}
+	}
+
+	if p.contentKey != nil && p.output.DASHPath != "" {
+		drm, err := s.protectRenditions(p.output, p.contentKey)
+		if err != nil {
+			return fmt.Errorf("failed to protect renditions: %w", err)
+		}
+		p.drm = drm
+	}
+	return nil
+}
+
+// upload uploads the processed files to S3 as a new output version
+func (p *pipeline) upload(ctx context.Context) error {
+	s := p.s
+	p.progress.stage(ctx, domain.ProcessingStageUploading)
+	p.version = &domain.OutputVersion{
+		Number:    p.media.NextVersion(),
+		DRM:       p.drm,
+		CreatedAt: time.Now().UTC(),
+	}
+	p.prefix = p.media.GetVersionPrefix(p.version.Number)
+	if err := s.uploadProcessedFiles(ctx, p.prefix, p.output); err != nil {
+		return fmt.Errorf("failed to upload processed files: %w", err)
+	}
+	if p.output.DASHPath != "" {
+		key := p.media.GetVersionDASHKey(p.version.Number)
+		if err := s.uploadFile(ctx, s.s3Client.GetProcessedBucket(), key, p.output.DASHPath, "application/dash+xml"); err != nil {
+			return fmt.Errorf("failed to upload DASH manifest: %w", err)
+		}
+		p.version.DASHKey = key
+	}
+	return nil
+}
+
+// thumbnails uploads the cover art and storyboard of the new version
+func (p *pipeline) thumbnails(ctx context.Context) error {
+	s := p.s
+	var errs []error
+	if len(p.output.CoverArt) > 0 {
+		key, err := s.uploadCoverArt(ctx, p.media, p.version.Number, p.output.CoverArt)
+		if err != nil {
+			p.log.Error("failed to upload cover art", "error", err)
+			errs = append(errs, err)
+		}
+		p.version.CoverArtKey = key
+	}
+	if p.output.Storyboard != nil {
+		key, err := s.uploadStoryboard(ctx, p.media.GetVersionStoryboardPrefix(p.version.Number), p.output.Storyboard)
+		if err != nil {
+			p.log.Error("failed to upload storyboard", "error", err)
+			errs = append(errs, err)
+		}
+		p.version.StoryboardKey = key
+	}
+	return errors.Join(errs...)
+}
+
+// publish activates the new version in place of the one playing and
+// completes the media
+func (p *pipeline) publish(ctx context.Context) error {
+	s := p.s
+	media := p.media
+
+	var err error
+	if p.version.Size, err = dirSize(filepath.Dir(p.output.MasterPath)); err != nil {
+		p.log.Error("failed to measure processed files", "error", err)
+	}
+
+	p.progress.stage(ctx, domain.ProcessingStagePublishing)
+	for _, r := range p.output.Renditions {
+		p.version.Renditions = append(p.version.Renditions, domain.Rendition{
+			Name:        r.Name,
+			Width:       r.Width,
+			Height:      r.Height,
+			Bitrate:     r.Bitrate,
+			Codec:       r.Codec,
+			PlaylistKey: p.prefix + r.Name + "/playlist.m3u8",
+		})
+	}
+	outputSize, err := s.publishVersion(ctx, media, p.version, p.output.MasterPath)
+	if err != nil {
+		return fmt.Errorf("failed to publish output version: %w", err)
+	}
+	s.recordUsage(ctx, media, p.output, outputSize)
+
+	// Update status to completed
+	p.progress.stage(ctx, domain.ProcessingStageCompleted)
+	var processed []*domain.Event
+	if s.outbox {
+		processed = append(processed, &domain.Event{Type: domain.EventMediaProcessed, MediaID: media.ID, UserID: media.UserID})
+	}
+	if err := s.dynamoClient.UpdateMediaStatus(ctx, media.ID, domain.MediaStatusCompleted, processed...); err != nil {
+		p.log.Error("failed to update status", "error", err)
+	}
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, media.ID)
+	}
+
+	// Re-published media may still have old manifests cached at the edge
+	if len(media.Renditions) > 0 {
+		s.invalidateCDN(ctx, media)
+	}
+	return nil
+}
+
+// captions queues transcription of the published media's captions
+func (p *pipeline) captions(ctx context.Context) error {
+	if err := p.s.captions.RequestCaptions(ctx, p.media.ID); err != nil {
+		p.log.Error("failed to queue transcription", "error", err)
+		return err
+	}
+	return nil
+}
+
+// cleanup removes the temp files of the source and processed output
+func (p *pipeline) cleanup() {
+	if p.tempPath != "" {
+		os.Remove(p.tempPath)
+	}
+	if p.input != nil {
+		os.RemoveAll(p.input.OutputDir)
+	}
+}
@@ -0,0 +1,183 @@
+package transcode
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/processor"
+	"github.com/streaming-service/internal/repository"
+	"github.com/streaming-service/internal/workflow"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// progressSaveInterval is how often a rendition's percent complete is
+// saved while it transcodes; its start and finish are always saved
+const progressSaveInterval = 2 * time.Second
+
+// progressTracker records a media item's progress through processing on
+// its record as the worker moves from stage to stage. Failing to record
+// progress is logged but never fails processing.
+type progressTracker struct {
+	dynamoClient repository.MediaStore
+	mediaID      string
+	log          *logger.Logger
+
+	mu       sync.Mutex
+	progress domain.ProcessingProgress
+	savedAt  time.Time
+}
+
+// newProgressTracker starts tracking a media item's processing
+func (s *Service) newProgressTracker(ctx context.Context, mediaID string) *progressTracker {
+	return &progressTracker{
+		dynamoClient: s.dynamoClient,
+		mediaID:      mediaID,
+		log:          logger.FromContext(ctx, s.log),
+	}
+}
+
+// workflow sets the steps of the processing workflow, recorded with the
+// next save
+func (t *progressTracker) workflow(steps []domain.WorkflowStep) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.progress.Steps = steps
+}
+
+// step returns a workflow.Observer recording each change of a step's
+// status
+func (t *progressTracker) step(ctx context.Context) workflow.Observer {
+	return func(step domain.WorkflowStep) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		for i := range t.progress.Steps {
+			if t.progress.Steps[i].Name == step.Name {
+				t.progress.Steps[i] = step
+			}
+		}
+		t.save(ctx)
+	}
+}
+
+// stage moves processing on to the next stage
+func (t *progressTracker) stage(ctx context.Context, stage domain.ProcessingStage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.progress.Stage = stage
+	t.save(ctx)
+}
+
+// transcoding starts the transcoding stage with every rendition pending
+func (t *progressTracker) transcoding(ctx context.Context, profiles []processor.ProfileConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.progress.Stage = domain.ProcessingStageTranscoding
+	t.progress.Renditions = make([]domain.RenditionProgress, 0, len(profiles))
+	for _, p := range profiles {
+		t.progress.Renditions = append(t.progress.Renditions, domain.RenditionProgress{
+			Name:   p.Name,
+			Status: domain.RenditionStatusPending,
+		})
+	}
+	t.save(ctx)
+}
+
+// rendition returns a processor.ProgressFunc updating the status of each
+// rendition as it is transcoded
+func (t *progressTracker) rendition(ctx context.Context) processor.ProgressFunc {
+	return func(name string, done bool) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		r := t.find(name)
+		if done {
+			r.Status = domain.RenditionStatusDone
+			r.Percent = 100
+			t.progress.Rendition = ""
+		} else {
+			r.Status = domain.RenditionStatusTranscoding
+			r.Percent = 0
+			t.progress.Rendition = name
+		}
+		t.sumPercent()
+		t.save(ctx)
+	}
+}
+
+// current returns the rendition being transcoded, or none
+func (t *progressTracker) current() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.progress.Rendition == "" {
+		return "none"
+	}
+	return t.progress.Rendition
+}
+
+// percent returns a processor.PercentFunc updating how far the rendition
+// being transcoded has got, saved at most every progressSaveInterval
+func (t *progressTracker) percent(ctx context.Context) processor.PercentFunc {
+	return func(name string, percent float64) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		t.find(name).Percent = math.Round(percent*10) / 10
+		t.sumPercent()
+		if time.Since(t.savedAt) >= progressSaveInterval {
+			t.save(ctx)
+		}
+	}
+}
+
+// find returns a rendition's progress, adding it if it wasn't known;
+// callers hold mu
+func (t *progressTracker) find(name string) *domain.RenditionProgress {
+	for i := range t.progress.Renditions {
+		if t.progress.Renditions[i].Name == name {
+			return &t.progress.Renditions[i]
+		}
+	}
+	t.progress.Renditions = append(t.progress.Renditions, domain.RenditionProgress{Name: name})
+	return &t.progress.Renditions[len(t.progress.Renditions)-1]
+}
+
+// sumPercent sets the overall percent complete from the renditions';
+// callers hold mu
+func (t *progressTracker) sumPercent() {
+	if len(t.progress.Renditions) == 0 {
+		return
+	}
+	var sum float64
+	for _, r := range t.progress.Renditions {
+		sum += r.Percent
+	}
+	t.progress.Percent = math.Round(sum/float64(len(t.progress.Renditions))*10) / 10
+}
+
+// fail records the stage processing failed in, and why if reason is set
+func (t *progressTracker) fail(ctx context.Context, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.progress.FailedStage = t.progress.Stage
+	t.progress.Error = reason
+	t.progress.Stage = domain.ProcessingStageFailed
+	t.save(ctx)
+}
+
+// save writes the current progress; callers hold mu
+func (t *progressTracker) save(ctx context.Context) {
+	t.progress.UpdatedAt = time.Now()
+	t.savedAt = t.progress.UpdatedAt
+	if err := t.dynamoClient.UpdateMediaProcessing(ctx, t.mediaID, &t.progress); err != nil {
+		t.log.Error("failed to record processing progress", "error", err, "media_id", t.mediaID, "stage", t.progress.Stage)
+	}
+}
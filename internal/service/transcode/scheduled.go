@@ -0,0 +1,67 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/tenant"
+)
+
+// SetPromoter promotes the queue's scheduled jobs to pending every
+// interval once they are due
+func (w *Worker) SetPromoter(p queue.Promoter, interval time.Duration) {
+	w.promoter = p
+	w.promoteInterval = interval
+}
+
+// Admit claims the tenant's job slot for a due scheduled media processing
+// job, which holds none while it waits. A job whose tenant has no slot
+// free stays scheduled until one is.
+func (w *Worker) Admit(ctx context.Context, job *queue.Job) bool {
+	if w.service.quotas == nil || !processesMedia(job) {
+		return true
+	}
+
+	err := w.service.quotas.StartJob(tenant.WithID(ctx, job.TenantID))
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		w.log.Debug("scheduled job waiting for a job slot", "job_id", job.ID, "media_id", job.MediaID, "tenant_id", job.TenantID)
+	default:
+		w.log.Error("failed to claim job slot", "error", err, "job_id", job.ID, "media_id", job.MediaID)
+	}
+	return false
+}
+
+// Withdraw frees the slot Admit claimed for a job promoted elsewhere
+func (w *Worker) Withdraw(ctx context.Context, job *queue.Job) {
+	w.endJob(ctx, job)
+}
+
+// promoteScheduled promotes due jobs every promote interval until ctx is
+// done
+func (w *Worker) promoteScheduled(ctx context.Context) {
+	ticker := time.NewTicker(w.promoteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			promoteCtx, cancel := context.WithTimeout(ctx, queueTimeout)
+			n, err := w.promoter.Promote(promoteCtx)
+			cancel()
+			if err != nil {
+				w.log.Error("failed to promote scheduled jobs", "error", err)
+			}
+			if n > 0 {
+				w.log.Info("scheduled jobs promoted", "count", n)
+			}
+		}
+	}
+}
@@ -2,179 +2,239 @@ package transcode
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/correlation"
 	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/events"
+	"github.com/streaming-service/internal/media/encryption"
+	"github.com/streaming-service/internal/media/manifest"
 	"github.com/streaming-service/internal/media/processor"
 	"github.com/streaming-service/internal/queue"
-	"github.com/streaming-service/internal/repository/dynamodb"
-	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/repository"
+	"github.com/streaming-service/internal/repository/cloudfront"
+	"github.com/streaming-service/internal/repository/speke"
+	"github.com/streaming-service/internal/service/captions"
+	"github.com/streaming-service/internal/service/copyright"
+	"github.com/streaming-service/internal/service/enrich"
+	"github.com/streaming-service/internal/service/export"
+	"github.com/streaming-service/internal/service/jobs"
+	"github.com/streaming-service/internal/service/keys"
+	"github.com/streaming-service/internal/service/moderation"
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/internal/service/search"
+	"github.com/streaming-service/internal/tenant"
 	"github.com/streaming-service/pkg/logger"
 )
 
 // Service handles transcoding operations
 type Service struct {
-	s3Client     *s3.Client
-	dynamoClient *dynamodb.Client
+	s3Client     Storage
+	dynamoClient repository.MediaStore
 	processor    processor.MediaProcessor
+	cdn          *cloudfront.Client
+	keys         *keys.Service
+	drm          *speke.Client
+	drmSystems   []domain.DRMSystem
+	search       *search.Service
+	quotas       *quotas.Service
+	moderation   *moderation.Service
+	copyright    *copyright.Service
+	captions     *captions.Service
+	outbox       bool
+	keepVersions int
 	log          *logger.Logger
+
+	// profiles are the renditions produced, which can be reloaded
+	mu       sync.RWMutex
+	profiles []processor.ProfileConfig
+}
+
+// Storage is the object storage the transcode service reads sources from
+// and writes processed files to
+type Storage interface {
+	repository.Uploader
+	repository.Downloader
+	Delete(ctx context.Context, bucket, key string) error
+	ListObjects(ctx context.Context, bucket, prefix string) ([]types.Object, error)
+	GetProcessedBucket() string
+}
+
+// defaultKeepVersions is how many output versions are kept unless
+// configured
+const defaultKeepVersions = 3
+
+// defaultProfiles are produced when no profiles are configured
+var defaultProfiles = []processor.ProfileConfig{
+	{Name: "1080p", Width: 1920, Height: 1080, VideoBitrate: "5000k", AudioBitrate: "192k", Codec: "h264"},
+	{Name: "720p", Width: 1280, Height: 720, VideoBitrate: "2500k", AudioBitrate: "128k", Codec: "h264"},
+	{Name: "480p", Width: 854, Height: 480, VideoBitrate: "1000k", AudioBitrate: "96k", Codec: "h264"},
+	{Name: "360p", Width: 640, Height: 360, VideoBitrate: "500k", AudioBitrate: "64k", Codec: "h264"},
+}
+
+// segmentContentTypes maps the extensions of a rendition's media files to
+// their content types
+var segmentContentTypes = map[string]string{
+	".ts":  "video/MP2T",
+	".aac": "audio/aac",
+	".m4s": "video/iso.segment",
+	".mp4": "video/mp4",
 }
 
 // NewService creates a new transcode service
-func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, proc processor.MediaProcessor, log *logger.Logger) *Service {
+func NewService(s3Client Storage, dynamoClient repository.MediaStore, proc processor.MediaProcessor, log *logger.Logger) *Service {
 	return &Service{
 		s3Client:     s3Client,
 		dynamoClient: dynamoClient,
 		processor:    proc,
+		keepVersions: defaultKeepVersions,
 		log:          log,
+		profiles:     defaultProfiles,
 	}
 }
 
-// ProcessMedia processes a media file
-func (s *Service) ProcessMedia(ctx context.Context, mediaID string) error {
-	s.log.Info("starting media processing", "media_id", mediaID)
+// SetKeepVersions sets how many output versions of a media item are kept
+// to roll back to, the active one included
+func (s *Service) SetKeepVersions(n int) {
+	s.keepVersions = n
+}
 
-	// Get media record
-	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
-	if err != nil {
-		return fmt.Errorf("failed to get media: %w", err)
+// SetProfiles changes the renditions produced for media processed from
+// now on
+func (s *Service) SetProfiles(profiles []config.TranscodeProfile) {
+	next := make([]processor.ProfileConfig, 0, len(profiles))
+	for _, p := range profiles {
+		next = append(next, processor.ProfileConfig{
+			Name:         p.Name,
+			Width:        p.Width,
+			Height:       p.Height,
+			VideoBitrate: p.VideoBitrate,
+			AudioBitrate: p.AudioBitrate,
+			Codec:        p.Codec,
+		})
 	}
-
-	// Update status to processing
-	if err := s.dynamoClient.UpdateMediaStatus(ctx, mediaID, domain.MediaStatusProcessing); err != nil {
-		s.log.Error("failed to update status", "error", err)
+	if len(next) == 0 {
+		next = defaultProfiles
 	}
 
-	// Download source file
-	reader, err := s.s3Client.Download(ctx, media.SourceBucket, media.SourceKey)
-	if err != nil {
-		s.markFailed(ctx, mediaID)
-		return fmt.Errorf("failed to download source: %w", err)
-	}
-	defer reader.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles = next
+}
 
-	// Save to temp file
-	tempPath := filepath.Join(os.TempDir(), "streaming", mediaID+media.SourceFormat)
-	if err := os.MkdirAll(filepath.Dir(tempPath), 0755); err != nil {
-		s.markFailed(ctx, mediaID)
-		return fmt.Errorf("failed to create temp dir: %w", err)
-	}
+// SetCDN sets the CloudFront client used for cache invalidation
+func (s *Service) SetCDN(cdn *cloudfront.Client) {
+	s.cdn = cdn
+}
 
-	tempFile, err := os.Create(tempPath)
-	if err != nil {
-		s.markFailed(ctx, mediaID)
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
+// SetKeys enables AES-128 segment encryption using the given key service
+func (s *Service) SetKeys(k *keys.Service) {
+	s.keys = k
+}
 
-	if _, err := io.Copy(tempFile, reader); err != nil {
-		tempFile.Close()
-		os.Remove(tempPath)
-		s.markFailed(ctx, mediaID)
-		return fmt.Errorf("failed to save source: %w", err)
-	}
-	tempFile.Close()
-	defer os.Remove(tempPath)
+// SetSearch keeps the search index in step with processing status
+func (s *Service) SetSearch(svc *search.Service) {
+	s.search = svc
+}
 
-	// Configure processing profiles
-	profiles := []processor.ProfileConfig{
-		{Name: "1080p", Width: 1920, Height: 1080, VideoBitrate: "5000k", AudioBitrate: "192k", Codec: "h264"},
-		{Name: "720p", Width: 1280, Height: 720, VideoBitrate: "2500k", AudioBitrate: "128k", Codec: "h264"},
-		{Name: "480p", Width: 854, Height: 480, VideoBitrate: "1000k", AudioBitrate: "96k", Codec: "h264"},
-		{Name: "360p", Width: 640, Height: 360, VideoBitrate: "500k", AudioBitrate: "64k", Codec: "h264"},
-	}
+// SetModeration scans each source against content policy before its
+// media is published
+func (s *Service) SetModeration(svc *moderation.Service) {
+	s.moderation = svc
+}
 
-	// Process media
-	input := &processor.ProcessInput{
-		MediaID:    mediaID,
-		SourcePath: tempPath,
-		OutputDir:  filepath.Join(os.TempDir(), "streaming", mediaID),
-		Profiles:   profiles,
-	}
+// SetCopyright flags media whose audio matches copyrighted recordings
+func (s *Service) SetCopyright(svc *copyright.Service) {
+	s.copyright = svc
+}
 
-	output, err := s.processor.Process(ctx, input)
-	if err != nil {
-		s.markFailed(ctx, mediaID)
-		return fmt.Errorf("processing failed: %w", err)
-	}
+// SetQuotas counts processed output and transcode minutes against tenant
+// quotas, and frees each job's slot once it is done
+func (s *Service) SetQuotas(svc *quotas.Service) {
+	s.quotas = svc
+}
+
+// SetOutbox writes media.processed and media.failed to the outbox with
+// the status change, for the relay to deliver, instead of the worker
+// publishing them
+func (s *Service) SetOutbox(enabled bool) {
+	s.outbox = enabled
+}
 
-	// Upload processed files to S3
-	if err := s.uploadProcessedFiles(ctx, mediaID, output); err != nil {
-		s.markFailed(ctx, mediaID)
-		return fmt.Errorf("failed to upload processed files: %w", err)
+// recordUsage counts a media item's processed files, now totalling
+// outputSize across the versions kept, and the minutes transcoded against
+// the tenant's quotas. Only the difference from what was counted before
+// is added.
+func (s *Service) recordUsage(ctx context.Context, media *domain.Media, output *processor.ProcessOutput, outputSize int64) {
+	if s.quotas != nil {
+		s.quotas.AddStorage(ctx, outputSize-media.OutputSize)
+		s.quotas.RecordTranscode(ctx, time.Duration(output.Duration*float64(time.Second)))
 	}
+}
 
-	// Update media record with renditions
-	for _, r := range output.Renditions {
-		rendition := domain.Rendition{
-			Name:        r.Name,
-			Width:       r.Width,
-			Height:      r.Height,
-			Bitrate:     r.Bitrate,
-			Codec:       r.Codec,
-			PlaylistKey: fmt.Sprintf("%s/%s/playlist.m3u8", mediaID, r.Name),
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
 		}
-		if err := s.dynamoClient.AddRendition(ctx, mediaID, rendition); err != nil {
-			s.log.Error("failed to add rendition", "error", err, "rendition", r.Name)
+		info, err := d.Info()
+		if err != nil {
+			return err
 		}
-	}
-
-	// Update status to completed
-	if err := s.dynamoClient.UpdateMediaStatus(ctx, mediaID, domain.MediaStatusCompleted); err != nil {
-		s.log.Error("failed to update status", "error", err)
-	}
-
-	// Cleanup temp files
-	os.RemoveAll(input.OutputDir)
-
-	s.log.Info("media processing completed", "media_id", mediaID)
-
-	return nil
+		size += info.Size()
+		return nil
+	})
+	return size, err
 }
 
-// uploadProcessedFiles uploads all processed HLS files to S3
-func (s *Service) uploadProcessedFiles(ctx context.Context, mediaID string, output *processor.ProcessOutput) error {
+// uploadProcessedFiles uploads the rendition playlists and segments to
+// S3 under prefix; the master playlist is published with the version
+func (s *Service) uploadProcessedFiles(ctx context.Context, prefix string, output *processor.ProcessOutput) error {
+	log := logger.FromContext(ctx, s.log)
 	bucket := s.s3Client.GetProcessedBucket()
 	outputDir := filepath.Dir(output.MasterPath)
 
-	// Upload master playlist
-	masterFile, err := os.Open(output.MasterPath)
-	if err != nil {
-		return fmt.Errorf("failed to open master playlist: %w", err)
-	}
-	defer masterFile.Close()
-
-	masterKey := mediaID + "/master.m3u8"
-	if err := s.s3Client.Upload(ctx, bucket, masterKey, masterFile, "application/x-mpegURL"); err != nil {
-		return fmt.Errorf("failed to upload master playlist: %w", err)
-	}
-
 	// Upload each rendition
 	for _, r := range output.Renditions {
 		renditionDir := filepath.Join(outputDir, r.Name)
 
 		// Upload playlist
 		playlistPath := filepath.Join(renditionDir, "playlist.m3u8")
-		if err := s.uploadFile(ctx, bucket, fmt.Sprintf("%s/%s/playlist.m3u8", mediaID, r.Name), playlistPath, "application/x-mpegURL"); err != nil {
-			s.log.Error("failed to upload playlist", "error", err, "rendition", r.Name)
+		if err := s.uploadFile(ctx, bucket, prefix+r.Name+"/playlist.m3u8", playlistPath, "application/x-mpegURL"); err != nil {
+			log.Error("failed to upload playlist", "error", err, "rendition", r.Name)
 			continue
 		}
 
-		// Upload segments
-		segments, err := filepath.Glob(filepath.Join(renditionDir, "segment_*.ts"))
+		// Upload segments, or the single media file of byte-range
+		// renditions
+		files, err := filepath.Glob(filepath.Join(renditionDir, "*"))
 		if err != nil {
-			s.log.Error("failed to find segments", "error", err)
+			log.Error("failed to find segments", "error", err)
 			continue
 		}
 
-		for _, seg := range segments {
+		for _, seg := range files {
+			contentType, ok := segmentContentTypes[filepath.Ext(seg)]
+			if !ok {
+				continue
+			}
 			segName := filepath.Base(seg)
-			segKey := fmt.Sprintf("%s/%s/%s", mediaID, r.Name, segName)
-			if err := s.uploadFile(ctx, bucket, segKey, seg, "video/MP2T"); err != nil {
-				s.log.Error("failed to upload segment", "error", err, "segment", segName)
+			segKey := prefix + r.Name + "/" + segName
+			if err := s.uploadFile(ctx, bucket, segKey, seg, contentType); err != nil {
+				log.Error("failed to upload segment", "error", err, "segment", segName)
 			}
 		}
 	}
@@ -182,6 +242,141 @@ func (s *Service) uploadProcessedFiles(ctx context.Context, mediaID string, outp
 	return nil
 }
 
+// uploadCoverArt uploads each size of cover art made from the source
+// with an output version, returning the key of the largest
+func (s *Service) uploadCoverArt(ctx context.Context, media *domain.Media, version int, paths map[int]string) (string, error) {
+	bucket := s.s3Client.GetProcessedBucket()
+	var largest string
+	for _, size := range domain.CoverArtSizes {
+		path, ok := paths[size]
+		if !ok {
+			continue
+		}
+		key := media.GetVersionCoverArtKey(version, size)
+		if err := s.uploadFile(ctx, bucket, key, path, "image/jpeg"); err != nil {
+			return "", err
+		}
+		if largest == "" {
+			largest = key
+		}
+	}
+	return largest, nil
+}
+
+// uploadStoryboard uploads thumbnail sprite sheets and their WebVTT file
+// under prefix, returning the WebVTT file's key. The sheets go first so
+// the file never references missing ones.
+func (s *Service) uploadStoryboard(ctx context.Context, prefix string, storyboard *processor.StoryboardOutput) (string, error) {
+	bucket := s.s3Client.GetProcessedBucket()
+	for _, path := range storyboard.SpritePaths {
+		if err := s.uploadFile(ctx, bucket, prefix+filepath.Base(path), path, "image/jpeg"); err != nil {
+			return "", err
+		}
+	}
+	key := prefix + filepath.Base(storyboard.VTTPath)
+	if err := s.uploadFile(ctx, bucket, key, storyboard.VTTPath, "text/vtt"); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// applyAdBreaks inserts cue markers into the local rendition playlists,
+// and events into the DASH manifest
+func (s *Service) applyAdBreaks(ctx context.Context, output *processor.ProcessOutput, breaks []domain.AdBreak) {
+	log := logger.FromContext(ctx, s.log)
+	outputDir := filepath.Dir(output.MasterPath)
+	if output.DASHPath != "" {
+		data, err := os.ReadFile(output.DASHPath)
+		if err == nil {
+			err = os.WriteFile(output.DASHPath, manifest.SetDASHEvents(data, breaks), 0644)
+		}
+		if err != nil {
+			log.Error("failed to add ad breaks to DASH manifest", "error", err)
+		}
+	}
+	for _, r := range output.Renditions {
+		playlistPath := filepath.Join(outputDir, r.Name, "playlist.m3u8")
+		data, err := os.ReadFile(playlistPath)
+		if err != nil {
+			log.Error("failed to read playlist", "error", err, "rendition", r.Name)
+			continue
+		}
+		if err := os.WriteFile(playlistPath, manifest.InsertCueMarkers(data, breaks), 0644); err != nil {
+			log.Error("failed to write playlist", "error", err, "rendition", r.Name)
+		}
+	}
+}
+
+// encryptRenditions encrypts rendition segments in place, rotating to a
+// new content key every SegmentsPerKey segments, and references the keys
+// from each rendition playlist. Segment N uses the implicit IV N, which
+// matches ffmpeg's media sequence numbering for VOD output.
+func (s *Service) encryptRenditions(ctx context.Context, mediaID string, output *processor.ProcessOutput) (*domain.EncryptionInfo, error) {
+	outputDir := filepath.Dir(output.MasterPath)
+	perKey := s.keys.SegmentsPerKey()
+
+	segments := make(map[string][]string, len(output.Renditions))
+	maxSegments := 0
+	for _, r := range output.Renditions {
+		segs, err := filepath.Glob(filepath.Join(outputDir, r.Name, "segment_*.ts"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to find segments: %w", err)
+		}
+		segments[r.Name] = segs
+		if len(segs) > maxSegments {
+			maxSegments = len(segs)
+		}
+	}
+
+	if maxSegments == 0 {
+		return nil, nil
+	}
+
+	// Renditions share segment boundaries, so they share key periods too
+	contentKeys, err := s.keys.CreateKeys(ctx, mediaID, (maxSegments+perKey-1)/perKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create content keys: %w", err)
+	}
+
+	keyIDs := make([]string, len(contentKeys))
+	keyURIs := make([]string, len(contentKeys))
+	for i, k := range contentKeys {
+		keyIDs[i] = k.ID
+		keyURIs[i] = k.URI
+	}
+
+	for _, r := range output.Renditions {
+		for i, seg := range segments[r.Name] {
+			data, err := os.ReadFile(seg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read segment: %w", err)
+			}
+			encrypted, err := encryption.EncryptSegment(data, contentKeys[i/perKey].Key, encryption.SequenceIV(uint64(i)))
+			if err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(seg, encrypted, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write segment: %w", err)
+			}
+		}
+
+		playlistPath := filepath.Join(outputDir, r.Name, "playlist.m3u8")
+		playlist, err := os.ReadFile(playlistPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read playlist: %w", err)
+		}
+		if err := os.WriteFile(playlistPath, manifest.InsertKeyTags(playlist, keyURIs, perKey), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write playlist: %w", err)
+		}
+	}
+
+	return &domain.EncryptionInfo{
+		Method:         "AES-128",
+		KeyIDs:         keyIDs,
+		SegmentsPerKey: perKey,
+	}, nil
+}
+
 func (s *Service) uploadFile(ctx context.Context, bucket, key, path, contentType string) error {
 	file, err := os.Open(path)
 	if err != nil {
@@ -192,46 +387,331 @@ func (s *Service) uploadFile(ctx context.Context, bucket, key, path, contentType
 	return s.s3Client.Upload(ctx, bucket, key, file, contentType)
 }
 
-func (s *Service) markFailed(ctx context.Context, mediaID string) {
+func (s *Service) markFailed(ctx context.Context, mediaID string, progress *progressTracker) {
+	// A job cancelled by the worker shutting down goes back to the queue
+	// rather than failing
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	// A job that timed out fails with the reason, recorded outside its
+	// expired context
+	var reason string
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = context.Cause(ctx).Error()
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), failTimeout)
+		defer cancel()
+	}
+	progress.fail(ctx, reason)
 	if err := s.dynamoClient.UpdateMediaStatus(ctx, mediaID, domain.MediaStatusFailed); err != nil {
-		s.log.Error("failed to mark as failed", "error", err, "media_id", mediaID)
+		logger.FromContext(ctx, s.log).Error("failed to mark as failed", "error", err, "media_id", mediaID)
 	}
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+}
+
+const (
+	// dequeueTimeout is how long a process loop blocks waiting for a job
+	dequeueTimeout = 5 * time.Second
+	// queueTimeout bounds acking or requeuing a job, which happens on a
+	// fresh context so it still completes during shutdown
+	queueTimeout = 10 * time.Second
+	// eventTimeout bounds delivering the event ending a job
+	eventTimeout = 30 * time.Second
+	// failTimeout bounds recording the failure of a job that timed out
+	failTimeout = 10 * time.Second
+	// slotBackoff is how long a process loop waits before dequeuing again
+	// after returning a job whose type was at its concurrency limit
+	slotBackoff = time.Second
+)
+
+// errJobTimedOut fails a job that ran longer than the job timeout
+var errJobTimedOut = errors.New("job timed out")
+
+// DependencyCheck probes a dependency the worker needs to process jobs
+type DependencyCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
 }
 
-// Worker processes jobs from the queue
+// Handler runs a job of one type. The job's logger is in ctx.
+type Handler func(ctx context.Context, job *queue.Job) error
+
+// Worker processes jobs from the queue, running each with the handler
+// registered for its type
 type Worker struct {
-	queue       queue.Queue
-	service     *Service
+	queue   queue.Queue
+	service *Service
+	events  *events.Dispatcher
+	jobs    *jobs.Service
+	log     *logger.Logger
+	wg      sync.WaitGroup
+
+	// handlers are registered before Start. slots holds a semaphore for
+	// each type with a concurrency limit.
+	handlers map[queue.JobType]Handler
+	slots    map[queue.JobType]chan struct{}
+
+	// Dequeuing pauses while healthy is false
+	checks        []DependencyCheck
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+	healthy       atomic.Bool
+
+	// leaser, when set, leases jobs to the worker, which heartbeats them
+	// and reaps the jobs of stalled workers
+	leaser            queue.Leaser
+	heartbeatInterval time.Duration
+	reapInterval      time.Duration
+
+	// jobTimeout, when set, bounds running each job
+	jobTimeout time.Duration
+
+	// promoter, when set, has scheduled jobs the worker promotes once due
+	promoter        queue.Promoter
+	promoteInterval time.Duration
+
+	// Jobs run under jobsCtx rather than the context passed to Start, so
+	// they can finish while draining; abortJobs cancels it
+	jobsCtx   context.Context
+	abortJobs context.CancelFunc
+
+	// active holds the jobs being run, for diagnostics
+	activeMu sync.Mutex
+	active   map[string]ActiveJob
+
+	mu          sync.Mutex
+	ctx         context.Context
 	concurrency int
-	log         *logger.Logger
-	wg          sync.WaitGroup
+	// loops holds a stop channel for each running process loop
+	loops []chan struct{}
 }
 
-// NewWorker creates a new transcode worker
+// NewWorker creates a new transcode worker, which processes media from
+// transcode, audio and thumbnail jobs
 func NewWorker(q queue.Queue, svc *Service, concurrency int, log *logger.Logger) *Worker {
-	return &Worker{
+	w := &Worker{
 		queue:       q,
 		service:     svc,
 		concurrency: concurrency,
 		log:         log,
+		handlers:    make(map[queue.JobType]Handler),
+		slots:       make(map[queue.JobType]chan struct{}),
+		active:      make(map[string]ActiveJob),
+	}
+	w.healthy.Store(true)
+	w.jobsCtx, w.abortJobs = context.WithCancel(context.Background())
+
+	w.Handle(queue.JobTypeTranscode, w.processMedia)
+	w.Handle(queue.JobTypeAudio, w.processMedia)
+	w.Handle(queue.JobTypeThumbnail, w.processMedia)
+	return w
+}
+
+// Handle registers h to run jobs of a type, replacing any handler
+// registered for it. Jobs of types without a handler fail.
+func (w *Worker) Handle(jobType queue.JobType, h Handler) {
+	w.handlers[jobType] = h
+}
+
+// SetTypeConcurrency caps how many jobs of each type the worker runs at
+// once. A process loop dequeuing a job of a type at its limit returns it
+// to the queue and moves on, so a backlog of one type can't starve the
+// others. Types without a limit are only bound by the worker's
+// concurrency.
+func (w *Worker) SetTypeConcurrency(limits map[queue.JobType]int) {
+	w.slots = make(map[queue.JobType]chan struct{}, len(limits))
+	for jobType, limit := range limits {
+		if limit > 0 {
+			w.slots[jobType] = make(chan struct{}, limit)
+		}
 	}
 }
 
-// Start begins processing jobs
+// SetJobTimeout fails jobs that run longer than timeout, killing any
+// ffmpeg they started
+func (w *Worker) SetJobTimeout(timeout time.Duration) {
+	w.jobTimeout = timeout
+}
+
+// SetCaptions runs subtitle translation and transcription jobs with svc,
+// which also queues transcription of processed media
+func (w *Worker) SetCaptions(svc *captions.Service) {
+	w.service.captions = svc
+	w.Handle(queue.JobTypeTranslate, func(ctx context.Context, job *queue.Job) error {
+		languages := strings.Split(job.Payload["languages"], ",")
+		return svc.Translate(ctx, job.MediaID, job.Payload["track"], languages)
+	})
+	w.Handle(queue.JobTypeTranscribe, func(ctx context.Context, job *queue.Job) error {
+		return svc.Transcribe(ctx, job.MediaID, job.LastAttempt())
+	})
+}
+
+// SetEnrichment runs enrichment jobs with svc
+func (w *Worker) SetEnrichment(svc *enrich.Service) {
+	w.Handle(queue.JobTypeEnrich, func(ctx context.Context, job *queue.Job) error {
+		return svc.Enrich(ctx, job.MediaID, job.Payload["track"])
+	})
+}
+
+// SetExport builds media export packages with svc
+func (w *Worker) SetExport(svc *export.Service) {
+	w.Handle(queue.JobTypeExport, func(ctx context.Context, job *queue.Job) error {
+		return svc.Build(ctx, job.MediaID, job.Payload["export_id"], job.LastAttempt())
+	})
+}
+
+// SetEvents publishes media.processed and media.failed as processing jobs
+// end
+func (w *Worker) SetEvents(d *events.Dispatcher) {
+	w.events = d
+}
+
+// SetJobs records each job as it starts and finishes with svc
+func (w *Worker) SetJobs(svc *jobs.Service) {
+	w.jobs = svc
+}
+
+// SetDependencies gates dequeuing on checks: Start waits until they all
+// pass, then they are rerun every interval and dequeuing pauses while any
+// fails. Each check is bounded by timeout.
+func (w *Worker) SetDependencies(checks []DependencyCheck, interval, timeout time.Duration) {
+	w.checks = checks
+	w.checkInterval = interval
+	w.checkTimeout = timeout
+	w.healthy.Store(false)
+}
+
+// Start begins processing jobs once dependencies are healthy
 func (w *Worker) Start(ctx context.Context) error {
-	for i := 0; i < w.concurrency; i++ {
-		w.wg.Add(1)
-		go w.processLoop(ctx, i)
+	if len(w.checks) > 0 {
+		for !w.checkDependencies(ctx) {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(w.checkInterval):
+			}
+		}
+		go w.monitorDependencies(ctx)
+	}
+	if w.leaser != nil {
+		go w.reapStalled(ctx)
 	}
+	if w.promoter != nil {
+		go w.promoteScheduled(ctx)
+	}
+	for jobType := range w.slots {
+		if _, ok := w.handlers[jobType]; !ok {
+			w.log.Warn("concurrency limit for a job type without a handler", "type", jobType)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.ctx = ctx
+	w.resize()
 	return nil
 }
 
+// SetConcurrency changes how many jobs are processed at once. Loops let go
+// when shrinking finish their current job first.
+func (w *Worker) SetConcurrency(concurrency int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.concurrency = concurrency
+	if w.ctx != nil {
+		w.resize()
+	}
+}
+
+// resize starts or stops process loops to match the concurrency; callers
+// hold mu
+func (w *Worker) resize() {
+	for len(w.loops) < w.concurrency {
+		stop := make(chan struct{})
+		w.wg.Add(1)
+		go w.processLoop(w.ctx, len(w.loops), stop)
+		w.loops = append(w.loops, stop)
+	}
+	for len(w.loops) > w.concurrency && len(w.loops) > 0 {
+		last := len(w.loops) - 1
+		close(w.loops[last])
+		w.loops = w.loops[:last]
+	}
+}
+
 // Wait waits for all workers to finish
 func (w *Worker) Wait() {
 	w.wg.Wait()
 }
 
-func (w *Worker) processLoop(ctx context.Context, workerID int) {
+// Drain waits for in-flight jobs to finish once the context passed to
+// Start is cancelled. Jobs still running after timeout are aborted and
+// released back to the queue for another worker to pick up; Drain returns
+// false if any had to be.
+func (w *Worker) Drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		w.abortJobs()
+		<-done
+		return false
+	}
+}
+
+// monitorDependencies rechecks dependencies every interval until ctx is
+// done
+func (w *Worker) monitorDependencies(ctx context.Context) {
+	ticker := time.NewTicker(w.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.checkDependencies(ctx)
+		}
+	}
+}
+
+// checkDependencies runs every check, recording and returning whether
+// they all passed
+func (w *Worker) checkDependencies(ctx context.Context) bool {
+	var down []string
+	for _, check := range w.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, w.checkTimeout)
+		err := check.Check(checkCtx)
+		cancel()
+		if err != nil {
+			down = append(down, check.Name)
+			w.log.Warn("dependency check failed", "dependency", check.Name, "error", err)
+		}
+	}
+
+	healthy := len(down) == 0
+	if w.healthy.Swap(healthy) != healthy {
+		if healthy {
+			w.log.Info("dependencies healthy, dequeuing jobs")
+		} else {
+			w.log.Warn("dependencies unhealthy, dequeuing paused", "dependencies", down)
+		}
+	}
+	return healthy
+}
+
+func (w *Worker) processLoop(ctx context.Context, workerID int, stop <-chan struct{}) {
 	defer w.wg.Done()
 
 	w.log.Info("worker started", "worker_id", workerID)
@@ -241,36 +721,246 @@ func (w *Worker) processLoop(ctx context.Context, workerID int) {
 		case <-ctx.Done():
 			w.log.Info("worker stopping", "worker_id", workerID)
 			return
+		case <-stop:
+			w.log.Info("worker stopping", "worker_id", workerID)
+			return
 		default:
 		}
 
+		// Leave jobs queued while a dependency is down
+		if !w.healthy.Load() {
+			select {
+			case <-ctx.Done():
+			case <-stop:
+			case <-time.After(w.checkInterval):
+			}
+			continue
+		}
+
 		// Get next job
-		job, err := w.queue.Dequeue(ctx, 5) // 5 second timeout
+		job, err := w.queue.Dequeue(ctx, dequeueTimeout)
 		if err != nil {
-			w.log.Error("failed to dequeue job", "error", err)
+			if !errors.Is(err, queue.ErrNoJobAvailable) && ctx.Err() == nil {
+				w.log.Error("failed to dequeue job", "error", err)
+			}
 			continue
 		}
 
-		if job == nil {
-			continue // No jobs available
+		// Hand a job of a type at its concurrency limit back rather than
+		// wait holding it, and back off so it isn't dequeued again at once
+		freeSlot, ok := w.takeSlot(job.Type)
+		if !ok {
+			w.returnJob(job)
+			select {
+			case <-ctx.Done():
+			case <-stop:
+			case <-time.After(slotBackoff):
+			}
+			continue
 		}
 
-		w.log.Info("processing job", "job_id", job.ID, "media_id", job.MediaID, "worker_id", workerID)
+		// Every log line for the job carries its IDs, including the API
+		// request that queued it
+		fields := []interface{}{"job_id", job.ID, "media_id", job.MediaID}
+		if job.RequestID != "" {
+			fields = append(fields, "request_id", job.RequestID)
+		}
+		if job.TenantID != "" {
+			fields = append(fields, "tenant_id", job.TenantID)
+		}
+		jobLog := w.log.WithFields(fields...)
+		jobCtx := tenant.WithID(correlation.WithID(w.jobsCtx, job.RequestID), job.TenantID)
+		jobCtx = logger.NewContext(jobCtx, jobLog)
 
-		// Process the job
-		if err := w.service.ProcessMedia(ctx, job.MediaID); err != nil {
-			w.log.Error("job processing failed", "error", err, "job_id", job.ID)
-			if err := w.queue.Nack(ctx, job); err != nil {
-				w.log.Error("failed to nack job", "error", err)
-			}
-			continue
+		jobLog.Info("processing job", "worker_id", workerID)
+		if w.jobs != nil {
+			w.jobs.Started(job)
 		}
+		w.publish(job, domain.EventMediaProcessing, nil)
+
+		// Process the job, keeping it leased
+		stopHeartbeat := w.heartbeat(job, jobLog)
+		untrack := w.track(job)
+		start := time.Now()
+		err = w.process(jobCtx, job, jobLog)
+		untrack()
+		stopHeartbeat()
+		freeSlot()
+		w.observeJob(job, start, err)
+		w.finish(job, err, jobLog)
+	}
+}
 
-		// Acknowledge successful completion
+// takeSlot takes one of the slots of a job type with a concurrency limit,
+// returning the func freeing it, or false when the type is at its limit
+func (w *Worker) takeSlot(jobType queue.JobType) (func(), bool) {
+	slots := w.slots[jobType]
+	if slots == nil {
+		return func() {}, true
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		return nil, false
+	}
+}
+
+// returnJob puts a job that wasn't started back in the queue without
+// counting an attempt
+func (w *Worker) returnJob(job *queue.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueTimeout)
+	defer cancel()
+
+	w.log.Debug("job type at its concurrency limit, requeuing", "job_id", job.ID, "type", job.Type)
+	if err := w.queue.Release(ctx, job); err != nil {
+		w.log.Error("failed to requeue job", "error", err, "job_id", job.ID)
+	}
+}
+
+// finish acks a completed job, nacks a failed one for retry, and releases
+// one aborted by shutdown back to the queue
+func (w *Worker) finish(job *queue.Job, err error, log *logger.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueTimeout)
+	defer cancel()
+
+	switch {
+	case err == nil:
 		if err := w.queue.Ack(ctx, job); err != nil {
-			w.log.Error("failed to ack job", "error", err, "job_id", job.ID)
+			log.Error("failed to ack job", "error", err)
+		}
+		if w.jobs != nil {
+			w.jobs.Finished(job, nil, false)
+		}
+		w.endJob(ctx, job)
+		w.publish(job, domain.EventMediaProcessed, nil)
+		log.Info("job completed")
+
+	case w.jobsCtx.Err() != nil:
+		log.Warn("job interrupted by shutdown, requeuing")
+		if err := w.queue.Release(ctx, job); err != nil {
+			log.Error("failed to requeue job", "error", err)
+		}
+		if w.jobs != nil {
+			w.jobs.Queued(job)
+		}
+
+	default:
+		log.Error("job processing failed", "error", err)
+		// The last failure is published before the nack, so an outbox
+		// entry is written before the job is given up on
+		if job.LastAttempt() {
+			w.publish(job, domain.EventMediaFailed, err)
+		}
+		// Recorded before the nack counts the attempt
+		if w.jobs != nil {
+			w.jobs.Finished(job, err, job.LastAttempt())
+		}
+		if err := w.queue.Nack(ctx, job); err != nil {
+			log.Error("failed to nack job", "error", err)
+		}
+		if job.LastAttempt() {
+			w.endJob(ctx, job)
+		}
+	}
+}
+
+// publish emits the events starting and ending a media processing job.
+// With the outbox, media.processing and media.processed were written with
+// the status updates, and media.failed is written with the failed status
+// set again.
+func (w *Worker) publish(job *queue.Job, eventType domain.EventType, err error) {
+	if !processesMedia(job) {
+		return
+	}
+	if !w.service.outbox && w.events == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+	defer cancel()
+	ctx = tenant.WithID(correlation.WithID(ctx, job.RequestID), job.TenantID)
+
+	event := &domain.Event{
+		Type:     eventType,
+		MediaID:  job.MediaID,
+		TenantID: job.TenantID,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	switch {
+	case !w.service.outbox:
+		w.events.Publish(ctx, event)
+	case eventType == domain.EventMediaFailed:
+		if err := w.service.dynamoClient.UpdateMediaStatus(ctx, job.MediaID, domain.MediaStatusFailed, event); err != nil {
+			logger.FromContext(ctx, w.log).Error("failed to write event to outbox", "error", err, "event", eventType)
 		}
+	}
+}
 
-		w.log.Info("job completed", "job_id", job.ID, "media_id", job.MediaID)
+// processesMedia reports whether a job processes a media item's source,
+// as opposed to working on its subtitles or packaging it for export
+func processesMedia(job *queue.Job) bool {
+	switch job.Type {
+	case queue.JobTypeTranslate, queue.JobTypeEnrich, queue.JobTypeExport, queue.JobTypeTranscribe:
+		return false
+	}
+	return true
+}
+
+// endJob frees the tenant's job slot held by a job that won't run again.
+// Only media processing jobs hold one.
+func (w *Worker) endJob(ctx context.Context, job *queue.Job) {
+	if w.service.quotas != nil && processesMedia(job) {
+		w.service.quotas.EndJob(tenant.WithID(ctx, job.TenantID))
+	}
+}
+
+// process runs a job, turning a panic into a failed job so one bad input
+// can't take the worker down
+func (w *Worker) process(ctx context.Context, job *queue.Job, log *logger.Logger) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Error("job panicked", "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			err = fmt.Errorf("job panicked: %v", rec)
+		}
+	}()
+
+	handle, ok := w.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler for %s jobs", job.Type)
+	}
+
+	if w.jobTimeout <= 0 {
+		return handle(ctx, job)
+	}
+	// Commands run with the context, so expiry kills them
+	ctx, cancel := context.WithTimeoutCause(ctx, w.jobTimeout, fmt.Errorf("%w after %s", errJobTimedOut, w.jobTimeout))
+	defer cancel()
+	err = handle(ctx, job)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// processMedia processes a media item from its source file
+func (w *Worker) processMedia(ctx context.Context, job *queue.Job) error {
+	return w.service.ProcessMedia(ctx, job.MediaID)
+}
+
+// invalidateCDN drops cached manifests and segments for a media item
+func (s *Service) invalidateCDN(ctx context.Context, media *domain.Media) {
+	if s.cdn == nil {
+		return
+	}
+	log := logger.FromContext(ctx, s.log)
+	id, err := s.cdn.InvalidateMedia(ctx, media.GetOutputPrefix())
+	if err != nil {
+		log.Error("failed to invalidate CDN cache", "error", err, "media_id", media.ID)
+		return
 	}
+	log.Info("CDN invalidation created", "media_id", media.ID, "invalidation_id", id)
 }
@@ -0,0 +1,178 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/mocks"
+	"github.com/streaming-service/pkg/logger"
+)
+
+func TestWorkerProcess(t *testing.T) {
+	errHandler := errors.New("handler failed")
+
+	tests := []struct {
+		name    string
+		jobType queue.JobType
+		// handler, when set, is registered for the job's type
+		handler Handler
+		// jobTimeout bounds running the job when set
+		jobTimeout time.Duration
+		// wantGet reports whether the default handler reads the media
+		wantGet bool
+		wantErr error
+		// wantMsg is the error's message, for errors without a sentinel
+		wantMsg string
+	}{
+		{
+			name:    "runs media jobs with the default handler",
+			jobType: queue.JobTypeTranscode,
+			wantGet: true,
+			wantErr: domain.ErrMediaNotFound,
+		},
+		{
+			name:    "runs a registered handler",
+			jobType: queue.JobTypeExport,
+			handler: func(ctx context.Context, job *queue.Job) error { return nil },
+		},
+		{
+			name:    "replaces the default handler",
+			jobType: queue.JobTypeThumbnail,
+			handler: func(ctx context.Context, job *queue.Job) error { return errHandler },
+			wantErr: errHandler,
+		},
+		{
+			name:    "fails jobs of a type without a handler",
+			jobType: queue.JobTypeEnrich,
+			wantMsg: "no handler for enrich jobs",
+		},
+		{
+			name:    "turns a panic into a failure",
+			jobType: queue.JobTypeExport,
+			handler: func(ctx context.Context, job *queue.Job) error { panic("bad input") },
+			wantMsg: "job panicked: bad input",
+		},
+		{
+			name:    "fails a job running past the timeout",
+			jobType: queue.JobTypeExport,
+			handler: func(ctx context.Context, job *queue.Job) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			jobTimeout: time.Millisecond,
+			wantErr:    errJobTimedOut,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			objects := mocks.NewMockObjectStore(ctrl)
+			store := mocks.NewMockMediaStore(ctrl)
+			log := logger.New("error", "json")
+
+			w := NewWorker(queue.NewMemoryQueue(), NewService(objects, store, nil, log), 1, log)
+			w.SetJobTimeout(tt.jobTimeout)
+			if tt.handler != nil {
+				w.Handle(tt.jobType, tt.handler)
+			}
+			if tt.wantGet {
+				store.EXPECT().GetMedia(gomock.Any(), "media-1").Return(nil, domain.ErrMediaNotFound)
+			}
+
+			job := &queue.Job{ID: "job-1", Type: tt.jobType, MediaID: "media-1"}
+			err := w.process(context.Background(), job, log)
+			if tt.wantMsg != "" {
+				if err == nil || err.Error() != tt.wantMsg {
+					t.Fatalf("process() error = %v, want %q", err, tt.wantMsg)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("process() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMarkFailed(t *testing.T) {
+	errStore := errors.New("store unavailable")
+
+	tests := []struct {
+		name string
+		// ctx returns the job's context as processing ended
+		ctx func() (context.Context, context.CancelFunc)
+		// statusErr is returned by the store setting the failed status
+		statusErr error
+		// wantFailed reports whether the media is marked failed, with
+		// wantReason recorded on its progress
+		wantFailed bool
+		wantReason string
+	}{
+		{
+			name:       "marks failed media",
+			ctx:        func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantFailed: true,
+		},
+		{
+			name:       "marks failed media when the status can't be set",
+			ctx:        func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			statusErr:  errStore,
+			wantFailed: true,
+		},
+		{
+			name: "records why a timed out job failed",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeoutCause(context.Background(), 0, errJobTimedOut)
+			},
+			wantFailed: true,
+			wantReason: errJobTimedOut.Error(),
+		},
+		{
+			name: "leaves media of a job cancelled by shutdown",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			objects := mocks.NewMockObjectStore(ctrl)
+			store := mocks.NewMockMediaStore(ctrl)
+			svc := NewService(objects, store, nil, logger.New("error", "json"))
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			if tt.wantFailed {
+				// Writes happen on a live context even once the job's expired
+				live := gomock.Cond(func(ctx context.Context) bool { return ctx.Err() == nil })
+				store.EXPECT().UpdateMediaProcessing(live, "media-1", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, progress *domain.ProcessingProgress) error {
+						if progress.Stage != domain.ProcessingStageFailed || progress.FailedStage != domain.ProcessingStageTranscoding {
+							t.Errorf("recorded stage %q failed at %q, want failed at transcoding", progress.Stage, progress.FailedStage)
+						}
+						if progress.Error != tt.wantReason {
+							t.Errorf("recorded reason %q, want %q", progress.Error, tt.wantReason)
+						}
+						return nil
+					})
+				store.EXPECT().UpdateMediaStatus(live, "media-1", domain.MediaStatusFailed).Return(tt.statusErr)
+			}
+
+			progress := svc.newProgressTracker(ctx, "media-1")
+			progress.progress.Stage = domain.ProcessingStageTranscoding
+			svc.markFailed(ctx, "media-1", progress)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/media/manifest"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// publishVersion stores the master playlist of a newly uploaded output
+// version, publishes it with the media's subtitle tracks and makes it the
+// active version, dropping the oldest beyond those kept. It returns the
+// size of all the versions kept.
+func (s *Service) publishVersion(ctx context.Context, media *domain.Media, version *domain.OutputVersion, masterPath string) (int64, error) {
+	log := logger.FromContext(ctx, s.log)
+	bucket := s.s3Client.GetProcessedBucket()
+
+	// Media processed before output was versioned keeps only its published
+	// master playlist, which is saved before being replaced
+	if len(media.Versions) == 0 && len(media.Renditions) > 0 {
+		if err := s.saveLegacyMaster(ctx, media); err != nil {
+			log.Error("failed to save master playlist of version 1", "error", err, "media_id", media.ID)
+		}
+	}
+
+	data, err := os.ReadFile(masterPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read master playlist: %w", err)
+	}
+	master := manifest.PrefixURIs(data, strings.TrimPrefix(media.GetVersionPrefix(version.Number), media.GetOutputPrefix()))
+	if err := s.s3Client.Upload(ctx, bucket, media.GetVersionMasterKey(version.Number), bytes.NewReader(master), "application/x-mpegURL"); err != nil {
+		return 0, fmt.Errorf("failed to upload version master playlist: %w", err)
+	}
+	published := manifest.InsertSubtitleTracks(master, media.Subtitles)
+	if err := s.s3Client.Upload(ctx, bucket, media.GetMasterPlaylistKey(), bytes.NewReader(published), "application/x-mpegURL"); err != nil {
+		return 0, fmt.Errorf("failed to upload master playlist: %w", err)
+	}
+
+	versions := append(media.GetVersions(), *version)
+	var pruned []domain.OutputVersion
+	if keep := max(s.keepVersions, 1); len(versions) > keep {
+		pruned = versions[:len(versions)-keep]
+		versions = versions[len(versions)-keep:]
+	}
+	if err := s.dynamoClient.SetMediaVersions(ctx, media.ID, versions, version.Number, 0); err != nil {
+		return 0, err
+	}
+
+	for _, v := range pruned {
+		s.deleteVersion(ctx, media, v)
+	}
+
+	var size int64
+	for _, v := range versions {
+		size += v.Size
+	}
+	return size, nil
+}
+
+// saveLegacyMaster stores the published master playlist of media
+// processed before output was versioned as that of version 1, without the
+// subtitle tracks listed in it
+func (s *Service) saveLegacyMaster(ctx context.Context, media *domain.Media) error {
+	bucket := s.s3Client.GetProcessedBucket()
+	body, err := s.s3Client.Download(ctx, bucket, media.GetMasterPlaylistKey())
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	master := manifest.InsertSubtitleTracks(data, nil)
+	return s.s3Client.Upload(ctx, bucket, media.GetVersionMasterKey(1), bytes.NewReader(master), "application/x-mpegURL")
+}
+
+// deleteVersion deletes the files of an output version no longer kept.
+// Version 1 shares the output prefix with the others, so only its own
+// renditions, cover art, storyboard and manifests are deleted.
+func (s *Service) deleteVersion(ctx context.Context, media *domain.Media, version domain.OutputVersion) {
+	log := logger.FromContext(ctx, s.log)
+	bucket := s.s3Client.GetProcessedBucket()
+
+	prefixes := []string{media.GetVersionPrefix(version.Number)}
+	if version.Number <= 1 {
+		prefixes = []string{media.GetOutputPrefix() + "cover/", media.GetVersionStoryboardPrefix(1)}
+		for _, r := range version.Renditions {
+			prefixes = append(prefixes, path.Dir(r.PlaylistKey)+"/")
+		}
+	}
+
+	keys := []string{media.GetVersionMasterKey(version.Number)}
+	if version.Number <= 1 && version.DASHKey != "" {
+		keys = append(keys, version.DASHKey)
+	}
+	for _, prefix := range prefixes {
+		objects, err := s.s3Client.ListObjects(ctx, bucket, prefix)
+		if err != nil {
+			log.Error("failed to list version files", "error", err, "media_id", media.ID, "version", version.Number)
+			return
+		}
+		for _, obj := range objects {
+			keys = append(keys, *obj.Key)
+		}
+	}
+	for _, key := range keys {
+		if err := s.s3Client.Delete(ctx, bucket, key); err != nil {
+			log.Error("failed to delete version file", "error", err, "key", key)
+		}
+	}
+
+	log.Info("output version deleted", "media_id", media.ID, "version", version.Number)
+}
@@ -0,0 +1,60 @@
+package upload
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+)
+
+// allowlist holds the extensions and MIME types uploads may have
+type allowlist struct {
+	extensions   map[string]bool
+	contentTypes map[string]bool
+}
+
+// SetAllowlist only accepts uploads of the files cfg allows; without it,
+// any file is accepted
+func (s *Service) SetAllowlist(cfg config.UploadConfig) {
+	a := &allowlist{
+		extensions:   make(map[string]bool, len(cfg.Extensions)),
+		contentTypes: make(map[string]bool, len(cfg.ContentTypes)),
+	}
+	for _, ext := range cfg.Extensions {
+		a.extensions[strings.ToLower(ext)] = true
+	}
+	for _, contentType := range cfg.ContentTypes {
+		a.contentTypes[strings.ToLower(contentType)] = true
+	}
+	s.allowlist = a
+}
+
+// CheckFile returns ErrFileTypeNotAllowed unless a file's extension, and
+// the MIME type it declares if any, are allowed
+func (s *Service) CheckFile(filename, contentType string) error {
+	if s.allowlist == nil {
+		return nil
+	}
+	if !s.allowlist.extensions[strings.ToLower(filepath.Ext(filename))] {
+		return domain.ErrFileTypeNotAllowed
+	}
+
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return domain.ErrFileTypeNotAllowed
+	}
+	// Clients that don't know a file's type send octet-stream
+	if mediaType == "application/octet-stream" {
+		return nil
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	if !s.allowlist.contentTypes[mediaType] && !s.allowlist.contentTypes[major+"/*"] {
+		return domain.ErrFileTypeNotAllowed
+	}
+	return nil
+}
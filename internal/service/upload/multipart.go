@@ -0,0 +1,135 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/streaming-service/internal/domain"
+)
+
+const (
+	// MaxParts is the most parts a multipart upload may have, as in S3
+	MaxParts = 10000
+	// MaxPartURLs bounds the part URLs presigned in one request
+	MaxPartURLs = 100
+	// partURLExpiry is how long presigned part URLs stay valid; clients
+	// ask for more as they go
+	partURLExpiry = time.Hour
+)
+
+// MultipartUpload is a multipart upload of a media source, uploaded in
+// parts to presigned URLs and completed into the media item
+type MultipartUpload struct {
+	MediaID  string `json:"media_id"`
+	UploadID string `json:"upload_id"`
+}
+
+// PartURL is a presigned URL to PUT a part of a multipart upload to. The
+// response's ETag header is needed to complete the upload.
+type PartURL struct {
+	PartNumber int32  `json:"part_number"`
+	URL        string `json:"url"`
+}
+
+// Part is an uploaded part of a multipart upload
+type Part struct {
+	PartNumber int32  `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// StartMultipartUpload starts a multipart upload of a media source for
+// the user. The filename's extension must be given again for each of the
+// upload's parts and to complete or abort it.
+func (s *Service) StartMultipartUpload(ctx context.Context, userID, filename, contentType string) (*MultipartUpload, error) {
+	if err := s.CheckFile(filename, contentType); err != nil {
+		return nil, err
+	}
+
+	// The size is only known once the upload is completed
+	if s.quotas != nil {
+		if err := s.quotas.CheckStorage(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	mediaID := uuid.New().String()
+	s3Key := rawKey(ctx, mediaID, filepath.Ext(filename))
+
+	uploadID, err := s.s3Client.CreateMultipartUpload(ctx, s3Key, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	s.log.Info("multipart upload started", "media_id", mediaID, "user_id", userID)
+
+	return &MultipartUpload{MediaID: mediaID, UploadID: uploadID}, nil
+}
+
+// GetPartURLs presigns URLs for parts of a multipart upload, numbered
+// from 1 to MaxParts, at most MaxPartURLs at a time
+func (s *Service) GetPartURLs(ctx context.Context, mediaID, uploadID, filename string, partNumbers []int32) ([]PartURL, error) {
+	if uploadID == "" || len(partNumbers) == 0 || len(partNumbers) > MaxPartURLs {
+		return nil, domain.ErrInvalidInput
+	}
+	for _, n := range partNumbers {
+		if n < 1 || n > MaxParts {
+			return nil, domain.ErrInvalidInput
+		}
+	}
+
+	s3Key := rawKey(ctx, mediaID, filepath.Ext(filename))
+	urls := make([]PartURL, 0, len(partNumbers))
+	for _, n := range partNumbers {
+		url, err := s.s3Client.GetPresignedPartURL(ctx, s3Key, uploadID, n, partURLExpiry)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, PartURL{PartNumber: n, URL: url})
+	}
+
+	return urls, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the media
+// source and confirms the upload as ConfirmUpload does. Parts may be
+// listed in any order; each part number once.
+func (s *Service) CompleteMultipartUpload(ctx context.Context, req *UploadRequest, mediaID, uploadID string, parts []Part) (*UploadResponse, error) {
+	if !s.valid(req) || uploadID == "" || len(parts) == 0 || len(parts) > MaxParts {
+		return nil, domain.ErrInvalidInput
+	}
+
+	sorted := append([]Part(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+	completed := make([]types.CompletedPart, len(sorted))
+	for i, part := range sorted {
+		if part.ETag == "" || (i > 0 && part.PartNumber == sorted[i-1].PartNumber) {
+			return nil, domain.ErrInvalidInput
+		}
+		completed[i] = types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		}
+	}
+
+	s3Key := rawKey(ctx, mediaID, filepath.Ext(req.Filename))
+	if err := s.s3Client.CompleteMultipartUpload(ctx, s3Key, uploadID, completed); err != nil {
+		return nil, err
+	}
+
+	return s.ConfirmUpload(ctx, req, mediaID)
+}
+
+// AbortMultipartUpload discards a multipart upload and the parts uploaded
+// so far
+func (s *Service) AbortMultipartUpload(ctx context.Context, mediaID, uploadID, filename string) error {
+	if uploadID == "" {
+		return domain.ErrInvalidInput
+	}
+	return s.s3Client.AbortMultipartUpload(ctx, rawKey(ctx, mediaID, filepath.Ext(filename)), uploadID)
+}
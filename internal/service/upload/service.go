@@ -8,24 +8,40 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/correlation"
 	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/events"
 	"github.com/streaming-service/internal/media/processor"
 	"github.com/streaming-service/internal/queue"
-	"github.com/streaming-service/internal/repository/dynamodb"
-	"github.com/streaming-service/internal/repository/s3"
+	"github.com/streaming-service/internal/repository"
+	"github.com/streaming-service/internal/service/quotas"
+	"github.com/streaming-service/internal/service/search"
+	"github.com/streaming-service/internal/tenant"
 	"github.com/streaming-service/pkg/logger"
 )
 
+// retryHistory is how many retries of failed processing a media item
+// keeps in its history
+const retryHistory = 20
+
 // Service handles media upload operations
 type Service struct {
-	s3Client     *s3.Client
-	dynamoClient *dynamodb.Client
+	s3Client     repository.ObjectStore
+	dynamoClient repository.MediaStore
 	queue        queue.Queue
+	search       *search.Service
+	quotas       *quotas.Service
+	events       *events.Dispatcher
+	outbox       bool
+	retention    config.RetentionConfig
+	allowlist    *allowlist
+	tags         repository.TagStore
 	log          *logger.Logger
 }
 
 // NewService creates a new upload service
-func NewService(s3Client *s3.Client, dynamoClient *dynamodb.Client, log *logger.Logger) *Service {
+func NewService(s3Client repository.ObjectStore, dynamoClient repository.MediaStore, log *logger.Logger) *Service {
 	return &Service{
 		s3Client:     s3Client,
 		dynamoClient: dynamoClient,
@@ -38,14 +54,104 @@ func (s *Service) SetQueue(q queue.Queue) {
 	s.queue = q
 }
 
+// SetSearch indexes media as it is created
+func (s *Service) SetSearch(svc *search.Service) {
+	s.search = svc
+}
+
+// SetTagIndex indexes the tags of new media, so they can be browsed by tag
+func (s *Service) SetTagIndex(store repository.TagStore) {
+	s.tags = store
+}
+
+// SetQuotas enforces tenant quotas on uploads and processing
+func (s *Service) SetQuotas(svc *quotas.Service) {
+	s.quotas = svc
+}
+
+// SetEvents publishes media.created for each upload
+func (s *Service) SetEvents(d *events.Dispatcher) {
+	s.events = d
+}
+
+// SetOutbox writes media.created to the outbox with each new media record,
+// for the worker's relay to deliver, instead of publishing it afterwards
+func (s *Service) SetOutbox(enabled bool) {
+	s.outbox = enabled
+}
+
+// SetRetention sets new media to expire after its tenant's retention
+func (s *Service) SetRetention(cfg config.RetentionConfig) {
+	s.retention = cfg
+}
+
+// indexTags adds new media to the tag index under its tags. Media whose
+// tags fail to index keeps them, but isn't listed under them.
+func (s *Service) indexTags(ctx context.Context, media *domain.Media) {
+	if s.tags == nil || len(media.Tags) == 0 {
+		return
+	}
+	if err := s.tags.PutTagEntries(ctx, media.ID, domain.TagIndexKeys(media.Tags), media.IsListed()); err != nil {
+		s.log.Error("failed to index tags", "error", err, "media_id", media.ID)
+	}
+}
+
+// expire sets when new media expires: at, when the upload asked for a
+// time, or else after its tenant's retention, if it has one
+func (s *Service) expire(ctx context.Context, media *domain.Media, at *time.Time) {
+	if !s.retention.Enabled {
+		return
+	}
+	if at != nil {
+		media.SetExpiration(at)
+		return
+	}
+	if retention := s.retention.For(tenant.FromContext(ctx)); retention > 0 {
+		at := media.CreatedAt.Add(retention)
+		media.SetExpiration(&at)
+	}
+}
+
 // UploadRequest represents a media upload request
 type UploadRequest struct {
 	Title       string
 	Description string
 	UserID      string
+	// Visibility defaults to public when empty
+	Visibility  domain.Visibility
 	Filename    string
 	ContentType string
 	Body        io.Reader
+	// Size is the length of Body in bytes
+	Size int64
+	// AutoCaptions transcribes captions from the speech once the media
+	// is processed
+	AutoCaptions bool
+	// Tags are indexed for browsing by tag once the media is created
+	Tags map[string]string
+	// ExpiresAt, when set, expires the media then instead of after its
+	// tenant's retention. It needs retention enabled.
+	ExpiresAt *time.Time
+}
+
+// valid reports whether the request's visibility, tags and expiration
+// may be stored
+func (s *Service) valid(req *UploadRequest) bool {
+	if req.Visibility != "" && !req.Visibility.IsValid() {
+		return false
+	}
+	if req.ExpiresAt != nil && (!s.retention.Enabled || !req.ExpiresAt.After(time.Now())) {
+		return false
+	}
+	if len(req.Tags) > domain.MaxTagsPerMedia {
+		return false
+	}
+	for key, value := range req.Tags {
+		if !domain.IsValidTag(key, value) {
+			return false
+		}
+	}
+	return true
 }
 
 // UploadResponse contains upload result
@@ -57,6 +163,13 @@ type UploadResponse struct {
 
 // Upload handles direct file upload
 func (s *Service) Upload(ctx context.Context, req *UploadRequest) (*UploadResponse, error) {
+	if !s.valid(req) {
+		return nil, domain.ErrInvalidInput
+	}
+	if err := s.CheckFile(req.Filename, req.ContentType); err != nil {
+		return nil, err
+	}
+
 	// Generate unique ID
 	mediaID := uuid.New().String()
 
@@ -65,10 +178,15 @@ func (s *Service) Upload(ctx context.Context, req *UploadRequest) (*UploadRespon
 
 	// Create S3 key
 	ext := filepath.Ext(req.Filename)
-	s3Key := fmt.Sprintf("raw/%s%s", mediaID, ext)
+	s3Key := rawKey(ctx, mediaID, ext)
+
+	if err := s.reserve(ctx, req.Size); err != nil {
+		return nil, err
+	}
 
 	// Upload to S3
 	if err := s.s3Client.UploadRaw(ctx, s3Key, req.Body, req.ContentType); err != nil {
+		s.unreserve(ctx, req.Size)
 		s.log.Error("failed to upload to S3", "error", err, "media_id", mediaID)
 		return nil, fmt.Errorf("upload failed: %w", err)
 	}
@@ -76,17 +194,33 @@ func (s *Service) Upload(ctx context.Context, req *UploadRequest) (*UploadRespon
 	// Create media record
 	media := domain.NewMedia(mediaID, req.Title, req.UserID, mediaType)
 	media.Description = req.Description
+	if req.Visibility != "" {
+		media.Visibility = req.Visibility
+	}
+	media.AutoCaptions = req.AutoCaptions
+	if len(req.Tags) > 0 {
+		media.Tags = req.Tags
+	}
 	media.SourceKey = s3Key
 	media.SourceBucket = s.s3Client.GetRawBucket()
+	media.SourceSize = req.Size
 	media.SourceFormat = ext
+	if s.queue != nil {
+		media.Processing = domain.NewProcessingProgress(domain.ProcessingStageQueued)
+	}
+	s.expire(ctx, media, req.ExpiresAt)
 
-	if err := s.dynamoClient.CreateMedia(ctx, media); err != nil {
+	created := &domain.Event{Type: domain.EventMediaCreated, MediaID: mediaID, UserID: media.UserID}
+	if err := s.dynamoClient.CreateMedia(ctx, media, s.outboxed(created)...); err != nil {
 		s.log.Error("failed to create media record", "error", err, "media_id", mediaID)
 		// Clean up S3 on failure
 		_ = s.s3Client.Delete(ctx, s.s3Client.GetRawBucket(), s3Key)
+		s.unreserve(ctx, req.Size)
 		return nil, fmt.Errorf("failed to create media record: %w", err)
 	}
 
+	s.indexTags(ctx, media)
+
 	// Queue transcoding job
 	if s.queue != nil {
 		job := &queue.Job{
@@ -98,13 +232,21 @@ func (s *Service) Upload(ctx context.Context, req *UploadRequest) (*UploadRespon
 				"source_key":    s3Key,
 				"source_bucket": s.s3Client.GetRawBucket(),
 			},
+			RequestID: correlation.ID(ctx),
+			TenantID:  tenant.FromContext(ctx),
 		}
 		if err := s.queue.Enqueue(ctx, job); err != nil {
 			s.log.Error("failed to enqueue job", "error", err, "media_id", mediaID)
+			s.endJob(ctx)
 			// Don't fail the upload, processing can be retried
 		}
 	}
 
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+	s.publish(ctx, created)
+
 	s.log.Info("media uploaded", "media_id", mediaID, "type", mediaType)
 
 	return &UploadResponse{
@@ -113,11 +255,189 @@ func (s *Service) Upload(ctx context.Context, req *UploadRequest) (*UploadRespon
 	}, nil
 }
 
+// Reprocess re-runs processing of a media item owned by the user from its
+// source file, at a time if one is given. Media still waiting for or
+// undergoing processing yields ErrMediaBusy.
+func (s *Service) Reprocess(ctx context.Context, mediaID, userID string, at time.Time) error {
+	if s.queue == nil {
+		return domain.ErrQueueUnavailable
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+
+	if media.UserID != userID {
+		return domain.ErrUnauthorized
+	}
+
+	if media.Status == domain.MediaStatusPending || media.Status == domain.MediaStatusProcessing {
+		return domain.ErrMediaBusy
+	}
+
+	return s.requeue(ctx, media, at)
+}
+
+// Requeue queues a media item for processing from its source file whoever
+// owns it and whatever its status, for operators recovering media stuck
+// in or failed by processing, at a time if one is given
+func (s *Service) Requeue(ctx context.Context, mediaID string, at time.Time) error {
+	if s.queue == nil {
+		return domain.ErrQueueUnavailable
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+
+	return s.requeue(ctx, media, at)
+}
+
+// requeue resets a media item to pending and queues a job to process it.
+// A job scheduled for later leaves the media as it is until the job runs,
+// so processed media stays playable until then, and claims its job slot
+// only once it is due, when the worker admits it.
+func (s *Service) requeue(ctx context.Context, media *domain.Media, at time.Time) error {
+	mediaID := media.ID
+	scheduled := at.After(time.Now())
+	if s.quotas != nil && !scheduled {
+		if err := s.quotas.StartJob(ctx); err != nil {
+			return err
+		}
+	}
+
+	if !scheduled {
+		if err := s.dynamoClient.UpdateMediaStatus(ctx, mediaID, domain.MediaStatusPending); err != nil {
+			s.endJob(ctx)
+			return err
+		}
+		if err := s.dynamoClient.UpdateMediaProcessing(ctx, mediaID, domain.NewProcessingProgress(domain.ProcessingStageQueued)); err != nil {
+			s.endJob(ctx)
+			return err
+		}
+	}
+
+	if err := s.queue.EnqueueAt(ctx, processingJob(ctx, media), at); err != nil {
+		if !scheduled {
+			s.endJob(ctx)
+		}
+		return err
+	}
+
+	if scheduled {
+		s.log.Info("media scheduled for reprocessing", "media_id", mediaID, "run_at", at)
+		return nil
+	}
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+
+	s.log.Info("media queued for reprocessing", "media_id", mediaID)
+
+	return nil
+}
+
+// Retry queues the user's failed media for processing again from its
+// source file, as a new job with none of the failed job's attempts, and
+// adds the retry to the media's history. Media that hasn't failed yields
+// ErrInvalidMediaStatus.
+func (s *Service) Retry(ctx context.Context, mediaID, userID string) (*domain.ProcessingRetry, error) {
+	if s.queue == nil {
+		return nil, domain.ErrQueueUnavailable
+	}
+
+	media, err := s.dynamoClient.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if media.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	if media.Status != domain.MediaStatusFailed {
+		return nil, domain.ErrInvalidMediaStatus
+	}
+
+	if s.quotas != nil {
+		if err := s.quotas.StartJob(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	job := processingJob(ctx, media)
+	retry := domain.ProcessingRetry{
+		JobID:     job.ID,
+		RetriedAt: time.Now().UTC(),
+	}
+	if media.Processing != nil {
+		retry.FailedStage = media.Processing.FailedStage
+	}
+	retries := append(media.Retries, retry)
+	if len(retries) > retryHistory {
+		retries = retries[len(retries)-retryHistory:]
+	}
+
+	// Moving the media out of failed claims the retry
+	if err := s.dynamoClient.RetryMedia(ctx, mediaID, retries); err != nil {
+		s.endJob(ctx)
+		return nil, err
+	}
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		s.endJob(ctx)
+		// Leave the media failed, to be retried again
+		if err := s.dynamoClient.UpdateMediaStatus(ctx, mediaID, domain.MediaStatusFailed); err != nil {
+			s.log.Error("failed to restore failed status", "error", err, "media_id", mediaID)
+		}
+		return nil, err
+	}
+
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+
+	s.log.Info("failed media queued for retry", "media_id", mediaID, "job_id", job.ID, "failed_stage", retry.FailedStage)
+
+	return &retry, nil
+}
+
+// processingJob returns a new job processing a media item from its source
+// file
+func processingJob(ctx context.Context, media *domain.Media) *queue.Job {
+	return &queue.Job{
+		ID:       uuid.New().String(),
+		Type:     queue.JobTypeTranscode,
+		MediaID:  media.ID,
+		Priority: 1,
+		Payload: map[string]string{
+			"source_key":    media.SourceKey,
+			"source_bucket": media.SourceBucket,
+		},
+		RequestID: correlation.ID(ctx),
+		TenantID:  tenant.FromContext(ctx),
+	}
+}
+
 // GetPresignedUploadURL generates a presigned URL for client-side upload
 func (s *Service) GetPresignedUploadURL(ctx context.Context, userID, filename, contentType string) (*UploadResponse, error) {
+	// The URL is signed for the content type, so S3 holds the upload to it
+	if err := s.CheckFile(filename, contentType); err != nil {
+		return nil, err
+	}
+
+	// The size is only known once the upload is confirmed
+	if s.quotas != nil {
+		if err := s.quotas.CheckStorage(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	mediaID := uuid.New().String()
 	ext := filepath.Ext(filename)
-	s3Key := fmt.Sprintf("raw/%s%s", mediaID, ext)
+	s3Key := rawKey(ctx, mediaID, ext)
 
 	// Generate presigned URL (valid for 1 hour)
 	url, err := s.s3Client.GetPresignedUploadURL(ctx, s3Key, contentType, time.Hour)
@@ -134,21 +454,55 @@ func (s *Service) GetPresignedUploadURL(ctx context.Context, userID, filename, c
 
 // ConfirmUpload confirms a presigned URL upload and triggers processing
 func (s *Service) ConfirmUpload(ctx context.Context, req *UploadRequest, mediaID string) (*UploadResponse, error) {
+	if !s.valid(req) {
+		return nil, domain.ErrInvalidInput
+	}
+
 	mediaType := processor.DetectMediaType(req.Filename)
 	ext := filepath.Ext(req.Filename)
-	s3Key := fmt.Sprintf("raw/%s%s", mediaID, ext)
+	s3Key := rawKey(ctx, mediaID, ext)
+
+	var size int64
+	if s.quotas != nil {
+		var err error
+		size, err = s.s3Client.Size(ctx, s.s3Client.GetRawBucket(), s3Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find upload: %w", err)
+		}
+		if err := s.reserve(ctx, size); err != nil {
+			// Uploads over quota aren't kept
+			_ = s.s3Client.Delete(ctx, s.s3Client.GetRawBucket(), s3Key)
+			return nil, err
+		}
+	}
 
 	// Create media record
 	media := domain.NewMedia(mediaID, req.Title, req.UserID, mediaType)
 	media.Description = req.Description
+	if req.Visibility != "" {
+		media.Visibility = req.Visibility
+	}
+	media.AutoCaptions = req.AutoCaptions
+	if len(req.Tags) > 0 {
+		media.Tags = req.Tags
+	}
 	media.SourceKey = s3Key
 	media.SourceBucket = s.s3Client.GetRawBucket()
+	media.SourceSize = size
 	media.SourceFormat = ext
+	if s.queue != nil {
+		media.Processing = domain.NewProcessingProgress(domain.ProcessingStageQueued)
+	}
+	s.expire(ctx, media, req.ExpiresAt)
 
-	if err := s.dynamoClient.CreateMedia(ctx, media); err != nil {
+	created := &domain.Event{Type: domain.EventMediaCreated, MediaID: mediaID, UserID: media.UserID}
+	if err := s.dynamoClient.CreateMedia(ctx, media, s.outboxed(created)...); err != nil {
+		s.unreserve(ctx, size)
 		return nil, fmt.Errorf("failed to create media record: %w", err)
 	}
 
+	s.indexTags(ctx, media)
+
 	// Queue transcoding job
 	if s.queue != nil {
 		job := &queue.Job{
@@ -160,14 +514,79 @@ func (s *Service) ConfirmUpload(ctx context.Context, req *UploadRequest, mediaID
 				"source_key":    s3Key,
 				"source_bucket": s.s3Client.GetRawBucket(),
 			},
+			RequestID: correlation.ID(ctx),
+			TenantID:  tenant.FromContext(ctx),
 		}
 		if err := s.queue.Enqueue(ctx, job); err != nil {
 			s.log.Error("failed to enqueue job", "error", err, "media_id", mediaID)
+			s.endJob(ctx)
 		}
 	}
 
+	if s.search != nil {
+		s.search.MediaChanged(ctx, mediaID)
+	}
+	s.publish(ctx, created)
+
 	return &UploadResponse{
 		MediaID: mediaID,
 		Status:  domain.MediaStatusPending,
 	}, nil
 }
+
+// outboxed returns the events to write to the outbox with a change: all
+// of them when the outbox is enabled, otherwise none
+func (s *Service) outboxed(events ...*domain.Event) []*domain.Event {
+	if !s.outbox {
+		return nil
+	}
+	return events
+}
+
+// publish publishes an event after the change it describes, unless it
+// went to the outbox with it
+func (s *Service) publish(ctx context.Context, event *domain.Event) {
+	if s.events != nil && !s.outbox {
+		s.events.Publish(ctx, event)
+	}
+}
+
+// reserve claims an upload's storage, and the job slot to process it,
+// from the tenant's quotas
+func (s *Service) reserve(ctx context.Context, size int64) error {
+	if s.quotas == nil {
+		return nil
+	}
+	if err := s.quotas.ReserveStorage(ctx, size); err != nil {
+		return err
+	}
+	if s.queue != nil {
+		if err := s.quotas.StartJob(ctx); err != nil {
+			s.quotas.AddStorage(ctx, -size)
+			return err
+		}
+	}
+	return nil
+}
+
+// unreserve returns what reserve claimed for an upload that failed
+func (s *Service) unreserve(ctx context.Context, size int64) {
+	if s.quotas == nil {
+		return
+	}
+	s.quotas.AddStorage(ctx, -size)
+	s.endJob(ctx)
+}
+
+// endJob frees the job slot claimed for a job that wasn't queued
+func (s *Service) endJob(ctx context.Context) {
+	if s.quotas != nil && s.queue != nil {
+		s.quotas.EndJob(ctx)
+	}
+}
+
+// rawKey is the key a media item's source file is uploaded to, under the
+// caller's tenant
+func rawKey(ctx context.Context, mediaID, ext string) string {
+	return "raw/" + tenant.KeyPrefix(tenant.FromContext(ctx)) + mediaID + ext
+}
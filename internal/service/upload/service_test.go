@@ -0,0 +1,121 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/queue"
+	"github.com/streaming-service/internal/repository/mocks"
+	"github.com/streaming-service/pkg/logger"
+)
+
+func TestConfirmUpload(t *testing.T) {
+	errStore := errors.New("store unavailable")
+
+	tests := []struct {
+		name string
+		req  *UploadRequest
+		// createErr is returned by the store for the new media record
+		createErr error
+		// wantTags are the tag index keys written, and wantListed whether
+		// they count towards public tag counts
+		wantTags   []string
+		wantListed bool
+		wantErr    error
+		wantJob    bool
+	}{
+		{
+			name:    "creates the record and queues transcoding",
+			req:     &UploadRequest{Title: "Clip", UserID: "user-1", Filename: "clip.mp4"},
+			wantJob: true,
+		},
+		{
+			name:       "indexes tags of public media as listed",
+			req:        &UploadRequest{Title: "Clip", UserID: "user-1", Filename: "clip.mp4", Tags: map[string]string{"genre": "drama"}},
+			wantTags:   []string{"genre", "genre:drama"},
+			wantListed: true,
+			wantJob:    true,
+		},
+		{
+			name:     "indexes tags of private media as unlisted",
+			req:      &UploadRequest{Title: "Clip", UserID: "user-1", Filename: "clip.mp4", Visibility: domain.VisibilityPrivate, Tags: map[string]string{"genre": "drama"}},
+			wantTags: []string{"genre", "genre:drama"},
+			wantJob:  true,
+		},
+		{
+			name:    "rejects an unknown visibility",
+			req:     &UploadRequest{Title: "Clip", UserID: "user-1", Filename: "clip.mp4", Visibility: "secret"},
+			wantErr: domain.ErrInvalidInput,
+		},
+		{
+			name:    "rejects an expiry without retention enabled",
+			req:     &UploadRequest{Title: "Clip", UserID: "user-1", Filename: "clip.mp4", ExpiresAt: new(time.Time)},
+			wantErr: domain.ErrInvalidInput,
+		},
+		{
+			name:      "fails without queuing when the record can't be created",
+			req:       &UploadRequest{Title: "Clip", UserID: "user-1", Filename: "clip.mp4"},
+			createErr: errStore,
+			wantErr:   errStore,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			objects := mocks.NewMockObjectStore(ctrl)
+			store := mocks.NewMockMediaStore(ctrl)
+			tags := mocks.NewMockTagStore(ctrl)
+			jobs := queue.NewMemoryQueue()
+
+			svc := NewService(objects, store, logger.New("error", "json"))
+			svc.SetQueue(jobs)
+			svc.SetTagIndex(tags)
+
+			objects.EXPECT().GetRawBucket().Return("raw-bucket").AnyTimes()
+
+			var created *domain.Media
+			if tt.wantErr != domain.ErrInvalidInput {
+				store.EXPECT().CreateMedia(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, media *domain.Media, _ ...*domain.Event) error {
+						created = media
+						return tt.createErr
+					})
+			}
+			if tt.wantTags != nil {
+				tags.EXPECT().PutTagEntries(gomock.Any(), "media-1", tt.wantTags, tt.wantListed).Return(nil)
+			}
+
+			resp, err := svc.ConfirmUpload(context.Background(), tt.req, "media-1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConfirmUpload() error = %v, want %v", err, tt.wantErr)
+			}
+
+			queued, _ := jobs.Len(context.Background())
+			if tt.wantJob != (queued == 1) {
+				t.Errorf("queued %d jobs, want job %v", queued, tt.wantJob)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if resp.MediaID != "media-1" || resp.Status != domain.MediaStatusPending {
+				t.Errorf("ConfirmUpload() = %+v, want media-1 pending", resp)
+			}
+			if created.UserID != tt.req.UserID || created.Type != domain.MediaTypeVideo {
+				t.Errorf("created media for %q of type %q, want %q video", created.UserID, created.Type, tt.req.UserID)
+			}
+			if created.SourceKey != "raw/media-1.mp4" || created.SourceBucket != "raw-bucket" {
+				t.Errorf("created media with source %s/%s, want raw-bucket/raw/media-1.mp4", created.SourceBucket, created.SourceKey)
+			}
+			if created.Processing == nil || created.Processing.Stage != domain.ProcessingStageQueued {
+				t.Errorf("created media with processing %+v, want queued", created.Processing)
+			}
+		})
+	}
+}
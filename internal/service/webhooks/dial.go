@@ -0,0 +1,76 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress refuses a delivery to an address that isn't on the
+// public internet
+var errPrivateAddress = errors.New("webhook URL must resolve to a public address")
+
+// blockedPrefixes are ranges outside the public internet that
+// netip.Addr's predicates don't cover
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT, also used by clusters
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, reaching IPv4 through the gateway
+	netip.MustParsePrefix("fec0::/10"),
+}
+
+// publicAddress reports whether ip is on the public internet, rather than
+// loopback, link-local (such as the cloud metadata service), private or
+// otherwise reserved
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// refusePrivate is a net.Dialer Control refusing connections to addresses
+// that aren't public. It sees the address each connection is made to
+// after resolution, so a hostname that resolves, or later rebinds, to an
+// internal address is caught.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// newTransport returns the transport deliveries are sent with. Unless
+// allowPrivate, it only connects to public addresses, and never through a
+// proxy, whose address is all the dialer would see.
+func newTransport(allowPrivate bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if allowPrivate {
+		return transport
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   refusePrivate,
+	}
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
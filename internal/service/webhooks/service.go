@@ -0,0 +1,468 @@
+// Package webhooks delivers the lifecycle events of users' media to the
+// URLs they subscribe, signed with each webhook's secret, and keeps a log
+// of every delivery
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/streaming-service/internal/config"
+	"github.com/streaming-service/internal/domain"
+	"github.com/streaming-service/internal/repository/dynamodb"
+	"github.com/streaming-service/internal/tenant"
+	"github.com/streaming-service/pkg/logger"
+)
+
+// MaxWebhooks is how many webhooks a user may have
+const MaxWebhooks = 10
+
+// Headers sent with each delivery
+const (
+	// HeaderDelivery is the delivery's ID, the same on every attempt
+	HeaderDelivery  = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature is "sha256=" and the hex Sign of the request
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// secretBytes is the amount of randomness in a webhook secret
+	secretBytes = 32
+	// secretPrefix marks webhook secrets so they are recognisable in leaks
+	secretPrefix = "whsec_"
+	// claimMargin is how long a claimed delivery is held beyond its
+	// request's timeout, covering recording the outcome
+	claimMargin = 30 * time.Second
+	// responseLimit bounds how much of a response is read
+	responseLimit = 64 << 10
+)
+
+// Service manages webhooks and delivers events to them
+type Service struct {
+	dynamoClient *dynamodb.Client
+	client       *http.Client
+	cfg          config.WebhooksConfig
+	// wake has Run send deliveries as soon as they're recorded
+	wake chan struct{}
+	log  *logger.Logger
+}
+
+// NewService creates a new webhook service
+func NewService(dynamoClient *dynamodb.Client, cfg config.WebhooksConfig, log *logger.Logger) *Service {
+	return &Service{
+		dynamoClient: dynamoClient,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: newTransport(cfg.AllowPrivate),
+			// A redirect is answered like any other non-2xx response
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg:  cfg,
+		wake: make(chan struct{}, 1),
+		log:  log,
+	}
+}
+
+// CreateWebhookRequest contains the fields for a new webhook
+type CreateWebhookRequest struct {
+	UserID      string
+	URL         string
+	Description string
+	Events      []domain.EventType
+}
+
+// UpdateWebhookRequest contains the fields of a webhook to change; nil
+// fields are left as they are
+type UpdateWebhookRequest struct {
+	URL         *string
+	Description *string
+	Events      []domain.EventType
+	Enabled     *bool
+}
+
+// ValidURL reports whether u is an http or https URL deliveries can be
+// sent to
+func ValidURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	return parsed.Host != ""
+}
+
+// validEvents reports whether events names at least one event type, all
+// of which webhooks can subscribe to
+func validEvents(events []domain.EventType) bool {
+	for _, e := range events {
+		if !domain.IsWebhookEvent(e) {
+			return false
+		}
+	}
+	return len(events) > 0
+}
+
+// CreateWebhook subscribes a URL to the events of the user's media. The
+// webhook is returned with its signing secret, which is only shown here.
+func (s *Service) CreateWebhook(ctx context.Context, req *CreateWebhookRequest) (*domain.Webhook, error) {
+	if !ValidURL(req.URL) || !validEvents(req.Events) {
+		return nil, domain.ErrInvalidInput
+	}
+
+	existing, err := s.dynamoClient.ListWebhooksByUser(ctx, req.UserID, MaxWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= MaxWebhooks {
+		return nil, domain.ErrInvalidInput
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	webhook := &domain.Webhook{
+		ID:          uuid.New().String(),
+		UserID:      req.UserID,
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		Enabled:     true,
+		Secret:      secret,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.dynamoClient.CreateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("webhook created", "webhook_id", webhook.ID, "user_id", req.UserID, "events", req.Events)
+
+	return webhook, nil
+}
+
+// ListWebhooks returns the user's webhooks
+func (s *Service) ListWebhooks(ctx context.Context, userID string) ([]*domain.Webhook, error) {
+	return s.dynamoClient.ListWebhooksByUser(ctx, userID, MaxWebhooks)
+}
+
+// GetWebhook returns one of the user's webhooks
+func (s *Service) GetWebhook(ctx context.Context, webhookID, userID string) (*domain.Webhook, error) {
+	webhook, err := s.dynamoClient.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if webhook.UserID != userID {
+		return nil, domain.ErrUnauthorized
+	}
+
+	return webhook, nil
+}
+
+// UpdateWebhook changes one of the user's webhooks. Deliveries already
+// recorded are sent to its new URL.
+func (s *Service) UpdateWebhook(ctx context.Context, webhookID, userID string, req *UpdateWebhookRequest) (*domain.Webhook, error) {
+	if (req.URL != nil && !ValidURL(*req.URL)) || (req.Events != nil && !validEvents(req.Events)) {
+		return nil, domain.ErrInvalidInput
+	}
+
+	webhook, err := s.GetWebhook(ctx, webhookID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		webhook.URL = *req.URL
+	}
+	if req.Description != nil {
+		webhook.Description = *req.Description
+	}
+	if req.Events != nil {
+		webhook.Events = req.Events
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	webhook.UpdatedAt = time.Now().UTC()
+
+	if err := s.dynamoClient.UpdateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	s.log.Info("webhook updated", "webhook_id", webhookID, "user_id", userID)
+
+	return webhook, nil
+}
+
+// DeleteWebhook deletes one of the user's webhooks. Its pending deliveries
+// fail when they come due.
+func (s *Service) DeleteWebhook(ctx context.Context, webhookID, userID string) error {
+	if _, err := s.GetWebhook(ctx, webhookID, userID); err != nil {
+		return err
+	}
+
+	if err := s.dynamoClient.DeleteWebhook(ctx, webhookID); err != nil {
+		return err
+	}
+
+	s.log.Info("webhook deleted", "webhook_id", webhookID, "user_id", userID)
+
+	return nil
+}
+
+// ListDeliveries returns a page of the delivery log of one of the user's
+// webhooks, newest first, and the cursor of the next page
+func (s *Service) ListDeliveries(ctx context.Context, webhookID, userID string, limit int32, cursor string) ([]*domain.WebhookDelivery, string, error) {
+	if _, err := s.GetWebhook(ctx, webhookID, userID); err != nil {
+		return nil, "", err
+	}
+
+	return s.dynamoClient.ListWebhookDeliveries(ctx, webhookID, limit, cursor)
+}
+
+// HandleEvent records a delivery of an event to each of its owner's
+// webhooks that subscribe to it, for Run to send. A delivery's ID is
+// derived from the event and webhook, so an event handled again, as the
+// outbox may, is still only sent once.
+func (s *Service) HandleEvent(ctx context.Context, event *domain.Event) error {
+	if !domain.IsWebhookEvent(event.Type) {
+		return nil
+	}
+
+	userID := event.UserID
+	if userID == "" {
+		media, err := s.dynamoClient.GetMedia(ctx, event.MediaID)
+		if err == domain.ErrMediaNotFound {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get media: %w", err)
+		}
+		userID = media.UserID
+	}
+
+	webhooks, err := s.dynamoClient.ListWebhooksByUser(ctx, userID, MaxWebhooks)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	recorded := false
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event.Type) {
+			continue
+		}
+
+		created, err := s.dynamoClient.CreateWebhookDelivery(ctx, &domain.WebhookDelivery{
+			ID:            uuid.NewSHA1(uuid.NameSpaceURL, []byte(event.ID+"/"+webhook.ID)).String(),
+			WebhookID:     webhook.ID,
+			Event:         *event,
+			Status:        domain.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+		recorded = recorded || created
+	}
+
+	if recorded {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Run sends due deliveries every interval, and as soon as HandleEvent
+// records any in this process, until ctx is cancelled
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.SendDue(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error("failed to send webhook deliveries", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// SendDue makes an attempt at each due delivery it can claim, longest
+// overdue first
+func (s *Service) SendDue(ctx context.Context) error {
+	cursor := ""
+	for {
+		deliveries, next, err := s.dynamoClient.ListDueWebhookDeliveries(ctx, time.Now(), int32(s.cfg.BatchSize), cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			now := time.Now()
+			claimed, err := s.dynamoClient.ClaimWebhookDelivery(ctx, delivery.ID, now, now.Add(s.cfg.Timeout+claimMargin))
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+
+			s.send(tenant.WithID(ctx, delivery.TenantID), delivery)
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// send makes one attempt at a claimed delivery and records the outcome,
+// scheduling a retry with backoff until the last attempt has failed. A
+// delivery whose webhook was deleted or disabled fails without a request.
+func (s *Service) send(ctx context.Context, delivery *domain.WebhookDelivery) {
+	log := logger.FromContext(ctx, s.log).WithFields("delivery_id", delivery.ID,
+		"webhook_id", delivery.WebhookID, "event", delivery.Event.Type)
+
+	webhook, err := s.dynamoClient.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil && err != domain.ErrWebhookNotFound {
+		// Left claimed, to be tried again once the claim runs out
+		log.Error("failed to get webhook", "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case webhook == nil:
+		s.finish(delivery, domain.WebhookDeliveryFailed, "webhook was deleted", now)
+	case !webhook.Enabled:
+		s.finish(delivery, domain.WebhookDeliveryFailed, "webhook was disabled", now)
+	default:
+		delivery.Attempts++
+		delivery.ResponseStatus, err = s.post(ctx, webhook, delivery)
+		now = time.Now().UTC()
+		switch {
+		case err == nil:
+			s.finish(delivery, domain.WebhookDeliverySucceeded, "", now)
+			delivery.DeliveredAt = &now
+			log.Info("webhook delivered", "attempts", delivery.Attempts)
+		case delivery.Attempts >= s.cfg.MaxAttempts:
+			s.finish(delivery, domain.WebhookDeliveryFailed, err.Error(), now)
+			log.Warn("webhook delivery failed", "error", err, "attempts", delivery.Attempts)
+		default:
+			next := now.Add(s.backoff(delivery.Attempts)).Truncate(time.Second)
+			delivery.NextAttemptAt = &next
+			delivery.Error = err.Error()
+			log.Debug("webhook delivery will be retried", "error", err, "attempts", delivery.Attempts, "next_attempt_at", next)
+		}
+	}
+
+	if err := s.dynamoClient.SaveWebhookDelivery(ctx, delivery); err != nil {
+		log.Error("failed to record webhook delivery", "error", err)
+	}
+}
+
+// finish ends a delivery, taking it out of the due index and keeping it
+// in the log for the retention
+func (s *Service) finish(delivery *domain.WebhookDelivery, status domain.WebhookDeliveryStatus, reason string, now time.Time) {
+	delivery.Status = status
+	delivery.Error = reason
+	delivery.NextAttemptAt = nil
+	delivery.ExpiresAt = now.Add(s.cfg.Retention).Unix()
+}
+
+// backoff returns the wait after a delivery's attempts have failed,
+// doubling from the configured backoff up to its maximum
+func (s *Service) backoff(attempts int) time.Duration {
+	wait := s.cfg.Backoff
+	for i := 1; i < attempts && wait < s.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, s.cfg.MaxBackoff)
+}
+
+// post sends an event to a webhook, returning the response status. Any
+// status but 2xx is an error.
+func (s *Service) post(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(&delivery.Event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderEvent, string(delivery.Event.Type))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, responseLimit))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256, under a webhook's secret, of a
+// delivery's timestamp and body joined by a dot. Receivers recompute it
+// to check a delivery came from this service, and reject old timestamps
+// to stop replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateSecret returns a new random webhook secret
+func generateSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,63 @@
+// Package tenant carries the customer a request or job acts for, so every
+// record it reads or writes can be kept to that customer's data
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default is the tenant of callers and records that carry none, so a
+// single-tenant deployment and records written before tenancy keep working
+// unchanged
+const Default = "default"
+
+// validID restricts tenant IDs to what is safe in S3 keys and index keys
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type idKey struct{}
+
+// Valid reports whether id may be used as a tenant ID: up to 63 lowercase
+// letters, digits and dashes, not starting with a dash
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// Of returns the tenant a record belongs to given its tenant ID, which is
+// empty for records written before tenancy
+func Of(id string) string {
+	if id == "" {
+		return Default
+	}
+	return id
+}
+
+// WithID returns a context scoped to the tenant
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, Of(id))
+}
+
+// FromContext returns the tenant ctx is scoped to, or Default when it
+// isn't scoped
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(idKey{}).(string); ok {
+		return id
+	}
+	return Default
+}
+
+// Detach returns a context for background work started by ctx, scoped to
+// the same tenant but not cancelled with it
+func Detach(ctx context.Context) context.Context {
+	return WithID(context.Background(), FromContext(ctx))
+}
+
+// KeyPrefix returns the storage prefix of a tenant's objects. The default
+// tenant keeps the original unprefixed layout; others are kept under
+// "tenants/<id>/".
+func KeyPrefix(id string) string {
+	if Of(id) == Default {
+		return ""
+	}
+	return "tenants/" + id + "/"
+}
@@ -0,0 +1,107 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes why one field of a request is invalid
+type FieldError struct {
+	// Field is the JSON path of the field, such as "media_ids[2]"
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field of a request
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validator collects field errors. Checks of a field are skipped once it
+// has failed one, so each field is reported once.
+type Validator struct {
+	errors Errors
+	failed map[string]bool
+}
+
+// Validatable is a request body that can check its own fields
+type Validatable interface {
+	Validate(v *Validator)
+}
+
+// Struct runs a request's checks, returning its field errors or nil
+func Struct(req Validatable) Errors {
+	v := &Validator{}
+	req.Validate(v)
+	return v.errors
+}
+
+// Check records message against field unless ok holds
+func (v *Validator) Check(ok bool, field, message string) {
+	if ok || v.failed[field] {
+		return
+	}
+	if v.failed == nil {
+		v.failed = make(map[string]bool)
+	}
+	v.failed[field] = true
+	v.errors = append(v.errors, FieldError{Field: field, Message: message})
+}
+
+// Required checks that a string field is not empty
+func (v *Validator) Required(field, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// MaxLength checks that a string field is at most max characters
+func (v *Validator) MaxLength(field, value string, max int) {
+	v.Check(utf8.RuneCountInString(value) <= max, field, fmt.Sprintf("must be at most %d characters", max))
+}
+
+// OneOf checks that a string field, when set, is one of allowed
+func (v *Validator) OneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.Check(false, field, "must be one of "+strings.Join(allowed, ", "))
+}
+
+// Min checks that a number field is at least min
+func (v *Validator) Min(field string, value float64, min float64) {
+	v.Check(value >= min, field, fmt.Sprintf("must be at least %g", min))
+}
+
+// Items checks that a list field holds between min and max items
+func (v *Validator) Items(field string, n, min, max int) {
+	switch {
+	case min > 0 && n == 0:
+		v.Check(false, field, "is required")
+	case min == max:
+		v.Check(n == min, field, fmt.Sprintf("must hold exactly %d items", min))
+	default:
+		v.Check(n >= min && n <= max, field, fmt.Sprintf("must hold between %d and %d items", min, max))
+	}
+}
+
+// UniqueIDs checks that every ID in a list field is non-empty and unique
+func (v *Validator) UniqueIDs(field string, ids []string) {
+	seen := make(map[string]bool, len(ids))
+	for i, id := range ids {
+		item := fmt.Sprintf("%s[%d]", field, i)
+		v.Required(item, id)
+		v.Check(id == "" || !seen[id], item, "is a duplicate")
+		seen[id] = true
+	}
+}
@@ -0,0 +1,94 @@
+// Package workflow runs multi-step processing as an ordered list of
+// steps, each with its own status, retries and condition, reporting every
+// change of status so it can be recorded as it happens
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/streaming-service/internal/domain"
+)
+
+// retryDelay is the wait before a step's first retry; each further retry
+// waits one more delay
+const retryDelay = time.Second
+
+// Step is one step of a workflow
+type Step struct {
+	Name string
+	// When, if set, runs the step only if it returns true, and skips it
+	// otherwise. It is evaluated as the step is reached, so it can branch
+	// on what earlier steps found.
+	When func() bool
+	Run  func(ctx context.Context) error
+	// Retries is how many more times a failing step runs before it fails
+	Retries int
+	// Optional steps failing doesn't stop the workflow
+	Optional bool
+}
+
+// Observer is told of each change of a step's status
+type Observer func(step domain.WorkflowStep)
+
+// Pending returns the status of steps not yet run
+func Pending(steps []Step) []domain.WorkflowStep {
+	statuses := make([]domain.WorkflowStep, 0, len(steps))
+	for _, step := range steps {
+		statuses = append(statuses, domain.WorkflowStep{Name: step.Name, Status: domain.StepStatusPending})
+	}
+	return statuses
+}
+
+// Run runs the steps in order until one that isn't optional fails,
+// returning its error. The steps after it are left pending.
+func Run(ctx context.Context, steps []Step, observe Observer) error {
+	for _, step := range steps {
+		status := domain.WorkflowStep{Name: step.Name}
+		if step.When != nil && !step.When() {
+			status.Status = domain.StepStatusSkipped
+			observe(status)
+			continue
+		}
+
+		err := run(ctx, step, &status, observe)
+		now := time.Now().UTC()
+		status.FinishedAt = &now
+		if err != nil {
+			status.Status = domain.StepStatusFailed
+			status.Error = err.Error()
+			observe(status)
+			if step.Optional {
+				continue
+			}
+			return err
+		}
+		status.Status = domain.StepStatusSucceeded
+		status.Error = ""
+		observe(status)
+	}
+	return nil
+}
+
+// run runs a step, retrying it while it fails and has retries left
+func run(ctx context.Context, step Step, status *domain.WorkflowStep, observe Observer) error {
+	for {
+		now := time.Now().UTC()
+		status.Status = domain.StepStatusRunning
+		status.Attempts++
+		status.StartedAt = &now
+		observe(*status)
+
+		err := step.Run(ctx)
+		if err == nil || status.Attempts > step.Retries || ctx.Err() != nil {
+			return err
+		}
+		status.Error = err.Error()
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(status.Attempts) * retryDelay):
+		}
+	}
+}
@@ -1,7 +1,8 @@
 package logger
 
 import (
-	"os"
+	"context"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -10,16 +11,68 @@ import (
 // Logger wraps zap.SugaredLogger for structured logging
 type Logger struct {
 	*zap.SugaredLogger
+	level zap.AtomicLevel
 }
 
-// New creates a new Logger instance
+// New creates a new Logger instance writing to stdout
 func New(level, format string) *Logger {
+	// Writing to stdout can't fail
+	l, _ := NewWithOptions(Options{Level: level, Format: format})
+	return l
+}
+
+// NewWithOptions creates a new Logger writing to every output in opts
+func NewWithOptions(opts Options) (*Logger, error) {
 	// Parse log level
-	var zapLevel zapcore.Level
-	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
-		zapLevel = zapcore.InfoLevel
+	zapLevel := zap.NewAtomicLevel()
+	if err := zapLevel.UnmarshalText([]byte(opts.Level)); err != nil {
+		zapLevel.SetLevel(zapcore.InfoLevel)
+	}
+
+	outputs := opts.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{OutputStdout}
+	}
+
+	// Create a core for each output
+	cores := make([]zapcore.Core, 0, len(outputs))
+	for _, output := range outputs {
+		sink, err := openOutput(output, opts)
+		if err != nil {
+			return nil, err
+		}
+		// Only terminals get colored levels
+		color := output == OutputStdout || output == OutputStderr
+		encoder := newEncoder(opts.Format, color)
+
+		if opts.Sampling.Initial <= 0 {
+			cores = append(cores, zapcore.NewCore(encoder, sink, zapLevel))
+			continue
+		}
+
+		// Sample debug entries so a noisy debug session can't flood the
+		// output; other levels are always written
+		debug := zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+			return l == zapcore.DebugLevel && zapLevel.Enabled(l)
+		})
+		rest := zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+			return l > zapcore.DebugLevel && zapLevel.Enabled(l)
+		})
+		cores = append(cores,
+			zapcore.NewSamplerWithOptions(zapcore.NewCore(encoder, sink, debug),
+				time.Second, opts.Sampling.Initial, opts.Sampling.Thereafter),
+			zapcore.NewCore(encoder, sink, rest),
+		)
 	}
 
+	// Create logger with caller info
+	logger := zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.AddCallerSkip(1))
+
+	return &Logger{logger.Sugar(), zapLevel}, nil
+}
+
+// newEncoder creates the encoder for format, "console" or "json"
+func newEncoder(format string, color bool) zapcore.Encoder {
 	// Create encoder config
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.TimeKey = "timestamp"
@@ -27,33 +80,69 @@ func New(level, format string) *Logger {
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 
 	// Create encoder based on format
-	var encoder zapcore.Encoder
 	if format == "console" {
-		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-		encoder = zapcore.NewConsoleEncoder(encoderConfig)
-	} else {
-		encoder = zapcore.NewJSONEncoder(encoderConfig)
+		if color {
+			encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		return zapcore.NewConsoleEncoder(encoderConfig)
 	}
+	return zapcore.NewJSONEncoder(encoderConfig)
+}
+
+// Level returns the minimum level logged
+func (l *Logger) Level() string {
+	return l.level.String()
+}
 
-	// Create core
-	core := zapcore.NewCore(
-		encoder,
-		zapcore.AddSync(os.Stdout),
-		zapLevel,
-	)
+// SetLevel changes the minimum level logged by this logger and every
+// logger derived from it
+func (l *Logger) SetLevel(level string) error {
+	return l.level.UnmarshalText([]byte(level))
+}
 
-	// Create logger with caller info
-	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
+// Debug logs a message with alternating keys and values
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Debugw(msg, keysAndValues...)
+}
+
+// Info logs a message with alternating keys and values
+func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Infow(msg, keysAndValues...)
+}
+
+// Warn logs a message with alternating keys and values
+func (l *Logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Warnw(msg, keysAndValues...)
+}
 
-	return &Logger{logger.Sugar()}
+// Error logs a message with alternating keys and values
+func (l *Logger) Error(msg string, keysAndValues ...interface{}) {
+	l.SugaredLogger.Errorw(msg, keysAndValues...)
 }
 
 // WithFields returns a new Logger with additional fields
 func (l *Logger) WithFields(fields ...interface{}) *Logger {
-	return &Logger{l.SugaredLogger.With(fields...)}
+	return &Logger{l.SugaredLogger.With(fields...), l.level}
 }
 
 // WithError returns a new Logger with error field
 func (l *Logger) WithError(err error) *Logger {
-	return &Logger{l.SugaredLogger.With("error", err.Error())}
+	return &Logger{l.SugaredLogger.With("error", err.Error()), l.level}
+}
+
+type loggerKey struct{}
+
+// NewContext returns a context carrying l, so work started for a request
+// or job logs with its fields
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or fallback when it has
+// none
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return fallback
 }
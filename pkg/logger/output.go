@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"fmt"
+	"log/syslog"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Outputs a Logger can write to
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file"
+	OutputSyslog = "syslog"
+)
+
+// Options configures a Logger
+type Options struct {
+	Level  string
+	Format string
+	// Outputs lists where logs are written; every entry goes to all of
+	// them. Defaults to stdout.
+	Outputs []string
+	File    FileOptions
+	Syslog  SyslogOptions
+	// Sampling limits how many debug entries are written
+	Sampling SamplingOptions
+}
+
+// SamplingOptions limits debug entries with the same message to the
+// first Initial each second, then every Thereafter-th. Zero Initial
+// writes them all.
+type SamplingOptions struct {
+	Initial    int
+	Thereafter int
+}
+
+// FileOptions configures the file output and its rotation
+type FileOptions struct {
+	Path string
+	// MaxSizeMB is the size a file is rotated at
+	MaxSizeMB int
+	// MaxAgeDays and MaxBackups bound how many rotated files are kept;
+	// zero keeps them all
+	MaxAgeDays int
+	MaxBackups int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// SyslogOptions configures the syslog output
+type SyslogOptions struct {
+	// Network and Address locate a remote syslog daemon; when empty the
+	// local daemon is used
+	Network string
+	Address string
+	Tag     string
+}
+
+// openOutput opens the named output for writing
+func openOutput(output string, opts Options) (zapcore.WriteSyncer, error) {
+	switch output {
+	case OutputStdout:
+		return zapcore.Lock(os.Stdout), nil
+
+	case OutputStderr:
+		return zapcore.Lock(os.Stderr), nil
+
+	case OutputFile:
+		if opts.File.Path == "" {
+			return nil, fmt.Errorf("a path is required for the file log output")
+		}
+		return zapcore.AddSync(&lumberjack.Logger{
+			Filename:   opts.File.Path,
+			MaxSize:    opts.File.MaxSizeMB,
+			MaxAge:     opts.File.MaxAgeDays,
+			MaxBackups: opts.File.MaxBackups,
+			Compress:   opts.File.Compress,
+		}), nil
+
+	case OutputSyslog:
+		w, err := syslog.Dial(opts.Syslog.Network, opts.Syslog.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, opts.Syslog.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		return zapcore.AddSync(w), nil
+	}
+
+	return nil, fmt.Errorf("unknown log output %q", output)
+}
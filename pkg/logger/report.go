@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Reporter sends error entries to an error tracking service
+type Reporter interface {
+	// Report receives each entry logged at error level or above, with its
+	// fields including those added by WithFields
+	Report(entry zapcore.Entry, fields map[string]interface{})
+}
+
+// WithReporter returns a Logger that also sends error entries to r
+func (l *Logger) WithReporter(r Reporter) *Logger {
+	wrapped := l.SugaredLogger.Desugar().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, &reportingCore{reporter: r})
+	}))
+	return &Logger{wrapped.Sugar(), l.level}
+}
+
+// reportingCore passes error entries and their fields to a Reporter
+type reportingCore struct {
+	reporter Reporter
+	fields   []zapcore.Field
+}
+
+func (c *reportingCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.ErrorLevel
+}
+
+func (c *reportingCore) With(fields []zapcore.Field) zapcore.Core {
+	merged := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	merged = append(merged, c.fields...)
+	merged = append(merged, fields...)
+	return &reportingCore{reporter: c.reporter, fields: merged}
+}
+
+func (c *reportingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *reportingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	c.reporter.Report(entry, enc.Fields)
+	return nil
+}
+
+func (c *reportingCore) Sync() error {
+	return nil
+}
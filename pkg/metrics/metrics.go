@@ -0,0 +1,341 @@
+// Package metrics keeps counters, histograms and gauges and serves them in
+// the Prometheus text exposition format, so Prometheus can scrape the API
+// and worker without a client library
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets suit latencies in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets returns count buckets, the first start and each
+// factor times the one before
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// collectTimeout bounds gauges collected as they are scraped
+const collectTimeout = 5 * time.Second
+
+// metric is a family of series written by a Registry
+type metric interface {
+	write(ctx context.Context, w io.Writer)
+}
+
+// Registry holds metrics and writes them for scraping
+type Registry struct {
+	mu      sync.Mutex
+	names   map[string]bool
+	metrics []metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Default is the registry the package-level constructors register in
+var Default = NewRegistry()
+
+// register adds a metric, panicking if its name is taken, as the
+// metrics are declared once when their package loads
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes every metric in the text exposition format
+func (r *Registry) WriteTo(ctx context.Context, w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(ctx, w)
+	}
+}
+
+// Handler serves the registry's metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(req.Context(), w)
+	})
+}
+
+// Handler serves the default registry's metrics
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// family holds the series of a metric, one per combination of label
+// values
+type family[S any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*S
+	values map[string][]string
+	newS   func() *S
+}
+
+func newFamily[S any](name, help, kind string, labels []string, newS func() *S) *family[S] {
+	return &family[S]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*S),
+		values: make(map[string][]string),
+		newS:   newS,
+	}
+}
+
+// get returns the series of the label values, creating it the first time
+func (f *family[S]) get(values []string) *S {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = f.newS()
+		f.series[key] = s
+		f.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// each calls fn for each series in order of label values, holding the
+// family's lock
+func (f *family[S]) each(fn func(values []string, s *S)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fn(f.values[key], f.series[key])
+	}
+}
+
+func (f *family[S]) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+}
+
+// Counter is a family of values that only go up
+type Counter struct {
+	f *family[float64]
+}
+
+// NewCounter registers a counter in the default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter registers a counter, with a series per combination of the
+// labels' values
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{f: newFamily(name, help, "counter", labels, func() *float64 { return new(float64) })}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which mustn't be negative, to the series of the label
+// values
+func (c *Counter) Add(v float64, values ...string) {
+	s := c.f.get(values)
+	c.f.mu.Lock()
+	*s += v
+	c.f.mu.Unlock()
+}
+
+func (c *Counter) write(_ context.Context, w io.Writer) {
+	c.f.header(w)
+	c.f.each(func(values []string, s *float64) {
+		fmt.Fprintf(w, "%s%s %s\n", c.f.name, labelPairs(c.f.labels, values), formatFloat(*s))
+	})
+}
+
+// Histogram is a family of distributions of observed values
+type Histogram struct {
+	f       *family[histogramSeries]
+	buckets []float64
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram in the default registry
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram registers a histogram counting observations into buckets
+// by their upper bounds, which must be increasing
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{buckets: buckets}
+	h.f = newFamily(name, help, "histogram", labels, func() *histogramSeries {
+		return &histogramSeries{counts: make([]uint64, len(buckets))}
+	})
+	r.register(name, h)
+	return h
+}
+
+// Observe records v in the series of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := h.f.get(values)
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Since records the seconds since start in the series of the label values
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *Histogram) write(_ context.Context, w io.Writer) {
+	h.f.header(w)
+	labels := append(append([]string(nil), h.f.labels...), "le")
+	h.f.each(func(values []string, s *histogramSeries) {
+		bucketValues := append(append([]string(nil), values...), "")
+		for i, bound := range h.buckets {
+			bucketValues[len(values)] = formatFloat(bound)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.name, labelPairs(labels, bucketValues), s.counts[i])
+		}
+		bucketValues[len(values)] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.f.name, labelPairs(labels, bucketValues), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.f.name, labelPairs(h.f.labels, values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.f.name, labelPairs(h.f.labels, values), s.count)
+	})
+}
+
+// GaugeFunc is a gauge read as it is scraped, such as the length of a
+// queue kept elsewhere
+type GaugeFunc struct {
+	name    string
+	help    string
+	label   string
+	collect func(ctx context.Context) (map[string]float64, error)
+}
+
+// NewGaugeFunc registers a gauge func in the default registry
+func NewGaugeFunc(name, help, label string, collect func(ctx context.Context) (map[string]float64, error)) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, label, collect)
+}
+
+// NewGaugeFunc registers a gauge whose values collect returns by the
+// value of its one label. A gauge that fails to collect is left out of
+// the scrape.
+func (r *Registry) NewGaugeFunc(name, help, label string, collect func(ctx context.Context) (map[string]float64, error)) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, label: label, collect: collect}
+	r.register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(ctx context.Context, w io.Writer) {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+
+	values, err := g.collect(ctx)
+	if err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, escapeHelp(g.help), g.name)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labelPairs([]string{g.label}, []string{key}), formatFloat(values[key]))
+	}
+}
+
+// labelPairs formats labels and their values as {name="value",...}
+func labelPairs(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}